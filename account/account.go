@@ -0,0 +1,669 @@
+package account
+
+import (
+	"context"
+	"errors"
+	repository "order-book/account/repository/gen"
+	"order-book/logger"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInsufficientBalance   = errors.New("Insufficient balance")
+	ErrIdempotencyKeyReused  = errors.New("Idempotency key was already used for a different request")
+	ErrUnbalancedTransaction = errors.New("Ledger transaction postings do not sum to zero")
+	ErrInvalidPosting        = errors.New("Ledger posting should target exactly one account")
+	ErrInsufficientHeld      = errors.New("Insufficient held balance")
+	ErrInvalidAmount         = errors.New("Amount should be positive")
+	ErrAmountScale           = errors.New("Amount has more decimal places than the ledger keeps")
+)
+
+// repoLog logs the balance repositories under the repo component
+var repoLog = logger.Component("repo")
+
+type EntryType string
+
+const (
+	DEPOSIT    EntryType = "DEPOSIT"
+	WITHDRAWAL EntryType = "WITHDRAWAL"
+	TRANSFER   EntryType = "TRANSFER"
+	// FUNDING is a funding payment of a position on a perpetual pair
+	FUNDING EntryType = "FUNDING"
+	// LIQUIDATION settles the losses and fees of a liquidated position
+	LIQUIDATION EntryType = "LIQUIDATION"
+	// FEE is a trading fee charged in the asset the account chose to pay its
+	// fees in
+	FEE EntryType = "FEE"
+	// REBATE is paid to a maker on a negative maker fee rate
+	REBATE EntryType = "REBATE"
+	// NETTING is the net of the fees and rebates of an account in netting
+	// mode over a settlement window
+	NETTING EntryType = "NETTING"
+)
+
+// Source tells who initiated a balance movement
+type Source string
+
+const (
+	ADMIN    Source = "ADMIN"
+	PAYMENTS Source = "PAYMENTS"
+	// SANDBOX funds are fictional, credited to the accounts of the sandbox
+	SANDBOX Source = "SANDBOX"
+	// ENGINE movements are made by the venue on its own, like funding
+	ENGINE Source = "ENGINE"
+	// ACCOUNT movements are asked for by the holder of the account, like the
+	// transfers between sub-accounts
+	ACCOUNT Source = "ACCOUNT"
+)
+
+// SystemAccount is a venue owned ledger account that takes the other side of
+// user postings, so every transaction sums to zero.
+type SystemAccount string
+
+const (
+	// EXTERNAL is the counterpart of funds entering or leaving the venue
+	EXTERNAL SystemAccount = "EXTERNAL"
+	FEES     SystemAccount = "FEES"
+	// FUNDING_POOL passes the funding payments of the positions on one side
+	// of a perpetual pair to those on the other
+	FUNDING_POOL SystemAccount = "FUNDING_POOL"
+	// INSURANCE_FUND collects the losses and fees of liquidated positions
+	// and absorbs what the collateral of bankrupt accounts can't cover
+	INSURANCE_FUND SystemAccount = "INSURANCE_FUND"
+)
+
+// LedgerScale is the number of decimal places the ledger keeps, amounts are
+// checked against it so they're stored as they were posted and their exact
+// sums are the ones the database checks on commit
+const LedgerScale = 10
+
+type Balance struct {
+	AccountID int             `json:"account_id"`
+	Asset     string          `json:"asset"`
+	Available decimal.Decimal `json:"available"`
+	Held      decimal.Decimal `json:"held"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Posting is a single leg of a ledger transaction. Exactly one of AccountID
+// and SystemAccount is set, Amount is positive for credits and negative for debits.
+// System accounts have no balance, BalanceAfter is only set on user postings.
+type Posting struct {
+	ID            int              `json:"id,omitempty"`
+	AccountID     int              `json:"account_id,omitempty"`
+	SystemAccount SystemAccount    `json:"system_account,omitempty"`
+	Asset         string           `json:"asset"`
+	Amount        decimal.Decimal  `json:"amount"`
+	BalanceAfter  *decimal.Decimal `json:"balance_after,omitempty"`
+
+	// fromHeld debits the held part of the balance instead of the available one
+	fromHeld bool
+}
+
+type Transaction struct {
+	ID             int       `json:"id"`
+	Type           EntryType `json:"type"`
+	IdempotencyKey string    `json:"idempotency_key"`
+	Source         Source    `json:"source"`
+	Reference      string    `json:"reference,omitempty"`
+	Postings       []Posting `json:"postings"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// LedgerEntry is the view of a single user posting in the account history.
+type LedgerEntry struct {
+	ID             int             `json:"id"`
+	TransactionID  int             `json:"transaction_id"`
+	AccountID      int             `json:"account_id"`
+	Asset          string          `json:"asset"`
+	Type           EntryType       `json:"type"`
+	Amount         decimal.Decimal `json:"amount"`
+	BalanceAfter   decimal.Decimal `json:"balance_after"`
+	IdempotencyKey string          `json:"idempotency_key"`
+	Source         Source          `json:"source"`
+	Reference      string          `json:"reference,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type Movement struct {
+	AccountID      int
+	Asset          string
+	Amount         decimal.Decimal
+	IdempotencyKey string
+	Source         Source
+	Reference      string
+}
+
+// Transfer moves funds between two user accounts. With FromHeld the funds are
+// taken from the held part of the sender balance, e.g. to settle a hold.
+type Transfer struct {
+	FromAccountID  int
+	ToAccountID    int
+	Asset          string
+	Amount         decimal.Decimal
+	FromHeld       bool
+	IdempotencyKey string
+	Source         Source
+	Reference      string
+}
+
+type AssetTotal struct {
+	Asset string          `json:"asset"`
+	Total decimal.Decimal `json:"total"`
+}
+
+type SystemAccountTotal struct {
+	SystemAccount SystemAccount   `json:"system_account"`
+	Asset         string          `json:"asset"`
+	Total         decimal.Decimal `json:"total"`
+}
+
+type BalanceMismatch struct {
+	AccountID   int             `json:"account_id"`
+	Asset       string          `json:"asset"`
+	Balance     decimal.Decimal `json:"balance"`
+	LedgerTotal decimal.Decimal `json:"ledger_total"`
+}
+
+// ReconciliationReport compares the ledger against itself (every asset sums to
+// zero) and against the materialized balances.
+type ReconciliationReport struct {
+	Balanced       bool                 `json:"balanced"`
+	Totals         []AssetTotal         `json:"totals"`
+	SystemAccounts []SystemAccountTotal `json:"system_accounts"`
+	Mismatches     []BalanceMismatch    `json:"mismatches"`
+	GeneratedAt    time.Time            `json:"generated_at"`
+}
+
+type BalanceRepo interface {
+	// Deposit and Withdraw return replayed=true when the idempotency key was
+	// already processed, in which case the original entry is returned.
+	Deposit(m Movement) (entry LedgerEntry, replayed bool, err error)
+	Withdraw(m Movement) (entry LedgerEntry, replayed bool, err error)
+	// Post records a balanced transaction and applies its user postings to the balances
+	Post(t Transaction) (created Transaction, replayed bool, err error)
+	// Hold moves funds from available to held and Release moves them back.
+	// The balance row stays locked until the change is committed.
+	Hold(accountId int, asset string, amount decimal.Decimal) (Balance, error)
+	Release(accountId int, asset string, amount decimal.Decimal) (Balance, error)
+	// Transfer posts a TRANSFER transaction between two user accounts
+	Transfer(t Transfer) (created Transaction, replayed bool, err error)
+	GetBalances(accountId int) ([]Balance, error)
+	GetLedger(accountId int, page int, size int) ([]LedgerEntry, error)
+	Reconcile() (ReconciliationReport, error)
+}
+
+type balanceRepo struct {
+	queries *repository.Queries
+	dbpool  *pgxpool.Pool
+}
+
+func (repo *balanceRepo) Deposit(m Movement) (LedgerEntry, bool, error) {
+	return repo.move(DEPOSIT, m, m.Amount)
+}
+
+func (repo *balanceRepo) Withdraw(m Movement) (LedgerEntry, bool, error) {
+	return repo.move(WITHDRAWAL, m, m.Amount.Neg())
+}
+
+func (repo *balanceRepo) move(entryType EntryType, m Movement, amount decimal.Decimal) (LedgerEntry, bool, error) {
+	created, replayed, err := repo.Post(Transaction{
+		Type:           entryType,
+		IdempotencyKey: m.IdempotencyKey,
+		Source:         m.Source,
+		Reference:      m.Reference,
+		Postings: []Posting{
+			{AccountID: m.AccountID, Asset: m.Asset, Amount: amount},
+			{SystemAccount: EXTERNAL, Asset: m.Asset, Amount: amount.Neg()},
+		},
+	})
+	if err != nil {
+		return LedgerEntry{}, false, err
+	}
+	userPosting := created.Postings[0]
+	return LedgerEntry{
+		ID:             userPosting.ID,
+		TransactionID:  created.ID,
+		AccountID:      userPosting.AccountID,
+		Asset:          userPosting.Asset,
+		Type:           created.Type,
+		Amount:         userPosting.Amount,
+		BalanceAfter:   *userPosting.BalanceAfter,
+		IdempotencyKey: created.IdempotencyKey,
+		Source:         created.Source,
+		Reference:      created.Reference,
+		CreatedAt:      created.CreatedAt,
+	}, replayed, nil
+}
+
+func (repo *balanceRepo) Post(t Transaction) (Transaction, bool, error) {
+	if err := validatePostings(t.Postings); err != nil {
+		return Transaction{}, false, err
+	}
+
+	existing, err := repo.queries.GetLedgerTransactionByIdempotencyKey(context.Background(), t.IdempotencyKey)
+	if err == nil {
+		return repo.replay(t, existing)
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return Transaction{}, false, err
+	}
+
+	tx, err := repo.dbpool.Begin(context.Background())
+	if err != nil {
+		return Transaction{}, false, err
+	}
+	defer tx.Rollback(context.Background())
+
+	qtx := repo.queries.WithTx(tx)
+	createdTx, err := qtx.InsertLedgerTransaction(context.Background(), repository.InsertLedgerTransactionParams{
+		TxType:         string(t.Type),
+		IdempotencyKey: t.IdempotencyKey,
+		Source:         string(t.Source),
+		Reference:      pgtype.Text{String: t.Reference, Valid: t.Reference != ""},
+	})
+	if err != nil {
+		// A concurrent request with the same key won the race, answer with its transaction
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			tx.Rollback(context.Background())
+			existing, err := repo.queries.GetLedgerTransactionByIdempotencyKey(context.Background(), t.IdempotencyKey)
+			if err != nil {
+				return Transaction{}, false, err
+			}
+			return repo.replay(t, existing)
+		}
+		return Transaction{}, false, err
+	}
+
+	// Balances are touched in a stable order so concurrent transactions over
+	// the same accounts can't deadlock each other
+	balanceOrder := make([]int, 0, len(t.Postings))
+	for idx, p := range t.Postings {
+		if p.AccountID != 0 {
+			balanceOrder = append(balanceOrder, idx)
+		}
+	}
+	slices.SortFunc(balanceOrder, func(a, b int) int {
+		pa, pb := t.Postings[a], t.Postings[b]
+		if pa.AccountID != pb.AccountID {
+			return pa.AccountID - pb.AccountID
+		}
+		return strings.Compare(pa.Asset, pb.Asset)
+	})
+
+	balancesAfter := make(map[int]decimal.Decimal, len(balanceOrder))
+	for _, idx := range balanceOrder {
+		p := t.Postings[idx]
+		var balance repository.TblBalance
+		if p.Amount.IsPositive() {
+			balance, err = qtx.CreditBalance(context.Background(), repository.CreditBalanceParams{
+				AccountID: int32(p.AccountID),
+				Asset:     p.Asset,
+				Amount:    p.Amount,
+			})
+		} else if p.fromHeld {
+			balance, err = qtx.DebitHeldBalance(context.Background(), repository.DebitHeldBalanceParams{
+				AccountID: int32(p.AccountID),
+				Asset:     p.Asset,
+				Amount:    p.Amount.Neg(),
+			})
+			if errors.Is(err, pgx.ErrNoRows) {
+				return Transaction{}, false, ErrInsufficientHeld
+			}
+		} else {
+			balance, err = qtx.DebitBalance(context.Background(), repository.DebitBalanceParams{
+				AccountID: int32(p.AccountID),
+				Asset:     p.Asset,
+				Amount:    p.Amount.Neg(),
+			})
+			if errors.Is(err, pgx.ErrNoRows) {
+				return Transaction{}, false, ErrInsufficientBalance
+			}
+		}
+		if err != nil {
+			return Transaction{}, false, err
+		}
+		balancesAfter[idx] = balance.Available
+	}
+
+	postings := make([]repository.TblLedgerPosting, len(t.Postings))
+	for idx, p := range t.Postings {
+		balanceAfter, ok := balancesAfter[idx]
+		posting, err := qtx.InsertLedgerPosting(context.Background(), repository.InsertLedgerPostingParams{
+			TransactionID: createdTx.ID,
+			AccountID:     pgtype.Int4{Int32: int32(p.AccountID), Valid: p.AccountID != 0},
+			SystemAccount: pgtype.Text{String: string(p.SystemAccount), Valid: p.SystemAccount != ""},
+			Asset:         p.Asset,
+			Amount:        p.Amount,
+			BalanceAfter:  decimal.NullDecimal{Decimal: balanceAfter, Valid: ok},
+		})
+		if err != nil {
+			return Transaction{}, false, err
+		}
+		postings[idx] = posting
+	}
+
+	// The deferred balance trigger runs here and rejects unbalanced transactions
+	if err := tx.Commit(context.Background()); err != nil {
+		repoLog.Error("failed to commit ledger transaction", map[string]any{
+			"type":            t.Type,
+			"idempotency_key": t.IdempotencyKey,
+			"error":           err,
+		})
+		return Transaction{}, false, err
+	}
+
+	created, err := convertTransaction(createdTx, postings)
+	if err != nil {
+		return Transaction{}, false, err
+	}
+	repoLog.Info("ledger transaction posted", map[string]any{
+		"transaction_id": created.ID,
+		"type":           created.Type,
+		"postings":       len(created.Postings),
+	})
+	return created, false, nil
+}
+
+func (repo *balanceRepo) Transfer(t Transfer) (Transaction, bool, error) {
+	if !t.Amount.IsPositive() {
+		return Transaction{}, false, ErrInvalidAmount
+	}
+	if t.FromAccountID == t.ToAccountID {
+		return Transaction{}, false, ErrInvalidPosting
+	}
+	return repo.Post(Transaction{
+		Type:           TRANSFER,
+		IdempotencyKey: t.IdempotencyKey,
+		Source:         t.Source,
+		Reference:      t.Reference,
+		Postings: []Posting{
+			{AccountID: t.FromAccountID, Asset: t.Asset, Amount: t.Amount.Neg(), fromHeld: t.FromHeld},
+			{AccountID: t.ToAccountID, Asset: t.Asset, Amount: t.Amount},
+		},
+	})
+}
+
+func (repo *balanceRepo) Hold(accountId int, asset string, amount decimal.Decimal) (Balance, error) {
+	return repo.adjustHold(accountId, asset, amount, true)
+}
+
+func (repo *balanceRepo) Release(accountId int, asset string, amount decimal.Decimal) (Balance, error) {
+	return repo.adjustHold(accountId, asset, amount, false)
+}
+
+// adjustHold moves amount between the available and held parts of a balance.
+// The row is locked before checking the funds so a concurrent hold can't
+// spend the same available balance.
+func (repo *balanceRepo) adjustHold(accountId int, asset string, amount decimal.Decimal, hold bool) (Balance, error) {
+	if err := validateAmount(amount); err != nil {
+		return Balance{}, err
+	}
+	errInsufficient := ErrInsufficientHeld
+	if hold {
+		errInsufficient = ErrInsufficientBalance
+	}
+
+	tx, err := repo.dbpool.Begin(context.Background())
+	if err != nil {
+		return Balance{}, err
+	}
+	defer tx.Rollback(context.Background())
+
+	qtx := repo.queries.WithTx(tx)
+	balance, err := lockBalance(context.Background(), qtx, accountId, asset)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Balance{}, errInsufficient
+	}
+	if err != nil {
+		return Balance{}, err
+	}
+
+	value := amount
+	if hold {
+		if balance.Available.LessThan(value) {
+			return Balance{}, errInsufficient
+		}
+		balance, err = qtx.HoldBalance(context.Background(), repository.HoldBalanceParams{
+			AccountID: int32(accountId),
+			Asset:     asset,
+			Amount:    value,
+		})
+	} else {
+		if balance.Held.LessThan(value) {
+			return Balance{}, errInsufficient
+		}
+		balance, err = qtx.ReleaseBalance(context.Background(), repository.ReleaseBalanceParams{
+			AccountID: int32(accountId),
+			Asset:     asset,
+			Amount:    value,
+		})
+	}
+	if err != nil {
+		return Balance{}, err
+	}
+
+	if err := tx.Commit(context.Background()); err != nil {
+		repoLog.Error("failed to commit balance hold", map[string]any{
+			"account_id": accountId,
+			"asset":      asset,
+			"hold":       hold,
+			"error":      err,
+		})
+		return Balance{}, err
+	}
+	return convertBalance(balance)
+}
+
+// lockBalance reads a balance with SELECT ... FOR UPDATE, other transactions
+// touching the row wait until qtx commits or rolls back
+func lockBalance(ctx context.Context, qtx *repository.Queries, accountId int, asset string) (repository.TblBalance, error) {
+	return qtx.LockBalance(ctx, repository.LockBalanceParams{
+		AccountID: int32(accountId),
+		Asset:     asset,
+	})
+}
+
+func (repo *balanceRepo) replay(t Transaction, existing repository.TblLedgerTransaction) (Transaction, bool, error) {
+	postings, err := repo.queries.GetPostingsByTransaction(context.Background(), existing.ID)
+	if err != nil {
+		return Transaction{}, false, err
+	}
+	created, err := convertTransaction(existing, postings)
+	if err != nil {
+		return Transaction{}, false, err
+	}
+	if created.Type != t.Type || len(created.Postings) != len(t.Postings) {
+		return Transaction{}, false, ErrIdempotencyKeyReused
+	}
+	for idx, p := range created.Postings {
+		requested := t.Postings[idx]
+		if p.AccountID != requested.AccountID || p.SystemAccount != requested.SystemAccount ||
+			p.Asset != requested.Asset || !p.Amount.Equal(requested.Amount) {
+			return Transaction{}, false, ErrIdempotencyKeyReused
+		}
+	}
+	return created, true, nil
+}
+
+func validatePostings(postings []Posting) error {
+	if len(postings) < 2 {
+		return ErrUnbalancedTransaction
+	}
+	sums := make(map[string]decimal.Decimal)
+	for _, p := range postings {
+		if (p.AccountID == 0) == (p.SystemAccount == "") || p.Amount.IsZero() {
+			return ErrInvalidPosting
+		}
+		if !p.Amount.Equal(p.Amount.Truncate(LedgerScale)) {
+			return ErrAmountScale
+		}
+		sums[p.Asset] = sums[p.Asset].Add(p.Amount)
+	}
+	for _, sum := range sums {
+		if !sum.IsZero() {
+			return ErrUnbalancedTransaction
+		}
+	}
+	return nil
+}
+
+// validateAmount checks an amount moved within a balance, like a hold
+func validateAmount(amount decimal.Decimal) error {
+	if !amount.IsPositive() {
+		return ErrInvalidAmount
+	}
+	if !amount.Equal(amount.Truncate(LedgerScale)) {
+		return ErrAmountScale
+	}
+	return nil
+}
+
+func (repo *balanceRepo) GetBalances(accountId int) ([]Balance, error) {
+	dbres, err := repo.queries.GetBalancesByAccount(context.Background(), int32(accountId))
+	if err != nil {
+		return nil, err
+	}
+	balances := make([]Balance, len(dbres))
+	for idx, b := range dbres {
+		balance, err := convertBalance(b)
+		if err != nil {
+			return nil, err
+		}
+		balances[idx] = balance
+	}
+	return balances, nil
+}
+
+func (repo *balanceRepo) GetLedger(accountId int, page int, size int) ([]LedgerEntry, error) {
+	dbres, err := repo.queries.GetLedgerEntriesByAccount(context.Background(), repository.GetLedgerEntriesByAccountParams{
+		AccountID: pgtype.Int4{Int32: int32(accountId), Valid: true},
+		Limit:     int32(size),
+		Offset:    int32(max(0, page-1) * size),
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]LedgerEntry, len(dbres))
+	for idx, e := range dbres {
+		entry, err := convertLedgerEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		entries[idx] = entry
+	}
+	return entries, nil
+}
+
+func (repo *balanceRepo) Reconcile() (ReconciliationReport, error) {
+	report := ReconciliationReport{Balanced: true, GeneratedAt: time.Now()}
+
+	totals, err := repo.queries.GetLedgerTotals(context.Background())
+	if err != nil {
+		return report, err
+	}
+	for _, t := range totals {
+		if !t.Total.IsZero() {
+			report.Balanced = false
+		}
+		report.Totals = append(report.Totals, AssetTotal{Asset: t.Asset, Total: t.Total})
+	}
+
+	systemTotals, err := repo.queries.GetSystemAccountTotals(context.Background())
+	if err != nil {
+		return report, err
+	}
+	for _, t := range systemTotals {
+		report.SystemAccounts = append(report.SystemAccounts, SystemAccountTotal{
+			SystemAccount: SystemAccount(t.SystemAccount),
+			Asset:         t.Asset,
+			Total:         t.Total,
+		})
+	}
+
+	mismatches, err := repo.queries.GetBalanceMismatches(context.Background())
+	if err != nil {
+		return report, err
+	}
+	for _, m := range mismatches {
+		report.Balanced = false
+		report.Mismatches = append(report.Mismatches, BalanceMismatch{
+			AccountID:   int(m.AccountID),
+			Asset:       m.Asset,
+			Balance:     m.Balance,
+			LedgerTotal: m.LedgerTotal,
+		})
+	}
+
+	return report, nil
+}
+
+func NewBalanceRepository(dbpool *pgxpool.Pool) BalanceRepo {
+	return &balanceRepo{
+		queries: repository.New(dbpool),
+		dbpool:  dbpool,
+	}
+}
+
+func convertBalance(b repository.TblBalance) (res Balance, err error) {
+	res.AccountID = int(b.AccountID)
+	res.Asset = b.Asset
+	res.Available = b.Available
+	res.Held = b.Held
+	res.UpdatedAt = b.UpdatedAt.Time
+	return
+}
+
+func convertTransaction(t repository.TblLedgerTransaction, postings []repository.TblLedgerPosting) (res Transaction, err error) {
+	res.ID = int(t.ID)
+	res.Type = EntryType(t.TxType)
+	res.IdempotencyKey = t.IdempotencyKey
+	res.Source = Source(t.Source)
+	res.Reference = t.Reference.String
+	res.CreatedAt = t.CreatedAt.Time
+	res.Postings = make([]Posting, len(postings))
+	for idx, p := range postings {
+		res.Postings[idx] = Posting{
+			ID:            int(p.ID),
+			AccountID:     int(p.AccountID.Int32),
+			SystemAccount: SystemAccount(p.SystemAccount.String),
+			Asset:         p.Asset,
+			Amount:        p.Amount,
+			BalanceAfter:  balanceAfterOf(p.BalanceAfter),
+		}
+	}
+	return
+}
+
+func convertLedgerEntry(e repository.GetLedgerEntriesByAccountRow) (res LedgerEntry, err error) {
+	res.ID = int(e.ID)
+	res.TransactionID = int(e.TransactionID)
+	res.AccountID = int(e.AccountID.Int32)
+	res.Asset = e.Asset
+	res.Type = EntryType(e.TxType)
+	res.Amount = e.Amount
+	res.BalanceAfter = e.BalanceAfter.Decimal
+	res.IdempotencyKey = e.IdempotencyKey
+	res.Source = Source(e.Source)
+	res.Reference = e.Reference.String
+	res.CreatedAt = e.CreatedAt.Time
+	return
+}
+
+// balanceAfterOf is the balance after of a posting, nil for the system
+// accounts which have none
+func balanceAfterOf(d decimal.NullDecimal) *decimal.Decimal {
+	if !d.Valid {
+		return nil
+	}
+	return &d.Decimal
+}
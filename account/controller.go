@@ -0,0 +1,223 @@
+package account
+
+import (
+	"errors"
+	"net/http"
+	"order-book/logger"
+	"order-book/order"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrFieldRequired = errors.New("ErrFieldRequired")
+	ErrInvalidData   = errors.New("ErrInvalidData")
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// movementRequest takes the amount as a JSON number or string, it's checked
+// against the precision of the asset before it's applied
+type movementRequest struct {
+	Asset     string          `json:"asset"`
+	Amount    decimal.Decimal `json:"amount"`
+	Source    Source          `json:"source"`
+	Reference string          `json:"reference"`
+}
+
+// BindAccountRouter serves the balances and the ledger of the accounts.
+// Deposits and withdrawals move funds in and out of the engine, they're
+// operator actions under /admin and no API key of an account reaches them.
+// So is the reconciliation, which reports on the accounts of every tenant.
+func BindAccountRouter(r fiber.Router, balanceRepo BalanceRepo) {
+	r.Post("/admin/accounts/:id/deposits", func(c *fiber.Ctx) error {
+		return handleMovement(c, balanceRepo.Deposit, order.ValidateAmount)
+	})
+	r.Post("/admin/accounts/:id/withdrawals", func(c *fiber.Ctx) error {
+		return handleMovement(c, balanceRepo.Withdraw, order.ValidateWithdrawal)
+	})
+
+	r.Get("/accounts/:id/balances", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+
+		balances, err := balanceRepo.GetBalances(accountId)
+		if err != nil {
+			logger.Error("failed to get balances", map[string]any{
+				"account_id": accountId,
+				"error":      err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    balances,
+		})
+	})
+
+	r.Get("/admin/ledger/reconciliation", func(c *fiber.Ctx) error {
+		report, err := balanceRepo.Reconcile()
+		if err != nil {
+			logger.Error("failed to reconcile ledger", map[string]any{
+				"error": err,
+			})
+			return err
+		}
+		if !report.Balanced {
+			logger.Error("ledger is out of balance", map[string]any{
+				"totals":     report.Totals,
+				"mismatches": len(report.Mismatches),
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    report,
+		})
+	})
+
+	r.Get("/accounts/:id/ledger", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		page := c.QueryInt("page", 1)
+		size := c.QueryInt("size", 50)
+
+		entries, err := balanceRepo.GetLedger(accountId, page, size)
+		if err != nil {
+			logger.Error("failed to get ledger", map[string]any{
+				"account_id": accountId,
+				"error":      err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    entries,
+		})
+	})
+}
+
+func handleMovement(c *fiber.Ctx, apply func(m Movement) (LedgerEntry, bool, error), validate func(asset string, amount decimal.Decimal) error) error {
+	accountId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return c.JSON(&Response{
+			Message: "Invalid account ID",
+			Data:    nil,
+		})
+	}
+
+	idempotencyKey := c.Get("Idempotency-Key")
+	if idempotencyKey == "" {
+		c.Status(http.StatusBadRequest)
+		return c.JSON(&Response{
+			Error:   ErrFieldRequired,
+			Message: "Idempotency-Key header is required",
+		})
+	}
+
+	var req movementRequest
+	if err := c.BodyParser(&req); err != nil {
+		return err
+	}
+	if req.Asset == "" {
+		c.Status(http.StatusBadRequest)
+		return c.JSON(&Response{
+			Error:   ErrFieldRequired,
+			Message: "Please provide an asset",
+		})
+	}
+	if !req.Amount.IsPositive() {
+		c.Status(http.StatusBadRequest)
+		return c.JSON(&Response{
+			Error:   ErrInvalidData,
+			Message: "Amount should be a positive number",
+		})
+	}
+	if err := validate(req.Asset, req.Amount); err != nil {
+		c.Status(http.StatusUnprocessableEntity)
+		return c.JSON(&Response{
+			Error:   err,
+			Message: err.Error(),
+		})
+	}
+	if req.Source == "" {
+		req.Source = ADMIN
+	}
+	if req.Source != ADMIN && req.Source != PAYMENTS {
+		c.Status(http.StatusBadRequest)
+		return c.JSON(&Response{
+			Error:   ErrInvalidData,
+			Message: "Source should be either ADMIN or PAYMENTS",
+		})
+	}
+
+	entry, replayed, err := apply(Movement{
+		AccountID:      accountId,
+		Asset:          req.Asset,
+		Amount:         req.Amount,
+		IdempotencyKey: idempotencyKey,
+		Source:         req.Source,
+		Reference:      req.Reference,
+	})
+	if err == ErrInsufficientBalance {
+		c.Status(http.StatusUnprocessableEntity)
+		return c.JSON(&Response{
+			Message: "Insufficient balance",
+			Data:    nil,
+		})
+	}
+	if err == ErrAmountScale {
+		c.Status(http.StatusUnprocessableEntity)
+		return c.JSON(&Response{
+			Error:   err,
+			Message: err.Error(),
+		})
+	}
+	if err == ErrIdempotencyKeyReused {
+		c.Status(http.StatusConflict)
+		return c.JSON(&Response{
+			Message: "Idempotency key was already used for a different request",
+			Data:    nil,
+		})
+	}
+	if err != nil {
+		logger.Error("failed to apply balance movement", map[string]any{
+			"account_id":      accountId,
+			"asset":           req.Asset,
+			"idempotency_key": idempotencyKey,
+			"error":           err,
+		})
+		return err
+	}
+
+	if replayed {
+		c.Status(http.StatusOK)
+	} else {
+		c.Status(http.StatusCreated)
+	}
+	return c.JSON(&Response{
+		Message: "Balance updated successfully",
+		Data:    entry,
+	})
+}
@@ -0,0 +1,76 @@
+-- name: GetBalancesByAccount :many
+SELECT * FROM tbl_balances WHERE account_id = $1 ORDER BY asset;
+
+-- name: CreditBalance :one
+INSERT INTO tbl_balances (account_id, asset, available)
+VALUES (@account_id, @asset, @amount::DECIMAL)
+ON CONFLICT (account_id, asset) DO UPDATE
+SET available = tbl_balances.available + EXCLUDED.available, updated_at = NOW()
+RETURNING *;
+
+-- name: DebitBalance :one
+UPDATE tbl_balances SET available = available - @amount::DECIMAL, updated_at = NOW()
+WHERE account_id = @account_id AND asset = @asset AND available >= @amount::DECIMAL
+RETURNING *;
+
+-- name: LockBalance :one
+SELECT * FROM tbl_balances WHERE account_id = @account_id AND asset = @asset FOR UPDATE;
+
+-- name: HoldBalance :one
+UPDATE tbl_balances SET available = available - @amount::DECIMAL, held = held + @amount::DECIMAL, updated_at = NOW()
+WHERE account_id = @account_id AND asset = @asset
+RETURNING *;
+
+-- name: ReleaseBalance :one
+UPDATE tbl_balances SET available = available + @amount::DECIMAL, held = held - @amount::DECIMAL, updated_at = NOW()
+WHERE account_id = @account_id AND asset = @asset
+RETURNING *;
+
+-- name: DebitHeldBalance :one
+UPDATE tbl_balances SET held = held - @amount::DECIMAL, updated_at = NOW()
+WHERE account_id = @account_id AND asset = @asset AND held >= @amount::DECIMAL
+RETURNING *;
+
+-- name: InsertLedgerTransaction :one
+INSERT INTO tbl_ledger_transactions (tx_type, idempotency_key, source, reference)
+VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: InsertLedgerPosting :one
+INSERT INTO tbl_ledger_postings (transaction_id, account_id, system_account, asset, amount, balance_after)
+VALUES ($1, $2, $3, $4, $5, $6) RETURNING *;
+
+-- name: GetLedgerTransactionByIdempotencyKey :one
+SELECT * FROM tbl_ledger_transactions WHERE idempotency_key = $1;
+
+-- name: GetPostingsByTransaction :many
+SELECT * FROM tbl_ledger_postings WHERE transaction_id = $1 ORDER BY id;
+
+-- name: GetLedgerEntriesByAccount :many
+SELECT p.id, p.transaction_id, p.account_id, p.asset, p.amount, p.balance_after, p.created_at,
+       t.tx_type, t.idempotency_key, t.source, t.reference
+FROM tbl_ledger_postings p
+JOIN tbl_ledger_transactions t ON t.id = p.transaction_id
+WHERE p.account_id = $1
+ORDER BY p.id DESC LIMIT $2 OFFSET $3;
+
+-- name: GetLedgerTotals :many
+SELECT asset, SUM(amount)::DECIMAL AS total FROM tbl_ledger_postings GROUP BY asset ORDER BY asset;
+
+-- name: GetSystemAccountTotals :many
+SELECT system_account::VARCHAR AS system_account, asset, SUM(amount)::DECIMAL AS total
+FROM tbl_ledger_postings
+WHERE system_account IS NOT NULL
+GROUP BY system_account, asset
+ORDER BY system_account, asset;
+
+-- name: GetBalanceMismatches :many
+SELECT b.account_id, b.asset, (b.available + b.held)::DECIMAL AS balance, COALESCE(p.total, 0)::DECIMAL AS ledger_total
+FROM tbl_balances b
+LEFT JOIN (
+    SELECT account_id, asset, SUM(amount) AS total
+    FROM tbl_ledger_postings
+    WHERE account_id IS NOT NULL
+    GROUP BY account_id, asset
+) p ON p.account_id = b.account_id AND p.asset = b.asset
+WHERE (b.available + b.held) <> COALESCE(p.total, 0)
+ORDER BY b.account_id, b.asset;
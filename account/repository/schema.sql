@@ -0,0 +1,32 @@
+CREATE TABLE tbl_balances (
+    account_id INTEGER NOT NULL REFERENCES tbl_accounts(id),
+    asset VARCHAR(25) NOT NULL,
+    available DECIMAL(20, 10) NOT NULL DEFAULT 0,
+    held DECIMAL(20, 10) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (account_id, asset),
+    CHECK (available >= 0 AND held >= 0)
+);
+
+CREATE TABLE tbl_ledger_transactions (
+    id BIGSERIAL PRIMARY KEY,
+    tx_type VARCHAR(25) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL UNIQUE,
+    source VARCHAR(25) NOT NULL,
+    reference VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE tbl_ledger_postings (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id BIGINT NOT NULL REFERENCES tbl_ledger_transactions(id),
+    account_id INTEGER REFERENCES tbl_accounts(id),
+    system_account VARCHAR(25),
+    asset VARCHAR(25) NOT NULL,
+    amount DECIMAL(20, 10) NOT NULL,
+    balance_after DECIMAL(20, 10),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    CHECK ((account_id IS NULL) <> (system_account IS NULL))
+);
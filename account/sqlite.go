@@ -0,0 +1,522 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"order-book/db/sqlite"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// sqliteBalanceRepo keeps the ledger of balanceRepo on SQLite. Decimals are
+// stored as text, so the sums and the funds checks run here rather than in
+// SQL, inside transactions that SQLite serializes.
+type sqliteBalanceRepo struct {
+	db *sql.DB
+}
+
+func (repo *sqliteBalanceRepo) Deposit(m Movement) (LedgerEntry, bool, error) {
+	return repo.move(DEPOSIT, m, m.Amount)
+}
+
+func (repo *sqliteBalanceRepo) Withdraw(m Movement) (LedgerEntry, bool, error) {
+	return repo.move(WITHDRAWAL, m, m.Amount.Neg())
+}
+
+func (repo *sqliteBalanceRepo) move(entryType EntryType, m Movement, amount decimal.Decimal) (LedgerEntry, bool, error) {
+	created, replayed, err := repo.Post(Transaction{
+		Type:           entryType,
+		IdempotencyKey: m.IdempotencyKey,
+		Source:         m.Source,
+		Reference:      m.Reference,
+		Postings: []Posting{
+			{AccountID: m.AccountID, Asset: m.Asset, Amount: amount},
+			{SystemAccount: EXTERNAL, Asset: m.Asset, Amount: amount.Neg()},
+		},
+	})
+	if err != nil {
+		return LedgerEntry{}, false, err
+	}
+	userPosting := created.Postings[0]
+	return LedgerEntry{
+		ID:             userPosting.ID,
+		TransactionID:  created.ID,
+		AccountID:      userPosting.AccountID,
+		Asset:          userPosting.Asset,
+		Type:           created.Type,
+		Amount:         userPosting.Amount,
+		BalanceAfter:   *userPosting.BalanceAfter,
+		IdempotencyKey: created.IdempotencyKey,
+		Source:         created.Source,
+		Reference:      created.Reference,
+		CreatedAt:      created.CreatedAt,
+	}, replayed, nil
+}
+
+// sqliteBalance is a balance row with its exact amounts
+type sqliteBalance struct {
+	available decimal.Decimal
+	held      decimal.Decimal
+	updatedAt time.Time
+}
+
+func getSQLiteBalance(ctx context.Context, tx *sql.Tx, accountId int, asset string) (b sqliteBalance, err error) {
+	err = tx.QueryRowContext(ctx,
+		"SELECT available, held, updated_at FROM tbl_balances WHERE account_id = ? AND asset = ?",
+		accountId, asset,
+	).Scan(&b.available, &b.held, &b.updatedAt)
+	return
+}
+
+func putSQLiteBalance(ctx context.Context, tx *sql.Tx, accountId int, asset string, b sqliteBalance) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO tbl_balances (account_id, asset, available, held, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (account_id, asset) DO UPDATE
+		SET available = excluded.available, held = excluded.held, updated_at = excluded.updated_at`,
+		accountId, asset, b.available.String(), b.held.String(), b.updatedAt,
+	)
+	return err
+}
+
+func (repo *sqliteBalanceRepo) Post(t Transaction) (Transaction, bool, error) {
+	if err := validatePostings(t.Postings); err != nil {
+		return Transaction{}, false, err
+	}
+	ctx := context.Background()
+
+	existing, err := repo.getTransaction(ctx, t.IdempotencyKey)
+	if err == nil {
+		return repo.replay(t, existing)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return Transaction{}, false, err
+	}
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Transaction{}, false, err
+	}
+	defer tx.Rollback()
+
+	now := sqlite.Now()
+	created := Transaction{
+		Type:           t.Type,
+		IdempotencyKey: t.IdempotencyKey,
+		Source:         t.Source,
+		Reference:      t.Reference,
+		CreatedAt:      now,
+	}
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO tbl_ledger_transactions (tx_type, idempotency_key, source, reference, created_at) VALUES (?, ?, ?, ?, ?) RETURNING id",
+		string(t.Type), t.IdempotencyKey, string(t.Source), sql.NullString{String: t.Reference, Valid: t.Reference != ""}, now,
+	).Scan(&created.ID)
+	if sqlite.IsUniqueViolation(err) {
+		tx.Rollback()
+		existing, err := repo.getTransaction(ctx, t.IdempotencyKey)
+		if err != nil {
+			return Transaction{}, false, err
+		}
+		return repo.replay(t, existing)
+	}
+	if err != nil {
+		return Transaction{}, false, err
+	}
+
+	// Same order as balanceRepo, SQLite doesn't need it but the balance
+	// after of each posting should match whatever backend wrote it
+	balanceOrder := make([]int, 0, len(t.Postings))
+	for idx, p := range t.Postings {
+		if p.AccountID != 0 {
+			balanceOrder = append(balanceOrder, idx)
+		}
+	}
+	slices.SortFunc(balanceOrder, func(a, b int) int {
+		pa, pb := t.Postings[a], t.Postings[b]
+		if pa.AccountID != pb.AccountID {
+			return pa.AccountID - pb.AccountID
+		}
+		return strings.Compare(pa.Asset, pb.Asset)
+	})
+
+	balancesAfter := make(map[int]decimal.Decimal, len(balanceOrder))
+	for _, idx := range balanceOrder {
+		p := t.Postings[idx]
+		amount := p.Amount
+		// A credit creates the balance, a debit needs it to exist
+		balance, err := getSQLiteBalance(ctx, tx, p.AccountID, p.Asset)
+		missing := errors.Is(err, sql.ErrNoRows)
+		if err != nil && !missing {
+			return Transaction{}, false, err
+		}
+		switch {
+		case amount.IsPositive():
+			balance.available = balance.available.Add(amount)
+		case p.fromHeld:
+			if missing || balance.held.LessThan(amount.Neg()) {
+				return Transaction{}, false, ErrInsufficientHeld
+			}
+			balance.held = balance.held.Add(amount)
+		default:
+			if missing || balance.available.LessThan(amount.Neg()) {
+				return Transaction{}, false, ErrInsufficientBalance
+			}
+			balance.available = balance.available.Add(amount)
+		}
+		balance.updatedAt = now
+		if err := putSQLiteBalance(ctx, tx, p.AccountID, p.Asset, balance); err != nil {
+			return Transaction{}, false, err
+		}
+		balancesAfter[idx] = balance.available
+	}
+
+	created.Postings = make([]Posting, len(t.Postings))
+	for idx, p := range t.Postings {
+		balanceAfter, ok := balancesAfter[idx]
+		posting := Posting{
+			AccountID:     p.AccountID,
+			SystemAccount: p.SystemAccount,
+			Asset:         p.Asset,
+			Amount:        p.Amount,
+		}
+		if ok {
+			posting.BalanceAfter = &balanceAfter
+		}
+		err := tx.QueryRowContext(ctx, `INSERT INTO tbl_ledger_postings
+			(transaction_id, account_id, system_account, asset, amount, balance_after, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+			created.ID,
+			sql.NullInt64{Int64: int64(p.AccountID), Valid: p.AccountID != 0},
+			sql.NullString{String: string(p.SystemAccount), Valid: p.SystemAccount != ""},
+			p.Asset,
+			p.Amount.String(),
+			sql.NullString{String: balanceAfter.String(), Valid: ok},
+			now,
+		).Scan(&posting.ID)
+		if err != nil {
+			return Transaction{}, false, err
+		}
+		created.Postings[idx] = posting
+	}
+
+	if err := tx.Commit(); err != nil {
+		repoLog.Error("failed to commit ledger transaction", map[string]any{
+			"type":            t.Type,
+			"idempotency_key": t.IdempotencyKey,
+			"error":           err,
+		})
+		return Transaction{}, false, err
+	}
+	repoLog.Info("ledger transaction posted", map[string]any{
+		"transaction_id": created.ID,
+		"type":           created.Type,
+		"postings":       len(created.Postings),
+	})
+	return created, false, nil
+}
+
+// getTransaction reads a transaction by its idempotency key along with its postings
+func (repo *sqliteBalanceRepo) getTransaction(ctx context.Context, idempotencyKey string) (Transaction, error) {
+	var (
+		res       Transaction
+		reference sql.NullString
+	)
+	err := repo.db.QueryRowContext(ctx,
+		"SELECT id, tx_type, idempotency_key, source, reference, created_at FROM tbl_ledger_transactions WHERE idempotency_key = ?",
+		idempotencyKey,
+	).Scan(&res.ID, &res.Type, &res.IdempotencyKey, &res.Source, &reference, &res.CreatedAt)
+	if err != nil {
+		return Transaction{}, err
+	}
+	res.Reference = reference.String
+
+	rows, err := repo.db.QueryContext(ctx,
+		"SELECT id, account_id, system_account, asset, amount, balance_after FROM tbl_ledger_postings WHERE transaction_id = ? ORDER BY id",
+		res.ID,
+	)
+	if err != nil {
+		return Transaction{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			p             Posting
+			accountId     sql.NullInt64
+			systemAccount sql.NullString
+			amount        decimal.Decimal
+			balanceAfter  decimal.NullDecimal
+		)
+		if err := rows.Scan(&p.ID, &accountId, &systemAccount, &p.Asset, &amount, &balanceAfter); err != nil {
+			return Transaction{}, err
+		}
+		p.AccountID = int(accountId.Int64)
+		p.SystemAccount = SystemAccount(systemAccount.String)
+		p.Amount = amount
+		p.BalanceAfter = balanceAfterOf(balanceAfter)
+		res.Postings = append(res.Postings, p)
+	}
+	return res, rows.Err()
+}
+
+func (repo *sqliteBalanceRepo) replay(t Transaction, existing Transaction) (Transaction, bool, error) {
+	if existing.Type != t.Type || len(existing.Postings) != len(t.Postings) {
+		return Transaction{}, false, ErrIdempotencyKeyReused
+	}
+	for idx, p := range existing.Postings {
+		requested := t.Postings[idx]
+		if p.AccountID != requested.AccountID || p.SystemAccount != requested.SystemAccount ||
+			p.Asset != requested.Asset || !p.Amount.Equal(requested.Amount) {
+			return Transaction{}, false, ErrIdempotencyKeyReused
+		}
+	}
+	return existing, true, nil
+}
+
+func (repo *sqliteBalanceRepo) Transfer(t Transfer) (Transaction, bool, error) {
+	if !t.Amount.IsPositive() {
+		return Transaction{}, false, ErrInvalidAmount
+	}
+	if t.FromAccountID == t.ToAccountID {
+		return Transaction{}, false, ErrInvalidPosting
+	}
+	return repo.Post(Transaction{
+		Type:           TRANSFER,
+		IdempotencyKey: t.IdempotencyKey,
+		Source:         t.Source,
+		Reference:      t.Reference,
+		Postings: []Posting{
+			{AccountID: t.FromAccountID, Asset: t.Asset, Amount: t.Amount.Neg(), fromHeld: t.FromHeld},
+			{AccountID: t.ToAccountID, Asset: t.Asset, Amount: t.Amount},
+		},
+	})
+}
+
+func (repo *sqliteBalanceRepo) Hold(accountId int, asset string, amount decimal.Decimal) (Balance, error) {
+	return repo.adjustHold(accountId, asset, amount, true)
+}
+
+func (repo *sqliteBalanceRepo) Release(accountId int, asset string, amount decimal.Decimal) (Balance, error) {
+	return repo.adjustHold(accountId, asset, amount, false)
+}
+
+func (repo *sqliteBalanceRepo) adjustHold(accountId int, asset string, amount decimal.Decimal, hold bool) (Balance, error) {
+	if err := validateAmount(amount); err != nil {
+		return Balance{}, err
+	}
+	errInsufficient := ErrInsufficientHeld
+	if hold {
+		errInsufficient = ErrInsufficientBalance
+	}
+	ctx := context.Background()
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Balance{}, err
+	}
+	defer tx.Rollback()
+
+	balance, err := getSQLiteBalance(ctx, tx, accountId, asset)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Balance{}, errInsufficient
+	}
+	if err != nil {
+		return Balance{}, err
+	}
+
+	value := amount
+	if hold {
+		if balance.available.LessThan(value) {
+			return Balance{}, errInsufficient
+		}
+		balance.available = balance.available.Sub(value)
+		balance.held = balance.held.Add(value)
+	} else {
+		if balance.held.LessThan(value) {
+			return Balance{}, errInsufficient
+		}
+		balance.available = balance.available.Add(value)
+		balance.held = balance.held.Sub(value)
+	}
+	balance.updatedAt = sqlite.Now()
+	if err := putSQLiteBalance(ctx, tx, accountId, asset, balance); err != nil {
+		return Balance{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		repoLog.Error("failed to commit balance hold", map[string]any{
+			"account_id": accountId,
+			"asset":      asset,
+			"hold":       hold,
+			"error":      err,
+		})
+		return Balance{}, err
+	}
+	return Balance{
+		AccountID: accountId,
+		Asset:     asset,
+		Available: balance.available,
+		Held:      balance.held,
+		UpdatedAt: balance.updatedAt,
+	}, nil
+}
+
+func (repo *sqliteBalanceRepo) GetBalances(accountId int) ([]Balance, error) {
+	rows, err := repo.db.QueryContext(context.Background(),
+		"SELECT account_id, asset, available, held, updated_at FROM tbl_balances WHERE account_id = ? ORDER BY asset",
+		accountId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	balances := []Balance{}
+	for rows.Next() {
+		var b Balance
+		if err := rows.Scan(&b.AccountID, &b.Asset, &b.Available, &b.Held, &b.UpdatedAt); err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+	return balances, rows.Err()
+}
+
+func (repo *sqliteBalanceRepo) GetLedger(accountId int, page int, size int) ([]LedgerEntry, error) {
+	rows, err := repo.db.QueryContext(context.Background(), `SELECT p.id, p.transaction_id, p.account_id, p.asset, p.amount, p.balance_after, p.created_at,
+		       t.tx_type, t.idempotency_key, t.source, t.reference
+		FROM tbl_ledger_postings p
+		JOIN tbl_ledger_transactions t ON t.id = p.transaction_id
+		WHERE p.account_id = ?
+		ORDER BY p.id DESC LIMIT ? OFFSET ?`,
+		accountId, size, max(0, page-1)*size,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []LedgerEntry{}
+	for rows.Next() {
+		var (
+			e            LedgerEntry
+			amount       decimal.Decimal
+			balanceAfter decimal.NullDecimal
+			reference    sql.NullString
+		)
+		err := rows.Scan(
+			&e.ID, &e.TransactionID, &e.AccountID, &e.Asset, &amount, &balanceAfter, &e.CreatedAt,
+			&e.Type, &e.IdempotencyKey, &e.Source, &reference,
+		)
+		if err != nil {
+			return nil, err
+		}
+		e.Amount = amount
+		e.BalanceAfter = balanceAfter.Decimal
+		e.Reference = reference.String
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Reconcile sums the postings here, SUM over text columns would go through floats
+func (repo *sqliteBalanceRepo) Reconcile() (ReconciliationReport, error) {
+	report := ReconciliationReport{Balanced: true, GeneratedAt: time.Now()}
+	ctx := context.Background()
+
+	type balanceKey struct {
+		accountId int
+		asset     string
+	}
+	totals := make(map[string]decimal.Decimal)
+	systemTotals := make(map[SystemAccount]map[string]decimal.Decimal)
+	ledgerTotals := make(map[balanceKey]decimal.Decimal)
+	rows, err := repo.db.QueryContext(ctx, "SELECT account_id, system_account, asset, amount FROM tbl_ledger_postings")
+	if err != nil {
+		return report, err
+	}
+	for rows.Next() {
+		var (
+			accountId     sql.NullInt64
+			systemAccount sql.NullString
+			asset         string
+			amount        decimal.Decimal
+		)
+		if err := rows.Scan(&accountId, &systemAccount, &asset, &amount); err != nil {
+			rows.Close()
+			return report, err
+		}
+		totals[asset] = totals[asset].Add(amount)
+		if systemAccount.Valid {
+			account := SystemAccount(systemAccount.String)
+			if systemTotals[account] == nil {
+				systemTotals[account] = make(map[string]decimal.Decimal)
+			}
+			systemTotals[account][asset] = systemTotals[account][asset].Add(amount)
+		} else {
+			key := balanceKey{int(accountId.Int64), asset}
+			ledgerTotals[key] = ledgerTotals[key].Add(amount)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	for _, asset := range sortedKeys(totals) {
+		if !totals[asset].IsZero() {
+			report.Balanced = false
+		}
+		report.Totals = append(report.Totals, AssetTotal{Asset: asset, Total: totals[asset]})
+	}
+	for _, account := range sortedKeys(systemTotals) {
+		for _, asset := range sortedKeys(systemTotals[account]) {
+			report.SystemAccounts = append(report.SystemAccounts, SystemAccountTotal{
+				SystemAccount: account,
+				Asset:         asset,
+				Total:         systemTotals[account][asset],
+			})
+		}
+	}
+
+	rows, err = repo.db.QueryContext(ctx, "SELECT account_id, asset, available, held FROM tbl_balances ORDER BY account_id, asset")
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			key             balanceKey
+			available, held decimal.Decimal
+		)
+		if err := rows.Scan(&key.accountId, &key.asset, &available, &held); err != nil {
+			return report, err
+		}
+		balance := available.Add(held)
+		if balance.Equal(ledgerTotals[key]) {
+			continue
+		}
+		report.Balanced = false
+		report.Mismatches = append(report.Mismatches, BalanceMismatch{
+			AccountID:   key.accountId,
+			Asset:       key.asset,
+			Balance:     balance,
+			LedgerTotal: ledgerTotals[key],
+		})
+	}
+	return report, rows.Err()
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// NewSQLiteBalanceRepository is NewBalanceRepository on a SQLite database
+func NewSQLiteBalanceRepository(db *sql.DB) BalanceRepo {
+	return &sqliteBalanceRepo{db: db}
+}
@@ -0,0 +1,283 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"order-book/book"
+	"order-book/logger"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Rules of the engine alerts
+const (
+	CROSSED_BOOK         = "crossed_book"
+	QUEUE_DEPTH          = "queue_depth"
+	PERSISTENCE_FAILURES = "persistence_failures"
+	NO_TRADES            = "no_trades"
+)
+
+const (
+	SEVERITY_WARNING  = "warning"
+	SEVERITY_CRITICAL = "critical"
+)
+
+// minPersists is the number of writes the failure rate is measured over, so
+// a single failed write of a quiet book doesn't page anyone
+const minPersists = 5
+
+// Alert is a condition of the book of a tenant, or of one of its pairs. It's
+// notified when it fires, again every repeat interval while it holds, and
+// once more with Resolved set when it clears.
+type Alert struct {
+	Rule       string         `json:"rule"`
+	Severity   string         `json:"severity"`
+	Tenant     string         `json:"tenant"`
+	PairID     string         `json:"pair_id,omitempty"`
+	Message    string         `json:"message"`
+	Details    map[string]any `json:"details,omitempty"`
+	FiredAt    time.Time      `json:"fired_at"`
+	NotifiedAt time.Time      `json:"notified_at"`
+	Resolved   bool           `json:"resolved"`
+}
+
+// Key identifies an alert across evaluations, notifiers deduplicate on it
+func (a Alert) Key() string {
+	return a.Rule + "/" + a.Tenant + "/" + a.PairID
+}
+
+// Rules enables the alerts of the Monitor, the zero value of each disables it
+type Rules struct {
+	// CrossedBook fires while the best bid of a pair is at or above its best ask
+	CrossedBook bool
+	// QueueDepth fires while more orders wait for the engine of a book
+	QueueDepth int
+	// PersistFailureRate fires once this ratio of the order and trade writes
+	// of a book failed since the last evaluation
+	PersistFailureRate float64
+	// NoTradesFor fires when a pair with orders resting on both sides didn't
+	// trade for this long
+	NoTradesFor time.Duration
+}
+
+// persistCounts are the write counters of a book at the last evaluation
+type persistCounts struct {
+	persists uint64
+	failures uint64
+}
+
+// Monitor evaluates the rules against every tenant book and tells the
+// notifier about the alerts firing, only once for each until it clears or
+// repeatInterval passed. A zero repeatInterval never repeats them.
+type Monitor struct {
+	books          *book.Registry
+	notifier       Notifier
+	rules          Rules
+	repeatInterval time.Duration
+	startedAt      time.Time
+	// checking serializes evaluations, they update measured
+	checking sync.Mutex
+
+	mu       sync.Mutex
+	active   map[string]Alert
+	measured map[string]persistCounts
+}
+
+func NewMonitor(books *book.Registry, notifier Notifier, rules Rules, repeatInterval time.Duration) *Monitor {
+	return &Monitor{
+		books:          books,
+		notifier:       notifier,
+		rules:          rules,
+		repeatInterval: repeatInterval,
+		startedAt:      time.Now(),
+		active:         make(map[string]Alert),
+		measured:       make(map[string]persistCounts),
+	}
+}
+
+// Run evaluates the rules every interval until ctx is done
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
+
+// Active lists the alerts firing as of the last evaluation
+func (m *Monitor) Active() []Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	alerts := make([]Alert, 0, len(m.active))
+	for _, a := range m.active {
+		alerts = append(alerts, a)
+	}
+	slices.SortFunc(alerts, func(a, b Alert) int {
+		return a.FiredAt.Compare(b.FiredAt)
+	})
+	return alerts
+}
+
+// Check evaluates the rules once and notifies what changed
+func (m *Monitor) Check(ctx context.Context) {
+	m.checking.Lock()
+	defer m.checking.Unlock()
+	now := time.Now()
+	firing, held := m.evaluate(now)
+
+	m.mu.Lock()
+	var notify []Alert
+	for key, a := range firing {
+		prev, ok := m.active[key]
+		switch {
+		case !ok:
+			a.FiredAt, a.NotifiedAt = now, now
+			notify = append(notify, a)
+		case m.repeatInterval > 0 && now.Sub(prev.NotifiedAt) >= m.repeatInterval:
+			a.FiredAt, a.NotifiedAt = prev.FiredAt, now
+			notify = append(notify, a)
+		default:
+			a.FiredAt, a.NotifiedAt = prev.FiredAt, prev.NotifiedAt
+		}
+		m.active[key] = a
+	}
+	for key, a := range m.active {
+		if _, ok := firing[key]; ok || held[key] {
+			continue
+		}
+		delete(m.active, key)
+		a.Resolved = true
+		a.NotifiedAt = now
+		notify = append(notify, a)
+	}
+	m.mu.Unlock()
+
+	for _, a := range notify {
+		fields := map[string]any{
+			"rule":     a.Rule,
+			"severity": a.Severity,
+			"tenant":   a.Tenant,
+			"pair_id":  a.PairID,
+			"message":  a.Message,
+		}
+		if a.Resolved {
+			logger.Info("alert resolved", fields)
+		} else {
+			logger.Warn("alert fired", fields)
+		}
+		if m.notifier == nil {
+			continue
+		}
+		if err := m.notifier.Notify(ctx, a); err != nil {
+			fields["error"] = err
+			logger.Error("failed to notify alert", fields)
+		}
+	}
+}
+
+// evaluate returns the alerts firing by key. held are the keys that couldn't
+// be evaluated this time, like the failure rate of a book that barely wrote,
+// they keep their state.
+func (m *Monitor) evaluate(now time.Time) (firing map[string]Alert, held map[string]bool) {
+	firing = make(map[string]Alert)
+	held = make(map[string]bool)
+	fire := func(a Alert) {
+		firing[a.Key()] = a
+	}
+
+	for tenantId, b := range m.books.Books() {
+		h := b.Health()
+		if m.rules.QueueDepth > 0 && h.QueueDepth > m.rules.QueueDepth {
+			fire(Alert{
+				Rule:     QUEUE_DEPTH,
+				Severity: SEVERITY_WARNING,
+				Tenant:   tenantId,
+				Message:  fmt.Sprintf("%d orders wait for the engine, above %d", h.QueueDepth, m.rules.QueueDepth),
+				Details: map[string]any{
+					"queue_depth":    h.QueueDepth,
+					"queue_capacity": h.QueueCapacity,
+				},
+			})
+		}
+		if m.rules.PersistFailureRate > 0 {
+			m.evaluatePersists(tenantId, h, fire, held)
+		}
+
+		for _, pairId := range b.Pairs() {
+			bid, ask, ok := b.Top(pairId)
+			if !ok {
+				continue
+			}
+			if m.rules.CrossedBook && bid.GreaterThanOrEqual(ask) {
+				fire(Alert{
+					Rule:     CROSSED_BOOK,
+					Severity: SEVERITY_CRITICAL,
+					Tenant:   tenantId,
+					PairID:   pairId,
+					Message:  fmt.Sprintf("best bid %s is at or above best ask %s", bid, ask),
+					Details: map[string]any{
+						"best_bid": bid,
+						"best_ask": ask,
+					},
+				})
+			}
+			if m.rules.NoTradesFor > 0 {
+				// A pair that didn't trade yet is measured from the start
+				lastTrade := b.LastTradeAt(pairId)
+				since := lastTrade
+				if since.IsZero() {
+					since = m.startedAt
+				}
+				if idle := now.Sub(since); idle >= m.rules.NoTradesFor {
+					fire(Alert{
+						Rule:     NO_TRADES,
+						Severity: SEVERITY_WARNING,
+						Tenant:   tenantId,
+						PairID:   pairId,
+						Message:  fmt.Sprintf("no trade for %s with orders on both sides", idle.Round(time.Second)),
+						Details: map[string]any{
+							"last_trade_at": lastTrade,
+						},
+					})
+				}
+			}
+		}
+	}
+	return firing, held
+}
+
+// evaluatePersists compares the writes of a book since the last time they
+// were measured. Until minPersists were made the alert keeps its state.
+func (m *Monitor) evaluatePersists(tenantId string, h book.EngineHealth, fire func(Alert), held map[string]bool) {
+	key := Alert{Rule: PERSISTENCE_FAILURES, Tenant: tenantId}.Key()
+	last := m.measured[tenantId]
+	persists := h.Persists - last.persists
+	if persists < minPersists {
+		held[key] = true
+		return
+	}
+	failures := h.PersistFailures - last.failures
+	m.measured[tenantId] = persistCounts{persists: h.Persists, failures: h.PersistFailures}
+
+	rate := float64(failures) / float64(persists)
+	if rate < m.rules.PersistFailureRate {
+		return
+	}
+	fire(Alert{
+		Rule:     PERSISTENCE_FAILURES,
+		Severity: SEVERITY_CRITICAL,
+		Tenant:   tenantId,
+		Message:  fmt.Sprintf("%d of %d order and trade writes failed", failures, persists),
+		Details: map[string]any{
+			"failures": failures,
+			"writes":   persists,
+			"rate":     rate,
+		},
+	})
+}
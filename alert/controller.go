@@ -0,0 +1,24 @@
+package alert
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindAlertRouter serves GET /admin/alerts, the engine alerts firing
+func BindAlertRouter(r fiber.Router, monitor *Monitor) {
+	r.Get("/admin/alerts", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    monitor.Active(),
+		})
+	})
+}
@@ -0,0 +1,120 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	requestTimeout   = 10 * time.Second
+	pagerDutyURL     = "https://events.pagerduty.com/v2/enqueue"
+	slackFiringIcon  = ":rotating_light:"
+	slackResolveIcon = ":white_check_mark:"
+)
+
+// Notifier delivers alerts outside of the engine
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// Notifiers sends every alert to each of them
+type Notifiers []Notifier
+
+func (ns Notifiers) Notify(ctx context.Context, a Alert) error {
+	var errs []error
+	for _, n := range ns {
+		if err := n.Notify(ctx, a); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var client = &http.Client{Timeout: requestTimeout}
+
+// Webhook posts alerts as JSON to a URL
+type Webhook struct {
+	URL string
+}
+
+func (w Webhook) Notify(ctx context.Context, a Alert) error {
+	return postJSON(ctx, w.URL, a)
+}
+
+// Slack posts alerts to a Slack incoming webhook
+type Slack struct {
+	WebhookURL string
+}
+
+func (s Slack) Notify(ctx context.Context, a Alert) error {
+	text := fmt.Sprintf("%s [%s] %s", slackFiringIcon, a.Severity, summary(a))
+	if a.Resolved {
+		text = fmt.Sprintf("%s resolved %s", slackResolveIcon, summary(a))
+	}
+	return postJSON(ctx, s.WebhookURL, map[string]any{"text": text})
+}
+
+// PagerDuty triggers and resolves incidents with the Events API v2, the key
+// of the alert deduplicates them
+type PagerDuty struct {
+	RoutingKey string
+}
+
+func (p PagerDuty) Notify(ctx context.Context, a Alert) error {
+	action := "trigger"
+	if a.Resolved {
+		action = "resolve"
+	}
+	source, _ := os.Hostname()
+	return postJSON(ctx, pagerDutyURL, map[string]any{
+		"routing_key":  p.RoutingKey,
+		"event_action": action,
+		"dedup_key":    a.Key(),
+		"payload": map[string]any{
+			"summary":        summary(a),
+			"source":         source,
+			"severity":       a.Severity,
+			"timestamp":      a.FiredAt,
+			"component":      a.PairID,
+			"group":          a.Tenant,
+			"custom_details": a.Details,
+		},
+	})
+}
+
+// summary is a one line description of an alert, like
+// "crossed_book on default/btcusdt: best bid 101 is at or above best ask 100"
+func summary(a Alert) string {
+	on := a.Tenant
+	if a.PairID != "" {
+		on += "/" + a.PairID
+	}
+	return a.Rule + " on " + on + ": " + a.Message
+}
+
+func postJSON(ctx context.Context, url string, body any) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, req.URL.Host)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package algo
+
+import (
+	"net/http"
+	"order-book/order"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// twapRequest is the body of POST /accounts/:id/algos/twap, duration is a Go
+// duration like 30m
+type twapRequest struct {
+	PairID           string          `json:"pair_id"`
+	Type             order.OrderType `json:"type"`
+	Amount           decimal.Decimal `json:"amount"`
+	Duration         string          `json:"duration"`
+	LimitPrice       decimal.Decimal `json:"limit_price"`
+	MaxParticipation float64         `json:"max_participation"`
+	Tag              string          `json:"tag"`
+}
+
+// stopRequest is the body of POST /accounts/:id/algos/stops, trigger is
+// last, mark or index
+type stopRequest struct {
+	PairID     string          `json:"pair_id"`
+	Type       order.OrderType `json:"type"`
+	Amount     decimal.Decimal `json:"amount"`
+	StopPrice  decimal.Decimal `json:"stop_price"`
+	LimitPrice decimal.Decimal `json:"limit_price"`
+	Trigger    string          `json:"trigger"`
+	Tag        string          `json:"tag"`
+}
+
+// BindAlgoRouter serves the execution algos of the accounts. A TWAP is started
+// with POST /accounts/:id/algos/twap, its progress is on
+// GET /accounts/:id/algos/:algo_id and DELETE cancels it. Stops are placed
+// with POST /accounts/:id/algos/stops and are on
+// GET /accounts/:id/algos/stops/:algo_id, DELETE cancels one that hasn't
+// triggered.
+func BindAlgoRouter(r fiber.Router, s *Service) {
+	r.Post("/accounts/:id/algos/twap", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		var req twapRequest
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "duration should be a duration like 30m",
+				Data:    nil,
+			})
+		}
+		if req.Type != order.ASK && req.Type != order.BID {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "type should be 0 (ASK) or 1 (BID)",
+				Data:    nil,
+			})
+		}
+
+		p, err := s.StartTWAP(TWAPRequest{
+			AccountID:        accountId,
+			PairID:           req.PairID,
+			Type:             req.Type,
+			Amount:           req.Amount,
+			Duration:         duration,
+			LimitPrice:       req.LimitPrice,
+			MaxParticipation: req.MaxParticipation,
+			Tag:              req.Tag,
+		})
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusCreated)
+		return c.JSON(&Response{
+			Message: "TWAP started",
+			Data:    p,
+		})
+	})
+
+	r.Post("/accounts/:id/algos/stops", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		var req stopRequest
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		if req.Type != order.ASK && req.Type != order.BID {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "type should be 0 (ASK) or 1 (BID)",
+				Data:    nil,
+			})
+		}
+
+		st, err := s.StartStop(StopRequest{
+			AccountID:  accountId,
+			PairID:     req.PairID,
+			Type:       req.Type,
+			Amount:     req.Amount,
+			StopPrice:  req.StopPrice,
+			LimitPrice: req.LimitPrice,
+			Trigger:    req.Trigger,
+			Tag:        req.Tag,
+		})
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusCreated)
+		return c.JSON(&Response{
+			Message: "Stop placed",
+			Data:    st,
+		})
+	})
+
+	r.Get("/accounts/:id/algos/stops", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    s.ListStops(accountId),
+		})
+	})
+
+	r.Get("/accounts/:id/algos/stops/:algo_id", func(c *fiber.Ctx) error {
+		accountId, algoId, ok := ids(c)
+		if !ok {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid ID",
+				Data:    nil,
+			})
+		}
+		st, err := s.GetStop(accountId, algoId)
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    st,
+		})
+	})
+
+	r.Delete("/accounts/:id/algos/stops/:algo_id", func(c *fiber.Ctx) error {
+		accountId, algoId, ok := ids(c)
+		if !ok {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid ID",
+				Data:    nil,
+			})
+		}
+		st, err := s.CancelStop(accountId, algoId)
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Stop cancelled",
+			Data:    st,
+		})
+	})
+
+	r.Get("/accounts/:id/algos", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    s.List(accountId),
+		})
+	})
+
+	r.Get("/accounts/:id/algos/:algo_id", func(c *fiber.Ctx) error {
+		accountId, algoId, ok := ids(c)
+		if !ok {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid ID",
+				Data:    nil,
+			})
+		}
+		p, err := s.Get(accountId, algoId)
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    p,
+		})
+	})
+
+	r.Delete("/accounts/:id/algos/:algo_id", func(c *fiber.Ctx) error {
+		accountId, algoId, ok := ids(c)
+		if !ok {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid ID",
+				Data:    nil,
+			})
+		}
+		p, err := s.Cancel(accountId, algoId)
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Algo cancelled",
+			Data:    p,
+		})
+	})
+}
+
+func ids(c *fiber.Ctx) (accountId int, algoId int, ok bool) {
+	accountId, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return 0, 0, false
+	}
+	algoId, err = strconv.Atoi(c.Params("algo_id"))
+	if err != nil {
+		return 0, 0, false
+	}
+	return accountId, algoId, true
+}
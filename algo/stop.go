@@ -0,0 +1,312 @@
+package algo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-book/book"
+	"order-book/order"
+	"slices"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Prices a stop can trigger off
+const (
+	TRIGGER_LAST  = "last"
+	TRIGGER_MARK  = "mark"
+	TRIGGER_INDEX = "index"
+)
+
+// Statuses of a stop
+const (
+	WAITING   = "WAITING"
+	TRIGGERED = "TRIGGERED"
+)
+
+var (
+	ErrInvalidStop    = errors.New("A stop needs a positive amount and stop price")
+	ErrInvalidTrigger = errors.New("The trigger should be last, mark or index")
+	ErrStopReached    = errors.New("The trigger price is already past the stop price")
+)
+
+// ValidTrigger reports whether trigger is a price stops can trigger off
+func ValidTrigger(trigger string) bool {
+	return trigger == TRIGGER_LAST || trigger == TRIGGER_MARK || trigger == TRIGGER_INDEX
+}
+
+// IndexPrices are the indexes of the pairs, index.Service in the engine
+type IndexPrices interface {
+	Price(pairId string) (price decimal.Decimal, ok bool)
+}
+
+// MarkPrices are the marks of the pairs, index.MarkPrices in the engine
+type MarkPrices interface {
+	AccountLastPrice(accountId int, pairId string) (price decimal.Decimal, ok bool)
+}
+
+// StopRequest is an order to buy (BID) or sell (ASK) Amount of a pair once
+// the Trigger price reaches StopPrice, at or above it for a buy and at or
+// below it for a sell. Without a Trigger the stop follows the default of the
+// pair. The order is sent at LimitPrice, or without one at the best opposite
+// price and cancelled once matched, as a market order.
+type StopRequest struct {
+	AccountID  int
+	PairID     string
+	Type       order.OrderType
+	Amount     decimal.Decimal
+	StopPrice  decimal.Decimal
+	LimitPrice decimal.Decimal
+	Trigger    string
+	Tag        string
+}
+
+// Stop is a stop order held by the server until it triggers. Its order
+// carries StrategyID.
+type Stop struct {
+	ID           int             `json:"id"`
+	AccountID    int             `json:"account_id"`
+	PairID       string          `json:"pair_id"`
+	Type         order.OrderType `json:"type"`
+	Amount       decimal.Decimal `json:"amount"`
+	StopPrice    decimal.Decimal `json:"stop_price"`
+	LimitPrice   decimal.Decimal `json:"limit_price"`
+	Trigger      string          `json:"trigger"`
+	Tag          string          `json:"tag,omitempty"`
+	StrategyID   string          `json:"strategy_id"`
+	Status       string          `json:"status"`
+	TriggerPrice decimal.Decimal `json:"trigger_price"`
+	OrderID      int             `json:"order_id"`
+	LastError    string          `json:"last_error,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	TriggeredAt  time.Time       `json:"triggered_at"`
+	DoneAt       time.Time       `json:"done_at"`
+}
+
+type stop struct {
+	Stop
+	book book.Book
+}
+
+// StartStop validates a stop and holds it until its trigger price reaches
+// the stop price. A stop that would trigger at once is refused.
+func (s *Service) StartStop(req StopRequest) (Stop, error) {
+	if !req.Amount.IsPositive() || !req.StopPrice.IsPositive() {
+		return Stop{}, ErrInvalidStop
+	}
+	if req.LimitPrice.IsNegative() {
+		return Stop{}, ErrInvalidLimit
+	}
+	if req.Trigger == "" {
+		req.Trigger = s.triggers[req.PairID]
+	}
+	if req.Trigger == "" {
+		req.Trigger = TRIGGER_LAST
+	}
+	if !ValidTrigger(req.Trigger) {
+		return Stop{}, ErrInvalidTrigger
+	}
+	// The order is checked against the pair as it's sent
+	if _, ok := order.GetPair(req.PairID); !ok && len(order.GetPairs()) > 0 {
+		return Stop{}, order.ErrUnknownPair
+	}
+	if err := order.ValidateTags(order.Order{Tag: req.Tag}); err != nil {
+		return Stop{}, err
+	}
+
+	now := time.Now()
+	st := &stop{
+		Stop: Stop{
+			AccountID:  req.AccountID,
+			PairID:     req.PairID,
+			Type:       req.Type,
+			Amount:     req.Amount,
+			StopPrice:  req.StopPrice,
+			LimitPrice: req.LimitPrice,
+			Trigger:    req.Trigger,
+			Tag:        req.Tag,
+			Status:     WAITING,
+			CreatedAt:  now,
+		},
+		book: s.books.ForAccount(req.AccountID),
+	}
+	if price, ok := s.triggerPrice(st, nil); ok && st.reached(price) {
+		return Stop{}, ErrStopReached
+	}
+	s.mu.Lock()
+	s.seq++
+	st.ID = s.seq
+	st.StrategyID = fmt.Sprintf("stop-%d-%d", now.Unix(), st.ID)
+	s.stops[st.ID] = st
+	snap := st.Stop
+	s.mu.Unlock()
+
+	algoLog.Info("stop placed", map[string]any{
+		"algo_id":    st.ID,
+		"account_id": st.AccountID,
+		"pair_id":    st.PairID,
+		"stop_price": st.StopPrice,
+		"trigger":    st.Trigger,
+	})
+	return snap, nil
+}
+
+// PriceUpdated checks the stops of a pair against its index and mark, on
+// every book, after the index of the pair changed
+func (s *Service) PriceUpdated(pairId string) {
+	s.checkStops(pairId, nil, nil)
+}
+
+// checkStops fires the waiting stops of a pair that reached their stop
+// price. With a book only its stops are checked, and with a trade it's the
+// last price of that book.
+func (s *Service) checkStops(pairId string, b book.Book, t *order.Trade) {
+	s.mu.Lock()
+	var fired []*stop
+	for _, st := range s.stops {
+		if st.Status != WAITING || st.PairID != pairId || b != nil && st.book != b {
+			continue
+		}
+		price, ok := s.triggerPrice(st, t)
+		if !ok || !st.reached(price) {
+			continue
+		}
+		st.Status = TRIGGERED
+		st.TriggerPrice = price
+		st.TriggeredAt = time.Now()
+		fired = append(fired, st)
+	}
+	s.mu.Unlock()
+
+	// The books call their listeners from the engine, the orders can't be
+	// sent from there
+	for _, st := range fired {
+		go s.fire(st)
+	}
+}
+
+// triggerPrice is the price a stop follows, that of the trade for the last
+// price when there's one
+func (s *Service) triggerPrice(st *stop, t *order.Trade) (decimal.Decimal, bool) {
+	switch st.Trigger {
+	case TRIGGER_INDEX:
+		return s.index.Price(st.PairID)
+	case TRIGGER_MARK:
+		return s.marks.AccountLastPrice(st.AccountID, st.PairID)
+	}
+	if t != nil {
+		return t.Price, true
+	}
+	return st.book.LastPrice(st.PairID)
+}
+
+// reached reports whether a trigger price is past the stop price of a stop
+func (st *stop) reached(price decimal.Decimal) bool {
+	if st.Type == order.BID {
+		return price.GreaterThanOrEqual(st.StopPrice)
+	}
+	return price.LessThanOrEqual(st.StopPrice)
+}
+
+// fire sends the order of a triggered stop
+func (s *Service) fire(st *stop) {
+	price := st.LimitPrice
+	market := price.IsZero()
+	if market {
+		opposite := order.ASK
+		if st.Type == order.ASK {
+			opposite = order.BID
+		}
+		best, ok := st.book.Best(st.PairID, opposite)
+		if !ok {
+			s.stopDone(st, 0, ErrNoOppositeSide)
+			return
+		}
+		price = best
+	}
+
+	o := order.Order{
+		Price:      price,
+		Amount:     st.Amount,
+		PairID:     st.PairID,
+		AccountID:  st.AccountID,
+		Type:       st.Type,
+		CreatedAt:  time.Now(),
+		Tag:        st.Tag,
+		StrategyID: st.StrategyID,
+	}
+	timings, err := st.book.AddOrderWithTimings(s.ctx, o)
+	if err != nil {
+		s.stopDone(st, 0, err)
+		return
+	}
+	if market {
+		// What the order didn't match isn't left on the book
+		err = st.book.CancellOrder(context.Background(), timings.OrderID)
+		if errors.Is(err, book.ErrOrderNotFound) {
+			err = nil
+		}
+	}
+	s.stopDone(st, timings.OrderID, err)
+}
+
+func (s *Service) stopDone(st *stop, orderId int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st.OrderID = orderId
+	st.DoneAt = time.Now()
+	fields := map[string]any{
+		"algo_id":       st.ID,
+		"trigger_price": st.TriggerPrice,
+		"order_id":      orderId,
+	}
+	if err != nil {
+		st.LastError = err.Error()
+		fields["error"] = err
+		algoLog.Error("failed to send the order of a stop", fields)
+		return
+	}
+	algoLog.Info("stop triggered", fields)
+}
+
+// CancelStop drops a stop of an account that hasn't triggered
+func (s *Service) CancelStop(accountId int, id int) (Stop, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.stops[id]
+	if !ok || st.AccountID != accountId {
+		return Stop{}, ErrAlgoNotFound
+	}
+	if st.Status != WAITING {
+		return Stop{}, ErrAlgoDone
+	}
+	st.Status = CANCELLED
+	st.DoneAt = time.Now()
+	return st.Stop, nil
+}
+
+// GetStop is a stop of an account
+func (s *Service) GetStop(accountId int, id int) (Stop, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.stops[id]
+	if !ok || st.AccountID != accountId {
+		return Stop{}, ErrAlgoNotFound
+	}
+	return st.Stop, nil
+}
+
+// ListStops is the stops of an account, the latest first
+func (s *Service) ListStops(accountId int) []Stop {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []Stop{}
+	for _, st := range s.stops {
+		if st.AccountID == accountId {
+			list = append(list, st.Stop)
+		}
+	}
+	slices.SortFunc(list, func(a, b Stop) int { return b.ID - a.ID })
+	return list
+}
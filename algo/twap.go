@@ -0,0 +1,420 @@
+// Package algo runs execution algos on the server. A TWAP parent order is
+// sliced into child orders placed through the books like any other order, so
+// they go through the same validators, risk checks and matching. A stop is
+// held until the last, mark or index price of its pair reaches its stop
+// price, then sent the same way. The parents and the stops live in memory,
+// the children are cancelled when the engine stops and the waiting stops are
+// lost.
+package algo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-book/book"
+	"order-book/logger"
+	"order-book/order"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// MAX_TWAP_DURATION bounds how long a TWAP can run
+const MAX_TWAP_DURATION = 24 * time.Hour
+
+// Statuses of a parent order
+const (
+	RUNNING   = "RUNNING"
+	COMPLETED = "COMPLETED"
+	EXPIRED   = "EXPIRED"
+	CANCELLED = "CANCELLED"
+)
+
+var (
+	ErrAlgoNotFound   = errors.New("Algo not found")
+	ErrAlgoDone       = errors.New("The algo is already done")
+	ErrInvalidTWAP    = errors.New("A TWAP needs a positive amount and a duration of at most 24h")
+	ErrInvalidLimit   = errors.New("The limit price of a TWAP can't be negative")
+	ErrParticipation  = errors.New("The participation limit should be between 0 and 1")
+	ErrTWAPTooShort   = errors.New("A TWAP should last at least a slice interval")
+	ErrNoOppositeSide = errors.New("No liquidity on the other side of the book")
+)
+
+var algoLog = logger.Component("algo")
+
+// TWAPRequest is a parent order to buy (BID) or sell (ASK) Amount of a pair
+// evenly over Duration. Without a LimitPrice the children are priced at the
+// best opposite price and cancelled once matched, as market orders. With
+// MaxParticipation the parent never fills more than that ratio of the volume
+// others traded on the pair since it started.
+type TWAPRequest struct {
+	AccountID        int
+	PairID           string
+	Type             order.OrderType
+	Amount           decimal.Decimal
+	Duration         time.Duration
+	LimitPrice       decimal.Decimal
+	MaxParticipation float64
+	Tag              string
+}
+
+// Parent is the progress of a TWAP. Its children carry StrategyID, which
+// attributes their fills to it.
+type Parent struct {
+	ID               int             `json:"id"`
+	AccountID        int             `json:"account_id"`
+	PairID           string          `json:"pair_id"`
+	Type             order.OrderType `json:"type"`
+	Amount           decimal.Decimal `json:"amount"`
+	LimitPrice       decimal.Decimal `json:"limit_price"`
+	MaxParticipation float64         `json:"max_participation"`
+	Tag              string          `json:"tag,omitempty"`
+	StrategyID       string          `json:"strategy_id"`
+	Status           string          `json:"status"`
+	Filled           decimal.Decimal `json:"filled"`
+	AvgPrice         decimal.Decimal `json:"avg_price"`
+	MarketVolume     decimal.Decimal `json:"market_volume"`
+	Slices           int             `json:"slices"`
+	SlicesSent       int             `json:"slices_sent"`
+	ChildOrderIDs    []int           `json:"child_order_ids"`
+	LastError        string          `json:"last_error,omitempty"`
+	StartedAt        time.Time       `json:"started_at"`
+	EndsAt           time.Time       `json:"ends_at"`
+	DoneAt           time.Time       `json:"done_at"`
+}
+
+// Books resolves the book an account trades on
+type Books interface {
+	ForAccount(accountId int) book.Book
+}
+
+type parent struct {
+	Parent
+	book     book.Book
+	notional decimal.Decimal
+	// resting is the child of the last slice while it may still be on the
+	// book
+	resting int
+	stop    chan struct{}
+	// run serializes the slices and the cancellation
+	run sync.Mutex
+}
+
+// Service runs the parents, a slice every interval, and fires the stops.
+// Triggers are the default trigger of the stops by pair, those of the pairs
+// without one follow the last price.
+type Service struct {
+	books    Books
+	interval time.Duration
+	index    IndexPrices
+	marks    MarkPrices
+	triggers map[string]string
+
+	mu      sync.Mutex
+	parents map[int]*parent
+	stops   map[int]*stop
+	seq     int
+	ctx     context.Context
+}
+
+func NewService(ctx context.Context, books Books, interval time.Duration, index IndexPrices, marks MarkPrices, triggers map[string]string) *Service {
+	return &Service{
+		books:    books,
+		interval: interval,
+		index:    index,
+		marks:    marks,
+		triggers: triggers,
+		parents:  make(map[int]*parent),
+		stops:    make(map[int]*stop),
+		ctx:      ctx,
+	}
+}
+
+// Attach follows the trades of a book, for the fills and the participation
+// of the parents trading on it and the stops following its last price
+func (s *Service) Attach(tenantId string, b book.Book) {
+	b.OnTrade(func(t order.Trade) {
+		s.onTrade(b, t)
+		s.checkStops(t.PairID, b, &t)
+	})
+}
+
+func (s *Service) onTrade(b book.Book, t order.Trade) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.parents {
+		if p.book != b || p.PairID != t.PairID || p.Status != RUNNING {
+			continue
+		}
+		if t.TakerStrategyID == p.StrategyID && t.TakerAccountID == p.AccountID ||
+			t.MakerStrategyID == p.StrategyID && t.MakerAccountID == p.AccountID {
+			p.Filled = p.Filled.Add(t.Amount)
+			p.notional = p.notional.Add(t.Amount.Mul(t.Price))
+			p.AvgPrice = p.notional.Div(p.Filled)
+			continue
+		}
+		p.MarketVolume = p.MarketVolume.Add(t.Amount)
+	}
+}
+
+// StartTWAP validates a TWAP and runs it until it's filled, its duration ends
+// or it's cancelled. The first slice is sent at once.
+func (s *Service) StartTWAP(req TWAPRequest) (Parent, error) {
+	switch {
+	case !req.Amount.IsPositive() || req.Duration <= 0 || req.Duration > MAX_TWAP_DURATION:
+		return Parent{}, ErrInvalidTWAP
+	case req.Duration < s.interval:
+		return Parent{}, ErrTWAPTooShort
+	case req.LimitPrice.IsNegative():
+		return Parent{}, ErrInvalidLimit
+	case req.MaxParticipation < 0 || req.MaxParticipation > 1:
+		return Parent{}, ErrParticipation
+	}
+	// The children are checked against the pair as they're sent
+	if _, ok := order.GetPair(req.PairID); !ok && len(order.GetPairs()) > 0 {
+		return Parent{}, order.ErrUnknownPair
+	}
+	if err := order.ValidateTags(order.Order{Tag: req.Tag}); err != nil {
+		return Parent{}, err
+	}
+
+	now := time.Now()
+	count := int((req.Duration + s.interval - 1) / s.interval)
+	s.mu.Lock()
+	s.seq++
+	p := &parent{
+		Parent: Parent{
+			ID:               s.seq,
+			AccountID:        req.AccountID,
+			PairID:           req.PairID,
+			Type:             req.Type,
+			Amount:           req.Amount,
+			LimitPrice:       req.LimitPrice,
+			MaxParticipation: req.MaxParticipation,
+			Tag:              req.Tag,
+			StrategyID:       fmt.Sprintf("twap-%d-%d", now.Unix(), s.seq),
+			Status:           RUNNING,
+			Slices:           count,
+			ChildOrderIDs:    []int{},
+			StartedAt:        now,
+			EndsAt:           now.Add(req.Duration),
+		},
+		book: s.books.ForAccount(req.AccountID),
+		stop: make(chan struct{}),
+	}
+	s.parents[p.ID] = p
+	s.mu.Unlock()
+
+	algoLog.Info("twap started", map[string]any{
+		"algo_id":    p.ID,
+		"account_id": p.AccountID,
+		"pair_id":    p.PairID,
+		"amount":     p.Amount,
+		"slices":     count,
+	})
+	go s.run(p)
+	return s.Get(p.AccountID, p.ID)
+}
+
+func (s *Service) run(p *parent) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if s.slice(p) {
+			return
+		}
+		select {
+		case <-p.stop:
+			return
+		case <-s.ctx.Done():
+			p.run.Lock()
+			s.finish(p, CANCELLED)
+			p.run.Unlock()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// slice pulls what's left of the previous child and sends the next one. The
+// children follow the schedule, after slice i the parent aims at i of
+// Slices of its amount, and what a slice missed is caught up by the next.
+// It reports whether the parent is done.
+func (s *Service) slice(p *parent) bool {
+	p.run.Lock()
+	defer p.run.Unlock()
+	s.pullResting(p)
+
+	s.mu.Lock()
+	if p.Status != RUNNING {
+		s.mu.Unlock()
+		return true
+	}
+	if !p.Filled.LessThan(p.Amount) {
+		s.mu.Unlock()
+		s.finish(p, COMPLETED)
+		return true
+	}
+	if p.SlicesSent >= p.Slices {
+		s.mu.Unlock()
+		s.finish(p, EXPIRED)
+		return true
+	}
+	p.SlicesSent++
+	target := p.Amount.Mul(decimal.NewFromInt(int64(p.SlicesSent))).Div(decimal.NewFromInt(int64(p.Slices)))
+	amount := target.Sub(p.Filled)
+	if p.MaxParticipation > 0 {
+		allowed := p.MarketVolume.Mul(decimal.NewFromFloat(p.MaxParticipation)).Sub(p.Filled)
+		amount = decimal.Min(amount, allowed)
+	}
+	s.mu.Unlock()
+
+	if pair, ok := order.GetPair(p.PairID); ok {
+		amount = amount.Truncate(pair.AmountScale)
+		if pair.LotSize.IsPositive() {
+			amount = amount.Div(pair.LotSize).Floor().Mul(pair.LotSize)
+		}
+	}
+	if !amount.IsPositive() {
+		return false
+	}
+	price := p.LimitPrice
+	market := price.IsZero()
+	if market {
+		opposite := order.ASK
+		if p.Type == order.ASK {
+			opposite = order.BID
+		}
+		best, ok := p.book.Best(p.PairID, opposite)
+		if !ok {
+			s.setError(p, ErrNoOppositeSide)
+			return false
+		}
+		price = best
+	}
+
+	child := order.Order{
+		Price:      price,
+		Amount:     amount,
+		PairID:     p.PairID,
+		AccountID:  p.AccountID,
+		Type:       p.Type,
+		CreatedAt:  time.Now(),
+		Tag:        p.Tag,
+		StrategyID: p.StrategyID,
+	}
+	timings, err := p.book.AddOrderWithTimings(s.ctx, child)
+	if err != nil {
+		s.setError(p, err)
+		return false
+	}
+	s.mu.Lock()
+	p.ChildOrderIDs = append(p.ChildOrderIDs, timings.OrderID)
+	p.LastError = ""
+	s.mu.Unlock()
+	p.resting = timings.OrderID
+	if market {
+		// What the child didn't match isn't left on the book
+		s.pullResting(p)
+	}
+	return false
+}
+
+// pullResting cancels the child of the last slice, callers hold p.run
+func (s *Service) pullResting(p *parent) {
+	if p.resting == 0 {
+		return
+	}
+	err := p.book.CancellOrder(context.Background(), p.resting)
+	if err != nil && !errors.Is(err, book.ErrOrderNotFound) {
+		algoLog.Error("failed to cancel a child order", map[string]any{
+			"algo_id":  p.ID,
+			"order_id": p.resting,
+			"error":    err,
+		})
+		s.setError(p, err)
+		return
+	}
+	p.resting = 0
+}
+
+func (s *Service) setError(p *parent, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.LastError = err.Error()
+}
+
+// finish ends a running parent, callers hold p.run
+func (s *Service) finish(p *parent, status string) {
+	s.pullResting(p)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p.Status != RUNNING {
+		return
+	}
+	p.Status = status
+	p.DoneAt = time.Now()
+	close(p.stop)
+	algoLog.Info("twap done", map[string]any{
+		"algo_id": p.ID,
+		"status":  status,
+		"filled":  p.Filled,
+	})
+}
+
+// Cancel stops a parent of an account and pulls its child off the book, the
+// fills so far stay
+func (s *Service) Cancel(accountId int, id int) (Parent, error) {
+	s.mu.Lock()
+	p, ok := s.parents[id]
+	s.mu.Unlock()
+	if !ok || p.AccountID != accountId {
+		return Parent{}, ErrAlgoNotFound
+	}
+	p.run.Lock()
+	s.mu.Lock()
+	running := p.Status == RUNNING
+	s.mu.Unlock()
+	if running {
+		s.finish(p, CANCELLED)
+	}
+	p.run.Unlock()
+	if !running {
+		return Parent{}, ErrAlgoDone
+	}
+	return s.Get(accountId, id)
+}
+
+// Get is the progress of a parent of an account
+func (s *Service) Get(accountId int, id int) (Parent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.parents[id]
+	if !ok || p.AccountID != accountId {
+		return Parent{}, ErrAlgoNotFound
+	}
+	return p.snapshot(), nil
+}
+
+// List is the parents of an account, the latest first
+func (s *Service) List(accountId int) []Parent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []Parent{}
+	for _, p := range s.parents {
+		if p.AccountID == accountId {
+			list = append(list, p.snapshot())
+		}
+	}
+	slices.SortFunc(list, func(a, b Parent) int { return b.ID - a.ID })
+	return list
+}
+
+// snapshot copies the progress of a parent, callers hold s.mu
+func (p *parent) snapshot() Parent {
+	snap := p.Parent
+	snap.ChildOrderIDs = slices.Clone(p.ChildOrderIDs)
+	return snap
+}
@@ -0,0 +1,398 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"order-book/logger"
+	"order-book/order"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/shopspring/decimal"
+)
+
+// manifestKey is the object listing every archived range
+const manifestKey = "manifest.json"
+
+// Archived tables
+const (
+	TRADES               = "trades"
+	ORDER_HISTORY_EVENTS = "order_history_events"
+)
+
+// ManifestEntry is a Parquet object holding the rows of a table created in
+// [RangeStart, RangeEnd)
+type ManifestEntry struct {
+	Table      string    `json:"table"`
+	Key        string    `json:"key"`
+	RangeStart time.Time `json:"range_start"`
+	RangeEnd   time.Time `json:"range_end"`
+	Rows       int       `json:"rows"`
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+type Manifest struct {
+	Entries []ManifestEntry `json:"entries"`
+}
+
+// covering returns the entries of a table overlapping [from, to), a zero to
+// is unbounded
+func (m Manifest) covering(table string, from time.Time, to time.Time) []ManifestEntry {
+	var entries []ManifestEntry
+	for _, e := range m.Entries {
+		if e.Table != table || !e.RangeEnd.After(from) || (!to.IsZero() && !e.RangeStart.Before(to)) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+type tradeRow struct {
+	ID             int64     `parquet:"id"`
+	PairID         string    `parquet:"pair_id,dict"`
+	Price          string    `parquet:"price"`
+	Amount         string    `parquet:"amount"`
+	MakerOrderID   int64     `parquet:"maker_order_id"`
+	TakerOrderID   int64     `parquet:"taker_order_id"`
+	MakerAccountID int64     `parquet:"maker_account_id"`
+	TakerAccountID int64     `parquet:"taker_account_id"`
+	TakerSide      int32     `parquet:"taker_side"`
+	MakerFee       string    `parquet:"maker_fee"`
+	TakerFee       string    `parquet:"taker_fee"`
+	ExecutedAt     time.Time `parquet:"executed_at,timestamp(microsecond)"`
+	// Months archived before the tags existed read them as empty
+	MakerTag        string `parquet:"maker_tag,optional"`
+	MakerStrategyID string `parquet:"maker_strategy_id,optional"`
+	TakerTag        string `parquet:"taker_tag,optional"`
+	TakerStrategyID string `parquet:"taker_strategy_id,optional"`
+	// and before the rebates without one
+	MakerRebate string `parquet:"maker_rebate,optional"`
+}
+
+type eventRow struct {
+	ID        int64     `parquet:"id"`
+	Event     string    `parquet:"event,dict"`
+	OrderID   int64     `parquet:"order_id"`
+	Metadata  string    `parquet:"metadata"`
+	CreatedAt time.Time `parquet:"created_at,timestamp(microsecond)"`
+}
+
+// Archiver exports the order history partitions archived by
+// order.HistoryArchiver and the months of trades older than tradeRetention to
+// Parquet objects, dropping the rows from the database once they are stored.
+// A zero tradeRetention keeps every trade in the database.
+type Archiver struct {
+	// mu serializes exports, the manifest is rewritten by each of them
+	mu             sync.Mutex
+	store          ObjectStore
+	partitions     order.HistoryPartitionRepo
+	trades         order.TradeRepo
+	tradeRetention time.Duration
+	interval       time.Duration
+}
+
+func NewArchiver(store ObjectStore, partitions order.HistoryPartitionRepo, trades order.TradeRepo, tradeRetention time.Duration, interval time.Duration) *Archiver {
+	return &Archiver{
+		store:          store,
+		partitions:     partitions,
+		trades:         trades,
+		tradeRetention: tradeRetention,
+		interval:       interval,
+	}
+}
+
+// Run exports right away and then every interval until ctx is done
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		if err := a.exportHistory(ctx); err != nil {
+			logger.Error("failed to export order history to object storage", map[string]any{
+				"error": err,
+			})
+		}
+		if err := a.exportTrades(ctx, time.Now().UTC()); err != nil {
+			logger.Error("failed to export trades to object storage", map[string]any{
+				"error": err,
+			})
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Archiver) exportHistory(ctx context.Context) error {
+	partitions, err := a.partitions.GetUnexportedPartitions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range partitions {
+		events, err := a.partitions.ReadArchivedPartition(ctx, p.Name)
+		if err != nil {
+			return err
+		}
+		rows := make([]eventRow, len(events))
+		for idx, ev := range events {
+			metadata, err := json.Marshal(ev.Metadata)
+			if err != nil {
+				return err
+			}
+			rows[idx] = eventRow{
+				ID:        ev.ID,
+				Event:     ev.Name,
+				OrderID:   int64(ev.OrderId),
+				Metadata:  string(metadata),
+				CreatedAt: ev.CreatedAt,
+			}
+		}
+		entry := ManifestEntry{
+			Table:      ORDER_HISTORY_EVENTS,
+			Key:        ORDER_HISTORY_EVENTS + "/" + p.RangeStart.Format("2006-01") + ".parquet",
+			RangeStart: p.RangeStart,
+			RangeEnd:   p.RangeEnd,
+			Rows:       len(rows),
+		}
+		if err := writeObject(ctx, a, entry, rows); err != nil {
+			return err
+		}
+		// The partition is only dropped once its object is listed in the manifest
+		if err := a.partitions.DropArchivedPartition(ctx, p.Name, entry.Key); err != nil {
+			return err
+		}
+		logger.Info("order history partition exported", map[string]any{
+			"partition": p.Name,
+			"key":       entry.Key,
+			"rows":      entry.Rows,
+		})
+	}
+	return nil
+}
+
+func (a *Archiver) exportTrades(ctx context.Context, now time.Time) error {
+	if a.tradeRetention <= 0 {
+		return nil
+	}
+	oldest, ok, err := a.trades.OldestTradeTime(ctx)
+	if err != nil || !ok {
+		return err
+	}
+	cutoff := now.Add(-a.tradeRetention)
+	month := time.Date(oldest.Year(), oldest.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for end := month.AddDate(0, 1, 0); !end.After(cutoff); month, end = end, end.AddDate(0, 1, 0) {
+		trades, err := a.trades.GetTradesBetween(ctx, month, end)
+		if err != nil {
+			return err
+		}
+		if len(trades) == 0 {
+			continue
+		}
+		rows := make([]tradeRow, len(trades))
+		for idx, t := range trades {
+			rows[idx] = tradeRow{
+				ID:              t.ID,
+				PairID:          t.PairID,
+				Price:           t.Price.String(),
+				Amount:          t.Amount.String(),
+				MakerOrderID:    int64(t.MakerOrderID),
+				TakerOrderID:    int64(t.TakerOrderID),
+				MakerAccountID:  int64(t.MakerAccountID),
+				TakerAccountID:  int64(t.TakerAccountID),
+				TakerSide:       int32(t.TakerSide),
+				MakerFee:        t.MakerFee.String(),
+				TakerFee:        t.TakerFee.String(),
+				ExecutedAt:      t.ExecutedAt,
+				MakerTag:        t.MakerTag,
+				MakerStrategyID: t.MakerStrategyID,
+				TakerTag:        t.TakerTag,
+				TakerStrategyID: t.TakerStrategyID,
+				MakerRebate:     t.MakerRebate.String(),
+			}
+		}
+		entry := ManifestEntry{
+			Table:      TRADES,
+			Key:        TRADES + "/" + month.Format("2006-01") + ".parquet",
+			RangeStart: month,
+			RangeEnd:   end,
+			Rows:       len(rows),
+		}
+		if err := writeObject(ctx, a, entry, rows); err != nil {
+			return err
+		}
+		if err := a.trades.DeleteTradesBetween(ctx, month, end); err != nil {
+			return err
+		}
+		logger.Info("trades exported", map[string]any{
+			"key":  entry.Key,
+			"rows": entry.Rows,
+		})
+	}
+	return nil
+}
+
+// writeObject stores rows as a Parquet object and lists it in the manifest,
+// replacing any previous export of the same range
+func writeObject[T any](ctx context.Context, a *Archiver, entry ManifestEntry, rows []T) error {
+	var buf bytes.Buffer
+	if err := parquet.Write(&buf, rows, parquet.Compression(&parquet.Zstd)); err != nil {
+		return err
+	}
+	if err := a.store.Put(ctx, entry.Key, buf.Bytes(), "application/vnd.apache.parquet"); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	manifest, err := a.GetManifest(ctx)
+	if err != nil {
+		return err
+	}
+	entry.ArchivedAt = time.Now().UTC()
+	manifest.Entries = slices.DeleteFunc(manifest.Entries, func(e ManifestEntry) bool {
+		return e.Key == entry.Key
+	})
+	manifest.Entries = append(manifest.Entries, entry)
+	slices.SortFunc(manifest.Entries, func(a, b ManifestEntry) int {
+		return a.RangeStart.Compare(b.RangeStart)
+	})
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return a.store.Put(ctx, manifestKey, data, "application/json")
+}
+
+// GetManifest returns the archived ranges, empty before the first export
+func (a *Archiver) GetManifest(ctx context.Context) (Manifest, error) {
+	var manifest Manifest
+	data, err := a.store.Get(ctx, manifestKey)
+	if errors.Is(err, ErrObjectNotFound) {
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, err
+	}
+	err = json.Unmarshal(data, &manifest)
+	return manifest, err
+}
+
+// GetTrades reads the archived trades matching filter, the most recent first
+func (a *Archiver) GetTrades(ctx context.Context, filter order.TradeFilter) ([]order.Trade, error) {
+	manifest, err := a.GetManifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var trades []order.Trade
+	for _, entry := range manifest.covering(TRADES, filter.From, filter.To) {
+		rows, err := readObject[tradeRow](ctx, a.store, entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			if filter.AccountID != 0 && r.MakerAccountID != int64(filter.AccountID) && r.TakerAccountID != int64(filter.AccountID) {
+				continue
+			}
+			if (filter.PairID != "" && r.PairID != filter.PairID) || r.ExecutedAt.Before(filter.From) ||
+				(!filter.To.IsZero() && !r.ExecutedAt.Before(filter.To)) {
+				continue
+			}
+			t, err := convertTradeRow(r)
+			if err != nil {
+				return nil, err
+			}
+			trades = append(trades, t)
+		}
+	}
+	slices.SortFunc(trades, func(a, b order.Trade) int {
+		if c := b.ExecutedAt.Compare(a.ExecutedAt); c != 0 {
+			return c
+		}
+		return int(b.ID - a.ID)
+	})
+	return trades, nil
+}
+
+// GetOrderHistory reads the archived history events of an order created in
+// [from, to), the most recent first. A zero to is unbounded.
+func (a *Archiver) GetOrderHistory(ctx context.Context, orderId int, from time.Time, to time.Time) ([]order.OrderHistoryEvent, error) {
+	manifest, err := a.GetManifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var events []order.OrderHistoryEvent
+	for _, entry := range manifest.covering(ORDER_HISTORY_EVENTS, from, to) {
+		rows, err := readObject[eventRow](ctx, a.store, entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			if r.OrderID != int64(orderId) || r.CreatedAt.Before(from) || (!to.IsZero() && !r.CreatedAt.Before(to)) {
+				continue
+			}
+			ev := order.OrderHistoryEvent{
+				ID:        r.ID,
+				Name:      r.Event,
+				OrderId:   int(r.OrderID),
+				CreatedAt: r.CreatedAt,
+			}
+			if err := json.Unmarshal([]byte(r.Metadata), &ev.Metadata); err != nil {
+				return nil, err
+			}
+			if ev, err = order.Upcast(ev); err != nil {
+				return nil, err
+			}
+			events = append(events, ev)
+		}
+	}
+	slices.SortFunc(events, func(a, b order.OrderHistoryEvent) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return events, nil
+}
+
+func readObject[T any](ctx context.Context, store ObjectStore, key string) ([]T, error) {
+	data, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return parquet.Read[T](bytes.NewReader(data), int64(len(data)))
+}
+
+func convertTradeRow(r tradeRow) (order.Trade, error) {
+	t := order.Trade{
+		ID:              r.ID,
+		PairID:          r.PairID,
+		MakerOrderID:    int(r.MakerOrderID),
+		TakerOrderID:    int(r.TakerOrderID),
+		MakerAccountID:  int(r.MakerAccountID),
+		TakerAccountID:  int(r.TakerAccountID),
+		TakerSide:       order.OrderType(r.TakerSide),
+		ExecutedAt:      r.ExecutedAt,
+		MakerTag:        r.MakerTag,
+		MakerStrategyID: r.MakerStrategyID,
+		TakerTag:        r.TakerTag,
+		TakerStrategyID: r.TakerStrategyID,
+	}
+	var err error
+	for _, f := range []struct {
+		dst *decimal.Decimal
+		src string
+	}{{&t.Price, r.Price}, {&t.Amount, r.Amount}, {&t.MakerFee, r.MakerFee}, {&t.TakerFee, r.TakerFee}} {
+		if *f.dst, err = decimal.NewFromString(f.src); err != nil {
+			return t, err
+		}
+	}
+	if r.MakerRebate != "" {
+		if t.MakerRebate, err = decimal.NewFromString(r.MakerRebate); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,110 @@
+package archive
+
+import (
+	"errors"
+	"net/http"
+	"order-book/logger"
+	"order-book/order"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var ErrInvalidData = errors.New("ErrInvalidData")
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindArchiveRouter serves compliance requests for ranges that were moved to
+// object storage. Time ranges are given as RFC 3339 from and to parameters.
+func BindArchiveRouter(r fiber.Router, archiver *Archiver) {
+	r.Get("/admin/archive/manifest", func(c *fiber.Ctx) error {
+		manifest, err := archiver.GetManifest(c.UserContext())
+		if err != nil {
+			logger.Error("failed to read archive manifest", map[string]any{
+				"error": err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    manifest,
+		})
+	})
+
+	r.Get("/admin/archive/trades", func(c *fiber.Ctx) error {
+		filter := order.TradeFilter{PairID: c.Query("pair_id"), AccountID: c.QueryInt("account_id", 0)}
+		if err := parseRange(c, &filter.From, &filter.To); err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrInvalidData,
+				Message: err.Error(),
+			})
+		}
+
+		trades, err := archiver.GetTrades(c.UserContext(), filter)
+		if err != nil {
+			logger.Error("failed to read archived trades", map[string]any{
+				"account_id": filter.AccountID,
+				"error":      err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    trades,
+		})
+	})
+
+	r.Get("/admin/archive/orders/:id/history", func(c *fiber.Ctx) error {
+		orderId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid ID",
+				Data:    nil,
+			})
+		}
+		var from, to time.Time
+		if err := parseRange(c, &from, &to); err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrInvalidData,
+				Message: err.Error(),
+			})
+		}
+
+		events, err := archiver.GetOrderHistory(c.UserContext(), orderId, from, to)
+		if err != nil {
+			logger.Error("failed to read archived order history", map[string]any{
+				"order_id": orderId,
+				"error":    err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    events,
+		})
+	})
+}
+
+func parseRange(c *fiber.Ctx, from *time.Time, to *time.Time) error {
+	for param, dst := range map[string]*time.Time{"from": from, "to": to} {
+		if v := c.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return errors.New(param + " should be an RFC 3339 time")
+			}
+			*dst = t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+// Package backup captures the state of the engine from its Postgres database
+// at a point in time and loads it back into another one. A backup is a
+// directory holding the rows of every table, the latest snapshot of every
+// book with the seq of the journal it was taken at, and a manifest with the
+// checksums of both. The manifest is written last, a directory without one
+// is a backup that didn't complete.
+//
+// The books are as of their latest snapshot, the orders rested or filled
+// after it are in the tables but not in the books. Snapshotting the books
+// right before, or stopping the engine, keeps that gap empty.
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"order-book/db"
+	"order-book/order"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// FORMAT is the version of the layout of a backup
+const FORMAT = 1
+
+const (
+	MANIFEST_FILE = "manifest.json"
+	BOOKS_FILE    = "books.json"
+)
+
+var (
+	ErrBackupExists     = errors.New("The directory already holds a backup")
+	ErrUnknownFormat    = errors.New("Unknown backup format")
+	ErrChecksumMismatch = errors.New("Backup checksum mismatch")
+)
+
+// Tables are captured and restored in this order, each after those it
+// references. The engine leases belong to the cluster they were taken in,
+// the candle aggregates are rebuilt from the trade ticks.
+var Tables = []string{
+	"tbl_assets",
+	"tbl_pairs",
+	"tbl_tenants",
+	"tbl_tenant_pairs",
+	"tbl_accounts",
+	"tbl_api_keys",
+	"tbl_orders",
+	"tbl_order_history_events",
+	"tbl_balances",
+	"tbl_ledger_entries",
+	"tbl_ledger_transactions",
+	"tbl_ledger_postings",
+	"tbl_beneficial_owners",
+	"tbl_surveillance_alerts",
+	"tbl_webhooks",
+	"tbl_webhook_deliveries",
+	"tbl_trades",
+	"tbl_book_snapshots",
+	"tbl_archived_partitions",
+	"tbl_trade_ticks",
+	"tbl_depth_snapshots",
+	"tbl_netting_accounts",
+	"tbl_netting_obligations",
+	"tbl_ledger_queue",
+	"tbl_fee_asset_accounts",
+	"tbl_margin_accounts",
+	"tbl_outbox",
+	"tbl_consumer_processed_events",
+	"tbl_consumer_offsets",
+}
+
+// Manifest describes a backup and holds the checksums it's verified against
+type Manifest struct {
+	Format    int       `json:"format"`
+	CreatedAt time.Time `json:"created_at"`
+	// Migration is the schema version of the database, a backup is only
+	// restored into a database at the same version
+	Migration uint64      `json:"migration"`
+	Tables    []TableFile `json:"tables"`
+	Books     []BookEntry `json:"books"`
+	// BooksSHA256 is the checksum of BOOKS_FILE
+	BooksSHA256 string `json:"books_sha256"`
+}
+
+// TableFile holds the rows of a table in the text format of COPY
+type TableFile struct {
+	Name   string `json:"name"`
+	File   string `json:"file"`
+	Rows   int64  `json:"rows"`
+	SHA256 string `json:"sha256"`
+}
+
+// BookEntry is the snapshot of the book of a pair, Seq is the position of
+// the journal it was taken at and Checksum that of its resting orders
+type BookEntry struct {
+	TenantID string `json:"tenant_id"`
+	PairID   string `json:"pair_id"`
+	Seq      int64  `json:"seq"`
+	Orders   int    `json:"orders"`
+	Checksum string `json:"checksum"`
+}
+
+// BookChecksum hashes the resting orders of a snapshot in priority order,
+// the seq and the time the snapshot was taken at aren't part of it
+func BookChecksum(snap order.BookSnapshot) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", snap.PairID)
+	for _, side := range []struct {
+		name   string
+		levels []order.PriceLevel
+	}{{"ask", snap.Asks}, {"bid", snap.Bids}} {
+		for _, level := range side.levels {
+			for _, o := range level.Orders {
+				fmt.Fprintf(h, "%s %s %d %d %s %d %d\n",
+					side.name, level.Price, o.ID, o.AccountID, o.Amount, o.CreatedAt.UnixNano(), o.Version)
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Capture writes a backup of the database into dir. Everything is read in a
+// single read-only transaction, so the tables and the books are those of the
+// same point in time while the engine keeps running.
+func Capture(ctx context.Context, pool *pgxpool.Pool, dir string) (Manifest, error) {
+	if _, err := os.Stat(filepath.Join(dir, MANIFEST_FILE)); err == nil {
+		return Manifest{}, ErrBackupExists
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return Manifest{}, err
+	}
+
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	m := Manifest{Format: FORMAT}
+	if m.Migration, err = migration(ctx, tx); err != nil {
+		return m, err
+	}
+	// The time of the database, that of the snapshot of the transaction
+	if err := tx.QueryRow(ctx, "SELECT NOW()").Scan(&m.CreatedAt); err != nil {
+		return m, err
+	}
+	for _, table := range Tables {
+		file := TableFile{Name: table, File: table + ".copy"}
+		if file.Rows, file.SHA256, err = copyOut(ctx, tx, table, filepath.Join(dir, file.File)); err != nil {
+			return m, fmt.Errorf("failed to copy %s: %w", table, err)
+		}
+		m.Tables = append(m.Tables, file)
+	}
+
+	snaps, err := latestSnapshots(ctx, tx)
+	if err != nil {
+		return m, err
+	}
+	for _, snap := range snaps {
+		m.Books = append(m.Books, BookEntry{
+			TenantID: snap.TenantID,
+			PairID:   snap.PairID,
+			Seq:      snap.Seq,
+			Orders:   len(snap.Orders()),
+			Checksum: BookChecksum(snap),
+		})
+	}
+	data, err := json.MarshalIndent(snaps, "", "  ")
+	if err != nil {
+		return m, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, BOOKS_FILE), data, 0o644); err != nil {
+		return m, err
+	}
+	sum := sha256.Sum256(data)
+	m.BooksSHA256 = hex.EncodeToString(sum[:])
+
+	data, err = json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return m, err
+	}
+	return m, os.WriteFile(filepath.Join(dir, MANIFEST_FILE), data, 0o644)
+}
+
+// Verify checks the files of the backup in dir against the checksums of its
+// manifest, and the books against theirs
+func Verify(dir string) (Manifest, []order.BookSnapshot, error) {
+	var m Manifest
+	data, err := os.ReadFile(filepath.Join(dir, MANIFEST_FILE))
+	if err != nil {
+		return m, nil, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, nil, err
+	}
+	if m.Format != FORMAT {
+		return m, nil, fmt.Errorf("%w: %d", ErrUnknownFormat, m.Format)
+	}
+	for _, file := range m.Tables {
+		sum, err := fileChecksum(filepath.Join(dir, file.File))
+		if err != nil {
+			return m, nil, err
+		}
+		if sum != file.SHA256 {
+			return m, nil, fmt.Errorf("%w: %s", ErrChecksumMismatch, file.File)
+		}
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, BOOKS_FILE))
+	if err != nil {
+		return m, nil, err
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != m.BooksSHA256 {
+		return m, nil, fmt.Errorf("%w: %s", ErrChecksumMismatch, BOOKS_FILE)
+	}
+	var snaps []order.BookSnapshot
+	if err := json.Unmarshal(data, &snaps); err != nil {
+		return m, nil, err
+	}
+	if len(snaps) != len(m.Books) {
+		return m, nil, fmt.Errorf("%w: %d books in %s, %d in the manifest", ErrChecksumMismatch, len(snaps), BOOKS_FILE, len(m.Books))
+	}
+	for idx, snap := range snaps {
+		if BookChecksum(snap) != m.Books[idx].Checksum {
+			return m, nil, fmt.Errorf("%w: book %s/%s", ErrChecksumMismatch, snap.TenantID, snap.PairID)
+		}
+	}
+	return m, snaps, nil
+}
+
+// migration is the schema version of the database, it has to be clean
+func migration(ctx context.Context, tx pgx.Tx) (uint64, error) {
+	var version uint64
+	var dirty bool
+	if err := tx.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty); err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("%w: version %d", db.ErrMigrationsDirty, version)
+	}
+	return version, nil
+}
+
+// copyOut writes the rows of a table to path, partitioned tables and
+// hypertables can only be copied out of a query
+func copyOut(ctx context.Context, tx pgx.Tx, table string, path string) (int64, string, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	tag, err := tx.Conn().PgConn().CopyTo(ctx, io.MultiWriter(f, h), "COPY (SELECT * FROM "+pgx.Identifier{table}.Sanitize()+") TO STDOUT")
+	if err != nil {
+		return 0, "", err
+	}
+	if err := f.Close(); err != nil {
+		return 0, "", err
+	}
+	return tag.RowsAffected(), hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// latestSnapshots reads the latest snapshot of every book
+func latestSnapshots(ctx context.Context, tx pgx.Tx) ([]order.BookSnapshot, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT DISTINCT ON (tenant_id, pair_id) tenant_id, pair_id, seq, asks, bids, created_at
+		FROM tbl_book_snapshots
+		ORDER BY tenant_id, pair_id, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var snaps []order.BookSnapshot
+	for rows.Next() {
+		var snap order.BookSnapshot
+		var asks, bids []byte
+		if err := rows.Scan(&snap.TenantID, &snap.PairID, &snap.Seq, &asks, &bids, &snap.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(asks, &snap.Asks); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(bids, &snap.Bids); err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, snap)
+	}
+	return snaps, rows.Err()
+}
+
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
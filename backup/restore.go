@@ -0,0 +1,175 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-book/book"
+	"order-book/fee"
+	"order-book/order"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrMigrationMismatch = errors.New("The database isn't at the schema version of the backup")
+	ErrNotEmpty          = errors.New("The database already holds orders")
+)
+
+// Restore loads the backup in dir into a database migrated to the version
+// of the backup, replacing the rows of its tables, then checks the books
+// the engine loads from it against the backup. A database holding orders is
+// only overwritten with force.
+//
+// The rows are loaded with the triggers off, the trade ticks and the outbox
+// are restored as they were rather than made again from the trades, which
+// takes a role allowed to set session_replication_role.
+func Restore(ctx context.Context, pool *pgxpool.Pool, dir string, force bool) (Manifest, error) {
+	m, _, err := Verify(dir)
+	if err != nil {
+		return m, err
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return m, err
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	version, err := migration(ctx, tx)
+	if err != nil {
+		return m, err
+	}
+	if version != m.Migration {
+		return m, fmt.Errorf("%w: at %d, the backup is at %d", ErrMigrationMismatch, version, m.Migration)
+	}
+	if !force {
+		var exists bool
+		if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM tbl_orders)").Scan(&exists); err != nil {
+			return m, err
+		}
+		if exists {
+			return m, ErrNotEmpty
+		}
+	}
+	if _, err := tx.Exec(ctx, "SET LOCAL session_replication_role = replica"); err != nil {
+		return m, err
+	}
+
+	names := make([]string, len(m.Tables))
+	for idx, file := range m.Tables {
+		names[idx] = pgx.Identifier{file.Name}.Sanitize()
+	}
+	if _, err := tx.Exec(ctx, "TRUNCATE "+strings.Join(names, ", ")); err != nil {
+		return m, err
+	}
+	for _, file := range m.Tables {
+		rows, err := copyIn(ctx, tx, file.Name, filepath.Join(dir, file.File))
+		if err != nil {
+			return m, fmt.Errorf("failed to restore %s: %w", file.Name, err)
+		}
+		if rows != file.Rows {
+			return m, fmt.Errorf("%w: %d rows restored into %s, %d in the backup", ErrChecksumMismatch, rows, file.Name, file.Rows)
+		}
+	}
+	if err := resetSequences(ctx, tx, m.Tables); err != nil {
+		return m, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return m, err
+	}
+	return m, VerifyBooks(ctx, pool, m)
+}
+
+// VerifyBooks loads the latest snapshots of the database into books, the
+// way the engine does at start, and checks them against the books of the
+// manifest
+func VerifyBooks(ctx context.Context, pool *pgxpool.Pool, m Manifest) error {
+	repo := order.NewSnapshotRepository(pool, 0)
+	pairs := make(map[string][]string)
+	seqs := make(map[string]int64)
+	for _, entry := range m.Books {
+		pairs[entry.TenantID] = append(pairs[entry.TenantID], entry.PairID)
+		seqs[entry.TenantID] = max(seqs[entry.TenantID], entry.Seq)
+	}
+
+	books := make(map[string]book.Book)
+	defer func() {
+		for _, b := range books {
+			b.Drain(context.WithoutCancel(ctx))
+		}
+	}()
+	for tenantId, tenantPairs := range pairs {
+		b := book.NewBook(nil, nil, 1, fee.Schedule{})
+		books[tenantId] = b
+		if err := book.RestoreLatest(ctx, repo, tenantId, b, tenantPairs); err != nil {
+			return err
+		}
+		// The seq of a book is that of its latest change, whichever the pair
+		if b.Seq() != seqs[tenantId] {
+			return fmt.Errorf("%w: the book of %s is at seq %d, the backup at %d", ErrChecksumMismatch, tenantId, b.Seq(), seqs[tenantId])
+		}
+	}
+	for _, entry := range m.Books {
+		if BookChecksum(books[entry.TenantID].Snapshot(entry.PairID)) != entry.Checksum {
+			return fmt.Errorf("%w: the restored book %s/%s", ErrChecksumMismatch, entry.TenantID, entry.PairID)
+		}
+	}
+	return nil
+}
+
+func copyIn(ctx context.Context, tx pgx.Tx, table string, path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	tag, err := tx.Conn().PgConn().CopyFrom(ctx, f, "COPY "+pgx.Identifier{table}.Sanitize()+" FROM STDIN")
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// resetSequences moves the sequences of the serial columns past the rows
+// restored, the next row inserted gets a new ID
+func resetSequences(ctx context.Context, tx pgx.Tx, tables []TableFile) error {
+	names := make([]string, len(tables))
+	for idx, file := range tables {
+		names[idx] = file.Name
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT table_name, column_name
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name::text = ANY($1) AND column_default::text LIKE 'nextval(%'`, names)
+	if err != nil {
+		return err
+	}
+	type column struct{ table, name string }
+	var columns []column
+	for rows.Next() {
+		var c column
+		if err := rows.Scan(&c.table, &c.name); err != nil {
+			return err
+		}
+		columns = append(columns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, c := range columns {
+		_, err := tx.Exec(ctx, fmt.Sprintf(
+			"SELECT setval(pg_get_serial_sequence($1, $2), COALESCE(MAX(%s), 0) + 1, false) FROM %s",
+			pgx.Identifier{c.name}.Sanitize(), pgx.Identifier{c.table}.Sanitize(),
+		), c.table, c.name)
+		if err != nil {
+			return fmt.Errorf("failed to reset the sequence of %s.%s: %w", c.table, c.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package book
+
+import (
+	"context"
+	"order-book/order"
+	"slices"
+
+	"github.com/emirpasic/gods/trees/redblacktree"
+	"github.com/shopspring/decimal"
+)
+
+// uncrossFill is an order of the auction matched at the uncross, along with
+// the fills it made
+type uncrossFill struct {
+	taker   order.Order
+	results []MatchResult
+}
+
+// StartAuction collects the orders of a pair without matching them, they
+// rest on the book until EndAuction
+func (b *BookImpl) StartAuction(pairId string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.auctions == nil {
+		b.auctions = make(map[string]bool)
+	}
+	b.auctions[pairId] = true
+}
+
+// EndAuction uncrosses the book of a pair and goes back to matching the
+// orders as they come. The crossing orders are all filled at one clearing
+// price, the trades are published as any other.
+func (b *BookImpl) EndAuction(ctx context.Context, pairId string) {
+	b.mu.Lock()
+	if !b.auctions[pairId] {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.auctions, pairId)
+	fills := b.uncross(pairId)
+	b.mu.Unlock()
+
+	for _, f := range fills {
+		b.publishTrades(ctx, f.taker, f.results)
+		b.recordFills(ctx, f.taker, f.results)
+	}
+	if len(fills) > 0 {
+		engineLog(pairId).Info("auction uncrossed", map[string]any{
+			"pair_id": pairId,
+			"takers":  len(fills),
+		})
+	}
+}
+
+// inAuction reports whether the orders of a pair rest without matching,
+// callers hold b.mu
+func (b *BookImpl) inAuction(pairId string) bool {
+	return b.auctions[pairId]
+}
+
+// uncross fills the crossing orders of a pair at a single clearing price,
+// callers hold b.mu. The bids at or above it are filled against the asks at
+// or below it, both sides in price-time priority, and of each fill the order
+// that arrived last is the taker. Orders of the same owner are left unfilled
+// against each other, so less than the clearing volume may execute.
+func (b *BookImpl) uncross(pairId string) []uncrossFill {
+	asks := b.getTreeFor(pairId, order.ASK)
+	bids := b.getTreeFor(pairId, order.BID)
+	if asks == nil || bids == nil {
+		return nil
+	}
+	price, ok := clearingPrice(bids, asks)
+	if !ok {
+		return nil
+	}
+	var buyers, sellers []order.Order
+	for _, level := range slices.Backward(bids.Values()) {
+		orders := level.(*order.OrderList).List
+		if orders[0].Price.LessThan(price) {
+			break
+		}
+		buyers = append(buyers, orders...)
+	}
+	for _, level := range asks.Values() {
+		orders := level.(*order.OrderList).List
+		if orders[0].Price.GreaterThan(price) {
+			break
+		}
+		sellers = append(sellers, orders...)
+	}
+
+	var fills []uncrossFill
+	for bi := range buyers {
+		for si := range sellers {
+			bid, ask := &buyers[bi], &sellers[si]
+			if !bid.Amount.IsPositive() {
+				break
+			}
+			if !ask.Amount.IsPositive() || b.sameOwner(bid.AccountID, ask.AccountID) {
+				continue
+			}
+			maker, taker := ask, bid
+			if ask.CreatedAt.After(bid.CreatedAt) {
+				maker, taker = bid, ask
+			}
+			fills = append(fills, b.fillAt(maker, taker, decimal.Min(bid.Amount, ask.Amount), price))
+		}
+	}
+	if len(fills) > 0 {
+		b.lastPrices[pairId] = price
+		b.lastTrades[pairId] = b.now()
+	}
+	return fills
+}
+
+// fillAt fills amount of two resting orders against each other at price and
+// updates them on the book, callers hold b.mu
+func (b *BookImpl) fillAt(maker *order.Order, taker *order.Order, amount decimal.Decimal, price decimal.Decimal) uncrossFill {
+	result := MatchResult{
+		match_status: "partial",
+		price:        price,
+		before:       maker.Amount,
+	}
+	maker.Amount = maker.Amount.Sub(amount)
+	maker.Version++
+	if maker.Amount.IsZero() {
+		result.match_status = "full"
+	}
+	result.targetOrder = *maker
+	result.targetOrder.Amount = amount
+	result.remaining = maker.Amount
+
+	fill := uncrossFill{taker: *taker, results: []MatchResult{result}}
+	taker.Amount = taker.Amount.Sub(amount)
+	taker.Version++
+	b.replaceOrder(*maker)
+	b.replaceOrder(*taker)
+	b.seq++
+	b.emitChange(uncrossChange(*taker, fill.results, price))
+	return fill
+}
+
+// clearingPrice is the price of the book prices executing the most of the
+// crossing orders, then leaving the least of them unmatched, then the lowest.
+// It's false when the book doesn't cross.
+func clearingPrice(bids *redblacktree.Tree, asks *redblacktree.Tree) (decimal.Decimal, bool) {
+	var (
+		price                 = decimal.Zero
+		executable, imbalance decimal.Decimal
+	)
+	for _, candidate := range slices.Concat(bids.Keys(), asks.Keys()) {
+		p := candidate.(decimal.Decimal)
+		demand, supply := decimal.Zero, decimal.Zero
+		for _, node := range bids.Values() {
+			if level := node.(*order.OrderList); level.List[0].Price.GreaterThanOrEqual(p) {
+				demand = demand.Add(level.Amount)
+			}
+		}
+		for _, node := range asks.Values() {
+			if level := node.(*order.OrderList); level.List[0].Price.LessThanOrEqual(p) {
+				supply = supply.Add(level.Amount)
+			}
+		}
+		volume := decimal.Min(demand, supply)
+		if !volume.IsPositive() {
+			continue
+		}
+		left := demand.Sub(supply).Abs()
+		switch {
+		case volume.GreaterThan(executable),
+			volume.Equal(executable) && left.LessThan(imbalance),
+			volume.Equal(executable) && left.Equal(imbalance) && p.LessThan(price):
+			price, executable, imbalance = p, volume, left
+		}
+	}
+	return price, executable.IsPositive()
+}
+
+// uncrossChange is the change of a fill at the uncross, left is what remains
+// of its taker. Unlike an incoming order the taker was already on the book,
+// so it's among the filled orders.
+func uncrossChange(left order.Order, matchResults []MatchResult, price decimal.Decimal) Change {
+	ch := matchChange(left, matchResults, decimal.Zero)
+	ch.Filled = append(ch.Filled, left)
+	ch.LastPrice = price
+	return ch
+}
+
+// StartAuction starts an auction of a pair on every tenant book and on
+// those created until it ends
+func (r *Registry) StartAuction(pairId string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.auctions[pairId] = true
+	for _, b := range r.books {
+		b.StartAuction(pairId)
+	}
+}
+
+// EndAuction uncrosses the books of a pair, see Book.EndAuction
+func (r *Registry) EndAuction(ctx context.Context, pairId string) {
+	r.mu.Lock()
+	delete(r.auctions, pairId)
+	books := make([]Book, 0, len(r.books))
+	for _, b := range r.books {
+		books = append(books, b)
+	}
+	r.mu.Unlock()
+	for _, b := range books {
+		b.EndAuction(ctx, pairId)
+	}
+}
@@ -0,0 +1,76 @@
+package book
+
+import (
+	"context"
+	"order-book/order"
+
+	"github.com/shopspring/decimal"
+)
+
+// restingState is the state of a resting order at version, with remaining
+// left. Fills are the only changes of a resting order, so any version past
+// its creation is a partial fill.
+func restingState(version int, remaining decimal.Decimal) order.OrderState {
+	if version <= 1 {
+		return order.OrderState{Remaining: remaining, Status: order.STATUS_OPEN}
+	}
+	return order.OrderState{Remaining: remaining, Status: order.STATUS_PARTIALLY_FILLED}
+}
+
+// recordFills writes a TARGET_HIT event for the resting order of each fill
+// and an ORDER_FILLED one for the incoming order, both on behalf of whoever
+// submitted the incoming order
+func (b *BookImpl) recordFills(ctx context.Context, taker order.Order, matchResults []MatchResult) {
+	actor := order.ActorOf(ctx, taker.AccountID)
+	takerLeft := taker.Amount
+	for idx, res := range matchResults {
+		maker := res.targetOrder
+		before := restingState(maker.Version-1, res.before)
+		b.orderRepo.AddEvent(ctx, order.OrderHistoryEvent{
+			Name:    order.TARGET_HIT,
+			OrderId: maker.ID,
+			Metadata: order.AuditMetadata(map[string]any{
+				"taker_order_id": taker.ID,
+				"price":          res.price,
+				"amount":         maker.Amount,
+			}, maker.Version, actor, &before, order.StateAfterFill(res.remaining)),
+		})
+
+		version := taker.Version + idx + 1
+		before = restingState(version-1, takerLeft)
+		takerLeft = takerLeft.Sub(maker.Amount)
+		b.orderRepo.AddEvent(ctx, order.OrderHistoryEvent{
+			Name:    order.ORDER_FILLED,
+			OrderId: taker.ID,
+			Metadata: order.AuditMetadata(map[string]any{
+				"maker_order_id": maker.ID,
+				"price":          res.price,
+				"amount":         maker.Amount,
+			}, version, actor, &before, order.StateAfterFill(takerLeft)),
+		})
+	}
+}
+
+// recordRejection writes an ORDER_REJECTED event for an order a validator
+// refused, it has no ID as it was never persisted
+func (b *BookImpl) recordRejection(ctx context.Context, o order.Order, reason error) {
+	metadata := map[string]any{
+		"account_id": o.AccountID,
+		"pair_id":    o.PairID,
+		"type":       o.Type,
+		"price":      o.Price,
+		"amount":     o.Amount,
+		"reason":     reason.Error(),
+		"actor":      order.ActorOf(ctx, o.AccountID),
+	}
+	err := b.orderRepo.AddEvent(context.WithoutCancel(ctx), order.OrderHistoryEvent{
+		Name:     order.ORDER_REJECTED,
+		Metadata: metadata,
+	})
+	if err != nil {
+		engineLog(o.PairID).Error("failed to add order history event", map[string]any{
+			"account_id": o.AccountID,
+			"error":      err,
+		})
+	}
+}
@@ -1,32 +1,121 @@
 package book
 
 import (
-	"errors"
+	"context"
+	"order-book/fee"
 	"order-book/logger"
+	"order-book/metrics"
 	"order-book/order"
+	"order-book/panics"
+	"order-book/tracing"
 	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/emirpasic/gods/trees/redblacktree"
-	"github.com/emirpasic/gods/utils"
+	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var ErrOrderNotFound = errors.New("Order not found")
+var ErrOrderNotFound = order.ErrOrderNotFound
+
+// OrderValidator runs before an order is queued for matching, a non-nil error rejects it
+type OrderValidator func(o order.Order) error
 
 type Book interface {
-	AddOrder(o order.Order)
+	// AddOrder validates and queues an order for matching. ctx only bounds the
+	// wait for room in the queue, the engine persists the order on its own.
+	AddOrder(ctx context.Context, o order.Order) error
+	// AddOrderWithTimings is AddOrder waiting for the engine to be done with
+	// the order, it reports how long each stage took for debugging
+	AddOrderWithTimings(ctx context.Context, o order.Order) (StageTimings, error)
+	AddValidator(fn OrderValidator)
 	GetOrders(pairId string, size int, offset int) (
 		ask []order.Order,
 		bid []order.Order,
 	)
-	CancellOrder(id int) error
+	CancellOrder(ctx context.Context, id int) error
+	// OnTrade registers a listener called from the engine goroutine for every fill
+	OnTrade(fn func(t order.Trade))
+	// OnOrderEvent registers a listener for orders being created and cancelled
+	OnOrderEvent(fn func(ev order.OrderEvent))
+	LastPrice(pairId string) (price decimal.Decimal, ok bool)
+	// LastTradeAt is when a pair last traded, zero if it didn't since the start
+	LastTradeAt(pairId string) time.Time
+	// Top is the best bid and ask of a pair, ok is false while a side is empty
+	Top(pairId string) (bid decimal.Decimal, ask decimal.Decimal, ok bool)
+	// Best is the best price resting on a side of a pair, ok is false while
+	// the side is empty
+	Best(pairId string, side order.OrderType) (price decimal.Decimal, ok bool)
+	GetAccountOrders(accountId int) []order.Order
+	// OpenOrders is the number of orders resting on the book across all pairs
+	OpenOrders() int
+	// Seq is the number of engine events (accepted orders and cancellations)
+	// applied to the book so far
+	Seq() int64
+	// Pairs lists the pairs the book has seen orders for
+	Pairs() []string
+	// QueueDepth is the number of accepted orders waiting for the engine
+	QueueDepth() int
+	Health() EngineHealth
+	// Size counts the price levels and orders resting on each side of a pair
+	Size(pairId string) PairSize
+	// Stats describe the resting orders of each side of a pair, cheaply
+	Stats(pairId string) BookStats
+	// Snapshot copies the resting orders of a pair along with the Seq they
+	// reflect. TenantID is left for the caller to fill.
+	Snapshot(pairId string) order.BookSnapshot
+	// OnChange registers a listener for every change of the resting orders,
+	// called with the book locked
+	OnChange(fn func(ch Change))
+	// Restore and ApplyChange rebuild the book of another node from its
+	// snapshots and changes
+	Restore(snap order.BookSnapshot)
+	ApplyChange(ch Change)
+	// Drain stops accepting orders and waits for the engine to be done with
+	// the queued ones, or for ctx to be done
+	Drain(ctx context.Context) error
+	// SetFees replaces the fee schedule, for the trades from then on
+	SetFees(fees fee.Schedule)
+	// WouldMatch reports whether an order would fill against the book
+	WouldMatch(o order.Order) bool
+	// BeforeMatch registers a hook the engine runs before matching an
+	// order, the engine waits for it
+	BeforeMatch(fn func(o order.Order))
+	// SetSTPGroups groups accounts for self-trade prevention: the orders of
+	// the accounts of a group don't match each other, like those of one
+	// account. groupOf is empty for an account in no group.
+	SetSTPGroups(groupOf func(accountId int) string)
+	// OpenPair and ClosePair create and drop the book of a listed pair
+	OpenPair(pairId string)
+	ClosePair(pairId string) error
+	// StartAuction rests the orders of a pair without matching them until
+	// EndAuction uncrosses the book
+	StartAuction(pairId string)
+	EndAuction(ctx context.Context, pairId string)
+	// Quote replaces the quote of an account on a pair and waits for the
+	// engine, see BookImpl.Quote
+	Quote(ctx context.Context, q order.Quote) (QuoteResult, error)
+	// CancelQuotes pulls the quotes of an account on a pair, or on every
+	// pair when pairId is empty
+	CancelQuotes(ctx context.Context, accountId int, pairId string) (QuoteResult, error)
+}
+
+type PairSize struct {
+	AskLevels int `json:"ask_levels"`
+	BidLevels int `json:"bid_levels"`
+	AskOrders int `json:"ask_orders"`
+	BidOrders int `json:"bid_orders"`
 }
 
 type OrderMetadata struct {
 	PairId string
-	Price  float64
+	Price  decimal.Decimal
 	Type   order.OrderType
-	Amount float64
+	Amount decimal.Decimal
 	ID     int
 }
 
@@ -34,31 +123,104 @@ type BookImpl struct {
 	mu                     sync.RWMutex
 	askTreesMap            map[string]*redblacktree.Tree
 	bidTreesMap            map[string]*redblacktree.Tree
-	orderProcessingChannel chan order.Order
+	orderProcessingChannel chan queuedOrder
 	orderRepo              order.OrderRepo
+	tradeRepo              order.TradeRepo
+	lastPrices             map[string]decimal.Decimal
+	lastTrades             map[string]time.Time
+	tradeListeners         []func(t order.Trade)
+	orderEventListeners    []func(ev order.OrderEvent)
+	changeListeners        []func(ch Change)
+	matchHooks             []func(o order.Order)
+	validators             []OrderValidator
+	fees                   fee.Schedule
+	seq                    int64
+	// stpGroupOf is the STP group of an account, nil until SetSTPGroups
+	stpGroupOf func(accountId int) string
+	// open are the pairs opened with OpenPair, nil until the first one
+	open map[string]bool
+	// auctions are the pairs whose orders rest without matching
+	auctions map[string]bool
+	// stats describe the resting orders of each pair, see adjust
+	stats map[string]*pairStats
+	// quotes are the orders of the last quote of each account on a pair,
+	// only the engine goroutine reads or writes them
+	quotes map[quoteKey][]order.Order
+	// now stamps the trades, events and changes, the wall clock but in
+	// simulations
+	now     func() time.Time
+	running atomic.Bool
+	// closing guards closed, orders are queued under its read lock so the
+	// queue isn't closed while they wait for room. stopped is closed once
+	// the engine is done with the queue.
+	closing sync.RWMutex
+	closed  bool
+	stopped chan struct{}
+	// lastDequeue is the unix nano time the engine took the last order
+	lastDequeue atomic.Int64
+	// persists and persistFailures count the writes of orders and trades
+	persists        atomic.Uint64
+	persistFailures atomic.Uint64
+	// panics counts the orders the engine panicked on
+	panics atomic.Uint64
 }
 
-func (b *BookImpl) insertOrder(o order.Order) {
-	createdOrder, err := b.orderRepo.CreateOrder(o.PairID, o.Price, o.Amount, o.AccountID, o.Type)
-	if err != nil {
-		logger.Error("failed to add order history event", map[string]any{
-			"error": err,
-		})
-	}
+// queuedOrder is an order waiting for the engine along with the trace of the
+// request that submitted it
+type queuedOrder struct {
+	order   order.Order
+	ctx     context.Context
+	timings StageTimings
+	// done receives the timings once the engine is through, when set
+	done chan StageTimings
+	// quote is set for a two-sided quote and cancelQuotes to pull quotes,
+	// quoteDone receives their result
+	quote        *order.Quote
+	cancelQuotes *quoteKey
+	quoteDone    chan QuoteResult
+	// persisted is set for a side of a quote, checked and persisted before
+	// the previous quote was pulled
+	persisted bool
+}
+
+// tradeSeq is shared by every book so trade IDs stay unique across tenants.
+// It is seeded from the clock so trade IDs keep increasing across restarts.
+var tradeSeq atomic.Int64
+
+func init() {
+	tradeSeq.Store(time.Now().UnixMicro())
+}
+
+// engineLog is the logger of the engine for a pair, so its level can be
+// lowered for a single pair during an incident
+func engineLog(pairId string) *logger.Child {
+	return logger.Component("engine/" + pairId)
+}
+
+func (b *BookImpl) persistOrder(ctx context.Context, o order.Order) (order.Order, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "engine.persist")
+	defer span.End()
+	createdOrder, err := b.orderRepo.CreateOrder(ctx, o.PairID, o.Price, o.Amount, o.AccountID, o.Type, o.Tag, o.StrategyID)
+	b.persists.Add(1)
 	if err != nil {
-		logger.Error("failed to create order", map[string]any{
-			"order_id": o.ID,
-			"pair_id":  o.PairID,
-			"price":    o.Price,
-			"amount":   o.Amount,
-			"error":    err,
+		b.persistFailures.Add(1)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		engineLog(o.PairID).Error("failed to create order", map[string]any{
+			"pair_id": o.PairID,
+			"price":   o.Price,
+			"amount":  o.Amount,
+			"error":   err,
 		})
-		return
+		return o, err
 	}
 	o.ID = createdOrder.ID
-	b.mu.Lock()
-	defer b.mu.Unlock()
+	o.Version = createdOrder.Version
+	return o, nil
+}
 
+// insertOrder rests an order on the book, callers hold b.mu
+func (b *BookImpl) insertOrder(o order.Order) {
 	tree := b.getTreeFor(o.PairID, o.Type)
 	if tree == nil {
 		tree = b.genTreeFor(o.PairID, o.Type)
@@ -66,8 +228,10 @@ func (b *BookImpl) insertOrder(o order.Order) {
 
 	node := tree.GetNode(o.Price)
 	if node == nil {
-		tree.Put(o.Price, &order.OrderList{List: []order.Order{o}})
-		logger.Debug("order inserted at new price level", map[string]any{
+		level := &order.OrderList{List: []order.Order{o}}
+		tree.Put(o.Price, level)
+		b.adjust(o, level, o.Amount, 1)
+		engineLog(o.PairID).Debug("order inserted at new price level", map[string]any{
 			"order_id": o.ID,
 			"pair_id":  o.PairID,
 			"price":    o.Price,
@@ -88,7 +252,8 @@ func (b *BookImpl) insertOrder(o order.Order) {
 		return 0
 	})
 	node.Value.(*order.OrderList).List = orderList
-	logger.Debug("order inserted at existing price level", map[string]interface{}{
+	b.adjust(o, node.Value.(*order.OrderList), o.Amount, 1)
+	engineLog(o.PairID).Debug("order inserted at existing price level", map[string]interface{}{
 		"order_id":        o.ID,
 		"pair_id":         o.PairID,
 		"price":           o.Price,
@@ -97,15 +262,20 @@ func (b *BookImpl) insertOrder(o order.Order) {
 	})
 }
 
+// MatchResult is a fill of a resting order, targetOrder holds the filled
+// amount and the version of the fill. before and remaining are what was left
+// of the resting order around it. price is what the fill traded at, the
+// price of the resting order but at the uncross of an auction.
 type MatchResult struct {
 	targetOrder  order.Order
 	match_status string
+	price        decimal.Decimal
+	before       decimal.Decimal
+	remaining    decimal.Decimal
 }
 
-func (b *BookImpl) matchOrder(o order.Order) (matchResults []MatchResult, amountLeft float64) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
+// matchOrder fills an order against the opposite side, callers hold b.mu
+func (b *BookImpl) matchOrder(o order.Order) (matchResults []MatchResult, amountLeft decimal.Decimal) {
 	var treeType order.OrderType
 	if o.Type == order.ASK {
 		treeType = order.BID
@@ -113,13 +283,14 @@ func (b *BookImpl) matchOrder(o order.Order) (matchResults []MatchResult, amount
 		treeType = order.ASK
 	}
 
+	amountLeft = o.Amount
 	tree := b.getTreeFor(o.PairID, treeType)
 	if tree == nil {
-		tree = b.genTreeFor(o.PairID, treeType)
+		return
 	}
 	priceMatchedOrdersNode := tree.GetNode(o.Price)
 	if priceMatchedOrdersNode == nil {
-		logger.Debug("no matching orders with this price found", map[string]any{
+		engineLog(o.PairID).Debug("no matching orders with this price found", map[string]any{
 			"order_id": o.ID,
 			"pair_id":  o.PairID,
 			"price":    o.Price,
@@ -127,38 +298,60 @@ func (b *BookImpl) matchOrder(o order.Order) (matchResults []MatchResult, amount
 		return
 	}
 
-	amountLeft = o.Amount
-	ordersList := priceMatchedOrdersNode.Value.(*order.OrderList).List
+	level := priceMatchedOrdersNode.Value.(*order.OrderList)
+	ordersList := level.List
 
-	for idx := 0; idx < len(ordersList) && amountLeft > 0; {
-		// Skip user's previous orders
-		if ordersList[idx].AccountID == o.AccountID {
+	for idx := 0; idx < len(ordersList) && amountLeft.IsPositive(); {
+		// Skip user's previous orders, and those of its STP group
+		if b.sameOwner(ordersList[idx].AccountID, o.AccountID) {
 			idx++
 			continue
 		}
 		// NOTE: A larger existing order causes a break -> No need to increase idx
 		// 		 A smaller existing order causes a delete -> a shift in the array -> idx now points to the next element automatically -> no need to increase idx
 		// 		 This is more like a FIFO stack instead of an array
-		if ordersList[idx].Amount > amountLeft {
+		if ordersList[idx].Amount.GreaterThan(amountLeft) {
+			before := ordersList[idx].Amount
+			ordersList[idx].Amount = ordersList[idx].Amount.Sub(amountLeft)
+			ordersList[idx].Version++
 			matched := ordersList[idx]
 			matched.Amount = amountLeft
-			ordersList[idx].Amount -= amountLeft
-			matchResults = append(matchResults, MatchResult{targetOrder: matched, match_status: "partial"})
-			amountLeft = 0
+			b.adjust(matched, level, amountLeft.Neg(), 0)
+			matchResults = append(matchResults, MatchResult{
+				targetOrder:  matched,
+				match_status: "partial",
+				price:        matched.Price,
+				before:       before,
+				remaining:    ordersList[idx].Amount,
+			})
+			amountLeft = decimal.Zero
 			break
 		}
-		if ordersList[idx].Amount <= amountLeft {
+		if ordersList[idx].Amount.LessThanOrEqual(amountLeft) {
 			matched := ordersList[idx]
-			matchResults = append(matchResults, MatchResult{targetOrder: matched, match_status: "full"})
-			amountLeft -= matched.Amount
+			matched.Version++
+			matchResults = append(matchResults, MatchResult{
+				targetOrder:  matched,
+				match_status: "full",
+				price:        matched.Price,
+				before:       matched.Amount,
+				remaining:    decimal.Zero,
+			})
+			amountLeft = amountLeft.Sub(matched.Amount)
 			ordersList = slices.Delete(ordersList, idx, idx+1)
+			b.adjust(matched, level, matched.Amount.Neg(), -1)
 			continue
 		}
 	}
-	priceMatchedOrdersNode.Value.(*order.OrderList).List = ordersList
+	level.List = ordersList
+	if len(ordersList) == 0 {
+		tree.Remove(priceMatchedOrdersNode.Key)
+	}
 
 	if len(matchResults) > 0 {
-		logger.Info("order matched", map[string]any{
+		b.lastPrices[o.PairID] = o.Price
+		b.lastTrades[o.PairID] = b.now()
+		engineLog(o.PairID).Info("order matched", map[string]any{
 			"order_id":      o.ID,
 			"pair_id":       o.PairID,
 			"matched_count": len(matchResults),
@@ -169,61 +362,210 @@ func (b *BookImpl) matchOrder(o order.Order) (matchResults []MatchResult, amount
 	return
 }
 
-func (b *BookImpl) CancellOrder(id int) error {
-	logger.Info("Searching for order", map[string]any{
+func (b *BookImpl) CancellOrder(ctx context.Context, id int) error {
+	log := logger.Component("engine").With(map[string]any{
 		"order_id": id,
 	})
+	log.Info("Searching for order")
 
-	foundOrder, err := b.orderRepo.GetOrderByID(id)
+	foundOrder, err := b.orderRepo.GetOrderByID(ctx, id)
 	if err != nil {
-		logger.Error("Order not found in the index")
+		log.Error("Order not found in the index", map[string]any{
+			"error": err,
+		})
 		return err
 	}
+	log = engineLog(foundOrder.PairID).With(map[string]any{
+		"order_id": id,
+		"pair_id":  foundOrder.PairID,
+	})
 
-	tree := b.getTreeFor(foundOrder.PairID, foundOrder.Type)
-	if tree == nil {
-		logger.Error("Order tree not found")
+	removed, found := b.cancelResting(ctx, foundOrder)
+	if !found {
 		return ErrOrderNotFound
 	}
-	node := tree.GetNode(foundOrder.Price)
+	log.Debug("order removed", map[string]any{
+		"type":   removed.Type,
+		"price":  removed.Price,
+		"amount": removed.Amount,
+	})
+	return nil
+}
+
+// cancelResting takes an order off the book, records its cancellation and
+// tells the listeners. It reports false if the order isn't resting.
+func (b *BookImpl) cancelResting(ctx context.Context, o order.Order) (order.Order, bool) {
+	b.mu.Lock()
+	tree := b.getTreeFor(o.PairID, o.Type)
+	if tree == nil {
+		b.mu.Unlock()
+		return order.Order{}, false
+	}
+	node := tree.GetNode(o.Price)
 	if node == nil {
-		return ErrOrderNotFound
+		b.mu.Unlock()
+		return order.Order{}, false
+	}
+	removed, found := b.removeOrder(tree, node, o.ID)
+	if found {
+		b.seq++
+		b.emitChange(Change{PairID: removed.PairID, Cancelled: &removed})
 	}
+	b.mu.Unlock()
+	if !found {
+		return order.Order{}, false
+	}
+	metrics.OrdersCancelled.WithLabelValues(removed.PairID).Inc()
+
+	// The order already left the book, the event is recorded even if the
+	// request is cancelled meanwhile
+	b.recordCancelled(ctx, removed)
+	b.publishOrderEvent(order.ORDER_CANCELLED, removed)
+	return removed, true
+}
 
+// recordCancelled adds the cancellation of an order to its history, o.Amount
+// being what was left of it
+func (b *BookImpl) recordCancelled(ctx context.Context, o order.Order) {
+	before := restingState(o.Version, o.Amount)
+	err := b.orderRepo.AddEvent(context.WithoutCancel(ctx), order.OrderHistoryEvent{
+		Name:    order.ORDER_CANCELLED,
+		OrderId: o.ID,
+		Metadata: order.AuditMetadata(nil, o.Version+1, order.ActorOf(ctx, o.AccountID), &before, order.OrderState{
+			Remaining: decimal.Zero,
+			Status:    order.STATUS_CANCELLED,
+		}),
+	})
+	if err != nil {
+		engineLog(o.PairID).Error("failed to add order history event", map[string]any{
+			"order_id": o.ID,
+			"error":    err,
+		})
+	}
+}
+
+func (b *BookImpl) removeOrder(tree *redblacktree.Tree, node *redblacktree.Node, id int) (order.Order, bool) {
 	orders := node.Value.(*order.OrderList).List
 	for idx, o := range orders {
-		if o.ID == foundOrder.ID {
-			logger.Debug("order removed", map[string]any{
-				"order_id": o.ID,
-				"pair_id":  o.PairID,
-				"type":     o.Type,
-				"price":    o.Price,
-				"amount":   o.Amount,
-			})
-			orders := slices.Delete(orders, idx, idx+1)
+		if o.ID == id {
+			orders = slices.Delete(orders, idx, idx+1)
 			node.Value.(*order.OrderList).List = orders
-			err = b.orderRepo.AddEvent(order.OrderHistoryEvent{
-				Name:    "ORDER_CANCELLED",
-				OrderId: foundOrder.ID,
-			})
+			b.adjust(o, node.Value.(*order.OrderList), o.Amount.Neg(), -1)
 			if len(orders) == 0 {
 				tree.Remove(node.Key)
 			}
-			return nil
+			return o, true
 		}
 	}
-	return ErrOrderNotFound
+	return order.Order{}, false
 }
 
-func (b *BookImpl) AddOrder(o order.Order) {
-	logger.Info("order received", map[string]any{
+func (b *BookImpl) AddOrder(ctx context.Context, o order.Order) error {
+	return b.addOrder(ctx, o, nil)
+}
+
+func (b *BookImpl) AddOrderWithTimings(ctx context.Context, o order.Order) (StageTimings, error) {
+	done := make(chan StageTimings, 1)
+	if err := b.addOrder(ctx, o, done); err != nil {
+		return StageTimings{}, err
+	}
+	select {
+	case timings := <-done:
+		return timings, timings.Err
+	case <-ctx.Done():
+		return StageTimings{}, ctx.Err()
+	}
+}
+
+func (b *BookImpl) addOrder(ctx context.Context, o order.Order, done chan StageTimings) error {
+	receivedAt := time.Now()
+	ctx, span := tracing.Tracer.Start(ctx, "book.add_order", trace.WithAttributes(
+		attribute.String("pair_id", o.PairID),
+		attribute.Int("account_id", o.AccountID),
+	))
+	defer span.End()
+	engineLog(o.PairID).Info("order received", map[string]any{
 		"order_id": o.ID,
 		"pair_id":  o.PairID,
 		"type":     o.Type,
 		"price":    o.Price,
 		"amount":   o.Amount,
 	})
-	b.orderProcessingChannel <- o
+	metrics.OrdersReceived.WithLabelValues(o.PairID).Inc()
+
+	if err := b.validate(ctx, o); err != nil {
+		span.SetAttributes(attribute.String("reject_reason", err.Error()))
+		return err
+	}
+
+	b.closing.RLock()
+	defer b.closing.RUnlock()
+	if b.closed {
+		return ErrBookClosed
+	}
+	select {
+	// The engine goes on with the trace once the request returned
+	case b.orderProcessingChannel <- queuedOrder{
+		order:   o,
+		ctx:     context.WithoutCancel(ctx),
+		timings: StageTimings{ReceivedAt: receivedAt},
+		done:    done,
+	}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// validate runs the validators on an order, the first to refuse it records
+// the rejection
+func (b *BookImpl) validate(ctx context.Context, o order.Order) error {
+	b.mu.RLock()
+	validators := b.validators
+	b.mu.RUnlock()
+	for _, validate := range validators {
+		if err := validate(o); err != nil {
+			metrics.OrdersRejected.WithLabelValues(o.PairID).Inc()
+			engineLog(o.PairID).Info("order rejected", map[string]any{
+				"account_id": o.AccountID,
+				"pair_id":    o.PairID,
+				"reason":     err.Error(),
+			})
+			b.recordRejection(ctx, o, err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *BookImpl) AddValidator(fn OrderValidator) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.validators = append(b.validators, fn)
+}
+
+func (b *BookImpl) SetSTPGroups(groupOf func(accountId int) string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stpGroupOf = groupOf
+}
+
+// sameOwner tells whether two orders can't match each other, callers hold b.mu
+func (b *BookImpl) sameOwner(a int, other int) bool {
+	if a == other {
+		return true
+	}
+	if b.stpGroupOf == nil {
+		return false
+	}
+	group := b.stpGroupOf(a)
+	return group != "" && group == b.stpGroupOf(other)
+}
+
+func (b *BookImpl) BeforeMatch(fn func(o order.Order)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.matchHooks = append(b.matchHooks, fn)
 }
 
 func (b *BookImpl) GetOrders(pairId string, size int, offset int) (
@@ -260,7 +602,7 @@ func (b *BookImpl) GetOrders(pairId string, size int, offset int) (
 		}
 	}
 
-	logger.Debug("retrieved all orders", map[string]any{
+	engineLog(pairId).Debug("retrieved all orders", map[string]any{
 		"pair_id":   pairId,
 		"ask_count": len(ask),
 		"bid_count": len(bid),
@@ -269,6 +611,281 @@ func (b *BookImpl) GetOrders(pairId string, size int, offset int) (
 	return
 }
 
+func (b *BookImpl) OnTrade(fn func(t order.Trade)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tradeListeners = append(b.tradeListeners, fn)
+}
+
+func (b *BookImpl) LastPrice(pairId string) (decimal.Decimal, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	price, ok := b.lastPrices[pairId]
+	return price, ok
+}
+
+func (b *BookImpl) LastTradeAt(pairId string) time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.lastTrades[pairId]
+}
+
+func (b *BookImpl) Top(pairId string) (bid decimal.Decimal, ask decimal.Decimal, ok bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	bidTree, askTree := b.bidTreesMap[pairId], b.askTreesMap[pairId]
+	if bidTree == nil || askTree == nil || bidTree.Empty() || askTree.Empty() {
+		return decimal.Zero, decimal.Zero, false
+	}
+	// Price levels are sorted ascending
+	return bidTree.Right().Key.(decimal.Decimal), askTree.Left().Key.(decimal.Decimal), true
+}
+
+func (b *BookImpl) Best(pairId string, side order.OrderType) (price decimal.Decimal, ok bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if side == order.BID {
+		if tree := b.bidTreesMap[pairId]; tree != nil && !tree.Empty() {
+			return tree.Right().Key.(decimal.Decimal), true
+		}
+		return decimal.Zero, false
+	}
+	if tree := b.askTreesMap[pairId]; tree != nil && !tree.Empty() {
+		return tree.Left().Key.(decimal.Decimal), true
+	}
+	return decimal.Zero, false
+}
+
+func (b *BookImpl) OnOrderEvent(fn func(ev order.OrderEvent)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.orderEventListeners = append(b.orderEventListeners, fn)
+}
+
+func (b *BookImpl) publishOrderEvent(name string, o order.Order) {
+	b.mu.RLock()
+	listeners := b.orderEventListeners
+	now := b.now
+	b.mu.RUnlock()
+
+	ev := order.OrderEvent{Name: name, Order: o, At: now()}
+	for _, fn := range listeners {
+		fn(ev)
+	}
+}
+
+func (b *BookImpl) publishTrades(ctx context.Context, taker order.Order, matchResults []MatchResult) {
+	if len(matchResults) == 0 {
+		return
+	}
+	b.mu.RLock()
+	listeners := b.tradeListeners
+	fees := b.fees
+	now := b.now
+	b.mu.RUnlock()
+
+	takerRate := decimal.NewFromFloat(fees.ForAccount(taker.PairID, taker.AccountID).Taker)
+	trades := make([]order.Trade, len(matchResults))
+	for idx, matchResult := range matchResults {
+		maker := matchResult.targetOrder
+		notional := matchResult.price.Mul(maker.Amount)
+		makerFee := notional.Mul(decimal.NewFromFloat(fees.ForAccount(taker.PairID, maker.AccountID).Maker))
+		trades[idx] = order.Trade{
+			ID:              tradeSeq.Add(1),
+			PairID:          taker.PairID,
+			Price:           matchResult.price,
+			Amount:          maker.Amount,
+			MakerOrderID:    maker.ID,
+			TakerOrderID:    taker.ID,
+			MakerAccountID:  maker.AccountID,
+			TakerAccountID:  taker.AccountID,
+			TakerSide:       taker.Type,
+			MakerFee:        makerFee,
+			TakerFee:        notional.Mul(takerRate),
+			ExecutedAt:      now(),
+			MakerTag:        maker.Tag,
+			MakerStrategyID: maker.StrategyID,
+			TakerTag:        taker.Tag,
+			TakerStrategyID: taker.StrategyID,
+		}
+		// A negative maker rate is a rebate, reported apart from the fees
+		if makerFee.IsNegative() {
+			trades[idx].MakerFee, trades[idx].MakerRebate = decimal.Zero, makerFee.Neg()
+		}
+	}
+
+	// The fills are recorded before anyone is told about them
+	b.persists.Add(1)
+	if err := b.tradeRepo.AddTrades(ctx, trades); err != nil {
+		b.persistFailures.Add(1)
+		engineLog(taker.PairID).Error("failed to record trades", map[string]any{
+			"taker_order_id": taker.ID,
+			"pair_id":        taker.PairID,
+			"trade_count":    len(trades),
+			"error":          err,
+		})
+	}
+	metrics.Trades.WithLabelValues(taker.PairID).Add(float64(len(trades)))
+	for _, trade := range trades {
+		for _, fn := range listeners {
+			fn(trade)
+		}
+	}
+}
+
+// GetAccountOrders returns every resting order of an account across all pairs
+func (b *BookImpl) GetAccountOrders(accountId int) []order.Order {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var orders []order.Order
+	for _, treesMap := range []map[string]*redblacktree.Tree{b.askTreesMap, b.bidTreesMap} {
+		for _, tree := range treesMap {
+			it := tree.Iterator()
+			for it.Next() {
+				for _, o := range it.Value().(*order.OrderList).List {
+					if o.AccountID == accountId {
+						orders = append(orders, o)
+					}
+				}
+			}
+		}
+	}
+	return orders
+}
+
+func (b *BookImpl) OpenOrders() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	count := 0
+	for _, treesMap := range []map[string]*redblacktree.Tree{b.askTreesMap, b.bidTreesMap} {
+		for _, tree := range treesMap {
+			it := tree.Iterator()
+			for it.Next() {
+				count += len(it.Value().(*order.OrderList).List)
+			}
+		}
+	}
+	return count
+}
+
+func (b *BookImpl) Seq() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.seq
+}
+
+// SetClock replaces the clock stamping the trades, the order events and the
+// changes, for simulations under a virtual clock. The timings and the
+// health of the engine keep the wall clock.
+func (b *BookImpl) SetClock(now func() time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.now = now
+}
+
+func (b *BookImpl) SetFees(fees fee.Schedule) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fees = fees
+}
+
+func (b *BookImpl) Pairs() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var pairs []string
+	for pairId := range b.askTreesMap {
+		pairs = append(pairs, pairId)
+	}
+	for pairId := range b.bidTreesMap {
+		if _, ok := b.askTreesMap[pairId]; !ok {
+			pairs = append(pairs, pairId)
+		}
+	}
+	slices.Sort(pairs)
+	return pairs
+}
+
+func (b *BookImpl) QueueDepth() int {
+	return len(b.orderProcessingChannel)
+}
+
+func (b *BookImpl) Health() EngineHealth {
+	h := EngineHealth{
+		Running:         b.running.Load(),
+		QueueDepth:      len(b.orderProcessingChannel),
+		QueueCapacity:   cap(b.orderProcessingChannel),
+		Persists:        b.persists.Load(),
+		PersistFailures: b.persistFailures.Load(),
+		Panics:          b.panics.Load(),
+	}
+	if last := b.lastDequeue.Load(); last != 0 {
+		h.LastDequeue = time.Unix(0, last)
+	}
+	return h
+}
+
+func (b *BookImpl) Size(pairId string) PairSize {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var size PairSize
+	size.AskLevels, size.AskOrders = treeSize(b.askTreesMap[pairId])
+	size.BidLevels, size.BidOrders = treeSize(b.bidTreesMap[pairId])
+	return size
+}
+
+func treeSize(tree *redblacktree.Tree) (levels int, orders int) {
+	if tree == nil {
+		return 0, 0
+	}
+	it := tree.Iterator()
+	for it.Next() {
+		orders += len(it.Value().(*order.OrderList).List)
+	}
+	return tree.Size(), orders
+}
+
+func (b *BookImpl) Snapshot(pairId string) order.BookSnapshot {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return order.BookSnapshot{
+		PairID:    pairId,
+		Seq:       b.seq,
+		Asks:      snapshotLevels(b.askTreesMap[pairId]),
+		Bids:      snapshotLevels(b.bidTreesMap[pairId]),
+		CreatedAt: b.now(),
+	}
+}
+
+func snapshotLevels(tree *redblacktree.Tree) []order.PriceLevel {
+	if tree == nil {
+		return nil
+	}
+	levels := make([]order.PriceLevel, 0, tree.Size())
+	it := tree.Iterator()
+	for it.Next() {
+		list := it.Value().(*order.OrderList).List
+		level := order.PriceLevel{
+			Price:  it.Key().(decimal.Decimal),
+			Orders: make([]order.SnapshotOrder, len(list)),
+		}
+		for idx, o := range list {
+			level.Orders[idx] = order.SnapshotOrder{
+				ID:        o.ID,
+				AccountID: o.AccountID,
+				Amount:    o.Amount,
+				CreatedAt: o.CreatedAt,
+				Version:   o.Version,
+			}
+		}
+		levels = append(levels, level)
+	}
+	return levels
+}
+
 func (b *BookImpl) getTreeFor(pairId string, orderType order.OrderType) *redblacktree.Tree {
 	if orderType == order.ASK {
 		tree := b.askTreesMap[pairId]
@@ -281,15 +898,21 @@ func (b *BookImpl) getTreeFor(pairId string, orderType order.OrderType) *redblac
 	return nil
 }
 
+// priceComparator orders price levels, equal decimals with different
+// trailing zeros share a level
+func priceComparator(a, b any) int {
+	return a.(decimal.Decimal).Cmp(b.(decimal.Decimal))
+}
+
 func (b *BookImpl) genTreeFor(pairId string, orderType order.OrderType) *redblacktree.Tree {
-	tree := redblacktree.NewWith(utils.Float64Comparator)
+	tree := redblacktree.NewWith(priceComparator)
 	if orderType == order.ASK {
-		logger.Debug("created new ask tree", map[string]any{
+		engineLog(pairId).Debug("created new ask tree", map[string]any{
 			"pair_id": pairId,
 		})
 		b.askTreesMap[pairId] = tree
 	} else {
-		logger.Debug("created new bid tree", map[string]any{
+		engineLog(pairId).Debug("created new bid tree", map[string]any{
 			"pair_id": pairId,
 		})
 		b.bidTreesMap[pairId] = tree
@@ -297,45 +920,183 @@ func (b *BookImpl) genTreeFor(pairId string, orderType order.OrderType) *redblac
 	return tree
 }
 
-func NewBook(orderRepo order.OrderRepo) Book {
-	logger.Info("order book initialized")
+// NewBook starts the engine of a book. queueSize is the number of accepted
+// orders that can wait for matching before AddOrder blocks.
+func NewBook(orderRepo order.OrderRepo, tradeRepo order.TradeRepo, queueSize int, fees fee.Schedule) Book {
+	logger.Component("engine").Info("order book initialized")
 
 	b := BookImpl{
 		askTreesMap:            make(map[string]*redblacktree.Tree, 0),
 		bidTreesMap:            make(map[string]*redblacktree.Tree, 0),
-		orderProcessingChannel: make(chan order.Order),
+		orderProcessingChannel: make(chan queuedOrder, queueSize),
 		orderRepo:              orderRepo,
+		tradeRepo:              tradeRepo,
+		lastPrices:             make(map[string]decimal.Decimal),
+		lastTrades:             make(map[string]time.Time),
+		fees:                   fees,
+		now:                    time.Now,
+		stopped:                make(chan struct{}),
+		quotes:                 make(map[quoteKey][]order.Order),
+		stats:                  make(map[string]*pairStats),
 	}
 
+	b.running.Store(true)
 	go func() {
-		for o := range b.orderProcessingChannel {
-			matchedResults, amountLeft := b.matchOrder(o)
-			b.insertOrder(o)
-
-			for _, matchedResult := range matchedResults {
-				b.orderRepo.AddEvent(order.OrderHistoryEvent{
-					Name:    "TARGET_HIT",
-					OrderId: matchedResult.targetOrder.ID,
-					Metadata: map[string]any{
-						"matching_order_id": o.ID,
-					},
-				})
-			}
-
-			if amountLeft > 0 {
-				logger.Info("order partially matched", map[string]any{
-					"order_id":  o.ID,
-					"pair_id":   o.PairID,
-					"remaining": amountLeft,
-				})
+		defer close(b.stopped)
+		defer b.running.Store(false)
+		for q := range b.orderProcessingChannel {
+			b.lastDequeue.Store(time.Now().UnixNano())
+			switch {
+			case q.quote != nil:
+				b.processQuote(q)
+				continue
+			case q.cancelQuotes != nil:
+				b.processCancelQuotes(q)
 				continue
 			}
-			logger.Info("order fully matched", map[string]any{
-				"order_id": o.ID,
-				"pair_id":  o.PairID,
-			})
+			b.process(q)
 		}
 	}()
 
 	return &b
 }
+
+func (b *BookImpl) Drain(ctx context.Context) error {
+	b.closing.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.orderProcessingChannel)
+	}
+	b.closing.Unlock()
+	select {
+	case <-b.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// matchAndRest matches an order and rests what is left of it on the book,
+// during an auction it only rests. The lock is released even if it panics.
+func (b *BookImpl) matchAndRest(o order.Order) ([]MatchResult, decimal.Decimal) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var (
+		matchedResults []MatchResult
+		amountLeft     = o.Amount
+	)
+	if !b.inAuction(o.PairID) {
+		matchedResults, amountLeft = b.matchOrder(o)
+	}
+	if amountLeft.IsPositive() {
+		resting := o
+		resting.Amount = amountLeft
+		// Each fill is a version of the incoming order
+		resting.Version += len(matchedResults)
+		b.insertOrder(resting)
+	}
+	b.seq++
+	b.emitChange(matchChange(o, matchedResults, amountLeft))
+	return matchedResults, amountLeft
+}
+
+// process runs an order through the engine. Each stage is a span under the
+// request that submitted the order, so a slow order shows where it waited.
+func (b *BookImpl) process(q queuedOrder) {
+	timings := q.timings
+	timings.DequeuedAt = time.Now()
+	ctx, span := tracing.Tracer.Start(q.ctx, "engine.process", trace.WithAttributes(
+		attribute.String("pair_id", q.order.PairID),
+		attribute.Int64("queue_wait_us", timings.DequeuedAt.Sub(timings.ReceivedAt).Microseconds()),
+	))
+	defer span.End()
+	defer func() {
+		// A panic fails the order rather than the engine, which goes on
+		// with the next one
+		if recovered := recover(); recovered != nil {
+			b.panics.Add(1)
+			timings.Err = ErrEnginePanic
+			span.SetStatus(codes.Error, ErrEnginePanic.Error())
+			panics.Capture("engine", recovered, map[string]any{
+				"pair_id":    q.order.PairID,
+				"account_id": q.order.AccountID,
+				"price":      q.order.Price,
+				"amount":     q.order.Amount,
+			})
+		}
+		timings.observe()
+		if q.done != nil {
+			q.done <- timings
+		}
+	}()
+
+	o := q.order
+	if !q.persisted {
+		// The pair may have been closed while the order was queued
+		b.mu.RLock()
+		open := b.isOpen(q.order.PairID)
+		b.mu.RUnlock()
+		if !open {
+			timings.Err = ErrPairNotOpen
+			metrics.OrdersRejected.WithLabelValues(q.order.PairID).Inc()
+			b.recordRejection(ctx, q.order, ErrPairNotOpen)
+			return
+		}
+
+		// The book may have changed since the validators ran, the engine alone
+		// adds to it so a post-only order can't match once checked here
+		if q.order.PostOnly && b.WouldMatch(q.order) {
+			timings.Err = ErrPostOnlyWouldMatch
+			metrics.OrdersRejected.WithLabelValues(q.order.PairID).Inc()
+			b.recordRejection(ctx, q.order, ErrPostOnlyWouldMatch)
+			return
+		}
+
+		// The order is persisted first so fills can reference its ID
+		var err error
+		if o, err = b.persistOrder(ctx, q.order); err != nil {
+			timings.Err = err
+			return
+		}
+	}
+	span.SetAttributes(attribute.Int("order_id", o.ID))
+	timings.OrderID = o.ID
+	log := engineLog(o.PairID).With(map[string]any{
+		"order_id": o.ID,
+		"pair_id":  o.PairID,
+	})
+	// The created event goes out as part of persisting, before any fill
+	b.publishOrderEvent(order.ORDER_CREATED, o)
+	timings.PersistedAt = time.Now()
+
+	// Matching and resting the remainder is a single step for snapshots
+	_, matchSpan := tracing.Tracer.Start(ctx, "engine.match")
+	b.mu.RLock()
+	matchHooks := b.matchHooks
+	b.mu.RUnlock()
+	for _, fn := range matchHooks {
+		fn(o)
+	}
+	matchedResults, amountLeft := b.matchAndRest(o)
+	timings.MatchedAt = time.Now()
+	metrics.MatchLatency.WithLabelValues(o.PairID).Observe(timings.MatchedAt.Sub(timings.PersistedAt).Seconds())
+	matchSpan.SetAttributes(attribute.Int("fills", len(matchedResults)))
+	matchSpan.End()
+	if len(matchedResults) > 0 {
+		metrics.OrdersMatched.WithLabelValues(o.PairID).Inc()
+	}
+
+	publishCtx, publishSpan := tracing.Tracer.Start(ctx, "engine.publish")
+	b.publishTrades(publishCtx, o, matchedResults)
+	b.recordFills(publishCtx, o, matchedResults)
+	publishSpan.End()
+	timings.PublishedAt = time.Now()
+
+	if amountLeft.IsPositive() {
+		log.Info("order partially matched", map[string]any{
+			"remaining": amountLeft,
+		})
+		return
+	}
+	log.Info("order fully matched")
+}
@@ -4,8 +4,15 @@ import (
 	"errors"
 	"net/http"
 	"order-book/logger"
+	"order-book/metrics"
 	"order-book/order"
+	"order-book/panics"
+	"order-book/shutdown"
+	"order-book/tenant"
+	"order-book/tracing"
+	"order-book/wslimit"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -17,13 +24,17 @@ var (
 	ErrInvalidData   = errors.New("ErrInvalidData")
 )
 
+// wsLog logs the book streams under the ws component
+var wsLog = logger.Component("ws")
+
 type Response struct {
 	Message string `json:"message"`
 	Data    any    `json:"data"`
 	Error   error
 }
 
-func BindOrderBookRouter(r fiber.Router, book Book) {
+// BindOrderBookRouter serves the book of the tenant resolved for each request
+func BindOrderBookRouter(r fiber.Router, books *Registry) {
 	r.Delete("/order-book/:id", func(c *fiber.Ctx) error {
 		id := c.Params("id")
 		if id == "" {
@@ -42,7 +53,7 @@ func BindOrderBookRouter(r fiber.Router, book Book) {
 			})
 		}
 
-		err = book.CancellOrder(orderId)
+		err = books.Get(tenant.FromCtx(c).ID).CancellOrder(c.UserContext(), orderId)
 		if err == ErrOrderNotFound {
 			c.Status(http.StatusNotFound)
 			return c.JSON(&Response{
@@ -50,6 +61,13 @@ func BindOrderBookRouter(r fiber.Router, book Book) {
 				Data:    nil,
 			})
 		}
+		if err != nil {
+			logger.Error("failed to cancel order", map[string]any{
+				"order_id": orderId,
+				"error":    err,
+			})
+			return err
+		}
 
 		c.Status(http.StatusOK)
 		return c.JSON(&Response{
@@ -59,12 +77,49 @@ func BindOrderBookRouter(r fiber.Router, book Book) {
 	})
 	r.Post("/add-order", func(c *fiber.Ctx) error {
 		var order order.Order
-		if err := c.BodyParser(&order); err != nil {
+		_, span := tracing.Tracer.Start(c.UserContext(), "decode_order")
+		err := c.BodyParser(&order)
+		span.End()
+		if err != nil {
 			return err
 		}
+		if !tenant.Allows(c, order.AccountID) {
+			return tenant.ErrAccountNotInScope
+		}
 		order.CreatedAt = time.Now()
+		book := books.Get(tenant.FromCtx(c).ID)
 
-		book.AddOrder(order)
+		// With ?timings=true the ack waits for the engine and reports where
+		// the time went
+		if c.QueryBool("timings") {
+			timings, err := book.AddOrderWithTimings(c.UserContext(), order)
+			if err != nil && timings.ReceivedAt.IsZero() {
+				c.Status(http.StatusUnprocessableEntity)
+				return c.JSON(&Response{
+					Message: err.Error(),
+					Data:    nil,
+				})
+			}
+			if err != nil {
+				return err
+			}
+			c.Status(http.StatusAccepted)
+			return c.JSON(&Response{
+				Message: "Order Submitted Succesfully",
+				Data: map[string]any{
+					"order_id": timings.OrderID,
+					"timings":  timings.Breakdown(),
+				},
+			})
+		}
+
+		if err := book.AddOrder(c.UserContext(), order); err != nil {
+			c.Status(http.StatusUnprocessableEntity)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
 		resp := &Response{
 			Message: "Order Submitted Succesfully",
 			Data:    nil,
@@ -74,8 +129,107 @@ func BindOrderBookRouter(r fiber.Router, book Book) {
 
 	})
 
-	r.Get("/ws/order-book/:pair_id", websocket.New(func(c *websocket.Conn) {
+	// A quote replaces the previous quote of the account on the pair, the
+	// response lists the orders placed and cancelled
+	r.Post("/quotes", func(c *fiber.Ctx) error {
+		var quote order.Quote
+		if err := c.BodyParser(&quote); err != nil {
+			return err
+		}
+		if !tenant.Allows(c, quote.AccountID) {
+			return tenant.ErrAccountNotInScope
+		}
+		res, err := books.Get(tenant.FromCtx(c).ID).Quote(c.UserContext(), quote)
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusAccepted)
+		return c.JSON(&Response{
+			Message: "Quote Submitted Succesfully",
+			Data:    res,
+		})
+	})
+
+	// A mass quote replaces the quotes of an account on many pairs, each
+	// quote is acked or rejected on its own
+	r.Post("/mass-quotes", func(c *fiber.Ctx) error {
+		var req struct {
+			AccountID int           `json:"account_id"`
+			Quotes    []order.Quote `json:"quotes"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		if !tenant.Allows(c, req.AccountID) {
+			return tenant.ErrAccountNotInScope
+		}
+		acks, err := MassQuote(c.UserContext(), books.Get(tenant.FromCtx(c).ID), req.AccountID, req.Quotes)
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusAccepted)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    acks,
+		})
+	})
+
+	// Pulls the quotes of an account, on the pair of ?pair_id= or on all
+	r.Delete("/quotes", func(c *fiber.Ctx) error {
+		accountId := c.QueryInt("account_id")
+		if accountId == 0 {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "account_id is required",
+				Data:    nil,
+			})
+		}
+		if !tenant.Allows(c, accountId) {
+			return tenant.ErrAccountNotInScope
+		}
+		res, err := books.Get(tenant.FromCtx(c).ID).CancelQuotes(c.UserContext(), accountId, c.Query("pair_id"))
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Quotes cancelled successfully",
+			Data:    res,
+		})
+	})
+
+	// The engine keeps the stats of the book as it changes, serving them
+	// doesn't walk the orders
+	r.Get("/book-stats/:pair_id", func(c *fiber.Ctx) error {
+		pairId := strings.Clone(c.Params("pair_id"))
+		if _, ok := order.GetPair(pairId); !ok && len(order.GetPairs()) > 0 {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: order.ErrUnknownPair.Error(),
+				Data:    nil,
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    books.Get(tenant.FromCtx(c).ID).Stats(pairId),
+		})
+	})
+
+	r.Get("/ws/order-book/:pair_id", func(c *fiber.Ctx) error {
+		// The websocket connection doesn't carry the fiber context
+		c.Locals("book", books.Get(tenant.FromCtx(c).ID))
+		return c.Next()
+	}, websocket.New(func(c *websocket.Conn) {
+		defer panics.Recover("ws", map[string]any{"stream": "order_book"})
+		defer shutdown.TrackWebsocket(c)()
+		defer wslimit.Release(c)
+		book := c.Locals("book").(Book)
+		stream := metrics.Stream("order_book")
+		stream.Connected()
+		reason := metrics.DisconnectClientClosed
 		defer func() {
+			stream.Disconnected(reason)
 			c.Close()
 		}()
 
@@ -105,9 +259,14 @@ func BindOrderBookRouter(r fiber.Router, book Book) {
 			})
 		}
 
+		stream.Subscribed()
+		defer stream.Unsubscribed()
 		ticker := time.NewTicker(time.Second * 1)
 		defer ticker.Stop()
+		closed := make(chan struct{})
 		go func() {
+			defer panics.Recover("ws", map[string]any{"stream": "order_book"})
+			defer close(closed)
 			defer c.Close()
 			for {
 				_, _, err := c.ReadMessage()
@@ -120,9 +279,10 @@ func BindOrderBookRouter(r fiber.Router, book Book) {
 		for t := range ticker.C {
 			err := c.WriteControl(websocket.PingMessage, []byte("Ping message"), time.Now().Add(5*time.Second))
 			if err != nil {
-				logger.Error("Closing ws connection", map[string]any{
+				wsLog.Error("Closing ws connection", map[string]any{
 					"err": err.Error(),
 				})
+				reason = disconnectReason(closed, metrics.DisconnectPingFailed)
 				break
 			}
 			asks, bids := book.GetOrders(pairId, size, offset)
@@ -132,15 +292,17 @@ func BindOrderBookRouter(r fiber.Router, book Book) {
 				"time": t,
 			})
 			if err != nil {
-				logger.Error("Error while sending orders through ws", map[string]any{
+				wsLog.Error("Error while sending orders through ws", map[string]any{
 					"err":     err,
 					"pair_id": pairId,
 					"size":    size,
 					"offset":  offset,
 				})
+				reason = disconnectReason(closed, metrics.DisconnectWriteError)
 				c.Close()
 				return
 			}
+			stream.Sent()
 		}
 
 	}))
@@ -179,3 +341,14 @@ func BindOrderBookRouter(r fiber.Router, book Book) {
 	// })
 
 }
+
+// disconnectReason is reason unless the client already went away, which
+// makes the writes to it fail too
+func disconnectReason(closed chan struct{}, reason string) string {
+	select {
+	case <-closed:
+		return metrics.DisconnectClientClosed
+	default:
+		return reason
+	}
+}
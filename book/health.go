@@ -0,0 +1,64 @@
+package book
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrEngineStopped = errors.New("The engine of a book stopped")
+	ErrEnginePanic   = errors.New("The engine failed to process the order")
+	ErrBookClosed    = errors.New("The book stopped accepting orders")
+)
+
+// EngineHealth is the state of the goroutine matching the orders of a book
+type EngineHealth struct {
+	Running       bool      `json:"running"`
+	QueueDepth    int       `json:"queue_depth"`
+	QueueCapacity int       `json:"queue_capacity"`
+	LastDequeue   time.Time `json:"last_dequeue"`
+	// Persists counts the writes of orders and trades since the start,
+	// PersistFailures those that failed
+	Persists        uint64 `json:"persists"`
+	PersistFailures uint64 `json:"persist_failures"`
+	// Panics counts the orders the engine panicked on and dropped
+	Panics uint64 `json:"panics"`
+}
+
+// Saturated reports whether the queue filled up to ratio of its capacity
+func (h EngineHealth) Saturated(ratio float64) bool {
+	return h.QueueCapacity > 0 && float64(h.QueueDepth) >= ratio*float64(h.QueueCapacity)
+}
+
+// Stalled reports whether orders are waiting while the engine hasn't taken
+// one for longer than timeout, as when it's stuck on the database
+func (h EngineHealth) Stalled(timeout time.Duration) bool {
+	return h.QueueDepth > 0 && !h.LastDequeue.IsZero() && time.Since(h.LastDequeue) > timeout
+}
+
+// Health reports the engine of every tenant book
+func (r *Registry) Health() map[string]EngineHealth {
+	healths := make(map[string]EngineHealth)
+	for tenantId, b := range r.Books() {
+		healths[tenantId] = b.Health()
+	}
+	return healths
+}
+
+// CheckEngines fails while the engine of a book stopped, is stalled or has
+// its queue filled to saturation of its capacity, new orders would wait
+func (r *Registry) CheckEngines(saturation float64, stallTimeout time.Duration) error {
+	var errs []error
+	for tenantId, h := range r.Health() {
+		switch {
+		case !h.Running:
+			errs = append(errs, fmt.Errorf("tenant %s: %w", tenantId, ErrEngineStopped))
+		case h.Stalled(stallTimeout):
+			errs = append(errs, fmt.Errorf("tenant %s: engine took no order for %s with %d queued", tenantId, time.Since(h.LastDequeue).Round(time.Millisecond), h.QueueDepth))
+		case h.Saturated(saturation):
+			errs = append(errs, fmt.Errorf("tenant %s: queue saturated, %d of %d", tenantId, h.QueueDepth, h.QueueCapacity))
+		}
+	}
+	return errors.Join(errs...)
+}
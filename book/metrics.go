@@ -0,0 +1,43 @@
+package book
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	queueDepthDesc = prometheus.NewDesc(
+		"orderbook_queue_depth",
+		"Accepted orders waiting for the engine of a tenant book.",
+		[]string{"tenant"}, nil,
+	)
+	priceLevelsDesc = prometheus.NewDesc(
+		"orderbook_price_levels",
+		"Distinct prices resting on a side of a pair.",
+		[]string{"tenant", "pair", "side"}, nil,
+	)
+)
+
+// registryCollector reads the queue depth and price levels of every book
+// when scraped rather than tracking them on the engine goroutine
+type registryCollector struct {
+	registry *Registry
+}
+
+func (rc registryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+	ch <- priceLevelsDesc
+}
+
+func (rc registryCollector) Collect(ch chan<- prometheus.Metric) {
+	for tenantId, b := range rc.registry.Books() {
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(b.QueueDepth()), tenantId)
+		for _, pairId := range b.Pairs() {
+			size := b.Size(pairId)
+			ch <- prometheus.MustNewConstMetric(priceLevelsDesc, prometheus.GaugeValue, float64(size.AskLevels), tenantId, pairId, "ask")
+			ch <- prometheus.MustNewConstMetric(priceLevelsDesc, prometheus.GaugeValue, float64(size.BidLevels), tenantId, pairId, "bid")
+		}
+	}
+}
+
+// Collector exposes the books of the registry to Prometheus
+func (r *Registry) Collector() prometheus.Collector {
+	return registryCollector{registry: r}
+}
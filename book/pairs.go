@@ -0,0 +1,123 @@
+package book
+
+import (
+	"errors"
+	"maps"
+	"order-book/fee"
+	"order-book/order"
+)
+
+var (
+	ErrPairNotOpen  = errors.New("The engine has no book for the pair")
+	ErrPairNotEmpty = errors.New("Orders are resting on the pair, cancel them first")
+)
+
+// OpenPair creates the trees of a pair. From the first pair opened the book
+// only takes orders for the pairs it opened, before that any pair gets its
+// trees on its first order.
+func (b *BookImpl) OpenPair(pairId string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.open == nil {
+		b.open = make(map[string]bool)
+	}
+	b.open[pairId] = true
+	if b.askTreesMap[pairId] == nil {
+		b.genTreeFor(pairId, order.ASK)
+	}
+	if b.bidTreesMap[pairId] == nil {
+		b.genTreeFor(pairId, order.BID)
+	}
+}
+
+// ClosePair drops the trees of a pair, orders for it are refused from then
+// on. It fails while orders rest on the pair.
+func (b *BookImpl) ClosePair(pairId string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, asks := treeSize(b.askTreesMap[pairId])
+	_, bids := treeSize(b.bidTreesMap[pairId])
+	if asks+bids > 0 {
+		return ErrPairNotEmpty
+	}
+	if b.open == nil {
+		b.open = make(map[string]bool)
+	}
+	delete(b.open, pairId)
+	delete(b.askTreesMap, pairId)
+	delete(b.bidTreesMap, pairId)
+	delete(b.stats, pairId)
+	return nil
+}
+
+// isOpen reports whether the book takes orders for a pair, callers hold b.mu
+func (b *BookImpl) isOpen(pairId string) bool {
+	return b.open == nil || b.open[pairId]
+}
+
+// OpenPair opens a pair on every tenant book and on those created from then
+// on. Fees set for the pair override the schedule, until it's closed.
+func (r *Registry) OpenPair(p order.Pair) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.open[p.ID] = true
+	delete(r.pairFees, p.ID)
+	if p.MakerFee != nil || p.TakerFee != nil {
+		rates := r.fees.For(p.ID)
+		if p.MakerFee != nil {
+			rates.Maker = *p.MakerFee
+		}
+		if p.TakerFee != nil {
+			rates.Taker = *p.TakerFee
+		}
+		r.pairFees[p.ID] = rates
+	}
+	fees := r.schedule()
+	for _, b := range r.books {
+		b.OpenPair(p.ID)
+		b.SetFees(fees)
+	}
+}
+
+// ClosePair closes a pair on every tenant book. Nothing is closed while
+// orders rest on the pair in any of them.
+func (r *Registry) ClosePair(pairId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range r.books {
+		if size := b.Size(pairId); size.AskOrders+size.BidOrders > 0 {
+			return ErrPairNotEmpty
+		}
+	}
+	for _, b := range r.books {
+		// The engine may have rested an order since the sizes were read,
+		// the pair is then opened again on the books already closed
+		if err := b.ClosePair(pairId); err != nil {
+			for _, reopened := range r.books {
+				reopened.OpenPair(pairId)
+			}
+			return err
+		}
+	}
+	delete(r.open, pairId)
+	delete(r.pairFees, pairId)
+	fees := r.schedule()
+	for _, b := range r.books {
+		b.SetFees(fees)
+	}
+	return nil
+}
+
+// schedule is the fee schedule with the fees of the open pairs, callers
+// hold r.mu
+func (r *Registry) schedule() fee.Schedule {
+	if len(r.pairFees) == 0 {
+		return r.fees
+	}
+	s := fee.Schedule{Default: r.fees.Default, Pairs: maps.Clone(r.fees.Pairs), Tiers: r.fees.Tiers}
+	if s.Pairs == nil {
+		s.Pairs = make(map[string]fee.Rates)
+	}
+	maps.Copy(s.Pairs, r.pairFees)
+	return s
+}
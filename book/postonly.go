@@ -0,0 +1,54 @@
+package book
+
+import (
+	"errors"
+	"order-book/order"
+)
+
+var (
+	ErrPostOnlyDisabled   = errors.New("Post-only orders aren't enabled for this pair")
+	ErrPostOnlyWouldMatch = errors.New("The post-only order would match on arrival")
+)
+
+// PostOnlyValidator refuses post-only orders unless enabled allows them, and
+// those that would match the book. The engine checks them again when it
+// takes them, the book may have changed in between.
+func PostOnlyValidator(b Book, enabled func(o order.Order) bool) OrderValidator {
+	return func(o order.Order) error {
+		if !o.PostOnly {
+			return nil
+		}
+		if !enabled(o) {
+			return ErrPostOnlyDisabled
+		}
+		if b.WouldMatch(o) {
+			return ErrPostOnlyWouldMatch
+		}
+		return nil
+	}
+}
+
+// WouldMatch reports whether an order would fill against a resting order of
+// another account, out of its STP group, at its price, as matchOrder fills it
+func (b *BookImpl) WouldMatch(o order.Order) bool {
+	opposite := order.ASK
+	if o.Type == order.ASK {
+		opposite = order.BID
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	tree := b.getTreeFor(o.PairID, opposite)
+	if tree == nil {
+		return false
+	}
+	node := tree.GetNode(o.Price)
+	if node == nil {
+		return false
+	}
+	for _, resting := range node.Value.(*order.OrderList).List {
+		if !b.sameOwner(resting.AccountID, o.AccountID) {
+			return true
+		}
+	}
+	return false
+}
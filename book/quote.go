@@ -0,0 +1,286 @@
+package book
+
+import (
+	"context"
+	"errors"
+	"order-book/metrics"
+	"order-book/order"
+	"order-book/panics"
+	"sync"
+	"time"
+)
+
+// MAX_MASS_QUOTE_ENTRIES bounds the quotes of a mass quote
+const MAX_MASS_QUOTE_ENTRIES = 100
+
+var (
+	ErrTooManyQuotes   = errors.New("A mass quote takes at most 100 quotes")
+	ErrDuplicatedQuote = errors.New("A mass quote takes a single quote per pair")
+)
+
+// Statuses of the quotes of a mass quote
+const (
+	QUOTE_ACK      = "ACK"
+	QUOTE_REJECTED = "REJECTED"
+)
+
+// quoteKey is the account and pair a quote is for
+type quoteKey struct {
+	accountId int
+	pairId    string
+}
+
+// QuoteResult is what became of a quote: the orders it placed, the bid
+// first, and those it cancelled, of the previous quote or of a side it
+// pulled when the other failed
+type QuoteResult struct {
+	OrderIDs     []int `json:"order_ids"`
+	CancelledIDs []int `json:"cancelled_order_ids"`
+	err          error
+}
+
+// QuoteAck is the result of a quote of a mass quote, Reason is why it was
+// rejected
+type QuoteAck struct {
+	PairID string `json:"pair_id"`
+	Status string `json:"status"`
+	QuoteResult
+	Reason string `json:"reason,omitempty"`
+}
+
+// Quote replaces the quote of an account on a pair and waits for the engine.
+// The engine takes the orders of the previous quote off the book before the
+// new ones can match, with no other order in between, so a market maker
+// moving its quote never trades against its previous one. Each side is
+// validated as an order before the quote is queued.
+func (b *BookImpl) Quote(ctx context.Context, q order.Quote) (QuoteResult, error) {
+	if err := q.Validate(); err != nil {
+		return QuoteResult{}, err
+	}
+	receivedAt := time.Now()
+	for _, o := range q.Orders() {
+		metrics.OrdersReceived.WithLabelValues(o.PairID).Inc()
+		if err := b.validate(ctx, o); err != nil {
+			return QuoteResult{}, err
+		}
+	}
+
+	done := make(chan QuoteResult, 1)
+	b.closing.RLock()
+	if b.closed {
+		b.closing.RUnlock()
+		return QuoteResult{}, ErrBookClosed
+	}
+	select {
+	case b.orderProcessingChannel <- queuedOrder{
+		ctx:       context.WithoutCancel(ctx),
+		timings:   StageTimings{ReceivedAt: receivedAt},
+		quote:     &q,
+		quoteDone: done,
+	}:
+		b.closing.RUnlock()
+	case <-ctx.Done():
+		b.closing.RUnlock()
+		return QuoteResult{}, ctx.Err()
+	}
+
+	select {
+	case res := <-done:
+		return res, res.err
+	case <-ctx.Done():
+		return QuoteResult{}, ctx.Err()
+	}
+}
+
+// processQuote cancels the previous quote of the account on the pair, then
+// runs the sides of the new one through the engine as orders. The quote is
+// placed whole or not at all: both sides are checked and persisted before
+// the previous quote is pulled, so a refused quote leaves it resting, and a
+// side placed before the other fails is pulled again.
+func (b *BookImpl) processQuote(q queuedOrder) {
+	res := QuoteResult{OrderIDs: []int{}, CancelledIDs: []int{}}
+	defer func() { q.quoteDone <- res }()
+	defer b.recoverQuote(&res, map[string]any{
+		"pair_id":    q.quote.PairID,
+		"account_id": q.quote.AccountID,
+	})
+
+	sides := q.quote.Orders()
+	for _, o := range sides {
+		if err := b.checkQuoteSide(o); err != nil {
+			res.err = err
+			metrics.OrdersRejected.WithLabelValues(o.PairID).Inc()
+			b.recordRejection(q.ctx, o, err)
+			return
+		}
+	}
+	persisted := make([]order.Order, 0, len(sides))
+	for _, o := range sides {
+		o.CreatedAt = q.timings.ReceivedAt
+		created, err := b.persistOrder(q.ctx, o)
+		if err != nil {
+			res.err = err
+			// The sides persisted already never reach the book
+			for _, o := range persisted {
+				b.recordCancelled(q.ctx, o)
+			}
+			return
+		}
+		persisted = append(persisted, created)
+	}
+
+	key := quoteKey{accountId: q.quote.AccountID, pairId: q.quote.PairID}
+	for _, o := range b.quotes[key] {
+		if removed, ok := b.cancelResting(q.ctx, o); ok {
+			res.CancelledIDs = append(res.CancelledIDs, removed.ID)
+		}
+	}
+	delete(b.quotes, key)
+
+	var placed []order.Order
+	for idx, o := range persisted {
+		done := make(chan StageTimings, 1)
+		b.process(queuedOrder{
+			order:     o,
+			ctx:       q.ctx,
+			timings:   StageTimings{ReceivedAt: q.timings.ReceivedAt},
+			done:      done,
+			persisted: true,
+		})
+		timings := <-done
+		if timings.Err != nil {
+			res.err = timings.Err
+			// What rests of the sides placed is pulled, what they filled
+			// already stands, and the sides left never reach the book
+			for _, o := range placed {
+				if removed, ok := b.cancelResting(q.ctx, o); ok {
+					res.CancelledIDs = append(res.CancelledIDs, removed.ID)
+				}
+			}
+			for _, o := range persisted[idx+1:] {
+				b.recordCancelled(q.ctx, o)
+			}
+			res.OrderIDs = []int{}
+			return
+		}
+		placed = append(placed, o)
+		res.OrderIDs = append(res.OrderIDs, o.ID)
+	}
+	if len(placed) > 0 {
+		b.quotes[key] = placed
+	}
+}
+
+// recoverQuote fails a quote or its cancellation rather than the engine when
+// it panics, as process does for an order. It's deferred by the engine.
+func (b *BookImpl) recoverQuote(res *QuoteResult, fields map[string]any) {
+	if recovered := recover(); recovered != nil {
+		b.panics.Add(1)
+		res.OrderIDs = []int{}
+		res.err = ErrEnginePanic
+		panics.Capture("engine", recovered, fields)
+	}
+}
+
+// checkQuoteSide runs the checks the engine makes of an order before placing
+// it on a side of a quote. The previous quote of the account is of the same
+// owner, so pulling it first wouldn't change the outcome.
+func (b *BookImpl) checkQuoteSide(o order.Order) error {
+	b.mu.RLock()
+	open := b.isOpen(o.PairID)
+	b.mu.RUnlock()
+	if !open {
+		return ErrPairNotOpen
+	}
+	if o.PostOnly && b.WouldMatch(o) {
+		return ErrPostOnlyWouldMatch
+	}
+	return nil
+}
+
+// MassQuote replaces the quotes of an account on many pairs. Each quote is
+// processed on its own, atomically for its pair as Quote does, and acked or
+// rejected in the order they came. A rejected quote leaves the others as they
+// are.
+func MassQuote(ctx context.Context, b Book, accountId int, quotes []order.Quote) ([]QuoteAck, error) {
+	if len(quotes) > MAX_MASS_QUOTE_ENTRIES {
+		return nil, ErrTooManyQuotes
+	}
+	seen := make(map[string]bool, len(quotes))
+	for _, q := range quotes {
+		if seen[q.PairID] {
+			return nil, ErrDuplicatedQuote
+		}
+		seen[q.PairID] = true
+	}
+
+	acks := make([]QuoteAck, len(quotes))
+	var wg sync.WaitGroup
+	for idx, q := range quotes {
+		q.AccountID = accountId
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := b.Quote(ctx, q)
+			acks[idx] = QuoteAck{PairID: q.PairID, Status: QUOTE_ACK, QuoteResult: res}
+			if err != nil {
+				acks[idx].Status = QUOTE_REJECTED
+				acks[idx].Reason = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+	return acks, nil
+}
+
+// CancelQuotes pulls the quotes of an account on a pair, on every pair when
+// pairId is empty, and waits for the engine
+func (b *BookImpl) CancelQuotes(ctx context.Context, accountId int, pairId string) (QuoteResult, error) {
+	done := make(chan QuoteResult, 1)
+	b.closing.RLock()
+	if b.closed {
+		b.closing.RUnlock()
+		return QuoteResult{}, ErrBookClosed
+	}
+	select {
+	case b.orderProcessingChannel <- queuedOrder{
+		ctx:          context.WithoutCancel(ctx),
+		timings:      StageTimings{ReceivedAt: time.Now()},
+		cancelQuotes: &quoteKey{accountId: accountId, pairId: pairId},
+		quoteDone:    done,
+	}:
+		b.closing.RUnlock()
+	case <-ctx.Done():
+		b.closing.RUnlock()
+		return QuoteResult{}, ctx.Err()
+	}
+
+	select {
+	case res := <-done:
+		return res, res.err
+	case <-ctx.Done():
+		return QuoteResult{}, ctx.Err()
+	}
+}
+
+// processCancelQuotes cancels the quotes a cancelQuotes request covers
+func (b *BookImpl) processCancelQuotes(q queuedOrder) {
+	res := QuoteResult{OrderIDs: []int{}, CancelledIDs: []int{}}
+	defer func() { q.quoteDone <- res }()
+	defer b.recoverQuote(&res, map[string]any{
+		"pair_id":    q.cancelQuotes.pairId,
+		"account_id": q.cancelQuotes.accountId,
+	})
+
+	for key, orders := range b.quotes {
+		if key.accountId != q.cancelQuotes.accountId || (q.cancelQuotes.pairId != "" && key.pairId != q.cancelQuotes.pairId) {
+			continue
+		}
+		for _, o := range orders {
+			if removed, ok := b.cancelResting(q.ctx, o); ok {
+				res.CancelledIDs = append(res.CancelledIDs, removed.ID)
+			}
+		}
+		delete(b.quotes, key)
+	}
+}
@@ -0,0 +1,133 @@
+package book
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-book/fee"
+	"order-book/order"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+// Registry holds one isolated book per tenant. Books are created on first use
+// and set up by the hooks registered with OnBook.
+type Registry struct {
+	mu        sync.Mutex
+	books     map[string]Book
+	orderRepo order.OrderRepo
+	tradeRepo order.TradeRepo
+	tenantOf  func(accountId int) string
+	hooks     []func(tenantId string, b Book)
+	queueSize int
+	fees      fee.Schedule
+	// open are the pairs opened with OpenPair, pairFees the fees they set
+	open     map[string]bool
+	pairFees map[string]fee.Rates
+	// auctions are the pairs in auction, see StartAuction
+	auctions map[string]bool
+}
+
+func NewRegistry(orderRepo order.OrderRepo, tradeRepo order.TradeRepo, tenantOf func(accountId int) string, queueSize int, fees fee.Schedule) *Registry {
+	return &Registry{
+		books:     make(map[string]Book),
+		orderRepo: orderRepo,
+		tradeRepo: tradeRepo,
+		tenantOf:  tenantOf,
+		queueSize: queueSize,
+		fees:      fees,
+		open:      make(map[string]bool),
+		pairFees:  make(map[string]fee.Rates),
+		auctions:  make(map[string]bool),
+	}
+}
+
+// OnBook registers a hook run for every tenant book, including the existing ones
+func (r *Registry) OnBook(fn func(tenantId string, b Book)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, fn)
+	for tenantId, b := range r.books {
+		fn(tenantId, b)
+	}
+}
+
+func (r *Registry) Get(tenantId string) Book {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if b, ok := r.books[tenantId]; ok {
+		return b
+	}
+	b := NewBook(r.orderRepo, r.tradeRepo, r.queueSize, r.schedule())
+	for pairId := range r.open {
+		b.OpenPair(pairId)
+	}
+	for pairId := range r.auctions {
+		b.StartAuction(pairId)
+	}
+	for _, fn := range r.hooks {
+		fn(tenantId, b)
+	}
+	r.books[tenantId] = b
+	return b
+}
+
+// SetFees replaces the fee schedule of every book, and of those created
+// from then on. The fees of the pairs opened with OpenPair still apply.
+func (r *Registry) SetFees(fees fee.Schedule) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fees = fees
+	for _, b := range r.books {
+		b.SetFees(r.schedule())
+	}
+}
+
+// Books copies the tenant books created so far
+func (r *Registry) Books() map[string]Book {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	books := make(map[string]Book, len(r.books))
+	for tenantId, b := range r.books {
+		books[tenantId] = b
+	}
+	return books
+}
+
+// Drain drains every tenant book concurrently, see Book.Drain
+func (r *Registry) Drain(ctx context.Context) error {
+	books := r.Books()
+	errs := make(chan error, len(books))
+	for tenantId, b := range books {
+		go func() {
+			if err := b.Drain(ctx); err != nil {
+				errs <- fmt.Errorf("tenant %s: %w", tenantId, err)
+				return
+			}
+			errs <- nil
+		}()
+	}
+	var failed []error
+	for range books {
+		if err := <-errs; err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// ForAccount returns the book of the tenant owning the account
+func (r *Registry) ForAccount(accountId int) Book {
+	return r.Get(r.tenantOf(accountId))
+}
+
+// AccountLastPrice is the last price of a pair on the book the account trades
+// on, as the mark price positions and portfolios are valued at
+func (r *Registry) AccountLastPrice(accountId int, pairId string) (decimal.Decimal, bool) {
+	return r.ForAccount(accountId).LastPrice(pairId)
+}
+
+func (r *Registry) GetAccountOrders(accountId int) []order.Order {
+	return r.ForAccount(accountId).GetAccountOrders(accountId)
+}
@@ -0,0 +1,129 @@
+package book
+
+import (
+	"order-book/order"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Change is what an engine event did to the resting orders of a pair.
+// Applied in Seq order on top of a snapshot of a lower Seq, the changes
+// rebuild the book without matching again.
+type Change struct {
+	Seq    int64  `json:"seq"`
+	PairID string `json:"pair_id"`
+	// Rested is the remainder of an incoming order left resting on the book
+	Rested *order.Order `json:"rested,omitempty"`
+	// Filled are the resting orders a fill reduced, with the amount left of
+	// them, zero for those that left the book
+	Filled []order.Order `json:"filled,omitempty"`
+	// Cancelled is the order a cancellation removed
+	Cancelled *order.Order    `json:"cancelled,omitempty"`
+	LastPrice decimal.Decimal `json:"last_price,omitzero"`
+	At        time.Time       `json:"at"`
+}
+
+// OnChange registers a listener for the changes of the resting orders. It is
+// called with the book locked so the changes line up with Snapshot, it must
+// neither block nor call the book.
+func (b *BookImpl) OnChange(fn func(ch Change)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.changeListeners = append(b.changeListeners, fn)
+}
+
+// emitChange numbers a change with the current seq, callers hold b.mu
+func (b *BookImpl) emitChange(ch Change) {
+	ch.Seq = b.seq
+	ch.At = b.now()
+	for _, fn := range b.changeListeners {
+		fn(ch)
+	}
+}
+
+// matchChange is the change of an order matched against matchResults, with
+// amountLeft of it resting
+func matchChange(o order.Order, matchResults []MatchResult, amountLeft decimal.Decimal) Change {
+	ch := Change{PairID: o.PairID}
+	if amountLeft.IsPositive() {
+		resting := o
+		resting.Amount = amountLeft
+		resting.Version += len(matchResults)
+		ch.Rested = &resting
+	}
+	for _, matchResult := range matchResults {
+		filled := matchResult.targetOrder
+		filled.Amount = matchResult.remaining
+		ch.Filled = append(ch.Filled, filled)
+	}
+	if len(matchResults) > 0 {
+		ch.LastPrice = o.Price
+	}
+	return ch
+}
+
+// Restore replaces the resting orders of a pair with those of a snapshot,
+// and moves Seq up to it. It's meant for books following another node,
+// nothing is persisted or published.
+func (b *BookImpl) Restore(snap order.BookSnapshot) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.askTreesMap, snap.PairID)
+	delete(b.bidTreesMap, snap.PairID)
+	delete(b.stats, snap.PairID)
+	b.genTreeFor(snap.PairID, order.ASK)
+	b.genTreeFor(snap.PairID, order.BID)
+	for _, o := range snap.Orders() {
+		b.insertOrder(o)
+	}
+	b.seq = max(b.seq, snap.Seq)
+}
+
+// ApplyChange applies a change made by the engine of another node, like
+// Restore it persists and publishes nothing
+func (b *BookImpl) ApplyChange(ch Change) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, filled := range ch.Filled {
+		b.replaceOrder(filled)
+	}
+	if ch.Cancelled != nil {
+		cancelled := *ch.Cancelled
+		cancelled.Amount = decimal.Zero
+		b.replaceOrder(cancelled)
+	}
+	if ch.Rested != nil {
+		b.insertOrder(*ch.Rested)
+	}
+	if !ch.LastPrice.IsZero() {
+		b.lastPrices[ch.PairID] = ch.LastPrice
+		b.lastTrades[ch.PairID] = ch.At
+	}
+	b.seq = max(b.seq, ch.Seq)
+}
+
+// replaceOrder sets the amount and version of a resting order, removing it
+// for a zero amount, callers hold b.mu
+func (b *BookImpl) replaceOrder(o order.Order) {
+	tree := b.getTreeFor(o.PairID, o.Type)
+	if tree == nil {
+		return
+	}
+	node := tree.GetNode(o.Price)
+	if node == nil {
+		return
+	}
+	if !o.Amount.IsPositive() {
+		b.removeOrder(tree, node, o.ID)
+		return
+	}
+	for idx, resting := range node.Value.(*order.OrderList).List {
+		if resting.ID == o.ID {
+			b.adjust(resting, node.Value.(*order.OrderList), o.Amount.Sub(resting.Amount), 0)
+			node.Value.(*order.OrderList).List[idx].Amount = o.Amount
+			node.Value.(*order.OrderList).List[idx].Version = o.Version
+			return
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package book
+
+import (
+	"context"
+	"fmt"
+	"order-book/fee"
+	"order-book/logger"
+	"order-book/order"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+// A scenario is a file of testdata/scenarios, YAML or JSON: the book it
+// starts from, the orders and cancels sent to the engine one after the
+// other, and the trades and book they should leave. Orders are referred to
+// by ref, they're given IDs and creation times in the order of the file, so
+// time priority follows it.
+//
+//	book:
+//	  - {ref: s1, account: 1, side: sell, price: 100, amount: 1}
+//	steps:
+//	  - {ref: b1, account: 2, side: buy, price: 100, amount: 0.4}
+//	  - {cancel: s1}
+//	expect:
+//	  trades:
+//	    - {maker: s1, taker: b1, price: 100, amount: 0.4}
+//	  asks: []
+//	  bids: []
+type scenario struct {
+	Description string          `yaml:"description"`
+	Pair        string          `yaml:"pair"`
+	Fees        fee.Rates       `yaml:"fees"`
+	Book        []scenarioOrder `yaml:"book"`
+	// Auction sends the steps during an auction of the pair, uncrossed
+	// after the last one
+	Auction bool           `yaml:"auction"`
+	Steps   []scenarioStep `yaml:"steps"`
+	Expect  struct {
+		Trades []scenarioTrade `yaml:"trades"`
+		// Asks and Bids are the resting orders, the best price first
+		Asks []scenarioOrder `yaml:"asks"`
+		Bids []scenarioOrder `yaml:"bids"`
+	} `yaml:"expect"`
+}
+
+type scenarioOrder struct {
+	Ref      string          `yaml:"ref"`
+	Account  int             `yaml:"account"`
+	Side     string          `yaml:"side"`
+	Price    decimal.Decimal `yaml:"price"`
+	Amount   decimal.Decimal `yaml:"amount"`
+	PostOnly bool            `yaml:"post_only"`
+}
+
+// scenarioStep is an order, or the cancel of the order with the ref of
+// Cancel. Reject is the error the step should fail with.
+type scenarioStep struct {
+	scenarioOrder `yaml:",inline"`
+	Cancel        string `yaml:"cancel"`
+	Reject        string `yaml:"reject"`
+}
+
+// scenarioTrade leaves out the fees it doesn't set
+type scenarioTrade struct {
+	Maker       string           `yaml:"maker"`
+	Taker       string           `yaml:"taker"`
+	Price       decimal.Decimal  `yaml:"price"`
+	Amount      decimal.Decimal  `yaml:"amount"`
+	MakerFee    *decimal.Decimal `yaml:"maker_fee"`
+	TakerFee    *decimal.Decimal `yaml:"taker_fee"`
+	MakerRebate *decimal.Decimal `yaml:"maker_rebate"`
+}
+
+func TestScenarios(t *testing.T) {
+	logger.SetLevel(logger.ErrorLevel)
+	files, err := filepath.Glob("testdata/scenarios/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no scenario in testdata/scenarios")
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sc scenario
+			if err := yaml.Unmarshal(data, &sc); err != nil {
+				t.Fatalf("%s: %s", file, err)
+			}
+			runScenario(t, sc)
+		})
+	}
+}
+
+func runScenario(t *testing.T, sc scenario) {
+	if sc.Pair == "" {
+		sc.Pair = "btcusdt"
+	}
+	orders := newScenarioOrderRepo()
+	trades := &scenarioTradeRepo{}
+	b := NewBook(orders, trades, 16, fee.Schedule{Default: sc.Fees})
+	t.Cleanup(func() { b.Drain(context.Background()) })
+	b.AddValidator(PostOnlyValidator(b, func(o order.Order) bool { return true }))
+
+	// The refs of the orders by ID and the other way around
+	ids := make(map[string]int)
+	refs := make(map[int]string)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(idx int) time.Time { return start.Add(time.Duration(idx) * time.Second) }
+
+	snap := order.BookSnapshot{PairID: sc.Pair}
+	for idx, so := range sc.Book {
+		o := scenarioToOrder(t, sc.Pair, so)
+		o.CreatedAt = at(idx)
+		o = orders.add(o)
+		ids[so.Ref], refs[o.ID] = o.ID, so.Ref
+		level := order.PriceLevel{Price: o.Price, Orders: []order.SnapshotOrder{{
+			ID:        o.ID,
+			AccountID: o.AccountID,
+			Amount:    o.Amount,
+			CreatedAt: o.CreatedAt,
+		}}}
+		if o.Type == order.ASK {
+			snap.Asks = append(snap.Asks, level)
+		} else {
+			snap.Bids = append(snap.Bids, level)
+		}
+	}
+	b.Restore(snap)
+
+	ctx := context.Background()
+	if sc.Auction {
+		b.StartAuction(sc.Pair)
+	}
+	for idx, step := range sc.Steps {
+		var err error
+		if step.Cancel != "" {
+			id, ok := ids[step.Cancel]
+			if !ok {
+				t.Fatalf("step %d: cancel of %q, an order that isn't in the scenario", idx, step.Cancel)
+			}
+			err = b.CancellOrder(ctx, id)
+		} else {
+			o := scenarioToOrder(t, sc.Pair, step.scenarioOrder)
+			o.CreatedAt = at(len(sc.Book) + idx)
+			var timings StageTimings
+			timings, err = b.AddOrderWithTimings(ctx, o)
+			if err == nil {
+				ids[step.Ref], refs[timings.OrderID] = timings.OrderID, step.Ref
+			}
+		}
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != step.Reject {
+			t.Fatalf("step %d: got error %q, want %q", idx, got, step.Reject)
+		}
+	}
+	if sc.Auction {
+		b.EndAuction(ctx, sc.Pair)
+	}
+
+	var gotTrades []scenarioTrade
+	for _, tr := range trades.all() {
+		gotTrades = append(gotTrades, scenarioTrade{
+			Maker:       refs[tr.MakerOrderID],
+			Taker:       refs[tr.TakerOrderID],
+			Price:       tr.Price,
+			Amount:      tr.Amount,
+			MakerFee:    &tr.MakerFee,
+			TakerFee:    &tr.TakerFee,
+			MakerRebate: &tr.MakerRebate,
+		})
+	}
+	if len(gotTrades) != len(sc.Expect.Trades) {
+		t.Fatalf("got %d trades, want %d:\n%s", len(gotTrades), len(sc.Expect.Trades), describeTrades(gotTrades))
+	}
+	for idx, want := range sc.Expect.Trades {
+		got := gotTrades[idx]
+		if got.Maker != want.Maker || got.Taker != want.Taker || !got.Price.Equal(want.Price) || !got.Amount.Equal(want.Amount) ||
+			(want.MakerFee != nil && !got.MakerFee.Equal(*want.MakerFee)) ||
+			(want.TakerFee != nil && !got.TakerFee.Equal(*want.TakerFee)) ||
+			(want.MakerRebate != nil && !got.MakerRebate.Equal(*want.MakerRebate)) {
+			t.Fatalf("trade %d doesn't match, got:\n%s", idx, describeTrades(gotTrades))
+		}
+	}
+
+	resting := b.Snapshot(sc.Pair)
+	// The bids are kept from the lowest price like the asks
+	slices.Reverse(resting.Bids)
+	compareSide(t, "asks", refs, resting.Asks, sc.Expect.Asks)
+	compareSide(t, "bids", refs, resting.Bids, sc.Expect.Bids)
+
+	// The stats kept along the way agree with the resting orders
+	stats := b.Stats(sc.Pair)
+	compareStats(t, "asks", stats.Asks, resting.Asks)
+	compareStats(t, "bids", stats.Bids, resting.Bids)
+}
+
+func scenarioToOrder(t *testing.T, pairId string, so scenarioOrder) order.Order {
+	o := order.Order{
+		PairID:    pairId,
+		AccountID: so.Account,
+		Price:     so.Price,
+		Amount:    so.Amount,
+		PostOnly:  so.PostOnly,
+	}
+	switch so.Side {
+	case "buy":
+		o.Type = order.BID
+	case "sell":
+		o.Type = order.ASK
+	default:
+		t.Fatalf("order %q: side should be buy or sell", so.Ref)
+	}
+	return o
+}
+
+func compareSide(t *testing.T, side string, refs map[int]string, levels []order.PriceLevel, want []scenarioOrder) {
+	var got []string
+	for _, level := range levels {
+		for _, o := range level.Orders {
+			got = append(got, fmt.Sprintf("%s %s@%s", refs[o.ID], o.Amount, level.Price))
+		}
+	}
+	var expected []string
+	for _, so := range want {
+		expected = append(expected, fmt.Sprintf("%s %s@%s", so.Ref, so.Amount, so.Price))
+	}
+	if !slices.Equal(got, expected) {
+		t.Fatalf("%s: got %v, want %v", side, got, expected)
+	}
+}
+
+func compareStats(t *testing.T, side string, got SideStats, levels []order.PriceLevel) {
+	var orders int
+	quantity, largest := decimal.Zero, decimal.Zero
+	for _, level := range levels {
+		total := decimal.Zero
+		for _, o := range level.Orders {
+			total = total.Add(o.Amount)
+		}
+		orders += len(level.Orders)
+		quantity = quantity.Add(total)
+		largest = decimal.Max(largest, total)
+	}
+	gotLargest := decimal.Zero
+	if got.LargestLevel != nil {
+		gotLargest = got.LargestLevel.Quantity
+	}
+	if got.Orders != orders || !got.Quantity.Equal(quantity) || got.Levels != len(levels) || !gotLargest.Equal(largest) {
+		t.Fatalf("%s stats: got %d orders, %s on %d levels, largest %s, want %d orders, %s on %d levels, largest %s",
+			side, got.Orders, got.Quantity, got.Levels, gotLargest, orders, quantity, len(levels), largest)
+	}
+}
+
+func describeTrades(trades []scenarioTrade) string {
+	var sb strings.Builder
+	for _, tr := range trades {
+		fmt.Fprintf(&sb, "  maker %s taker %s %s@%s fees %s/%s rebate %s\n", tr.Maker, tr.Taker, tr.Amount, tr.Price, tr.MakerFee, tr.TakerFee, tr.MakerRebate)
+	}
+	return sb.String()
+}
+
+// scenarioOrderRepo keeps the orders in memory, the history is dropped
+type scenarioOrderRepo struct {
+	order.OrderRepo
+	mu     sync.Mutex
+	orders map[int]order.Order
+}
+
+func newScenarioOrderRepo() *scenarioOrderRepo {
+	return &scenarioOrderRepo{orders: make(map[int]order.Order)}
+}
+
+func (repo *scenarioOrderRepo) add(o order.Order) order.Order {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	o.ID = len(repo.orders) + 1
+	repo.orders[o.ID] = o
+	return o
+}
+
+func (repo *scenarioOrderRepo) CreateOrder(ctx context.Context, pairID string, price decimal.Decimal, amount decimal.Decimal, accountID int, orderType order.OrderType, tag string, strategyId string) (order.Order, error) {
+	return repo.add(order.Order{PairID: pairID, Price: price, Amount: amount, AccountID: accountID, Type: orderType, Tag: tag, StrategyID: strategyId}), nil
+}
+
+func (repo *scenarioOrderRepo) GetOrderByID(ctx context.Context, id int) (order.Order, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	o, ok := repo.orders[id]
+	if !ok {
+		return o, order.ErrOrderNotFound
+	}
+	return o, nil
+}
+
+func (repo *scenarioOrderRepo) AddEvent(ctx context.Context, ev order.OrderHistoryEvent) error {
+	return nil
+}
+
+func (repo *scenarioOrderRepo) AddEvents(ctx context.Context, evs []order.OrderHistoryEvent) error {
+	return nil
+}
+
+type scenarioTradeRepo struct {
+	order.TradeRepo
+	mu     sync.Mutex
+	trades []order.Trade
+}
+
+func (repo *scenarioTradeRepo) AddTrades(ctx context.Context, trades []order.Trade) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.trades = append(repo.trades, trades...)
+	return nil
+}
+
+func (repo *scenarioTradeRepo) all() []order.Trade {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return slices.Clone(repo.trades)
+}
@@ -0,0 +1,118 @@
+package book
+
+import (
+	"context"
+	"errors"
+	"order-book/order"
+	"sync/atomic"
+	"time"
+)
+
+// Snapshotter persists the resting book of every pair every interval, or
+// sooner once eventCount engine events were applied since the last snapshot.
+// Recovery then starts from the latest snapshot and only replays the events
+// recorded after it. A zero interval or eventCount disables that trigger.
+type Snapshotter struct {
+	repo       order.SnapshotRepo
+	interval   time.Duration
+	eventCount int64
+}
+
+func NewSnapshotter(repo order.SnapshotRepo, interval time.Duration, eventCount int) *Snapshotter {
+	return &Snapshotter{
+		repo:       repo,
+		interval:   interval,
+		eventCount: int64(eventCount),
+	}
+}
+
+// Attach starts snapshotting the book of a tenant, it is meant to be run
+// from a Registry.OnBook hook
+func (s *Snapshotter) Attach(tenantId string, b Book) {
+	if s.interval <= 0 && s.eventCount <= 0 {
+		return
+	}
+	due := make(chan struct{}, 1)
+	if s.eventCount > 0 {
+		var events atomic.Int64
+		b.OnOrderEvent(func(ev order.OrderEvent) {
+			if events.Add(1)%s.eventCount != 0 {
+				return
+			}
+			// A snapshot already pending covers this one
+			select {
+			case due <- struct{}{}:
+			default:
+			}
+		})
+	}
+	go s.run(tenantId, b, due)
+}
+
+func (s *Snapshotter) run(tenantId string, b Book, due <-chan struct{}) {
+	var tick <-chan time.Time
+	if s.interval > 0 {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var lastSeq int64
+	for {
+		select {
+		case <-tick:
+		case <-due:
+		}
+		seq := b.Seq()
+		if seq == lastSeq {
+			continue
+		}
+		if s.snapshot(tenantId, b) {
+			lastSeq = seq
+		}
+	}
+}
+
+// snapshot writes every pair of the book and reports whether all of them were saved
+func (s *Snapshotter) snapshot(tenantId string, b Book) bool {
+	saved := true
+	for _, pairId := range b.Pairs() {
+		snap := b.Snapshot(pairId)
+		snap.TenantID = tenantId
+		if err := s.repo.SaveSnapshot(context.Background(), snap); err != nil {
+			engineLog(pairId).Error("failed to save book snapshot", map[string]any{
+				"tenant_id": tenantId,
+				"pair_id":   pairId,
+				"seq":       snap.Seq,
+				"error":     err,
+			})
+			saved = false
+			continue
+		}
+		engineLog(pairId).Debug("book snapshot saved", map[string]any{
+			"tenant_id": tenantId,
+			"pair_id":   pairId,
+			"seq":       snap.Seq,
+			"asks":      len(snap.Asks),
+			"bids":      len(snap.Bids),
+		})
+	}
+	return saved
+}
+
+// RestoreLatest loads the latest snapshot of each of pairs into the book of
+// a tenant, for an instance taking over from another. The events recorded
+// after the snapshots aren't replayed, pairs never snapshotted stay empty.
+func RestoreLatest(ctx context.Context, repo order.SnapshotRepo, tenantId string, b Book, pairs []string) error {
+	for _, pairId := range pairs {
+		snap, err := repo.GetLatestSnapshot(ctx, tenantId, pairId)
+		if errors.Is(err, order.ErrSnapshotNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		b.Restore(snap)
+	}
+	return nil
+}
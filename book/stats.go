@@ -0,0 +1,117 @@
+package book
+
+import (
+	"order-book/order"
+
+	"github.com/emirpasic/gods/trees/redblacktree"
+	"github.com/shopspring/decimal"
+)
+
+// BookStats describe the resting orders of both sides of a pair
+type BookStats struct {
+	PairID string    `json:"pair_id"`
+	Asks   SideStats `json:"asks"`
+	Bids   SideStats `json:"bids"`
+}
+
+// SideStats describe the resting orders of a side. LargestLevel is the level
+// with the most quantity, nil while the side is empty, and AvgQueueDepth the
+// number of orders per level.
+type SideStats struct {
+	Orders        int             `json:"orders"`
+	Quantity      decimal.Decimal `json:"quantity"`
+	Levels        int             `json:"levels"`
+	LargestLevel  *LevelStats     `json:"largest_level"`
+	AvgQueueDepth float64         `json:"avg_queue_depth"`
+}
+
+type LevelStats struct {
+	Price    decimal.Decimal `json:"price"`
+	Quantity decimal.Decimal `json:"quantity"`
+	Orders   int             `json:"orders"`
+}
+
+// sideStats are kept up to date by the engine as orders rest, fill and
+// leave, so serving them doesn't walk the orders. The largest level is
+// found again over the levels only once it shrank.
+type sideStats struct {
+	orders        int
+	quantity      decimal.Decimal
+	largest       decimal.Decimal
+	largestAmount decimal.Decimal
+	stale         bool
+}
+
+type pairStats struct {
+	asks sideStats
+	bids sideStats
+}
+
+// adjust moves the stats of the side and the level of a resting order by
+// amount and orders, callers hold b.mu
+func (b *BookImpl) adjust(o order.Order, level *order.OrderList, amount decimal.Decimal, orders int) {
+	ps, ok := b.stats[o.PairID]
+	if !ok {
+		ps = &pairStats{}
+		b.stats[o.PairID] = ps
+	}
+	s := &ps.asks
+	if o.Type == order.BID {
+		s = &ps.bids
+	}
+	s.orders += orders
+	s.quantity = s.quantity.Add(amount)
+	level.Amount = level.Amount.Add(amount)
+	switch {
+	case s.orders == 0:
+		*s = sideStats{}
+	case s.stale:
+	case o.Price.Equal(s.largest) && amount.IsNegative():
+		s.stale = true
+	case o.Price.Equal(s.largest):
+		s.largestAmount = level.Amount
+	case level.Amount.GreaterThan(s.largestAmount):
+		s.largest, s.largestAmount = o.Price, level.Amount
+	}
+}
+
+// Stats describe the resting orders of a pair. They're kept by the engine,
+// only the largest level may be looked for again among the levels.
+func (b *BookImpl) Stats(pairId string) BookStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := BookStats{PairID: pairId}
+	ps, ok := b.stats[pairId]
+	if !ok {
+		return stats
+	}
+	stats.Asks = sideStatsOf(&ps.asks, b.askTreesMap[pairId])
+	stats.Bids = sideStatsOf(&ps.bids, b.bidTreesMap[pairId])
+	return stats
+}
+
+// sideStatsOf finds the largest level again when it's stale, callers hold
+// b.mu
+func sideStatsOf(s *sideStats, tree *redblacktree.Tree) SideStats {
+	stats := SideStats{Orders: s.orders, Quantity: s.quantity}
+	if tree == nil || tree.Size() == 0 {
+		return stats
+	}
+	if s.stale {
+		s.largestAmount = decimal.Zero
+		it := tree.Iterator()
+		for it.Next() {
+			if amount := it.Value().(*order.OrderList).Amount; amount.GreaterThan(s.largestAmount) {
+				s.largest, s.largestAmount = it.Key().(decimal.Decimal), amount
+			}
+		}
+		s.stale = false
+	}
+	stats.Levels = tree.Size()
+	stats.AvgQueueDepth = float64(s.orders) / float64(stats.Levels)
+	if level, ok := tree.Get(s.largest); ok {
+		list := level.(*order.OrderList)
+		stats.LargestLevel = &LevelStats{Price: s.largest, Quantity: list.Amount, Orders: len(list.List)}
+	}
+	return stats
+}
@@ -0,0 +1,75 @@
+package book
+
+import (
+	"order-book/metrics"
+	"time"
+)
+
+// StageTimings are the times an order went through the stages of the
+// engine. A stage that didn't happen, like matching after persisting
+// failed, is left zero.
+type StageTimings struct {
+	ReceivedAt  time.Time
+	DequeuedAt  time.Time
+	PersistedAt time.Time
+	MatchedAt   time.Time
+	PublishedAt time.Time
+	// OrderID is the ID the order was persisted with
+	OrderID int
+	Err     error
+}
+
+// StageBreakdown is the time spent in each stage, in microseconds
+type StageBreakdown struct {
+	QueueUS   int64 `json:"queue_us"`
+	PersistUS int64 `json:"persist_us"`
+	MatchUS   int64 `json:"match_us"`
+	PublishUS int64 `json:"publish_us"`
+	TotalUS   int64 `json:"total_us"`
+}
+
+// stages is the time each stage the order reached took, and the total
+func (t StageTimings) stages() map[string]time.Duration {
+	marks := []struct {
+		stage string
+		at    time.Time
+	}{
+		{"queue", t.DequeuedAt},
+		{"persist", t.PersistedAt},
+		{"match", t.MatchedAt},
+		{"publish", t.PublishedAt},
+	}
+	stages := make(map[string]time.Duration, len(marks)+1)
+	from := t.ReceivedAt
+	for _, mark := range marks {
+		if mark.at.IsZero() {
+			break
+		}
+		stages[mark.stage] = mark.at.Sub(from)
+		from = mark.at
+	}
+	stages["total"] = from.Sub(t.ReceivedAt)
+	return stages
+}
+
+func (t StageTimings) Breakdown() StageBreakdown {
+	stages := t.stages()
+	return StageBreakdown{
+		QueueUS:   stages["queue"].Microseconds(),
+		PersistUS: stages["persist"].Microseconds(),
+		MatchUS:   stages["match"].Microseconds(),
+		PublishUS: stages["publish"].Microseconds(),
+		TotalUS:   stages["total"].Microseconds(),
+	}
+}
+
+// observe records the stages the order went through, the total only counts
+// orders that made it through every stage
+func (t StageTimings) observe() {
+	for stage, took := range t.stages() {
+		if stage == "total" && t.PublishedAt.IsZero() {
+			continue
+		}
+		metrics.StageLatency.WithLabelValues(stage).Observe(took.Seconds())
+	}
+}
@@ -0,0 +1,293 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"order-book/book"
+	"order-book/logger"
+	"order-book/marketdata"
+	"order-book/order"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Types of the published events
+const (
+	TRADE           = "TRADE"
+	ORDER_CREATED   = order.ORDER_CREATED
+	ORDER_CANCELLED = order.ORDER_CANCELLED
+	DEPTH_DIFF      = "DEPTH_DIFF"
+)
+
+// Event is the envelope of every published message, Data holds an
+// order.Trade, an order.Order or a marketdata.DepthDiff depending on Type.
+// ID is unique across tenants and increases with the events of a pair, so
+// consumers can drop the redelivered ones.
+type Event struct {
+	ID       int64     `json:"id"`
+	Type     string    `json:"type"`
+	Version  int       `json:"version"`
+	TenantID string    `json:"tenant_id"`
+	PairID   string    `json:"pair_id"`
+	At       time.Time `json:"at"`
+	Data     any       `json:"data"`
+}
+
+// EventSchemas are the current schema versions of the Data of each event type
+//
+//	TRADE           1: order.Trade
+//	ORDER_CREATED   1: order.Order
+//	ORDER_CANCELLED 1: order.Order
+//	DEPTH_DIFF      1: marketdata.DepthDiff
+var EventSchemas = map[string]int{
+	TRADE:           1,
+	ORDER_CREATED:   1,
+	ORDER_CANCELLED: 1,
+	DEPTH_DIFF:      1,
+}
+
+// Message is an encoded event bound to a topic
+type Message struct {
+	ID    int64
+	Topic string
+	Key   string
+	Value []byte
+}
+
+// eventSeq is seeded from the clock like trade IDs so event IDs keep
+// increasing across restarts
+var eventSeq atomic.Int64
+
+func init() {
+	eventSeq.Store(time.Now().UnixMicro())
+}
+
+// Transport sends a batch of messages, keeping the order of the messages of
+// a key, and returns nil once all of them were accepted
+type Transport interface {
+	Send(ctx context.Context, msgs []Message) error
+	Close()
+}
+
+type Topics struct {
+	Trades string
+	Orders string
+	Depth  string
+}
+
+type Options struct {
+	Topics Topics
+	// Messages are sent in batches of up to BatchSize, waiting at most Linger
+	// for a batch to fill
+	BatchSize int
+	Linger    time.Duration
+	// A failed batch is retried MaxRetries times, RetryBackoff apart and
+	// doubling, before its messages are dropped
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// BufferSize messages can wait to be sent before new ones are dropped
+	BufferSize int
+	// DepthInterval is how often the depth of changed pairs is diffed
+	DepthInterval time.Duration
+}
+
+// Stats counts the messages since startup
+type Stats struct {
+	Published  uint64    `json:"published"`
+	Failed     uint64    `json:"failed"`
+	Dropped    uint64    `json:"dropped"`
+	Retries    uint64    `json:"retries"`
+	Batches    uint64    `json:"batches"`
+	Queued     int       `json:"queued"`
+	LastError  string    `json:"last_error,omitempty"`
+	LastSentAt time.Time `json:"last_sent_at,omitzero"`
+}
+
+// Publisher streams the trades, order lifecycle events and depth diffs of
+// the books to the transport. Messages are keyed by pair so the events of a
+// pair stay in order. The engine never waits on the transport.
+type Publisher struct {
+	transport Transport
+	opts      Options
+	queue     chan Message
+
+	published atomic.Uint64
+	failed    atomic.Uint64
+	dropped   atomic.Uint64
+	retries   atomic.Uint64
+	batches   atomic.Uint64
+	mu        sync.Mutex
+	lastError string
+	lastSent  time.Time
+}
+
+func NewPublisher(transport Transport, opts Options) *Publisher {
+	return &Publisher{
+		transport: transport,
+		opts:      opts,
+		queue:     make(chan Message, opts.BufferSize),
+	}
+}
+
+// Attach publishes the events of the book of a tenant, it is meant to be
+// run from a Registry.OnBook hook
+func (p *Publisher) Attach(tenantId string, b book.Book) {
+	d := &depthFeed{publisher: p, tenantId: tenantId, book: b, dirty: make(map[string]bool), last: make(map[string]marketdata.Depth)}
+	b.OnTrade(func(t order.Trade) {
+		p.enqueue(p.opts.Topics.Trades, Event{Type: TRADE, TenantID: tenantId, PairID: t.PairID, At: t.ExecutedAt, Data: t})
+		d.touch(t.PairID)
+	})
+	b.OnOrderEvent(func(ev order.OrderEvent) {
+		p.enqueue(p.opts.Topics.Orders, Event{Type: ev.Name, TenantID: tenantId, PairID: ev.Order.PairID, At: ev.At, Data: ev.Order})
+		d.touch(ev.Order.PairID)
+	})
+	go d.run()
+}
+
+func (p *Publisher) enqueue(topic string, ev Event) {
+	if topic == "" {
+		return
+	}
+	ev.ID = eventSeq.Add(1)
+	ev.Version = EventSchemas[ev.Type]
+	value, err := json.Marshal(ev)
+	if err != nil {
+		logger.Error("failed to encode broker event", map[string]any{
+			"type":  ev.Type,
+			"error": err,
+		})
+		return
+	}
+	select {
+	case p.queue <- Message{ID: ev.ID, Topic: topic, Key: ev.PairID, Value: value}:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+// Run sends the queued messages until ctx is done, then closes the transport
+func (p *Publisher) Run(ctx context.Context) {
+	defer p.transport.Close()
+	batch := make([]Message, 0, p.opts.BatchSize)
+	linger := time.NewTimer(p.opts.Linger)
+	defer linger.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-p.queue:
+			batch = append(batch, msg)
+			if len(batch) < p.opts.BatchSize {
+				if len(batch) == 1 {
+					linger.Reset(p.opts.Linger)
+				}
+				continue
+			}
+		case <-linger.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		p.send(ctx, batch)
+		batch = batch[:0]
+	}
+}
+
+func (p *Publisher) send(ctx context.Context, batch []Message) {
+	p.batches.Add(1)
+	backoff := p.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := p.transport.Send(ctx, batch)
+		if err == nil {
+			p.published.Add(uint64(len(batch)))
+			p.mu.Lock()
+			p.lastSent = time.Now()
+			p.mu.Unlock()
+			return
+		}
+		p.mu.Lock()
+		p.lastError = err.Error()
+		p.mu.Unlock()
+		if attempt == p.opts.MaxRetries || ctx.Err() != nil {
+			p.failed.Add(uint64(len(batch)))
+			logger.Error("failed to publish broker events, dropping the batch", map[string]any{
+				"count":    len(batch),
+				"attempts": attempt + 1,
+				"error":    err,
+			})
+			return
+		}
+		p.retries.Add(1)
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (p *Publisher) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return Stats{
+		Published:  p.published.Load(),
+		Failed:     p.failed.Load(),
+		Dropped:    p.dropped.Load(),
+		Retries:    p.retries.Load(),
+		Batches:    p.batches.Load(),
+		Queued:     len(p.queue),
+		LastError:  p.lastError,
+		LastSentAt: p.lastSent,
+	}
+}
+
+// depthFeed diffs the depth of the pairs of a book that changed since the last diff
+type depthFeed struct {
+	publisher *Publisher
+	tenantId  string
+	book      book.Book
+	mu        sync.Mutex
+	dirty     map[string]bool
+	// last is only used by run
+	last map[string]marketdata.Depth
+}
+
+func (d *depthFeed) touch(pairId string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dirty[pairId] = true
+}
+
+func (d *depthFeed) run() {
+	if d.publisher.opts.Topics.Depth == "" {
+		return
+	}
+	ticker := time.NewTicker(d.publisher.opts.DepthInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		d.mu.Lock()
+		pairs := make([]string, 0, len(d.dirty))
+		for pairId := range d.dirty {
+			pairs = append(pairs, pairId)
+		}
+		clear(d.dirty)
+		d.mu.Unlock()
+
+		for _, pairId := range pairs {
+			depth := marketdata.RenderDepth(d.book.Snapshot(pairId), 0)
+			diff := marketdata.Diff(d.last[pairId], depth)
+			d.last[pairId] = depth
+			if diff.Empty() {
+				continue
+			}
+			d.publisher.enqueue(d.publisher.opts.Topics.Depth, Event{
+				Type:     DEPTH_DIFF,
+				TenantID: d.tenantId,
+				PairID:   pairId,
+				At:       diff.At,
+				Data:     diff,
+			})
+		}
+	}
+}
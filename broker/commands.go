@@ -0,0 +1,140 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"order-book/book"
+	"order-book/logger"
+	"order-book/order"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+var ErrUnknownCommand = errors.New("Unknown command type")
+
+// Types of the inbound commands
+const (
+	ADD_ORDER    = "ADD_ORDER"
+	CANCEL_ORDER = "CANCEL_ORDER"
+)
+
+// CommandSchemas are the schema versions of the commands this engine reads,
+// a command without a version is read as version 1
+var CommandSchemas = map[string]int{
+	ADD_ORDER:    1,
+	CANCEL_ORDER: 1,
+}
+
+// Command is an inbound message. ADD_ORDER carries the order, CANCEL_ORDER
+// the order ID and the account it belongs to.
+type Command struct {
+	Type      string      `json:"type"`
+	Version   int         `json:"version"`
+	Order     order.Order `json:"order"`
+	OrderID   int         `json:"order_id"`
+	AccountID int         `json:"account_id"`
+}
+
+// CommandConsumer feeds order commands read from a JetStream subject to the
+// book of the tenant owning the account. A rejected command is terminated so
+// it isn't redelivered, a command that couldn't be queued in time is retried.
+type CommandConsumer struct {
+	books    *book.Registry
+	consumer jetstream.Consumer
+	timeout  time.Duration
+}
+
+// NewCommandConsumer creates or updates the stream holding the subject and a
+// durable consumer on it, so commands sent while the engine is down are
+// applied once it's back
+func NewCommandConsumer(ctx context.Context, conn *nats.Conn, stream string, subject string, durable string, books *book.Registry, timeout time.Duration) (*CommandConsumer, error) {
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return nil, err
+	}
+	s, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     stream,
+		Subjects: []string{subject},
+		// Commands are removed once applied
+		Retention: jetstream.WorkQueuePolicy,
+	})
+	if err != nil {
+		return nil, err
+	}
+	consumer, err := s.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Durable:   durable,
+		AckPolicy: jetstream.AckExplicitPolicy,
+		AckWait:   2 * timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CommandConsumer{books: books, consumer: consumer, timeout: timeout}, nil
+}
+
+// Run applies commands until ctx is done
+func (c *CommandConsumer) Run(ctx context.Context) error {
+	consumeCtx, err := c.consumer.Consume(func(msg jetstream.Msg) {
+		c.handle(ctx, msg)
+	})
+	if err != nil {
+		return err
+	}
+	<-ctx.Done()
+	consumeCtx.Stop()
+	return nil
+}
+
+func (c *CommandConsumer) handle(ctx context.Context, msg jetstream.Msg) {
+	var cmd Command
+	if err := json.Unmarshal(msg.Data(), &cmd); err != nil {
+		logger.Warn("dropping malformed order command", map[string]any{
+			"subject": msg.Subject(),
+			"error":   err,
+		})
+		msg.Term()
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	err := c.apply(ctx, cmd)
+	switch {
+	case err == nil:
+		msg.Ack()
+	case errors.Is(err, context.DeadlineExceeded):
+		// The book queue is full, let the command come back later
+		msg.Nak()
+	default:
+		logger.Info("order command rejected", map[string]any{
+			"type":       cmd.Type,
+			"account_id": cmd.AccountID,
+			"order_id":   cmd.OrderID,
+			"reason":     err.Error(),
+		})
+		msg.Term()
+	}
+}
+
+func (c *CommandConsumer) apply(ctx context.Context, cmd Command) error {
+	if supported, ok := CommandSchemas[cmd.Type]; ok && cmd.Version > supported {
+		// Sent for a newer engine, guessing its meaning could trade on the wrong terms
+		logger.Error("order command has an unsupported schema version", map[string]any{
+			"type":      cmd.Type,
+			"version":   cmd.Version,
+			"supported": supported,
+		})
+		return order.ErrUnsupportedSchemaVersion
+	}
+	switch cmd.Type {
+	case ADD_ORDER:
+		cmd.Order.CreatedAt = time.Now()
+		return c.books.ForAccount(cmd.Order.AccountID).AddOrder(ctx, cmd.Order)
+	case CANCEL_ORDER:
+		return c.books.ForAccount(cmd.AccountID).CancellOrder(ctx, cmd.OrderID)
+	}
+	return ErrUnknownCommand
+}
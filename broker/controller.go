@@ -0,0 +1,25 @@
+package broker
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindBrokerRouter exposes the delivery counters to operators. Growing
+// dropped or failed counts mean events never reached the broker.
+func BindBrokerRouter(r fiber.Router, p *Publisher) {
+	r.Get("/admin/broker/stats", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    p.Stats(),
+		})
+	})
+}
@@ -0,0 +1,37 @@
+package broker
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+type kafkaTransport struct {
+	client *kgo.Client
+}
+
+// NewKafkaTransport produces messages with the client, which is closed with
+// the transport. The event ID is set in the id header.
+func NewKafkaTransport(client *kgo.Client) Transport {
+	return &kafkaTransport{client: client}
+}
+
+func (t *kafkaTransport) Send(ctx context.Context, msgs []Message) error {
+	records := make([]*kgo.Record, len(msgs))
+	for idx, msg := range msgs {
+		records[idx] = &kgo.Record{
+			Topic: msg.Topic,
+			Key:   []byte(msg.Key),
+			Value: msg.Value,
+			Headers: []kgo.RecordHeader{
+				{Key: "id", Value: []byte(strconv.FormatInt(msg.ID, 10))},
+			},
+		}
+	}
+	return t.client.ProduceSync(ctx, records...).FirstErr()
+}
+
+func (t *kafkaTransport) Close() {
+	t.client.Close()
+}
@@ -0,0 +1,72 @@
+package broker
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type natsTransport struct {
+	conn *nats.Conn
+	js   jetstream.JetStream
+}
+
+// NewNATSTransport publishes messages to JetStream on <topic>.<key>
+// subjects and creates or updates the stream capturing every topic. The
+// event ID is the message ID, so the stream drops the messages of a retried
+// batch it already stored.
+func NewNATSTransport(ctx context.Context, conn *nats.Conn, stream string, topics Topics) (Transport, error) {
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return nil, err
+	}
+	var subjects []string
+	for _, topic := range []string{topics.Trades, topics.Orders, topics.Depth} {
+		if topic != "" {
+			subjects = append(subjects, topic+".>")
+		}
+	}
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     stream,
+		Subjects: subjects,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &natsTransport{conn: conn, js: js}, nil
+}
+
+// subjectToken replaces the characters NATS reserves in subjects
+var subjectToken = strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_")
+
+func (t *natsTransport) Send(ctx context.Context, msgs []Message) error {
+	acks := make([]jetstream.PubAckFuture, len(msgs))
+	for idx, msg := range msgs {
+		ack, err := t.js.PublishAsync(
+			msg.Topic+"."+subjectToken.Replace(msg.Key),
+			msg.Value,
+			jetstream.WithMsgID(strconv.FormatInt(msg.ID, 10)),
+		)
+		if err != nil {
+			return err
+		}
+		acks[idx] = ack
+	}
+	for _, ack := range acks {
+		select {
+		case <-ack.Ok():
+		case err := <-ack.Err():
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (t *natsTransport) Close() {
+	t.conn.Drain()
+}
@@ -0,0 +1,46 @@
+package chaos
+
+import (
+	"order-book/book"
+	"order-book/order"
+)
+
+// lossyBook drops the trades and order events of a book at the rate of the
+// faults before they reach the listeners registered through it
+type lossyBook struct {
+	book.Book
+	inj *Injector
+}
+
+// Lossy returns b with its trades and order events dropped for the
+// listeners registered on it, the others still get every event
+func (inj *Injector) Lossy(b book.Book) book.Book {
+	if !inj.enabled {
+		return b
+	}
+	return &lossyBook{Book: b, inj: inj}
+}
+
+// Attach wraps the Attach of a consumer of the books, for Registry.OnBook,
+// so it misses the dropped events
+func (inj *Injector) Attach(attach func(tenantId string, b book.Book)) func(tenantId string, b book.Book) {
+	return func(tenantId string, b book.Book) {
+		attach(tenantId, inj.Lossy(b))
+	}
+}
+
+func (b *lossyBook) OnTrade(fn func(t order.Trade)) {
+	b.Book.OnTrade(func(t order.Trade) {
+		if !b.inj.dropEvent() {
+			fn(t)
+		}
+	})
+}
+
+func (b *lossyBook) OnOrderEvent(fn func(ev order.OrderEvent)) {
+	b.Book.OnOrderEvent(func(ev order.OrderEvent) {
+		if !b.inj.dropEvent() {
+			fn(ev)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"order-book/logger"
+	"order-book/metrics"
+	"order-book/order"
+	"order-book/shutdown"
+	"sync"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+var (
+	ErrInjectedFault = errors.New("Fault injected by the chaos mode")
+	ErrInvalidFaults = errors.New("The rates should be between 0 and 1 and the delays can't be negative")
+)
+
+// disconnectInterval is how often the open websockets are considered for a
+// disconnect
+const disconnectInterval = time.Second
+
+// Faults are what the chaos mode injects, the zero value injects none. The
+// rates are probabilities between 0 and 1.
+type Faults struct {
+	// WriteDelayMs delays every write of orders, history events and trades
+	WriteDelayMs int64 `json:"write_delay_ms"`
+	// WriteFailureRate fails writes with ErrInjectedFault, after the delay
+	WriteFailureRate float64 `json:"write_failure_rate"`
+	// DropEventRate drops the trades and order events of the books before
+	// they reach the market data, the broker, the notifications and webhooks
+	DropEventRate float64 `json:"drop_event_rate"`
+	// MatchDelayMs holds the engine before it matches each order
+	MatchDelayMs int64 `json:"match_delay_ms"`
+	// WSDisconnectRate closes each open websocket with this probability
+	// every second
+	WSDisconnectRate float64 `json:"ws_disconnect_rate"`
+}
+
+func (f Faults) Validate() error {
+	for _, rate := range []float64{f.WriteFailureRate, f.DropEventRate, f.WSDisconnectRate} {
+		if rate < 0 || rate > 1 {
+			return ErrInvalidFaults
+		}
+	}
+	if f.WriteDelayMs < 0 || f.MatchDelayMs < 0 {
+		return ErrInvalidFaults
+	}
+	return nil
+}
+
+// Injector injects the faults set through the admin API, so the recovery
+// paths can be exercised in staging. It's meant for test environments only.
+type Injector struct {
+	enabled bool
+	mu      sync.RWMutex
+	faults  Faults
+}
+
+// NewInjector returns an injector that leaves the repositories and books it
+// wraps as they are unless enabled
+func NewInjector(enabled bool) *Injector {
+	return &Injector{enabled: enabled}
+}
+
+func (inj *Injector) Enabled() bool {
+	return inj.enabled
+}
+
+func (inj *Injector) Faults() Faults {
+	inj.mu.RLock()
+	defer inj.mu.RUnlock()
+	return inj.faults
+}
+
+// Set replaces the faults injected from now on
+func (inj *Injector) Set(f Faults) {
+	inj.mu.Lock()
+	inj.faults = f
+	inj.mu.Unlock()
+	logger.Warn("chaos faults changed", map[string]any{
+		"faults": f,
+	})
+}
+
+// write delays a write and fails it at the rate of the faults
+func (inj *Injector) write(ctx context.Context) error {
+	f := inj.Faults()
+	if f.WriteDelayMs > 0 {
+		metrics.FaultsInjected.WithLabelValues("write_delay").Inc()
+		select {
+		case <-time.After(time.Duration(f.WriteDelayMs) * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if rand.Float64() < f.WriteFailureRate {
+		metrics.FaultsInjected.WithLabelValues("write_failure").Inc()
+		return ErrInjectedFault
+	}
+	return nil
+}
+
+// dropEvent reports whether to drop an event
+func (inj *Injector) dropEvent() bool {
+	if rand.Float64() < inj.Faults().DropEventRate {
+		metrics.FaultsInjected.WithLabelValues("dropped_event").Inc()
+		return true
+	}
+	return false
+}
+
+// BeforeMatch holds the engine of a book, it's registered with
+// book.Book.BeforeMatch
+func (inj *Injector) BeforeMatch(o order.Order) {
+	if delay := inj.Faults().MatchDelayMs; delay > 0 {
+		metrics.FaultsInjected.WithLabelValues("match_delay").Inc()
+		time.Sleep(time.Duration(delay) * time.Millisecond)
+	}
+}
+
+// Run disconnects websockets at the rate of the faults until ctx is done
+func (inj *Injector) Run(ctx context.Context) {
+	ticker := time.NewTicker(disconnectInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rate := inj.Faults().WSDisconnectRate
+			if rate <= 0 {
+				continue
+			}
+			if n := shutdown.DropWebsockets(rate, websocket.CloseTryAgainLater, "chaos disconnect"); n > 0 {
+				metrics.FaultsInjected.WithLabelValues("ws_disconnect").Add(float64(n))
+			}
+		}
+	}
+}
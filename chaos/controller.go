@@ -0,0 +1,52 @@
+package chaos
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindChaosRouter serves the faults injected on GET /admin/chaos, replaces
+// them on PUT and stops injecting any on DELETE
+func BindChaosRouter(r fiber.Router, inj *Injector) {
+	r.Get("/admin/chaos", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    inj.Faults(),
+		})
+	})
+	r.Put("/admin/chaos", func(c *fiber.Ctx) error {
+		var f Faults
+		if err := c.BodyParser(&f); err != nil {
+			return err
+		}
+		if err := f.Validate(); err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		inj.Set(f)
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Faults updated",
+			Data:    f,
+		})
+	})
+	r.Delete("/admin/chaos", func(c *fiber.Ctx) error {
+		inj.Set(Faults{})
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Faults cleared",
+			Data:    Faults{},
+		})
+	})
+}
@@ -0,0 +1,65 @@
+package chaos
+
+import (
+	"context"
+	"order-book/order"
+
+	"github.com/shopspring/decimal"
+)
+
+// orderRepo injects the write faults into an order repository, reads go
+// through as they are
+type orderRepo struct {
+	order.OrderRepo
+	inj *Injector
+}
+
+// OrderRepo wraps repo with the write faults of inj. It goes under the
+// breaker so the retries and the breaker see the faults.
+func (inj *Injector) OrderRepo(repo order.OrderRepo) order.OrderRepo {
+	if !inj.enabled {
+		return repo
+	}
+	return &orderRepo{OrderRepo: repo, inj: inj}
+}
+
+func (r *orderRepo) AddEvent(ctx context.Context, ev order.OrderHistoryEvent) error {
+	if err := r.inj.write(ctx); err != nil {
+		return err
+	}
+	return r.OrderRepo.AddEvent(ctx, ev)
+}
+
+func (r *orderRepo) AddEvents(ctx context.Context, evs []order.OrderHistoryEvent) error {
+	if err := r.inj.write(ctx); err != nil {
+		return err
+	}
+	return r.OrderRepo.AddEvents(ctx, evs)
+}
+
+func (r *orderRepo) CreateOrder(ctx context.Context, pairID string, price decimal.Decimal, amount decimal.Decimal, accountID int, orderType order.OrderType, tag string, strategyId string) (order.Order, error) {
+	if err := r.inj.write(ctx); err != nil {
+		return order.Order{}, err
+	}
+	return r.OrderRepo.CreateOrder(ctx, pairID, price, amount, accountID, orderType, tag, strategyId)
+}
+
+type tradeRepo struct {
+	order.TradeRepo
+	inj *Injector
+}
+
+// TradeRepo wraps repo with the write faults of inj like OrderRepo
+func (inj *Injector) TradeRepo(repo order.TradeRepo) order.TradeRepo {
+	if !inj.enabled {
+		return repo
+	}
+	return &tradeRepo{TradeRepo: repo, inj: inj}
+}
+
+func (r *tradeRepo) AddTrades(ctx context.Context, trades []order.Trade) error {
+	if err := r.inj.write(ctx); err != nil {
+		return err
+	}
+	return r.TradeRepo.AddTrades(ctx, trades)
+}
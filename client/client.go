@@ -0,0 +1,353 @@
+// Package client is the Go client of the engine. It wraps the REST API and
+// the websocket streams with typed methods, authenticates every request and
+// stream with the API key, and keeps streams up across disconnects.
+//
+//	c := client.New("https://engine.example.com", client.Options{APIKey: key})
+//	orderId, err := c.SubmitOrder(ctx, order.Order{PairID: "btcusdt", ...})
+//	go c.SubscribeDepth(ctx, "btcusdt", func(d marketdata.Depth) { ... })
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"order-book/account"
+	"order-book/marketdata"
+	"order-book/order"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrOrderNotFound = errors.New("The order not found")
+
+// APIError is a request the engine refused, with the message it gave
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d: %s", e.Status, e.Message)
+}
+
+type Options struct {
+	// APIKey is sent as X-API-Key with every request and stream, none is the
+	// default tenant
+	APIKey string
+	// OperatorToken is sent as X-Operator-Token, the /admin routes require
+	// the token of the operator
+	OperatorToken string
+	// WSURL is where the streams are, the base URL with ws:// by default
+	WSURL string
+	// HTTPClient sends the requests, one with a 10s timeout by default
+	HTTPClient *http.Client
+	// MinReconnectDelay and MaxReconnectDelay bound the backoff between
+	// reconnects of a stream, 1s and 30s by default
+	MinReconnectDelay time.Duration
+	MaxReconnectDelay time.Duration
+	// OnReconnect is called when a stream is connected again, with the error
+	// that dropped it. Order updates sent meanwhile are lost, the orders are
+	// best reloaded.
+	OnReconnect func(stream string, err error)
+}
+
+type Client struct {
+	baseURL string
+	opts    Options
+}
+
+func New(baseURL string, opts Options) *Client {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if opts.WSURL == "" {
+		opts.WSURL = "ws" + strings.TrimPrefix(baseURL, "http")
+	}
+	opts.WSURL = strings.TrimSuffix(opts.WSURL, "/")
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.MinReconnectDelay <= 0 {
+		opts.MinReconnectDelay = time.Second
+	}
+	if opts.MaxReconnectDelay < opts.MinReconnectDelay {
+		opts.MaxReconnectDelay = max(30*time.Second, opts.MinReconnectDelay)
+	}
+	return &Client{
+		baseURL: baseURL,
+		opts:    opts,
+	}
+}
+
+// header carries the credentials of the client
+func (c *Client) header() http.Header {
+	header := http.Header{}
+	if c.opts.APIKey != "" {
+		header.Set("X-API-Key", c.opts.APIKey)
+	}
+	if c.opts.OperatorToken != "" {
+		header.Set("X-Operator-Token", c.opts.OperatorToken)
+	}
+	return header
+}
+
+type response struct {
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// do sends body as JSON and decodes the data of the response into out
+func (c *Client) do(ctx context.Context, method string, path string, body any, out any) error {
+	_, err := c.send(ctx, method, path, nil, body, out)
+	return err
+}
+
+// send is do with extra headers, it returns the status of the response
+func (c *Client) send(ctx context.Context, method string, path string, header http.Header, body any, out any) (int, error) {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		payload = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, payload)
+	if err != nil {
+		return 0, err
+	}
+	req.Header = c.header()
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.opts.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	var resp response
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil && err != io.EOF {
+		return res.StatusCode, fmt.Errorf("%s %s: %d: %w", method, path, res.StatusCode, err)
+	}
+	if res.StatusCode >= 300 {
+		return res.StatusCode, &APIError{Status: res.StatusCode, Message: resp.Message}
+	}
+	if out != nil && len(resp.Data) > 0 {
+		return res.StatusCode, json.Unmarshal(resp.Data, out)
+	}
+	return res.StatusCode, nil
+}
+
+// SubmitOrder waits for the engine to take the order, and returns the ID
+// it was given. An order the engine rejects is an *APIError with status 422.
+func (c *Client) SubmitOrder(ctx context.Context, o order.Order) (int, error) {
+	var data struct {
+		OrderID int `json:"order_id"`
+	}
+	err := c.do(ctx, http.MethodPost, "/add-order?timings=true", o, &data)
+	return data.OrderID, err
+}
+
+// Quote replaces the quote of the account on the pair, it returns the IDs
+// of the orders placed, the bid first, and of those cancelled
+func (c *Client) Quote(ctx context.Context, q order.Quote) (orderIds []int, cancelledIds []int, err error) {
+	var data struct {
+		OrderIDs     []int `json:"order_ids"`
+		CancelledIDs []int `json:"cancelled_order_ids"`
+	}
+	err = c.do(ctx, http.MethodPost, "/quotes", q, &data)
+	return data.OrderIDs, data.CancelledIDs, err
+}
+
+// QuoteAck is the result of a quote of a mass quote
+type QuoteAck struct {
+	PairID       string `json:"pair_id"`
+	Status       string `json:"status"`
+	OrderIDs     []int  `json:"order_ids"`
+	CancelledIDs []int  `json:"cancelled_order_ids"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// MassQuote replaces the quotes of the account on many pairs, a quote
+// rejected by the engine is a QuoteAck with the REJECTED status
+func (c *Client) MassQuote(ctx context.Context, accountId int, quotes []order.Quote) ([]QuoteAck, error) {
+	var acks []QuoteAck
+	body := map[string]any{"account_id": accountId, "quotes": quotes}
+	err := c.do(ctx, http.MethodPost, "/mass-quotes", body, &acks)
+	return acks, err
+}
+
+// CancelQuotes pulls the quotes of the account on a pair, on every pair
+// when pairId is empty, and returns the IDs of the orders cancelled
+func (c *Client) CancelQuotes(ctx context.Context, accountId int, pairId string) ([]int, error) {
+	var data struct {
+		CancelledIDs []int `json:"cancelled_order_ids"`
+	}
+	path := "/quotes?account_id=" + strconv.Itoa(accountId)
+	if pairId != "" {
+		path += "&pair_id=" + url.QueryEscape(pairId)
+	}
+	err := c.do(ctx, http.MethodDelete, path, nil, &data)
+	return data.CancelledIDs, err
+}
+
+// CancelOrder returns ErrOrderNotFound for an order that isn't on the book
+// anymore
+func (c *Client) CancelOrder(ctx context.Context, orderId int) error {
+	err := c.do(ctx, http.MethodDelete, "/order-book/"+strconv.Itoa(orderId), nil, nil)
+	if apiErr, ok := err.(*APIError); ok && apiErr.Status == http.StatusNotFound {
+		return ErrOrderNotFound
+	}
+	return err
+}
+
+func (c *Client) GetOrder(ctx context.Context, accountId int, orderId int) (order.Order, error) {
+	var o order.Order
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/accounts/%d/orders/%d", accountId, orderId), nil, &o)
+	if apiErr, ok := err.(*APIError); ok && apiErr.Status == http.StatusNotFound {
+		return o, ErrOrderNotFound
+	}
+	return o, err
+}
+
+// GetDepth returns the cached depth of a pair, levels 0 for every level kept
+func (c *Client) GetDepth(ctx context.Context, pairId string, levels int) (marketdata.Depth, error) {
+	var depth marketdata.Depth
+	path := "/market/" + url.PathEscape(pairId) + "/depth"
+	if levels > 0 {
+		path += "?levels=" + strconv.Itoa(levels)
+	}
+	err := c.do(ctx, http.MethodGet, path, nil, &depth)
+	return depth, err
+}
+
+// GetOrderHistory returns the history events of an order of the account
+func (c *Client) GetOrderHistory(ctx context.Context, accountId int, orderId int) ([]order.OrderHistoryEvent, error) {
+	var events []order.OrderHistoryEvent
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/accounts/%d/orders/%d/history", accountId, orderId), nil, &events)
+	if apiErr, ok := err.(*APIError); ok && apiErr.Status == http.StatusNotFound {
+		return nil, ErrOrderNotFound
+	}
+	return events, err
+}
+
+// GetTrades returns the trades of an account on a pair, of every pair with
+// none, the most recent first
+func (c *Client) GetTrades(ctx context.Context, accountId int, pairId string) ([]order.Trade, error) {
+	var trades []order.Trade
+	path := fmt.Sprintf("/accounts/%d/trades", accountId)
+	if pairId != "" {
+		path += "?pair_id=" + url.QueryEscape(pairId)
+	}
+	err := c.do(ctx, http.MethodGet, path, nil, &trades)
+	return trades, err
+}
+
+// Deposit credits an account once per idempotency key. Sent again with the
+// same key it returns the entry of the first deposit with replayed set, and
+// an *APIError with status 409 when the deposit isn't the same.
+func (c *Client) Deposit(ctx context.Context, accountId int, asset string, amount float64, idempotencyKey string) (entry account.LedgerEntry, replayed bool, err error) {
+	header := http.Header{}
+	header.Set("Idempotency-Key", idempotencyKey)
+	body := map[string]any{"asset": asset, "amount": amount}
+	status, err := c.send(ctx, http.MethodPost, fmt.Sprintf("/admin/accounts/%d/deposits", accountId), header, body, &entry)
+	return entry, status == http.StatusOK, err
+}
+
+func (c *Client) GetBalances(ctx context.Context, accountId int) ([]account.Balance, error) {
+	var balances []account.Balance
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/accounts/%d/balances", accountId), nil, &balances)
+	return balances, err
+}
+
+// GetRecentTrades returns the cached recent trades of a pair, the most
+// recent first
+func (c *Client) GetRecentTrades(ctx context.Context, pairId string, limit int) ([]marketdata.PublicTrade, error) {
+	var trades []marketdata.PublicTrade
+	path := "/market/" + url.PathEscape(pairId) + "/trades"
+	if limit > 0 {
+		path += "?limit=" + strconv.Itoa(limit)
+	}
+	err := c.do(ctx, http.MethodGet, path, nil, &trades)
+	return trades, err
+}
+
+// Pairs are the listed pairs, and the halted ones with order.ALL_PAIRS
+// when every pair is
+type Pairs struct {
+	Pairs  []order.PairStatus `json:"pairs"`
+	Halted []string           `json:"halted"`
+}
+
+// The account, pair and halt methods need the key of the operator
+
+func (c *Client) ListPairs(ctx context.Context) (Pairs, error) {
+	var pairs Pairs
+	err := c.do(ctx, http.MethodGet, "/admin/pairs", nil, &pairs)
+	return pairs, err
+}
+
+// ListAssets returns the listed assets, their precision formats and checks
+// the amounts of deposits and withdrawals
+func (c *Client) ListAssets(ctx context.Context) ([]order.Asset, error) {
+	var assets []order.Asset
+	err := c.do(ctx, http.MethodGet, "/assets", nil, &assets)
+	return assets, err
+}
+
+// PutAsset lists an asset or changes its definition
+func (c *Client) PutAsset(ctx context.Context, a order.Asset) error {
+	return c.do(ctx, http.MethodPut, "/admin/assets/"+url.PathEscape(a.Symbol), a, nil)
+}
+
+func (c *Client) DelistAsset(ctx context.Context, symbol string) error {
+	return c.do(ctx, http.MethodDelete, "/admin/assets/"+url.PathEscape(symbol), nil, nil)
+}
+
+// CreateAccount opens an account in a tenant
+func (c *Client) CreateAccount(ctx context.Context, tenantId string) (int, error) {
+	var data struct {
+		AccountID int `json:"account_id"`
+	}
+	err := c.do(ctx, http.MethodPost, "/admin/tenants/"+url.PathEscape(tenantId)+"/accounts", nil, &data)
+	return data.AccountID, err
+}
+
+// PutPair lists a pair or changes its definition
+func (c *Client) PutPair(ctx context.Context, p order.Pair) error {
+	return c.do(ctx, http.MethodPut, "/admin/pairs/"+url.PathEscape(p.ID), p, nil)
+}
+
+func (c *Client) DelistPair(ctx context.Context, pairId string) error {
+	return c.do(ctx, http.MethodDelete, "/admin/pairs/"+url.PathEscape(pairId), nil, nil)
+}
+
+// Halt stops the trading of a pair, of every pair with order.ALL_PAIRS.
+// It returns the halted pairs.
+func (c *Client) Halt(ctx context.Context, pairId string) ([]string, error) {
+	var halted []string
+	err := c.do(ctx, http.MethodPost, haltPath(pairId), nil, &halted)
+	return halted, err
+}
+
+// Resume lifts a halt, it returns the pairs still halted
+func (c *Client) Resume(ctx context.Context, pairId string) ([]string, error) {
+	var halted []string
+	err := c.do(ctx, http.MethodDelete, haltPath(pairId), nil, &halted)
+	return halted, err
+}
+
+func haltPath(pairId string) string {
+	if pairId == order.ALL_PAIRS {
+		return "/admin/halt"
+	}
+	return "/admin/pairs/" + url.PathEscape(pairId) + "/halt"
+}
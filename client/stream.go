@@ -0,0 +1,170 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"order-book/marketdata"
+	"order-book/pgnotify"
+	"time"
+
+	"github.com/fasthttp/websocket"
+)
+
+// READ_TIMEOUT drops a stream that went silent, the engine pings every 30s
+const READ_TIMEOUT = 90 * time.Second
+
+var ErrSeqGap = errors.New("A depth diff doesn't follow the depth it should apply to")
+
+// message is an update of a stream, or the message the engine sends before
+// closing it
+type message struct {
+	Type    string          `json:"type"`
+	Data    json.RawMessage `json:"data"`
+	Message string          `json:"message"`
+}
+
+// SubscribeDepth calls fn with the depth of a pair each time it changes,
+// until ctx is done. The depth is kept from the snapshot the stream starts
+// with and the diffs that follow it. A diff that doesn't follow the depth,
+// missed updates, resubscribes for a new snapshot. fn is called from the
+// goroutine reading the stream, it should return quickly.
+func (c *Client) SubscribeDepth(ctx context.Context, pairId string, fn func(marketdata.Depth)) error {
+	return c.stream(ctx, "depth", "/ws/market/"+url.PathEscape(pairId), func(conn *websocket.Conn) error {
+		// Without a snapshot the diffs apply to an empty book
+		depth := marketdata.Depth{PairID: pairId}
+		for {
+			var msg message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return err
+			}
+			switch msg.Type {
+			case marketdata.DEPTH:
+				var snapshot marketdata.Depth
+				if err := json.Unmarshal(msg.Data, &snapshot); err != nil {
+					return err
+				}
+				depth = snapshot
+				fn(depth)
+			case marketdata.DEPTH_DIFF:
+				var diff marketdata.DepthDiff
+				if err := json.Unmarshal(msg.Data, &diff); err != nil {
+					return err
+				}
+				// The snapshot can be newer than the first diffs
+				if diff.Seq <= depth.Seq {
+					continue
+				}
+				if diff.FromSeq != depth.Seq {
+					return ErrSeqGap
+				}
+				depth = diff.Apply(depth)
+				fn(depth)
+			case "":
+				return fmt.Errorf("depth: %s", msg.Message)
+			}
+		}
+	})
+}
+
+// SubscribeTrades calls fn with the trades of a pair as they happen, until
+// ctx is done. Trades made while reconnecting are missed, they're in
+// GetRecentTrades.
+func (c *Client) SubscribeTrades(ctx context.Context, pairId string, fn func(marketdata.PublicTrade)) error {
+	return c.stream(ctx, "trades", "/ws/market/"+url.PathEscape(pairId), func(conn *websocket.Conn) error {
+		for {
+			var msg message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return err
+			}
+			switch msg.Type {
+			case marketdata.TRADE:
+				var t marketdata.PublicTrade
+				if err := json.Unmarshal(msg.Data, &t); err != nil {
+					return err
+				}
+				fn(t)
+			case "":
+				return fmt.Errorf("trades: %s", msg.Message)
+			}
+		}
+	})
+}
+
+// SubscribeUserStream calls fn with the updates of the orders of an
+// account, until ctx is done. Updates sent while reconnecting are lost,
+// Options.OnReconnect is the time to reload the orders.
+func (c *Client) SubscribeUserStream(ctx context.Context, accountId int, fn func(pgnotify.OrderUpdate)) error {
+	return c.stream(ctx, "user", fmt.Sprintf("/ws/accounts/%d/orders", accountId), func(conn *websocket.Conn) error {
+		for {
+			var msg struct {
+				pgnotify.OrderUpdate
+				Message string `json:"message"`
+			}
+			if err := conn.ReadJSON(&msg); err != nil {
+				return err
+			}
+			if msg.Message != "" {
+				return fmt.Errorf("user: %s", msg.Message)
+			}
+			fn(msg.OrderUpdate)
+		}
+	})
+}
+
+// stream keeps a websocket on path until ctx is done, connecting again with
+// a backoff whenever handle returns
+func (c *Client) stream(ctx context.Context, name string, path string, handle func(conn *websocket.Conn) error) error {
+	delay := c.opts.MinReconnectDelay
+	var dropped error
+	for {
+		connected := false
+		err := c.connect(ctx, path, func(conn *websocket.Conn) error {
+			connected = true
+			if dropped != nil && c.opts.OnReconnect != nil {
+				c.opts.OnReconnect(name, dropped)
+			}
+			return handle(conn)
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if connected {
+			delay = c.opts.MinReconnectDelay
+		}
+		dropped = err
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, c.opts.MaxReconnectDelay)
+	}
+}
+
+// connect holds a websocket for handle, it's closed once ctx is done
+func (c *Client) connect(ctx context.Context, path string, handle func(conn *websocket.Conn) error) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, c.opts.WSURL+path, c.header())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(READ_TIMEOUT))
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(READ_TIMEOUT))
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(5*time.Second))
+	})
+	return handle(conn)
+}
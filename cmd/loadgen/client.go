@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"order-book/order"
+	"strconv"
+	"time"
+)
+
+var ErrOrderNotFound = errors.New("The order not found")
+
+// Client calls the REST API of the engine
+type Client struct {
+	url           string
+	apiKey        string
+	operatorToken string
+	http          *http.Client
+}
+
+func NewClient(baseURL string, apiKey string, operatorToken string, timeout time.Duration, conns int) *Client {
+	return &Client{
+		url:           baseURL,
+		apiKey:        apiKey,
+		operatorToken: operatorToken,
+		http: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				MaxIdleConns:        conns,
+				MaxIdleConnsPerHost: conns,
+			},
+		},
+	}
+}
+
+type response struct {
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// do sends body as JSON and decodes the data of the response into out
+func (c *Client) do(ctx context.Context, method string, path string, body any, out any) (int, error) {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		payload = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, payload)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.operatorToken != "" {
+		req.Header.Set("X-Operator-Token", c.operatorToken)
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	var resp response
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil && err != io.EOF {
+		return res.StatusCode, fmt.Errorf("%s %s: %d: %w", method, path, res.StatusCode, err)
+	}
+	if res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("%s %s: %d: %s", method, path, res.StatusCode, resp.Message)
+	}
+	if out != nil && len(resp.Data) > 0 {
+		return res.StatusCode, json.Unmarshal(resp.Data, out)
+	}
+	return res.StatusCode, nil
+}
+
+// AddOrder waits for the engine to take the order, and returns its ID
+func (c *Client) AddOrder(ctx context.Context, o order.Order) (int, error) {
+	var data struct {
+		OrderID int `json:"order_id"`
+	}
+	_, err := c.do(ctx, http.MethodPost, "/add-order?timings=true", o, &data)
+	return data.OrderID, err
+}
+
+func (c *Client) CancelOrder(ctx context.Context, id int) error {
+	status, err := c.do(ctx, http.MethodDelete, "/order-book/"+strconv.Itoa(id), nil, nil)
+	if status == http.StatusNotFound {
+		return ErrOrderNotFound
+	}
+	return err
+}
+
+// CreateAccount opens an account in tenantId, it needs the key of the
+// operator
+func (c *Client) CreateAccount(ctx context.Context, tenantId string) (int, error) {
+	var data struct {
+		AccountID int `json:"account_id"`
+	}
+	_, err := c.do(ctx, http.MethodPost, "/admin/tenants/"+url.PathEscape(tenantId)+"/accounts", nil, &data)
+	return data.AccountID, err
+}
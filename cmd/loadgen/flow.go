@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math/rand/v2"
+	"order-book/order"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Operations of the flow
+const (
+	OP_ADD    = "add"
+	OP_CANCEL = "cancel"
+	OP_AMEND  = "amend"
+)
+
+// Flow draws the synthetic orders, and keeps those that were added to cancel
+// and amend them. An order that has been filled since stays in it, its
+// cancel is reported as not found.
+type Flow struct {
+	opts     Options
+	accounts []int
+
+	mu   sync.Mutex
+	open []order.Order
+}
+
+func NewFlow(opts Options, accounts []int) *Flow {
+	return &Flow{
+		opts:     opts,
+		accounts: accounts,
+	}
+}
+
+// Next draws the next operation, and takes the order it cancels or amends
+// off the open ones. It's an add while no order is open.
+func (f *Flow) Next() (string, order.Order) {
+	roll := rand.Float64()
+	if roll >= f.opts.CancelRatio+f.opts.AmendRatio {
+		return OP_ADD, f.newOrder()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.open) == 0 {
+		return OP_ADD, f.newOrder()
+	}
+	idx := rand.IntN(len(f.open))
+	o := f.open[idx]
+	f.open[idx] = f.open[len(f.open)-1]
+	f.open = f.open[:len(f.open)-1]
+	if roll < f.opts.CancelRatio {
+		return OP_CANCEL, o
+	}
+	return OP_AMEND, o
+}
+
+// Added keeps an order the engine accepted, with the ID it was given
+func (f *Flow) Added(o order.Order) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.open = append(f.open, o)
+}
+
+// Reprice is the amended order, at a new price
+func (f *Flow) Reprice(o order.Order) order.Order {
+	for _, p := range f.opts.Pairs {
+		if p.ID == o.PairID {
+			o.ID = 0
+			o.Price = f.price(p)
+			return o
+		}
+	}
+	return o
+}
+
+func (f *Flow) newOrder() order.Order {
+	p := f.opts.Pairs[rand.IntN(len(f.opts.Pairs))]
+	side := order.ASK
+	if rand.IntN(2) == 0 {
+		side = order.BID
+	}
+	amount := max(rand.NormFloat64()*f.opts.AmountStddev+f.opts.AmountMean, f.opts.MinAmount)
+	return order.Order{
+		PairID:    p.ID,
+		Type:      side,
+		Price:     f.price(p),
+		Amount:    decimal.NewFromFloat(amount).Round(int32(f.opts.AmountDecimals)),
+		AccountID: f.accounts[rand.IntN(len(f.accounts))],
+		CreatedAt: time.Now(),
+	}
+}
+
+func (f *Flow) price(p Pair) decimal.Decimal {
+	var price float64
+	if f.opts.Distribution == DIST_UNIFORM {
+		price = p.Mid + (rand.Float64()*2-1)*p.Spread
+	} else {
+		price = p.Mid + rand.NormFloat64()*p.Spread
+	}
+	tick := decimal.New(1, -int32(f.opts.PriceDecimals))
+	return decimal.Max(decimal.NewFromFloat(price).Round(int32(f.opts.PriceDecimals)), tick)
+}
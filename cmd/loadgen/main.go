@@ -0,0 +1,83 @@
+// Command loadgen fires synthetic order flow at the engine and reports the
+// throughput and latencies it achieved, for capacity testing releases.
+//
+//	go run ./cmd/loadgen -url http://localhost:5000 -rate 500 -duration 1m \
+//		-pairs btcusdt=65000:1200,ethusdt=3200:120 -accounts 100 -create-accounts \
+//		-cancel-ratio 0.3 -amend-ratio 0.1 -ws-subscribers 10
+//
+// Orders are added with POST /add-order and cancelled with DELETE
+// /order-book/:id. The engine has no amend, an amend is a cancel and an add
+// of the same order at a new price, timed as one. The websocket subscribers
+// follow the book of each pair and report the updates they received.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+	var opts Options
+	var pairs string
+	flag.StringVar(&opts.URL, "url", "http://localhost:5000", "base URL of the REST API")
+	flag.StringVar(&opts.WSURL, "ws-url", "", "base URL of the websockets, the REST URL with ws:// by default")
+	flag.StringVar(&opts.APIKey, "api-key", "", "X-API-Key sent with every request, none for the default tenant")
+	flag.StringVar(&opts.OperatorToken, "operator-token", os.Getenv("OPERATOR_TOKEN"), "X-Operator-Token, needed to create the accounts")
+	flag.StringVar(&pairs, "pairs", "btcusdt=65000:1200", "pairs to trade as pair=mid:spread, comma separated")
+	flag.StringVar(&opts.Distribution, "price-distribution", DIST_NORMAL, "normal, spread is the standard deviation, or uniform, spread is the distance to the mid")
+	flag.IntVar(&opts.PriceDecimals, "price-decimals", 2, "decimals the prices are rounded to, to fit the tick size")
+	flag.Float64Var(&opts.AmountMean, "amount", 2, "mean amount of an order")
+	flag.Float64Var(&opts.AmountStddev, "amount-stddev", 1.2, "standard deviation of the amounts")
+	flag.Float64Var(&opts.MinAmount, "min-amount", 0.01, "smallest amount of an order")
+	flag.IntVar(&opts.AmountDecimals, "amount-decimals", 4, "decimals the amounts are rounded to")
+	flag.Float64Var(&opts.CancelRatio, "cancel-ratio", 0.2, "share of the operations that cancel an open order")
+	flag.Float64Var(&opts.AmendRatio, "amend-ratio", 0.1, "share of the operations that amend an open order")
+	flag.IntVar(&opts.Accounts, "accounts", 10, "number of accounts placing orders")
+	flag.IntVar(&opts.FirstAccount, "first-account", 1, "ID of the first of the existing accounts to use")
+	flag.BoolVar(&opts.CreateAccounts, "create-accounts", false, "open the accounts with POST /admin/tenants/:tenant/accounts instead")
+	flag.StringVar(&opts.Tenant, "tenant", "default", "tenant the accounts are opened in")
+	flag.Float64Var(&opts.Rate, "rate", 100, "target operations per second")
+	flag.DurationVar(&opts.Duration, "duration", 30*time.Second, "how long to run")
+	flag.IntVar(&opts.Workers, "workers", 32, "concurrent requests at most")
+	flag.IntVar(&opts.WSSubscribers, "ws-subscribers", 0, "websocket subscribers per pair")
+	flag.DurationVar(&opts.Timeout, "timeout", 5*time.Second, "timeout of a request")
+	jsonReport := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	var err error
+	if opts.Pairs, err = parsePairs(pairs); err != nil {
+		fail(err)
+	}
+	if opts.WSURL == "" {
+		opts.WSURL = "ws" + strings.TrimPrefix(opts.URL, "http")
+	}
+	if err := opts.Validate(); err != nil {
+		fail(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	report, err := Run(ctx, opts)
+	if err != nil {
+		fail(err)
+	}
+	if *jsonReport {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "loadgen:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Price distributions of the orders around the mid of their pair
+const (
+	DIST_NORMAL  = "normal"
+	DIST_UNIFORM = "uniform"
+)
+
+// Pair is a pair the flow trades. Prices are drawn around Mid, Spread is the
+// standard deviation of a normal distribution or the half width of a
+// uniform one.
+type Pair struct {
+	ID     string
+	Mid    float64
+	Spread float64
+}
+
+type Options struct {
+	URL    string
+	WSURL  string
+	APIKey string
+	// OperatorToken is needed to create the accounts
+	OperatorToken string
+
+	Pairs          []Pair
+	Distribution   string
+	PriceDecimals  int
+	AmountMean     float64
+	AmountStddev   float64
+	MinAmount      float64
+	AmountDecimals int
+	CancelRatio    float64
+	AmendRatio     float64
+
+	Accounts       int
+	FirstAccount   int
+	CreateAccounts bool
+	Tenant         string
+
+	Rate          float64
+	Duration      time.Duration
+	Workers       int
+	WSSubscribers int
+	Timeout       time.Duration
+}
+
+func (o Options) Validate() error {
+	var errs []error
+	if len(o.Pairs) == 0 {
+		errs = append(errs, errors.New("-pairs is required"))
+	}
+	if o.Distribution != DIST_NORMAL && o.Distribution != DIST_UNIFORM {
+		errs = append(errs, errors.New("-price-distribution should be normal or uniform"))
+	}
+	if o.CancelRatio < 0 || o.AmendRatio < 0 || o.CancelRatio+o.AmendRatio > 1 {
+		errs = append(errs, errors.New("-cancel-ratio and -amend-ratio should be positive and add up to 1 at most"))
+	}
+	if o.AmountMean <= 0 || o.MinAmount <= 0 || o.AmountStddev < 0 {
+		errs = append(errs, errors.New("-amount and -min-amount should be positive"))
+	}
+	if o.Accounts <= 0 {
+		errs = append(errs, errors.New("-accounts should be positive"))
+	}
+	if o.Rate <= 0 || o.Duration <= 0 || o.Workers <= 0 {
+		errs = append(errs, errors.New("-rate, -duration and -workers should be positive"))
+	}
+	if o.WSSubscribers < 0 {
+		errs = append(errs, errors.New("-ws-subscribers can't be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// parsePairs reads a list of pair=mid:spread
+func parsePairs(s string) ([]Pair, error) {
+	var pairs []Pair
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		id, model, found := strings.Cut(item, "=")
+		mid, spread, found2 := strings.Cut(model, ":")
+		if !found || !found2 || id == "" {
+			return nil, fmt.Errorf("-pairs: %q should be pair=mid:spread", item)
+		}
+		p := Pair{ID: id}
+		var err error
+		if p.Mid, err = strconv.ParseFloat(mid, 64); err != nil || p.Mid <= 0 {
+			return nil, fmt.Errorf("-pairs: the mid of %s should be a positive number", id)
+		}
+		if p.Spread, err = strconv.ParseFloat(spread, 64); err != nil || p.Spread < 0 {
+			return nil, fmt.Errorf("-pairs: the spread of %s should be a number", id)
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs, nil
+}
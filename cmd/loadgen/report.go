@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// MAX_ERROR_KINDS bounds the distinct errors a report keeps, the others are
+// counted together
+const MAX_ERROR_KINDS = 20
+
+type opStats struct {
+	count     int
+	errors    int
+	notFound  int
+	latencies []time.Duration
+}
+
+// Recorder collects the outcome of the operations and of the websockets
+type Recorder struct {
+	mu         sync.Mutex
+	ops        map[string]*opStats
+	errs       map[string]int
+	wsMessages int
+	wsErrors   int
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		ops:  make(map[string]*opStats),
+		errs: make(map[string]int),
+	}
+}
+
+// Observe records an operation, the latency of those that failed is left out
+func (r *Recorder) Observe(op string, took time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.ops[op]
+	if !ok {
+		s = &opStats{}
+		r.ops[op] = s
+	}
+	s.count++
+	switch {
+	case err == ErrOrderNotFound:
+		s.notFound++
+	case err != nil:
+		s.errors++
+		r.addError(err.Error())
+	default:
+		s.latencies = append(s.latencies, took)
+	}
+}
+
+func (r *Recorder) WSMessage() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.wsMessages++
+}
+
+func (r *Recorder) WSError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.wsErrors++
+	r.addError("ws: " + err.Error())
+}
+
+func (r *Recorder) addError(msg string) {
+	if _, ok := r.errs[msg]; !ok && len(r.errs) >= MAX_ERROR_KINDS {
+		msg = "other errors"
+	}
+	r.errs[msg]++
+}
+
+// OpReport is the outcome of an operation, latencies are in milliseconds
+type OpReport struct {
+	Op       string  `json:"op"`
+	Count    int     `json:"count"`
+	Errors   int     `json:"errors"`
+	NotFound int     `json:"not_found"`
+	Rate     float64 `json:"rate"`
+	P50      float64 `json:"p50_ms"`
+	P90      float64 `json:"p90_ms"`
+	P99      float64 `json:"p99_ms"`
+	Max      float64 `json:"max_ms"`
+}
+
+type Report struct {
+	Seconds      float64        `json:"seconds"`
+	TargetRate   float64        `json:"target_rate"`
+	AchievedRate float64        `json:"achieved_rate"`
+	Ops          []OpReport     `json:"ops"`
+	WSMessages   int            `json:"ws_messages"`
+	WSRate       float64        `json:"ws_rate"`
+	WSErrors     int            `json:"ws_errors"`
+	Errors       map[string]int `json:"errors,omitempty"`
+}
+
+// Report sums up what was recorded over elapsed
+func (r *Recorder) Report(elapsed time.Duration, targetRate float64) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	secs := elapsed.Seconds()
+	report := Report{
+		Seconds:    secs,
+		TargetRate: targetRate,
+		WSMessages: r.wsMessages,
+		WSRate:     float64(r.wsMessages) / secs,
+		WSErrors:   r.wsErrors,
+		Errors:     r.errs,
+	}
+	total := 0
+	for _, op := range []string{OP_ADD, OP_CANCEL, OP_AMEND} {
+		s, ok := r.ops[op]
+		if !ok {
+			continue
+		}
+		total += s.count
+		slices.Sort(s.latencies)
+		report.Ops = append(report.Ops, OpReport{
+			Op:       op,
+			Count:    s.count,
+			Errors:   s.errors,
+			NotFound: s.notFound,
+			Rate:     float64(s.count) / secs,
+			P50:      percentile(s.latencies, 0.50),
+			P90:      percentile(s.latencies, 0.90),
+			P99:      percentile(s.latencies, 0.99),
+			Max:      percentile(s.latencies, 1),
+		})
+	}
+	report.AchievedRate = float64(total) / secs
+	return report
+}
+
+// percentile of sorted latencies, in milliseconds
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := min(int(p*float64(len(sorted))), len(sorted)-1)
+	return float64(sorted[idx].Microseconds()) / 1000
+}
+
+func (rep Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+func (rep Report) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "%.1fs, target %.1f ops/s, achieved %.1f ops/s\n\n", rep.Seconds, rep.TargetRate, rep.AchievedRate)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "op\tcount\terrors\tnot found\tops/s\tp50 ms\tp90 ms\tp99 ms\tmax ms\t")
+	for _, op := range rep.Ops {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f\t%.2f\t%.2f\t%.2f\t%.2f\t\n",
+			op.Op, op.Count, op.Errors, op.NotFound, op.Rate, op.P50, op.P90, op.P99, op.Max)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\nwebsocket: %d messages, %.1f/s, %d errors\n", rep.WSMessages, rep.WSRate, rep.WSErrors)
+	if len(rep.Errors) > 0 {
+		fmt.Fprintln(w, "\nerrors:")
+		for _, msg := range slices.Sorted(maps.Keys(rep.Errors)) {
+			fmt.Fprintf(w, "  %6d  %s\n", rep.Errors[msg], msg)
+		}
+	}
+	return nil
+}
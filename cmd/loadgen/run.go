@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"order-book/order"
+	"sync"
+	"time"
+
+	"github.com/fasthttp/websocket"
+)
+
+// Run opens the accounts, then fires the flow at opts.Rate for
+// opts.Duration. Once every worker is busy the flow waits for one, the
+// achieved rate tells how far it fell behind.
+func Run(ctx context.Context, opts Options) (Report, error) {
+	client := NewClient(opts.URL, opts.APIKey, opts.OperatorToken, opts.Timeout, opts.Workers)
+	accounts, err := openAccounts(ctx, client, opts)
+	if err != nil {
+		return Report{}, err
+	}
+	rec := NewRecorder()
+	flow := NewFlow(opts, accounts)
+
+	// The requests in flight when the time is up are waited for
+	dispatchCtx, stop := context.WithTimeout(ctx, opts.Duration)
+	defer stop()
+
+	var subscribers sync.WaitGroup
+	for _, p := range opts.Pairs {
+		for range opts.WSSubscribers {
+			subscribers.Add(1)
+			go func() {
+				defer subscribers.Done()
+				subscribe(dispatchCtx, opts, p.ID, rec)
+			}()
+		}
+	}
+
+	jobs := make(chan struct{})
+	var workers sync.WaitGroup
+	for range opts.Workers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for range jobs {
+				runOp(ctx, client, flow, rec)
+			}
+		}()
+	}
+
+	start := time.Now()
+	interval := time.Duration(float64(time.Second) / opts.Rate)
+	next := start
+dispatch:
+	for {
+		select {
+		case <-time.After(time.Until(next)):
+		case <-dispatchCtx.Done():
+			break dispatch
+		}
+		select {
+		case jobs <- struct{}{}:
+		case <-dispatchCtx.Done():
+			break dispatch
+		}
+		next = next.Add(interval)
+	}
+	close(jobs)
+	workers.Wait()
+	elapsed := time.Since(start)
+	subscribers.Wait()
+	return rec.Report(elapsed, opts.Rate), nil
+}
+
+func runOp(ctx context.Context, client *Client, flow *Flow, rec *Recorder) {
+	add := func(o order.Order) error {
+		id, err := client.AddOrder(ctx, o)
+		if err == nil {
+			o.ID = id
+			flow.Added(o)
+		}
+		return err
+	}
+
+	op, o := flow.Next()
+	began := time.Now()
+	var err error
+	switch op {
+	case OP_ADD:
+		err = add(o)
+	case OP_CANCEL:
+		err = client.CancelOrder(ctx, o.ID)
+	case OP_AMEND:
+		if err = client.CancelOrder(ctx, o.ID); err == nil {
+			err = add(flow.Reprice(o))
+		}
+	}
+	rec.Observe(op, time.Since(began), err)
+}
+
+func openAccounts(ctx context.Context, client *Client, opts Options) ([]int, error) {
+	accounts := make([]int, opts.Accounts)
+	for idx := range accounts {
+		if !opts.CreateAccounts {
+			accounts[idx] = opts.FirstAccount + idx
+			continue
+		}
+		accountId, err := client.CreateAccount(ctx, opts.Tenant)
+		if err != nil {
+			return nil, err
+		}
+		accounts[idx] = accountId
+	}
+	return accounts, nil
+}
+
+// subscribe follows the book of a pair until ctx is done
+func subscribe(ctx context.Context, opts Options, pairId string, rec *Recorder) {
+	header := http.Header{}
+	if opts.APIKey != "" {
+		header.Set("X-API-Key", opts.APIKey)
+	}
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, opts.WSURL+"/ws/order-book/"+url.PathEscape(pairId), header)
+	if err != nil {
+		rec.WSError(err)
+		return
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if ctx.Err() == nil {
+				rec.WSError(err)
+			}
+			return
+		}
+		rec.WSMessage()
+	}
+}
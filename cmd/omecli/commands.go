@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"order-book/marketdata"
+	"order-book/order"
+
+	"github.com/shopspring/decimal"
+)
+
+// print writes v as JSON with -json, or as text otherwise
+func (cli *CLI) print(v any, text func(w io.Writer)) {
+	if cli.json {
+		json.NewEncoder(os.Stdout).Encode(v)
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	text(tw)
+	tw.Flush()
+}
+
+// parse parses the flags of a command, and checks it got nargs arguments
+// or at least one with a negative nargs
+func parse(fs *flag.FlagSet, args []string, nargs int) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (nargs >= 0 && fs.NArg() != nargs) || (nargs < 0 && fs.NArg() == 0) {
+		return fmt.Errorf("usage: omecli %s", usages[fs.Name()])
+	}
+	return nil
+}
+
+func orderId(arg string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, fmt.Errorf("%q isn't an order ID", arg)
+	}
+	return id, nil
+}
+
+func submit(ctx context.Context, cli *CLI, args []string) error {
+	fs := flag.NewFlagSet("submit", flag.ContinueOnError)
+	accountId := fs.Int("account", 0, "account placing the order")
+	pairId := fs.String("pair", "", "pair of the order")
+	side := fs.String("side", "", "buy or sell")
+	price := fs.String("price", "", "limit price")
+	amount := fs.String("amount", "", "amount of the base asset")
+	postOnly := fs.Bool("post-only", false, "refuse the order if it would match on arrival")
+	tag := fs.String("tag", "", "free-form label echoed in the fills")
+	strategyId := fs.String("strategy", "", "strategy ID echoed in the fills")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	o := order.Order{AccountID: *accountId, PairID: *pairId, PostOnly: *postOnly, Tag: *tag, StrategyID: *strategyId}
+	switch *side {
+	case "buy":
+		o.Type = order.BID
+	case "sell":
+		o.Type = order.ASK
+	default:
+		return errors.New("-side should be buy or sell")
+	}
+	var err error
+	if o.Price, err = decimal.NewFromString(*price); err != nil {
+		return errors.New("-price should be a number")
+	}
+	if o.Amount, err = decimal.NewFromString(*amount); err != nil {
+		return errors.New("-amount should be a number")
+	}
+	id, err := cli.client.SubmitOrder(ctx, o)
+	if err != nil {
+		return err
+	}
+	cli.print(map[string]any{"order_id": id}, func(w io.Writer) {
+		fmt.Fprintf(w, "order %d submitted\n", id)
+	})
+	return nil
+}
+
+func cancel(ctx context.Context, cli *CLI, args []string) error {
+	fs := flag.NewFlagSet("cancel", flag.ContinueOnError)
+	if err := parse(fs, args, -1); err != nil {
+		return err
+	}
+	var failed int
+	for _, arg := range fs.Args() {
+		id, err := orderId(arg)
+		if err == nil {
+			err = cli.client.CancelOrder(ctx, id)
+		}
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "order %s: %s\n", arg, err)
+			continue
+		}
+		cli.print(map[string]any{"order_id": id, "cancelled": true}, func(w io.Writer) {
+			fmt.Fprintf(w, "order %d cancelled\n", id)
+		})
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of the orders weren't cancelled", failed)
+	}
+	return nil
+}
+
+func showOrder(ctx context.Context, cli *CLI, args []string) error {
+	fs := flag.NewFlagSet("order", flag.ContinueOnError)
+	accountId := fs.Int("account", 0, "account of the order")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	id, err := orderId(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	o, err := cli.client.GetOrder(ctx, *accountId, id)
+	if err != nil {
+		return err
+	}
+	cli.print(o, func(w io.Writer) {
+		fmt.Fprintf(w, "id\t%d\n", o.ID)
+		fmt.Fprintf(w, "account\t%d\n", o.AccountID)
+		fmt.Fprintf(w, "pair\t%s\n", o.PairID)
+		fmt.Fprintf(w, "side\t%s\n", sideName(o.Type))
+		fmt.Fprintf(w, "price\t%s\n", o.Price)
+		fmt.Fprintf(w, "amount\t%s\n", o.Amount)
+		fmt.Fprintf(w, "created\t%s\n", o.CreatedAt.Format(time.RFC3339Nano))
+	})
+	return nil
+}
+
+func history(ctx context.Context, cli *CLI, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	accountId := fs.Int("account", 0, "account of the order")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	id, err := orderId(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	events, err := cli.client.GetOrderHistory(ctx, *accountId, id)
+	if err != nil {
+		return err
+	}
+	cli.print(events, func(w io.Writer) {
+		fmt.Fprintln(w, "AT\tEVENT\tDETAILS")
+		for _, ev := range events {
+			details, _ := json.Marshal(ev.Metadata)
+			fmt.Fprintf(w, "%s\t%s\t%s\n", ev.CreatedAt.Format(time.RFC3339Nano), ev.Name, details)
+		}
+	})
+	return nil
+}
+
+func depth(ctx context.Context, cli *CLI, args []string) error {
+	fs := flag.NewFlagSet("depth", flag.ContinueOnError)
+	levels := fs.Int("levels", 10, "levels of each side, 0 for all")
+	watch := fs.Bool("watch", false, "print the depth again as it changes")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	show := func(d marketdata.Depth) {
+		if *levels > 0 {
+			d.Asks = d.Asks[:min(*levels, len(d.Asks))]
+			d.Bids = d.Bids[:min(*levels, len(d.Bids))]
+		}
+		cli.print(d, func(w io.Writer) {
+			fmt.Fprintf(w, "%s seq %d at %s\n", d.PairID, d.Seq, d.UpdatedAt.Format(time.RFC3339Nano))
+			fmt.Fprintln(w, "SIDE\tPRICE\tAMOUNT")
+			// Asks from the highest so the spread is in the middle
+			for _, level := range slices.Backward(d.Asks) {
+				fmt.Fprintf(w, "ask\t%s\t%s\n", level.Price, level.Amount)
+			}
+			for _, level := range d.Bids {
+				fmt.Fprintf(w, "bid\t%s\t%s\n", level.Price, level.Amount)
+			}
+		})
+	}
+	if *watch {
+		return cli.client.SubscribeDepth(ctx, fs.Arg(0), show)
+	}
+	d, err := cli.client.GetDepth(ctx, fs.Arg(0), *levels)
+	if err != nil {
+		return err
+	}
+	show(d)
+	return nil
+}
+
+func trades(ctx context.Context, cli *CLI, args []string) error {
+	fs := flag.NewFlagSet("trades", flag.ContinueOnError)
+	n := fs.Int("n", 20, "recent trades to print first")
+	follow := fs.Bool("f", false, "print the trades as they happen")
+	if err := parse(fs, args, 1); err != nil {
+		return err
+	}
+	show := func(t marketdata.PublicTrade) {
+		cli.print(t, func(w io.Writer) {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t.ExecutedAt.Format(time.RFC3339Nano), t.PairID, sideName(t.TakerSide), t.Price, t.Amount)
+		})
+	}
+	if *n > 0 {
+		recent, err := cli.client.GetRecentTrades(ctx, fs.Arg(0), *n)
+		if err != nil && !*follow {
+			return err
+		}
+		for _, t := range slices.Backward(recent) {
+			show(t)
+		}
+	}
+	if *follow {
+		return cli.client.SubscribeTrades(ctx, fs.Arg(0), show)
+	}
+	return nil
+}
+
+func pairs(ctx context.Context, cli *CLI, args []string) error {
+	if len(args) > 0 && args[0] == "set" {
+		return setPair(ctx, cli, args[1:])
+	}
+	if len(args) > 0 && args[0] == "delist" {
+		if len(args) != 2 {
+			return errors.New("usage: omecli pairs delist PAIR")
+		}
+		if err := cli.client.DelistPair(ctx, args[1]); err != nil {
+			return err
+		}
+		cli.print(map[string]any{"pair_id": args[1], "delisted": true}, func(w io.Writer) {
+			fmt.Fprintf(w, "%s delisted\n", args[1])
+		})
+		return nil
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("usage: omecli %s", usages["pairs"])
+	}
+
+	list, err := cli.client.ListPairs(ctx)
+	if err != nil {
+		return err
+	}
+	cli.print(list, func(w io.Writer) {
+		fmt.Fprintln(w, "PAIR\tBASE\tQUOTE\tTICK\tLOT\tMIN AMOUNT\tMIN NOTIONAL\tPRICE SCALE\tAMOUNT SCALE\tSTATUS\tHALTED")
+		for _, p := range list.Pairs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\t%t\n", p.ID, p.Base, p.Quote, p.TickSize, p.LotSize, p.MinAmount, p.MinNotional, p.PriceScale, p.AmountScale, p.Status, p.Halted)
+		}
+		if slices.Contains(list.Halted, order.ALL_PAIRS) {
+			fmt.Fprintln(w, "\ntrading is halted on every pair")
+		}
+	})
+	return nil
+}
+
+func setPair(ctx context.Context, cli *CLI, args []string) error {
+	fs := flag.NewFlagSet("pairs", flag.ContinueOnError)
+	base := fs.String("base", "", "base asset")
+	quote := fs.String("quote", "", "quote asset")
+	tick := fs.String("tick", "0", "tick size, 0 for none")
+	lot := fs.String("lot", "0", "lot size, 0 for none")
+	minAmount := fs.String("min-amount", "0", "minimum amount, 0 for none")
+	minNotional := fs.String("min-notional", "0", "minimum price times amount, 0 for none")
+	priceScale := fs.Int("price-scale", order.DefaultScale, "decimal places of the prices")
+	amountScale := fs.Int("amount-scale", order.DefaultScale, "decimal places of the amounts")
+	status := fs.String("status", order.PAIR_TRADING, "trading or halted")
+	makerFee := fs.String("maker-fee", "", "maker fee rate, the fee schedule's when empty")
+	takerFee := fs.String("taker-fee", "", "taker fee rate, the fee schedule's when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: omecli pairs set -base B -quote Q [-tick T] [-lot L] [-min-amount M] [-min-notional N] [-status S] PAIR")
+	}
+	p := order.Pair{
+		ID:          fs.Arg(0),
+		Base:        *base,
+		Quote:       *quote,
+		PriceScale:  int32(*priceScale),
+		AmountScale: int32(*amountScale),
+		Status:      *status,
+	}
+	decimals := []struct {
+		flag string
+		src  string
+		dst  *decimal.Decimal
+	}{
+		{"-tick", *tick, &p.TickSize},
+		{"-lot", *lot, &p.LotSize},
+		{"-min-amount", *minAmount, &p.MinAmount},
+		{"-min-notional", *minNotional, &p.MinNotional},
+	}
+	for _, d := range decimals {
+		v, err := decimal.NewFromString(d.src)
+		if err != nil {
+			return fmt.Errorf("%s should be a number", d.flag)
+		}
+		*d.dst = v
+	}
+	for flagName, fee := range map[string]struct {
+		src string
+		dst **float64
+	}{"-maker-fee": {*makerFee, &p.MakerFee}, "-taker-fee": {*takerFee, &p.TakerFee}} {
+		if fee.src == "" {
+			continue
+		}
+		rate, err := strconv.ParseFloat(fee.src, 64)
+		if err != nil {
+			return fmt.Errorf("%s should be a number", flagName)
+		}
+		*fee.dst = &rate
+	}
+	if err := cli.client.PutPair(ctx, p); err != nil {
+		return err
+	}
+	cli.print(p, func(w io.Writer) {
+		fmt.Fprintf(w, "%s listed\n", p.ID)
+	})
+	return nil
+}
+
+func halt(ctx context.Context, cli *CLI, args []string) error {
+	return setHalt(ctx, cli, args, cli.client.Halt)
+}
+
+func resume(ctx context.Context, cli *CLI, args []string) error {
+	return setHalt(ctx, cli, args, cli.client.Resume)
+}
+
+func setHalt(ctx context.Context, cli *CLI, args []string, fn func(ctx context.Context, pairId string) ([]string, error)) error {
+	if len(args) > 1 {
+		return errors.New("usage: omecli halt|resume [PAIR]")
+	}
+	pairId := order.ALL_PAIRS
+	if len(args) == 1 {
+		pairId = args[0]
+	}
+	halted, err := fn(ctx, pairId)
+	if err != nil {
+		return err
+	}
+	cli.print(map[string]any{"halted": halted}, func(w io.Writer) {
+		if len(halted) == 0 {
+			fmt.Fprintln(w, "no pair is halted")
+			return
+		}
+		fmt.Fprintf(w, "halted: %s\n", strings.ReplaceAll(strings.Join(halted, ", "), order.ALL_PAIRS, "every pair"))
+	})
+	return nil
+}
+
+func sideName(t order.OrderType) string {
+	if t == order.BID {
+		return "buy"
+	}
+	return "sell"
+}
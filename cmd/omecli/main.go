@@ -0,0 +1,108 @@
+// Command omecli trades on and operates the engine from the command line.
+//
+//	omecli [-url URL] [-api-key KEY] [-json] <command> [flags] [args]
+//
+//	submit -account 1 -pair btcusdt -side buy -price 65000 -amount 0.5
+//	cancel ORDER_ID...
+//	order -account 1 ORDER_ID          the order
+//	history -account 1 ORDER_ID        the history events of the order
+//	depth [-levels 10] [-watch] PAIR   the depth of a pair, updated with -watch
+//	trades [-n 20] [-f] PAIR           the recent trades, followed with -f
+//	pairs                              the listed pairs and the halts
+//	pairs set -base btc -quote usdt [-tick 0.01] [-min-amount 0.0001] PAIR
+//	pairs delist PAIR
+//	halt [PAIR]                        halts a pair, every pair without one
+//	resume [PAIR]                      lifts the halt
+//
+// The URL and the API key default to OME_URL and OME_API_KEY. pairs, halt
+// and resume need the key of the operator.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"order-book/client"
+)
+
+// usages is kept apart from commands so the commands can print their own
+var usages = map[string]string{
+	"submit":  "submit -account ID -pair PAIR -side buy|sell -price P -amount A [-post-only] [-tag T] [-strategy S]",
+	"cancel":  "cancel ORDER_ID...",
+	"order":   "order -account ID ORDER_ID",
+	"history": "history -account ID ORDER_ID",
+	"depth":   "depth [-levels N] [-watch] PAIR",
+	"trades":  "trades [-n N] [-f] PAIR",
+	"pairs":   "pairs [set|delist] ...",
+	"halt":    "halt [PAIR]",
+	"resume":  "resume [PAIR]",
+}
+
+var commands = map[string]func(ctx context.Context, cli *CLI, args []string) error{
+	"submit":  submit,
+	"cancel":  cancel,
+	"order":   showOrder,
+	"history": history,
+	"depth":   depth,
+	"trades":  trades,
+	"pairs":   pairs,
+	"halt":    halt,
+	"resume":  resume,
+}
+
+// CLI is the client and the output format the commands share
+type CLI struct {
+	client *client.Client
+	json   bool
+}
+
+func main() {
+	url := flag.String("url", envOr("OME_URL", "http://localhost:5000"), "base URL of the engine")
+	wsURL := flag.String("ws-url", os.Getenv("OME_WS_URL"), "base URL of the websockets, the URL with ws:// by default")
+	apiKey := flag.String("api-key", os.Getenv("OME_API_KEY"), "API key, none for the default tenant")
+	operatorToken := flag.String("operator-token", os.Getenv("OME_OPERATOR_TOKEN"), "operator token, needed by the pair and halt commands")
+	jsonOut := flag.Bool("json", false, "print JSON")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	run, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "omecli: unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	cli := &CLI{
+		client: client.New(*url, client.Options{APIKey: *apiKey, OperatorToken: *operatorToken, WSURL: *wsURL}),
+		json:   *jsonOut,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, cli, flag.Args()[1:]); err != nil && ctx.Err() == nil {
+		fmt.Fprintln(os.Stderr, "omecli:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: omecli [flags] <command> [flags] [args]\n\nflags:")
+	flag.PrintDefaults()
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, name := range []string{"submit", "cancel", "order", "history", "depth", "trades", "pairs", "halt", "resume"} {
+		fmt.Fprintln(os.Stderr, "  "+usages[name])
+	}
+}
+
+func envOr(name string, fallback string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,138 @@
+// Command tui shows the order book of a pair live in the terminal, the
+// ladder of its depth, the spread and the last trades, from the websocket
+// streams of the engine.
+//
+//	tui [-url URL] [-ws-url URL] [-api-key KEY] [-trades 30] [-refresh 100ms] PAIR
+//
+// The URLs and the API key default to OME_URL, OME_WS_URL and OME_API_KEY.
+// q or Ctrl-C quits.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"order-book/client"
+
+	"golang.org/x/term"
+)
+
+func main() {
+	url := flag.String("url", envOr("OME_URL", "http://localhost:5000"), "base URL of the engine")
+	wsURL := flag.String("ws-url", os.Getenv("OME_WS_URL"), "base URL of the websockets, the URL with ws:// by default")
+	apiKey := flag.String("api-key", os.Getenv("OME_API_KEY"), "API key, none for the default tenant")
+	maxTrades := flag.Int("trades", 30, "number of last trades kept")
+	refresh := flag.Duration("refresh", 100*time.Millisecond, "least time between two frames")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: tui [flags] PAIR\n\nflags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	pairId := flag.Arg(0)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, stop, pairId, *url, *wsURL, *apiKey, *maxTrades, *refresh); err != nil && ctx.Err() == nil {
+		fail(err)
+	}
+}
+
+// run draws the view of pairId until ctx is done, quit is called on q. The
+// terminal is restored when it returns.
+func run(ctx context.Context, quit func(), pairId string, url string, wsURL string, apiKey string, maxTrades int, refresh time.Duration) error {
+	view := NewView(pairId, maxTrades)
+	cli := client.New(url, client.Options{
+		APIKey: apiKey,
+		WSURL:  wsURL,
+		OnReconnect: func(stream string, err error) {
+			view.SetStatus(fmt.Sprintf("%s reconnected after: %v", stream, err))
+		},
+	})
+
+	// The stream only has the trades to come
+	recent, err := cli.GetRecentTrades(ctx, pairId, maxTrades)
+	if err != nil {
+		return err
+	}
+	for _, t := range slices.Backward(recent) {
+		view.AddTrade(t)
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- cli.SubscribeDepth(ctx, pairId, view.SetDepth) }()
+	go func() { errs <- cli.SubscribeTrades(ctx, pairId, view.AddTrade) }()
+
+	out := os.Stdout
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		// Raw mode reads q without enter, Ctrl-C comes in as a key too
+		state, err := term.MakeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			return err
+		}
+		defer term.Restore(int(os.Stdin.Fd()), state)
+		go readKeys(quit)
+	}
+	fmt.Fprint(out, ALT_SCREEN)
+	defer fmt.Fprint(out, MAIN_SCREEN)
+
+	// Frames are drawn on changes at most every refresh, and every second
+	// for the clock and a resized terminal
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		width, height, err := term.GetSize(int(out.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+		fmt.Fprint(out, view.Render(width, height))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			return err
+		case <-view.Changed():
+			time.Sleep(refresh)
+		case <-tick.C:
+		}
+	}
+}
+
+// readKeys calls quit on q or Ctrl-C
+func readKeys(quit func()) {
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, key := range buf[:n] {
+			if key == 'q' || key == 'Q' || key == 0x03 {
+				quit()
+				return
+			}
+		}
+	}
+}
+
+func envOr(name string, fallback string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
+	}
+	return fallback
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "tui:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"order-book/marketdata"
+	"order-book/order"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ANSI sequences of the screen
+const (
+	ALT_SCREEN   = "\x1b[?1049h\x1b[?25l"
+	MAIN_SCREEN  = "\x1b[?25h\x1b[?1049l"
+	HOME         = "\x1b[H"
+	CLEAR_LINE   = "\x1b[K"
+	CLEAR_BELOW  = "\x1b[J"
+	RESET        = "\x1b[0m"
+	BOLD         = "\x1b[1m"
+	DIM          = "\x1b[2m"
+	RED          = "\x1b[31m"
+	GREEN        = "\x1b[32m"
+	REVERSE_BOLD = "\x1b[1;7m"
+)
+
+// View is what the streams of a pair reported so far, updated from their
+// goroutines and drawn from the main one
+type View struct {
+	mu     sync.Mutex
+	pairId string
+	depth  marketdata.Depth
+	// prev is the depth last drawn, the levels changed since are highlighted
+	prev     marketdata.Depth
+	trades   []marketdata.PublicTrade
+	maxTrade int
+	updates  int
+	status   string
+	changed  chan struct{}
+}
+
+func NewView(pairId string, maxTrades int) *View {
+	return &View{
+		pairId:   pairId,
+		depth:    marketdata.Depth{PairID: pairId},
+		maxTrade: maxTrades,
+		status:   "connecting",
+		changed:  make(chan struct{}, 1),
+	}
+}
+
+// Changed is signalled once the view changed since it was last drawn
+func (v *View) Changed() <-chan struct{} {
+	return v.changed
+}
+
+func (v *View) signal() {
+	select {
+	case v.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (v *View) SetDepth(d marketdata.Depth) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.depth = d
+	v.updates++
+	v.status = "live"
+	v.signal()
+}
+
+// AddTrade keeps the latest trades, the most recent first. A trade of the
+// stream can also be in the recent trades loaded at startup.
+func (v *View) AddTrade(t marketdata.PublicTrade) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if slices.ContainsFunc(v.trades, func(kept marketdata.PublicTrade) bool { return kept.ID == t.ID }) {
+		return
+	}
+	v.trades = slices.Insert(v.trades, 0, t)
+	v.trades = v.trades[:min(len(v.trades), v.maxTrade)]
+	v.signal()
+}
+
+func (v *View) SetStatus(status string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.status = status
+	v.signal()
+}
+
+// Render draws the view on a screen of width by height cells. The ladder
+// holds the asks above the spread and the bids below it, the trades are on
+// its right on a wide screen and below it otherwise.
+func (v *View) Render(width int, height int) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	changed := changedLevels(v.prev, v.depth)
+	v.prev = v.depth
+
+	var b strings.Builder
+	b.WriteString(HOME)
+	header := fmt.Sprintf("%s%s%s  seq %d  %d updates  %s  %s", BOLD, v.pairId, RESET, v.depth.Seq, v.updates, v.status, time.Now().Format("15:04:05"))
+	line(&b, header)
+	line(&b, v.summary())
+	line(&b, "")
+
+	// 3 lines of header, the spread, the column names and the keys
+	side := width >= 100
+	rows := height - 6
+	if !side {
+		rows = (height - 8) * 2 / 3
+	}
+	ladder := v.ladder(max(rows/2, 1), changed)
+	trades := v.tradeLines()
+	if side {
+		for idx := range max(len(ladder), len(trades)) {
+			left, right := "", ""
+			if idx < len(ladder) {
+				left = ladder[idx]
+			}
+			if idx < len(trades) && idx < rows+1 {
+				right = trades[idx]
+			}
+			line(&b, pad(left, 62)+right)
+		}
+	} else {
+		for _, l := range ladder {
+			line(&b, l)
+		}
+		line(&b, "")
+		for _, l := range trades[:min(len(trades), max(height-len(ladder)-6, 0))] {
+			line(&b, l)
+		}
+	}
+	line(&b, "")
+	line(&b, DIM+"q quit"+RESET)
+	b.WriteString(CLEAR_BELOW)
+	return b.String()
+}
+
+// summary is the best prices, the spread and the last trade
+func (v *View) summary() string {
+	var parts []string
+	if len(v.depth.Bids) > 0 && len(v.depth.Asks) > 0 {
+		bid, ask := v.depth.Bids[0].Price, v.depth.Asks[0].Price
+		spread := ask.Sub(bid)
+		mid := ask.Add(bid).Div(decimal.NewFromInt(2))
+		parts = append(parts,
+			fmt.Sprintf("bid %s%s%s", GREEN, bid, RESET),
+			fmt.Sprintf("ask %s%s%s", RED, ask, RESET),
+			fmt.Sprintf("spread %s (%s bps)", spread, spread.Div(mid).Mul(decimal.NewFromInt(10000)).StringFixed(1)),
+			fmt.Sprintf("mid %s", mid),
+		)
+	} else {
+		parts = append(parts, "one-sided book")
+	}
+	if len(v.trades) > 0 {
+		t := v.trades[0]
+		parts = append(parts, fmt.Sprintf("last %s%s%s", sideColor(t.TakerSide), t.Price, RESET))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// ladder lists n levels of each side with their running total, the depth
+// bar is scaled to the largest total shown
+func (v *View) ladder(n int, changed map[string]bool) []string {
+	asks := v.depth.Asks[:min(n, len(v.depth.Asks))]
+	bids := v.depth.Bids[:min(n, len(v.depth.Bids))]
+	askTotals, bidTotals := totals(asks), totals(bids)
+	largest := decimal.Zero
+	if len(askTotals) > 0 {
+		largest = decimal.Max(largest, askTotals[len(askTotals)-1])
+	}
+	if len(bidTotals) > 0 {
+		largest = decimal.Max(largest, bidTotals[len(bidTotals)-1])
+	}
+
+	lines := []string{BOLD + fmt.Sprintf("%-6s %16s %16s %16s", "SIDE", "PRICE", "AMOUNT", "TOTAL") + RESET}
+	// Asks from the highest so the spread is in the middle
+	for idx := n - 1; idx >= 0; idx-- {
+		if idx >= len(asks) {
+			lines = append(lines, "")
+			continue
+		}
+		lines = append(lines, level("ask", RED, asks[idx], askTotals[idx], largest, changed["a"+asks[idx].Price.String()]))
+	}
+	spread := "-"
+	if len(asks) > 0 && len(bids) > 0 {
+		spread = asks[0].Price.Sub(bids[0].Price).String()
+	}
+	lines = append(lines, DIM+fmt.Sprintf("%-6s %16s", "", "spread "+spread)+RESET)
+	for idx := range bids {
+		lines = append(lines, level("bid", GREEN, bids[idx], bidTotals[idx], largest, changed["b"+bids[idx].Price.String()]))
+	}
+	return lines
+}
+
+func (v *View) tradeLines() []string {
+	lines := []string{BOLD + fmt.Sprintf("%-12s %-4s %16s %16s", "TIME", "SIDE", "PRICE", "AMOUNT") + RESET}
+	for _, t := range v.trades {
+		lines = append(lines, sideColor(t.TakerSide)+fmt.Sprintf("%-12s %-4s %16s %16s",
+			t.ExecutedAt.Local().Format("15:04:05.000"), sideName(t.TakerSide), t.Price, t.Amount)+RESET)
+	}
+	return lines
+}
+
+func level(name string, color string, l marketdata.Level, total decimal.Decimal, largest decimal.Decimal, changed bool) string {
+	const barWidth = 10
+	bar := 0
+	if largest.IsPositive() {
+		bar = int(total.Mul(decimal.NewFromInt(barWidth)).Div(largest).Ceil().IntPart())
+	}
+	style := color
+	if changed {
+		style = REVERSE_BOLD + color
+	}
+	return style + fmt.Sprintf("%-6s %16s %16s %16s", name, l.Price, l.Amount, total) + RESET +
+		" " + color + strings.Repeat("█", bar) + RESET
+}
+
+// totals is the running sum of the amounts from the best level
+func totals(levels []marketdata.Level) []decimal.Decimal {
+	sums := make([]decimal.Decimal, len(levels))
+	sum := decimal.Zero
+	for idx, l := range levels {
+		sum = sum.Add(l.Amount)
+		sums[idx] = sum
+	}
+	return sums
+}
+
+// changedLevels keys the levels of next added or resized since prev by side
+// and price
+func changedLevels(prev marketdata.Depth, next marketdata.Depth) map[string]bool {
+	changed := make(map[string]bool)
+	if prev.Seq == next.Seq {
+		return changed
+	}
+	for _, side := range []struct {
+		key        string
+		prev, next []marketdata.Level
+	}{{"a", prev.Asks, next.Asks}, {"b", prev.Bids, next.Bids}} {
+		amounts := make(map[string]decimal.Decimal, len(side.prev))
+		for _, l := range side.prev {
+			amounts[l.Price.String()] = l.Amount
+		}
+		for _, l := range side.next {
+			if amount, ok := amounts[l.Price.String()]; !ok || !amount.Equal(l.Amount) {
+				changed[side.key+l.Price.String()] = true
+			}
+		}
+	}
+	return changed
+}
+
+// line writes a line of the screen, clearing what the previous frame left
+func line(b *strings.Builder, s string) {
+	b.WriteString(s)
+	b.WriteString(CLEAR_LINE + "\r\n")
+}
+
+// pad fills s up to width visible cells, the escape sequences take none
+func pad(s string, width int) string {
+	visible := 0
+	escaped := false
+	for _, r := range s {
+		switch {
+		case r == '\x1b':
+			escaped = true
+		case escaped:
+			escaped = r < '@' || r > '~'
+		default:
+			visible++
+		}
+	}
+	if visible >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-visible)
+}
+
+func sideColor(t order.OrderType) string {
+	if t == order.BID {
+		return GREEN
+	}
+	return RED
+}
+
+func sideName(t order.OrderType) string {
+	if t == order.BID {
+		return "buy"
+	}
+	return "sell"
+}
@@ -0,0 +1,345 @@
+// Package conformance checks a deployment of the engine behaves like an
+// exchange should, over its public API only, so operators can validate a
+// custom build before it takes traffic:
+//
+//	go test ./conformance -url https://engine.example.com -api-key KEY
+//
+// or from a test of their own with Run. The checks trade on Target.Pair at
+// prices from Target.Price up, from accounts they open, the pair should see
+// no other orders at those prices while they run. db.driver memory keeps no
+// order history, cancel_semantics fails on it.
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"order-book/client"
+	"order-book/order"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Target is the deployment under test
+type Target struct {
+	Client *client.Client
+	Pair   string
+	// Price is that of the first check, each check trades a Tick above the
+	// previous one so a check never fills the orders another left behind
+	Price decimal.Decimal
+	Tick  decimal.Decimal
+	// Amount is the unit of the orders, a valid amount of the pair
+	Amount decimal.Decimal
+	// NewAccount opens the accounts of the checks, those of the default
+	// tenant with the key of the operator when nil
+	NewAccount func(ctx context.Context) (int, error)
+	// Timeout bounds each check, 30s when 0
+	Timeout time.Duration
+}
+
+// Check is a behavior of the engine, price is the price it trades at
+type Check struct {
+	Name string
+	Run  func(ctx context.Context, target *Target, price decimal.Decimal) error
+}
+
+var Checks = []Check{
+	{"fifo_priority", fifoPriority},
+	{"no_self_trade", noSelfTrade},
+	{"partial_fill_accounting", partialFillAccounting},
+	{"cancel_semantics", cancelSemantics},
+	{"idempotent_deposits", idempotentDeposits},
+}
+
+// Run runs every check as a subtest of t
+func Run(t *testing.T, target Target) {
+	if target.NewAccount == nil {
+		target.NewAccount = func(ctx context.Context) (int, error) {
+			return target.Client.CreateAccount(ctx, "default")
+		}
+	}
+	if target.Timeout <= 0 {
+		target.Timeout = 30 * time.Second
+	}
+	for idx, check := range Checks {
+		price := target.Price.Add(target.Tick.Mul(decimal.NewFromInt(int64(idx))))
+		t.Run(check.Name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), target.Timeout)
+			defer cancel()
+			if err := check.Run(ctx, &target, price); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// units is n times the unit amount
+func (target *Target) units(n int64) decimal.Decimal {
+	return target.Amount.Mul(decimal.NewFromInt(n))
+}
+
+func (target *Target) accounts(ctx context.Context, n int) ([]int, error) {
+	accounts := make([]int, n)
+	for idx := range accounts {
+		accountId, err := target.NewAccount(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open an account: %w", err)
+		}
+		accounts[idx] = accountId
+	}
+	return accounts, nil
+}
+
+func (target *Target) submit(ctx context.Context, accountId int, side order.OrderType, price decimal.Decimal, amount decimal.Decimal) (int, error) {
+	id, err := target.Client.SubmitOrder(ctx, order.Order{
+		AccountID: accountId,
+		PairID:    target.Pair,
+		Type:      side,
+		Price:     price,
+		Amount:    amount,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("account %d: failed to submit an order: %w", accountId, err)
+	}
+	return id, nil
+}
+
+// trades are those of an account on the pair. SubmitOrder returns once the
+// engine recorded the fills, no wait is needed.
+func (target *Target) trades(ctx context.Context, accountId int) ([]order.Trade, error) {
+	trades, err := target.Client.GetTrades(ctx, accountId, target.Pair)
+	if err != nil {
+		return nil, fmt.Errorf("account %d: failed to get the trades: %w", accountId, err)
+	}
+	return trades, nil
+}
+
+// cancelAll pulls orders a check left resting, those filled are ignored
+func (target *Target) cancelAll(ctx context.Context, ids ...int) {
+	for _, id := range ids {
+		target.Client.CancelOrder(context.WithoutCancel(ctx), id)
+	}
+}
+
+// eventually retries fn until it succeeds or ctx is done, for what the
+// engine writes in the background
+func eventually(ctx context.Context, fn func() error) error {
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// fifoPriority: orders resting at a price fill in the order they arrived
+func fifoPriority(ctx context.Context, target *Target, price decimal.Decimal) error {
+	accounts, err := target.accounts(ctx, 3)
+	if err != nil {
+		return err
+	}
+	first, err := target.submit(ctx, accounts[0], order.ASK, price, target.units(1))
+	if err != nil {
+		return err
+	}
+	second, err := target.submit(ctx, accounts[1], order.ASK, price, target.units(1))
+	if err != nil {
+		return err
+	}
+	defer target.cancelAll(ctx, first, second)
+	if _, err := target.submit(ctx, accounts[2], order.BID, price, target.units(1)); err != nil {
+		return err
+	}
+
+	trades, err := target.trades(ctx, accounts[2])
+	if err != nil {
+		return err
+	}
+	if len(trades) != 1 || trades[0].MakerOrderID != first {
+		return fmt.Errorf("the taker should fill the order that arrived first, %d, got the trades %+v", first, trades)
+	}
+	if err := target.Client.CancelOrder(ctx, second); err != nil {
+		return fmt.Errorf("the order that arrived second should be resting untouched: %w", err)
+	}
+	return nil
+}
+
+// noSelfTrade: an order never fills an order of its own account
+func noSelfTrade(ctx context.Context, target *Target, price decimal.Decimal) error {
+	accounts, err := target.accounts(ctx, 1)
+	if err != nil {
+		return err
+	}
+	ask, err := target.submit(ctx, accounts[0], order.ASK, price, target.units(1))
+	if err != nil {
+		return err
+	}
+	bid, err := target.submit(ctx, accounts[0], order.BID, price, target.units(1))
+	if err != nil {
+		return err
+	}
+	defer target.cancelAll(ctx, ask, bid)
+
+	trades, err := target.trades(ctx, accounts[0])
+	if err != nil {
+		return err
+	}
+	if len(trades) > 0 {
+		return fmt.Errorf("the account traded with itself: %+v", trades)
+	}
+	for _, id := range []int{ask, bid} {
+		if err := target.Client.CancelOrder(ctx, id); err != nil {
+			return fmt.Errorf("order %d should be resting: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// partialFillAccounting: a resting order fills in parts, each trade is the
+// amount taken and what's left stays on the book until cancelled
+func partialFillAccounting(ctx context.Context, target *Target, price decimal.Decimal) error {
+	accounts, err := target.accounts(ctx, 4)
+	if err != nil {
+		return err
+	}
+	maker, err := target.submit(ctx, accounts[0], order.ASK, price, target.units(3))
+	if err != nil {
+		return err
+	}
+	defer target.cancelAll(ctx, maker)
+	for _, taker := range accounts[1:3] {
+		if _, err := target.submit(ctx, taker, order.BID, price, target.units(1)); err != nil {
+			return err
+		}
+	}
+
+	trades, err := target.trades(ctx, accounts[0])
+	if err != nil {
+		return err
+	}
+	filled := decimal.Zero
+	for _, t := range trades {
+		if t.MakerOrderID != maker || !t.Price.Equal(price) || !t.Amount.Equal(target.units(1)) {
+			return fmt.Errorf("each taker should fill a unit of order %d at %s, got the trade %+v", maker, price, t)
+		}
+		filled = filled.Add(t.Amount)
+	}
+	if !filled.Equal(target.units(2)) {
+		return fmt.Errorf("order %d should have %s filled, got %s in %d trades", maker, target.units(2), filled, len(trades))
+	}
+
+	if err := target.Client.CancelOrder(ctx, maker); err != nil {
+		return fmt.Errorf("the rest of order %d should be resting: %w", maker, err)
+	}
+	late, err := target.submit(ctx, accounts[3], order.BID, price, target.units(1))
+	if err != nil {
+		return err
+	}
+	defer target.cancelAll(ctx, late)
+	if trades, err := target.trades(ctx, accounts[3]); err != nil || len(trades) > 0 {
+		return fmt.Errorf("the cancelled rest of order %d shouldn't fill, got the trades %+v (%v)", maker, trades, err)
+	}
+	return nil
+}
+
+// cancelSemantics: a cancelled order leaves the book and is recorded as
+// such, it can't be cancelled again nor can a filled order
+func cancelSemantics(ctx context.Context, target *Target, price decimal.Decimal) error {
+	accounts, err := target.accounts(ctx, 3)
+	if err != nil {
+		return err
+	}
+	cancelled, err := target.submit(ctx, accounts[0], order.ASK, price, target.units(1))
+	if err != nil {
+		return err
+	}
+	if err := target.Client.CancelOrder(ctx, cancelled); err != nil {
+		return fmt.Errorf("failed to cancel resting order %d: %w", cancelled, err)
+	}
+	if err := target.Client.CancelOrder(ctx, cancelled); !errors.Is(err, client.ErrOrderNotFound) {
+		return fmt.Errorf("order %d was cancelled again, got %v", cancelled, err)
+	}
+
+	filled, err := target.submit(ctx, accounts[1], order.ASK, price, target.units(1))
+	if err != nil {
+		return err
+	}
+	defer target.cancelAll(ctx, filled)
+	if _, err := target.submit(ctx, accounts[2], order.BID, price, target.units(1)); err != nil {
+		return err
+	}
+	trades, err := target.trades(ctx, accounts[2])
+	if err != nil {
+		return err
+	}
+	if len(trades) != 1 || trades[0].MakerOrderID != filled {
+		return fmt.Errorf("the taker should only fill order %d, cancelled %d is gone, got the trades %+v", filled, cancelled, trades)
+	}
+	if err := target.Client.CancelOrder(ctx, filled); !errors.Is(err, client.ErrOrderNotFound) {
+		return fmt.Errorf("filled order %d was cancelled, got %v", filled, err)
+	}
+
+	// The history is written in batches
+	return eventually(ctx, func() error {
+		events, err := target.Client.GetOrderHistory(ctx, accounts[0], cancelled)
+		if err != nil {
+			return err
+		}
+		for _, ev := range events {
+			if ev.Name == order.ORDER_CANCELLED {
+				return nil
+			}
+		}
+		return fmt.Errorf("the history of order %d has no %s event", cancelled, order.ORDER_CANCELLED)
+	})
+}
+
+// idempotentDeposits: a deposit sent again with its idempotency key is
+// applied once, the key can't be used for another deposit
+func idempotentDeposits(ctx context.Context, target *Target, price decimal.Decimal) error {
+	accounts, err := target.accounts(ctx, 1)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("conformance-%d-%d", accounts[0], time.Now().UnixNano())
+	first, replayed, err := target.Client.Deposit(ctx, accounts[0], "usdt", 100, key)
+	if err != nil {
+		return fmt.Errorf("failed to deposit: %w", err)
+	}
+	if replayed {
+		return errors.New("the first deposit with a key was reported as replayed")
+	}
+	again, replayed, err := target.Client.Deposit(ctx, accounts[0], "usdt", 100, key)
+	if err != nil {
+		return fmt.Errorf("failed to send the deposit again: %w", err)
+	}
+	if !replayed || again.ID != first.ID {
+		return fmt.Errorf("the deposit sent again should replay entry %d, got entry %d replayed %t", first.ID, again.ID, replayed)
+	}
+	var apiErr *client.APIError
+	if _, _, err := target.Client.Deposit(ctx, accounts[0], "usdt", 50, key); !errors.As(err, &apiErr) || apiErr.Status != http.StatusConflict {
+		return fmt.Errorf("another deposit with the key should be refused with 409, got %v", err)
+	}
+
+	balances, err := target.Client.GetBalances(ctx, accounts[0])
+	if err != nil {
+		return err
+	}
+	for _, b := range balances {
+		if b.Asset == "usdt" {
+			if !b.Available.Add(b.Held).Equal(decimal.NewFromInt(100)) {
+				return fmt.Errorf("the balance should be credited once, got %v available and %v held", b.Available, b.Held)
+			}
+			return nil
+		}
+	}
+	return errors.New("the deposit isn't in the balances of the account")
+}
@@ -0,0 +1,33 @@
+package conformance
+
+import (
+	"flag"
+	"order-book/client"
+	"os"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	url    = flag.String("url", os.Getenv("OME_URL"), "base URL of the deployment under test, skipped without one")
+	apiKey = flag.String("api-key", os.Getenv("OME_API_KEY"), "API key of the operator")
+	token  = flag.String("operator-token", os.Getenv("OME_OPERATOR_TOKEN"), "operator token of the deployment")
+	pair   = flag.String("pair", "btcusdt", "pair the checks trade on")
+	price  = flag.String("price", "1000", "price of the first check")
+	tick   = flag.String("tick", "1", "price step between the checks")
+	amount = flag.String("amount", "0.01", "unit amount of the orders")
+)
+
+func TestConformance(t *testing.T) {
+	if *url == "" {
+		t.Skip("no deployment to check, set -url or OME_URL")
+	}
+	Run(t, Target{
+		Client: client.New(*url, client.Options{APIKey: *apiKey, OperatorToken: *token}),
+		Pair:   *pair,
+		Price:  decimal.RequireFromString(*price),
+		Tick:   decimal.RequireFromString(*tick),
+		Amount: decimal.RequireFromString(*amount),
+	})
+}
@@ -0,0 +1,66 @@
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	fibercors "github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/websocket/v2"
+)
+
+// Policy is who browsers let call the API from another origin. An allowed
+// origin is "*" for any, an exact origin like https://app.example.com, or
+// https://*.example.com for its subdomains.
+type Policy struct {
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// Allowed reports whether the policy allows origin
+func (p Policy) Allowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range p.AllowOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		scheme, domain, ok := strings.Cut(allowed, "://*.")
+		if ok && strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(origin, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware answers the preflight requests and adds the CORS headers to the
+// responses to allowed origins
+func (p Policy) Middleware() fiber.Handler {
+	return fibercors.New(fibercors.Config{
+		AllowOriginsFunc: p.Allowed,
+		AllowMethods:     strings.Join(p.AllowMethods, ","),
+		AllowHeaders:     strings.Join(p.AllowHeaders, ","),
+		ExposeHeaders:    strings.Join(p.ExposeHeaders, ","),
+		AllowCredentials: p.AllowCredentials,
+		MaxAge:           int(p.MaxAge.Seconds()),
+	})
+}
+
+// CheckWSOrigin refuses the websocket upgrades browsers make from origins
+// the policy doesn't allow, CORS doesn't apply to them. Clients that aren't
+// browsers send no origin and are let through.
+func (p Policy) CheckWSOrigin() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		origin := c.Get(fiber.HeaderOrigin)
+		if origin == "" || !websocket.IsWebSocketUpgrade(c) || p.Allowed(origin) {
+			return c.Next()
+		}
+		return fiber.NewError(http.StatusForbidden, "Origin "+strconv.Quote(origin)+" is not allowed")
+	}
+}
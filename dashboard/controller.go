@@ -0,0 +1,47 @@
+package dashboard
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"order-book/book"
+	"order-book/diagnostics"
+	"order-book/tenant"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/filesystem"
+)
+
+// MAX_LEVELS caps the levels a side of the depth the page asks for
+const MAX_LEVELS = 100
+
+//go:embed static
+var static embed.FS
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindDashboardRouter serves the page on GET /admin/dashboard and the state
+// it polls on GET /admin/dashboard/state?pair_id=&tenant_id=&levels=. It's
+// behind the operator check of /admin, the page is opened with the
+// operator_token and passes it on, along with the api_key of its own URL.
+func BindDashboardRouter(r fiber.Router, books *book.Registry, rates *diagnostics.Rates, trades *Trades) {
+	r.Get("/admin/dashboard/state", func(c *fiber.Ctx) error {
+		levels := min(max(c.QueryInt("levels", 20), 1), MAX_LEVELS)
+		state := ReadState(books, rates, trades, c.Query("tenant_id", tenant.DEFAULT), c.Query("pair_id"), levels)
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    state,
+		})
+	})
+
+	assets, _ := fs.Sub(static, "static")
+	r.Use("/admin/dashboard", filesystem.New(filesystem.Config{
+		Root:  http.FS(assets),
+		Index: "index.html",
+	}))
+}
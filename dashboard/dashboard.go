@@ -0,0 +1,102 @@
+// Package dashboard serves a web page showing the depth and the recent
+// trades of a pair, the engine stats and the state of the pairs, for an
+// operator to watch a deployment with nothing else set up. The assets are
+// embedded in the binary and the page polls a single JSON endpoint, it reads
+// the books of this process and works without Redis.
+package dashboard
+
+import (
+	"order-book/book"
+	"order-book/diagnostics"
+	"order-book/marketdata"
+	"order-book/order"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Trades keeps the recent trades of every tenant pair, the market data cache
+// only has them with Redis
+type Trades struct {
+	mu     sync.Mutex
+	size   int
+	trades map[tradesKey][]marketdata.PublicTrade
+}
+
+type tradesKey struct {
+	tenantId string
+	pairId   string
+}
+
+// NewTrades keeps the last size trades of each pair
+func NewTrades(size int) *Trades {
+	return &Trades{
+		size:   size,
+		trades: make(map[tradesKey][]marketdata.PublicTrade),
+	}
+}
+
+// Attach records the trades of the book of a tenant, it is meant to be run
+// from a Registry.OnBook hook
+func (t *Trades) Attach(tenantId string, b book.Book) {
+	b.OnTrade(func(trade order.Trade) {
+		t.add(tenantId, trade)
+	})
+}
+
+func (t *Trades) add(tenantId string, trade order.Trade) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := tradesKey{tenantId, trade.PairID}
+	recent := slices.Insert(t.trades[key], 0, marketdata.PublicTrade{
+		ID:         trade.ID,
+		PairID:     trade.PairID,
+		Price:      trade.Price,
+		Amount:     trade.Amount,
+		TakerSide:  trade.TakerSide,
+		ExecutedAt: trade.ExecutedAt,
+	})
+	t.trades[key] = recent[:min(len(recent), t.size)]
+}
+
+// Recent returns the recent trades of a pair, the most recent first
+func (t *Trades) Recent(tenantId string, pairId string) []marketdata.PublicTrade {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.trades[tradesKey{tenantId, pairId}])
+}
+
+// State is what the page shows. Depth and Trades are those of the pair
+// picked, Depth is nil without one or when the tenant has no book yet.
+type State struct {
+	At      time.Time                    `json:"at"`
+	Stats   diagnostics.Stats            `json:"stats"`
+	Engines map[string]book.EngineHealth `json:"engines"`
+	Pairs   []order.PairStatus           `json:"pairs"`
+	Depth   *marketdata.Depth            `json:"depth"`
+	Trades  []marketdata.PublicTrade     `json:"trades"`
+}
+
+// ReadState reads the state with the depth of pairId in the book of
+// tenantId, levels levels a side
+func ReadState(books *book.Registry, rates *diagnostics.Rates, trades *Trades, tenantId string, pairId string, levels int) State {
+	state := State{
+		At:      time.Now().UTC(),
+		Stats:   diagnostics.ReadStats(books, rates),
+		Engines: books.Health(),
+		Pairs:   []order.PairStatus{},
+		Trades:  []marketdata.PublicTrade{},
+	}
+	for _, p := range order.GetPairs() {
+		state.Pairs = append(state.Pairs, order.PairStatus{Pair: p, Halted: order.IsHalted(p.ID)})
+	}
+	// Books() doesn't create the book of an unknown tenant like Get would
+	b, ok := books.Books()[tenantId]
+	if pairId == "" || !ok {
+		return state
+	}
+	depth := marketdata.RenderDepth(b.Snapshot(pairId), levels)
+	state.Depth = &depth
+	state.Trades = append(state.Trades, trades.Recent(tenantId, pairId)...)
+	return state
+}
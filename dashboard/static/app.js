@@ -0,0 +1,239 @@
+// Polls /admin/dashboard/state and renders it. The api_key and the
+// operator_token of the page URL are sent along, the tenant and the pair picked are kept in the URL.
+"use strict";
+
+const BID = 1;
+const POLL_MS = 1000;
+
+const params = new URLSearchParams(location.search);
+const apiKey = params.get("api_key");
+const operatorToken = params.get("operator_token");
+let tenantId = params.get("tenant_id") || "default";
+let pairId = params.get("pair_id") || "";
+let prevDepth = null;
+
+const $ = (id) => document.getElementById(id);
+
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  Object.assign(node, attrs || {});
+  for (const child of children) {
+    node.append(child instanceof Node ? child : document.createTextNode(child ?? ""));
+  }
+  return node;
+}
+
+function row(cells, attrs) {
+  return el("tr", attrs, ...cells.map((cell) => (cell instanceof Node ? cell : el("td", {}, String(cell)))));
+}
+
+function fill(id, rows) {
+  $(id).replaceChildren(...rows);
+}
+
+function setOptions(select, values, selected) {
+  const current = [...select.options].map((o) => o.value).join("\n");
+  if (current !== values.join("\n")) {
+    select.replaceChildren(...values.map((v) => el("option", { value: v }, v)));
+  }
+  select.value = selected;
+}
+
+function keepInURL() {
+  const next = new URLSearchParams(location.search);
+  next.set("tenant_id", tenantId);
+  if (pairId) {
+    next.set("pair_id", pairId);
+  } else {
+    next.delete("pair_id");
+  }
+  history.replaceState(null, "", "?" + next.toString());
+}
+
+function rate(r) {
+  return r["1m"].toFixed(2);
+}
+
+function bytes(n) {
+  return (n / (1 << 20)).toFixed(1) + " MiB";
+}
+
+function changedPrices(prev, levels, side) {
+  const changed = new Set();
+  if (!prev) {
+    return changed;
+  }
+  const amounts = new Map(prev[side].map((l) => [l.price, l.amount]));
+  for (const l of levels) {
+    if (amounts.get(l.price) !== l.amount) {
+      changed.add(l.price);
+    }
+  }
+  return changed;
+}
+
+function renderDepth(depth, trades) {
+  if (!depth) {
+    $("seq").textContent = "";
+    $("summary").textContent = pairId ? "no book for this tenant yet" : "pick a pair";
+    fill("asks", []);
+    fill("bids", []);
+    $("spread").textContent = "";
+    return;
+  }
+  const cumulate = (levels) => {
+    let sum = 0;
+    return levels.map((l) => (sum += Number(l.amount)));
+  };
+  const askTotals = cumulate(depth.asks);
+  const bidTotals = cumulate(depth.bids);
+  const largest = Math.max(askTotals.at(-1) || 0, bidTotals.at(-1) || 0);
+  // A seq that didn't move has nothing new to highlight
+  const prev = prevDepth && prevDepth.seq !== depth.seq ? prevDepth : null;
+  const ladder = (side, levels, totals) => {
+    const changed = changedPrices(prev, levels, side + "s");
+    return levels.map((l, idx) =>
+      row(
+        [
+          l.price,
+          l.amount,
+          totals[idx].toString(),
+          el("td", { className: "bar" }, el("div", { style: `width: ${largest ? (100 * totals[idx]) / largest : 0}%` })),
+        ],
+        { className: side + (changed.has(l.price) ? " changed" : "") },
+      ),
+    );
+  };
+  fill("asks", ladder("ask", depth.asks, askTotals).reverse());
+  fill("bids", ladder("bid", depth.bids, bidTotals));
+  prevDepth = depth;
+
+  $("seq").textContent = "seq " + depth.seq;
+  const summary = [];
+  if (depth.asks.length && depth.bids.length) {
+    const bid = Number(depth.bids[0].price);
+    const ask = Number(depth.asks[0].price);
+    const mid = (ask + bid) / 2;
+    $("spread").textContent = "spread " + (ask - bid).toPrecision(6).replace(/\.?0+$/, "");
+    summary.push(
+      el("span", { className: "bid" }, "bid " + depth.bids[0].price),
+      el("span", { className: "ask" }, "ask " + depth.asks[0].price),
+      el("span", {}, `spread ${((10000 * (ask - bid)) / mid).toFixed(1)} bps`),
+    );
+  } else {
+    $("spread").textContent = "one-sided book";
+  }
+  if (trades.length) {
+    const last = trades[0];
+    summary.push(el("span", { className: last.taker_side === BID ? "buy" : "sell" }, "last " + last.price));
+  }
+  $("summary").replaceChildren(...summary);
+}
+
+function renderTrades(trades) {
+  fill(
+    "trades",
+    trades.map((t) => {
+      const side = t.taker_side === BID ? "buy" : "sell";
+      return row([new Date(t.executed_at).toLocaleTimeString(), side, t.price, t.amount], { className: side });
+    }),
+  );
+}
+
+function renderEngines(state) {
+  const tenants = new Map(state.stats.tenants.map((t) => [t.tenant_id, t]));
+  fill(
+    "engines",
+    Object.keys(state.engines)
+      .sort()
+      .map((id) => {
+        const e = state.engines[id];
+        const t = tenants.get(id) || {};
+        return row([id, e.running ? "yes" : "stopped", `${e.queue_depth}/${e.queue_capacity}`, t.open_orders ?? "", e.persists, e.persist_failures, e.panics], {
+          className: e.running ? "" : "halted",
+        });
+      }),
+  );
+  const m = state.stats.memory;
+  $("memory").textContent = `heap ${bytes(m.heap_alloc)} in use ${bytes(m.heap_inuse)}, ${m.heap_objects} objects, sys ${bytes(m.sys)}`;
+}
+
+function renderPairs(state) {
+  const tenant = state.stats.tenants.find((t) => t.tenant_id === tenantId);
+  const stats = new Map((tenant ? tenant.pairs : []).map((p) => [p.pair_id, p]));
+  const ids = [...new Set([...state.pairs.map((p) => p.id), ...stats.keys()])].sort();
+  const listed = new Map(state.pairs.map((p) => [p.id, p]));
+  fill(
+    "pairs",
+    ids.map((id) => {
+      const p = listed.get(id);
+      const s = stats.get(id);
+      const halted = p && p.halted;
+      return row(
+        [
+          id,
+          el("td", { className: halted ? "halted" : "" }, p ? (halted ? "halted" : "trading") : "not listed"),
+          p ? p.tick_size : "",
+          p ? p.lot_size : "",
+          p ? p.min_amount : "",
+          s && s.best_bid ? s.best_bid : "",
+          s && s.best_ask ? s.best_ask : "",
+          s ? s.ask_orders + s.bid_orders : 0,
+          s ? rate(s.order_rate) : "",
+          s ? rate(s.trade_rate) : "",
+        ],
+        {},
+      );
+    }),
+  );
+  return ids;
+}
+
+async function poll() {
+  const query = new URLSearchParams({ tenant_id: tenantId, pair_id: pairId, levels: "20" });
+  const headers = apiKey ? { "X-API-Key": apiKey } : {};
+  if (operatorToken) {
+    headers["X-Operator-Token"] = operatorToken;
+  }
+  try {
+    const res = await fetch("/admin/dashboard/state?" + query, { headers });
+    const body = await res.json();
+    if (!res.ok) {
+      throw new Error(body.message || res.statusText);
+    }
+    const state = body.data;
+    const tenants = [...new Set([tenantId, ...Object.keys(state.engines)])].sort();
+    setOptions($("tenant"), tenants, tenantId);
+    const pairs = renderPairs(state);
+    if (!pairId && pairs.length) {
+      pairId = pairs[0];
+      keepInURL();
+      return poll();
+    }
+    setOptions($("pair"), [...new Set([pairId, ...pairs])].filter(Boolean).sort(), pairId);
+    renderDepth(state.depth, state.trades);
+    renderTrades(state.trades);
+    renderEngines(state);
+    $("status").textContent = "updated " + new Date(state.at).toLocaleTimeString();
+  } catch (err) {
+    $("status").textContent = "error: " + err.message;
+  }
+}
+
+$("tenant").addEventListener("change", (ev) => {
+  tenantId = ev.target.value;
+  pairId = "";
+  prevDepth = null;
+  keepInURL();
+  poll();
+});
+
+$("pair").addEventListener("change", (ev) => {
+  pairId = ev.target.value;
+  prevDepth = null;
+  keepInURL();
+  poll();
+});
+
+poll();
+setInterval(poll, POLL_MS);
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"order-book/logger"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrMigrationsPending = errors.New("Database migrations are pending")
+	ErrMigrationsDirty   = errors.New("A database migration failed halfway")
+)
+
+//go:embed migrations/*.up.sql
+var migrations embed.FS
+
+// LatestMigration is the version of the newest migration this build expects
+func LatestMigration() (uint64, error) {
+	names, err := fs.Glob(migrations, "migrations/*.up.sql")
+	if err != nil {
+		return 0, err
+	}
+	var latest uint64
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(strings.TrimPrefix(name, "migrations/"), "_")
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration name %s: %w", name, err)
+		}
+		latest = max(latest, version)
+	}
+	return latest, nil
+}
+
+// CheckMigrations compares the version recorded by migrate in
+// schema_migrations with the newest migration of this build
+func CheckMigrations(ctx context.Context, pool *pgxpool.Pool) error {
+	latest, err := LatestMigration()
+	if err != nil {
+		return err
+	}
+	var version uint64
+	var dirty bool
+	err = pool.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: none applied, expected %d", ErrMigrationsPending, latest)
+	}
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w: version %d", ErrMigrationsDirty, version)
+	}
+	if version < latest {
+		return fmt.Errorf("%w: at %d, expected %d", ErrMigrationsPending, version, latest)
+	}
+	return nil
+}
+
+// WaitForMigrations checks the migrations until they're applied, for a
+// migration job started along with the engine. A dirty migration needs an
+// operator and is returned right away.
+func WaitForMigrations(ctx context.Context, pool *pgxpool.Pool, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		err := CheckMigrations(ctx, pool)
+		if err == nil || errors.Is(err, ErrMigrationsDirty) {
+			return err
+		}
+		logger.Warn("waiting for database migrations", map[string]any{
+			"error": err,
+		})
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE tbl_ledger_entries;
+DROP TABLE tbl_balances;
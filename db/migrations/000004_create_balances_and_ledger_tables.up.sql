@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS tbl_balances (
+    account_id INTEGER NOT NULL REFERENCES tbl_accounts(id),
+    asset VARCHAR(25) NOT NULL,
+    available DECIMAL(20, 10) NOT NULL DEFAULT 0,
+    held DECIMAL(20, 10) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (account_id, asset)
+);
+
+CREATE TABLE IF NOT EXISTS tbl_ledger_entries (
+    id BIGSERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES tbl_accounts(id),
+    asset VARCHAR(25) NOT NULL,
+    entry_type VARCHAR(25) NOT NULL,
+    amount DECIMAL(20, 10) NOT NULL,
+    balance_after DECIMAL(20, 10) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL UNIQUE,
+    source VARCHAR(25) NOT NULL,
+    reference VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_account_id ON tbl_ledger_entries (account_id, id DESC);
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS tbl_ledger_entries (
+    id BIGSERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES tbl_accounts(id),
+    asset VARCHAR(25) NOT NULL,
+    entry_type VARCHAR(25) NOT NULL,
+    amount DECIMAL(20, 10) NOT NULL,
+    balance_after DECIMAL(20, 10) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL UNIQUE,
+    source VARCHAR(25) NOT NULL,
+    reference VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_account_id ON tbl_ledger_entries (account_id, id DESC);
+
+-- Only the user legs of single-account transactions can be represented in the old layout
+INSERT INTO tbl_ledger_entries (account_id, asset, entry_type, amount, balance_after, idempotency_key, source, reference, created_at)
+SELECT p.account_id, p.asset, t.tx_type, p.amount, p.balance_after, t.idempotency_key, t.source, t.reference, p.created_at
+FROM tbl_ledger_postings p
+JOIN tbl_ledger_transactions t ON t.id = p.transaction_id
+WHERE p.account_id IS NOT NULL AND t.tx_type IN ('DEPOSIT', 'WITHDRAWAL');
+
+DROP TRIGGER trg_ledger_transaction_balanced ON tbl_ledger_postings;
+DROP FUNCTION fn_check_ledger_transaction_balanced;
+DROP TABLE tbl_ledger_postings;
+DROP TABLE tbl_ledger_transactions;
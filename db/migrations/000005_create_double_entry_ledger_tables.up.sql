@@ -0,0 +1,59 @@
+CREATE TABLE IF NOT EXISTS tbl_ledger_transactions (
+    id BIGSERIAL PRIMARY KEY,
+    tx_type VARCHAR(25) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL UNIQUE,
+    source VARCHAR(25) NOT NULL,
+    reference VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Exactly one of account_id (a user account) or system_account (EXTERNAL, FEES, ...) is set on each posting
+CREATE TABLE IF NOT EXISTS tbl_ledger_postings (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id BIGINT NOT NULL REFERENCES tbl_ledger_transactions(id),
+    account_id INTEGER REFERENCES tbl_accounts(id),
+    system_account VARCHAR(25),
+    asset VARCHAR(25) NOT NULL,
+    amount DECIMAL(20, 10) NOT NULL,
+    balance_after DECIMAL(20, 10),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    CHECK ((account_id IS NULL) <> (system_account IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_account_id ON tbl_ledger_postings (account_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_transaction_id ON tbl_ledger_postings (transaction_id);
+
+-- Every transaction has to sum to zero per asset, checked at commit time so all legs can be inserted first
+CREATE OR REPLACE FUNCTION fn_check_ledger_transaction_balanced() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM tbl_ledger_postings
+        WHERE transaction_id = NEW.transaction_id
+        GROUP BY asset
+        HAVING SUM(amount) <> 0
+    ) THEN
+        RAISE EXCEPTION 'ledger transaction % is not balanced', NEW.transaction_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER trg_ledger_transaction_balanced
+    AFTER INSERT OR UPDATE ON tbl_ledger_postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION fn_check_ledger_transaction_balanced();
+
+-- Move the single-entry history over, the counter leg of every deposit/withdrawal is the EXTERNAL system account
+INSERT INTO tbl_ledger_transactions (id, tx_type, idempotency_key, source, reference, created_at)
+SELECT id, entry_type, idempotency_key, source, reference, created_at FROM tbl_ledger_entries;
+
+SELECT setval('tbl_ledger_transactions_id_seq', COALESCE((SELECT MAX(id) FROM tbl_ledger_transactions), 0) + 1, false);
+
+INSERT INTO tbl_ledger_postings (transaction_id, account_id, asset, amount, balance_after, created_at)
+SELECT id, account_id, asset, amount, balance_after, created_at FROM tbl_ledger_entries;
+
+INSERT INTO tbl_ledger_postings (transaction_id, system_account, asset, amount, created_at)
+SELECT id, 'EXTERNAL', asset, -amount, created_at FROM tbl_ledger_entries;
+
+DROP TABLE tbl_ledger_entries;
@@ -0,0 +1,2 @@
+DROP TABLE tbl_surveillance_alerts;
+DROP TABLE tbl_beneficial_owners;
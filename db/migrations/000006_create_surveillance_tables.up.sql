@@ -0,0 +1,22 @@
+-- Accounts sharing an owner_id belong to the same beneficial owner
+CREATE TABLE IF NOT EXISTS tbl_beneficial_owners (
+    account_id INTEGER PRIMARY KEY REFERENCES tbl_accounts(id),
+    owner_id VARCHAR(64) NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_beneficial_owners_owner_id ON tbl_beneficial_owners (owner_id);
+
+CREATE TABLE IF NOT EXISTS tbl_surveillance_alerts (
+    id BIGSERIAL PRIMARY KEY,
+    alert_type VARCHAR(25) NOT NULL,
+    pair_id VARCHAR(25) NOT NULL,
+    status VARCHAR(25) NOT NULL DEFAULT 'OPEN',
+    details JSONB NOT NULL DEFAULT '{}',
+    reviewed_by VARCHAR(255),
+    review_note TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    reviewed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_surveillance_alerts_status ON tbl_surveillance_alerts (status, id);
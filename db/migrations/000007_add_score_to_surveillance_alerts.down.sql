@@ -0,0 +1,2 @@
+DROP INDEX idx_surveillance_alerts_score;
+ALTER TABLE tbl_surveillance_alerts DROP COLUMN score;
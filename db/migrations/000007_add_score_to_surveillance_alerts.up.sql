@@ -0,0 +1,3 @@
+ALTER TABLE tbl_surveillance_alerts ADD COLUMN score DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_surveillance_alerts_score ON tbl_surveillance_alerts (status, score DESC, id);
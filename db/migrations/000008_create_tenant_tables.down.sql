@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_accounts_tenant_id;
+ALTER TABLE tbl_accounts DROP COLUMN IF EXISTS tenant_id;
+DROP TABLE IF EXISTS tbl_api_keys;
+DROP TABLE IF EXISTS tbl_tenant_pairs;
+DROP TABLE IF EXISTS tbl_tenants;
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS tbl_tenants (
+    id VARCHAR(64) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    max_open_orders INTEGER NOT NULL DEFAULT 0,
+    max_orders_per_second INTEGER NOT NULL DEFAULT 0,
+    max_accounts INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Existing accounts and keyless requests belong to the default tenant
+INSERT INTO tbl_tenants (id, name) VALUES ('default', 'Default') ON CONFLICT (id) DO NOTHING;
+
+-- A tenant without pairs can trade any pair
+CREATE TABLE IF NOT EXISTS tbl_tenant_pairs (
+    tenant_id VARCHAR(64) NOT NULL REFERENCES tbl_tenants(id),
+    pair_id VARCHAR(25) NOT NULL,
+    PRIMARY KEY (tenant_id, pair_id)
+);
+
+CREATE TABLE IF NOT EXISTS tbl_api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL REFERENCES tbl_tenants(id),
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    key_prefix VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMP
+);
+
+ALTER TABLE tbl_accounts ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES tbl_tenants(id);
+
+CREATE INDEX IF NOT EXISTS idx_accounts_tenant_id ON tbl_accounts (tenant_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tbl_webhook_deliveries;
+DROP TABLE IF EXISTS tbl_webhooks;
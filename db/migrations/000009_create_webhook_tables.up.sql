@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS tbl_webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES tbl_accounts(id),
+    url TEXT NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_account_id ON tbl_webhooks (account_id) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS tbl_webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES tbl_webhooks(id),
+    account_id INTEGER NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(25) NOT NULL DEFAULT 'PENDING',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_status_code INTEGER,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_account_id ON tbl_webhook_deliveries (account_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON tbl_webhook_deliveries (next_attempt_at) WHERE status = 'PENDING';
@@ -0,0 +1,3 @@
+ALTER TABLE tbl_orders
+    ALTER COLUMN price TYPE DECIMAL(20, 10),
+    ALTER COLUMN amount TYPE DECIMAL(20, 10);
@@ -0,0 +1,5 @@
+-- Prices and amounts keep the exact decimals of each pair, the scale is
+-- enforced per pair by the engine rather than fixed for every pair here
+ALTER TABLE tbl_orders
+    ALTER COLUMN price TYPE NUMERIC,
+    ALTER COLUMN amount TYPE NUMERIC;
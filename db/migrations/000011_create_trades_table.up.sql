@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS tbl_trades
+(
+    id BIGINT PRIMARY KEY,
+    pair_id VARCHAR(25) NOT NULL,
+    price NUMERIC NOT NULL,
+    amount NUMERIC NOT NULL,
+    maker_order_id BIGINT NOT NULL REFERENCES tbl_orders(id),
+    taker_order_id BIGINT NOT NULL REFERENCES tbl_orders(id),
+    maker_account_id INTEGER NOT NULL REFERENCES tbl_accounts(id),
+    taker_account_id INTEGER NOT NULL REFERENCES tbl_accounts(id),
+    taker_side INTEGER NOT NULL,
+    maker_fee NUMERIC NOT NULL,
+    taker_fee NUMERIC NOT NULL,
+    executed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_trades_maker_account ON tbl_trades (maker_account_id, executed_at);
+CREATE INDEX IF NOT EXISTS idx_trades_taker_account ON tbl_trades (taker_account_id, executed_at);
+CREATE INDEX IF NOT EXISTS idx_trades_pair ON tbl_trades (pair_id, executed_at);
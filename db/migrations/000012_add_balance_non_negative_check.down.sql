@@ -0,0 +1 @@
+ALTER TABLE tbl_balances DROP CONSTRAINT IF EXISTS chk_balances_non_negative;
@@ -0,0 +1,2 @@
+-- Holds and releases move funds between available and held, neither side may go below zero
+ALTER TABLE tbl_balances ADD CONSTRAINT chk_balances_non_negative CHECK (available >= 0 AND held >= 0);
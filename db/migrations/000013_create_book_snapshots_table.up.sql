@@ -0,0 +1,13 @@
+-- Only the latest snapshot of each book is kept, older ones are deleted as new ones are written
+CREATE TABLE IF NOT EXISTS tbl_book_snapshots
+(
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL,
+    pair_id VARCHAR(25) NOT NULL,
+    seq BIGINT NOT NULL,
+    asks JSONB NOT NULL,
+    bids JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_book_snapshots_pair ON tbl_book_snapshots (tenant_id, pair_id, id DESC);
@@ -0,0 +1,22 @@
+-- Archived partitions are left in the archive schema
+ALTER TABLE tbl_order_history_events RENAME TO tbl_order_history_events_partitioned;
+ALTER TABLE tbl_order_history_events_partitioned ALTER COLUMN id DROP DEFAULT;
+
+CREATE TABLE tbl_order_history_events (
+    id BIGINT PRIMARY KEY DEFAULT nextval('tbl_order_history_events_id_seq'),
+    event VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    metadata JSONB DEFAULT '{}',
+
+    order_id BIGINT REFERENCES tbl_orders(id)
+);
+
+ALTER SEQUENCE tbl_order_history_events_id_seq OWNED BY tbl_order_history_events.id;
+
+INSERT INTO tbl_order_history_events (id, event, created_at, metadata, order_id)
+SELECT id, event, created_at, metadata, order_id FROM tbl_order_history_events_partitioned;
+
+DROP TABLE tbl_order_history_events_partitioned;
+DROP FUNCTION IF EXISTS fn_archive_order_history_partitions(TIMESTAMP);
+DROP FUNCTION IF EXISTS fn_create_order_history_partition(TIMESTAMP);
+DROP TABLE IF EXISTS tbl_archived_partitions;
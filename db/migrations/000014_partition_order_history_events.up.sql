@@ -0,0 +1,88 @@
+-- Order history events are partitioned by month, so months past the retention
+-- window can be detached and archived without touching the hot partitions
+ALTER TABLE tbl_order_history_events RENAME TO tbl_order_history_events_old;
+ALTER TABLE tbl_order_history_events_old ALTER COLUMN id DROP DEFAULT;
+ALTER SEQUENCE tbl_order_history_events_id_seq AS BIGINT;
+
+-- The partition key has to be part of the primary key
+CREATE TABLE tbl_order_history_events (
+    id BIGINT NOT NULL DEFAULT nextval('tbl_order_history_events_id_seq'),
+    event VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    metadata JSONB DEFAULT '{}',
+
+    order_id BIGINT REFERENCES tbl_orders(id),
+
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+ALTER SEQUENCE tbl_order_history_events_id_seq OWNED BY tbl_order_history_events.id;
+
+CREATE INDEX IF NOT EXISTS idx_order_history_events_order_id ON tbl_order_history_events (order_id, created_at);
+
+-- Catches events of months whose partition was not created in time
+CREATE TABLE IF NOT EXISTS tbl_order_history_events_default PARTITION OF tbl_order_history_events DEFAULT;
+
+-- Archived partitions are moved out of the public schema, history queries no longer see them
+CREATE SCHEMA IF NOT EXISTS archive;
+
+CREATE TABLE IF NOT EXISTS tbl_archived_partitions (
+    name VARCHAR(63) PRIMARY KEY,
+    parent VARCHAR(63) NOT NULL,
+    range_start TIMESTAMP NOT NULL,
+    range_end TIMESTAMP NOT NULL,
+    archived_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Creates the partition holding the month of p_month if it doesn't exist yet
+CREATE OR REPLACE FUNCTION fn_create_order_history_partition(p_month TIMESTAMP) RETURNS VARCHAR AS $$
+DECLARE
+    partition_start TIMESTAMP := date_trunc('month', p_month);
+    partition_name VARCHAR := 'tbl_order_history_events_' || to_char(partition_start, '"y"YYYY"m"MM');
+BEGIN
+    EXECUTE format(
+        'CREATE TABLE IF NOT EXISTS %I PARTITION OF tbl_order_history_events FOR VALUES FROM (%L) TO (%L)',
+        partition_name, partition_start, partition_start + INTERVAL '1 month'
+    );
+    RETURN partition_name;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Detaches the monthly partitions ending at or before p_before, moves them to
+-- the archive schema and returns their names
+CREATE OR REPLACE FUNCTION fn_archive_order_history_partitions(p_before TIMESTAMP) RETURNS SETOF VARCHAR AS $$
+DECLARE
+    partition_name VARCHAR;
+    partition_start TIMESTAMP;
+BEGIN
+    FOR partition_name IN
+        SELECT c.relname::VARCHAR
+        FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        WHERE i.inhparent = 'tbl_order_history_events'::regclass
+          AND c.relname ~ '_y[0-9]{4}m[0-9]{2}$'
+        ORDER BY c.relname
+    LOOP
+        partition_start := to_date(substring(partition_name from 'y([0-9]{4}m[0-9]{2})$'), 'YYYY"m"MM');
+        CONTINUE WHEN partition_start + INTERVAL '1 month' > p_before;
+
+        EXECUTE format('ALTER TABLE tbl_order_history_events DETACH PARTITION %I', partition_name);
+        EXECUTE format('ALTER TABLE %I SET SCHEMA archive', partition_name);
+        INSERT INTO tbl_archived_partitions (name, parent, range_start, range_end)
+        VALUES (partition_name, 'tbl_order_history_events', partition_start, partition_start + INTERVAL '1 month');
+        RETURN NEXT partition_name;
+    END LOOP;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT fn_create_order_history_partition(m)
+FROM generate_series(
+    date_trunc('month', COALESCE((SELECT MIN(created_at) FROM tbl_order_history_events_old), NOW())),
+    date_trunc('month', NOW()) + INTERVAL '2 months',
+    INTERVAL '1 month'
+) AS m;
+
+INSERT INTO tbl_order_history_events (id, event, created_at, metadata, order_id)
+SELECT id, event, COALESCE(created_at, NOW()), metadata, order_id FROM tbl_order_history_events_old;
+
+DROP TABLE tbl_order_history_events_old;
@@ -0,0 +1,4 @@
+DROP FUNCTION IF EXISTS fn_drop_archived_partition(VARCHAR, VARCHAR);
+DROP FUNCTION IF EXISTS fn_read_archived_history_partition(VARCHAR);
+ALTER TABLE tbl_archived_partitions DROP COLUMN IF EXISTS exported_at;
+ALTER TABLE tbl_archived_partitions DROP COLUMN IF EXISTS object_key;
@@ -0,0 +1,18 @@
+-- Archived partitions are exported to object storage and then dropped
+ALTER TABLE tbl_archived_partitions ADD COLUMN IF NOT EXISTS object_key VARCHAR(255);
+ALTER TABLE tbl_archived_partitions ADD COLUMN IF NOT EXISTS exported_at TIMESTAMP;
+
+-- Reads the rows of an order history partition moved to the archive schema
+CREATE OR REPLACE FUNCTION fn_read_archived_history_partition(p_name VARCHAR) RETURNS SETOF tbl_order_history_events AS $$
+BEGIN
+    RETURN QUERY EXECUTE format('SELECT id, event, created_at, metadata, order_id FROM archive.%I ORDER BY id', p_name);
+END;
+$$ LANGUAGE plpgsql;
+
+-- Drops an exported partition from the archive schema and records the object holding its rows
+CREATE OR REPLACE FUNCTION fn_drop_archived_partition(p_name VARCHAR, p_object_key VARCHAR) RETURNS VOID AS $$
+BEGIN
+    EXECUTE format('DROP TABLE IF EXISTS archive.%I', p_name);
+    UPDATE tbl_archived_partitions SET object_key = p_object_key, exported_at = NOW() WHERE name = p_name;
+END;
+$$ LANGUAGE plpgsql;
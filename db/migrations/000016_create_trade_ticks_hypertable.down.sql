@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS trg_record_trade_tick ON tbl_trades;
+DROP FUNCTION IF EXISTS fn_record_trade_tick();
+DROP TABLE IF EXISTS tbl_trade_ticks;
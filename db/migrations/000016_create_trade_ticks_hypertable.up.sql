@@ -0,0 +1,38 @@
+CREATE EXTENSION IF NOT EXISTS timescaledb;
+
+-- Prices of every fill, kept apart from tbl_trades so candles outlive the
+-- trades moved to cold storage
+CREATE TABLE IF NOT EXISTS tbl_trade_ticks
+(
+    trade_id BIGINT NOT NULL,
+    tenant_id VARCHAR(64) NOT NULL,
+    pair_id VARCHAR(25) NOT NULL,
+    price NUMERIC NOT NULL,
+    amount NUMERIC NOT NULL,
+    executed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (trade_id, executed_at)
+);
+
+SELECT create_hypertable('tbl_trade_ticks', 'executed_at', chunk_time_interval => INTERVAL '1 day', if_not_exists => TRUE);
+
+CREATE INDEX IF NOT EXISTS idx_trade_ticks_pair ON tbl_trade_ticks (tenant_id, pair_id, executed_at DESC);
+
+-- Trades carry no tenant, it is the tenant of the taker account
+CREATE OR REPLACE FUNCTION fn_record_trade_tick() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO tbl_trade_ticks (trade_id, tenant_id, pair_id, price, amount, executed_at)
+    SELECT NEW.id, a.tenant_id, NEW.pair_id, NEW.price, NEW.amount, NEW.executed_at
+    FROM tbl_accounts a WHERE a.id = NEW.taker_account_id
+    ON CONFLICT DO NOTHING;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_record_trade_tick ON tbl_trades;
+CREATE TRIGGER trg_record_trade_tick AFTER INSERT ON tbl_trades
+    FOR EACH ROW EXECUTE FUNCTION fn_record_trade_tick();
+
+INSERT INTO tbl_trade_ticks (trade_id, tenant_id, pair_id, price, amount, executed_at)
+SELECT t.id, a.tenant_id, t.pair_id, t.price, t.amount, t.executed_at
+FROM tbl_trades t JOIN tbl_accounts a ON a.id = t.taker_account_id
+ON CONFLICT DO NOTHING;
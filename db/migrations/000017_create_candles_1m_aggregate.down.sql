@@ -0,0 +1 @@
+DROP MATERIALIZED VIEW IF EXISTS cagg_candles_1m;
@@ -0,0 +1,17 @@
+-- Continuous aggregates can't be created in a transaction, so each one has its own migration
+CREATE MATERIALIZED VIEW IF NOT EXISTS cagg_candles_1d
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT
+    tenant_id,
+    pair_id,
+    time_bucket(INTERVAL '1 day', executed_at) AS bucket,
+    first(price, executed_at) AS open,
+    max(price) AS high,
+    min(price) AS low,
+    last(price, executed_at) AS close,
+    sum(amount) AS volume,
+    sum(price * amount) AS quote_volume,
+    count(*) AS trades
+FROM tbl_trade_ticks
+GROUP BY tenant_id, pair_id, bucket
+WITH NO DATA;
@@ -0,0 +1,3 @@
+SELECT remove_continuous_aggregate_policy('cagg_candles_1d', if_exists => TRUE);
+SELECT remove_continuous_aggregate_policy('cagg_candles_1h', if_exists => TRUE);
+SELECT remove_continuous_aggregate_policy('cagg_candles_1m', if_exists => TRUE);
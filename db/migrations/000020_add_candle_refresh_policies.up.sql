@@ -0,0 +1,10 @@
+-- Buckets past the refreshed range are aggregated from tbl_trade_ticks at query time
+SELECT add_continuous_aggregate_policy('cagg_candles_1m',
+    start_offset => INTERVAL '1 hour', end_offset => INTERVAL '1 minute', schedule_interval => INTERVAL '1 minute',
+    if_not_exists => TRUE);
+SELECT add_continuous_aggregate_policy('cagg_candles_1h',
+    start_offset => INTERVAL '1 day', end_offset => INTERVAL '1 hour', schedule_interval => INTERVAL '10 minutes',
+    if_not_exists => TRUE);
+SELECT add_continuous_aggregate_policy('cagg_candles_1d',
+    start_offset => INTERVAL '7 days', end_offset => INTERVAL '1 day', schedule_interval => INTERVAL '1 hour',
+    if_not_exists => TRUE);
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS trg_outbox_balance ON tbl_balances;
+DROP TRIGGER IF EXISTS trg_outbox_trade ON tbl_trades;
+DROP TRIGGER IF EXISTS trg_outbox_order_event ON tbl_order_history_events;
+DROP FUNCTION IF EXISTS fn_outbox_balance();
+DROP FUNCTION IF EXISTS fn_outbox_trade();
+DROP FUNCTION IF EXISTS fn_outbox_order_event();
+DROP TABLE IF EXISTS tbl_outbox;
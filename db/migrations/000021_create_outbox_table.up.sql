@@ -0,0 +1,67 @@
+-- Columns follow the Debezium outbox event router, events are routed to
+-- outbox.event.<aggregatetype> keyed by aggregateid
+CREATE TABLE IF NOT EXISTS tbl_outbox
+(
+    id BIGSERIAL PRIMARY KEY,
+    aggregatetype VARCHAR(64) NOT NULL,
+    aggregateid VARCHAR(64) NOT NULL,
+    type VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    published_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_unpublished ON tbl_outbox (id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_created_at ON tbl_outbox (created_at);
+
+-- Events are written by triggers so they commit or roll back with the change they describe
+CREATE OR REPLACE FUNCTION fn_outbox_order_event() RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.order_id IS NULL THEN
+        RETURN NEW;
+    END IF;
+    INSERT INTO tbl_outbox (aggregatetype, aggregateid, type, payload)
+    VALUES ('order', NEW.order_id::VARCHAR, NEW.event, jsonb_build_object(
+        'id', NEW.id,
+        'order_id', NEW.order_id,
+        'event', NEW.event,
+        'metadata', NEW.metadata,
+        'created_at', NEW.created_at
+    ));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION fn_outbox_trade() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO tbl_outbox (aggregatetype, aggregateid, type, payload)
+    VALUES ('trade', NEW.pair_id, 'TRADE', to_jsonb(NEW));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION fn_outbox_balance() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO tbl_outbox (aggregatetype, aggregateid, type, payload)
+    VALUES ('balance', NEW.account_id::VARCHAR, 'BALANCE_UPDATED', jsonb_build_object(
+        'account_id', NEW.account_id,
+        'asset', NEW.asset,
+        'available', NEW.available,
+        'held', NEW.held,
+        'updated_at', NEW.updated_at
+    ));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_outbox_order_event ON tbl_order_history_events;
+CREATE TRIGGER trg_outbox_order_event AFTER INSERT ON tbl_order_history_events
+    FOR EACH ROW EXECUTE FUNCTION fn_outbox_order_event();
+
+DROP TRIGGER IF EXISTS trg_outbox_trade ON tbl_trades;
+CREATE TRIGGER trg_outbox_trade AFTER INSERT ON tbl_trades
+    FOR EACH ROW EXECUTE FUNCTION fn_outbox_trade();
+
+DROP TRIGGER IF EXISTS trg_outbox_balance ON tbl_balances;
+CREATE TRIGGER trg_outbox_balance AFTER INSERT OR UPDATE ON tbl_balances
+    FOR EACH ROW EXECUTE FUNCTION fn_outbox_balance();
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tbl_consumer_offsets;
+DROP TABLE IF EXISTS tbl_consumer_processed_events;
@@ -0,0 +1,21 @@
+-- Downstream consumers record the events they applied in the same
+-- transaction as their effects, so a redelivered event is skipped
+CREATE TABLE IF NOT EXISTS tbl_consumer_processed_events
+(
+    consumer VARCHAR(128) NOT NULL,
+    event_id BIGINT NOT NULL,
+    processed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (consumer, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_consumer_processed_events_processed_at ON tbl_consumer_processed_events (consumer, processed_at);
+
+-- The position to resume each stream (topic partition, JetStream consumer) from
+CREATE TABLE IF NOT EXISTS tbl_consumer_offsets
+(
+    consumer VARCHAR(128) NOT NULL,
+    stream VARCHAR(255) NOT NULL,
+    next_offset BIGINT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (consumer, stream)
+);
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS trg_book_snapshots_fencing ON tbl_book_snapshots;
+DROP TRIGGER IF EXISTS trg_trades_fencing ON tbl_trades;
+DROP TRIGGER IF EXISTS trg_order_history_events_fencing ON tbl_order_history_events;
+DROP TRIGGER IF EXISTS trg_orders_fencing ON tbl_orders;
+DROP FUNCTION IF EXISTS check_fencing_token();
+DROP TABLE IF EXISTS tbl_engine_leases;
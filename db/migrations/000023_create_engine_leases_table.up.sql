@@ -0,0 +1,41 @@
+-- A lease elects the single engine instance allowed to write the books it
+-- covers. Every election bumps the token of the lease.
+CREATE TABLE IF NOT EXISTS tbl_engine_leases
+(
+    name VARCHAR(128) PRIMARY KEY,
+    token BIGINT NOT NULL,
+    holder VARCHAR(255) NOT NULL,
+    acquired_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- The sessions of an engine running an election carry its lease and fencing
+-- token, their writes are refused once another instance holds a newer token.
+-- Locking the lease row holds an election back until the writes in flight
+-- commit. Sessions without a lease aren't fenced.
+CREATE OR REPLACE FUNCTION check_fencing_token() RETURNS TRIGGER AS $$
+DECLARE
+    session_lease TEXT := current_setting('engine.lease', true);
+    session_token TEXT := current_setting('engine.fencing_token', true);
+BEGIN
+    IF session_lease IS NULL OR session_lease = '' THEN
+        RETURN NULL;
+    END IF;
+    PERFORM 1 FROM tbl_engine_leases
+    WHERE name = session_lease AND token = COALESCE(NULLIF(session_token, ''), '0')::BIGINT
+    FOR SHARE;
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'stale fencing token % for lease %', session_token, session_lease
+            USING ERRCODE = 'EF001';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_orders_fencing BEFORE INSERT OR UPDATE ON tbl_orders
+    FOR EACH STATEMENT EXECUTE FUNCTION check_fencing_token();
+CREATE TRIGGER trg_order_history_events_fencing BEFORE INSERT ON tbl_order_history_events
+    FOR EACH STATEMENT EXECUTE FUNCTION check_fencing_token();
+CREATE TRIGGER trg_trades_fencing BEFORE INSERT ON tbl_trades
+    FOR EACH STATEMENT EXECUTE FUNCTION check_fencing_token();
+CREATE TRIGGER trg_book_snapshots_fencing BEFORE INSERT OR DELETE ON tbl_book_snapshots
+    FOR EACH STATEMENT EXECUTE FUNCTION check_fencing_token();
@@ -0,0 +1,20 @@
+-- Pairs listed through the admin API. They override the pairs of the config
+-- file with the same ID, a zero size or minimum is not enforced and fees
+-- left NULL are those of the fee schedule.
+CREATE TABLE IF NOT EXISTS tbl_pairs
+(
+    id VARCHAR(25) PRIMARY KEY,
+    base VARCHAR(16) NOT NULL,
+    quote VARCHAR(16) NOT NULL,
+    tick_size NUMERIC NOT NULL DEFAULT 0,
+    lot_size NUMERIC NOT NULL DEFAULT 0,
+    min_amount NUMERIC NOT NULL DEFAULT 0,
+    min_notional NUMERIC NOT NULL DEFAULT 0,
+    price_scale INTEGER NOT NULL,
+    amount_scale INTEGER NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'trading',
+    maker_fee DOUBLE PRECISION,
+    taker_fee DOUBLE PRECISION,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
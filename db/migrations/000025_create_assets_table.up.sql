@@ -0,0 +1,13 @@
+-- Assets listed through the admin API. They override the assets of the
+-- config file with the same symbol, amounts of an asset carry at most
+-- precision decimal places.
+CREATE TABLE IF NOT EXISTS tbl_assets
+(
+    symbol VARCHAR(16) PRIMARY KEY,
+    name VARCHAR(64) NOT NULL DEFAULT '',
+    precision INTEGER NOT NULL,
+    trading_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    withdrawals_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
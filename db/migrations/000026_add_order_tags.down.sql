@@ -0,0 +1,9 @@
+ALTER TABLE tbl_trades
+    DROP COLUMN IF EXISTS maker_tag,
+    DROP COLUMN IF EXISTS maker_strategy_id,
+    DROP COLUMN IF EXISTS taker_tag,
+    DROP COLUMN IF EXISTS taker_strategy_id;
+
+ALTER TABLE tbl_orders
+    DROP COLUMN IF EXISTS tag,
+    DROP COLUMN IF EXISTS strategy_id;
@@ -0,0 +1,11 @@
+-- Clients attribute their orders to strategies with a free-form tag and a
+-- strategy ID, the fills carry those of both sides
+ALTER TABLE tbl_orders
+    ADD COLUMN tag VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN strategy_id VARCHAR(64) NOT NULL DEFAULT '';
+
+ALTER TABLE tbl_trades
+    ADD COLUMN maker_tag VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN maker_strategy_id VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN taker_tag VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN taker_strategy_id VARCHAR(64) NOT NULL DEFAULT '';
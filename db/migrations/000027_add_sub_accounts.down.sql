@@ -0,0 +1,8 @@
+ALTER TABLE tbl_api_keys DROP COLUMN IF EXISTS account_id;
+
+DROP INDEX IF EXISTS idx_accounts_master_id;
+
+ALTER TABLE tbl_accounts
+    DROP COLUMN IF EXISTS master_id,
+    DROP COLUMN IF EXISTS max_open_orders,
+    DROP COLUMN IF EXISTS max_orders_per_second;
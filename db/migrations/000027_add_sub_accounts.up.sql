@@ -0,0 +1,11 @@
+-- A sub-account trades under a master account of the same tenant, its own
+-- limits apply on top of the quotas of the tenant. An API key bound to an
+-- account only reaches that account and its sub-accounts.
+ALTER TABLE tbl_accounts
+    ADD COLUMN master_id INTEGER REFERENCES tbl_accounts(id),
+    ADD COLUMN max_open_orders INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN max_orders_per_second INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_accounts_master_id ON tbl_accounts (master_id);
+
+ALTER TABLE tbl_api_keys ADD COLUMN account_id INTEGER REFERENCES tbl_accounts(id);
@@ -0,0 +1,2 @@
+ALTER TABLE tbl_trades
+    DROP COLUMN IF EXISTS maker_rebate;
@@ -0,0 +1,3 @@
+-- Makers on a negative fee rate are paid a rebate, kept apart from the fees
+ALTER TABLE tbl_trades
+    ADD COLUMN maker_rebate NUMERIC NOT NULL DEFAULT 0;
@@ -0,0 +1,14 @@
+-- The top levels of the books recorded on an interval, for the depth of a
+-- pair at a time or over a period
+CREATE TABLE IF NOT EXISTS tbl_depth_snapshots
+(
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL,
+    pair_id VARCHAR(25) NOT NULL,
+    seq BIGINT NOT NULL,
+    asks JSONB NOT NULL,
+    bids JSONB NOT NULL,
+    recorded_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_depth_snapshots_recorded_at ON tbl_depth_snapshots (tenant_id, pair_id, recorded_at);
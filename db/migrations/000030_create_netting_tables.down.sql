@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tbl_netting_obligations;
+DROP TABLE IF EXISTS tbl_netting_accounts;
@@ -0,0 +1,24 @@
+-- The accounts in netting mode and the obligations they owe or are owed,
+-- kept until their net is posted. window_end is set when the window of an
+-- obligation closed, it's NULL in the running window.
+CREATE TABLE IF NOT EXISTS tbl_netting_accounts
+(
+    account_id INT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS tbl_netting_obligations
+(
+    id BIGSERIAL PRIMARY KEY,
+    account_id INT NOT NULL,
+    trade_id BIGINT NOT NULL,
+    pair_id VARCHAR(25) NOT NULL,
+    entry_type VARCHAR(25) NOT NULL,
+    asset VARCHAR(25) NOT NULL,
+    amount DECIMAL(20, 10) NOT NULL,
+    at TIMESTAMP NOT NULL,
+    window_start TIMESTAMP NULL,
+    window_end TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_netting_obligations_account ON tbl_netting_obligations (account_id, window_end);
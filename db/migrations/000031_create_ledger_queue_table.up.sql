@@ -0,0 +1,16 @@
+-- The ledger transactions the engine owes the accounts, like the maker
+-- rebates and the fees paid in a fee asset, kept until they're posted.
+-- fallback is posted instead when the balance is short for tx.
+CREATE TABLE IF NOT EXISTS tbl_ledger_queue
+(
+    id BIGSERIAL PRIMARY KEY,
+    idempotency_key VARCHAR(255) NOT NULL UNIQUE,
+    tx JSONB NOT NULL,
+    fallback JSONB NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    next_attempt_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_queue_next_attempt_at ON tbl_ledger_queue (next_attempt_at);
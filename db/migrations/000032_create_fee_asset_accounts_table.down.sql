@@ -0,0 +1 @@
+DROP TABLE IF EXISTS tbl_fee_asset_accounts;
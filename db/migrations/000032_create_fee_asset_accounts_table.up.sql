@@ -0,0 +1,7 @@
+-- The asset each account chose to pay its fees in
+CREATE TABLE IF NOT EXISTS tbl_fee_asset_accounts
+(
+    account_id INT PRIMARY KEY,
+    asset VARCHAR(25) NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
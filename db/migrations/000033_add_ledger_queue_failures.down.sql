@@ -0,0 +1,3 @@
+ALTER TABLE tbl_ledger_queue
+    DROP COLUMN IF EXISTS fell_back,
+    DROP COLUMN IF EXISTS failed_at;
@@ -0,0 +1,6 @@
+-- fell_back is set before the fallback of an entry is posted, so a retry
+-- never posts its transaction as well. An entry the ledger refuses for good
+-- is parked with failed_at until an operator retries it.
+ALTER TABLE tbl_ledger_queue
+    ADD COLUMN fell_back BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN failed_at TIMESTAMP NULL;
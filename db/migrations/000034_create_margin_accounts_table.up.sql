@@ -0,0 +1,8 @@
+-- The accounts trading on margin, with their leverage and collateral
+CREATE TABLE IF NOT EXISTS tbl_margin_accounts
+(
+    account_id INT PRIMARY KEY,
+    leverage NUMERIC NOT NULL,
+    collateral_asset VARCHAR(25) NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,106 @@
+package replica
+
+import (
+	"context"
+	"order-book/logger"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// lagQuery is zero on a primary and on a replica that replayed everything it
+// received, otherwise the age of the last replayed transaction
+const lagQuery = `SELECT CASE
+    WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+    ELSE COALESCE(EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp()), 0)
+END::DOUBLE PRECISION`
+
+// Router sends reads to the replica while its replication lag stays under
+// maxLag, and to the primary when there is no replica or it falls behind.
+// Writes always go to the primary.
+type Router struct {
+	primary *pgxpool.Pool
+	replica *pgxpool.Pool
+	maxLag  time.Duration
+	// lag is the last measured replication lag, negative while the replica is unreachable
+	lag atomic.Int64
+}
+
+// NewRouter returns a router reading from replica, or only from primary when replica is nil
+func NewRouter(primary *pgxpool.Pool, replica *pgxpool.Pool, maxLag time.Duration) *Router {
+	r := &Router{
+		primary: primary,
+		replica: replica,
+		maxLag:  maxLag,
+	}
+	// Reads stay on the primary until the first lag measurement
+	r.lag.Store(-1)
+	return r
+}
+
+func (r *Router) Primary() *pgxpool.Pool {
+	return r.primary
+}
+
+// Reader returns the pool reads should go to and whether it is the replica
+func (r *Router) Reader() (*pgxpool.Pool, bool) {
+	if r.replica == nil {
+		return r.primary, false
+	}
+	lag := time.Duration(r.lag.Load())
+	if lag < 0 || lag > r.maxLag {
+		return r.primary, false
+	}
+	return r.replica, true
+}
+
+// Lag is the last measured replication lag, ok is false without a reachable replica
+func (r *Router) Lag() (lag time.Duration, ok bool) {
+	if r.replica == nil {
+		return 0, false
+	}
+	lag = time.Duration(r.lag.Load())
+	return lag, lag >= 0
+}
+
+// Run measures the replication lag every interval until ctx is done
+func (r *Router) Run(ctx context.Context, interval time.Duration) {
+	if r.replica == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.measure(ctx, interval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Router) measure(ctx context.Context, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var seconds float64
+	if err := r.replica.QueryRow(ctx, lagQuery).Scan(&seconds); err != nil {
+		if r.lag.Swap(-1) >= 0 {
+			logger.Warn("read replica unreachable, reading from the primary", map[string]any{
+				"error": err,
+			})
+		}
+		return
+	}
+	lag := time.Duration(seconds * float64(time.Second))
+	previous := time.Duration(r.lag.Swap(int64(lag)))
+	if previous < 0 || (previous <= r.maxLag) != (lag <= r.maxLag) {
+		logger.Info("read replica lag changed routing", map[string]any{
+			"lag":         lag.String(),
+			"max_lag":     r.maxLag.String(),
+			"use_replica": lag <= r.maxLag,
+		})
+	}
+}
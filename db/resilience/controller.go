@@ -0,0 +1,23 @@
+package resilience
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+func BindBreakerRouter(r fiber.Router, b *Breaker) {
+	r.Get("/admin/db/breaker", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    b.Stats(),
+		})
+	})
+}
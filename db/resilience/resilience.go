@@ -0,0 +1,207 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"order-book/logger"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var ErrCircuitOpen = errors.New("Database is unavailable, try again later")
+
+// Breaker states
+const (
+	CLOSED    = "CLOSED"
+	OPEN      = "OPEN"
+	HALF_OPEN = "HALF_OPEN"
+)
+
+type Options struct {
+	// A transient failure is retried MaxRetries times, waiting a random time
+	// up to RetryBackoff doubling with each attempt and capped at MaxBackoff
+	MaxRetries   int
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+	// The breaker opens after FailureThreshold consecutive failed calls and
+	// lets a single call probe the database OpenTimeout later
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
+// Stats is a snapshot of the breaker
+type Stats struct {
+	State    string    `json:"state"`
+	Failures int       `json:"failures"`
+	Retries  uint64    `json:"retries"`
+	Rejected uint64    `json:"rejected"`
+	Trips    uint64    `json:"trips"`
+	OpenedAt time.Time `json:"opened_at,omitzero"`
+}
+
+// Breaker guards the database calls of the engine. Transient failures are
+// retried with jittered backoff, and once the database keeps failing calls
+// are rejected right away until a probe goes through.
+type Breaker struct {
+	opts     Options
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	retries  uint64
+	rejected uint64
+	trips    uint64
+	// closed is closed and replaced whenever the breaker closes
+	closed chan struct{}
+}
+
+func NewBreaker(opts Options) *Breaker {
+	return &Breaker{
+		opts:   opts,
+		state:  CLOSED,
+		closed: make(chan struct{}),
+	}
+}
+
+// IsTransient reports whether an error is worth retrying: the statement was
+// never sent, or the database rolled it back and asked for a retry
+func IsTransient(err error) bool {
+	if pgconn.SafeToRetry(err) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		// serialization_failure, deadlock_detected, too_many_connections,
+		// cannot_connect_now
+		case "40001", "40P01", "53300", "57P03":
+			return true
+		}
+	}
+	return false
+}
+
+// Allow returns ErrCircuitOpen while calls are rejected
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == OPEN && time.Since(b.openedAt) < b.opts.OpenTimeout {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// acquire lets a call through, moving an open breaker past its timeout to
+// half open for a single probe
+func (b *Breaker) acquire() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case OPEN:
+		if time.Since(b.openedAt) < b.opts.OpenTimeout {
+			b.rejected++
+			return false
+		}
+		b.state = HALF_OPEN
+		return true
+	case HALF_OPEN:
+		// The probe is running
+		b.rejected++
+		return false
+	}
+	return true
+}
+
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Errors of the caller, like a missing row, say the database is up
+	if err == nil || !IsTransient(err) && !errors.Is(err, context.DeadlineExceeded) {
+		if b.state != CLOSED {
+			logger.Info("database circuit closed", map[string]any{})
+			close(b.closed)
+			b.closed = make(chan struct{})
+		}
+		b.state = CLOSED
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == HALF_OPEN || b.failures >= b.opts.FailureThreshold {
+		if b.state != OPEN {
+			b.trips++
+			logger.Error("database circuit opened", map[string]any{
+				"failures": b.failures,
+				"error":    err,
+			})
+		}
+		b.state = OPEN
+		b.openedAt = time.Now()
+	}
+}
+
+// Do runs fn, retrying its transient failures. It returns ErrCircuitOpen
+// without running fn while the breaker is open.
+func (b *Breaker) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	backoff := b.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		if !b.acquire() {
+			return ErrCircuitOpen
+		}
+		err := fn(ctx)
+		b.record(err)
+		if err == nil || !IsTransient(err) || attempt == b.opts.MaxRetries {
+			return err
+		}
+		b.mu.Lock()
+		b.retries++
+		b.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(rand.N(backoff) + 1):
+		}
+		backoff = min(2*backoff, b.opts.MaxBackoff)
+	}
+}
+
+// DoWait is Do for calls that shouldn't be given up on: while the breaker is
+// open or the failures are transient, it waits for the database to come back
+// and runs fn again until ctx is done
+func (b *Breaker) DoWait(ctx context.Context, fn func(ctx context.Context) error) error {
+	for {
+		err := b.Do(ctx, fn)
+		if err == nil || !errors.Is(err, ErrCircuitOpen) && !IsTransient(err) {
+			return err
+		}
+		b.mu.Lock()
+		closed := b.closed
+		wait := b.opts.OpenTimeout - time.Since(b.openedAt)
+		b.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return err
+		case <-closed:
+		case <-time.After(max(wait, b.opts.RetryBackoff)):
+		}
+	}
+}
+
+func (b *Breaker) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := Stats{
+		State:    b.state,
+		Failures: b.failures,
+		Retries:  b.retries,
+		Rejected: b.rejected,
+		Trips:    b.trips,
+	}
+	if b.state != CLOSED {
+		s.OpenedAt = b.openedAt
+	}
+	return s
+}
@@ -0,0 +1,165 @@
+-- The schema of the Postgres migrations up to 000022 without the parts only
+-- Postgres runs: history partitions and their archive, the trade ticks
+-- hypertable and its candles, the outbox and the consumer dedup tables.
+-- Decimals are stored as TEXT so they keep their exact value, balances are
+-- checked by the repositories rather than by a constraint.
+CREATE TABLE tbl_tenants (
+    id VARCHAR(64) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    max_open_orders INTEGER NOT NULL DEFAULT 0,
+    max_orders_per_second INTEGER NOT NULL DEFAULT 0,
+    max_accounts INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO tbl_tenants (id, name) VALUES ('default', 'Default');
+
+CREATE TABLE tbl_tenant_pairs (
+    tenant_id VARCHAR(64) NOT NULL REFERENCES tbl_tenants(id),
+    pair_id VARCHAR(25) NOT NULL,
+    PRIMARY KEY (tenant_id, pair_id)
+);
+
+CREATE TABLE tbl_api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id VARCHAR(64) NOT NULL REFERENCES tbl_tenants(id),
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    key_prefix VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE TABLE tbl_accounts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP DEFAULT NULL,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES tbl_tenants(id)
+);
+
+CREATE TABLE tbl_orders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    pair_id VARCHAR(25) NOT NULL,
+    price TEXT NOT NULL,
+    amount TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    order_type INTEGER NOT NULL,
+    account_id INTEGER REFERENCES tbl_accounts(id)
+);
+
+CREATE INDEX idx_orders_account_id ON tbl_orders (account_id);
+
+CREATE TABLE tbl_order_history_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    metadata TEXT DEFAULT '{}',
+    order_id INTEGER REFERENCES tbl_orders(id)
+);
+
+CREATE INDEX idx_order_history_events_order_id ON tbl_order_history_events (order_id, created_at);
+
+CREATE TABLE tbl_trades (
+    id INTEGER PRIMARY KEY,
+    pair_id VARCHAR(25) NOT NULL,
+    price TEXT NOT NULL,
+    amount TEXT NOT NULL,
+    maker_order_id INTEGER NOT NULL REFERENCES tbl_orders(id),
+    taker_order_id INTEGER NOT NULL REFERENCES tbl_orders(id),
+    maker_account_id INTEGER NOT NULL REFERENCES tbl_accounts(id),
+    taker_account_id INTEGER NOT NULL REFERENCES tbl_accounts(id),
+    taker_side INTEGER NOT NULL,
+    maker_fee TEXT NOT NULL,
+    taker_fee TEXT NOT NULL,
+    executed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_trades_executed_at ON tbl_trades (executed_at);
+CREATE INDEX idx_trades_pair_executed_at ON tbl_trades (pair_id, executed_at);
+
+CREATE TABLE tbl_book_snapshots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id VARCHAR(64) NOT NULL,
+    pair_id VARCHAR(25) NOT NULL,
+    seq INTEGER NOT NULL,
+    asks TEXT NOT NULL,
+    bids TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_book_snapshots_latest ON tbl_book_snapshots (tenant_id, pair_id, seq);
+
+CREATE TABLE tbl_balances (
+    account_id INTEGER NOT NULL REFERENCES tbl_accounts(id),
+    asset VARCHAR(25) NOT NULL,
+    available TEXT NOT NULL DEFAULT '0',
+    held TEXT NOT NULL DEFAULT '0',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_id, asset)
+);
+
+CREATE TABLE tbl_ledger_transactions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tx_type VARCHAR(25) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL UNIQUE,
+    source VARCHAR(25) NOT NULL,
+    reference VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE tbl_ledger_postings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    transaction_id INTEGER NOT NULL REFERENCES tbl_ledger_transactions(id),
+    account_id INTEGER REFERENCES tbl_accounts(id),
+    system_account VARCHAR(25),
+    asset VARCHAR(25) NOT NULL,
+    amount TEXT NOT NULL,
+    balance_after TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((account_id IS NULL) <> (system_account IS NULL))
+);
+
+CREATE INDEX idx_ledger_postings_account_id ON tbl_ledger_postings (account_id);
+
+CREATE TABLE tbl_webhooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES tbl_accounts(id),
+    url TEXT NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE TABLE tbl_webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id INTEGER NOT NULL REFERENCES tbl_webhooks(id),
+    account_id INTEGER NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(25) NOT NULL DEFAULT 'PENDING',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_status_code INTEGER,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP
+);
+
+CREATE TABLE tbl_beneficial_owners (
+    account_id INTEGER PRIMARY KEY REFERENCES tbl_accounts(id),
+    owner_id VARCHAR(64) NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE tbl_surveillance_alerts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    alert_type VARCHAR(25) NOT NULL,
+    pair_id VARCHAR(25) NOT NULL,
+    status VARCHAR(25) NOT NULL DEFAULT 'OPEN',
+    details TEXT NOT NULL DEFAULT '{}',
+    reviewed_by VARCHAR(255),
+    review_note TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMP,
+    score REAL NOT NULL DEFAULT 0
+);
@@ -0,0 +1,17 @@
+-- 000024 of the Postgres migrations
+CREATE TABLE tbl_pairs (
+    id VARCHAR(25) PRIMARY KEY,
+    base VARCHAR(16) NOT NULL,
+    quote VARCHAR(16) NOT NULL,
+    tick_size TEXT NOT NULL DEFAULT '0',
+    lot_size TEXT NOT NULL DEFAULT '0',
+    min_amount TEXT NOT NULL DEFAULT '0',
+    min_notional TEXT NOT NULL DEFAULT '0',
+    price_scale INTEGER NOT NULL,
+    amount_scale INTEGER NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'trading',
+    maker_fee REAL,
+    taker_fee REAL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
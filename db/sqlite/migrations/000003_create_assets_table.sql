@@ -0,0 +1,10 @@
+-- 000025 of the Postgres migrations
+CREATE TABLE tbl_assets (
+    symbol VARCHAR(16) PRIMARY KEY,
+    name VARCHAR(64) NOT NULL DEFAULT '',
+    precision INTEGER NOT NULL,
+    trading_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    withdrawals_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,8 @@
+-- 000026 of the Postgres migrations
+ALTER TABLE tbl_orders ADD COLUMN tag VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE tbl_orders ADD COLUMN strategy_id VARCHAR(64) NOT NULL DEFAULT '';
+
+ALTER TABLE tbl_trades ADD COLUMN maker_tag VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE tbl_trades ADD COLUMN maker_strategy_id VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE tbl_trades ADD COLUMN taker_tag VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE tbl_trades ADD COLUMN taker_strategy_id VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+-- 000027 of the Postgres migrations
+ALTER TABLE tbl_accounts ADD COLUMN master_id INTEGER REFERENCES tbl_accounts(id);
+ALTER TABLE tbl_accounts ADD COLUMN max_open_orders INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tbl_accounts ADD COLUMN max_orders_per_second INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_accounts_master_id ON tbl_accounts (master_id);
+
+ALTER TABLE tbl_api_keys ADD COLUMN account_id INTEGER REFERENCES tbl_accounts(id);
@@ -0,0 +1,2 @@
+-- 000028 of the Postgres migrations
+ALTER TABLE tbl_trades ADD COLUMN maker_rebate TEXT NOT NULL DEFAULT '0';
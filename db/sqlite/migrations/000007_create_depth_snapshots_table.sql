@@ -0,0 +1,12 @@
+-- 000029 of the Postgres migrations
+CREATE TABLE tbl_depth_snapshots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id VARCHAR(64) NOT NULL,
+    pair_id VARCHAR(25) NOT NULL,
+    seq INTEGER NOT NULL,
+    asks TEXT NOT NULL,
+    bids TEXT NOT NULL,
+    recorded_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_depth_snapshots_recorded_at ON tbl_depth_snapshots (tenant_id, pair_id, recorded_at);
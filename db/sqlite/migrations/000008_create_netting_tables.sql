@@ -0,0 +1,20 @@
+-- 000030 of the Postgres migrations
+CREATE TABLE tbl_netting_accounts (
+    account_id INTEGER PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE tbl_netting_obligations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL,
+    trade_id INTEGER NOT NULL,
+    pair_id VARCHAR(25) NOT NULL,
+    entry_type VARCHAR(25) NOT NULL,
+    asset VARCHAR(25) NOT NULL,
+    amount TEXT NOT NULL,
+    at TIMESTAMP NOT NULL,
+    window_start TIMESTAMP NULL,
+    window_end TIMESTAMP NULL
+);
+
+CREATE INDEX idx_netting_obligations_account ON tbl_netting_obligations (account_id, window_end);
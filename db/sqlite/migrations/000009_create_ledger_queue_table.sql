@@ -0,0 +1,13 @@
+-- 000031 of the Postgres migrations
+CREATE TABLE tbl_ledger_queue (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    idempotency_key VARCHAR(255) NOT NULL UNIQUE,
+    tx TEXT NOT NULL,
+    fallback TEXT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    next_attempt_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_ledger_queue_next_attempt_at ON tbl_ledger_queue (next_attempt_at);
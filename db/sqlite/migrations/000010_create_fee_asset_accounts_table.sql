@@ -0,0 +1,6 @@
+-- 000032 of the Postgres migrations
+CREATE TABLE tbl_fee_asset_accounts (
+    account_id INTEGER PRIMARY KEY,
+    asset VARCHAR(25) NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+-- 000033 of the Postgres migrations
+ALTER TABLE tbl_ledger_queue ADD COLUMN fell_back BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE tbl_ledger_queue ADD COLUMN failed_at TIMESTAMP NULL;
@@ -0,0 +1,7 @@
+-- 000034 of the Postgres migrations
+CREATE TABLE tbl_margin_accounts (
+    account_id INTEGER PRIMARY KEY,
+    leverage TEXT NOT NULL,
+    collateral_asset VARCHAR(25) NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,127 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"order-book/logger"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	msqlite "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Open opens the database file at path, creating it if needed, and applies
+// the migrations it's missing. SQLite has a single writer, so the pool keeps
+// one connection and statements queue on it rather than failing as busy.
+func Open(path string) (*sql.DB, error) {
+	return open("file:"+path, "foreign_keys(1)", "journal_mode(WAL)", "busy_timeout(5000)")
+}
+
+// OpenMemory opens a database held in RAM, it's gone once closed or once
+// the process exits
+func OpenMemory() (*sql.DB, error) {
+	return open(":memory:", "foreign_keys(1)")
+}
+
+func open(name string, pragmas ...string) (*sql.DB, error) {
+	dsn := name + "?" + url.Values{
+		"_pragma":      pragmas,
+		"_time_format": {"sqlite"},
+	}.Encode()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// Every connection to :memory: has a database of its own, the single
+	// connection is also never closed for being idle
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxIdleTime(0)
+	db.SetConnMaxLifetime(0)
+	if err := migrate(context.Background(), db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// migrate runs the embedded migrations numbered above the user_version of
+// the database, each in its own transaction along with the version bump
+func migrate(ctx context.Context, db *sql.DB) error {
+	var current int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&current); err != nil {
+		return err
+	}
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		base := strings.TrimPrefix(name, "migrations/")
+		version, err := strconv.Atoi(strings.SplitN(base, "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("invalid migration name %s", base)
+		}
+		if version <= current {
+			continue
+		}
+		script, err := migrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %s: %w", base, err)
+		}
+		// PRAGMA doesn't take bound parameters
+		if _, err := tx.ExecContext(ctx, "PRAGMA user_version = "+strconv.Itoa(version)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		logger.Info("sqlite migration applied", map[string]any{
+			"migration": base,
+		})
+	}
+	return nil
+}
+
+// IsUniqueViolation reports whether err is a UNIQUE or PRIMARY KEY
+// constraint failure, the SQLite counterpart of Postgres error 23505
+func IsUniqueViolation(err error) bool {
+	var sqliteErr *msqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code()
+	return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
+
+// IsForeignKeyViolation is the SQLite counterpart of Postgres error 23503
+func IsForeignKeyViolation(err error) bool {
+	var sqliteErr *msqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+}
+
+// Now is the time written by the repositories in place of NOW(). Times are
+// stored as UTC text, so they have to be in UTC to compare in order.
+func Now() time.Time {
+	return time.Now().UTC()
+}
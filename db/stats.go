@@ -0,0 +1,61 @@
+package db
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// PoolStats is a snapshot of the connection pool health
+type PoolStats struct {
+	MaxConns                int32         `json:"max_conns"`
+	TotalConns              int32         `json:"total_conns"`
+	AcquiredConns           int32         `json:"acquired_conns"`
+	IdleConns               int32         `json:"idle_conns"`
+	ConstructingConns       int32         `json:"constructing_conns"`
+	AcquireCount            int64         `json:"acquire_count"`
+	AcquireDuration         time.Duration `json:"acquire_duration"`
+	EmptyAcquireCount       int64         `json:"empty_acquire_count"`
+	CanceledAcquireCount    int64         `json:"canceled_acquire_count"`
+	NewConnsCount           int64         `json:"new_conns_count"`
+	MaxLifetimeDestroyCount int64         `json:"max_lifetime_destroy_count"`
+	MaxIdleDestroyCount     int64         `json:"max_idle_destroy_count"`
+}
+
+func Stats(pool *pgxpool.Pool) PoolStats {
+	s := pool.Stat()
+	return PoolStats{
+		MaxConns:                s.MaxConns(),
+		TotalConns:              s.TotalConns(),
+		AcquiredConns:           s.AcquiredConns(),
+		IdleConns:               s.IdleConns(),
+		ConstructingConns:       s.ConstructingConns(),
+		AcquireCount:            s.AcquireCount(),
+		AcquireDuration:         s.AcquireDuration(),
+		EmptyAcquireCount:       s.EmptyAcquireCount(),
+		CanceledAcquireCount:    s.CanceledAcquireCount(),
+		NewConnsCount:           s.NewConnsCount(),
+		MaxLifetimeDestroyCount: s.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     s.MaxIdleDestroyCount(),
+	}
+}
+
+// BindDBRouter exposes the pool health to operators. A growing
+// empty_acquire_count means requests wait for a free connection.
+func BindDBRouter(r fiber.Router, pool *pgxpool.Pool) {
+	r.Get("/admin/db/pool", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    Stats(pool),
+		})
+	})
+}
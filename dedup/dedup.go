@@ -0,0 +1,117 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	repository "order-book/dedup/repository/gen"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Handler applies an event within tx, its changes are committed along with
+// the record of the event
+type Handler func(ctx context.Context, tx pgx.Tx) error
+
+// Store helps services consuming the engine's events apply each of them
+// once. Transports deliver at least once, so a consumer records the ID of
+// every event it applies, and the position to resume its stream from, in the
+// transaction that applies it.
+type Store interface {
+	// Process runs handle unless the consumer already applied the event, and
+	// moves the stream of the consumer to nextOffset. It reports whether
+	// handle ran, an error from handle rolls everything back.
+	Process(ctx context.Context, consumer string, stream string, nextOffset int64, eventId int64, handle Handler) (bool, error)
+	// Offset returns the position to resume a stream from, 0 for a stream
+	// the consumer never read
+	Offset(ctx context.Context, consumer string, stream string) (int64, error)
+	// Purge forgets the events processed before the given time, which should
+	// be older than any redelivery
+	Purge(ctx context.Context, consumer string, before time.Time) (int64, error)
+}
+
+type store struct {
+	queries      *repository.Queries
+	dbpool       *pgxpool.Pool
+	queryTimeout time.Duration
+}
+
+func (s *store) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+func (s *store) Process(ctx context.Context, consumer string, stream string, nextOffset int64, eventId int64, handle Handler) (bool, error) {
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	qtx := s.queries.WithTx(tx)
+	queryCtx, cancel := s.withTimeout(ctx)
+	inserted, err := qtx.InsertProcessedEvent(queryCtx, repository.InsertProcessedEventParams{
+		Consumer: consumer,
+		EventID:  eventId,
+	})
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	applied := inserted == 1
+	if applied {
+		if err := handle(ctx, tx); err != nil {
+			return false, err
+		}
+	}
+
+	// The offset still moves past a duplicate so it isn't read again
+	queryCtx, cancel = s.withTimeout(ctx)
+	defer cancel()
+	err = qtx.AdvanceConsumerOffset(queryCtx, repository.AdvanceConsumerOffsetParams{
+		Consumer:   consumer,
+		Stream:     stream,
+		NextOffset: nextOffset,
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(queryCtx); err != nil {
+		return false, err
+	}
+	return applied, nil
+}
+
+func (s *store) Offset(ctx context.Context, consumer string, stream string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	offset, err := s.queries.GetConsumerOffset(ctx, repository.GetConsumerOffsetParams{
+		Consumer: consumer,
+		Stream:   stream,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return offset, err
+}
+
+func (s *store) Purge(ctx context.Context, consumer string, before time.Time) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.queries.PurgeProcessedEvents(ctx, repository.PurgeProcessedEventsParams{
+		Consumer: consumer,
+		Before:   pgtype.Timestamp{Time: before.UTC(), Valid: true},
+	})
+}
+
+func NewStore(dbpool *pgxpool.Pool, queryTimeout time.Duration) Store {
+	return &store{
+		queries:      repository.New(dbpool),
+		dbpool:       dbpool,
+		queryTimeout: queryTimeout,
+	}
+}
@@ -0,0 +1,20 @@
+-- name: InsertProcessedEvent :one
+WITH inserted AS (
+    INSERT INTO tbl_consumer_processed_events (consumer, event_id) VALUES (@consumer, @event_id)
+    ON CONFLICT (consumer, event_id) DO NOTHING RETURNING 1
+)
+SELECT COUNT(*) AS inserted FROM inserted;
+
+-- name: AdvanceConsumerOffset :exec
+INSERT INTO tbl_consumer_offsets (consumer, stream, next_offset) VALUES (@consumer, @stream, @next_offset)
+ON CONFLICT (consumer, stream) DO UPDATE
+SET next_offset = GREATEST(tbl_consumer_offsets.next_offset, EXCLUDED.next_offset), updated_at = NOW();
+
+-- name: GetConsumerOffset :one
+SELECT next_offset FROM tbl_consumer_offsets WHERE consumer = @consumer AND stream = @stream;
+
+-- name: PurgeProcessedEvents :one
+WITH deleted AS (
+    DELETE FROM tbl_consumer_processed_events WHERE consumer = @consumer AND processed_at < @before RETURNING 1
+)
+SELECT COUNT(*) AS deleted FROM deleted;
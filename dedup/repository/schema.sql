@@ -0,0 +1,16 @@
+CREATE TABLE tbl_consumer_processed_events
+(
+    consumer VARCHAR(128) NOT NULL,
+    event_id BIGINT NOT NULL,
+    processed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (consumer, event_id)
+);
+
+CREATE TABLE tbl_consumer_offsets
+(
+    consumer VARCHAR(128) NOT NULL,
+    stream VARCHAR(255) NOT NULL,
+    next_offset BIGINT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (consumer, stream)
+);
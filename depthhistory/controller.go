@@ -0,0 +1,88 @@
+package depthhistory
+
+import (
+	"errors"
+	"net/http"
+	"order-book/logger"
+	"order-book/tenant"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var ErrInvalidData = errors.New("ErrInvalidData")
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindDepthHistoryRouter serves the recorded depth of a pair. With an RFC
+// 3339 at parameter it is the depth recorded last by then, otherwise the
+// records between the from and to parameters, the last hour by default.
+func BindDepthHistoryRouter(r fiber.Router, repo Repo) {
+	r.Get("/market/:pair_id/depth-history", func(c *fiber.Ctx) error {
+		pairId := c.Params("pair_id")
+		tenantId := tenant.FromCtx(c).ID
+
+		if at := c.Query("at"); at != "" {
+			t, err := time.Parse(time.RFC3339, at)
+			if err != nil {
+				return invalidTime(c, "at")
+			}
+			res, err := repo.GetSnapshotAt(c.UserContext(), tenantId, pairId, t)
+			if err != nil {
+				return err
+			}
+			c.Status(http.StatusOK)
+			return c.JSON(&Response{
+				Message: "",
+				Data:    res,
+			})
+		}
+
+		limit := c.QueryInt("limit", 500)
+		if limit < 1 || limit > 1000 {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrInvalidData,
+				Message: "limit should be between 1 and 1000",
+			})
+		}
+		to := time.Now().UTC()
+		from := to.Add(-time.Hour)
+		for param, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+			if v := c.Query(param); v != "" {
+				t, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					return invalidTime(c, param)
+				}
+				*dst = t
+			}
+		}
+		res, err := repo.GetSnapshots(c.UserContext(), tenantId, pairId, from, to, limit)
+		if err != nil {
+			logger.Error("failed to get the depth history", map[string]any{
+				"pair_id": pairId,
+				"from":    from,
+				"to":      to,
+				"error":   err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    res,
+		})
+	})
+}
+
+func invalidTime(c *fiber.Ctx, param string) error {
+	c.Status(http.StatusBadRequest)
+	return c.JSON(&Response{
+		Error:   ErrInvalidData,
+		Message: param + " should be an RFC 3339 time",
+	})
+}
@@ -0,0 +1,139 @@
+// Package depthhistory records the top levels of the books on an interval,
+// so the depth of a pair can be looked up at a past time or over a period
+// for liquidity analysis and post-trade investigations.
+package depthhistory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	repository "order-book/depthhistory/repository/gen"
+	"order-book/marketdata"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrSnapshotNotFound = errors.New("No depth was recorded for the pair by then")
+
+// Snapshot is the depth of a pair when it was recorded, Seq is the engine
+// event the book reflected
+type Snapshot struct {
+	TenantID   string             `json:"-"`
+	PairID     string             `json:"pair_id"`
+	Seq        int64              `json:"seq"`
+	Asks       []marketdata.Level `json:"asks"`
+	Bids       []marketdata.Level `json:"bids"`
+	RecordedAt time.Time          `json:"recorded_at"`
+}
+
+type Repo interface {
+	AddSnapshot(ctx context.Context, s Snapshot) error
+	// GetSnapshotAt is the last snapshot of a pair recorded at or before at
+	GetSnapshotAt(ctx context.Context, tenantId string, pairId string, at time.Time) (Snapshot, error)
+	// GetSnapshots are the snapshots of a pair recorded in [from, to), the
+	// oldest first and limit at most
+	GetSnapshots(ctx context.Context, tenantId string, pairId string, from time.Time, to time.Time, limit int) ([]Snapshot, error)
+	// DeleteSnapshotsBefore drops the snapshots recorded before a time but
+	// for the last one of each pair, which still describes the pair then
+	DeleteSnapshotsBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type repo struct {
+	queries      *repository.Queries
+	queryTimeout time.Duration
+}
+
+func (repo *repo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *repo) AddSnapshot(ctx context.Context, s Snapshot) error {
+	asks, err := json.Marshal(s.Asks)
+	if err != nil {
+		return err
+	}
+	bids, err := json.Marshal(s.Bids)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.InsertDepthSnapshot(ctx, repository.InsertDepthSnapshotParams{
+		TenantID:   s.TenantID,
+		PairID:     s.PairID,
+		Seq:        s.Seq,
+		Asks:       asks,
+		Bids:       bids,
+		RecordedAt: pgtype.Timestamp{Time: s.RecordedAt.UTC(), Valid: true},
+	})
+}
+
+func (repo *repo) GetSnapshotAt(ctx context.Context, tenantId string, pairId string, at time.Time) (Snapshot, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	res, err := repo.queries.GetDepthSnapshotAt(ctx, repository.GetDepthSnapshotAtParams{
+		TenantID:   tenantId,
+		PairID:     pairId,
+		RecordedAt: pgtype.Timestamp{Time: at.UTC(), Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Snapshot{}, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return convertSnapshot(res)
+}
+
+func (repo *repo) GetSnapshots(ctx context.Context, tenantId string, pairId string, from time.Time, to time.Time, limit int) ([]Snapshot, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	dbres, err := repo.queries.GetDepthSnapshotsBetween(ctx, repository.GetDepthSnapshotsBetweenParams{
+		TenantID: tenantId,
+		PairID:   pairId,
+		FromTime: pgtype.Timestamp{Time: from.UTC(), Valid: true},
+		ToTime:   pgtype.Timestamp{Time: to.UTC(), Valid: true},
+		MaxRows:  int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]Snapshot, len(dbres))
+	for idx, s := range dbres {
+		if snapshots[idx], err = convertSnapshot(s); err != nil {
+			return nil, err
+		}
+	}
+	return snapshots, nil
+}
+
+func (repo *repo) DeleteSnapshotsBefore(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.DeleteDepthSnapshotsBefore(ctx, pgtype.Timestamp{Time: before.UTC(), Valid: true})
+}
+
+func NewRepository(dbpool *pgxpool.Pool, queryTimeout time.Duration) Repo {
+	return &repo{
+		queries:      repository.New(dbpool),
+		queryTimeout: queryTimeout,
+	}
+}
+
+func convertSnapshot(s repository.TblDepthSnapshot) (res Snapshot, err error) {
+	res.TenantID = s.TenantID
+	res.PairID = s.PairID
+	res.Seq = s.Seq
+	res.RecordedAt = s.RecordedAt.Time
+	if err = json.Unmarshal(s.Asks, &res.Asks); err != nil {
+		return
+	}
+	err = json.Unmarshal(s.Bids, &res.Bids)
+	return
+}
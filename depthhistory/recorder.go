@@ -0,0 +1,130 @@
+package depthhistory
+
+import (
+	"context"
+	"order-book/book"
+	"order-book/logger"
+	"order-book/marketdata"
+	"slices"
+	"time"
+)
+
+var recorderLog = logger.Component("depthhistory")
+
+// Recorder records the top levels of every pair of the books every
+// interval. A pair isn't recorded again while its levels are those of its
+// last record, that record still describes it. Records older than the
+// retention are dropped but for the last one of each pair, a zero retention
+// keeps them all.
+type Recorder struct {
+	ctx       context.Context
+	repo      Repo
+	interval  time.Duration
+	levels    int
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewRecorder records the books attached until ctx is done
+func NewRecorder(ctx context.Context, repo Repo, interval time.Duration, levels int, retention time.Duration) *Recorder {
+	return &Recorder{
+		ctx:       ctx,
+		repo:      repo,
+		interval:  interval,
+		levels:    levels,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// Attach starts recording the book of a tenant, it is meant to be run from
+// a Registry.OnBook hook
+func (r *Recorder) Attach(tenantId string, b book.Book) {
+	go r.run(tenantId, b)
+}
+
+func (r *Recorder) run(tenantId string, b book.Book) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	recorded := map[string]marketdata.Depth{}
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := r.now()
+		for _, pairId := range b.Pairs() {
+			depth := marketdata.RenderDepth(b.Snapshot(pairId), r.levels)
+			if last, ok := recorded[pairId]; ok && sameLevels(last.Asks, depth.Asks) && sameLevels(last.Bids, depth.Bids) {
+				continue
+			}
+			err := r.repo.AddSnapshot(r.ctx, Snapshot{
+				TenantID:   tenantId,
+				PairID:     pairId,
+				Seq:        depth.Seq,
+				Asks:       depth.Asks,
+				Bids:       depth.Bids,
+				RecordedAt: now,
+			})
+			if err != nil {
+				recorderLog.Error("failed to record the depth", map[string]any{
+					"tenant_id": tenantId,
+					"pair_id":   pairId,
+					"seq":       depth.Seq,
+					"error":     err,
+				})
+				continue
+			}
+			recorded[pairId] = depth
+		}
+	}
+}
+
+// sameLevels tells whether two sides of a depth hold the same levels
+func sameLevels(a []marketdata.Level, b []marketdata.Level) bool {
+	return slices.EqualFunc(a, b, func(x marketdata.Level, y marketdata.Level) bool {
+		return x.Price.Equal(y.Price) && x.Amount.Equal(y.Amount)
+	})
+}
+
+// Run drops the records past the retention, once per interval or once an
+// hour for short intervals
+func (r *Recorder) Run(ctx context.Context) {
+	recorderLog.Info("depth recording started", map[string]any{
+		"interval":  r.interval.String(),
+		"levels":    r.levels,
+		"retention": r.retention.String(),
+	})
+	if r.retention <= 0 {
+		return
+	}
+	ticker := time.NewTicker(max(r.interval, time.Hour))
+	defer ticker.Stop()
+	for {
+		r.prune(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Recorder) prune(ctx context.Context) {
+	before := r.now().Add(-r.retention)
+	deleted, err := r.repo.DeleteSnapshotsBefore(ctx, before)
+	if err != nil {
+		recorderLog.Error("failed to drop the old depth records", map[string]any{
+			"before": before,
+			"error":  err,
+		})
+		return
+	}
+	if deleted > 0 {
+		recorderLog.Debug("old depth records dropped", map[string]any{
+			"before":  before,
+			"deleted": deleted,
+		})
+	}
+}
@@ -0,0 +1,20 @@
+-- name: InsertDepthSnapshot :exec
+INSERT INTO tbl_depth_snapshots (tenant_id, pair_id, seq, asks, bids, recorded_at)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: GetDepthSnapshotAt :one
+SELECT * FROM tbl_depth_snapshots
+WHERE tenant_id = $1 AND pair_id = $2 AND recorded_at <= $3
+ORDER BY recorded_at DESC LIMIT 1;
+
+-- name: GetDepthSnapshotsBetween :many
+SELECT * FROM tbl_depth_snapshots
+WHERE tenant_id = @tenant_id AND pair_id = @pair_id AND recorded_at >= @from_time AND recorded_at < @to_time
+ORDER BY recorded_at LIMIT @max_rows;
+
+-- name: DeleteDepthSnapshotsBefore :execrows
+DELETE FROM tbl_depth_snapshots AS d
+WHERE d.recorded_at < $1 AND EXISTS (
+    SELECT 1 FROM tbl_depth_snapshots AS n
+    WHERE n.tenant_id = d.tenant_id AND n.pair_id = d.pair_id AND n.recorded_at > d.recorded_at AND n.recorded_at <= $1
+);
@@ -0,0 +1,10 @@
+CREATE TABLE tbl_depth_snapshots
+(
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL,
+    pair_id VARCHAR(25) NOT NULL,
+    seq BIGINT NOT NULL,
+    asks JSONB NOT NULL,
+    bids JSONB NOT NULL,
+    recorded_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,116 @@
+package depthhistory
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type sqliteRepo struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func (repo *sqliteRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+const sqliteSnapshotColumns = "tenant_id, pair_id, seq, asks, bids, recorded_at"
+
+func scanSQLiteSnapshot(row interface{ Scan(...any) error }) (Snapshot, error) {
+	var (
+		s          Snapshot
+		asks, bids string
+	)
+	if err := row.Scan(&s.TenantID, &s.PairID, &s.Seq, &asks, &bids, &s.RecordedAt); err != nil {
+		return Snapshot{}, err
+	}
+	if err := json.Unmarshal([]byte(asks), &s.Asks); err != nil {
+		return Snapshot{}, err
+	}
+	err := json.Unmarshal([]byte(bids), &s.Bids)
+	return s, err
+}
+
+func (repo *sqliteRepo) AddSnapshot(ctx context.Context, s Snapshot) error {
+	asks, err := json.Marshal(s.Asks)
+	if err != nil {
+		return err
+	}
+	bids, err := json.Marshal(s.Bids)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err = repo.db.ExecContext(ctx,
+		"INSERT INTO tbl_depth_snapshots ("+sqliteSnapshotColumns+") VALUES (?, ?, ?, ?, ?, ?)",
+		s.TenantID, s.PairID, s.Seq, string(asks), string(bids), s.RecordedAt.UTC(),
+	)
+	return err
+}
+
+func (repo *sqliteRepo) GetSnapshotAt(ctx context.Context, tenantId string, pairId string, at time.Time) (Snapshot, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	res, err := scanSQLiteSnapshot(repo.db.QueryRowContext(ctx,
+		"SELECT "+sqliteSnapshotColumns+" FROM tbl_depth_snapshots WHERE tenant_id = ? AND pair_id = ? AND recorded_at <= ? ORDER BY recorded_at DESC LIMIT 1",
+		tenantId, pairId, at.UTC(),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Snapshot{}, ErrSnapshotNotFound
+	}
+	return res, err
+}
+
+func (repo *sqliteRepo) GetSnapshots(ctx context.Context, tenantId string, pairId string, from time.Time, to time.Time, limit int) ([]Snapshot, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	rows, err := repo.db.QueryContext(ctx,
+		"SELECT "+sqliteSnapshotColumns+" FROM tbl_depth_snapshots WHERE tenant_id = ? AND pair_id = ? AND recorded_at >= ? AND recorded_at < ? ORDER BY recorded_at LIMIT ?",
+		tenantId, pairId, from.UTC(), to.UTC(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	snapshots := []Snapshot{}
+	for rows.Next() {
+		s, err := scanSQLiteSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+func (repo *sqliteRepo) DeleteSnapshotsBefore(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	res, err := repo.db.ExecContext(ctx,
+		`DELETE FROM tbl_depth_snapshots AS d
+		WHERE d.recorded_at < ? AND EXISTS (
+			SELECT 1 FROM tbl_depth_snapshots AS n
+			WHERE n.tenant_id = d.tenant_id AND n.pair_id = d.pair_id AND n.recorded_at > d.recorded_at AND n.recorded_at <= ?
+		)`,
+		before.UTC(), before.UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// NewSQLiteRepository is NewRepository on a SQLite database
+func NewSQLiteRepository(db *sql.DB, queryTimeout time.Duration) Repo {
+	return &sqliteRepo{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
@@ -0,0 +1,50 @@
+package diagnostics
+
+import (
+	"net/http"
+	"order-book/book"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/pprof"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindDiagnosticsRouter serves net/http/pprof under /debug/pprof along with
+// the runtime statistics and the size of the books. It's meant for the admin
+// listener only, profiles expose the internals of the process.
+func BindDiagnosticsRouter(r fiber.Router, books *book.Registry) {
+	r.Use(pprof.New())
+
+	r.Get("/debug/runtime", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    ReadRuntime(),
+		})
+	})
+
+	r.Get("/debug/books", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    BookSizes(books),
+		})
+	})
+}
+
+// BindStatsRouter serves GET /admin/stats, the depth, rates and memory of
+// every book in one document for dashboards like a Grafana JSON datasource
+func BindStatsRouter(r fiber.Router, books *book.Registry, rates *Rates) {
+	r.Get("/admin/stats", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    ReadStats(books, rates),
+		})
+	})
+}
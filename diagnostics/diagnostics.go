@@ -0,0 +1,105 @@
+package diagnostics
+
+import (
+	"order-book/book"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Runtime struct {
+	Goroutines int    `json:"goroutines"`
+	GOMAXPROCS int    `json:"gomaxprocs"`
+	NumCPU     int    `json:"num_cpu"`
+	GoVersion  string `json:"go_version"`
+	Memory     Memory `json:"memory"`
+	GC         GC     `json:"gc"`
+}
+
+type Memory struct {
+	HeapAlloc   uint64 `json:"heap_alloc"`
+	HeapInuse   uint64 `json:"heap_inuse"`
+	HeapObjects uint64 `json:"heap_objects"`
+	StackInuse  uint64 `json:"stack_inuse"`
+	Sys         uint64 `json:"sys"`
+}
+
+// GC reports the collections so far. RecentPauses are the last pauses,
+// newest first, to tell a long stop of the world apart from many short ones.
+type GC struct {
+	NumGC        uint32          `json:"num_gc"`
+	NextGC       uint64          `json:"next_gc"`
+	LastGC       time.Time       `json:"last_gc"`
+	PauseTotal   time.Duration   `json:"pause_total"`
+	RecentPauses []time.Duration `json:"recent_pauses"`
+	CPUFraction  float64         `json:"cpu_fraction"`
+	ForcedGC     uint32          `json:"forced_gc"`
+}
+
+// ReadRuntime reads the runtime statistics, it stops the world briefly
+func ReadRuntime() Runtime {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	pauses := make([]time.Duration, 0, 16)
+	for i := 0; i < min(int(m.NumGC), 16); i++ {
+		// PauseNs is a circular buffer indexed by NumGC
+		pauses = append(pauses, time.Duration(m.PauseNs[(int(m.NumGC)-1-i+256)%256]))
+	}
+	var lastGC time.Time
+	if m.LastGC != 0 {
+		lastGC = time.Unix(0, int64(m.LastGC))
+	}
+	return Runtime{
+		Goroutines: runtime.NumGoroutine(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		GoVersion:  runtime.Version(),
+		Memory: Memory{
+			HeapAlloc:   m.HeapAlloc,
+			HeapInuse:   m.HeapInuse,
+			HeapObjects: m.HeapObjects,
+			StackInuse:  m.StackInuse,
+			Sys:         m.Sys,
+		},
+		GC: GC{
+			NumGC:        m.NumGC,
+			NextGC:       m.NextGC,
+			LastGC:       lastGC,
+			PauseTotal:   time.Duration(m.PauseTotalNs),
+			RecentPauses: pauses,
+			CPUFraction:  m.GCCPUFraction,
+			ForcedGC:     m.NumForcedGC,
+		},
+	}
+}
+
+type BookSize struct {
+	TenantID   string                   `json:"tenant_id"`
+	QueueDepth int                      `json:"queue_depth"`
+	Seq        int64                    `json:"seq"`
+	Pairs      map[string]book.PairSize `json:"pairs"`
+}
+
+// BookSizes lists the resting levels and orders of every pair of every
+// tenant book
+func BookSizes(books *book.Registry) []BookSize {
+	sizes := []BookSize{}
+	for tenantId, b := range books.Books() {
+		size := BookSize{
+			TenantID:   tenantId,
+			QueueDepth: b.QueueDepth(),
+			Seq:        b.Seq(),
+			Pairs:      make(map[string]book.PairSize),
+		}
+		for _, pairId := range b.Pairs() {
+			size.Pairs[pairId] = b.Size(pairId)
+		}
+		sizes = append(sizes, size)
+	}
+	slices.SortFunc(sizes, func(a, b BookSize) int {
+		return strings.Compare(a.TenantID, b.TenantID)
+	})
+	return sizes
+}
@@ -0,0 +1,181 @@
+package diagnostics
+
+import (
+	"order-book/book"
+	"order-book/order"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/emirpasic/gods/trees/redblacktree"
+	"github.com/shopspring/decimal"
+)
+
+// rateWindow is the number of seconds Rates keeps counts for
+const rateWindow = 5 * 60
+
+// Rates counts the orders created and the trades of every pair by second,
+// over the last five minutes
+type Rates struct {
+	mu     sync.Mutex
+	counts map[rateKey]*pairCounts
+}
+
+type rateKey struct {
+	tenantId string
+	pairId   string
+}
+
+// pairCounts is a ring of one bucket a second, a bucket holds the counts of
+// the unix second sec
+type pairCounts struct {
+	buckets [rateWindow]struct {
+		sec    int64
+		orders int
+		trades int
+	}
+}
+
+// Rate is the average number of events a second over the last minute and the
+// last five minutes
+type Rate struct {
+	OneMinute   float64 `json:"1m"`
+	FiveMinutes float64 `json:"5m"`
+}
+
+func NewRates() *Rates {
+	return &Rates{counts: make(map[rateKey]*pairCounts)}
+}
+
+// Attach counts the orders and trades of the book of a tenant, it is meant to
+// be run from a Registry.OnBook hook
+func (r *Rates) Attach(tenantId string, b book.Book) {
+	b.OnOrderEvent(func(ev order.OrderEvent) {
+		if ev.Name == order.ORDER_CREATED {
+			r.add(rateKey{tenantId, ev.Order.PairID}, ev.At, 1, 0)
+		}
+	})
+	b.OnTrade(func(t order.Trade) {
+		r.add(rateKey{tenantId, t.PairID}, t.ExecutedAt, 0, 1)
+	})
+}
+
+func (r *Rates) add(key rateKey, at time.Time, orders int, trades int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts, ok := r.counts[key]
+	if !ok {
+		counts = &pairCounts{}
+		r.counts[key] = counts
+	}
+	sec := at.Unix()
+	bucket := &counts.buckets[sec%rateWindow]
+	if bucket.sec != sec {
+		bucket.sec, bucket.orders, bucket.trades = sec, 0, 0
+	}
+	bucket.orders += orders
+	bucket.trades += trades
+}
+
+// rates returns the order and trade rates of a pair as of now
+func (r *Rates) rates(tenantId string, pairId string, now time.Time) (orders Rate, trades Rate) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts, ok := r.counts[rateKey{tenantId, pairId}]
+	if !ok {
+		return Rate{}, Rate{}
+	}
+	// The current second is still counting, the windows end before it
+	sec := now.Unix()
+	var orders1m, trades1m, orders5m, trades5m int
+	for _, bucket := range counts.buckets {
+		age := sec - bucket.sec
+		if age < 1 || age > rateWindow {
+			continue
+		}
+		if age <= 60 {
+			orders1m += bucket.orders
+			trades1m += bucket.trades
+		}
+		orders5m += bucket.orders
+		trades5m += bucket.trades
+	}
+	orders = Rate{OneMinute: float64(orders1m) / 60, FiveMinutes: float64(orders5m) / rateWindow}
+	trades = Rate{OneMinute: float64(trades1m) / 60, FiveMinutes: float64(trades5m) / rateWindow}
+	return orders, trades
+}
+
+// Stats is the state of every book in one document for dashboards
+type Stats struct {
+	At      time.Time     `json:"at"`
+	Tenants []TenantStats `json:"tenants"`
+	Memory  Memory        `json:"memory"`
+}
+
+type TenantStats struct {
+	TenantID      string      `json:"tenant_id"`
+	QueueDepth    int         `json:"queue_depth"`
+	QueueCapacity int         `json:"queue_capacity"`
+	OpenOrders    int         `json:"open_orders"`
+	BookBytes     int64       `json:"book_bytes"`
+	Pairs         []PairStats `json:"pairs"`
+}
+
+// PairStats is the depth of a pair, the orders created and trades a second
+// and the estimated memory its resting orders and price levels take
+type PairStats struct {
+	PairID string `json:"pair_id"`
+	book.PairSize
+	BestBid   *decimal.Decimal `json:"best_bid"`
+	BestAsk   *decimal.Decimal `json:"best_ask"`
+	OrderRate Rate             `json:"order_rate"`
+	TradeRate Rate             `json:"trade_rate"`
+	Bytes     int64            `json:"bytes"`
+}
+
+// Sizes of the structures of a book, decimals point to a big.Int of a few
+// words that is counted along with them
+var (
+	orderBytes = int64(unsafe.Sizeof(order.Order{})) + 2*bigIntBytes
+	levelBytes = int64(unsafe.Sizeof(redblacktree.Node{})+unsafe.Sizeof(order.OrderList{})) + int64(unsafe.Sizeof(decimal.Decimal{})) + bigIntBytes
+)
+
+const bigIntBytes = 48
+
+// ReadStats reads the stats of every tenant book. The runtime memory
+// statistics stop the world briefly.
+func ReadStats(books *book.Registry, rates *Rates) Stats {
+	now := time.Now()
+	stats := Stats{At: now, Tenants: []TenantStats{}, Memory: ReadRuntime().Memory}
+	for tenantId, b := range books.Books() {
+		h := b.Health()
+		tenant := TenantStats{
+			TenantID:      tenantId,
+			QueueDepth:    h.QueueDepth,
+			QueueCapacity: h.QueueCapacity,
+			OpenOrders:    b.OpenOrders(),
+			Pairs:         []PairStats{},
+		}
+		for _, pairId := range b.Pairs() {
+			size := b.Size(pairId)
+			pair := PairStats{
+				PairID:   pairId,
+				PairSize: size,
+				Bytes:    int64(size.AskLevels+size.BidLevels)*levelBytes + int64(size.AskOrders+size.BidOrders)*orderBytes,
+			}
+			if bid, ask, ok := b.Top(pairId); ok {
+				pair.BestBid, pair.BestAsk = &bid, &ask
+			}
+			pair.OrderRate, pair.TradeRate = rates.rates(tenantId, pairId, now)
+			tenant.BookBytes += pair.Bytes
+			tenant.Pairs = append(tenant.Pairs, pair)
+		}
+		stats.Tenants = append(stats.Tenants, tenant)
+	}
+	slices.SortFunc(stats.Tenants, func(a, b TenantStats) int {
+		return strings.Compare(a.TenantID, b.TenantID)
+	})
+	return stats
+}
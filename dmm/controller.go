@@ -0,0 +1,65 @@
+package dmm
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindDMMRouter serves the obligations of the designated market makers on
+// GET /admin/dmm and their compliance on GET /admin/dmm/report, per hour or
+// day of period from from to to, the last day by default
+func BindDMMRouter(r fiber.Router, t *Tracker) {
+	r.Get("/admin/dmm", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    t.Obligations(),
+		})
+	})
+
+	r.Get("/admin/dmm/report", func(c *fiber.Ctx) error {
+		period := c.Query("period", HOUR)
+		to := time.Now()
+		if raw := c.Query("to"); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				c.Status(http.StatusBadRequest)
+				return c.JSON(&Response{Message: "to should be an RFC 3339 time"})
+			}
+			to = parsed
+		}
+		from := to.Add(-24 * time.Hour)
+		if raw := c.Query("from"); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				c.Status(http.StatusBadRequest)
+				return c.JSON(&Response{Message: "from should be an RFC 3339 time"})
+			}
+			from = parsed
+		}
+		// The hours are keyed by their start, the one to falls in is kept
+		from = from.Truncate(time.Hour)
+
+		reports, err := t.Reports(period, from, to, c.QueryInt("account_id"), c.Query("pair_id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{Message: err.Error()})
+		}
+		if reports == nil {
+			reports = []Report{}
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    reports,
+		})
+	})
+}
@@ -0,0 +1,351 @@
+// Package dmm tracks the obligations of the designated market makers. The
+// book of each designated account and pair is sampled every interval, and
+// the time the account spent quoting and at the best bid and offer is
+// accumulated per hour for the compliance reports.
+package dmm
+
+import (
+	"context"
+	"errors"
+	"order-book/book"
+	"order-book/logger"
+	"order-book/order"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Periods of the reports
+const (
+	HOUR = "hour"
+	DAY  = "day"
+)
+
+var ErrInvalidPeriod = errors.New("Period should be hour or day")
+
+var dmmLog = logger.Component("dmm")
+
+// Obligation designates an account as market maker of a pair. The account
+// quotes while it rests at least MinSize on both sides at most MaxSpreadBps
+// apart, 0 for no limit, and is at the BBO while both its sides are the
+// best of the book. It complies over a period when it quoted MinQuoting and
+// was at the BBO MinAtBBO of the time the pair was open.
+type Obligation struct {
+	AccountID    int             `json:"account_id"`
+	PairID       string          `json:"pair_id"`
+	MinSize      decimal.Decimal `json:"min_size"`
+	MaxSpreadBps float64         `json:"max_spread_bps"`
+	MinQuoting   float64         `json:"min_quoting"`
+	MinAtBBO     float64         `json:"min_at_bbo"`
+}
+
+type key struct {
+	accountId int
+	pairId    string
+}
+
+// bucket is what was measured of an obligation over an hour
+type bucket struct {
+	observed   time.Duration
+	quoting    time.Duration
+	atBBO      time.Duration
+	spreadBps  float64
+	twoSided   int
+	lastSample time.Time
+}
+
+// Report is the compliance of an account with its obligation on a pair over
+// a period. The spread is averaged over the samples the account quoted both
+// sides in.
+type Report struct {
+	AccountID       int        `json:"account_id"`
+	PairID          string     `json:"pair_id"`
+	Period          string     `json:"period"`
+	Start           time.Time  `json:"start"`
+	ObservedSeconds float64    `json:"observed_seconds"`
+	QuotingSeconds  float64    `json:"quoting_seconds"`
+	AtBBOSeconds    float64    `json:"at_bbo_seconds"`
+	Quoting         float64    `json:"quoting"`
+	AtBBO           float64    `json:"at_bbo"`
+	AvgSpreadBps    float64    `json:"avg_spread_bps"`
+	Obligation      Obligation `json:"obligation"`
+	Compliant       bool       `json:"compliant"`
+}
+
+// Books resolves the book an account trades on
+type Books interface {
+	ForAccount(accountId int) book.Book
+}
+
+// Tracker samples the books for the obligations. Hours older than retention
+// are dropped.
+type Tracker struct {
+	books     Books
+	interval  time.Duration
+	retention time.Duration
+	// open reports whether a pair trades, its closed hours aren't measured
+	open func(pairId string) bool
+
+	mu          sync.Mutex
+	obligations map[key]Obligation
+	hours       map[key]map[time.Time]*bucket
+	now         func() time.Time
+}
+
+func NewTracker(books Books, obligations []Obligation, interval time.Duration, retention time.Duration, open func(pairId string) bool) *Tracker {
+	t := &Tracker{
+		books:     books,
+		interval:  interval,
+		retention: retention,
+		open:      open,
+		hours:     make(map[key]map[time.Time]*bucket),
+		now:       time.Now,
+	}
+	t.SetObligations(obligations)
+	return t
+}
+
+// SetObligations replaces the obligations, what was measured of those kept
+// stays
+func (t *Tracker) SetObligations(obligations []Obligation) {
+	byKey := make(map[key]Obligation, len(obligations))
+	for _, o := range obligations {
+		byKey[key{o.AccountID, o.PairID}] = o
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.obligations = byKey
+}
+
+// Obligations lists the obligations by account and pair
+func (t *Tracker) Obligations() []Obligation {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := make([]Obligation, 0, len(t.obligations))
+	for _, o := range t.obligations {
+		list = append(list, o)
+	}
+	slices.SortFunc(list, compareObligations)
+	return list
+}
+
+func compareObligations(a, b Obligation) int {
+	if a.AccountID != b.AccountID {
+		return a.AccountID - b.AccountID
+	}
+	switch {
+	case a.PairID < b.PairID:
+		return -1
+	case a.PairID > b.PairID:
+		return 1
+	}
+	return 0
+}
+
+// Run samples the books every interval until ctx is done
+func (t *Tracker) Run(ctx context.Context) {
+	dmmLog.Info("dmm tracking started", map[string]any{
+		"obligations": len(t.Obligations()),
+		"interval":    t.interval.String(),
+	})
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.sample()
+		}
+	}
+}
+
+func (t *Tracker) sample() {
+	t.mu.Lock()
+	obligations := make([]Obligation, 0, len(t.obligations))
+	for _, o := range t.obligations {
+		obligations = append(obligations, o)
+	}
+	t.mu.Unlock()
+
+	now := t.now()
+	for _, o := range obligations {
+		if t.open != nil && !t.open(o.PairID) {
+			continue
+		}
+		quoting, atBBO, spreadBps, twoSided := measure(t.books.ForAccount(o.AccountID), o)
+		t.record(o, now, quoting, atBBO, spreadBps, twoSided)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cutoff := now.Add(-t.retention)
+	for k, hours := range t.hours {
+		for hour := range hours {
+			if hour.Before(cutoff) {
+				delete(hours, hour)
+			}
+		}
+		if len(hours) == 0 {
+			delete(t.hours, k)
+		}
+	}
+}
+
+// measure reads the best sides of the account and of the book
+func measure(b book.Book, o Obligation) (quoting bool, atBBO bool, spreadBps float64, twoSided bool) {
+	bestBid, bestAsk, ok := b.Top(o.PairID)
+	if !ok {
+		return
+	}
+	var (
+		bid, ask         decimal.Decimal
+		bidSize, askSize decimal.Decimal
+	)
+	for _, ord := range b.GetAccountOrders(o.AccountID) {
+		if ord.PairID != o.PairID {
+			continue
+		}
+		switch {
+		case ord.Type == order.BID && (bidSize.IsZero() || ord.Price.GreaterThan(bid)):
+			bid, bidSize = ord.Price, ord.Amount
+		case ord.Type == order.BID && ord.Price.Equal(bid):
+			bidSize = bidSize.Add(ord.Amount)
+		case ord.Type == order.ASK && (askSize.IsZero() || ord.Price.LessThan(ask)):
+			ask, askSize = ord.Price, ord.Amount
+		case ord.Type == order.ASK && ord.Price.Equal(ask):
+			askSize = askSize.Add(ord.Amount)
+		}
+	}
+	if bidSize.IsZero() || askSize.IsZero() {
+		return
+	}
+	twoSided = true
+	mid := bid.Add(ask).Div(decimal.NewFromInt(2))
+	spreadBps = ask.Sub(bid).Div(mid).InexactFloat64() * 10000
+	quoting = !bidSize.LessThan(o.MinSize) && !askSize.LessThan(o.MinSize) &&
+		(o.MaxSpreadBps <= 0 || spreadBps <= o.MaxSpreadBps)
+	atBBO = quoting && bid.Equal(bestBid) && ask.Equal(bestAsk)
+	return
+}
+
+// record adds a sample to the hour it falls in, it stands for the time since
+// the previous sample up to two intervals
+func (t *Tracker) record(o Obligation, now time.Time, quoting bool, atBBO bool, spreadBps float64, twoSided bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	k := key{o.AccountID, o.PairID}
+	hours, ok := t.hours[k]
+	if !ok {
+		hours = make(map[time.Time]*bucket)
+		t.hours[k] = hours
+	}
+	hour := now.UTC().Truncate(time.Hour)
+	b, ok := hours[hour]
+	if !ok {
+		b = &bucket{}
+		hours[hour] = b
+	}
+	elapsed := t.interval
+	if prev := t.lastSample(k, now); !prev.IsZero() {
+		elapsed = min(now.Sub(prev), 2*t.interval)
+	}
+	b.lastSample = now
+	b.observed += elapsed
+	if quoting {
+		b.quoting += elapsed
+	}
+	if atBBO {
+		b.atBBO += elapsed
+	}
+	if twoSided {
+		b.spreadBps += spreadBps
+		b.twoSided++
+	}
+}
+
+// lastSample is the time of the latest sample of an obligation before now,
+// callers hold t.mu
+func (t *Tracker) lastSample(k key, now time.Time) time.Time {
+	var last time.Time
+	for _, b := range t.hours[k] {
+		if b.lastSample.Before(now) && b.lastSample.After(last) {
+			last = b.lastSample
+		}
+	}
+	return last
+}
+
+// Reports returns the compliance of the obligations over the hours or days
+// starting in [from, to), of an account or pair when they're set
+func (t *Tracker) Reports(period string, from time.Time, to time.Time, accountId int, pairId string) ([]Report, error) {
+	var truncate func(time.Time) time.Time
+	switch period {
+	case HOUR:
+		truncate = func(at time.Time) time.Time { return at.Truncate(time.Hour) }
+	case DAY:
+		truncate = func(at time.Time) time.Time { return at.Truncate(24 * time.Hour) }
+	default:
+		return nil, ErrInvalidPeriod
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var reports []Report
+	for k, o := range t.obligations {
+		if (accountId != 0 && k.accountId != accountId) || (pairId != "" && k.pairId != pairId) {
+			continue
+		}
+		periods := make(map[time.Time]*bucket)
+		for hour, b := range t.hours[k] {
+			if hour.Before(from) || !hour.Before(to) {
+				continue
+			}
+			start := truncate(hour)
+			sum, ok := periods[start]
+			if !ok {
+				sum = &bucket{}
+				periods[start] = sum
+			}
+			sum.observed += b.observed
+			sum.quoting += b.quoting
+			sum.atBBO += b.atBBO
+			sum.spreadBps += b.spreadBps
+			sum.twoSided += b.twoSided
+		}
+		for start, sum := range periods {
+			reports = append(reports, report(o, period, start, sum))
+		}
+	}
+	slices.SortFunc(reports, func(a, b Report) int {
+		if c := compareObligations(a.Obligation, b.Obligation); c != 0 {
+			return c
+		}
+		return a.Start.Compare(b.Start)
+	})
+	return reports, nil
+}
+
+func report(o Obligation, period string, start time.Time, b *bucket) Report {
+	r := Report{
+		AccountID:       o.AccountID,
+		PairID:          o.PairID,
+		Period:          period,
+		Start:           start,
+		ObservedSeconds: b.observed.Seconds(),
+		QuotingSeconds:  b.quoting.Seconds(),
+		AtBBOSeconds:    b.atBBO.Seconds(),
+		Obligation:      o,
+	}
+	if b.observed > 0 {
+		r.Quoting = b.quoting.Seconds() / b.observed.Seconds()
+		r.AtBBO = b.atBBO.Seconds() / b.observed.Seconds()
+	}
+	if b.twoSided > 0 {
+		r.AvgSpreadBps = b.spreadBps / float64(b.twoSided)
+	}
+	r.Compliant = r.Quoting >= o.MinQuoting && r.AtBBO >= o.MinAtBBO
+	return r
+}
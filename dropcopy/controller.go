@@ -0,0 +1,115 @@
+package dropcopy
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"order-book/logger"
+	"order-book/metrics"
+	"order-book/panics"
+	"order-book/shutdown"
+	"order-book/wslimit"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// wsLog is shared with the other streams through the ws component
+var wsLog = logger.Component("ws")
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindDropCopyRouter exposes the feed to the compliance consumer holding
+// token. The route is disabled when no token is configured.
+func BindDropCopyRouter(r fiber.Router, feed *Feed, token string) {
+	r.Get("/ws/drop-copy", func(c *fiber.Ctx) error {
+		provided := c.Get("X-Drop-Copy-Token", c.Query("token"))
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.Status(http.StatusUnauthorized)
+			return c.JSON(&Response{
+				Message: "Invalid drop-copy token",
+				Data:    nil,
+			})
+		}
+		return c.Next()
+	}, websocket.New(func(c *websocket.Conn) {
+		defer panics.Recover("ws", map[string]any{"stream": "drop_copy"})
+		defer shutdown.TrackWebsocket(c)()
+		defer wslimit.Release(c)
+		defer c.Close()
+		stream := metrics.Stream("drop_copy")
+		stream.Connected()
+		reason := metrics.DisconnectClientClosed
+		defer func() { stream.Disconnected(reason) }()
+
+		fromSeq, err := strconv.ParseInt(c.Query("from_seq", "0"), 10, 64)
+		if err != nil {
+			c.WriteJSON(&Response{
+				Message: "from_seq should be a number",
+			})
+			reason = metrics.DisconnectInvalidRequest
+			return
+		}
+
+		reports, err := feed.Subscribe(fromSeq)
+		if err != nil {
+			c.WriteJSON(&Response{
+				Message: err.Error(),
+			})
+			reason = metrics.DisconnectInvalidRequest
+			return
+		}
+		defer feed.Unsubscribe(reports)
+		stream.Subscribed()
+		defer stream.Unsubscribed()
+
+		// The feed is read-only, reading only detects the consumer going away
+		closed := make(chan struct{})
+		go func() {
+			defer panics.Recover("ws", map[string]any{"stream": "drop_copy"})
+			defer close(closed)
+			for {
+				if _, _, err := c.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		ticker := time.NewTicker(time.Second * 30)
+		defer ticker.Stop()
+		for {
+			select {
+			case r, ok := <-reports:
+				if !ok {
+					c.WriteJSON(&Response{
+						Message: "Consumer fell behind, reconnect with from_seq",
+					})
+					reason = metrics.DisconnectSlowConsumer
+					return
+				}
+				if err := c.WriteJSON(r); err != nil {
+					wsLog.Error("Error while sending drop-copy report", map[string]any{
+						"err": err,
+						"seq": r.Seq,
+					})
+					reason = metrics.DisconnectWriteError
+					return
+				}
+				stream.Sent()
+			case <-ticker.C:
+				err := c.WriteControl(websocket.PingMessage, []byte("Ping message"), time.Now().Add(5*time.Second))
+				if err != nil {
+					reason = metrics.DisconnectPingFailed
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	}))
+}
@@ -0,0 +1,185 @@
+package dropcopy
+
+import (
+	"errors"
+	"order-book/logger"
+	"order-book/metrics"
+	"order-book/order"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrSequenceTooOld = errors.New("Requested sequence is no longer available")
+
+type ExecType string
+
+const (
+	NEW      ExecType = "NEW"
+	CANCELED ExecType = "CANCELED"
+	TRADE    ExecType = "TRADE"
+)
+
+type Liquidity string
+
+const (
+	MAKER Liquidity = "MAKER"
+	TAKER Liquidity = "TAKER"
+)
+
+// ExecutionReport mirrors one execution of one account. A trade produces a
+// report for each side. Seq is gapless so the consumer can detect lost reports.
+type ExecutionReport struct {
+	Seq          int64           `json:"seq"`
+	ExecType     ExecType        `json:"exec_type"`
+	OrderID      int             `json:"order_id"`
+	AccountID    int             `json:"account_id"`
+	PairID       string          `json:"pair_id"`
+	Side         string          `json:"side"`
+	Price        decimal.Decimal `json:"price,omitzero"`
+	Amount       decimal.Decimal `json:"amount,omitzero"`
+	TradeID      int64           `json:"trade_id,omitempty"`
+	LastPrice    decimal.Decimal `json:"last_price,omitzero"`
+	LastAmount   decimal.Decimal `json:"last_amount,omitzero"`
+	Liquidity    Liquidity       `json:"liquidity,omitempty"`
+	Tag          string          `json:"tag,omitempty"`
+	StrategyID   string          `json:"strategy_id,omitempty"`
+	TransactTime time.Time       `json:"transact_time"`
+}
+
+// Feed fans execution reports of every account out to the drop-copy
+// consumers. The last reports are kept so a reconnecting consumer can resume
+// from the sequence it saw last.
+type Feed struct {
+	mu          sync.Mutex
+	seq         int64
+	history     []ExecutionReport
+	historySize int
+	subscribers map[chan ExecutionReport]struct{}
+}
+
+func NewFeed(historySize int) *Feed {
+	return &Feed{
+		historySize: historySize,
+		subscribers: make(map[chan ExecutionReport]struct{}),
+	}
+}
+
+func (f *Feed) OnOrderEvent(ev order.OrderEvent) {
+	var execType ExecType
+	switch ev.Name {
+	case order.ORDER_CREATED:
+		execType = NEW
+	case order.ORDER_CANCELLED:
+		execType = CANCELED
+	default:
+		return
+	}
+	o := ev.Order
+	f.publish(ExecutionReport{
+		ExecType:     execType,
+		OrderID:      o.ID,
+		AccountID:    o.AccountID,
+		PairID:       o.PairID,
+		Side:         o.Type.String(),
+		Price:        o.Price,
+		Amount:       o.Amount,
+		Tag:          o.Tag,
+		StrategyID:   o.StrategyID,
+		TransactTime: ev.At,
+	})
+}
+
+func (f *Feed) OnTrade(t order.Trade) {
+	makerSide := order.ASK
+	if t.TakerSide == order.ASK {
+		makerSide = order.BID
+	}
+	f.publish(ExecutionReport{
+		ExecType:     TRADE,
+		OrderID:      t.MakerOrderID,
+		AccountID:    t.MakerAccountID,
+		PairID:       t.PairID,
+		Side:         makerSide.String(),
+		TradeID:      t.ID,
+		LastPrice:    t.Price,
+		LastAmount:   t.Amount,
+		Liquidity:    MAKER,
+		Tag:          t.MakerTag,
+		StrategyID:   t.MakerStrategyID,
+		TransactTime: t.ExecutedAt,
+	}, ExecutionReport{
+		ExecType:     TRADE,
+		OrderID:      t.TakerOrderID,
+		AccountID:    t.TakerAccountID,
+		PairID:       t.PairID,
+		Side:         t.TakerSide.String(),
+		TradeID:      t.ID,
+		LastPrice:    t.Price,
+		LastAmount:   t.Amount,
+		Liquidity:    TAKER,
+		Tag:          t.TakerTag,
+		StrategyID:   t.TakerStrategyID,
+		TransactTime: t.ExecutedAt,
+	})
+}
+
+// publish runs on the engine goroutine, a consumer that can't keep up is
+// disconnected instead of slowing the engine down
+func (f *Feed) publish(reports ...ExecutionReport) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, r := range reports {
+		f.seq++
+		r.Seq = f.seq
+		f.history = append(f.history, r)
+		if len(f.history) > f.historySize {
+			f.history = f.history[len(f.history)-f.historySize:]
+		}
+		for ch := range f.subscribers {
+			select {
+			case ch <- r:
+			default:
+				logger.Warn("drop-copy consumer is too slow, disconnecting", map[string]any{
+					"seq": r.Seq,
+				})
+				metrics.Stream("drop_copy").Overflowed()
+				delete(f.subscribers, ch)
+				close(ch)
+			}
+		}
+	}
+}
+
+// Subscribe returns a channel of every report after fromSeq. A fromSeq of 0
+// starts from the next report. The channel is closed if the consumer falls behind.
+func (f *Feed) Subscribe(fromSeq int64) (chan ExecutionReport, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var backlog []ExecutionReport
+	if fromSeq > 0 && fromSeq < f.seq {
+		if len(f.history) == 0 || f.history[0].Seq > fromSeq+1 {
+			return nil, ErrSequenceTooOld
+		}
+		backlog = f.history[fromSeq+1-f.history[0].Seq:]
+	}
+
+	ch := make(chan ExecutionReport, len(backlog)+1024)
+	for _, r := range backlog {
+		ch <- r
+	}
+	f.subscribers[ch] = struct{}{}
+	return ch, nil
+}
+
+func (f *Feed) Unsubscribe(ch chan ExecutionReport) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.subscribers[ch]; ok {
+		delete(f.subscribers, ch)
+		close(ch)
+	}
+}
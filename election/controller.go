@@ -0,0 +1,25 @@
+package election
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindElectionRouter serves GET /admin/election, the lease of the instance
+// and whether it leads
+func BindElectionRouter(r fiber.Router, elector *Elector) {
+	r.Get("/admin/election", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    elector.Status(),
+		})
+	})
+}
@@ -0,0 +1,234 @@
+package election
+
+import (
+	"context"
+	"errors"
+	"order-book/logger"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrNotLeader = errors.New("This instance isn't the leader, writes go to the leader")
+	ErrDeposed   = errors.New("The lease of the engine was lost")
+)
+
+// Status is the election state of the instance for operators
+type Status struct {
+	Lease     string    `json:"lease"`
+	Holder    string    `json:"holder"`
+	Leader    bool      `json:"leader"`
+	Token     int64     `json:"token"`
+	ElectedAt time.Time `json:"elected_at,omitzero"`
+}
+
+// Elector campaigns for a lease with a Postgres advisory lock, held by a
+// connection kept out of the pool. The lock goes with the session, an
+// instance that can't reach the database anymore loses it once Postgres
+// drops its connection, and another instance is elected with a newer token.
+type Elector struct {
+	pool     *pgxpool.Pool
+	fence    *Fence
+	holder   string
+	interval time.Duration
+
+	mu        sync.Mutex
+	conn      *pgxpool.Conn
+	leader    bool
+	electedAt time.Time
+	onElected []func(ctx context.Context) error
+	onDeposed []func(err error)
+}
+
+// NewElector campaigns for the lease of fence as holder, like the host name
+// of the instance, every interval
+func NewElector(pool *pgxpool.Pool, fence *Fence, holder string, interval time.Duration) *Elector {
+	return &Elector{
+		pool:     pool,
+		fence:    fence,
+		holder:   holder,
+		interval: interval,
+	}
+}
+
+// OnElected registers a hook run once the lease is won, before the instance
+// takes writes, like loading the books. An error gives the lease back.
+func (e *Elector) OnElected(fn func(ctx context.Context) error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onElected = append(e.onElected, fn)
+}
+
+// OnDeposed registers a hook run when the leader loses the lease
+func (e *Elector) OnDeposed(fn func(err error)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onDeposed = append(e.onDeposed, fn)
+}
+
+// Run campaigns until ctx is done, the leader then gives the lease back
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		if e.Leader() {
+			if err := e.check(ctx); err != nil && ctx.Err() == nil {
+				e.depose(err)
+			}
+		} else if err := e.campaign(ctx); err != nil && ctx.Err() == nil {
+			logger.Warn("failed to campaign for the engine lease", map[string]any{
+				"lease": e.fence.lease,
+				"error": err,
+			})
+		}
+		select {
+		case <-ctx.Done():
+			e.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// campaign tries the lock once, the winner bumps the token of the lease
+func (e *Elector) campaign(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, e.interval)
+	defer cancel()
+	conn, err := e.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	var locked bool
+	err = conn.QueryRow(ctx, "SELECT pg_try_advisory_lock(hashtext('engine_lease:' || $1))", e.fence.lease).Scan(&locked)
+	if err != nil || !locked {
+		conn.Release()
+		return err
+	}
+	var token int64
+	err = conn.QueryRow(ctx, `INSERT INTO tbl_engine_leases (name, token, holder, acquired_at)
+		VALUES ($1, 1, $2, NOW())
+		ON CONFLICT (name) DO UPDATE SET token = tbl_engine_leases.token + 1, holder = $2, acquired_at = NOW()
+		RETURNING token`, e.fence.lease, e.holder).Scan(&token)
+	if err != nil {
+		e.unlock(conn)
+		return err
+	}
+	e.fence.set(token)
+
+	e.mu.Lock()
+	hooks := e.onElected
+	e.mu.Unlock()
+	for _, fn := range hooks {
+		if err := fn(context.WithoutCancel(ctx)); err != nil {
+			e.fence.set(0)
+			e.unlock(conn)
+			return err
+		}
+	}
+
+	e.mu.Lock()
+	e.conn = conn
+	e.leader = true
+	e.electedAt = time.Now()
+	e.mu.Unlock()
+	logger.Warn("elected leader of the engine", map[string]any{
+		"lease":  e.fence.lease,
+		"holder": e.holder,
+		"token":  token,
+	})
+	return nil
+}
+
+// check makes sure the session holding the lock is still alive
+func (e *Elector) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, e.interval)
+	defer cancel()
+	e.mu.Lock()
+	conn := e.conn
+	e.mu.Unlock()
+	_, err := conn.Exec(ctx, "SELECT 1")
+	return err
+}
+
+// depose steps down once the lock may be gone, the session is closed so the
+// lock is released if it wasn't already
+func (e *Elector) depose(err error) {
+	e.fence.set(0)
+	e.mu.Lock()
+	conn := e.conn
+	e.conn = nil
+	e.leader = false
+	hooks := e.onDeposed
+	e.mu.Unlock()
+	conn.Conn().Close(context.Background())
+	conn.Release()
+
+	logger.Error("lost the engine lease", map[string]any{
+		"lease": e.fence.lease,
+		"error": err,
+	})
+	for _, fn := range hooks {
+		fn(errors.Join(ErrDeposed, err))
+	}
+}
+
+// resign gives the lease back at shutdown. The token stays current until
+// another instance is elected, the writes of the drain still go through.
+func (e *Elector) resign() {
+	e.mu.Lock()
+	conn := e.conn
+	e.conn = nil
+	e.leader = false
+	e.mu.Unlock()
+	if conn != nil {
+		e.unlock(conn)
+	}
+}
+
+func (e *Elector) unlock(conn *pgxpool.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.interval)
+	defer cancel()
+	_, err := conn.Exec(ctx, "SELECT pg_advisory_unlock(hashtext('engine_lease:' || $1))", e.fence.lease)
+	if err != nil {
+		// The lock goes with the session
+		conn.Conn().Close(ctx)
+	}
+	conn.Release()
+}
+
+// Leader reports whether the instance takes writes
+func (e *Elector) Leader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+func (e *Elector) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return Status{
+		Lease:     e.fence.lease,
+		Holder:    e.holder,
+		Leader:    e.leader,
+		Token:     e.fence.Token(),
+		ElectedAt: e.electedAt,
+	}
+}
+
+// Middleware refuses the writes of the public API unless the instance is
+// the leader, reads are served from its books
+func (e *Elector) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			return c.Next()
+		}
+		if e.Leader() {
+			return c.Next()
+		}
+		return ErrNotLeader
+	}
+}
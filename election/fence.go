@@ -0,0 +1,56 @@
+package election
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// tokenKey records on a connection the fencing token its session carries
+const tokenKey = "fencing_token"
+
+// Fence carries the lease and the fencing token of the engine on every
+// session of a pool, the database checks them before the engine's writes.
+// The token is 0 until the lease is won, and again once it's lost, so an
+// instance that isn't the leader can't write.
+type Fence struct {
+	lease string
+	token atomic.Int64
+}
+
+func NewFence(lease string) *Fence {
+	return &Fence{lease: lease}
+}
+
+func (f *Fence) Token() int64 {
+	return f.token.Load()
+}
+
+func (f *Fence) set(token int64) {
+	f.token.Store(token)
+}
+
+// Configure makes the sessions of a pool carry the fence, it's passed to
+// db.Connect. A connection only updates its token when it's acquired after
+// an election.
+func (f *Fence) Configure(poolCfg *pgxpool.Config) {
+	poolCfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		_, err := conn.Exec(ctx, "SELECT set_config('engine.lease', $1, false)", f.lease)
+		return err
+	}
+	poolCfg.PrepareConn = func(ctx context.Context, conn *pgx.Conn) (bool, error) {
+		token := f.token.Load()
+		if current, ok := conn.PgConn().CustomData()[tokenKey].(int64); ok && current == token {
+			return true, nil
+		}
+		_, err := conn.Exec(ctx, "SELECT set_config('engine.fencing_token', $1, false)", strconv.FormatInt(token, 10))
+		if err != nil {
+			return false, err
+		}
+		conn.PgConn().CustomData()[tokenKey] = token
+		return true, nil
+	}
+}
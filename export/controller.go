@@ -0,0 +1,144 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"order-book/logger"
+	"order-book/order"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var ErrInvalidData = errors.New("ErrInvalidData")
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindExportRouter serves the exports. GET /admin/export/:dataset streams
+// the file to the client, POST /admin/exports stores it in object storage
+// in the background. Ranges are given as RFC 3339 from and to parameters.
+// A streamed export that fails halfway ends early, the status was sent.
+func BindExportRouter(bgCtx context.Context, r fiber.Router, exporter *Exporter) {
+	r.Get("/admin/export/:dataset", func(c *fiber.Ctx) error {
+		req := Request{
+			Dataset:   strings.Clone(c.Params("dataset")),
+			Format:    strings.Clone(c.Query("format")),
+			PairID:    strings.Clone(c.Query("pair_id")),
+			AccountID: c.QueryInt("account_id", 0),
+			TenantID:  strings.Clone(c.Query("tenant_id")),
+			Interval:  order.CandleInterval(strings.Clone(c.Query("interval"))),
+		}
+		for param, dst := range map[string]*time.Time{"from": &req.From, "to": &req.To} {
+			if v := c.Query(param); v != "" {
+				t, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					c.Status(http.StatusBadRequest)
+					return c.JSON(&Response{
+						Error:   ErrInvalidData,
+						Message: param + " should be an RFC 3339 time",
+					})
+				}
+				*dst = t
+			}
+		}
+		if err := req.Validate(); err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrInvalidData,
+				Message: err.Error(),
+			})
+		}
+
+		// The handler returns before the body is written, the request
+		// context is gone by then
+		ctx := context.WithoutCancel(c.UserContext())
+		c.Set(fiber.HeaderContentType, req.ContentType())
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+req.FileName()+`"`)
+		c.Status(http.StatusOK)
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			rows, err := exporter.Export(ctx, req, w)
+			if err == nil {
+				err = w.Flush()
+			}
+			if err != nil {
+				logger.Component("export").Error("streamed export failed", map[string]any{
+					"dataset": req.Dataset,
+					"rows":    rows,
+					"error":   err,
+				})
+			}
+		})
+		return nil
+	})
+
+	r.Post("/admin/exports", func(c *fiber.Ctx) error {
+		var req Request
+		if err := c.BodyParser(&req); err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrInvalidData,
+				Message: "Invalid body",
+			})
+		}
+		job, err := exporter.Start(bgCtx, req)
+		if err == ErrNoStore {
+			c.Status(http.StatusServiceUnavailable)
+			return c.JSON(&Response{
+				Error:   err,
+				Message: err.Error(),
+			})
+		}
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrInvalidData,
+				Message: err.Error(),
+			})
+		}
+		c.Status(http.StatusAccepted)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    job,
+		})
+	})
+
+	r.Get("/admin/exports", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    exporter.Jobs(),
+		})
+	})
+
+	r.Get("/admin/exports/:id", func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid ID",
+				Data:    nil,
+			})
+		}
+		job, err := exporter.GetJob(id)
+		if err == ErrJobNotFound {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Error:   err,
+				Message: err.Error(),
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    job,
+		})
+	})
+}
@@ -0,0 +1,380 @@
+// Package export writes the trades, the order history events or the candles
+// of a time range as CSV or Parquet, for analytics and tax reporting. Each
+// dataset has a fixed schema shared by both formats, columns are only ever
+// added at the end. The rows are read and written a page at a time, an
+// export of years of trades holds a single page in memory.
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"order-book/order"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Datasets
+const (
+	TRADES       = "trades"
+	ORDER_EVENTS = "order_events"
+	CANDLES      = "candles"
+)
+
+// Formats
+const (
+	CSV     = "csv"
+	PARQUET = "parquet"
+)
+
+// PAGE_SIZE is the number of rows read from the database at once
+const PAGE_SIZE = 5000
+
+var (
+	ErrUnknownDataset = errors.New("Dataset should be trades, order_events or candles")
+	ErrUnknownFormat  = errors.New("Format should be csv or parquet")
+	ErrInvalidRange   = errors.New("from and to are required, to after from")
+	ErrPairRequired   = errors.New("Candles are exported for a pair, pair_id is required")
+)
+
+// Request is an export of a dataset over [From, To). PairID and AccountID
+// narrow it down, TenantID and Interval only apply to candles.
+type Request struct {
+	Dataset   string               `json:"dataset"`
+	Format    string               `json:"format"`
+	From      time.Time            `json:"from"`
+	To        time.Time            `json:"to"`
+	PairID    string               `json:"pair_id,omitempty"`
+	AccountID int                  `json:"account_id,omitempty"`
+	TenantID  string               `json:"tenant_id,omitempty"`
+	Interval  order.CandleInterval `json:"interval,omitempty"`
+}
+
+// Validate checks the request and fills in its defaults, CSV and the 1m
+// candles of the default tenant
+func (r *Request) Validate() error {
+	if r.Format == "" {
+		r.Format = CSV
+	}
+	if r.Format != CSV && r.Format != PARQUET {
+		return ErrUnknownFormat
+	}
+	if r.From.IsZero() || r.To.IsZero() || !r.To.After(r.From) {
+		return ErrInvalidRange
+	}
+	switch r.Dataset {
+	case TRADES, ORDER_EVENTS:
+	case CANDLES:
+		if r.PairID == "" {
+			return ErrPairRequired
+		}
+		if r.TenantID == "" {
+			r.TenantID = "default"
+		}
+		if r.Interval == "" {
+			r.Interval = order.CANDLE_1M
+		}
+		if r.Interval.Duration() == 0 {
+			return order.ErrInvalidInterval
+		}
+	default:
+		return ErrUnknownDataset
+	}
+	return nil
+}
+
+// FileName names the export after its dataset and range
+func (r Request) FileName() string {
+	const layout = "20060102T150405Z"
+	return fmt.Sprintf("%s_%s_%s.%s", r.Dataset, r.From.UTC().Format(layout), r.To.UTC().Format(layout), r.Format)
+}
+
+func (r Request) ContentType() string {
+	if r.Format == PARQUET {
+		return "application/vnd.apache.parquet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// TradeRow is a row of the trades dataset, amounts are exact decimals
+type TradeRow struct {
+	ID              int64     `parquet:"id"`
+	PairID          string    `parquet:"pair_id,dict"`
+	Price           string    `parquet:"price"`
+	Amount          string    `parquet:"amount"`
+	QuoteAmount     string    `parquet:"quote_amount"`
+	TakerSide       string    `parquet:"taker_side,dict"`
+	MakerOrderID    int64     `parquet:"maker_order_id"`
+	TakerOrderID    int64     `parquet:"taker_order_id"`
+	MakerAccountID  int64     `parquet:"maker_account_id"`
+	TakerAccountID  int64     `parquet:"taker_account_id"`
+	MakerFee        string    `parquet:"maker_fee"`
+	TakerFee        string    `parquet:"taker_fee"`
+	MakerRebate     string    `parquet:"maker_rebate"`
+	ExecutedAt      time.Time `parquet:"executed_at,timestamp(microsecond)"`
+	MakerTag        string    `parquet:"maker_tag"`
+	MakerStrategyID string    `parquet:"maker_strategy_id"`
+	TakerTag        string    `parquet:"taker_tag"`
+	TakerStrategyID string    `parquet:"taker_strategy_id"`
+}
+
+// EventRow is a row of the order_events dataset, the metadata is JSON
+type EventRow struct {
+	ID        int64     `parquet:"id"`
+	Event     string    `parquet:"event,dict"`
+	OrderID   int64     `parquet:"order_id"`
+	AccountID int64     `parquet:"account_id"`
+	PairID    string    `parquet:"pair_id,dict"`
+	Metadata  string    `parquet:"metadata"`
+	CreatedAt time.Time `parquet:"created_at,timestamp(microsecond)"`
+}
+
+// CandleRow is a row of the candles dataset
+type CandleRow struct {
+	PairID      string    `parquet:"pair_id,dict"`
+	Interval    string    `parquet:"interval,dict"`
+	OpenTime    time.Time `parquet:"open_time,timestamp(microsecond)"`
+	Open        string    `parquet:"open"`
+	High        string    `parquet:"high"`
+	Low         string    `parquet:"low"`
+	Close       string    `parquet:"close"`
+	Volume      string    `parquet:"volume"`
+	QuoteVolume string    `parquet:"quote_volume"`
+	Trades      int64     `parquet:"trades"`
+}
+
+// Exporter runs the exports, streamed by Export or stored by Start
+type Exporter struct {
+	repo     order.ExportRepo
+	candles  order.CandleRepo
+	jobs     *jobs
+	pageSize int
+}
+
+// Export writes the rows of req to w and returns how many it wrote
+func (e *Exporter) Export(ctx context.Context, req Request, w io.Writer) (int, error) {
+	filter := order.ExportFilter{AccountID: req.AccountID, PairID: req.PairID, From: req.From, To: req.To}
+	switch req.Dataset {
+	case TRADES:
+		var afterId int64
+		return writeRows(req.Format, w, func() ([]TradeRow, error) {
+			trades, err := e.repo.GetTradesPage(ctx, filter, afterId, e.pageSize)
+			if err != nil || len(trades) == 0 {
+				return nil, err
+			}
+			afterId = trades[len(trades)-1].ID
+			rows := make([]TradeRow, len(trades))
+			for idx, t := range trades {
+				rows[idx] = convertTrade(t)
+			}
+			return rows, nil
+		})
+	case ORDER_EVENTS:
+		var afterId int64
+		return writeRows(req.Format, w, func() ([]EventRow, error) {
+			events, err := e.repo.GetEventsPage(ctx, filter, afterId, e.pageSize)
+			if err != nil || len(events) == 0 {
+				return nil, err
+			}
+			afterId = events[len(events)-1].ID
+			rows := make([]EventRow, len(events))
+			for idx, ev := range events {
+				if rows[idx], err = convertEvent(ev); err != nil {
+					return nil, err
+				}
+			}
+			return rows, nil
+		})
+	case CANDLES:
+		from := req.From
+		return writeRows(req.Format, w, func() ([]CandleRow, error) {
+			if !from.Before(req.To) {
+				return nil, nil
+			}
+			candles, err := e.candles.GetCandles(ctx, req.TenantID, req.PairID, req.Interval, from, req.To, e.pageSize)
+			if err != nil || len(candles) == 0 {
+				return nil, err
+			}
+			from = candles[len(candles)-1].OpenTime.Add(req.Interval.Duration())
+			rows := make([]CandleRow, len(candles))
+			for idx, c := range candles {
+				rows[idx] = convertCandle(c)
+			}
+			return rows, nil
+		})
+	}
+	return 0, ErrUnknownDataset
+}
+
+// writeRows writes the pages returned by next until it returns none
+func writeRows[T any](format string, w io.Writer, next func() ([]T, error)) (int, error) {
+	var rw rowWriter[T]
+	if format == PARQUET {
+		rw = &parquetWriter[T]{w: parquet.NewGenericWriter[T](w, parquet.Compression(&parquet.Zstd))}
+	} else {
+		rw = newCSVWriter[T](w)
+	}
+	var count int
+	for {
+		rows, err := next()
+		if err != nil {
+			return count, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		if err := rw.Write(rows); err != nil {
+			return count, err
+		}
+		count += len(rows)
+	}
+	return count, rw.Close()
+}
+
+type rowWriter[T any] interface {
+	Write(rows []T) error
+	Close() error
+}
+
+// parquetWriter writes a row group per page
+type parquetWriter[T any] struct {
+	w *parquet.GenericWriter[T]
+}
+
+func (pw *parquetWriter[T]) Write(rows []T) error {
+	if _, err := pw.w.Write(rows); err != nil {
+		return err
+	}
+	return pw.w.Flush()
+}
+
+func (pw *parquetWriter[T]) Close() error {
+	return pw.w.Close()
+}
+
+// csvWriter writes the columns of the Parquet schema, the header first.
+// Times are RFC 3339 in UTC.
+type csvWriter[T any] struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVWriter[T any](w io.Writer) *csvWriter[T] {
+	return &csvWriter[T]{w: csv.NewWriter(w)}
+}
+
+func (cw *csvWriter[T]) writeHeader() error {
+	if cw.header {
+		return nil
+	}
+	cw.header = true
+	t := reflect.TypeFor[T]()
+	columns := make([]string, t.NumField())
+	for idx := range columns {
+		name, _, _ := strings.Cut(t.Field(idx).Tag.Get("parquet"), ",")
+		columns[idx] = name
+	}
+	return cw.w.Write(columns)
+}
+
+func (cw *csvWriter[T]) Write(rows []T) error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		v := reflect.ValueOf(row)
+		record := make([]string, v.NumField())
+		for idx := range record {
+			switch f := v.Field(idx).Interface().(type) {
+			case string:
+				record[idx] = f
+			case int64:
+				record[idx] = strconv.FormatInt(f, 10)
+			case time.Time:
+				record[idx] = f.UTC().Format(time.RFC3339Nano)
+			}
+		}
+		if err := cw.w.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// Close writes the header of an export without rows
+func (cw *csvWriter[T]) Close() error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func convertTrade(t order.Trade) TradeRow {
+	side := "sell"
+	if t.TakerSide == order.BID {
+		side = "buy"
+	}
+	return TradeRow{
+		ID:              t.ID,
+		PairID:          t.PairID,
+		Price:           t.Price.String(),
+		Amount:          t.Amount.String(),
+		QuoteAmount:     t.Price.Mul(t.Amount).String(),
+		TakerSide:       side,
+		MakerOrderID:    int64(t.MakerOrderID),
+		TakerOrderID:    int64(t.TakerOrderID),
+		MakerAccountID:  int64(t.MakerAccountID),
+		TakerAccountID:  int64(t.TakerAccountID),
+		MakerFee:        t.MakerFee.String(),
+		TakerFee:        t.TakerFee.String(),
+		MakerRebate:     t.MakerRebate.String(),
+		ExecutedAt:      t.ExecutedAt,
+		MakerTag:        t.MakerTag,
+		MakerStrategyID: t.MakerStrategyID,
+		TakerTag:        t.TakerTag,
+		TakerStrategyID: t.TakerStrategyID,
+	}
+}
+
+func convertEvent(ev order.ExportedEvent) (EventRow, error) {
+	if ev.Metadata == nil {
+		ev.Metadata = map[string]any{}
+	}
+	metadata, err := json.Marshal(ev.Metadata)
+	if err != nil {
+		return EventRow{}, err
+	}
+	return EventRow{
+		ID:        ev.ID,
+		Event:     ev.Name,
+		OrderID:   int64(ev.OrderId),
+		AccountID: int64(ev.AccountID),
+		PairID:    ev.PairID,
+		Metadata:  string(metadata),
+		CreatedAt: ev.CreatedAt,
+	}, nil
+}
+
+func convertCandle(c order.Candle) CandleRow {
+	return CandleRow{
+		PairID:      c.PairID,
+		Interval:    string(c.Interval),
+		OpenTime:    c.OpenTime,
+		Open:        c.Open.String(),
+		High:        c.High.String(),
+		Low:         c.Low.String(),
+		Close:       c.Close.String(),
+		Volume:      c.Volume.String(),
+		QuoteVolume: c.QuoteVolume.String(),
+		Trades:      c.Trades,
+	}
+}
@@ -0,0 +1,153 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"order-book/archive"
+	"order-book/logger"
+	"order-book/order"
+	"sync"
+	"time"
+)
+
+// Job statuses
+const (
+	JOB_RUNNING = "running"
+	JOB_DONE    = "done"
+	JOB_FAILED  = "failed"
+)
+
+// MAX_JOBS is the number of jobs kept, the oldest finished ones are dropped
+const MAX_JOBS = 100
+
+var (
+	ErrNoStore     = errors.New("No object storage to write exports to")
+	ErrJobNotFound = errors.New("Export not found")
+)
+
+// Job is an export written to object storage under Key
+type Job struct {
+	ID         int        `json:"id"`
+	Request    Request    `json:"request"`
+	Status     string     `json:"status"`
+	Key        string     `json:"key"`
+	Rows       int        `json:"rows"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+type jobs struct {
+	mu     sync.Mutex
+	store  archive.ObjectStore
+	nextId int
+	list   []*Job
+}
+
+// NewExporter reads the exports from repo and candles, a nil store leaves
+// exports to be streamed only
+func NewExporter(repo order.ExportRepo, candles order.CandleRepo, store archive.ObjectStore) *Exporter {
+	return &Exporter{
+		repo:     repo,
+		candles:  candles,
+		jobs:     &jobs{store: store, nextId: 1},
+		pageSize: PAGE_SIZE,
+	}
+}
+
+// Start runs req in the background until ctx is done and stores the file
+// under exports/. The object is written once the export completed, it's
+// held in memory until then.
+func (e *Exporter) Start(ctx context.Context, req Request) (Job, error) {
+	if e.jobs.store == nil {
+		return Job{}, ErrNoStore
+	}
+	if err := req.Validate(); err != nil {
+		return Job{}, err
+	}
+
+	e.jobs.mu.Lock()
+	job := &Job{
+		ID:        e.jobs.nextId,
+		Request:   req,
+		Status:    JOB_RUNNING,
+		CreatedAt: time.Now().UTC(),
+	}
+	e.jobs.nextId++
+	// IDs start over with the process, the time keeps the keys apart
+	job.Key = fmt.Sprintf("exports/%s/%d/%s", job.CreatedAt.Format("2006-01-02"), job.CreatedAt.UnixNano(), req.FileName())
+	e.jobs.add(job)
+	started := *job
+	e.jobs.mu.Unlock()
+
+	go func() {
+		var buf bytes.Buffer
+		rows, err := e.Export(ctx, req, &buf)
+		if err == nil {
+			err = e.jobs.store.Put(ctx, job.Key, buf.Bytes(), req.ContentType())
+		}
+
+		e.jobs.mu.Lock()
+		defer e.jobs.mu.Unlock()
+		now := time.Now().UTC()
+		job.FinishedAt = &now
+		job.Rows = rows
+		job.Status = JOB_DONE
+		if err != nil {
+			job.Status = JOB_FAILED
+			job.Error = err.Error()
+			logger.Component("export").Error("export failed", map[string]any{
+				"id":      job.ID,
+				"dataset": req.Dataset,
+				"error":   err,
+			})
+			return
+		}
+		logger.Component("export").Info("export stored", map[string]any{
+			"id":   job.ID,
+			"key":  job.Key,
+			"rows": rows,
+		})
+	}()
+	return started, nil
+}
+
+// GetJob returns a job of Start by ID
+func (e *Exporter) GetJob(id int) (Job, error) {
+	e.jobs.mu.Lock()
+	defer e.jobs.mu.Unlock()
+	for _, job := range e.jobs.list {
+		if job.ID == id {
+			return *job, nil
+		}
+	}
+	return Job{}, ErrJobNotFound
+}
+
+// Jobs lists the jobs kept, the most recent first
+func (e *Exporter) Jobs() []Job {
+	e.jobs.mu.Lock()
+	defer e.jobs.mu.Unlock()
+	list := make([]Job, 0, len(e.jobs.list))
+	for idx := len(e.jobs.list) - 1; idx >= 0; idx-- {
+		list = append(list, *e.jobs.list[idx])
+	}
+	return list
+}
+
+// add appends a job, dropping the oldest finished one past MAX_JOBS.
+// Callers hold mu.
+func (j *jobs) add(job *Job) {
+	j.list = append(j.list, job)
+	if len(j.list) <= MAX_JOBS {
+		return
+	}
+	for idx, old := range j.list {
+		if old.Status != JOB_RUNNING {
+			j.list = append(j.list[:idx], j.list[idx+1:]...)
+			return
+		}
+	}
+}
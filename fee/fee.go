@@ -0,0 +1,55 @@
+package fee
+
+import (
+	"maps"
+	"slices"
+)
+
+// Rates are fractions of the trade notional charged in the quote asset. A
+// negative Maker rate is a rebate paid to the maker.
+type Rates struct {
+	Maker float64 `json:"maker"`
+	Taker float64 `json:"taker"`
+}
+
+// Tier holds the rates of a group of accounts, like the market makers of a
+// program, Pairs override them on some pairs
+type Tier struct {
+	Rates    Rates            `json:"rates"`
+	Pairs    map[string]Rates `json:"pairs,omitempty"`
+	Accounts []int            `json:"accounts"`
+}
+
+func (t Tier) Equal(other Tier) bool {
+	return t.Rates == other.Rates && maps.Equal(t.Pairs, other.Pairs) && slices.Equal(t.Accounts, other.Accounts)
+}
+
+// Schedule holds the default rates and per pair overrides, and the tiers
+// whose rates apply to their accounts instead
+type Schedule struct {
+	Default Rates
+	Pairs   map[string]Rates
+	Tiers   map[string]Tier
+}
+
+func (s Schedule) For(pairId string) Rates {
+	if r, ok := s.Pairs[pairId]; ok {
+		return r
+	}
+	return s.Default
+}
+
+// ForAccount returns the rates of an account on a pair, those of its tier
+// when it's in one
+func (s Schedule) ForAccount(pairId string, accountId int) Rates {
+	for _, t := range s.Tiers {
+		if !slices.Contains(t.Accounts, accountId) {
+			continue
+		}
+		if r, ok := t.Pairs[pairId]; ok {
+			return r
+		}
+		return t.Rates
+	}
+	return s.For(pairId)
+}
@@ -0,0 +1,141 @@
+package feeasset
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var ErrFieldRequired = errors.New("ErrFieldRequired")
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+type optInRequest struct {
+	Asset string `json:"asset"`
+}
+
+// BindFeeAssetRouter serves the assets fees can be paid in on GET
+// /fee-assets, the choice of an account on /accounts/:id/fee-asset and the
+// fees it paid in its asset on GET /accounts/:id/fee-charges
+func BindFeeAssetRouter(r fiber.Router, e *Engine) {
+	r.Get("/fee-assets", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    e.Assets(),
+		})
+	})
+
+	r.Get("/accounts/:id/fee-asset", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		asset, ok := e.AssetOf(accountId)
+		if !ok {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The account pays its fees in the quote asset",
+				Data:    nil,
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data: map[string]any{
+				"asset":    asset,
+				"discount": e.Assets()[asset],
+			},
+		})
+	})
+
+	r.Put("/accounts/:id/fee-asset", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		var req optInRequest
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		if req.Asset == "" {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrFieldRequired,
+				Message: "Please provide an asset",
+			})
+		}
+		if err := e.OptIn(c.UserContext(), accountId, req.Asset); err == ErrAssetNotAccepted {
+			c.Status(http.StatusUnprocessableEntity)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		} else if err != nil {
+			feeLog.Error("failed to opt an account in a fee asset", map[string]any{
+				"account_id": accountId,
+				"asset":      req.Asset,
+				"error":      err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Fees are paid in the asset",
+			Data:    nil,
+		})
+	})
+
+	r.Delete("/accounts/:id/fee-asset", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		if err := e.OptOut(c.UserContext(), accountId); err != nil {
+			feeLog.Error("failed to opt an account out of its fee asset", map[string]any{
+				"account_id": accountId,
+				"error":      err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Fees are paid in the quote asset",
+			Data:    nil,
+		})
+	})
+
+	r.Get("/accounts/:id/fee-charges", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    e.Charges(accountId),
+		})
+	})
+}
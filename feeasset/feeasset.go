@@ -0,0 +1,397 @@
+// Package feeasset charges the trading fees of the accounts that opted into
+// paying them in another asset, like a venue token, at the discount of that
+// asset. The fee of a fill, in the quote asset of the pair, is converted at
+// the index price when the fill happens and posted to the ledger in the
+// chosen asset.
+package feeasset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"order-book/account"
+	"order-book/ledgerqueue"
+	"order-book/logger"
+	"order-book/netting"
+	"order-book/order"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// maxHistory bounds the charges kept per account
+const maxHistory = 100
+
+var ErrAssetNotAccepted = errors.New("Fees can't be paid in this asset")
+
+var feeLog = logger.Component("feeasset")
+
+// Prices are the index prices of the pairs
+type Prices interface {
+	Price(pairId string) (decimal.Decimal, bool)
+}
+
+// Charge is the fee of a fill paid in the asset an account chose. Fee is the
+// fee in the quote asset of the pair, Price that of Asset in the quote asset,
+// and Amount what was charged in Asset after the discount. A charge with an
+// Error wasn't posted yet and is retried. One Asset had no price for, or the
+// balance in Asset was short for, is charged InQuote, the fee in the quote
+// asset instead. A Netted charge is posted with the others of its settlement
+// window.
+type Charge struct {
+	TradeID   int64           `json:"trade_id"`
+	PairID    string          `json:"pair_id"`
+	AccountID int             `json:"account_id"`
+	Fee       decimal.Decimal `json:"fee"`
+	Quote     string          `json:"quote"`
+	Asset     string          `json:"asset"`
+	Price     decimal.Decimal `json:"price"`
+	Discount  float64         `json:"discount"`
+	Amount    decimal.Decimal `json:"amount"`
+	At        time.Time       `json:"at"`
+	Netted    bool            `json:"netted,omitempty"`
+	InQuote   bool            `json:"in_quote,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// Engine posts the charges through the ledger queue, which keeps them until
+// they're posted. Those waiting for the queue are held in memory however
+// many there are. The assets the accounts chose are kept in repo.
+type Engine struct {
+	prices  Prices
+	repo    Repo
+	ledger  *ledgerqueue.Queue
+	netting *netting.Engine
+	wake    chan struct{}
+
+	mu     sync.Mutex
+	queued []Charge
+	// assets are the assets fees can be paid in by their discount
+	assets   map[string]float64
+	accounts map[int]string
+	history  map[int][]Charge
+	// flushing serializes the flushes of Run and Drain
+	flushing sync.Mutex
+}
+
+func NewEngine(assets map[string]float64, prices Prices, repo Repo, ledger *ledgerqueue.Queue, nets *netting.Engine) (*Engine, error) {
+	accounts, err := repo.GetAccounts(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	e := &Engine{
+		prices:   prices,
+		repo:     repo,
+		ledger:   ledger,
+		netting:  nets,
+		wake:     make(chan struct{}, 1),
+		accounts: accounts,
+		history:  make(map[int][]Charge),
+	}
+	e.SetAssets(assets)
+	ledger.OnResult(e.onResult)
+	return e, nil
+}
+
+// SetAssets replaces the assets fees can be paid in. The accounts that chose
+// an asset no longer accepted pay their fees in the quote asset again.
+func (e *Engine) SetAssets(assets map[string]float64) {
+	accepted := make(map[string]float64, len(assets))
+	for asset, discount := range assets {
+		accepted[strings.ToLower(asset)] = discount
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.assets = accepted
+}
+
+// Assets are the assets fees can be paid in by their discount
+func (e *Engine) Assets() map[string]float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return maps.Clone(e.assets)
+}
+
+// OptIn makes an account pay its fees in an accepted asset
+func (e *Engine) OptIn(ctx context.Context, accountId int, asset string) error {
+	asset = strings.ToLower(asset)
+	e.mu.Lock()
+	_, accepted := e.assets[asset]
+	e.mu.Unlock()
+	if !accepted {
+		return ErrAssetNotAccepted
+	}
+	// The engine goroutine takes e.mu on every fill, it isn't held while
+	// the database is written
+	if err := e.repo.SetAccount(ctx, accountId, asset); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.accounts[accountId] = asset
+	return nil
+}
+
+// OptOut makes an account pay its fees in the quote asset again
+func (e *Engine) OptOut(ctx context.Context, accountId int) error {
+	if err := e.repo.RemoveAccount(ctx, accountId); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.accounts, accountId)
+	return nil
+}
+
+// AssetOf is the asset an account pays its fees in, ok is false when it pays
+// them in the quote asset
+func (e *Engine) AssetOf(accountId int) (asset string, ok bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	asset, ok = e.accounts[accountId]
+	return asset, ok
+}
+
+// OnTrade converts the fees of both sides of a fill for the accounts paying
+// them in another asset. It's called from the engine goroutine, the charges
+// are posted by Run.
+func (e *Engine) OnTrade(t order.Trade) {
+	e.charge(t, t.MakerAccountID, t.MakerFee)
+	e.charge(t, t.TakerAccountID, t.TakerFee)
+}
+
+func (e *Engine) charge(t order.Trade, accountId int, fee decimal.Decimal) {
+	// Rebates are paid in the quote asset
+	if !fee.IsPositive() {
+		return
+	}
+	e.mu.Lock()
+	asset, ok := e.accounts[accountId]
+	discount, accepted := e.assets[asset]
+	e.mu.Unlock()
+	if !ok || !accepted {
+		return
+	}
+
+	_, quote, _ := order.SplitPairID(t.PairID)
+	c := Charge{
+		TradeID:   t.ID,
+		PairID:    t.PairID,
+		AccountID: accountId,
+		Fee:       fee,
+		Quote:     quote,
+		Asset:     asset,
+		Discount:  discount,
+		At:        t.ExecutedAt,
+	}
+	if price, ok := e.price(asset, quote); ok {
+		c.Price = price
+		c.Amount = round(asset, fee.Mul(decimal.NewFromFloat(1-discount)).Div(price))
+		if !c.Amount.IsPositive() {
+			return
+		}
+	} else {
+		// Without a price the fee is charged in the quote asset
+		feeLog.Warn("no index price for a fee asset, the fee is charged in the quote asset", map[string]any{
+			"trade_id":   t.ID,
+			"account_id": accountId,
+			"asset":      asset,
+			"quote":      quote,
+		})
+		c.InQuote = true
+		if !fee.Truncate(account.LedgerScale).IsPositive() {
+			return
+		}
+	}
+
+	e.mu.Lock()
+	e.queued = append(e.queued, c)
+	e.mu.Unlock()
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// price is that of one unit of asset in quote, from the index of either pair
+// between them
+func (e *Engine) price(asset string, quote string) (decimal.Decimal, bool) {
+	if asset == quote {
+		return decimal.NewFromInt(1), true
+	}
+	if p, ok := e.prices.Price(asset + quote); ok && p.IsPositive() {
+		return p, true
+	}
+	if p, ok := e.prices.Price(quote + asset); ok && p.IsPositive() {
+		return decimal.NewFromInt(1).Div(p), true
+	}
+	return decimal.Decimal{}, false
+}
+
+// round is amount at the scale of asset, truncated to the decimals the
+// ledger keeps so no more than the fee is charged
+func round(asset string, amount decimal.Decimal) decimal.Decimal {
+	if a, ok := order.GetAsset(asset); ok {
+		amount = a.Round(amount)
+	} else {
+		amount = amount.Round(order.MaxScale)
+	}
+	return amount.Truncate(account.LedgerScale)
+}
+
+// Run hands the charges to the ledger queue until ctx is done. Each charge
+// is a ledger transaction to the fees account, keyed by the trade and the
+// account so a retry never charges twice, with the fee in the quote asset
+// as its fallback. Those of the accounts in netting mode are left to the
+// netting of their window.
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.wake:
+		case <-ticker.C:
+		}
+		e.flush()
+	}
+}
+
+// Drain hands the charges still held to the ledger queue. It's called on
+// shutdown once the books are drained.
+func (e *Engine) Drain() {
+	if left := e.flush(); left > 0 {
+		feeLog.Error("fees were left uncharged at shutdown", map[string]any{
+			"charges": left,
+		})
+	}
+}
+
+// flush hands the held charges over in order until the queue refuses one,
+// which is tried again on the next flush. It returns how many are held.
+func (e *Engine) flush() int {
+	e.flushing.Lock()
+	defer e.flushing.Unlock()
+	for {
+		e.mu.Lock()
+		if len(e.queued) == 0 {
+			e.mu.Unlock()
+			return 0
+		}
+		c := e.queued[0]
+		e.mu.Unlock()
+
+		t, fallback := c.transaction(), c.fallback()
+		if c.InQuote {
+			t, fallback = *fallback, nil
+		}
+		c.Netted = e.netting.Defer(c.AccountID, netting.Obligation{
+			TradeID: c.TradeID,
+			PairID:  c.PairID,
+			Type:    account.FEE,
+			Asset:   t.Postings[0].Asset,
+			Amount:  t.Postings[0].Amount,
+			At:      c.At,
+		})
+		if !c.Netted {
+			if err := e.ledger.Enqueue(context.Background(), t, fallback); err != nil {
+				feeLog.Error("failed to queue a fee, it's retried", map[string]any{
+					"trade_id":   c.TradeID,
+					"account_id": c.AccountID,
+					"asset":      c.Asset,
+					"amount":     c.Amount,
+					"error":      err,
+				})
+				e.mu.Lock()
+				defer e.mu.Unlock()
+				return len(e.queued)
+			}
+		}
+		e.mu.Lock()
+		e.queued = e.queued[1:]
+		e.mu.Unlock()
+		e.record(c)
+	}
+}
+
+func key(tradeId int64, accountId int) string {
+	return fmt.Sprintf("fee:%d:%d", tradeId, accountId)
+}
+
+// transaction charges the fee in the asset the account chose
+func (c Charge) transaction() account.Transaction {
+	return account.Transaction{
+		Type:           account.FEE,
+		IdempotencyKey: key(c.TradeID, c.AccountID),
+		Source:         account.ENGINE,
+		Reference:      fmt.Sprintf("fee of trade %d on %s in %s", c.TradeID, c.PairID, c.Asset),
+		Postings: []account.Posting{
+			{AccountID: c.AccountID, Asset: c.Asset, Amount: c.Amount.Neg()},
+			{SystemAccount: account.FEES, Asset: c.Asset, Amount: c.Amount},
+		},
+	}
+}
+
+// fallback charges the fee in the quote asset, without the discount, when
+// the chosen asset has no price or the balance in it is short for the fee
+func (c Charge) fallback() *account.Transaction {
+	fee := c.Fee.Truncate(account.LedgerScale)
+	return &account.Transaction{
+		Type:           account.FEE,
+		IdempotencyKey: key(c.TradeID, c.AccountID) + ":quote",
+		Source:         account.ENGINE,
+		Reference:      fmt.Sprintf("fee of trade %d on %s in %s", c.TradeID, c.PairID, c.Quote),
+		Postings: []account.Posting{
+			{AccountID: c.AccountID, Asset: c.Quote, Amount: fee.Neg()},
+			{SystemAccount: account.FEES, Asset: c.Quote, Amount: fee},
+		},
+	}
+}
+
+// onResult keeps the outcome of an attempt to post a charge on it
+func (e *Engine) onResult(res ledgerqueue.Result) {
+	if res.Transaction.Type != account.FEE || len(res.Transaction.Postings) == 0 {
+		return
+	}
+	accountId := res.Transaction.Postings[0].AccountID
+	chargeKey := strings.TrimSuffix(res.Transaction.IdempotencyKey, ":quote")
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for idx, c := range e.history[accountId] {
+		if key(c.TradeID, c.AccountID) == chargeKey {
+			charge := &e.history[accountId][idx]
+			charge.InQuote = charge.InQuote || res.FellBack
+			charge.Error = ""
+			if res.Err != nil {
+				charge.Error = res.Err.Error()
+			}
+		}
+	}
+}
+
+func (e *Engine) record(c Charge) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	history := append(e.history[c.AccountID], c)
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	e.history[c.AccountID] = history
+}
+
+// Charges are the last fees an account paid in its fee asset, the latest first
+func (e *Engine) Charges(accountId int) []Charge {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	charges := slices.Clone(e.history[accountId])
+	slices.Reverse(charges)
+	if charges == nil {
+		charges = []Charge{}
+	}
+	return charges
+}
@@ -0,0 +1,65 @@
+package feeasset
+
+import (
+	"context"
+	repository "order-book/feeasset/repository/gen"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Repo keeps the asset each account chose to pay its fees in
+type Repo interface {
+	// GetAccounts are the assets of the accounts by account
+	GetAccounts(ctx context.Context) (map[int]string, error)
+	SetAccount(ctx context.Context, accountId int, asset string) error
+	RemoveAccount(ctx context.Context, accountId int) error
+}
+
+type repo struct {
+	queries      *repository.Queries
+	queryTimeout time.Duration
+}
+
+func (repo *repo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *repo) GetAccounts(ctx context.Context) (map[int]string, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	dbres, err := repo.queries.GetFeeAssetAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make(map[int]string, len(dbres))
+	for _, a := range dbres {
+		accounts[int(a.AccountID)] = a.Asset
+	}
+	return accounts, nil
+}
+
+func (repo *repo) SetAccount(ctx context.Context, accountId int, asset string) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.UpsertFeeAssetAccount(ctx, repository.UpsertFeeAssetAccountParams{
+		AccountID: int32(accountId),
+		Asset:     asset,
+	})
+}
+
+func (repo *repo) RemoveAccount(ctx context.Context, accountId int) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.DeleteFeeAssetAccount(ctx, int32(accountId))
+}
+
+func NewRepository(dbpool *pgxpool.Pool, queryTimeout time.Duration) Repo {
+	return &repo{
+		queries:      repository.New(dbpool),
+		queryTimeout: queryTimeout,
+	}
+}
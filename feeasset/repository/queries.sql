@@ -0,0 +1,9 @@
+-- name: GetFeeAssetAccounts :many
+SELECT * FROM tbl_fee_asset_accounts ORDER BY account_id;
+
+-- name: UpsertFeeAssetAccount :exec
+INSERT INTO tbl_fee_asset_accounts (account_id, asset) VALUES ($1, $2)
+ON CONFLICT (account_id) DO UPDATE SET asset = EXCLUDED.asset, updated_at = NOW();
+
+-- name: DeleteFeeAssetAccount :exec
+DELETE FROM tbl_fee_asset_accounts WHERE account_id = $1;
@@ -0,0 +1,6 @@
+CREATE TABLE tbl_fee_asset_accounts
+(
+    account_id INT PRIMARY KEY,
+    asset VARCHAR(25) NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
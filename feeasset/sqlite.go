@@ -0,0 +1,68 @@
+package feeasset
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type sqliteRepo struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func (repo *sqliteRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *sqliteRepo) GetAccounts(ctx context.Context) (map[int]string, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	rows, err := repo.db.QueryContext(ctx, "SELECT account_id, asset FROM tbl_fee_asset_accounts ORDER BY account_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	accounts := make(map[int]string)
+	for rows.Next() {
+		var (
+			accountId int
+			asset     string
+		)
+		if err := rows.Scan(&accountId, &asset); err != nil {
+			return nil, err
+		}
+		accounts[accountId] = asset
+	}
+	return accounts, rows.Err()
+}
+
+func (repo *sqliteRepo) SetAccount(ctx context.Context, accountId int, asset string) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	now := time.Now().UTC()
+	_, err := repo.db.ExecContext(ctx,
+		`INSERT INTO tbl_fee_asset_accounts (account_id, asset, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (account_id) DO UPDATE SET asset = excluded.asset, updated_at = excluded.updated_at`,
+		accountId, asset, now,
+	)
+	return err
+}
+
+func (repo *sqliteRepo) RemoveAccount(ctx context.Context, accountId int) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err := repo.db.ExecContext(ctx, "DELETE FROM tbl_fee_asset_accounts WHERE account_id = ?", accountId)
+	return err
+}
+
+// NewSQLiteRepository is NewRepository on a SQLite database
+func NewSQLiteRepository(db *sql.DB, queryTimeout time.Duration) Repo {
+	return &sqliteRepo{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
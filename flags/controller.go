@@ -0,0 +1,90 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"order-book/logger"
+	"order-book/order"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	ErrUnknownFlag    = errors.New("There is no flag with this name")
+	ErrInvalidPercent = errors.New("percent should be between 0 and 100")
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+type EventWriter interface {
+	AddEvent(ctx context.Context, ev order.OrderHistoryEvent) error
+}
+
+// BindFlagsRouter serves the flags in effect on GET /admin/flags, and
+// changes one on PUT /admin/flags/:name. A change holds until the config
+// file changes the same flag, each is recorded as a CONFIG_CHANGED event.
+func BindFlagsRouter(r fiber.Router, set *Set, events EventWriter) {
+	r.Get("/admin/flags", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    set.All(),
+		})
+	})
+	r.Put("/admin/flags/:name", func(c *fiber.Ctx) error {
+		name := strings.Clone(c.Params("name"))
+		if !Known(name) {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: ErrUnknownFlag.Error(),
+				Data:    nil,
+			})
+		}
+		var f Flag
+		if err := c.BodyParser(&f); err != nil {
+			return err
+		}
+		if f.Percent < 0 || f.Percent > 100 {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: ErrInvalidPercent.Error(),
+				Data:    nil,
+			})
+		}
+
+		before, existed := set.Set(name, f)
+		metadata := map[string]any{
+			"setting": "flags." + name,
+			"before":  nil,
+			"after":   f,
+			"source":  "admin",
+			"actor":   order.ActorOf(c.UserContext(), 0),
+		}
+		if existed {
+			metadata["before"] = before
+		}
+		logger.Info("setting changed", metadata)
+		err := events.AddEvent(context.WithoutCancel(c.UserContext()), order.OrderHistoryEvent{
+			Name:     order.CONFIG_CHANGED,
+			Metadata: metadata,
+		})
+		if err != nil {
+			logger.Error("failed to add the audit event of a setting change", map[string]any{
+				"setting": "flags." + name,
+				"error":   err,
+			})
+		}
+
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Flag updated",
+			Data:    f,
+		})
+	})
+}
@@ -0,0 +1,107 @@
+package flags
+
+import (
+	"hash/fnv"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// Names of the flags gating behaviors of the engine
+const (
+	// POST_ONLY accepts orders with post_only, which only rest on the book
+	POST_ONLY = "post_only"
+)
+
+var known = []string{POST_ONLY}
+
+// Known reports whether name is the flag of a behavior of the engine
+func Known(name string) bool {
+	return slices.Contains(known, name)
+}
+
+// Flag targets a behavior. A disabled flag is off everywhere, which rolls it
+// back at once. An enabled one is on for the listed pairs, every pair if
+// there are none, and there for the listed accounts and Percent of the
+// others. Without accounts nor a percent it's on for every account.
+type Flag struct {
+	Enabled  bool     `json:"enabled" yaml:"enabled"`
+	Pairs    []string `json:"pairs,omitempty" yaml:"pairs"`
+	Accounts []int    `json:"accounts,omitempty" yaml:"accounts"`
+	Percent  int      `json:"percent,omitempty" yaml:"percent"`
+}
+
+// On reports whether the flag name is on for an account trading pairId
+func (f Flag) On(name string, pairId string, accountId int) bool {
+	if !f.Enabled {
+		return false
+	}
+	if len(f.Pairs) > 0 && !slices.Contains(f.Pairs, pairId) {
+		return false
+	}
+	if slices.Contains(f.Accounts, accountId) {
+		return true
+	}
+	if f.Percent > 0 {
+		return bucket(name, accountId) < f.Percent
+	}
+	return len(f.Accounts) == 0
+}
+
+func (f Flag) Equal(other Flag) bool {
+	return f.Enabled == other.Enabled && f.Percent == other.Percent &&
+		slices.Equal(f.Pairs, other.Pairs) && slices.Equal(f.Accounts, other.Accounts)
+}
+
+// bucket places an account between 0 and 99 for a flag, an account stays in
+// the rollout as the percent grows
+func bucket(name string, accountId int) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + ":" + strconv.Itoa(accountId)))
+	return int(h.Sum32() % 100)
+}
+
+// Set holds the flags in effect, they're changed while the engine runs by
+// the config file or the admin API. A flag that isn't set is off.
+type Set struct {
+	mu    sync.RWMutex
+	flags map[string]Flag
+}
+
+func NewSet(flags map[string]Flag) *Set {
+	return &Set{flags: maps.Clone(flags)}
+}
+
+// Enabled reports whether the flag name is on for an account trading pairId
+func (s *Set) Enabled(name string, pairId string, accountId int) bool {
+	s.mu.RLock()
+	f, ok := s.flags[name]
+	s.mu.RUnlock()
+	return ok && f.On(name, pairId, accountId)
+}
+
+// Set replaces a flag and returns the one it replaced
+func (s *Set) Set(name string, f Flag) (before Flag, existed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.flags == nil {
+		s.flags = make(map[string]Flag)
+	}
+	before, existed = s.flags[name]
+	s.flags[name] = f
+	return before, existed
+}
+
+// Remove turns a flag off by removing it
+func (s *Set) Remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.flags, name)
+}
+
+func (s *Set) All() map[string]Flag {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.flags)
+}
@@ -0,0 +1,60 @@
+package funding
+
+import (
+	"net/http"
+	"order-book/tenant"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindFundingRouter serves the current and predicted funding rates of the
+// perpetual pairs on GET /funding and GET /funding/:pair_id, and their last
+// fundings with the payments on GET /funding/:pair_id/history, those of the
+// book of the tenant of the request
+func BindFundingRouter(r fiber.Router, e *Engine) {
+	r.Get("/funding", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    e.Rates(tenant.FromCtx(c).ID),
+		})
+	})
+
+	r.Get("/funding/:pair_id", func(c *fiber.Ctx) error {
+		rate, ok := e.Rate(tenant.FromCtx(c).ID, c.Params("pair_id"))
+		if !ok {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The pair isn't perpetual",
+				Data:    nil,
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    rate,
+		})
+	})
+
+	r.Get("/funding/:pair_id/history", func(c *fiber.Ctx) error {
+		history, ok := e.History(tenant.FromCtx(c).ID, c.Params("pair_id"))
+		if !ok {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The pair isn't perpetual",
+				Data:    nil,
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    history,
+		})
+	})
+}
@@ -0,0 +1,368 @@
+// Package funding charges the funding of the perpetual pairs. The premium of
+// the book over the index is sampled through each funding interval, and at
+// its end the average, capped, is the funding rate: the holders of long
+// positions pay it to those holding short ones when it's positive and get it
+// when it's negative, on the notional of their positions at the index. Each
+// tenant trades the pairs on its own book, so its premiums and rates are its
+// own and its positions are funded at them.
+package funding
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"order-book/account"
+	"order-book/book"
+	"order-book/logger"
+	"order-book/order"
+	"order-book/position"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// maxHistory bounds the fundings kept per pair
+const maxHistory = 100
+
+var fundingLog = logger.Component("funding")
+
+// Options are how the perpetual Pairs are funded. Fundings happen at the
+// multiples of Interval, 00:00, 08:00 and 16:00 UTC for 8h, the premium is
+// sampled every SampleInterval and the rate is capped to MaxRate either way.
+type Options struct {
+	Pairs          []string
+	Interval       time.Duration
+	SampleInterval time.Duration
+	MaxRate        float64
+}
+
+// Books are the books of the tenants by tenant ID, they price the pairs
+type Books interface {
+	Books() map[string]book.Book
+}
+
+// Index is the reference price of the pairs
+type Index interface {
+	Price(pairId string) (decimal.Decimal, bool)
+}
+
+// Positions are the positions held on the pairs
+type Positions interface {
+	PairPositions(pairId string) []position.Position
+}
+
+// Rate is the funding of a perpetual pair. Rate is that of the last funding,
+// PredictedRate that of the next one from the premiums sampled so far.
+// MarkPrice is the price of the pair on its book: the mid of the best bid
+// and ask, or the last price while a side is empty.
+type Rate struct {
+	PairID        string          `json:"pair_id"`
+	Rate          float64         `json:"rate"`
+	PredictedRate float64         `json:"predicted_rate"`
+	Premium       float64         `json:"premium"`
+	MarkPrice     decimal.Decimal `json:"mark_price"`
+	IndexPrice    decimal.Decimal `json:"index_price"`
+	Samples       int             `json:"samples"`
+	LastFundingAt time.Time       `json:"last_funding_at"`
+	NextFundingAt time.Time       `json:"next_funding_at"`
+}
+
+// Payment is what a position paid, negative, or got at a funding, truncated
+// to the decimals the ledger keeps
+type Payment struct {
+	AccountID int             `json:"account_id"`
+	Quantity  decimal.Decimal `json:"quantity"`
+	Amount    decimal.Decimal `json:"amount"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// Funding is a funding of a pair on the book of a tenant and its payments,
+// in the quote asset
+type Funding struct {
+	PairID     string          `json:"pair_id"`
+	Rate       float64         `json:"rate"`
+	IndexPrice decimal.Decimal `json:"index_price"`
+	Asset      string          `json:"asset"`
+	At         time.Time       `json:"at"`
+	Payments   []Payment       `json:"payments"`
+}
+
+// pairKey is a pair on the book of a tenant
+type pairKey struct {
+	tenantId string
+	pairId   string
+}
+
+type pairState struct {
+	premiums []float64
+	premium  float64
+	mark     decimal.Decimal
+	index    decimal.Decimal
+	last     *Funding
+	history  []Funding
+}
+
+type Engine struct {
+	opts      Options
+	books     Books
+	tenantOf  func(accountId int) string
+	index     Index
+	positions Positions
+	balances  account.BalanceRepo
+
+	mu    sync.Mutex
+	pairs map[pairKey]*pairState
+	// due is the end of the running funding interval
+	due time.Time
+	now func() time.Time
+}
+
+// NewEngine funds the positions of an account at the rate of the book of
+// its tenant, tenantOf
+func NewEngine(opts Options, books Books, tenantOf func(accountId int) string, index Index, positions Positions, balances account.BalanceRepo) *Engine {
+	e := &Engine{
+		opts:      opts,
+		books:     books,
+		tenantOf:  tenantOf,
+		index:     index,
+		positions: positions,
+		balances:  balances,
+		pairs:     make(map[pairKey]*pairState),
+		now:       time.Now,
+	}
+	e.due = e.nextFunding(e.now())
+	return e
+}
+
+// state is that of a pair on the book of a tenant, callers hold e.mu
+func (e *Engine) state(tenantId string, pairId string) *pairState {
+	key := pairKey{tenantId: tenantId, pairId: pairId}
+	s, ok := e.pairs[key]
+	if !ok {
+		s = &pairState{}
+		e.pairs[key] = s
+	}
+	return s
+}
+
+func (e *Engine) perpetual(pairId string) bool {
+	return slices.Contains(e.opts.Pairs, pairId)
+}
+
+func (e *Engine) nextFunding(after time.Time) time.Time {
+	return after.Truncate(e.opts.Interval).Add(e.opts.Interval)
+}
+
+// Run samples the premiums and funds the pairs on schedule until ctx is done
+func (e *Engine) Run(ctx context.Context) {
+	fundingLog.Info("funding started", map[string]any{
+		"pairs":    e.opts.Pairs,
+		"interval": e.opts.Interval.String(),
+	})
+	ticker := time.NewTicker(e.opts.SampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.sample()
+			now := e.now()
+			e.mu.Lock()
+			due := e.due
+			if !now.Before(due) {
+				e.due = e.nextFunding(now)
+			}
+			e.mu.Unlock()
+			if !now.Before(due) {
+				for _, pairId := range e.opts.Pairs {
+					e.fund(pairId, due)
+				}
+			}
+		}
+	}
+}
+
+// sample records the premium of each tenant book over the index of each
+// pair, a pair without both prices is skipped
+func (e *Engine) sample() {
+	books := e.books.Books()
+	for _, pairId := range e.opts.Pairs {
+		index, ok := e.index.Price(pairId)
+		if !ok || !index.IsPositive() {
+			continue
+		}
+		for tenantId, b := range books {
+			mark, ok := markPrice(b, pairId)
+			if !ok {
+				continue
+			}
+			premium := mark.Sub(index).Div(index).InexactFloat64()
+			e.mu.Lock()
+			s := e.state(tenantId, pairId)
+			s.premiums = append(s.premiums, premium)
+			s.premium, s.mark, s.index = premium, mark, index
+			e.mu.Unlock()
+		}
+	}
+}
+
+func markPrice(b book.Book, pairId string) (decimal.Decimal, bool) {
+	if bid, ask, ok := b.Top(pairId); ok {
+		return bid.Add(ask).Div(decimal.NewFromInt(2)), true
+	}
+	return b.LastPrice(pairId)
+}
+
+// rate is the average of the premiums capped to the max rate, callers hold
+// e.mu
+func (e *Engine) rate(premiums []float64) float64 {
+	if len(premiums) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, p := range premiums {
+		sum += p
+	}
+	return math.Max(-e.opts.MaxRate, math.Min(e.opts.MaxRate, sum/float64(len(premiums))))
+}
+
+// fund charges the funding of a pair for the interval ending at due, on
+// every tenant book. Each payment is its own ledger transaction against the
+// funding pool, keyed by the pair, the interval and the account so a retry
+// never pays twice.
+func (e *Engine) fund(pairId string, due time.Time) {
+	byTenant := make(map[string][]position.Position)
+	for _, p := range e.positions.PairPositions(pairId) {
+		tenantId := e.tenantOf(p.AccountID)
+		byTenant[tenantId] = append(byTenant[tenantId], p)
+	}
+	e.mu.Lock()
+	var tenants []string
+	for key := range e.pairs {
+		if key.pairId == pairId {
+			tenants = append(tenants, key.tenantId)
+		}
+	}
+	e.mu.Unlock()
+	slices.Sort(tenants)
+	for _, tenantId := range tenants {
+		e.fundTenant(tenantId, pairId, due, byTenant[tenantId])
+	}
+}
+
+// fundTenant charges the funding of a pair to the positions on the book of
+// a tenant, at the rate of its premiums
+func (e *Engine) fundTenant(tenantId string, pairId string, due time.Time, positions []position.Position) {
+	e.mu.Lock()
+	s := e.state(tenantId, pairId)
+	rate := e.rate(s.premiums)
+	s.premiums = nil
+	index := s.index
+	e.mu.Unlock()
+
+	_, asset, _ := order.SplitPairID(pairId)
+	f := Funding{PairID: pairId, Rate: rate, IndexPrice: index, Asset: asset, At: due, Payments: []Payment{}}
+	if rate != 0 && index.IsPositive() {
+		notionalRate := index.Mul(decimal.NewFromFloat(rate))
+		for _, p := range positions {
+			// Longs pay a positive rate, shorts a negative one
+			amount := p.Quantity.Neg().Mul(notionalRate).Truncate(account.LedgerScale)
+			if amount.IsZero() {
+				continue
+			}
+			payment := Payment{AccountID: p.AccountID, Quantity: p.Quantity, Amount: amount}
+			_, _, err := e.balances.Post(account.Transaction{
+				Type:           account.FUNDING,
+				IdempotencyKey: fmt.Sprintf("funding:%s:%d:%d", pairId, due.Unix(), p.AccountID),
+				Source:         account.ENGINE,
+				Reference:      fmt.Sprintf("%s funding at %s", pairId, due.UTC().Format(time.RFC3339)),
+				Postings: []account.Posting{
+					{AccountID: p.AccountID, Asset: asset, Amount: amount},
+					{SystemAccount: account.FUNDING_POOL, Asset: asset, Amount: amount.Neg()},
+				},
+			})
+			if err != nil {
+				payment.Error = err.Error()
+				fundingLog.Error("failed to post a funding payment", map[string]any{
+					"tenant_id":  tenantId,
+					"pair_id":    pairId,
+					"account_id": p.AccountID,
+					"amount":     amount,
+					"error":      err,
+				})
+			}
+			f.Payments = append(f.Payments, payment)
+		}
+	}
+
+	e.mu.Lock()
+	s.last = &f
+	s.history = append(s.history, f)
+	if len(s.history) > maxHistory {
+		s.history = s.history[len(s.history)-maxHistory:]
+	}
+	e.mu.Unlock()
+	fundingLog.Info("pair funded", map[string]any{
+		"tenant_id": tenantId,
+		"pair_id":   pairId,
+		"rate":      rate,
+		"payments":  len(f.Payments),
+	})
+}
+
+// Rate is the current and predicted funding of a perpetual pair on the book
+// of a tenant
+func (e *Engine) Rate(tenantId string, pairId string) (Rate, bool) {
+	if !e.perpetual(pairId) {
+		return Rate{}, false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.state(tenantId, pairId)
+	r := Rate{
+		PairID:        pairId,
+		PredictedRate: e.rate(s.premiums),
+		Premium:       s.premium,
+		MarkPrice:     s.mark,
+		IndexPrice:    s.index,
+		Samples:       len(s.premiums),
+		NextFundingAt: e.due,
+	}
+	if s.last != nil {
+		r.Rate, r.LastFundingAt = s.last.Rate, s.last.At
+	}
+	return r, true
+}
+
+// Rates are the fundings of the perpetual pairs on the book of a tenant by
+// pair ID
+func (e *Engine) Rates(tenantId string) []Rate {
+	pairs := slices.Sorted(slices.Values(e.opts.Pairs))
+	rates := make([]Rate, 0, len(pairs))
+	for _, pairId := range pairs {
+		if r, ok := e.Rate(tenantId, pairId); ok {
+			rates = append(rates, r)
+		}
+	}
+	return rates
+}
+
+// History are the last fundings of a pair on the book of a tenant, the
+// latest first
+func (e *Engine) History(tenantId string, pairId string) ([]Funding, bool) {
+	if !e.perpetual(pairId) {
+		return nil, false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.state(tenantId, pairId)
+	history := slices.Clone(s.history)
+	slices.Reverse(history)
+	if history == nil {
+		history = []Funding{}
+	}
+	return history, true
+}
@@ -0,0 +1,60 @@
+package health
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindHealthRouter serves the probes of orchestrators: GET /healthz answers
+// as long as the process serves requests, GET /readyz is 503 with what the
+// node waits for until every check passes. checkTimeout bounds the checks of
+// a single request.
+func BindHealthRouter(r fiber.Router, readiness *Readiness, checkTimeout time.Duration) {
+	r.Get("/healthz", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Alive",
+			Data:    nil,
+		})
+	})
+
+	r.Get("/readyz", func(c *fiber.Ctx) error {
+		ready, failing := readiness.Status(c.UserContext(), checkTimeout)
+		if !ready {
+			c.Status(http.StatusServiceUnavailable)
+			return c.JSON(&Response{
+				Message: "Not ready",
+				Data:    failing,
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Ready",
+			Data:    nil,
+		})
+	})
+}
+
+// BindHealthDetailsRouter serves GET /health/details, every check with its
+// latency along with the state of the engine, for operators
+func BindHealthDetailsRouter(r fiber.Router, readiness *Readiness, checkTimeout time.Duration) {
+	r.Get("/health/details", func(c *fiber.Ctx) error {
+		details := readiness.Details(c.UserContext(), checkTimeout)
+		c.Status(http.StatusOK)
+		if !details.Ready {
+			c.Status(http.StatusServiceUnavailable)
+		}
+		return c.JSON(&Response{
+			Message: "",
+			Data:    details,
+		})
+	})
+}
@@ -0,0 +1,146 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check reports a dependency the node can't serve without
+type Check func(ctx context.Context) error
+
+// Readiness tells orchestrators whether the node should receive traffic:
+// its startup completed and every check passes
+type Readiness struct {
+	started   atomic.Bool
+	stopping  atomic.Bool
+	startedAt time.Time
+	mu        sync.Mutex
+	names     []string
+	checks    map[string]Check
+	infos     map[string]func() any
+}
+
+// CheckResult is the outcome of a check for operators
+type CheckResult struct {
+	OK       bool          `json:"ok"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Details is the readiness of the node along with what the checks found and
+// the state the components report through AddInfo
+type Details struct {
+	Ready     bool                   `json:"ready"`
+	Started   bool                   `json:"started"`
+	StartedAt time.Time              `json:"started_at"`
+	Uptime    time.Duration          `json:"uptime"`
+	Checks    map[string]CheckResult `json:"checks"`
+	Info      map[string]any         `json:"info"`
+}
+
+func NewReadiness() *Readiness {
+	return &Readiness{
+		startedAt: time.Now(),
+		checks:    make(map[string]Check),
+		infos:     make(map[string]func() any),
+	}
+}
+
+func (r *Readiness) Add(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.names = append(r.names, name)
+	r.checks[name] = check
+}
+
+// AddInfo adds a section to the details, fn is called on every request
+func (r *Readiness) AddInfo(name string, fn func() any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.infos[name] = fn
+}
+
+// Started is called once the engine is set up and recovered
+func (r *Readiness) Started() {
+	r.started.Store(true)
+}
+
+// Stopping is called once the node shuts down, it stops being ready so
+// traffic moves to the other nodes
+func (r *Readiness) Stopping() {
+	r.stopping.Store(true)
+}
+
+// Status runs the checks concurrently and returns the failing ones with their errors
+func (r *Readiness) Status(ctx context.Context, timeout time.Duration) (ready bool, failing map[string]string) {
+	failing = make(map[string]string)
+	if !r.started.Load() {
+		failing["startup"] = "Still starting"
+	}
+	if r.stopping.Load() {
+		failing["shutdown"] = "Shutting down"
+	}
+	for name, res := range r.run(ctx, timeout) {
+		if !res.OK {
+			failing[name] = res.Error
+		}
+	}
+	return len(failing) == 0, failing
+}
+
+// Details runs the checks like Status and collects the info sections
+func (r *Readiness) Details(ctx context.Context, timeout time.Duration) Details {
+	checks := r.run(ctx, timeout)
+	started := r.started.Load()
+	ready := started && !r.stopping.Load()
+	for _, res := range checks {
+		ready = ready && res.OK
+	}
+	r.mu.Lock()
+	infos := r.infos
+	r.mu.Unlock()
+	info := make(map[string]any, len(infos))
+	for name, fn := range infos {
+		info[name] = fn()
+	}
+	return Details{
+		Ready:     ready,
+		Started:   started,
+		StartedAt: r.startedAt,
+		Uptime:    time.Since(r.startedAt),
+		Checks:    checks,
+		Info:      info,
+	}
+}
+
+func (r *Readiness) run(ctx context.Context, timeout time.Duration) map[string]CheckResult {
+	r.mu.Lock()
+	names := r.names
+	checks := r.checks
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	results := make(map[string]CheckResult, len(names))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := checks[name](ctx)
+			res := CheckResult{OK: err == nil, Duration: time.Since(start)}
+			if err != nil {
+				res.Error = err.Error()
+			}
+			mu.Lock()
+			results[name] = res
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,102 @@
+package httperr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"order-book/logger"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+var ErrInvalidBody = errors.New("Invalid request body")
+
+var httpLog = logger.Component("http")
+
+// Response is the envelope of every error, the shape of the responses of the
+// controllers. Error is the status text and RequestID is the X-Request-ID of
+// the request, to find it in the logs.
+type Response struct {
+	Message   string `json:"message"`
+	Data      any    `json:"data"`
+	Error     string `json:"Error"`
+	RequestID string `json:"request_id"`
+}
+
+var (
+	mu     sync.RWMutex
+	public []publicError
+)
+
+type publicError struct {
+	err    error
+	status int
+}
+
+// Register lets handlers return err, or an error wrapping it, as is: the
+// client gets status with the message of err. Any other error is a 500
+// without its message, as it could hold internals like a database error.
+func Register(err error, status int) {
+	mu.Lock()
+	defer mu.Unlock()
+	public = append(public, publicError{err: err, status: status})
+}
+
+func init() {
+	Register(ErrInvalidBody, http.StatusBadRequest)
+}
+
+// lookup returns the status and the message a client gets for err
+func lookup(err error) (int, string) {
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return fe.Code, fe.Message
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, p := range public {
+		if errors.Is(err, p.err) {
+			return p.status, p.err.Error()
+		}
+	}
+	return http.StatusInternalServerError, utils.StatusMessage(http.StatusInternalServerError)
+}
+
+// Handler is the fiber.Config ErrorHandler, it answers an error with the
+// envelope. Errors Middleware didn't see, like those of fasthttp, are logged.
+func Handler(c *fiber.Ctx, err error) error {
+	status, message := lookup(err)
+	if _, ok := err.(*fiber.Error); !ok {
+		logError(c, status, err)
+	}
+	c.Status(status)
+	return c.JSON(&Response{
+		Message:   message,
+		Data:      nil,
+		Error:     utils.StatusMessage(status),
+		RequestID: RequestID(c),
+	})
+}
+
+func logError(c *fiber.Ctx, status int, err error) {
+	fields := requestFields(c)
+	fields["status"] = status
+	fields["error"] = err
+	if status >= http.StatusInternalServerError {
+		httpLog.Error("request failed", fields)
+		return
+	}
+	httpLog.Debug("request rejected", fields)
+}
+
+// DecodeJSON is the fiber.Config JSONDecoder, a body that doesn't decode is
+// ErrInvalidBody rather than an internal error
+func DecodeJSON(data []byte, v any) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBody, err)
+	}
+	return nil
+}
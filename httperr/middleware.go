@@ -0,0 +1,58 @@
+package httperr
+
+import (
+	"order-book/panics"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+// requestIDKey is the local the request ID is stored under
+const requestIDKey = "requestid"
+
+// RequestIDs gives every request an ID, the X-Request-ID header of the client
+// or a new one, and returns it as X-Request-ID
+func RequestIDs() fiber.Handler {
+	return requestid.New(requestid.Config{ContextKey: requestIDKey})
+}
+
+// RequestID is the ID of a request, safe to keep after the request
+func RequestID(c *fiber.Ctx) string {
+	id, _ := c.Locals(requestIDKey).(string)
+	// The ID sent by the client is a view of the request buffer
+	return strings.Clone(id)
+}
+
+// Middleware recovers the panics of the handlers after it and logs the errors
+// they return with the request ID. Errors leave it as the *fiber.Error the
+// client gets, so the middlewares before it see the final status, and
+// Handler writes them.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) (err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				panics.Capture("http", recovered, requestFields(c))
+				err = fiber.ErrInternalServerError
+			}
+		}()
+		err = c.Next()
+		if err == nil {
+			return nil
+		}
+		status, message := lookup(err)
+		if _, ok := err.(*fiber.Error); !ok {
+			logError(c, status, err)
+		}
+		return fiber.NewError(status, message)
+	}
+}
+
+func requestFields(c *fiber.Ctx) map[string]any {
+	// The method and path are views of buffers fasthttp reuses
+	return map[string]any{
+		"request_id": RequestID(c),
+		"method":     strings.Clone(c.Method()),
+		"path":       strings.Clone(c.Path()),
+	}
+}
@@ -0,0 +1,41 @@
+package index
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindIndexRouter serves the index prices on GET /index-prices, and that of
+// a pair with the price of each source on GET /index-prices/:pair_id
+func BindIndexRouter(r fiber.Router, s *Service) {
+	r.Get("/index-prices", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    s.All(),
+		})
+	})
+
+	r.Get("/index-prices/:pair_id", func(c *fiber.Ctx) error {
+		p, ok := s.Get(c.Params("pair_id"))
+		if !ok {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The pair has no index price",
+				Data:    nil,
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    p,
+		})
+	})
+}
@@ -0,0 +1,228 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/shopspring/decimal"
+)
+
+// Kinds of feed
+const (
+	HTTP = "http"
+	WS   = "ws"
+)
+
+const (
+	requestTimeout = 5 * time.Second
+	maxRetryDelay  = 30 * time.Second
+)
+
+var errNoPrice = errors.New("no price at the path")
+
+// Feed is an external source of prices. Symbols maps the pairs to the
+// symbols of the source. An http feed gets URL every Interval for each pair,
+// {symbol} in it replaced by the symbol of the pair, and reads the price at
+// PricePath of the JSON it answers. A ws feed connects to URL, sends
+// Subscribe, {symbols} in it replaced by the symbols as a JSON list, and
+// reads the symbol at SymbolPath and the price at PricePath of each message.
+// Paths are dot separated keys and list indexes, like data.0.price.
+type Feed struct {
+	Name       string
+	Kind       string
+	URL        string
+	Interval   time.Duration
+	Subscribe  string
+	SymbolPath string
+	PricePath  string
+	Symbols    map[string]string
+}
+
+var client = &http.Client{Timeout: requestTimeout}
+
+// Run feeds the prices of the source to the index until ctx is done
+func (f Feed) Run(ctx context.Context, s *Service) {
+	indexLog.Info("index feed started", map[string]any{
+		"source": f.Name,
+		"kind":   f.Kind,
+		"pairs":  len(f.Symbols),
+	})
+	if f.Kind == WS {
+		f.stream(ctx, s)
+		return
+	}
+	ticker := time.NewTicker(f.Interval)
+	defer ticker.Stop()
+	for {
+		for pairId, symbol := range f.Symbols {
+			price, err := f.poll(ctx, symbol)
+			if err != nil {
+				if ctx.Err() == nil {
+					indexLog.Warn("failed to get an index price", map[string]any{
+						"source":  f.Name,
+						"pair_id": pairId,
+						"error":   err,
+					})
+				}
+				continue
+			}
+			s.Update(f.Name, pairId, price, time.Now())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (f Feed) poll(ctx context.Context, symbol string) (decimal.Decimal, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.ReplaceAll(f.URL, "{symbol}", symbol), nil)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return decimal.Zero, fmt.Errorf("status %d", res.StatusCode)
+	}
+	body, err := decode(res.Body)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return priceAt(body, f.PricePath)
+}
+
+// stream reads the prices of a ws feed, connecting again when the
+// connection fails
+func (f Feed) stream(ctx context.Context, s *Service) {
+	bySymbol := make(map[string]string, len(f.Symbols))
+	symbols := make([]string, 0, len(f.Symbols))
+	for pairId, symbol := range f.Symbols {
+		bySymbol[symbol] = pairId
+		symbols = append(symbols, symbol)
+	}
+	delay := time.Second
+	for {
+		err := f.follow(ctx, s, bySymbol, symbols)
+		if ctx.Err() != nil {
+			return
+		}
+		indexLog.Warn("lost the index feed", map[string]any{
+			"source": f.Name,
+			"error":  err,
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxRetryDelay)
+	}
+}
+
+func (f Feed) follow(ctx context.Context, s *Service, bySymbol map[string]string, symbols []string) error {
+	dialCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	conn, _, err := websocket.DefaultDialer.DialContext(dialCtx, f.URL, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// The connection is closed once ctx is done to end the read
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if f.Subscribe != "" {
+		list, _ := json.Marshal(symbols)
+		msg := strings.ReplaceAll(f.Subscribe, "{symbols}", string(list))
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			return err
+		}
+	}
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		msg, err := decode(bytes.NewReader(data))
+		if err != nil {
+			continue
+		}
+		// Acks and heartbeats carry no price
+		symbol, ok := lookup(msg, f.SymbolPath)
+		if !ok {
+			continue
+		}
+		pairId, ok := bySymbol[fmt.Sprint(symbol)]
+		if !ok {
+			continue
+		}
+		price, err := priceAt(msg, f.PricePath)
+		if err != nil {
+			continue
+		}
+		s.Update(f.Name, pairId, price, time.Now())
+	}
+}
+
+func decode(r io.Reader) (any, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var v any
+	err := dec.Decode(&v)
+	return v, err
+}
+
+// lookup follows a dot separated path of keys and list indexes
+func lookup(v any, path string) (any, bool) {
+	if path == "" {
+		return v, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			next, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []any:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			v = node[idx]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// priceAt reads a price given as a JSON number or string
+func priceAt(v any, path string) (decimal.Decimal, error) {
+	raw, ok := lookup(v, path)
+	if !ok {
+		return decimal.Zero, errNoPrice
+	}
+	switch price := raw.(type) {
+	case json.Number:
+		return decimal.NewFromString(price.String())
+	case string:
+		return decimal.NewFromString(price)
+	}
+	return decimal.Zero, errNoPrice
+}
@@ -0,0 +1,258 @@
+// Package index computes reference prices of the pairs from external feeds.
+// The index of a pair is the median of the latest prices of its sources,
+// without those older than the max age or too far from the median of the
+// others. Mark pricing values positions at it, and listeners are told of
+// every change, for the components guarding prices against it.
+package index
+
+import (
+	"context"
+	"order-book/logger"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Statuses of the components of an index
+const (
+	USED    = "USED"
+	OUTLIER = "OUTLIER"
+	STALE   = "STALE"
+)
+
+var indexLog = logger.Component("index")
+
+// Component is the latest price of a source for a pair and whether the index
+// counts it
+type Component struct {
+	Source string          `json:"source"`
+	Price  decimal.Decimal `json:"price"`
+	At     time.Time       `json:"at"`
+	Status string          `json:"status"`
+}
+
+// Price is the index of a pair. Ok is false while too few sources are fresh
+// and in line, Price is then the last index there was.
+type Price struct {
+	PairID     string          `json:"pair_id"`
+	Price      decimal.Decimal `json:"price"`
+	Ok         bool            `json:"ok"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	Components []Component     `json:"components"`
+}
+
+// Options are how the index is computed. A price older than MaxAge is stale,
+// one more than MaxDeviation (a ratio) from the median of the others is an
+// outlier, and the index needs MinSources prices that are neither.
+type Options struct {
+	MaxAge       time.Duration
+	MaxDeviation float64
+	MinSources   int
+}
+
+type Service struct {
+	opts Options
+
+	mu        sync.RWMutex
+	quotes    map[string]map[string]Component
+	prices    map[string]Price
+	listeners []func(p Price)
+	now       func() time.Time
+}
+
+func NewService(opts Options) *Service {
+	return &Service{
+		opts:   opts,
+		quotes: make(map[string]map[string]Component),
+		prices: make(map[string]Price),
+		now:    time.Now,
+	}
+}
+
+// OnUpdate registers a listener for the index of a pair changing, or
+// becoming available or not
+func (s *Service) OnUpdate(fn func(p Price)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Update records the price of a pair from a source
+func (s *Service) Update(source string, pairId string, price decimal.Decimal, at time.Time) {
+	if !price.IsPositive() {
+		return
+	}
+	s.mu.Lock()
+	if s.quotes[pairId] == nil {
+		s.quotes[pairId] = make(map[string]Component)
+	}
+	s.quotes[pairId][source] = Component{Source: source, Price: price, At: at}
+	s.mu.Unlock()
+	s.recompute(pairId)
+}
+
+// Run recomputes the indexes every interval, for the prices going stale
+// while their sources are silent
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.RLock()
+			pairs := make([]string, 0, len(s.quotes))
+			for pairId := range s.quotes {
+				pairs = append(pairs, pairId)
+			}
+			s.mu.RUnlock()
+			for _, pairId := range pairs {
+				s.recompute(pairId)
+			}
+		}
+	}
+}
+
+func (s *Service) recompute(pairId string) {
+	s.mu.Lock()
+	prev := s.prices[pairId]
+	next := compute(pairId, s.quotes[pairId], s.opts, s.now())
+	if !next.Ok {
+		next.Price, next.UpdatedAt = prev.Price, prev.UpdatedAt
+	}
+	s.prices[pairId] = next
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	if next.Ok == prev.Ok && next.Price.Equal(prev.Price) {
+		return
+	}
+	if next.Ok != prev.Ok {
+		indexLog.Info("index availability changed", map[string]any{
+			"pair_id": pairId,
+			"ok":      next.Ok,
+		})
+	}
+	for _, fn := range listeners {
+		fn(next)
+	}
+}
+
+// compute is the index of a pair from the latest price of each source
+func compute(pairId string, quotes map[string]Component, opts Options, now time.Time) Price {
+	p := Price{PairID: pairId, Components: make([]Component, 0, len(quotes))}
+	var fresh []decimal.Decimal
+	for _, c := range quotes {
+		if opts.MaxAge > 0 && now.Sub(c.At) > opts.MaxAge {
+			c.Status = STALE
+		} else {
+			fresh = append(fresh, c.Price)
+		}
+		p.Components = append(p.Components, c)
+	}
+	slices.SortFunc(p.Components, func(a, b Component) int {
+		switch {
+		case a.Source < b.Source:
+			return -1
+		case a.Source > b.Source:
+			return 1
+		}
+		return 0
+	})
+	if len(fresh) == 0 {
+		return p
+	}
+
+	mid := median(fresh)
+	var used []decimal.Decimal
+	for idx, c := range p.Components {
+		if c.Status == STALE {
+			continue
+		}
+		if opts.MaxDeviation > 0 && c.Price.Sub(mid).Abs().Div(mid).InexactFloat64() > opts.MaxDeviation {
+			p.Components[idx].Status = OUTLIER
+			continue
+		}
+		p.Components[idx].Status = USED
+		used = append(used, c.Price)
+	}
+	if len(used) == 0 || len(used) < opts.MinSources {
+		return p
+	}
+	p.Price = median(used)
+	p.Ok = true
+	p.UpdatedAt = now
+	return p
+}
+
+func median(prices []decimal.Decimal) decimal.Decimal {
+	sorted := slices.Clone(prices)
+	slices.SortFunc(sorted, func(a, b decimal.Decimal) int { return a.Cmp(b) })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+	return sorted[mid-1].Add(sorted[mid]).Div(decimal.NewFromInt(2))
+}
+
+// Price is the index of a pair, ok is false while there's none
+func (s *Service) Price(pairId string) (decimal.Decimal, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.prices[pairId]
+	return p.Price, ok && p.Ok
+}
+
+// Get is the index of a pair with its components
+func (s *Service) Get(pairId string) (Price, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.prices[pairId]
+	return p, ok
+}
+
+// All are the indexes of the pairs by pair ID
+func (s *Service) All() []Price {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]Price, 0, len(s.prices))
+	for _, p := range s.prices {
+		list = append(list, p)
+	}
+	slices.SortFunc(list, func(a, b Price) int {
+		switch {
+		case a.PairID < b.PairID:
+			return -1
+		case a.PairID > b.PairID:
+			return 1
+		}
+		return 0
+	})
+	return list
+}
+
+// MarkPriceSource is where the marks come from without an index
+type MarkPriceSource interface {
+	AccountLastPrice(accountId int, pairId string) (price decimal.Decimal, ok bool)
+}
+
+// MarkPrices values positions at the index of their pair, at the last price
+// of the book while it has none
+type MarkPrices struct {
+	index    *Service
+	fallback MarkPriceSource
+}
+
+func NewMarkPrices(index *Service, fallback MarkPriceSource) *MarkPrices {
+	return &MarkPrices{index: index, fallback: fallback}
+}
+
+func (m *MarkPrices) AccountLastPrice(accountId int, pairId string) (decimal.Decimal, bool) {
+	if price, ok := m.index.Price(pairId); ok {
+		return price, true
+	}
+	return m.fallback.AccountLastPrice(accountId, pairId)
+}
@@ -0,0 +1,211 @@
+//go:build integration
+
+// Package integration runs the engine against Postgres in a container, with
+// the migrations of db/migrations applied, and drives it over HTTP and
+// websockets like a client would. It needs Docker:
+//
+//	go test -tags=integration ./integration
+package integration
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+// Engine is the engine binary running against the database of the suite
+type Engine struct {
+	URL  string
+	Pool *pgxpool.Pool
+}
+
+var (
+	once     sync.Once
+	engine   *Engine
+	startErr error
+	cleanups []func()
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	// The engine goes before its database
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+	os.Exit(code)
+}
+
+// startEngine starts the database and the engine once for the whole suite,
+// the tests are skipped without a container runtime
+func startEngine(t *testing.T) *Engine {
+	t.Helper()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	once.Do(func() {
+		engine, startErr = start(context.Background())
+	})
+	if startErr != nil {
+		t.Fatal(startErr)
+	}
+	return engine
+}
+
+func start(ctx context.Context) (*Engine, error) {
+	container, err := postgres.Run(ctx, "postgres:16-alpine",
+		postgres.WithDatabase("order_book"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		postgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start postgres: %w", err)
+	}
+	cleanups = append(cleanups, func() { testcontainers.TerminateContainer(container) })
+	dsn, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		return nil, err
+	}
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, err
+	}
+	cleanups = append(cleanups, pool.Close)
+	if err := migrate(ctx, pool); err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "order-book-integration")
+	if err != nil {
+		return nil, err
+	}
+	cleanups = append(cleanups, func() { os.RemoveAll(dir) })
+	bin := filepath.Join(dir, "order-book")
+	build := exec.Command("go", "build", "-o", bin, "..")
+	build.Stdout, build.Stderr = os.Stdout, os.Stderr
+	if err := build.Run(); err != nil {
+		return nil, fmt.Errorf("failed to build the engine: %w", err)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(bin)
+	cmd.Env = append(os.Environ(),
+		"DB_DRIVER=postgres",
+		"DB_DSN="+dsn,
+		fmt.Sprintf("HTTP_PORT=%d", port),
+		"ADMIN_ADDR=",
+		"NOTIFY_ENABLED=true",
+		"LOG_LEVEL=warn",
+	)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	cleanups = append(cleanups, func() {
+		cmd.Process.Signal(os.Interrupt)
+		cmd.Wait()
+	})
+
+	e := &Engine{URL: fmt.Sprintf("http://127.0.0.1:%d", port), Pool: pool}
+	if err := e.waitReady(ctx, time.Minute); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// migrate applies the up migrations in order and records the version the
+// way migrate does, the engine refuses to start without it
+func migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	files, err := filepath.Glob("../db/migrations/*.up.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	var version int
+	for _, file := range files {
+		sql, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if _, err := pool.Exec(ctx, string(sql)); err != nil {
+			return fmt.Errorf("migration %s: %w", filepath.Base(file), err)
+		}
+		if _, err := fmt.Sscanf(filepath.Base(file), "%d_", &version); err != nil {
+			return fmt.Errorf("migration %s: %w", filepath.Base(file), err)
+		}
+	}
+	_, err = pool.Exec(ctx, fmt.Sprintf(`
+		CREATE TABLE schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL);
+		INSERT INTO schema_migrations (version, dirty) VALUES (%d, false);`, version))
+	return err
+}
+
+func (e *Engine) waitReady(ctx context.Context, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		res, err := http.Get(e.URL + "/readyz")
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+	return fmt.Errorf("the engine wasn't ready within %s", timeout)
+}
+
+// CreateAccount opens an account of the default tenant. It's inserted
+// directly, accounts the engine didn't load are the default tenant's.
+func (e *Engine) CreateAccount(t *testing.T) int {
+	t.Helper()
+	var id int
+	err := e.Pool.QueryRow(context.Background(), "INSERT INTO tbl_accounts DEFAULT VALUES RETURNING id").Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// eventually retries fn until it succeeds or timeout passes, for the writes
+// the engine batches
+func eventually(t *testing.T, timeout time.Duration, fn func() error) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		err := fn()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,136 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"order-book/client"
+	"order-book/order"
+	"order-book/pgnotify"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// TestOrderFlow follows a match from the HTTP submit of its orders to the
+// rows it leaves, the history served back and the updates of the user
+// stream
+func TestOrderFlow(t *testing.T) {
+	e := startEngine(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	c := client.New(e.URL, client.Options{})
+	seller := e.CreateAccount(t)
+	buyer := e.CreateAccount(t)
+
+	updates := make(chan pgnotify.OrderUpdate, 16)
+	go c.SubscribeUserStream(ctx, buyer, func(u pgnotify.OrderUpdate) { updates <- u })
+	// The stream has no ack, the updates sent before it's up would be missed
+	time.Sleep(500 * time.Millisecond)
+
+	askId, err := c.SubmitOrder(ctx, order.Order{
+		AccountID: seller,
+		PairID:    "btcusdt",
+		Type:      order.ASK,
+		Price:     decimal.RequireFromString("65000"),
+		Amount:    decimal.RequireFromString("1"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bidId, err := c.SubmitOrder(ctx, order.Order{
+		AccountID: buyer,
+		PairID:    "btcusdt",
+		Type:      order.BID,
+		Price:     decimal.RequireFromString("65000"),
+		Amount:    decimal.RequireFromString("0.4"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("persisted", func(t *testing.T) {
+		ask, err := c.GetOrder(ctx, seller, askId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ask.PairID != "btcusdt" || ask.Type != order.ASK || !ask.Price.Equal(decimal.RequireFromString("65000")) {
+			t.Fatalf("got order %+v", ask)
+		}
+		var makerId, takerId int
+		var price, amount decimal.Decimal
+		err = e.Pool.QueryRow(ctx, "SELECT maker_order_id, taker_order_id, price, amount FROM tbl_trades WHERE taker_order_id = $1", bidId).
+			Scan(&makerId, &takerId, &price, &amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if makerId != askId || !price.Equal(decimal.RequireFromString("65000")) || !amount.Equal(decimal.RequireFromString("0.4")) {
+			t.Fatalf("got trade of maker %d %s@%s", makerId, amount, price)
+		}
+	})
+
+	t.Run("history", func(t *testing.T) {
+		// History events are written in batches
+		eventually(t, 5*time.Second, func() error {
+			return hasEvents(ctx, c, seller, askId, order.ORDER_CREATED, order.TARGET_HIT)
+		})
+		eventually(t, 5*time.Second, func() error {
+			return hasEvents(ctx, c, buyer, bidId, order.ORDER_CREATED, order.ORDER_FILLED)
+		})
+	})
+
+	t.Run("user stream", func(t *testing.T) {
+		var statuses []pgnotify.Status
+		timeout := time.After(5 * time.Second)
+		for !slices.Contains(statuses, pgnotify.FILL) {
+			select {
+			case u := <-updates:
+				if u.OrderID != bidId {
+					t.Fatalf("got an update of order %d on the stream of account %d", u.OrderID, buyer)
+				}
+				if u.Status == pgnotify.FILL && !u.FillAmount.Equal(decimal.RequireFromString("0.4")) {
+					t.Fatalf("got a fill of %s", u.FillAmount)
+				}
+				statuses = append(statuses, u.Status)
+			case <-timeout:
+				t.Fatalf("got updates %v, want a fill", statuses)
+			}
+		}
+		if statuses[0] != pgnotify.NEW {
+			t.Fatalf("got updates %v, want NEW first", statuses)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		if err := c.CancelOrder(ctx, askId); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.CancelOrder(ctx, askId); err != client.ErrOrderNotFound {
+			t.Fatalf("cancelled twice, got %v", err)
+		}
+		eventually(t, 5*time.Second, func() error {
+			return hasEvents(ctx, c, seller, askId, order.ORDER_CREATED, order.TARGET_HIT, order.ORDER_CANCELLED)
+		})
+	})
+}
+
+// hasEvents checks the history of an order holds names and no other event,
+// in any order, the events of a batch can share their time
+func hasEvents(ctx context.Context, c *client.Client, accountId int, orderId int, names ...string) error {
+	events, err := c.GetOrderHistory(ctx, accountId, orderId)
+	if err != nil {
+		return err
+	}
+	var got []string
+	for _, ev := range events {
+		got = append(got, ev.Name)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, slices.Sorted(slices.Values(names))) {
+		return fmt.Errorf("order %d: got events %v, want %v", orderId, got, names)
+	}
+	return nil
+}
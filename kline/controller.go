@@ -0,0 +1,89 @@
+package kline
+
+import (
+	"errors"
+	"net/http"
+	"order-book/logger"
+	"order-book/order"
+	"order-book/tenant"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var ErrInvalidData = errors.New("ErrInvalidData")
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindKlineRouter serves the candles of a pair. Closed candles are read from
+// the database and the open one from memory. The range is given as RFC 3339
+// from and to parameters and defaults to the last limit candles.
+func BindKlineRouter(r fiber.Router, candles order.CandleRepo, live *Live) {
+	r.Get("/market/:pair_id/klines", func(c *fiber.Ctx) error {
+		pairId := c.Params("pair_id")
+		interval := order.CandleInterval(c.Query("interval", string(order.CANDLE_1M)))
+		if interval.Duration() == 0 {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrInvalidData,
+				Message: order.ErrInvalidInterval.Error(),
+			})
+		}
+		limit := c.QueryInt("limit", 500)
+		if limit < 1 || limit > 1000 {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrInvalidData,
+				Message: "limit should be between 1 and 1000",
+			})
+		}
+
+		now := time.Now().UTC()
+		to := now
+		from := now.Truncate(interval.Duration()).Add(-time.Duration(limit-1) * interval.Duration())
+		for param, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+			if v := c.Query(param); v != "" {
+				t, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					c.Status(http.StatusBadRequest)
+					return c.JSON(&Response{
+						Error:   ErrInvalidData,
+						Message: param + " should be an RFC 3339 time",
+					})
+				}
+				*dst = t
+			}
+		}
+
+		tenantId := tenant.FromCtx(c).ID
+		// The aggregates may hold part of the open candle, it is left to Live
+		openTime := now.Truncate(interval.Duration())
+		closedTo := to
+		if closedTo.After(openTime) {
+			closedTo = openTime
+		}
+		res, err := candles.GetCandles(c.UserContext(), tenantId, pairId, interval, from, closedTo, limit)
+		if err != nil {
+			logger.Error("failed to get candles", map[string]any{
+				"pair_id":  pairId,
+				"interval": interval,
+				"error":    err,
+			})
+			return err
+		}
+		if !to.Before(openTime) && !from.After(openTime) && len(res) < limit {
+			if current, ok := live.Current(tenantId, pairId, interval, now); ok {
+				res = append(res, current)
+			}
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    res,
+		})
+	})
+}
@@ -0,0 +1,77 @@
+package kline
+
+import (
+	"order-book/book"
+	"order-book/order"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type candleKey struct {
+	tenantId string
+	pairId   string
+	interval order.CandleInterval
+}
+
+// Live keeps the candle still open for every pair and interval of the books
+// run by this node. The continuous aggregates only catch up with it once the
+// trades are written, so the open candle is always served from here.
+type Live struct {
+	mu      sync.RWMutex
+	candles map[candleKey]order.Candle
+}
+
+func NewLive() *Live {
+	return &Live{
+		candles: make(map[candleKey]order.Candle),
+	}
+}
+
+// Attach follows the trades of the book of a tenant, it is meant to be run
+// from a Registry.OnBook hook
+func (l *Live) Attach(tenantId string, b book.Book) {
+	b.OnTrade(func(t order.Trade) {
+		l.onTrade(tenantId, t)
+	})
+}
+
+func (l *Live) onTrade(tenantId string, t order.Trade) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, interval := range order.CandleIntervals {
+		key := candleKey{tenantId: tenantId, pairId: t.PairID, interval: interval}
+		openTime := t.ExecutedAt.UTC().Truncate(interval.Duration())
+		c, ok := l.candles[key]
+		if !ok || !c.OpenTime.Equal(openTime) {
+			c = order.Candle{
+				PairID:   t.PairID,
+				Interval: interval,
+				OpenTime: openTime,
+				Open:     t.Price,
+				High:     t.Price,
+				Low:      t.Price,
+			}
+		}
+		c.High = decimal.Max(c.High, t.Price)
+		c.Low = decimal.Min(c.Low, t.Price)
+		c.Close = t.Price
+		c.Volume = c.Volume.Add(t.Amount)
+		c.QuoteVolume = c.QuoteVolume.Add(t.Price.Mul(t.Amount))
+		c.Trades++
+		l.candles[key] = c
+	}
+}
+
+// Current returns the candle open at now, ok is false when the pair had no
+// trade since it opened
+func (l *Live) Current(tenantId string, pairId string, interval order.CandleInterval, now time.Time) (order.Candle, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	c, ok := l.candles[candleKey{tenantId: tenantId, pairId: pairId, interval: interval}]
+	if !ok || !c.OpenTime.Equal(now.UTC().Truncate(interval.Duration())) {
+		return order.Candle{}, false
+	}
+	return c, true
+}
@@ -0,0 +1,63 @@
+package ledgerqueue
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindLedgerQueueRouter serves the transactions the ledger refused for good
+// on GET /admin/ledger-queue/failed, and retries one on
+// POST /admin/ledger-queue/:id/retry
+func BindLedgerQueueRouter(r fiber.Router, q *Queue) {
+	r.Get("/admin/ledger-queue/failed", func(c *fiber.Ctx) error {
+		entries, err := q.Failed(c.UserContext())
+		if err != nil {
+			queueLog.Error("failed to get the failed ledger transactions", map[string]any{
+				"error": err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    entries,
+		})
+	})
+
+	r.Post("/admin/ledger-queue/:id/retry", func(c *fiber.Ctx) error {
+		id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid ID",
+				Data:    nil,
+			})
+		}
+		if err := q.Retry(c.UserContext(), id); err == ErrNotFailed {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		} else if err != nil {
+			queueLog.Error("failed to retry a ledger transaction", map[string]any{
+				"id":    id,
+				"error": err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "The transaction is queued again",
+			Data:    nil,
+		})
+	})
+}
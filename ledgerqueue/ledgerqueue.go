@@ -0,0 +1,257 @@
+// Package ledgerqueue posts the ledger transactions the engine owes the
+// accounts, like the maker rebates and the fees paid in a fee asset. They're
+// kept in the database until they're posted, so a failed post or a restart
+// doesn't lose them, and retried with a backoff while the ledger refuses
+// them. Those the ledger can never post are parked as failed until an
+// operator retries them.
+package ledgerqueue
+
+import (
+	"context"
+	"errors"
+	"order-book/account"
+	"order-book/logger"
+	"sync"
+	"time"
+)
+
+const (
+	// pollInterval is how often the queue is checked for the transactions
+	// due for a retry
+	pollInterval = time.Second
+	// batchSize bounds the transactions read at a time
+	batchSize  = 100
+	minBackoff = time.Second
+	maxBackoff = 10 * time.Minute
+)
+
+var ErrNotFailed = errors.New("No failed ledger transaction with this ID")
+
+var queueLog = logger.Component("ledgerqueue")
+
+// Entry is a queued transaction. Fallback, when set, is posted in its place
+// if the balance is short for it, FellBack is set once it's chosen so the
+// transaction is never posted too. Attempts and LastError describe the
+// failed attempts so far, FailedAt is when it was parked.
+type Entry struct {
+	ID            int64                `json:"id"`
+	Transaction   account.Transaction  `json:"transaction"`
+	Fallback      *account.Transaction `json:"fallback,omitempty"`
+	FellBack      bool                 `json:"fell_back"`
+	Attempts      int                  `json:"attempts"`
+	LastError     string               `json:"last_error,omitempty"`
+	NextAttemptAt time.Time            `json:"next_attempt_at"`
+	FailedAt      *time.Time           `json:"failed_at,omitempty"`
+	CreatedAt     time.Time            `json:"created_at"`
+}
+
+type Repo interface {
+	// AddTransaction queues an entry, one whose idempotency key is queued
+	// already is left as it is
+	AddTransaction(ctx context.Context, e Entry) error
+	// GetDueTransactions are the entries due for an attempt at a time, the
+	// oldest first and limit at most, the failed ones left out
+	GetDueTransactions(ctx context.Context, at time.Time, limit int) ([]Entry, error)
+	GetFailedTransactions(ctx context.Context) ([]Entry, error)
+	// RescheduleTransaction records a failed attempt and when the next one
+	// is due
+	RescheduleTransaction(ctx context.Context, id int64, lastError string, nextAttemptAt time.Time) error
+	SetFellBack(ctx context.Context, id int64) error
+	// FailTransaction records a failed attempt and parks the entry
+	FailTransaction(ctx context.Context, id int64, lastError string, at time.Time) error
+	// RetryFailedTransaction makes a failed entry due again, it's false when
+	// there's no failed entry with the ID
+	RetryFailedTransaction(ctx context.Context, id int64, nextAttemptAt time.Time) (bool, error)
+	DeleteTransaction(ctx context.Context, id int64) error
+}
+
+// Result is the outcome of an attempt to post a queued transaction. FellBack
+// is true when its fallback was posted instead, Err is set when neither was
+// and the transaction is retried.
+type Result struct {
+	Transaction account.Transaction
+	FellBack    bool
+	Err         error
+}
+
+type Queue struct {
+	repo     Repo
+	balances account.BalanceRepo
+	wake     chan struct{}
+
+	mu       sync.Mutex
+	handlers []func(Result)
+	// posting serializes the passes over the queue
+	posting sync.Mutex
+	now     func() time.Time
+}
+
+func NewQueue(repo Repo, balances account.BalanceRepo) *Queue {
+	return &Queue{
+		repo:     repo,
+		balances: balances,
+		wake:     make(chan struct{}, 1),
+		now:      time.Now,
+	}
+}
+
+// OnResult calls fn with the outcome of every attempt, from the goroutine
+// posting the queue
+func (q *Queue) OnResult(fn func(Result)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers = append(q.handlers, fn)
+}
+
+// Enqueue keeps a transaction until it's posted, fallback may be nil. The
+// transaction is posted by Run right after.
+func (q *Queue) Enqueue(ctx context.Context, t account.Transaction, fallback *account.Transaction) error {
+	err := q.repo.AddTransaction(ctx, Entry{
+		Transaction:   t,
+		Fallback:      fallback,
+		NextAttemptAt: q.now(),
+	})
+	if err != nil {
+		return err
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run posts the queued transactions as they come and retries those due
+// until ctx is done. What was queued before a restart is posted first.
+func (q *Queue) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		q.Drain()
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Drain posts the queued transactions that are due. It's called on shutdown
+// once nothing more is queued, what fails is retried on the next start.
+func (q *Queue) Drain() {
+	q.posting.Lock()
+	defer q.posting.Unlock()
+	for {
+		due, err := q.repo.GetDueTransactions(context.Background(), q.now(), batchSize)
+		if err != nil {
+			queueLog.Error("failed to get the queued ledger transactions", map[string]any{
+				"error": err,
+			})
+			return
+		}
+		for _, e := range due {
+			if err := q.post(e); err != nil {
+				queueLog.Error("failed to update a queued ledger transaction", map[string]any{
+					"idempotency_key": e.Transaction.IdempotencyKey,
+					"error":           err,
+				})
+				return
+			}
+		}
+		if len(due) < batchSize {
+			return
+		}
+	}
+}
+
+// Failed are the entries parked as the ledger refused them for good
+func (q *Queue) Failed(ctx context.Context) ([]Entry, error) {
+	return q.repo.GetFailedTransactions(ctx)
+}
+
+// Retry makes a failed entry due again, once whatever the ledger refused it
+// for is fixed
+func (q *Queue) Retry(ctx context.Context, id int64) error {
+	ok, err := q.repo.RetryFailedTransaction(ctx, id, q.now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFailed
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// post attempts a queued transaction, dropping it once it or its fallback
+// is posted. One the ledger refuses for good is parked, the next attempt of
+// the others is pushed back. The ledger keys them by their idempotency keys,
+// so an attempt never posts twice.
+func (q *Queue) post(e Entry) error {
+	res := Result{Transaction: e.Transaction}
+	if !e.FellBack {
+		_, _, res.Err = q.balances.Post(e.Transaction)
+	}
+	if e.Fallback != nil && (e.FellBack || errors.Is(res.Err, account.ErrInsufficientBalance)) {
+		// The choice is kept before the fallback is posted, a later attempt
+		// sticks to it whether or not the fallback went through
+		if !e.FellBack {
+			if err := q.repo.SetFellBack(context.Background(), e.ID); err != nil {
+				return err
+			}
+		}
+		_, _, res.Err = q.balances.Post(*e.Fallback)
+		res.FellBack = res.Err == nil
+	}
+	q.mu.Lock()
+	handlers := q.handlers
+	q.mu.Unlock()
+	for _, fn := range handlers {
+		fn(res)
+	}
+
+	if res.Err == nil {
+		return q.repo.DeleteTransaction(context.Background(), e.ID)
+	}
+	if permanent(res.Err) {
+		queueLog.Error("the ledger refused a queued transaction, it's parked until retried", map[string]any{
+			"id":              e.ID,
+			"idempotency_key": e.Transaction.IdempotencyKey,
+			"fell_back":       e.FellBack,
+			"error":           res.Err,
+		})
+		return q.repo.FailTransaction(context.Background(), e.ID, res.Err.Error(), q.now())
+	}
+	backoff := maxBackoff
+	if e.Attempts < 20 {
+		backoff = min(minBackoff<<e.Attempts, maxBackoff)
+	}
+	queueLog.Error("failed to post a queued ledger transaction, it's retried", map[string]any{
+		"idempotency_key": e.Transaction.IdempotencyKey,
+		"attempts":        e.Attempts + 1,
+		"retry_in":        backoff.String(),
+		"error":           res.Err,
+	})
+	return q.repo.RescheduleTransaction(context.Background(), e.ID, res.Err.Error(), q.now().Add(backoff))
+}
+
+// permanent reports whether the ledger refused a transaction for what it
+// is, which no retry changes
+func permanent(err error) bool {
+	for _, target := range []error{
+		account.ErrIdempotencyKeyReused,
+		account.ErrUnbalancedTransaction,
+		account.ErrInvalidPosting,
+		account.ErrInvalidAmount,
+		account.ErrAmountScale,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
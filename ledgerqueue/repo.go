@@ -0,0 +1,149 @@
+package ledgerqueue
+
+import (
+	"context"
+	"encoding/json"
+	"order-book/account"
+	repository "order-book/ledgerqueue/repository/gen"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type repo struct {
+	queries      *repository.Queries
+	queryTimeout time.Duration
+}
+
+func (repo *repo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *repo) AddTransaction(ctx context.Context, e Entry) error {
+	tx, err := json.Marshal(e.Transaction)
+	if err != nil {
+		return err
+	}
+	var fallback []byte
+	if e.Fallback != nil {
+		if fallback, err = json.Marshal(e.Fallback); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.InsertQueuedTransaction(ctx, repository.InsertQueuedTransactionParams{
+		IdempotencyKey: e.Transaction.IdempotencyKey,
+		Tx:             tx,
+		Fallback:       fallback,
+		NextAttemptAt:  pgtype.Timestamp{Time: e.NextAttemptAt.UTC(), Valid: true},
+	})
+}
+
+func (repo *repo) GetDueTransactions(ctx context.Context, at time.Time, limit int) ([]Entry, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	dbres, err := repo.queries.GetDueTransactions(ctx, repository.GetDueTransactionsParams{
+		NextAttemptAt: pgtype.Timestamp{Time: at.UTC(), Valid: true},
+		Limit:         int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return convertEntries(dbres)
+}
+
+func (repo *repo) GetFailedTransactions(ctx context.Context) ([]Entry, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	dbres, err := repo.queries.GetFailedTransactions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return convertEntries(dbres)
+}
+
+func (repo *repo) SetFellBack(ctx context.Context, id int64) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.SetFellBack(ctx, id)
+}
+
+func (repo *repo) FailTransaction(ctx context.Context, id int64, lastError string, at time.Time) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.FailTransaction(ctx, repository.FailTransactionParams{
+		ID:        id,
+		LastError: pgtype.Text{String: lastError, Valid: true},
+		FailedAt:  pgtype.Timestamp{Time: at.UTC(), Valid: true},
+	})
+}
+
+func (repo *repo) RetryFailedTransaction(ctx context.Context, id int64, nextAttemptAt time.Time) (bool, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	rows, err := repo.queries.RetryFailedTransaction(ctx, repository.RetryFailedTransactionParams{
+		ID:            id,
+		NextAttemptAt: pgtype.Timestamp{Time: nextAttemptAt.UTC(), Valid: true},
+	})
+	return rows > 0, err
+}
+
+func (repo *repo) RescheduleTransaction(ctx context.Context, id int64, lastError string, nextAttemptAt time.Time) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.RescheduleTransaction(ctx, repository.RescheduleTransactionParams{
+		ID:            id,
+		LastError:     pgtype.Text{String: lastError, Valid: true},
+		NextAttemptAt: pgtype.Timestamp{Time: nextAttemptAt.UTC(), Valid: true},
+	})
+}
+
+func (repo *repo) DeleteTransaction(ctx context.Context, id int64) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.DeleteQueuedTransaction(ctx, id)
+}
+
+func NewRepository(dbpool *pgxpool.Pool, queryTimeout time.Duration) Repo {
+	return &repo{
+		queries:      repository.New(dbpool),
+		queryTimeout: queryTimeout,
+	}
+}
+
+func convertEntries(dbres []repository.TblLedgerQueue) ([]Entry, error) {
+	entries := make([]Entry, len(dbres))
+	for idx, e := range dbres {
+		entries[idx] = Entry{
+			ID:            e.ID,
+			Attempts:      int(e.Attempts),
+			LastError:     e.LastError.String,
+			FellBack:      e.FellBack,
+			NextAttemptAt: e.NextAttemptAt.Time,
+			CreatedAt:     e.CreatedAt.Time,
+		}
+		if e.FailedAt.Valid {
+			entries[idx].FailedAt = &e.FailedAt.Time
+		}
+		if err := unmarshalEntry(&entries[idx], e.Tx, e.Fallback); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+func unmarshalEntry(e *Entry, tx []byte, fallback []byte) error {
+	if err := json.Unmarshal(tx, &e.Transaction); err != nil {
+		return err
+	}
+	if len(fallback) == 0 {
+		return nil
+	}
+	e.Fallback = new(account.Transaction)
+	return json.Unmarshal(fallback, e.Fallback)
+}
@@ -0,0 +1,32 @@
+-- name: InsertQueuedTransaction :exec
+INSERT INTO tbl_ledger_queue (idempotency_key, tx, fallback, next_attempt_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (idempotency_key) DO NOTHING;
+
+-- name: GetDueTransactions :many
+SELECT * FROM tbl_ledger_queue
+WHERE failed_at IS NULL AND next_attempt_at <= $1
+ORDER BY id LIMIT $2;
+
+-- name: GetFailedTransactions :many
+SELECT * FROM tbl_ledger_queue
+WHERE failed_at IS NOT NULL
+ORDER BY id;
+
+-- name: RescheduleTransaction :exec
+UPDATE tbl_ledger_queue SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+WHERE id = $1;
+
+-- name: SetFellBack :exec
+UPDATE tbl_ledger_queue SET fell_back = TRUE WHERE id = $1;
+
+-- name: FailTransaction :exec
+UPDATE tbl_ledger_queue SET attempts = attempts + 1, last_error = $2, failed_at = $3
+WHERE id = $1;
+
+-- name: RetryFailedTransaction :execrows
+UPDATE tbl_ledger_queue SET failed_at = NULL, next_attempt_at = $2
+WHERE id = $1 AND failed_at IS NOT NULL;
+
+-- name: DeleteQueuedTransaction :exec
+DELETE FROM tbl_ledger_queue WHERE id = $1;
@@ -0,0 +1,13 @@
+CREATE TABLE tbl_ledger_queue
+(
+    id BIGSERIAL PRIMARY KEY,
+    idempotency_key VARCHAR(255) NOT NULL UNIQUE,
+    tx JSONB NOT NULL,
+    fallback JSONB NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    next_attempt_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    fell_back BOOLEAN NOT NULL DEFAULT FALSE,
+    failed_at TIMESTAMP NULL
+);
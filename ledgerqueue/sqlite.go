@@ -0,0 +1,145 @@
+package ledgerqueue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type sqliteRepo struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func (repo *sqliteRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *sqliteRepo) AddTransaction(ctx context.Context, e Entry) error {
+	tx, err := json.Marshal(e.Transaction)
+	if err != nil {
+		return err
+	}
+	var fallback sql.NullString
+	if e.Fallback != nil {
+		b, err := json.Marshal(e.Fallback)
+		if err != nil {
+			return err
+		}
+		fallback = sql.NullString{String: string(b), Valid: true}
+	}
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err = repo.db.ExecContext(ctx,
+		`INSERT INTO tbl_ledger_queue (idempotency_key, tx, fallback, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?) ON CONFLICT (idempotency_key) DO NOTHING`,
+		e.Transaction.IdempotencyKey, string(tx), fallback, e.NextAttemptAt.UTC(), time.Now().UTC(),
+	)
+	return err
+}
+
+func (repo *sqliteRepo) GetDueTransactions(ctx context.Context, at time.Time, limit int) ([]Entry, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.query(ctx,
+		"SELECT "+sqliteEntryColumns+" FROM tbl_ledger_queue WHERE failed_at IS NULL AND next_attempt_at <= ? ORDER BY id LIMIT ?",
+		at.UTC(), limit,
+	)
+}
+
+func (repo *sqliteRepo) GetFailedTransactions(ctx context.Context) ([]Entry, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.query(ctx, "SELECT "+sqliteEntryColumns+" FROM tbl_ledger_queue WHERE failed_at IS NOT NULL ORDER BY id")
+}
+
+const sqliteEntryColumns = "id, tx, fallback, attempts, last_error, fell_back, next_attempt_at, created_at, failed_at"
+
+func (repo *sqliteRepo) query(ctx context.Context, query string, args ...any) ([]Entry, error) {
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []Entry{}
+	for rows.Next() {
+		var (
+			e                   Entry
+			tx                  string
+			fallback, lastError sql.NullString
+			failedAt            sql.NullTime
+		)
+		if err := rows.Scan(&e.ID, &tx, &fallback, &e.Attempts, &lastError, &e.FellBack, &e.NextAttemptAt, &e.CreatedAt, &failedAt); err != nil {
+			return nil, err
+		}
+		e.LastError = lastError.String
+		if failedAt.Valid {
+			e.FailedAt = &failedAt.Time
+		}
+		if err := unmarshalEntry(&e, []byte(tx), []byte(fallback.String)); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (repo *sqliteRepo) SetFellBack(ctx context.Context, id int64) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err := repo.db.ExecContext(ctx, "UPDATE tbl_ledger_queue SET fell_back = TRUE WHERE id = ?", id)
+	return err
+}
+
+func (repo *sqliteRepo) FailTransaction(ctx context.Context, id int64, lastError string, at time.Time) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err := repo.db.ExecContext(ctx,
+		"UPDATE tbl_ledger_queue SET attempts = attempts + 1, last_error = ?, failed_at = ? WHERE id = ?",
+		lastError, at.UTC(), id,
+	)
+	return err
+}
+
+func (repo *sqliteRepo) RetryFailedTransaction(ctx context.Context, id int64, nextAttemptAt time.Time) (bool, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	res, err := repo.db.ExecContext(ctx,
+		"UPDATE tbl_ledger_queue SET failed_at = NULL, next_attempt_at = ? WHERE id = ? AND failed_at IS NOT NULL",
+		nextAttemptAt.UTC(), id,
+	)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+func (repo *sqliteRepo) RescheduleTransaction(ctx context.Context, id int64, lastError string, nextAttemptAt time.Time) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err := repo.db.ExecContext(ctx,
+		"UPDATE tbl_ledger_queue SET attempts = attempts + 1, last_error = ?, next_attempt_at = ? WHERE id = ?",
+		lastError, nextAttemptAt.UTC(), id,
+	)
+	return err
+}
+
+func (repo *sqliteRepo) DeleteTransaction(ctx context.Context, id int64) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err := repo.db.ExecContext(ctx, "DELETE FROM tbl_ledger_queue WHERE id = ?", id)
+	return err
+}
+
+// NewSQLiteRepository is NewRepository on a SQLite database
+func NewSQLiteRepository(db *sql.DB, queryTimeout time.Duration) Repo {
+	return &sqliteRepo{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
@@ -0,0 +1,43 @@
+package liquidation
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindLiquidationRouter serves the liquidation steps of an account on GET
+// /accounts/:id/liquidations and what the insurance fund collected and
+// covered on GET /admin/insurance-fund
+func BindLiquidationRouter(r fiber.Router, e *Engine) {
+	r.Get("/accounts/:id/liquidations", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    e.Steps(accountId),
+		})
+	})
+
+	r.Get("/admin/insurance-fund", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    e.Fund(),
+		})
+	})
+}
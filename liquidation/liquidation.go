@@ -0,0 +1,474 @@
+// Package liquidation closes the positions of the margin accounts that fall
+// below maintenance margin. A liquidation works in steps: the open orders of
+// the account are cancelled, then part of its largest position is sent as an
+// IOC order at the best opposite price within a band around the mark, until
+// the account is back above maintenance or has nothing left to close. The
+// losses and fees each step realizes are settled from the collateral into
+// the insurance fund, which absorbs what a bankrupt account can't pay.
+package liquidation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-book/account"
+	"order-book/book"
+	"order-book/logger"
+	"order-book/margin"
+	"order-book/order"
+	"order-book/position"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrNoLiquidity = errors.New("No liquidity within the price band")
+
+// maxPerAccount bounds the liquidation steps kept per account
+const maxPerAccount = 100
+
+var liqLog = logger.Component("liquidation")
+
+// Options are how positions are liquidated. Each step closes StepRatio of
+// the largest position, the whole of it once the equity is gone, at the best
+// opposite price if it's no worse than PriceBand (a ratio) from its mark,
+// and the next one waits StepInterval for the marks to catch up. FeeRate of
+// the notional closed is charged to the insurance fund.
+type Options struct {
+	StepRatio    decimal.Decimal
+	PriceBand    decimal.Decimal
+	StepInterval time.Duration
+	FeeRate      decimal.Decimal
+}
+
+// Books are the books of the accounts
+type Books interface {
+	ForAccount(accountId int) book.Book
+	OnBook(fn func(tenantId string, b book.Book))
+}
+
+// Margins are the margin accounts and their status
+type Margins interface {
+	GetStatus(accountId int) (margin.Status, error)
+}
+
+// Step is a liquidation order and its settlement. The fills of the order
+// carry StrategyID on the private stream like any other. Equity and
+// MaintenanceMargin are those of the account when it was sent. RealizedPnL
+// and Fee are what the fills realized and cost, Collected what the
+// collateral paid the insurance fund for them (negative when it was paid)
+// and Deficit what the fund covered because the collateral fell short.
+type Step struct {
+	ID                int             `json:"id"`
+	AccountID         int             `json:"account_id"`
+	PairID            string          `json:"pair_id"`
+	Type              order.OrderType `json:"type"`
+	Step              int             `json:"step"`
+	OrderID           int             `json:"order_id,omitempty"`
+	StrategyID        string          `json:"strategy_id"`
+	Price             decimal.Decimal `json:"price"`
+	Amount            decimal.Decimal `json:"amount"`
+	Filled            decimal.Decimal `json:"filled"`
+	AvgPrice          decimal.Decimal `json:"avg_price"`
+	MarkPrice         decimal.Decimal `json:"mark_price"`
+	RealizedPnL       decimal.Decimal `json:"realized_pnl"`
+	Fee               decimal.Decimal `json:"fee"`
+	Collected         decimal.Decimal `json:"collected"`
+	Deficit           decimal.Decimal `json:"deficit"`
+	Asset             string          `json:"asset"`
+	Equity            decimal.Decimal `json:"equity"`
+	MaintenanceMargin decimal.Decimal `json:"maintenance_margin"`
+	Error             string          `json:"error,omitempty"`
+	At                time.Time       `json:"at"`
+}
+
+// Fund is what the insurance fund collected from and covered for the
+// liquidated accounts since the start, by asset
+type Fund struct {
+	Asset     string          `json:"asset"`
+	Collected decimal.Decimal `json:"collected"`
+	Covered   decimal.Decimal `json:"covered"`
+}
+
+type Engine struct {
+	opts      Options
+	books     Books
+	margins   Margins
+	positions *position.Tracker
+	balances  account.BalanceRepo
+	calls     chan int
+
+	mu        sync.Mutex
+	nextId    int
+	active    map[int]bool
+	steps     map[int][]Step
+	fund      map[string]*Fund
+	fills     map[string][]order.Trade
+	listeners []func(s Step)
+}
+
+func NewEngine(opts Options, books Books, margins Margins, positions *position.Tracker, balances account.BalanceRepo) *Engine {
+	e := &Engine{
+		opts:      opts,
+		books:     books,
+		margins:   margins,
+		positions: positions,
+		balances:  balances,
+		calls:     make(chan int, 1024),
+		active:    make(map[int]bool),
+		steps:     make(map[int][]Step),
+		fund:      make(map[string]*Fund),
+		fills:     make(map[string][]order.Trade),
+	}
+	books.OnBook(func(tenantId string, b book.Book) {
+		b.OnTrade(e.onTrade)
+	})
+	return e
+}
+
+func (e *Engine) onTrade(t order.Trade) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, strategyId := range []string{t.TakerStrategyID, t.MakerStrategyID} {
+		if _, ok := e.fills[strategyId]; ok {
+			e.fills[strategyId] = append(e.fills[strategyId], t)
+		}
+	}
+}
+
+// OnLiquidation registers a listener for every liquidation step
+func (e *Engine) OnLiquidation(fn func(s Step)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.listeners = append(e.listeners, fn)
+}
+
+// OnMarginCall starts liquidating an account, it is meant to be registered
+// with margin.Engine.OnMarginCall
+func (e *Engine) OnMarginCall(ev margin.MarginCallEvent) {
+	select {
+	case e.calls <- ev.AccountID:
+	default:
+		liqLog.Warn("liquidation queue is full, skipping margin call", map[string]any{
+			"account_id": ev.AccountID,
+		})
+	}
+}
+
+// Run liquidates the accounts in margin call until ctx is done, each on its
+// own goroutine
+func (e *Engine) Run(ctx context.Context) {
+	liqLog.Info("liquidations started", map[string]any{
+		"step_ratio": e.opts.StepRatio.String(),
+		"price_band": e.opts.PriceBand.String(),
+	})
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case accountId := <-e.calls:
+			e.mu.Lock()
+			if e.active[accountId] {
+				e.mu.Unlock()
+				continue
+			}
+			e.active[accountId] = true
+			e.mu.Unlock()
+			go func() {
+				defer func() {
+					e.mu.Lock()
+					delete(e.active, accountId)
+					e.mu.Unlock()
+				}()
+				e.liquidate(ctx, accountId)
+			}()
+		}
+	}
+}
+
+// liquidate steps until the account is out of margin call, has no position
+// left or margin is disabled for it
+func (e *Engine) liquidate(ctx context.Context, accountId int) {
+	for step := 1; ; step++ {
+		status, err := e.margins.GetStatus(accountId)
+		if errors.Is(err, margin.ErrMarginNotEnabled) {
+			return
+		}
+		if err != nil {
+			liqLog.Error("failed to get the margin status", map[string]any{
+				"account_id": accountId,
+				"error":      err,
+			})
+		} else if !status.MarginCall {
+			liqLog.Info("account liquidated back above maintenance", map[string]any{
+				"account_id": accountId,
+				"steps":      step - 1,
+			})
+			return
+		} else if !e.step(ctx, status, step) {
+			liqLog.Warn("account has nothing left to liquidate", map[string]any{
+				"account_id": accountId,
+				"equity":     status.Equity.String(),
+			})
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.opts.StepInterval):
+		}
+	}
+}
+
+// step sends one liquidation order for the largest position of the account
+// and settles its fills, it is false when there's no position to close
+func (e *Engine) step(ctx context.Context, status margin.Status, n int) bool {
+	accountId := status.AccountID
+	b := e.books.ForAccount(accountId)
+	for _, o := range b.GetAccountOrders(accountId) {
+		if err := b.CancellOrder(ctx, o.ID); err != nil && !errors.Is(err, book.ErrOrderNotFound) {
+			liqLog.Error("failed to cancel an order of a liquidated account", map[string]any{
+				"account_id": accountId,
+				"order_id":   o.ID,
+				"error":      err,
+			})
+		}
+	}
+
+	var target position.Position
+	for _, p := range e.positions.GetPositions(accountId) {
+		if _, quote, ok := order.SplitPairID(p.PairID); !ok || quote != status.CollateralAsset {
+			continue
+		}
+		if p.Quantity.Abs().Mul(p.MarkPrice).GreaterThan(target.Quantity.Abs().Mul(target.MarkPrice)) {
+			target = p
+		}
+	}
+	if target.Quantity.IsZero() {
+		return false
+	}
+
+	s := Step{
+		AccountID:         accountId,
+		PairID:            target.PairID,
+		Type:              order.ASK,
+		Step:              n,
+		MarkPrice:         target.MarkPrice,
+		Asset:             status.CollateralAsset,
+		Equity:            status.Equity,
+		MaintenanceMargin: status.MaintenanceMargin,
+	}
+	opposite, band := order.BID, decimal.NewFromInt(1).Sub(e.opts.PriceBand)
+	if target.Quantity.IsNegative() {
+		s.Type, opposite, band = order.BID, order.ASK, decimal.NewFromInt(1).Add(e.opts.PriceBand)
+	}
+	size := target.Quantity.Abs()
+	partial := size
+	if status.Equity.IsPositive() {
+		partial = size.Mul(e.opts.StepRatio)
+	}
+	s.Amount = roundAmount(target.PairID, partial)
+	if !s.Amount.IsPositive() {
+		s.Amount = roundAmount(target.PairID, size)
+	}
+	if !s.Amount.IsPositive() {
+		// A position smaller than a lot can't be closed
+		return false
+	}
+
+	// Orders match at the price they rest at, so the order takes the best
+	// opposite level as long as it's within the band
+	best, ok := b.Best(target.PairID, opposite)
+	limit := s.MarkPrice.Mul(band)
+	if !ok || (s.Type == order.ASK && best.LessThan(limit)) || (s.Type == order.BID && best.GreaterThan(limit)) {
+		s.Error = ErrNoLiquidity.Error()
+		e.record(s)
+		return true
+	}
+	s.Price = best
+
+	e.send(ctx, b, &s)
+	if s.Filled.IsPositive() {
+		e.settle(&s, target)
+	}
+	e.record(s)
+	return true
+}
+
+// send places the liquidation order and cancels what it didn't fill right
+// away, as an IOC
+func (e *Engine) send(ctx context.Context, b book.Book, s *Step) {
+	e.mu.Lock()
+	e.nextId++
+	s.ID = e.nextId
+	strategyId := fmt.Sprintf("liquidation-%d-%d", s.AccountID, s.ID)
+	s.StrategyID = strategyId
+	e.fills[strategyId] = []order.Trade{}
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		delete(e.fills, strategyId)
+		e.mu.Unlock()
+	}()
+
+	timings, err := b.AddOrderWithTimings(ctx, order.Order{
+		Price:      s.Price,
+		Amount:     s.Amount,
+		PairID:     s.PairID,
+		AccountID:  s.AccountID,
+		Type:       s.Type,
+		CreatedAt:  time.Now(),
+		StrategyID: strategyId,
+	})
+	if err != nil {
+		s.Error = err.Error()
+		return
+	}
+	s.OrderID = timings.OrderID
+	if err := b.CancellOrder(ctx, timings.OrderID); err != nil && !errors.Is(err, book.ErrOrderNotFound) {
+		s.Error = err.Error()
+	}
+
+	e.mu.Lock()
+	trades := e.fills[strategyId]
+	e.mu.Unlock()
+	notional := decimal.Zero
+	for _, t := range trades {
+		if t.TakerOrderID != timings.OrderID && t.MakerOrderID != timings.OrderID {
+			continue
+		}
+		s.Filled = s.Filled.Add(t.Amount)
+		notional = notional.Add(t.Amount.Mul(t.Price))
+	}
+	if s.Filled.IsPositive() {
+		s.AvgPrice = notional.Div(s.Filled)
+	}
+}
+
+// settle realizes the PnL of the filled part of the position against its
+// entry price. The loss and the fee are taken from the collateral into the
+// insurance fund, as much as the collateral has, and a gain is paid from it.
+func (e *Engine) settle(s *Step, p position.Position) {
+	pnl := s.Filled.Mul(s.AvgPrice.Sub(p.AverageEntryPrice))
+	if p.Quantity.IsNegative() {
+		pnl = pnl.Neg()
+	}
+	s.RealizedPnL = pnl.Truncate(account.LedgerScale)
+	s.Fee = s.Filled.Mul(s.AvgPrice).Mul(e.opts.FeeRate).Truncate(account.LedgerScale)
+	charge := s.Fee.Sub(s.RealizedPnL)
+
+	collected := charge
+	if charge.IsPositive() {
+		available := decimal.Zero
+		balances, err := e.balances.GetBalances(s.AccountID)
+		if err != nil {
+			s.Error = err.Error()
+			return
+		}
+		for _, b := range balances {
+			if strings.ToLower(b.Asset) == s.Asset {
+				available = available.Add(b.Available)
+			}
+		}
+		collected = decimal.Max(decimal.Zero, decimal.Min(charge, available))
+		s.Deficit = charge.Sub(collected)
+	}
+	if !collected.IsZero() {
+		_, _, err := e.balances.Post(account.Transaction{
+			Type:           account.LIQUIDATION,
+			IdempotencyKey: fmt.Sprintf("liquidation:%d", s.OrderID),
+			Source:         account.ENGINE,
+			Reference:      fmt.Sprintf("%s liquidation step %d", s.PairID, s.Step),
+			Postings: []account.Posting{
+				{AccountID: s.AccountID, Asset: s.Asset, Amount: collected.Neg()},
+				{SystemAccount: account.INSURANCE_FUND, Asset: s.Asset, Amount: collected},
+			},
+		})
+		if err != nil {
+			liqLog.Error("failed to settle a liquidation", map[string]any{
+				"account_id": s.AccountID,
+				"order_id":   s.OrderID,
+				"amount":     collected.String(),
+				"error":      err,
+			})
+			s.Error = err.Error()
+			return
+		}
+	}
+	s.Collected = collected
+
+	e.mu.Lock()
+	f := e.fund[s.Asset]
+	if f == nil {
+		f = &Fund{Asset: s.Asset}
+		e.fund[s.Asset] = f
+	}
+	f.Collected = f.Collected.Add(collected)
+	f.Covered = f.Covered.Add(s.Deficit)
+	e.mu.Unlock()
+}
+
+func (e *Engine) record(s Step) {
+	s.At = time.Now()
+	e.mu.Lock()
+	steps := append(e.steps[s.AccountID], s)
+	if len(steps) > maxPerAccount {
+		steps = steps[len(steps)-maxPerAccount:]
+	}
+	e.steps[s.AccountID] = steps
+	listeners := e.listeners
+	e.mu.Unlock()
+
+	liqLog.Warn("liquidation step", map[string]any{
+		"account_id": s.AccountID,
+		"pair_id":    s.PairID,
+		"step":       s.Step,
+		"amount":     s.Amount.String(),
+		"filled":     s.Filled.String(),
+		"deficit":    s.Deficit.String(),
+		"error":      s.Error,
+	})
+	for _, fn := range listeners {
+		fn(s)
+	}
+}
+
+// Steps are the last liquidation steps of an account, the latest first
+func (e *Engine) Steps(accountId int) []Step {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	steps := slices.Clone(e.steps[accountId])
+	slices.Reverse(steps)
+	if steps == nil {
+		steps = []Step{}
+	}
+	return steps
+}
+
+// Fund is what the insurance fund collected and covered by asset
+func (e *Engine) Fund() []Fund {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	list := make([]Fund, 0, len(e.fund))
+	for _, f := range e.fund {
+		list = append(list, *f)
+	}
+	slices.SortFunc(list, func(a, b Fund) int { return strings.Compare(a.Asset, b.Asset) })
+	return list
+}
+
+// roundAmount truncates an amount to the scale and lot size of its pair
+func roundAmount(pairId string, amount decimal.Decimal) decimal.Decimal {
+	pair, ok := order.GetPair(pairId)
+	if !ok {
+		return amount
+	}
+	amount = amount.Truncate(pair.AmountScale)
+	if pair.LotSize.IsPositive() {
+		amount = amount.Div(pair.LotSize).Floor().Mul(pair.LotSize)
+	}
+	return amount
+}
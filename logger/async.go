@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"context"
+	"time"
+)
+
+type queuedEntry struct {
+	at     time.Time
+	level  Level
+	msg    string
+	fields map[string]any
+	// flushed is closed once the entries queued before it are written, it
+	// marks a Flush call rather than an entry when set
+	flushed chan struct{}
+}
+
+// asyncQueue hands the entries to a goroutine writing them, a full queue
+// drops the entry rather than blocking the caller
+type asyncQueue struct {
+	entries chan queuedEntry
+}
+
+// AsyncStats tells how many entries wait in the buffer and how many were
+// dropped because it was full. HookDropped counts the entries hooks missed
+// because they were lagging behind.
+type AsyncStats struct {
+	Enabled     bool
+	Buffered    int
+	Capacity    int
+	Dropped     uint64
+	HookDropped uint64
+}
+
+// EnableAsync moves formatting and writing the entries of the default logger
+// to a goroutine behind a buffer of size entries, so a slow writer never
+// blocks the caller. Entries logged while the buffer is full are dropped and
+// counted. Fields maps must not be modified once passed to the logger. It's
+// meant to be called once at startup.
+func EnableAsync(size int) {
+	q := &asyncQueue{entries: make(chan queuedEntry, size)}
+	go q.run(defaultLogger)
+	defaultLogger.async.Store(q)
+}
+
+func (q *asyncQueue) run(l *Logger) {
+	for e := range q.entries {
+		if e.flushed != nil {
+			close(e.flushed)
+			continue
+		}
+		l.write(e.at, e.level, e.msg, e.fields)
+	}
+}
+
+// Flush waits for the entries logged so far to be written, for ctx at most.
+// It returns right away unless EnableAsync was called.
+func Flush(ctx context.Context) error {
+	q := defaultLogger.async.Load()
+	if q == nil {
+		return nil
+	}
+	flushed := make(chan struct{})
+	select {
+	case q.entries <- queuedEntry{flushed: flushed}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the state of the buffer of the default logger
+func Stats() AsyncStats {
+	stats := AsyncStats{
+		Dropped:     defaultLogger.dropped.Load(),
+		HookDropped: defaultLogger.hookDropped.Load(),
+	}
+	if q := defaultLogger.async.Load(); q != nil {
+		stats.Enabled = true
+		stats.Buffered = len(q.entries)
+		stats.Capacity = cap(q.entries)
+	}
+	return stats
+}
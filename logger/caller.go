@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// packagePrefix is the prefix of the functions of this package, their
+// frames are skipped to find the caller of the logger
+var packagePrefix = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	slash := strings.LastIndex(name, "/")
+	return name[:slash+strings.Index(name[slash:], ".")+1]
+}()
+
+// SetCaller adds the file:line logging an entry as the field "caller"
+func SetCaller(enabled bool) {
+	defaultLogger.caller.Store(enabled)
+}
+
+// SetErrorStacks adds the stack of the goroutine logging an Error entry as
+// the field "stack"
+func SetErrorStacks(enabled bool) {
+	defaultLogger.errorStacks.Store(enabled)
+}
+
+// LogPanic logs recovered, the value of a recovered panic, as an Error entry
+// along with the stack it was raised from. It's meant to be called from the
+// deferred function that recovered it.
+func LogPanic(msg string, recovered any, fields map[string]any) {
+	defaultLogger.log(ErrorLevel, msg, mergeFields(fields, map[string]any{
+		"panic": fmt.Sprint(recovered),
+		"stack": string(debug.Stack()),
+	}))
+}
+
+// addCallerInfo adds the caller and the stack to fields as enabled, the
+// fields of the caller are copied rather than modified
+func (l *Logger) addCallerInfo(level Level, fields map[string]any) map[string]any {
+	withCaller := l.caller.Load()
+	withStack := level == ErrorLevel && l.errorStacks.Load() && fields["stack"] == nil
+	if !withCaller && !withStack {
+		return fields
+	}
+
+	extra := make(map[string]any, 2)
+	var stack strings.Builder
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for found, more := false, true; more; {
+		var frame runtime.Frame
+		frame, more = frames.Next()
+		if !found {
+			// The frames of the logger come first
+			if strings.HasPrefix(frame.Function, packagePrefix) {
+				continue
+			}
+			found = true
+			if withCaller {
+				extra["caller"] = fmt.Sprintf("%s/%s:%d", filepath.Base(filepath.Dir(frame.File)), filepath.Base(frame.File), frame.Line)
+			}
+			if !withStack {
+				break
+			}
+		}
+		fmt.Fprintf(&stack, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+	if stack.Len() > 0 {
+		extra["stack"] = stack.String()
+	}
+	return mergeFields(fields, extra)
+}
@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"strings"
+)
+
+// Component returns the logger of a component, like engine, repo or ws. Its
+// entries carry the field "component" and follow the level set for it with
+// SetComponentLevel. Names nest with a slash: engine/btcusdt follows the level
+// of engine unless it has one of its own, and the global level last.
+func Component(name string) *Child {
+	if c, ok := defaultLogger.components.Load(name); ok {
+		return c.(*Child)
+	}
+	c := &Child{
+		logger:    defaultLogger,
+		fields:    map[string]any{"component": name},
+		component: name,
+	}
+	actual, _ := defaultLogger.components.LoadOrStore(name, c)
+	return actual.(*Child)
+}
+
+// SetComponentLevel sets the minimum level of a component and of the ones
+// nested under it
+func SetComponentLevel(name string, lvl Level) {
+	defaultLogger.componentLevels.Store(name, lvl)
+}
+
+// ResetComponentLevel makes a component follow its parent again
+func ResetComponentLevel(name string) {
+	defaultLogger.componentLevels.Delete(name)
+}
+
+// LevelSettings is the global level along with the components that have one
+// of their own
+type LevelSettings struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+// Levels returns the levels in effect
+func Levels() LevelSettings {
+	settings := LevelSettings{
+		Level:      levelNames[defaultLogger.level()],
+		Components: make(map[string]string),
+	}
+	defaultLogger.componentLevels.Range(func(name, lvl any) bool {
+		settings.Components[name.(string)] = levelNames[lvl.(Level)]
+		return true
+	})
+	return settings
+}
+
+// levelFor returns the minimum level of a component, the global one for ""
+func (l *Logger) levelFor(component string) Level {
+	for component != "" {
+		if lvl, ok := l.componentLevels.Load(component); ok {
+			return lvl.(Level)
+		}
+		idx := strings.LastIndex(component, "/")
+		if idx < 0 {
+			break
+		}
+		component = component[:idx]
+	}
+	return l.level()
+}
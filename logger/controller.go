@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var ErrInvalidLevel = errors.New("ErrInvalidLevel")
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+type levelRequest struct {
+	// Component is engine, engine/<pair>, repo or ws, the global level when empty
+	Component string `json:"component"`
+	// Level is debug, info, warn or error. It's left empty to make a
+	// component follow its parent again.
+	Level string `json:"level"`
+}
+
+// BindLogRouter serves GET /admin/log-level, the levels in effect, and
+// PUT /admin/log-level changing one of them while the engine runs
+func BindLogRouter(r fiber.Router) {
+	r.Get("/admin/log-level", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    Levels(),
+		})
+	})
+
+	r.Put("/admin/log-level", func(c *fiber.Ctx) error {
+		var req levelRequest
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		if req.Level == "" && req.Component != "" {
+			ResetComponentLevel(req.Component)
+			Info("log level reset", map[string]any{
+				"component": req.Component,
+			})
+			c.Status(http.StatusOK)
+			return c.JSON(&Response{
+				Message: "Log level reset",
+				Data:    Levels(),
+			})
+		}
+		lvl, err := ParseLevel(req.Level)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrInvalidLevel,
+				Message: "The level should be debug, info, warn or error",
+			})
+		}
+		if req.Component == "" {
+			SetLevel(lvl)
+		} else {
+			SetComponentLevel(req.Component, lvl)
+		}
+		Info("log level changed", map[string]any{
+			"component": req.Component,
+			"level":     levelNames[lvl],
+		})
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Log level changed",
+			Data:    Levels(),
+		})
+	})
+}
@@ -0,0 +1,143 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is how the entries are encoded for a writer
+type Format int
+
+const (
+	// FormatJSON writes an object a line, the default
+	FormatJSON Format = iota
+	// FormatLogfmt writes key=value pairs, as read by Loki and Heroku
+	FormatLogfmt
+	// FormatConsole writes aligned lines for a terminal, optionally colorized
+	FormatConsole
+)
+
+var formatNames = map[Format]string{
+	FormatJSON:    "json",
+	FormatLogfmt:  "logfmt",
+	FormatConsole: "console",
+}
+
+// ParseFormat returns the format named s: json, logfmt or console
+func ParseFormat(s string) (Format, error) {
+	for f, name := range formatNames {
+		if strings.EqualFold(name, s) {
+			return f, nil
+		}
+	}
+	return FormatJSON, fmt.Errorf("unknown log format %q", s)
+}
+
+// output is a writer along with the format of its entries, color only
+// applies to FormatConsole
+type output struct {
+	w      io.Writer
+	format Format
+	color  bool
+}
+
+// AddWriter also writes the entries to w in format
+func AddWriter(w io.Writer, format Format) {
+	defaultLogger.addOutput(output{w: w, format: format})
+}
+
+// SetStdoutFormat sets how the entries written to stdout are encoded, color
+// highlights the level of FormatConsole entries
+func SetStdoutFormat(format Format, color bool) {
+	defaultLogger.mu.Lock()
+	defer defaultLogger.mu.Unlock()
+	outputs := slices.Clone(defaultLogger.outputs)
+	for idx := range outputs {
+		if outputs[idx].w == stdout {
+			outputs[idx].format = format
+			outputs[idx].color = color
+		}
+	}
+	defaultLogger.outputs = outputs
+}
+
+func (l *Logger) addOutput(o output) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.outputs = append(slices.Clip(l.outputs), o)
+}
+
+var levelColors = map[Level]string{
+	DebugLevel: "\x1b[90m",
+	InfoLevel:  "\x1b[36m",
+	WarnLevel:  "\x1b[33m",
+	ErrorLevel: "\x1b[31m",
+}
+
+const colorReset = "\x1b[0m"
+
+// encode returns the line of an entry in format, with its trailing newline
+func encode(format Format, color bool, at time.Time, level Level, msg string, fields map[string]any) []byte {
+	switch format {
+	case FormatLogfmt:
+		var b strings.Builder
+		b.WriteString("time=" + at.Format(time.RFC3339))
+		b.WriteString(" level=" + strings.ToLower(levelNames[level]))
+		b.WriteString(" msg=" + logfmtValue(msg))
+		for _, k := range sortedKeys(fields) {
+			b.WriteString(" " + k + "=" + logfmtValue(fieldString(fields[k])))
+		}
+		b.WriteByte('\n')
+		return []byte(b.String())
+	case FormatConsole:
+		var b strings.Builder
+		b.WriteString(at.Format("2006-01-02 15:04:05.000") + " ")
+		name := fmt.Sprintf("%-5s", levelNames[level])
+		if color {
+			name = levelColors[level] + name + colorReset
+		}
+		b.WriteString(name + " " + msg)
+		for _, k := range sortedKeys(fields) {
+			b.WriteString("  " + k + "=" + fieldString(fields[k]))
+		}
+		b.WriteByte('\n')
+		return []byte(b.String())
+	default:
+		data, _ := json.Marshal(entry{
+			Timestamp: at.Format(time.RFC3339),
+			Level:     levelNames[level],
+			Message:   msg,
+			Fields:    fields,
+		})
+		return append(data, '\n')
+	}
+}
+
+func sortedKeys(fields map[string]any) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func fieldString(v any) string {
+	if err, ok := v.(error); ok {
+		return err.Error()
+	}
+	return fmt.Sprint(v)
+}
+
+// logfmtValue quotes s when it's empty or holds a space, a quote or an equal sign
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
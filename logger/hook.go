@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"fmt"
+	"time"
+)
+
+// Entry is a log entry as handed to hooks
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	Fields  map[string]any
+}
+
+// hookBufferSize is the number of entries a hook can lag behind before the
+// next ones are dropped
+const hookBufferSize = 256
+
+type hook struct {
+	min     Level
+	fn      func(Entry)
+	entries chan Entry
+}
+
+// AddHook forwards the entries at min or above to fn, to send them to an
+// error tracker or an alerting pipeline. fn runs on a goroutine of its own,
+// entries are dropped rather than waiting for it and a panic in fn is logged
+// without reaching the caller. Each call gets its own copy of the fields.
+func AddHook(min Level, fn func(Entry)) {
+	h := &hook{min: min, fn: fn, entries: make(chan Entry, hookBufferSize)}
+	go h.run(defaultLogger)
+
+	defaultLogger.mu.Lock()
+	defer defaultLogger.mu.Unlock()
+	var hooks []*hook
+	if current := defaultLogger.hooks.Load(); current != nil {
+		hooks = append(hooks, *current...)
+	}
+	hooks = append(hooks, h)
+	defaultLogger.hooks.Store(&hooks)
+}
+
+func (h *hook) run(l *Logger) {
+	for e := range h.entries {
+		h.call(l, e)
+	}
+}
+
+func (h *hook) call(l *Logger, e Entry) {
+	defer func() {
+		if r := recover(); r != nil {
+			// Written to the outputs only so a failing hook doesn't feed itself
+			l.write(time.Now(), ErrorLevel, "log hook panicked", map[string]any{
+				"panic":   fmt.Sprint(r),
+				"message": e.Message,
+			})
+		}
+	}()
+	h.fn(e)
+}
+
+// runHooks hands an entry to the hooks it's meant for
+func (l *Logger) runHooks(at time.Time, level Level, msg string, fields map[string]any) {
+	hooks := l.hooks.Load()
+	if hooks == nil {
+		return
+	}
+	for _, h := range *hooks {
+		if level < h.min {
+			continue
+		}
+		select {
+		case h.entries <- Entry{Time: at, Level: level, Message: msg, Fields: mergeFields(nil, fields)}:
+		default:
+			l.hookDropped.Add(1)
+		}
+	}
+}
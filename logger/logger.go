@@ -1,11 +1,13 @@
 package logger
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -34,55 +36,125 @@ type entry struct {
 
 type Logger struct {
 	mu       sync.Mutex
-	writers  []io.Writer
-	minLevel Level
+	outputs  []output
+	handler  slog.Handler
+	minLevel atomic.Int32
+	// samplers holds a *sampler by message
+	samplers sync.Map
+	async    atomic.Pointer[asyncQueue]
+	dropped  atomic.Uint64
+	// caller and errorStacks add where an entry was logged from
+	caller      atomic.Bool
+	errorStacks atomic.Bool
+	// components holds the *Child of each component, componentLevels the
+	// Level of the ones that don't follow the global level
+	components      sync.Map
+	componentLevels sync.Map
+	// hooks is replaced as a whole by AddHook so entries read it without a lock
+	hooks       atomic.Pointer[[]*hook]
+	hookDropped atomic.Uint64
 }
 
 var defaultLogger *Logger
 
+// stdout tells the output SetStdoutFormat changes apart from the others
+var stdout io.Writer = os.Stdout
+
 func init() {
 	defaultLogger = &Logger{
-		writers:  []io.Writer{os.Stdout},
-		minLevel: DebugLevel,
+		outputs: []output{{w: stdout, format: FormatJSON}},
 	}
+	defaultLogger.minLevel.Store(int32(DebugLevel))
 }
 
+// AddFile appends the entries to the file at path, it's never rotated
 func AddFile(path string) error {
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open log file: %w", err)
+	return AddRotatingFile(path, FormatJSON, RotateOptions{})
+}
+
+// ParseLevel returns the level named by s, as printed in log entries
+func ParseLevel(s string) (Level, error) {
+	for lvl, name := range levelNames {
+		if strings.EqualFold(name, s) {
+			return lvl, nil
+		}
 	}
-	defaultLogger.mu.Lock()
-	defer defaultLogger.mu.Unlock()
-	defaultLogger.writers = append(defaultLogger.writers, f)
-	return nil
+	return DebugLevel, fmt.Errorf("unknown log level %q", s)
 }
 
 // SetLevel sets the minimum log level
 func SetLevel(lvl Level) {
-	defaultLogger.mu.Lock()
-	defer defaultLogger.mu.Unlock()
-	defaultLogger.minLevel = lvl
+	defaultLogger.minLevel.Store(int32(lvl))
+}
+
+func (l *Logger) level() Level {
+	return Level(l.minLevel.Load())
 }
 
 func (l *Logger) log(level Level, msg string, fields map[string]any) {
-	if level < l.minLevel {
+	if level < l.level() {
+		return
+	}
+	l.emit(level, msg, fields)
+}
+
+// emit writes an entry that passed the level of its logger
+func (l *Logger) emit(level Level, msg string, fields map[string]any) {
+	if s, ok := l.samplers.Load(msg); ok {
+		keep, dropped := s.(*sampler).allow(fields)
+		if !keep {
+			return
+		}
+		if dropped > 0 {
+			fields = mergeFields(fields, map[string]any{"suppressed": dropped})
+		}
+	}
+
+	// The stack is read here, the async writer runs on another goroutine
+	fields = l.addCallerInfo(level, fields)
+
+	now := time.Now()
+	l.runHooks(now, level, msg, fields)
+	if q := l.async.Load(); q != nil {
+		select {
+		case q.entries <- queuedEntry{at: now, level: level, msg: msg, fields: fields}:
+		default:
+			l.dropped.Add(1)
+		}
 		return
 	}
+	l.write(now, level, msg, fields)
+}
 
-	e := entry{
-		Timestamp: time.Now().Format(time.RFC3339),
-		Level:     levelNames[level],
-		Message:   msg,
-		Fields:    fields,
+// write hands an entry to the slog handler, or encodes it once for each
+// format of the outputs and writes it to them
+func (l *Logger) write(at time.Time, level Level, msg string, fields map[string]any) {
+	l.mu.Lock()
+	handler := l.handler
+	outputs := l.outputs
+	l.mu.Unlock()
+	if handler != nil {
+		handleSlog(handler, at, level, msg, fields)
+		return
 	}
 
-	data, _ := json.Marshal(e)
+	lines := make([][]byte, len(outputs))
+	for idx, o := range outputs {
+		for prev := range idx {
+			if outputs[prev].format == o.format && outputs[prev].color == o.color {
+				lines[idx] = lines[prev]
+				break
+			}
+		}
+		if lines[idx] == nil {
+			lines[idx] = encode(o.format, o.color, at, level, msg, fields)
+		}
+	}
 
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	for _, w := range l.writers {
-		fmt.Fprintln(w, string(data))
+	for idx, o := range outputs {
+		o.w.Write(lines[idx])
 	}
 }
 
@@ -106,6 +178,64 @@ func Error(msg string, fields ...map[string]any) {
 	defaultLogger.log(ErrorLevel, msg, f)
 }
 
+// Child is a logger binding fields to every entry it writes, fields passed
+// to a call take precedence over the bound ones
+type Child struct {
+	logger *Logger
+	fields map[string]any
+	// component picks the level of the child, the global one when empty
+	component string
+}
+
+// With returns a child of the default logger carrying fields
+func With(fields map[string]any) *Child {
+	return defaultLogger.With(fields)
+}
+
+func (l *Logger) With(fields map[string]any) *Child {
+	return &Child{logger: l, fields: mergeFields(nil, fields)}
+}
+
+// With returns a child carrying the fields of c along with fields
+func (c *Child) With(fields map[string]any) *Child {
+	return &Child{logger: c.logger, fields: mergeFields(c.fields, fields), component: c.component}
+}
+
+func (c *Child) log(level Level, msg string, fields []map[string]any) {
+	if level < c.logger.levelFor(c.component) {
+		return
+	}
+	c.logger.emit(level, msg, mergeFields(c.fields, getFields(fields)))
+}
+
+func (c *Child) Debug(msg string, fields ...map[string]any) {
+	c.log(DebugLevel, msg, fields)
+}
+
+func (c *Child) Info(msg string, fields ...map[string]any) {
+	c.log(InfoLevel, msg, fields)
+}
+
+func (c *Child) Warn(msg string, fields ...map[string]any) {
+	c.log(WarnLevel, msg, fields)
+}
+
+func (c *Child) Error(msg string, fields ...map[string]any) {
+	c.log(ErrorLevel, msg, fields)
+}
+
+// mergeFields copies bound and fields into a new map so children never share one
+func mergeFields(bound map[string]any, fields map[string]any) map[string]any {
+	merged := make(map[string]any, len(bound)+len(fields))
+	for k, v := range bound {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return merged
+}
+
 func getFields(fields []map[string]any) map[string]any {
 	if len(fields) > 0 {
 		return fields[0]
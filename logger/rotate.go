@@ -0,0 +1,209 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotateOptions bounds the disk a log file takes. The file is rotated once it
+// would grow past MaxSize bytes or was opened Interval ago, 0 disables either.
+// Rotated files are gzipped when Compress is set, the oldest are removed
+// beyond MaxBackups files or MaxAge, 0 keeps them.
+type RotateOptions struct {
+	MaxSize    int64
+	Interval   time.Duration
+	MaxBackups int
+	MaxAge     time.Duration
+	Compress   bool
+}
+
+// rotatingFile is a log file renamed to a timestamped backup next to it when
+// it's rotated, like app-2006-01-02T15-04-05.000.log
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	opts     RotateOptions
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	// cleanup serializes compressing and removing backups off the write path
+	cleanup sync.Mutex
+}
+
+// AddRotatingFile appends the entries to the file at path in format,
+// rotating it as opts says
+func AddRotatingFile(path string, format Format, opts RotateOptions) error {
+	f := &rotatingFile{path: path, opts: opts}
+	if err := f.open(); err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defaultLogger.addOutput(output{w: f, format: format})
+	return nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = time.Now()
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tooLarge := f.opts.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.opts.MaxSize
+	tooOld := f.opts.Interval > 0 && time.Since(f.openedAt) >= f.opts.Interval
+	if tooLarge || tooOld {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	// The file is reopened even if it couldn't be renamed, to keep logging
+	renameErr := os.Rename(f.path, f.backupName(time.Now()))
+	if err := f.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	go f.cleanupBackups()
+	return nil
+}
+
+func (f *rotatingFile) backupName(at time.Time) string {
+	ext := filepath.Ext(f.path)
+	return strings.TrimSuffix(f.path, ext) + "-" + at.UTC().Format(backupTimeFormat) + ext
+}
+
+// backups lists the rotated files, oldest first. The timestamp in their name
+// sorts them.
+func (f *rotatingFile) backups() ([]string, error) {
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(filepath.Base(f.path), ext) + "-"
+	entries, err := os.ReadDir(filepath.Dir(f.path))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		stamp, ok := strings.CutPrefix(name, prefix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".gz"), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+		names = append(names, filepath.Join(filepath.Dir(f.path), name))
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func (f *rotatingFile) cleanupBackups() {
+	f.cleanup.Lock()
+	defer f.cleanup.Unlock()
+
+	backups, err := f.backups()
+	if err != nil {
+		Error("failed to list rotated log files", map[string]any{
+			"path":  f.path,
+			"error": err,
+		})
+		return
+	}
+	if f.opts.Compress {
+		for idx, name := range backups {
+			if strings.HasSuffix(name, ".gz") {
+				continue
+			}
+			if err := compressFile(name); err != nil {
+				Error("failed to compress rotated log file", map[string]any{
+					"path":  name,
+					"error": err,
+				})
+				continue
+			}
+			backups[idx] = name + ".gz"
+		}
+	}
+
+	var remove []string
+	if f.opts.MaxBackups > 0 && len(backups) > f.opts.MaxBackups {
+		remove = backups[:len(backups)-f.opts.MaxBackups]
+		backups = backups[len(backups)-f.opts.MaxBackups:]
+	}
+	if f.opts.MaxAge > 0 {
+		for _, name := range backups {
+			if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > f.opts.MaxAge {
+				remove = append(remove, name)
+			}
+		}
+	}
+	for _, name := range remove {
+		if err := os.Remove(name); err != nil {
+			Error("failed to remove rotated log file", map[string]any{
+				"path":  name,
+				"error": err,
+			})
+		}
+	}
+}
+
+// compressFile gzips name into name.gz and removes name once it's written
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	return os.Remove(name)
+}
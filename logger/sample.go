@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SampleRule thins out the entries of a message. Every keeps one entry in
+// Every, PerSecond keeps at most PerSecond entries a second for each value of
+// the field KeyField, or for the message as a whole without one. 0 disables
+// either. The next entry kept reports how many were dropped as "suppressed".
+type SampleRule struct {
+	Every     int
+	PerSecond int
+	KeyField  string
+}
+
+type sampler struct {
+	rule    SampleRule
+	mu      sync.Mutex
+	seen    uint64
+	dropped int
+	windows map[string]*rateWindow
+}
+
+type rateWindow struct {
+	start time.Time
+	kept  int
+}
+
+// SetSampling applies rule to the entries of the default logger with the
+// message msg, a zero rule removes it
+func SetSampling(msg string, rule SampleRule) {
+	if rule == (SampleRule{}) {
+		defaultLogger.samplers.Delete(msg)
+		return
+	}
+	defaultLogger.samplers.Store(msg, &sampler{rule: rule, windows: make(map[string]*rateWindow)})
+}
+
+// allow reports whether an entry is kept and how many entries of the message
+// were dropped since the last one kept
+func (s *sampler) allow(fields map[string]any) (bool, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seen++
+	if s.rule.Every > 1 && (s.seen-1)%uint64(s.rule.Every) != 0 {
+		s.dropped++
+		return false, 0
+	}
+	if s.rule.PerSecond > 0 {
+		key := ""
+		if s.rule.KeyField != "" {
+			key = fmt.Sprint(fields[s.rule.KeyField])
+		}
+		now := time.Now()
+		w, ok := s.windows[key]
+		if !ok {
+			// Keys seen once would pile up otherwise
+			if len(s.windows) >= 10000 {
+				clear(s.windows)
+			}
+			w = &rateWindow{start: now}
+			s.windows[key] = w
+		}
+		if now.Sub(w.start) >= time.Second {
+			w.start = now
+			w.kept = 0
+		}
+		if w.kept >= s.rule.PerSecond {
+			s.dropped++
+			return false, 0
+		}
+		w.kept++
+	}
+	dropped := s.dropped
+	s.dropped = 0
+	return true, dropped
+}
@@ -0,0 +1,41 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+var slogLevels = map[Level]slog.Level{
+	DebugLevel: slog.LevelDebug,
+	InfoLevel:  slog.LevelInfo,
+	WarnLevel:  slog.LevelWarn,
+	ErrorLevel: slog.LevelError,
+}
+
+// UseSlog hands the entries of the default logger to h instead of its
+// writers, so a deployment can plug in the handlers it already runs. The
+// minimum level still applies first, h may filter further. A nil h goes back
+// to the writers.
+func UseSlog(h slog.Handler) {
+	defaultLogger.mu.Lock()
+	defer defaultLogger.mu.Unlock()
+	defaultLogger.handler = h
+}
+
+// handleSlog passes an entry to h, the fields become attributes in key order
+func handleSlog(h slog.Handler, at time.Time, level Level, msg string, fields map[string]any) {
+	ctx := context.Background()
+	if !h.Enabled(ctx, slogLevels[level]) {
+		return
+	}
+	record := slog.NewRecord(at, slogLevels[level], msg, 0)
+	for _, k := range sortedKeys(fields) {
+		v := fields[k]
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		record.AddAttrs(slog.Any(k, v))
+	}
+	h.Handle(ctx, record)
+}
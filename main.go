@@ -1,25 +1,899 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"flag"
+	"log/slog"
+	"order-book/account"
+	"order-book/alert"
+	"order-book/algo"
+	"order-book/archive"
 	"order-book/book"
+	"order-book/broker"
+	"order-book/chaos"
+	"order-book/config"
+	"order-book/cors"
+	"order-book/dashboard"
 	"order-book/db"
+	"order-book/db/replica"
+	"order-book/db/resilience"
+	"order-book/db/sqlite"
+	"order-book/depthhistory"
+	"order-book/diagnostics"
+	"order-book/dmm"
+	"order-book/dropcopy"
+	"order-book/election"
+	"order-book/export"
+	"order-book/feeasset"
+	"order-book/flags"
+	"order-book/funding"
+	"order-book/health"
+	"order-book/httperr"
+	"order-book/index"
+	"order-book/kline"
+	"order-book/ledgerqueue"
+	"order-book/liquidation"
+	applog "order-book/logger"
+	"order-book/margin"
+	"order-book/marketdata"
+	"order-book/marketmaker"
+	"order-book/metrics"
+	"order-book/netting"
 	"order-book/order"
+	"order-book/outbox"
+	"order-book/panics"
+	"order-book/pgnotify"
+	"order-book/portfolio"
+	"order-book/position"
+	"order-book/rebate"
+	"order-book/reload"
+	"order-book/replay"
+	"order-book/sandbox"
+	"order-book/session"
+	"order-book/shard"
+	"order-book/shutdown"
+	"order-book/standby"
+	"order-book/stp"
+	"order-book/subaccount"
+	"order-book/surveillance"
+	"order-book/synthetic"
+	"order-book/tenant"
+	"order-book/tlscert"
+	"order-book/tracing"
+	"order-book/webhook"
+	"order-book/wslimit"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/websocket/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"github.com/twmb/franz-go/pkg/kgo"
 )
 
+// Exit codes of a failed startup, so orchestrators can tell a bad
+// configuration apart from a dependency that isn't there yet
+const (
+	exitStartup    = 1
+	exitConfig     = 2
+	exitDatabase   = 3
+	exitMigrations = 4
+)
+
+func exit(code int, msg string, err error) {
+	applog.Error(msg, map[string]any{
+		"error": err,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	applog.Flush(ctx)
+	os.Exit(code)
+}
+
 func main() {
-	dbpool, err := db.Connect()
+	memory := flag.Bool("memory", false, "run entirely in RAM without persisting anything, like db.driver memory")
+	flag.Parse()
+	// --memory overrides db.driver like DB_DRIVER does, so the config is
+	// validated with it
+	if *memory {
+		os.Setenv("DB_DRIVER", "memory")
+	}
+	loadConfig := config.Load
+	cfg, err := loadConfig()
 	if err != nil {
-		panic(err)
+		exit(exitConfig, "invalid configuration", err)
+	}
+	level, _ := applog.ParseLevel(cfg.Log.Level)
+	applog.SetLevel(level)
+	for name, lvl := range cfg.Log.Components {
+		componentLevel, _ := applog.ParseLevel(lvl)
+		applog.SetComponentLevel(name, componentLevel)
+	}
+	format, _ := applog.ParseFormat(cfg.Log.Format)
+	applog.SetStdoutFormat(format, cfg.Log.Color)
+	applog.SetCaller(cfg.Log.Caller)
+	applog.SetErrorStacks(cfg.Log.ErrorStacks)
+	if cfg.Log.Backend == "slog" {
+		// The level is applied by the logger, the handler takes every entry
+		applog.UseSlog(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+	if cfg.Log.Async {
+		applog.EnableAsync(cfg.Log.BufferSize)
+	}
+	for _, rule := range cfg.Log.Sampling {
+		applog.SetSampling(rule.Message, applog.SampleRule{
+			Every:     rule.Every,
+			PerSecond: rule.PerSecond,
+			KeyField:  rule.KeyField,
+		})
+	}
+	if cfg.Log.File != "" {
+		fileFormat, _ := applog.ParseFormat(cfg.Log.FileFormat)
+		err := applog.AddRotatingFile(cfg.Log.File, fileFormat, applog.RotateOptions{
+			MaxSize:    int64(cfg.Log.MaxSizeMB) << 20,
+			Interval:   cfg.Log.RotateInterval,
+			MaxBackups: cfg.Log.MaxBackups,
+			MaxAge:     cfg.Log.MaxAge,
+			Compress:   cfg.Log.Compress,
+		})
+		if err != nil {
+			exit(exitConfig, "failed to open the log file", err)
+		}
+	}
+	for _, a := range cfg.OrderAssets() {
+		order.RegisterAsset(a)
+	}
+	for _, p := range cfg.OrderPairs() {
+		order.RegisterPair(p)
+	}
+	if cfg.Tracing.Enabled {
+		shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+			Endpoint:    cfg.Tracing.Endpoint,
+			Insecure:    cfg.Tracing.Insecure,
+			ServiceName: cfg.Tracing.ServiceName,
+			SampleRatio: cfg.Tracing.SampleRatio,
+		})
+		if err != nil {
+			exit(exitStartup, "failed to set up tracing", err)
+		}
+		// Deferred first so it runs last, after the queued events were written
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			shutdownTracing(ctx)
+		}()
+	}
+	if cfg.Sentry.DSN != "" {
+		reporter, err := panics.SentryReporter(cfg.Sentry.DSN, cfg.Sentry.Environment, cfg.Sentry.Release)
+		if err != nil {
+			exit(exitConfig, "failed to set up sentry", err)
+		}
+		panics.OnPanic(reporter)
+		defer panics.FlushSentry(2 * time.Second)
 	}
-	orderHistoryRepo := order.NewOrderRepository(dbpool)
-	orderBook := book.NewBook(orderHistoryRepo)
 
-	app := fiber.New()
-	app.Use(logger.New())
+	// Background jobs stop once the server shut down
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	readiness := health.NewReadiness()
+	var (
+		dbpool       *pgxpool.Pool
+		archiver     *archive.Archiver
+		exporter     *export.Exporter
+		orderStore   order.OrderRepo
+		tradeStore   order.TradeRepo
+		snapshotRepo order.SnapshotRepo
+		fence        *election.Fence
+		candleRepo   order.CandleRepo
+		pairRepo     order.PairRepo
+		assetRepo    order.AssetRepo
+		tenantRepo   tenant.TenantRepo
+		balanceRepo  account.BalanceRepo
+		alertRepo    surveillance.AlertRepo
+		webhookRepo  webhook.WebhookRepo
+		depthRepo    depthhistory.Repo
+		nettingRepo  netting.Repo
+		ledgerRepo   ledgerqueue.Repo
+		feeAssetRepo feeasset.Repo
+		marginRepo   margin.Repo
+	)
+	switch cfg.DB.Driver {
+	case "sqlite", "memory":
+		open := func() (*sql.DB, error) { return sqlite.Open(cfg.DB.SQLitePath) }
+		if cfg.DB.Driver == "memory" {
+			applog.Warn("running in memory mode, orders, trades and balances are lost on exit and the order history isn't kept", map[string]any{})
+			open = sqlite.OpenMemory
+		}
+		sqliteDB, err := open()
+		if err != nil {
+			exit(exitDatabase, "failed to open the sqlite database", err)
+		}
+		defer sqliteDB.Close()
+		readiness.Add("database", func(ctx context.Context) error { return sqliteDB.PingContext(ctx) })
+		orderStore = order.NewSQLiteOrderRepository(sqliteDB, cfg.DB.QueryTimeout)
+		if cfg.DB.Driver == "memory" {
+			orderStore = order.NewDiscardingOrderRepository(orderStore)
+		}
+		tradeStore = order.NewSQLiteTradeRepository(sqliteDB, cfg.DB.QueryTimeout)
+		snapshotRepo = order.NewSQLiteSnapshotRepository(sqliteDB, cfg.DB.QueryTimeout)
+		candleRepo = order.NewSQLiteCandleRepository(sqliteDB, cfg.DB.QueryTimeout)
+		pairRepo = order.NewSQLitePairRepository(sqliteDB, cfg.DB.QueryTimeout)
+		assetRepo = order.NewSQLiteAssetRepository(sqliteDB, cfg.DB.QueryTimeout)
+		tenantRepo = tenant.NewSQLiteTenantRepository(sqliteDB)
+		balanceRepo = account.NewSQLiteBalanceRepository(sqliteDB)
+		alertRepo = surveillance.NewSQLiteAlertRepository(sqliteDB)
+		webhookRepo = webhook.NewSQLiteWebhookRepository(sqliteDB)
+		depthRepo = depthhistory.NewSQLiteRepository(sqliteDB, cfg.DB.QueryTimeout)
+		nettingRepo = netting.NewSQLiteRepository(sqliteDB, cfg.DB.QueryTimeout)
+		ledgerRepo = ledgerqueue.NewSQLiteRepository(sqliteDB, cfg.DB.QueryTimeout)
+		feeAssetRepo = feeasset.NewSQLiteRepository(sqliteDB, cfg.DB.QueryTimeout)
+		marginRepo = margin.NewSQLiteRepository(sqliteDB, cfg.DB.QueryTimeout)
+	default:
+		// The sessions of an elected engine carry its fencing token
+		var configure []func(*pgxpool.Config)
+		if cfg.Election.Enabled {
+			fence = election.NewFence(cfg.Election.Lease)
+			configure = append(configure, fence.Configure)
+		}
+		dbpool, err = db.ConnectWithRetry(context.Background(), cfg.DB, configure...)
+		if err != nil {
+			exit(exitDatabase, "failed to connect to the database", err)
+		}
+		if err := db.WaitForMigrations(context.Background(), dbpool, cfg.DB.MigrationsTimeout); err != nil {
+			exit(exitMigrations, "database migrations aren't applied", err)
+		}
+		var replicaPool *pgxpool.Pool
+		if cfg.DB.ReadDSN != "" {
+			replicaCfg := cfg.DB
+			replicaCfg.DSN = cfg.DB.ReadDSN
+			if replicaPool, err = db.ConnectWithRetry(context.Background(), replicaCfg); err != nil {
+				exit(exitDatabase, "failed to connect to the read replica", err)
+			}
+		}
+		reads := replica.NewRouter(dbpool, replicaPool, cfg.DB.MaxReplicaLag)
+		go reads.Run(bgCtx, cfg.DB.ReplicaCheckInterval)
+		readiness.Add("database", func(ctx context.Context) error { return dbpool.Ping(ctx) })
+		readiness.Add("migrations", func(ctx context.Context) error { return db.CheckMigrations(ctx, dbpool) })
+		readiness.AddInfo("db_pool", func() any { return db.Stats(dbpool) })
+		orderStore = order.NewOrderRepository(dbpool, reads, cfg.DB.QueryTimeout)
+		tradeStore = order.NewTradeRepository(dbpool, reads, cfg.DB.QueryTimeout)
+		snapshotRepo = order.NewSnapshotRepository(dbpool, cfg.DB.QueryTimeout)
+		candleRepo = order.NewCandleRepository(reads, cfg.DB.QueryTimeout)
+		pairRepo = order.NewPairRepository(dbpool, cfg.DB.QueryTimeout)
+		assetRepo = order.NewAssetRepository(dbpool, cfg.DB.QueryTimeout)
+		tenantRepo = tenant.NewTenantRepository(dbpool)
+		balanceRepo = account.NewBalanceRepository(dbpool)
+		alertRepo = surveillance.NewAlertRepository(dbpool)
+		webhookRepo = webhook.NewWebhookRepository(dbpool)
+		depthRepo = depthhistory.NewRepository(dbpool, cfg.DB.QueryTimeout)
+		nettingRepo = netting.NewRepository(dbpool, cfg.DB.QueryTimeout)
+		ledgerRepo = ledgerqueue.NewRepository(dbpool, cfg.DB.QueryTimeout)
+		feeAssetRepo = feeasset.NewRepository(dbpool, cfg.DB.QueryTimeout)
+		marginRepo = margin.NewRepository(dbpool, cfg.DB.QueryTimeout)
+
+		go order.NewHistoryArchiver(
+			order.NewHistoryPartitionRepository(dbpool, cfg.DB.QueryTimeout),
+			cfg.Archive.HistoryRetention,
+			cfg.Archive.Interval,
+		).Run(bgCtx)
+		var exportStore archive.ObjectStore
+		if cfg.Archive.S3.Endpoint != "" {
+			store, err := archive.NewS3Store(context.Background(), archive.S3Options{
+				Endpoint:  cfg.Archive.S3.Endpoint,
+				Region:    cfg.Archive.S3.Region,
+				Bucket:    cfg.Archive.S3.Bucket,
+				AccessKey: cfg.Archive.S3.AccessKey,
+				SecretKey: cfg.Archive.S3.SecretKey,
+				UseSSL:    cfg.Archive.S3.UseSSL,
+			})
+			if err != nil {
+				exit(exitStartup, "failed to set up the archive store", err)
+			}
+			exportStore = store
+			archiver = archive.NewArchiver(
+				store,
+				order.NewHistoryPartitionRepository(dbpool, cfg.DB.QueryTimeout),
+				tradeStore,
+				cfg.Archive.TradeRetention,
+				cfg.Archive.Interval,
+			)
+			go archiver.Run(bgCtx)
+		}
+		// Exports are stored next to the archive, they're only streamed without one
+		exporter = export.NewExporter(order.NewExportRepository(reads, cfg.DB.QueryTimeout), candleRepo, exportStore)
+		var outboxPublisher outbox.Publisher
+		if cfg.Outbox.Mode == "relay" {
+			kafkaClient, err := kgo.NewClient(
+				kgo.SeedBrokers(cfg.Kafka.Brokers...),
+				kgo.ClientID(cfg.Kafka.ClientID),
+			)
+			if err != nil {
+				exit(exitStartup, "failed to create the outbox kafka client", err)
+			}
+			defer kafkaClient.Close()
+			outboxPublisher = outbox.NewKafkaPublisher(kafkaClient, cfg.Outbox.TopicPrefix)
+		}
+		go outbox.NewRelay(
+			outbox.NewOutboxRepository(dbpool, cfg.DB.QueryTimeout),
+			outboxPublisher,
+			cfg.Outbox.BatchSize,
+			cfg.Outbox.Interval,
+			cfg.Outbox.Retention,
+		).Run(bgCtx)
+	}
+	breaker := resilience.NewBreaker(resilience.Options{
+		MaxRetries:       cfg.Resilience.MaxRetries,
+		RetryBackoff:     cfg.Resilience.RetryBackoff,
+		MaxBackoff:       cfg.Resilience.MaxBackoff,
+		FailureThreshold: cfg.Resilience.FailureThreshold,
+		OpenTimeout:      cfg.Resilience.OpenTimeout,
+	})
+	queueWhileOpen := cfg.Resilience.WhileOpen == "queue"
+	readiness.Add("breaker", func(context.Context) error { return breaker.Allow() })
+	readiness.AddInfo("breaker", func() any { return breaker.Stats() })
+	// The chaos mode injects faults under the breaker, its retries and
+	// queueing are what gets exercised
+	faults := chaos.NewInjector(cfg.Chaos.Enabled)
+	if faults.Enabled() {
+		applog.Warn("chaos mode enabled, faults can be injected through /admin/chaos", map[string]any{})
+		orderStore = faults.OrderRepo(orderStore)
+		tradeStore = faults.TradeRepo(tradeStore)
+		go faults.Run(bgCtx)
+	}
+	orderRepo := order.NewResilientOrderRepository(orderStore, breaker, queueWhileOpen)
+	eventWriter := order.NewEventWriter(
+		orderRepo,
+		cfg.Engine.EventBatchSize,
+		cfg.Engine.EventFlushInterval,
+		cfg.Engine.EventBufferSize,
+	)
+	tenantDirectory, err := tenant.NewDirectory(tenantRepo)
+	if err != nil {
+		exit(exitDatabase, "failed to load the tenants", err)
+	}
+	tradeRepo := order.NewResilientTradeRepository(tradeStore, breaker, queueWhileOpen)
+	books := book.NewRegistry(eventWriter, tradeRepo, tenantDirectory.TenantOf, cfg.Engine.OrderQueueSize, cfg.FeeSchedule())
+	metrics.MustRegister(books.Collector())
+	// The assets and pairs listed through the admin API take over those of
+	// the file, every listed pair gets its book before the first one is
+	// created
+	storedAssets, err := assetRepo.GetAssets(context.Background())
+	if err != nil {
+		exit(exitDatabase, "failed to load the assets", err)
+	}
+	for _, a := range storedAssets {
+		order.RegisterAsset(a)
+	}
+	storedPairs, err := pairRepo.GetPairs(context.Background())
+	if err != nil {
+		exit(exitDatabase, "failed to load the pairs", err)
+	}
+	for _, p := range storedPairs {
+		order.RegisterPair(p)
+	}
+	for _, p := range order.GetPairs() {
+		books.OpenPair(p)
+	}
+	// The books of a pair in an auction pre-open collect its orders, they're
+	// uncrossed when the pre-open ends
+	sessions := session.New(cfg.Sessions())
+	sessions.OnTransition(func(pairId string, from string, to string) {
+		if to == session.PRE_OPEN && sessions.Auction(pairId) {
+			books.StartAuction(pairId)
+		}
+		if from == session.PRE_OPEN {
+			books.EndAuction(bgCtx, pairId)
+		}
+	})
+	sessions.SetCalendar(cfg.Closures())
+	go sessions.Run(bgCtx, time.Second)
+	// The obligations of the designated market makers only count while their
+	// pairs are open
+	dmmTracker := dmm.NewTracker(books, cfg.DMMObligations(), cfg.DMM.SampleInterval, cfg.DMM.Retention, func(pairId string) bool {
+		return sessions.State(pairId) == session.OPEN
+	})
+	go dmmTracker.Run(bgCtx)
+	readiness.Add("engine", func(context.Context) error {
+		return books.CheckEngines(cfg.Health.QueueSaturation, cfg.Health.StallTimeout)
+	})
+	readiness.AddInfo("engines", func() any { return books.Health() })
+
+	// A standby follows the books of the primary until it's promoted, a node
+	// with a token streams its own to standbys
+	var follower *standby.Follower
+	if cfg.Standby.PrimaryURL != "" {
+		follower = standby.NewFollower(books, cfg.Standby.PrimaryURL, cfg.Standby.Token)
+		readiness.Add("standby", func(context.Context) error {
+			if !follower.Promoted() {
+				return standby.ErrStandby
+			}
+			return nil
+		})
+		readiness.AddInfo("standby", func() any { return follower.Status() })
+		go follower.Run(bgCtx)
+	}
+	// restoreBooks loads the latest snapshots of the books of every tenant
+	restoreBooks := func(ctx context.Context) error {
+		var pairIds []string
+		for _, p := range cfg.Pairs {
+			pairIds = append(pairIds, p.ID)
+		}
+		for _, t := range tenantDirectory.GetTenants() {
+			pairs := t.Pairs
+			if len(pairs) == 0 {
+				pairs = pairIds
+			}
+			if err := book.RestoreLatest(ctx, snapshotRepo, t.ID, books.Get(t.ID), pairs); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// Instances sharing the database elect the one taking orders, the others
+	// wait for the lease
+	var elector *election.Elector
+	if cfg.Election.Enabled {
+		host, _ := os.Hostname()
+		elector = election.NewElector(dbpool, fence, host+"/"+strconv.Itoa(os.Getpid()), cfg.Election.Interval)
+		elector.OnElected(restoreBooks)
+		// The books may have moved on under the new leader, they're loaded
+		// again once the restarted instance wins the lease back
+		elector.OnDeposed(func(err error) {
+			exit(exitDatabase, "stepped down as the engine leader", err)
+		})
+		readiness.Add("leader", func(context.Context) error {
+			if !elector.Leader() {
+				return election.ErrNotLeader
+			}
+			return nil
+		})
+		readiness.AddInfo("election", func() any { return elector.Status() })
+	} else if cfg.Engine.RestoreSnapshots {
+		if err := restoreBooks(context.Background()); err != nil {
+			exit(exitDatabase, "failed to restore the book snapshots", err)
+		}
+	}
+	var journal *standby.Journal
+	if cfg.Standby.Token != "" {
+		journal = standby.NewJournal()
+		books.OnBook(journal.Attach)
+	}
+	var alertMonitor *alert.Monitor
+	if cfg.Alerting.Enabled {
+		var notifiers alert.Notifiers
+		if cfg.Alerting.WebhookURL != "" {
+			notifiers = append(notifiers, alert.Webhook{URL: cfg.Alerting.WebhookURL})
+		}
+		if cfg.Alerting.SlackWebhookURL != "" {
+			notifiers = append(notifiers, alert.Slack{WebhookURL: cfg.Alerting.SlackWebhookURL})
+		}
+		if cfg.Alerting.PagerDutyRoutingKey != "" {
+			notifiers = append(notifiers, alert.PagerDuty{RoutingKey: cfg.Alerting.PagerDutyRoutingKey})
+		}
+		alertMonitor = alert.NewMonitor(books, notifiers, alert.Rules{
+			CrossedBook:        cfg.Alerting.CrossedBook,
+			QueueDepth:         cfg.Alerting.QueueDepth,
+			PersistFailureRate: cfg.Alerting.PersistFailureRate,
+			NoTradesFor:        cfg.Alerting.NoTradesFor,
+		}, cfg.Alerting.RepeatInterval)
+		go alertMonitor.Run(bgCtx, cfg.Alerting.Interval)
+	}
+	snapshotter := book.NewSnapshotter(
+		snapshotRepo,
+		cfg.Engine.SnapshotInterval,
+		cfg.Engine.SnapshotEvents,
+	)
+	depthRecorder := depthhistory.NewRecorder(
+		bgCtx,
+		depthRepo,
+		cfg.DepthHistory.Interval,
+		cfg.DepthHistory.Levels,
+		cfg.DepthHistory.Retention,
+	)
+	if cfg.DepthHistory.Enabled {
+		go depthRecorder.Run(bgCtx)
+	}
+	// Positions are marked at the index of their pair, at the last price
+	// of the book while it has none
+	indexPrices := index.NewService(index.Options{
+		MaxAge:       cfg.Index.MaxAge,
+		MaxDeviation: cfg.Index.MaxDeviation,
+		MinSources:   cfg.Index.MinSources,
+	})
+	for _, src := range cfg.Index.Sources {
+		go src.Feed().Run(bgCtx, indexPrices)
+	}
+	go indexPrices.Run(bgCtx, time.Second)
+	positionTracker := position.NewTracker(index.NewMarkPrices(indexPrices, books))
+	// The positions are rebuilt from the trades before the instance takes
+	// orders, once it's elected when instances share the database
+	rebuildPositions := func(ctx context.Context) error {
+		count, err := positionTracker.Rebuild(ctx, tradeRepo)
+		if err != nil {
+			return err
+		}
+		applog.Info("positions rebuilt from the trades", map[string]any{
+			"trades": count,
+		})
+		return nil
+	}
+	if elector != nil {
+		elector.OnElected(rebuildPositions)
+	} else if err := rebuildPositions(context.Background()); err != nil {
+		exit(exitDatabase, "failed to rebuild the positions", err)
+	}
+	portfolioService := portfolio.NewService(balanceRepo, books, positionTracker)
+	defaultMargin, pairMargins := cfg.MarginLimits()
+	marginEngine, err := margin.NewEngine(balanceRepo, positionTracker, books, marginRepo, defaultMargin, pairMargins)
+	if err != nil {
+		exit(exitStartup, "failed to load the margin accounts", err)
+	}
+	indexPrices.OnUpdate(func(p index.Price) { marginEngine.MarkUpdated(p.PairID) })
+	// Each tenant funds the perpetual pairs at the premium of its own book
+	fundingEngine := funding.NewEngine(funding.Options{
+		Pairs:          cfg.Funding.Pairs,
+		Interval:       cfg.Funding.Interval,
+		SampleInterval: cfg.Funding.SampleInterval,
+		MaxRate:        cfg.Funding.MaxRate,
+	}, books, tenantDirectory.TenantOf, indexPrices, positionTracker, balanceRepo)
+	if len(cfg.Funding.Pairs) > 0 {
+		go fundingEngine.Run(bgCtx)
+	}
+	liquidations := liquidation.NewEngine(liquidation.Options{
+		StepRatio:    cfg.Liquidation.StepRatio,
+		PriceBand:    cfg.Liquidation.PriceBand,
+		StepInterval: cfg.Liquidation.StepInterval,
+		FeeRate:      cfg.Liquidation.FeeRate,
+	}, books, marginEngine, positionTracker, balanceRepo)
+	if cfg.Liquidation.Enabled {
+		marginEngine.OnMarginCall(liquidations.OnMarginCall)
+		go liquidations.Run(bgCtx)
+	}
+	nets, err := netting.NewEngine(cfg.Netting.Window, cfg.Netting.Accounts, nettingRepo, balanceRepo)
+	if err != nil {
+		exit(exitStartup, "failed to load the accounts in netting mode", err)
+	}
+	go nets.Run(bgCtx)
+	ledgerQueue := ledgerqueue.NewQueue(ledgerRepo, balanceRepo)
+	go ledgerQueue.Run(bgCtx)
+	feeAssets, err := feeasset.NewEngine(cfg.Fees.Assets, indexPrices, feeAssetRepo, ledgerQueue, nets)
+	if err != nil {
+		exit(exitStartup, "failed to load the fee assets of the accounts", err)
+	}
+	go feeAssets.Run(bgCtx)
+	rebates := rebate.NewEngine(ledgerQueue, nets)
+	go rebates.Run(bgCtx)
+	washDetector, err := surveillance.NewWashTradeDetector(alertRepo)
+	if err != nil {
+		exit(exitDatabase, "failed to set up the wash trade detector", err)
+	}
+	spoofingDetector := surveillance.NewSpoofingDetector(alertRepo, surveillance.SpoofingThresholds{
+		LargeOrderNotional:  100000,
+		MaxLifetime:         2 * time.Second,
+		Window:              time.Minute,
+		MinOppositeNotional: 1000,
+		Layers:              3,
+		MinScore:            0.6,
+	}, map[string]surveillance.SpoofingThresholds{})
+	dropCopyFeed := dropcopy.NewFeed(cfg.Engine.DropCopyHistorySize)
+	webhookDispatcher, err := webhook.NewDispatcher(webhookRepo)
+	if err != nil {
+		exit(exitDatabase, "failed to load the webhooks", err)
+	}
+	var marketCache marketdata.Cache
+	var marketGateway *marketdata.Gateway
+	if cfg.Redis.Addr != "" {
+		redisClient := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		marketCache = marketdata.NewRedisCache(redisClient, cfg.MarketData.TTL)
+		var broadcaster marketdata.Broadcaster
+		if cfg.MarketData.Fanout {
+			broadcaster = marketdata.NewRedisBroadcaster(redisClient)
+		}
+		marketGateway = marketdata.NewGateway(redisClient, cfg.HTTP.WS.SendQueueSize, cfg.HTTP.WS.SlowConsumerPolicy)
+		go marketGateway.Run(bgCtx)
+		publisher := marketdata.NewPublisher(
+			marketCache,
+			broadcaster,
+			cfg.MarketData.Interval,
+			cfg.MarketData.TTL,
+			cfg.MarketData.DepthLevels,
+			cfg.MarketData.RecentTrades,
+		)
+		books.OnBook(faults.Attach(publisher.Attach))
+	}
+	var brokerPublisher *broker.Publisher
+	if cfg.Broker.Enabled {
+		topics := broker.Topics{
+			Trades: cfg.Broker.Topics.Trades,
+			Orders: cfg.Broker.Topics.Orders,
+			Depth:  cfg.Broker.Topics.Depth,
+		}
+		var transport broker.Transport
+		switch cfg.Broker.Transport {
+		case "kafka":
+			kafkaClient, err := kgo.NewClient(
+				kgo.SeedBrokers(cfg.Kafka.Brokers...),
+				kgo.ClientID(cfg.Kafka.ClientID),
+				kgo.ProducerLinger(cfg.Broker.Linger),
+			)
+			if err != nil {
+				exit(exitStartup, "failed to create the broker kafka client", err)
+			}
+			transport = broker.NewKafkaTransport(kafkaClient)
+		case "nats":
+			conn, err := nats.Connect(cfg.NATS.URL)
+			if err != nil {
+				exit(exitStartup, "failed to connect to nats", err)
+			}
+			if transport, err = broker.NewNATSTransport(context.Background(), conn, cfg.NATS.Stream, topics); err != nil {
+				exit(exitStartup, "failed to set up the nats stream", err)
+			}
+		}
+		brokerPublisher = broker.NewPublisher(transport, broker.Options{
+			Topics:        topics,
+			BatchSize:     cfg.Broker.BatchSize,
+			Linger:        cfg.Broker.Linger,
+			MaxRetries:    cfg.Broker.MaxRetries,
+			RetryBackoff:  cfg.Broker.RetryBackoff,
+			BufferSize:    cfg.Broker.BufferSize,
+			DepthInterval: cfg.Broker.DepthInterval,
+		})
+		go brokerPublisher.Run(bgCtx)
+		books.OnBook(faults.Attach(brokerPublisher.Attach))
+	}
+
+	if cfg.NATS.CommandsSubject != "" {
+		conn, err := nats.Connect(cfg.NATS.URL)
+		if err != nil {
+			exit(exitStartup, "failed to connect to nats", err)
+		}
+		defer conn.Drain()
+		commands, err := broker.NewCommandConsumer(
+			context.Background(),
+			conn,
+			cfg.NATS.CommandsStream,
+			cfg.NATS.CommandsSubject,
+			cfg.NATS.Durable,
+			books,
+			cfg.HTTP.RequestTimeout,
+		)
+		if err != nil {
+			exit(exitStartup, "failed to set up the order command consumer", err)
+		}
+		consume := func() {
+			go func() {
+				if err := commands.Run(bgCtx); err != nil {
+					exit(exitStartup, "order command consumer stopped", err)
+				}
+			}()
+		}
+		// The commands are left queued for the primary, or the leader
+		switch {
+		case follower != nil:
+			follower.OnPromote(consume)
+		case elector != nil:
+			elector.OnElected(func(context.Context) error {
+				consume()
+				return nil
+			})
+		default:
+			consume()
+		}
+	}
+	// Campaigning once the hooks of the leader are all registered
+	if elector != nil {
+		go elector.Run(bgCtx)
+	}
+
+	liveCandles := kline.NewLive()
+	books.OnBook(liveCandles.Attach)
+
+	var listener *pgnotify.Listener
+	if cfg.Notify.Enabled {
+		notifier := pgnotify.NewNotifier(dbpool, cfg.Notify.Channel, cfg.Notify.BufferSize)
+		go notifier.Run(bgCtx)
+		books.OnBook(faults.Attach(notifier.Attach))
+		liquidations.OnLiquidation(notifier.Liquidation)
+		listener = pgnotify.NewListener(dbpool.Config().ConnConfig, cfg.Notify.Channel)
+		go listener.Run(bgCtx)
+	}
+
+	bookRates := diagnostics.NewRates()
+	dashboardTrades := dashboard.NewTrades(cfg.MarketData.RecentTrades)
+	flagSet := flags.NewSet(cfg.Flags)
+	stpGroups := stp.NewGroups(cfg.STP.Groups)
+	books.OnBook(func(tenantId string, b book.Book) { b.SetSTPGroups(stpGroups.GroupOf) })
+	postOnlyEnabled := func(o order.Order) bool {
+		return flagSet.Enabled(flags.POST_ONLY, o.PairID, o.AccountID)
+	}
+	algos := algo.NewService(bgCtx, books, cfg.Algo.SliceInterval, indexPrices, index.NewMarkPrices(indexPrices, books), cfg.StopTriggers())
+	indexPrices.OnUpdate(func(p index.Price) { algos.PriceUpdated(p.PairID) })
+	syntheticRouter := synthetic.NewRouter(books, stpGroups.GroupOf)
+	books.OnBook(func(tenantId string, b book.Book) {
+		b.AddValidator(order.ValidatePair)
+		b.AddValidator(order.ValidateTags)
+		b.AddValidator(sessions.ValidateOrder)
+		b.AddValidator(tenantDirectory.OrderValidator(tenantId, b))
+		b.AddValidator(book.PostOnlyValidator(b, postOnlyEnabled))
+		b.AddValidator(marginEngine.ValidateOrder)
+		if !queueWhileOpen {
+			b.AddValidator(func(order.Order) error { return breaker.Allow() })
+		}
+		b.OnTrade(positionTracker.OnTrade)
+		b.OnTrade(marginEngine.OnTrade)
+		b.OnTrade(feeAssets.OnTrade)
+		b.OnTrade(rebates.OnTrade)
+		b.OnTrade(washDetector.OnTrade)
+		b.OnOrderEvent(spoofingDetector.OnOrderEvent)
+		b.OnTrade(spoofingDetector.OnTrade)
+		b.OnOrderEvent(dropCopyFeed.OnOrderEvent)
+		b.OnTrade(dropCopyFeed.OnTrade)
+		faults.Lossy(b).OnOrderEvent(webhookDispatcher.OnOrderEvent)
+		faults.Lossy(b).OnTrade(webhookDispatcher.OnTrade)
+		if faults.Enabled() {
+			b.BeforeMatch(faults.BeforeMatch)
+		}
+		snapshotter.Attach(tenantId, b)
+		if cfg.DepthHistory.Enabled {
+			depthRecorder.Attach(tenantId, b)
+		}
+		bookRates.Attach(tenantId, b)
+		dashboardTrades.Attach(tenantId, b)
+		algos.Attach(tenantId, b)
+		if cfg.Synthetic.Enabled {
+			syntheticRouter.Attach(tenantId, b)
+		}
+	})
+
+	// Errors handlers can return as is, their message is meant for clients
+	for err, status := range map[error]int{
+		context.DeadlineExceeded:             fiber.StatusGatewayTimeout,
+		resilience.ErrCircuitOpen:            fiber.StatusServiceUnavailable,
+		book.ErrEngineStopped:                fiber.StatusServiceUnavailable,
+		book.ErrEnginePanic:                  fiber.StatusInternalServerError,
+		book.ErrBookClosed:                   fiber.StatusServiceUnavailable,
+		book.ErrPostOnlyDisabled:             fiber.StatusUnprocessableEntity,
+		book.ErrPostOnlyWouldMatch:           fiber.StatusUnprocessableEntity,
+		chaos.ErrInjectedFault:               fiber.StatusServiceUnavailable,
+		standby.ErrStandby:                   fiber.StatusServiceUnavailable,
+		election.ErrNotLeader:                fiber.StatusServiceUnavailable,
+		shard.ErrNotOwner:                    fiber.StatusMisdirectedRequest,
+		shard.ErrOwnerUnreachable:            fiber.StatusBadGateway,
+		order.ErrOrderNotFound:               fiber.StatusNotFound,
+		algo.ErrAlgoNotFound:                 fiber.StatusNotFound,
+		algo.ErrAlgoDone:                     fiber.StatusConflict,
+		algo.ErrInvalidTWAP:                  fiber.StatusBadRequest,
+		algo.ErrInvalidLimit:                 fiber.StatusBadRequest,
+		algo.ErrParticipation:                fiber.StatusBadRequest,
+		algo.ErrTWAPTooShort:                 fiber.StatusBadRequest,
+		algo.ErrInvalidStop:                  fiber.StatusBadRequest,
+		algo.ErrInvalidTrigger:               fiber.StatusBadRequest,
+		algo.ErrStopReached:                  fiber.StatusUnprocessableEntity,
+		synthetic.ErrNoRoute:                 fiber.StatusNotFound,
+		synthetic.ErrDirectPair:              fiber.StatusBadRequest,
+		synthetic.ErrInvalidSynthetic:        fiber.StatusBadRequest,
+		synthetic.ErrInsufficientLiquidity:   fiber.StatusUnprocessableEntity,
+		synthetic.ErrSyntheticLimit:          fiber.StatusUnprocessableEntity,
+		order.ErrTradeNotFound:               fiber.StatusNotFound,
+		order.ErrSnapshotNotFound:            fiber.StatusNotFound,
+		depthhistory.ErrSnapshotNotFound:     fiber.StatusNotFound,
+		order.ErrInvalidInterval:             fiber.StatusBadRequest,
+		order.ErrUnknownPair:                 fiber.StatusUnprocessableEntity,
+		order.ErrPairHalted:                  fiber.StatusUnprocessableEntity,
+		order.ErrInvalidTickSize:             fiber.StatusUnprocessableEntity,
+		order.ErrAmountTooSmall:              fiber.StatusUnprocessableEntity,
+		order.ErrPriceScale:                  fiber.StatusUnprocessableEntity,
+		order.ErrAmountScale:                 fiber.StatusUnprocessableEntity,
+		order.ErrInvalidLotSize:              fiber.StatusUnprocessableEntity,
+		order.ErrNotionalTooLow:              fiber.StatusUnprocessableEntity,
+		order.ErrPairExists:                  fiber.StatusConflict,
+		session.ErrSessionClosed:             fiber.StatusUnprocessableEntity,
+		session.ErrCancelOnly:                fiber.StatusUnprocessableEntity,
+		session.ErrCalendarClosed:            fiber.StatusUnprocessableEntity,
+		order.ErrTagTooLong:                  fiber.StatusUnprocessableEntity,
+		order.ErrStrategyIDTooLong:           fiber.StatusUnprocessableEntity,
+		order.ErrQuoteCrossed:                fiber.StatusUnprocessableEntity,
+		order.ErrQuoteInvalid:                fiber.StatusUnprocessableEntity,
+		book.ErrTooManyQuotes:                fiber.StatusBadRequest,
+		book.ErrDuplicatedQuote:              fiber.StatusBadRequest,
+		order.ErrUnknownAsset:                fiber.StatusUnprocessableEntity,
+		order.ErrAssetPrecision:              fiber.StatusUnprocessableEntity,
+		order.ErrTradingDisabled:             fiber.StatusUnprocessableEntity,
+		order.ErrWithdrawalsDisabled:         fiber.StatusUnprocessableEntity,
+		order.ErrAssetExists:                 fiber.StatusConflict,
+		order.ErrAssetInUse:                  fiber.StatusConflict,
+		book.ErrPairNotOpen:                  fiber.StatusUnprocessableEntity,
+		book.ErrPairNotEmpty:                 fiber.StatusConflict,
+		account.ErrInsufficientBalance:       fiber.StatusUnprocessableEntity,
+		account.ErrInsufficientHeld:          fiber.StatusUnprocessableEntity,
+		account.ErrInvalidAmount:             fiber.StatusBadRequest,
+		account.ErrIdempotencyKeyReused:      fiber.StatusConflict,
+		margin.ErrInsufficientMargin:         fiber.StatusUnprocessableEntity,
+		margin.ErrLeverageTooHigh:            fiber.StatusBadRequest,
+		margin.ErrMarginNotEnabled:           fiber.StatusNotFound,
+		tenant.ErrTenantNotFound:             fiber.StatusNotFound,
+		tenant.ErrTenantExists:               fiber.StatusConflict,
+		tenant.ErrAccountNotInTenant:         fiber.StatusForbidden,
+		tenant.ErrPairNotAllowed:             fiber.StatusUnprocessableEntity,
+		tenant.ErrOpenOrdersQuota:            fiber.StatusTooManyRequests,
+		tenant.ErrOrderRateQuota:             fiber.StatusTooManyRequests,
+		tenant.ErrAccountsQuota:              fiber.StatusUnprocessableEntity,
+		tenant.ErrAccountNotFound:            fiber.StatusNotFound,
+		tenant.ErrNestedSubAccount:           fiber.StatusUnprocessableEntity,
+		tenant.ErrOpenOrdersLimit:            fiber.StatusTooManyRequests,
+		tenant.ErrOrderRateLimit:             fiber.StatusTooManyRequests,
+		tenant.ErrAccountNotInScope:          fiber.StatusForbidden,
+		surveillance.ErrAlertNotFound:        fiber.StatusNotFound,
+		surveillance.ErrAlertAlreadyReviewed: fiber.StatusConflict,
+	} {
+		httperr.Register(err, status)
+	}
+	app := fiber.New(fiber.Config{
+		ErrorHandler: httperr.Handler,
+		JSONDecoder:  httperr.DecodeJSON,
+	})
+	app.Use(httperr.RequestIDs())
+	app.Use(logger.New(logger.Config{
+		Format: "${time} | ${status} | ${latency} | ${ip} | ${method} | ${path} | ${locals:requestid} | ${error}\n",
+	}))
+	if cfg.Metrics.Enabled {
+		app.Use(metrics.Middleware())
+	}
+	if cfg.Tracing.Enabled {
+		app.Use(tracing.Middleware())
+	}
+	// Errors and panics of the handlers become the envelope, the middlewares
+	// above see their final status
+	app.Use(httperr.Middleware())
+	if follower != nil {
+		app.Use(follower.Middleware())
+	}
+	if elector != nil {
+		app.Use(elector.Middleware())
+	}
+	if len(cfg.HTTP.CORS.AllowOrigins) > 0 {
+		policy := cors.Policy{
+			AllowOrigins:     cfg.HTTP.CORS.AllowOrigins,
+			AllowMethods:     cfg.HTTP.CORS.AllowMethods,
+			AllowHeaders:     cfg.HTTP.CORS.AllowHeaders,
+			ExposeHeaders:    cfg.HTTP.CORS.ExposeHeaders,
+			AllowCredentials: cfg.HTTP.CORS.AllowCredentials,
+			MaxAge:           cfg.HTTP.CORS.MaxAge,
+		}
+		app.Use(policy.Middleware())
+		app.Use("/ws", policy.CheckWSOrigin())
+	}
+	if cfg.DB.Driver == "memory" {
+		// Clients can tell they're talking to an engine that keeps nothing
+		app.Use(func(c *fiber.Ctx) error {
+			c.Set("X-Engine-Durable", "false")
+			return c.Next()
+		})
+	}
+	app.Use(func(c *fiber.Ctx) error {
+		if cfg.HTTP.RequestTimeout <= 0 {
+			return c.Next()
+		}
+		// Handlers pass c.UserContext() down so their queries stop with the request
+		ctx, cancel := context.WithTimeout(c.UserContext(), cfg.HTTP.RequestTimeout)
+		defer cancel()
+		c.SetUserContext(ctx)
+		return c.Next()
+	})
 
 	app.Use("/ws", func(c *fiber.Ctx) error {
 		if websocket.IsWebSocketUpgrade(c) {
@@ -29,11 +903,297 @@ func main() {
 		return fiber.ErrUpgradeRequired
 	})
 
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.Send([]byte("Working..."))
+	health.BindHealthRouter(app, readiness, cfg.Health.CheckTimeout)
+	if cfg.Metrics.Enabled {
+		metrics.BindMetricsRouter(app, cfg.Metrics.Path)
+	}
+
+	app.Use(tenant.Middleware(tenantDirectory))
+	// The sandbox is a tenant of its own, its books and accounts are apart
+	// from the real ones and funded with fictional balances
+	var paper *sandbox.Sandbox
+	if cfg.Sandbox.Enabled {
+		paper = sandbox.New(cfg.Sandbox.TenantID, tenantDirectory, balanceRepo, cfg.Sandbox.AutoFund, cfg.Sandbox.MaxFund)
+		if err := paper.Ensure(); err != nil {
+			exit(exitDatabase, "failed to create the sandbox tenant", err)
+		}
+		app.Use(paper.Middleware())
+	}
+	wsLimiter := wslimit.NewLimiter(wslimit.Limits{
+		Total:  cfg.HTTP.WS.MaxConnections,
+		PerIP:  cfg.HTTP.WS.MaxConnectionsPerIP,
+		PerKey: cfg.HTTP.WS.MaxConnectionsPerKey,
 	})
+	readiness.AddInfo("websockets", func() any { return wsLimiter.Stats() })
+	app.Use("/ws", wsLimiter.Middleware())
 
-	book.BindOrderBookRouter(app, orderBook)
+	// The settings that can change while the engine runs are applied again
+	// when the config file changes
+	reloader := reload.NewReloader(os.Getenv("CONFIG_FILE"), loadConfig, cfg, eventWriter)
+	reloader.Register(reload.LogLevels())
+	reloader.Register(reload.WSLimits(wsLimiter))
+	reloader.Register(reload.Fees(books))
+	reloader.Register(reload.FeeAssets(feeAssets))
+	reloader.Register(reload.Margin(marginEngine))
+	reloader.Register(reload.Assets())
+	reloader.Register(reload.Pairs(books))
+	reloader.Register(reload.Sessions(sessions))
+	reloader.Register(reload.Calendar(sessions))
+	reloader.Register(reload.DMM(dmmTracker))
+	reloader.Register(reload.Flags(flagSet))
+	reloader.Register(reload.STPGroups(stpGroups))
+	if os.Getenv("CONFIG_FILE") != "" && cfg.Reload.Interval > 0 {
+		go reloader.Run(bgCtx, cfg.Reload.Interval)
+	}
+	app.Use("/accounts/:id", tenant.RequireAccount(tenantDirectory))
+	app.Use("/ws/accounts/:id", tenant.RequireAccount(tenantDirectory))
+	if cfg.Operator.Token == "" {
+		applog.Warn("no operator token is set, the /admin routes are refused", map[string]any{})
+	}
+	app.Use("/admin", tenant.RequireOperator(cfg.Operator.Token))
 
-	app.Listen(":5000")
+	// With sharding, the requests for the pairs of other nodes are forwarded
+	// before reaching the books of this one
+	if len(cfg.Sharding.Nodes) > 0 {
+		nodes := make([]shard.Node, 0, len(cfg.Sharding.Nodes))
+		for _, node := range cfg.Sharding.Nodes {
+			nodes = append(nodes, shard.Node{ID: node.ID, URL: node.URL})
+		}
+		shardRouter := shard.NewRouter(cfg.Sharding.NodeID, nodes, cfg.Sharding.Assignments, cfg.Sharding.ForwardTimeout, orderRepo)
+		readiness.AddInfo("shard", func() any { return shardRouter.Self() })
+		shardRouter.Bind(app)
+		shard.BindShardRouter(app, shardRouter)
+	}
+	book.BindOrderBookRouter(app, books)
+	account.BindAccountRouter(app, balanceRepo)
+	position.BindPositionRouter(app, positionTracker)
+	portfolio.BindPortfolioRouter(app, portfolioService)
+	margin.BindMarginRouter(app, marginEngine)
+	surveillance.BindSurveillanceRouter(app, alertRepo, washDetector)
+	dropcopy.BindDropCopyRouter(app, dropCopyFeed, cfg.DropCopy.Token)
+	tenant.BindTenantRouter(app, tenantDirectory)
+	subaccount.BindSubAccountRouter(app, tenantDirectory, subaccount.NewService(tenantDirectory, balanceRepo, positionTracker, books))
+	if paper != nil {
+		sandbox.BindSandboxRouter(app, paper)
+	}
+	applog.BindLogRouter(app)
+	reload.BindReloadRouter(app, reloader)
+	flags.BindFlagsRouter(app, flagSet, eventWriter)
+	stp.BindSTPRouter(app, stpGroups, eventWriter)
+	if faults.Enabled() {
+		chaos.BindChaosRouter(app, faults)
+	}
+	if cfg.Replay.Enabled() {
+		open := func(ctx context.Context) (replay.Source, error) {
+			if cfg.Replay.File != "" {
+				return replay.OpenFile(cfg.Replay.File)
+			}
+			// The history is read from db.dsn while the engine runs in memory
+			historyPool, err := db.Connect(cfg.DB)
+			if err != nil {
+				return nil, err
+			}
+			defer historyPool.Close()
+			return replay.LoadHistory(ctx, order.NewFlowRepository(historyPool, cfg.DB.QueryTimeout), cfg.Replay.From, cfg.Replay.To)
+		}
+		newAccount := func() (int, error) { return tenantDirectory.CreateAccount(tenant.DEFAULT) }
+		replayer := replay.NewReplayer(books.Get(tenant.DEFAULT), cfg.Replay.Speed, open, newAccount)
+		readiness.AddInfo("replay", func() any { return replayer.Stats() })
+		replay.BindReplayRouter(bgCtx, app, replayer)
+		if cfg.Replay.AutoStart {
+			replayer.Start(bgCtx)
+		}
+	}
+	if cfg.MarketMaker.Enabled {
+		accountId := cfg.MarketMaker.AccountID
+		if accountId == 0 {
+			var err error
+			if accountId, err = tenantDirectory.CreateAccount(cfg.MarketMaker.TenantID); err != nil {
+				exit(exitDatabase, "failed to open the account of the market maker", err)
+			}
+		}
+		quotes := make(map[string]marketmaker.Quote, len(cfg.MarketMaker.Pairs))
+		for pairId, q := range cfg.MarketMaker.Pairs {
+			quotes[pairId] = marketmaker.Quote{
+				Price:        decimal.NewFromFloat(q.Price),
+				Spread:       q.Spread,
+				Size:         decimal.NewFromFloat(q.Size),
+				Levels:       q.Levels,
+				MaxInventory: decimal.NewFromFloat(q.MaxInventory),
+				Skew:         q.Skew,
+			}
+		}
+		mm := marketmaker.New(books.Get(cfg.MarketMaker.TenantID), accountId, quotes, cfg.MarketMaker.Interval)
+		readiness.AddInfo("market_maker", func() any { return mm.Stats() })
+		marketmaker.BindMarketMakerRouter(app, mm)
+		go mm.Run(bgCtx)
+	}
+	dmm.BindDMMRouter(app, dmmTracker)
+	algo.BindAlgoRouter(app, algos)
+	index.BindIndexRouter(app, indexPrices)
+	funding.BindFundingRouter(app, fundingEngine)
+	liquidation.BindLiquidationRouter(app, liquidations)
+	feeasset.BindFeeAssetRouter(app, feeAssets)
+	rebate.BindRebateRouter(app, rebates)
+	netting.BindNettingRouter(app, nets)
+	ledgerqueue.BindLedgerQueueRouter(app, ledgerQueue)
+	if cfg.Synthetic.Enabled {
+		synthetic.BindSyntheticRouter(app, syntheticRouter)
+	}
+	metrics.BindWSStatsRouter(app)
+	diagnostics.BindStatsRouter(app, books, bookRates)
+	dashboard.BindDashboardRouter(app, books, bookRates, dashboardTrades)
+	webhook.BindWebhookRouter(app, webhookDispatcher)
+	if dbpool != nil {
+		db.BindDBRouter(app, dbpool)
+	}
+	resilience.BindBreakerRouter(app, breaker)
+	order.BindOrderRouter(app, orderRepo)
+	order.BindTradeRouter(app, tradeRepo)
+	order.BindAssetRouter(app, eventWriter, assetRepo)
+	order.BindPairRouter(app, eventWriter, pairRepo, books)
+	session.BindSessionRouter(app, sessions)
+	kline.BindKlineRouter(app, candleRepo, liveCandles)
+	depthhistory.BindDepthHistoryRouter(app, depthRepo)
+	if archiver != nil {
+		archive.BindArchiveRouter(app, archiver)
+	}
+	if exporter != nil {
+		export.BindExportRouter(bgCtx, app, exporter)
+	}
+	if brokerPublisher != nil {
+		broker.BindBrokerRouter(app, brokerPublisher)
+	}
+	if alertMonitor != nil {
+		alert.BindAlertRouter(app, alertMonitor)
+	}
+	if listener != nil {
+		pgnotify.BindOrderUpdatesRouter(app, listener)
+	}
+	if marketCache != nil {
+		marketdata.BindMarketDataRouter(app, marketCache)
+		marketdata.BindMarketStreamRouter(app, marketGateway, marketCache)
+	}
+
+	var adminApp *fiber.App
+	if cfg.Admin.Addr != "" {
+		runtime.SetMutexProfileFraction(cfg.Admin.MutexProfileFraction)
+		runtime.SetBlockProfileRate(cfg.Admin.BlockProfileRate)
+		adminApp = fiber.New(fiber.Config{
+			DisableStartupMessage: true,
+			ErrorHandler:          httperr.Handler,
+		})
+		diagnostics.BindDiagnosticsRouter(adminApp, books)
+		health.BindHealthDetailsRouter(adminApp, readiness, cfg.Health.CheckTimeout)
+		if journal != nil {
+			standby.BindStreamRouter(adminApp, books, journal, cfg.Standby.Token)
+		}
+		if follower != nil {
+			standby.BindFollowerRouter(adminApp, follower)
+		}
+		if elector != nil {
+			election.BindElectionRouter(adminApp, elector)
+		}
+		go func() {
+			if err := adminApp.Listen(cfg.Admin.Addr); err != nil {
+				exit(exitStartup, "failed to listen for admin clients", err)
+			}
+		}()
+	}
+
+	// With TLS the HTTP and websocket ports serve HTTPS and WSS
+	var tlsConfig *tls.Config
+	switch {
+	case cfg.HTTP.TLS.CertFile != "":
+		certs, err := tlscert.NewReloader(cfg.HTTP.TLS.CertFile, cfg.HTTP.TLS.KeyFile)
+		if err != nil {
+			exit(exitConfig, "failed to load the TLS certificate", err)
+		}
+		go certs.Run(bgCtx, cfg.HTTP.TLS.ReloadInterval)
+		tlsConfig = tlscert.Config(certs.GetCertificate)
+	case len(cfg.HTTP.TLS.ACMEDomains) > 0:
+		tlsConfig = tlscert.ACME(cfg.HTTP.TLS.ACMEDomains, cfg.HTTP.TLS.ACMEEmail, cfg.HTTP.TLS.ACMECacheDir)
+	}
+	listen := func(port int) error {
+		addr := ":" + strconv.Itoa(port)
+		if tlsConfig == nil {
+			return app.Listen(addr)
+		}
+		ln, err := tls.Listen("tcp", addr, tlsConfig)
+		if err != nil {
+			return err
+		}
+		return app.Listener(ln)
+	}
+
+	if cfg.HTTP.WSPort != 0 && cfg.HTTP.WSPort != cfg.HTTP.Port {
+		go func() {
+			if err := listen(cfg.HTTP.WSPort); err != nil {
+				exit(exitStartup, "failed to listen for websocket clients", err)
+			}
+		}()
+	}
+	// On a signal the listeners close and the websocket clients are told to
+	// go away while the requests in flight finish. httpStopped is closed once
+	// they did or ShutdownTimeout passed.
+	httpStopped := make(chan struct{})
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+		defer close(httpStopped)
+		applog.Info("shutting down", map[string]any{
+			"shutdown_timeout": cfg.HTTP.ShutdownTimeout,
+		})
+		readiness.Stopping()
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+		defer cancel()
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- app.ShutdownWithContext(ctx)
+		}()
+		closed := shutdown.CloseWebsockets(websocket.CloseGoingAway, "server shutting down")
+		applog.Info("closed the websocket connections", map[string]any{
+			"connections": closed,
+		})
+		if err := <-stopped; err != nil {
+			applog.Error("requests were still in flight at the shutdown timeout", map[string]any{
+				"error": err,
+			})
+		}
+	}()
+	readiness.Started()
+	if err := listen(cfg.HTTP.Port); err != nil {
+		exit(exitStartup, "failed to listen", err)
+	}
+	<-httpStopped
+
+	// No more orders come from HTTP, the engines are through with the queued
+	// ones before their history events are written
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.Engine.DrainTimeout)
+	defer cancelDrain()
+	if err := books.Drain(drainCtx); err != nil {
+		applog.Error("orders were still queued at the drain timeout", map[string]any{
+			"error": err,
+		})
+	}
+	if adminApp != nil {
+		adminApp.Shutdown()
+	}
+	// What the drained fills owe is queued, the running netting window
+	// settled and the ledger queue posted
+	feeAssets.Drain()
+	rebates.Drain()
+	nets.Close()
+	ledgerQueue.Drain()
+
+	// The queued order history events are written before exiting
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := eventWriter.Close(ctx); err != nil {
+		applog.Error("failed to write the queued order history events", map[string]any{
+			"error": err,
+		})
+	}
+	applog.Flush(ctx)
 }
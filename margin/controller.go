@@ -0,0 +1,127 @@
+package margin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+)
+
+var ErrFieldRequired = errors.New("ErrFieldRequired")
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+type enableRequest struct {
+	Leverage        decimal.Decimal `json:"leverage"`
+	CollateralAsset string          `json:"collateral_asset"`
+}
+
+func BindMarginRouter(r fiber.Router, engine *Engine) {
+	r.Put("/accounts/:id/margin", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+
+		var req enableRequest
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		if req.CollateralAsset == "" {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrFieldRequired,
+				Message: "Please provide a collateral_asset",
+			})
+		}
+
+		a, err := engine.Enable(c.UserContext(), accountId, req.Leverage, req.CollateralAsset)
+		if err == ErrLeverageTooHigh {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			marginLog.Error("failed to enable margin trading", map[string]any{
+				"account_id": accountId,
+				"error":      err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Margin trading enabled",
+			Data:    a,
+		})
+	})
+
+	r.Delete("/accounts/:id/margin", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		if err := engine.Disable(c.UserContext(), accountId); err != nil {
+			marginLog.Error("failed to disable margin trading", map[string]any{
+				"account_id": accountId,
+				"error":      err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Margin trading disabled",
+			Data:    nil,
+		})
+	})
+
+	r.Get("/accounts/:id/margin", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+
+		status, err := engine.GetStatus(accountId)
+		if err == ErrMarginNotEnabled {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			marginLog.Error("failed to get margin status", map[string]any{
+				"account_id": accountId,
+				"error":      err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data: map[string]any{
+				"status":       status,
+				"margin_calls": engine.GetEvents(accountId),
+			},
+		})
+	})
+}
@@ -0,0 +1,369 @@
+package margin
+
+import (
+	"context"
+	"errors"
+	"math"
+	"order-book/account"
+	"order-book/logger"
+	"order-book/order"
+	"order-book/position"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInsufficientMargin = errors.New("Insufficient margin for this order")
+	ErrLeverageTooHigh    = errors.New("Requested leverage is above the allowed limit")
+	ErrMarginNotEnabled   = errors.New("Margin trading is not enabled for this account")
+)
+
+// maxEventsPerAccount bounds the margin call history kept per account
+const maxEventsPerAccount = 50
+
+var marginLog = logger.Component("margin")
+
+// Limits are the margin parameters of a pair. The initial margin rate of an
+// account is 1/leverage, which can't go above MaxLeverage.
+type Limits struct {
+	MaxLeverage           float64 `json:"max_leverage"`
+	MaintenanceMarginRate float64 `json:"maintenance_margin_rate"`
+}
+
+type Account struct {
+	AccountID       int             `json:"account_id"`
+	Leverage        decimal.Decimal `json:"leverage"`
+	CollateralAsset string          `json:"collateral_asset"`
+}
+
+type Status struct {
+	Account
+	Equity            decimal.Decimal `json:"equity"`
+	PositionNotional  decimal.Decimal `json:"position_notional"`
+	InitialMargin     decimal.Decimal `json:"initial_margin"`
+	MaintenanceMargin decimal.Decimal `json:"maintenance_margin"`
+	// MarginLevel is equity over maintenance margin, below 1 the account is in margin call
+	MarginLevel decimal.Decimal `json:"margin_level"`
+	MarginCall  bool            `json:"margin_call"`
+}
+
+type MarginCallEvent struct {
+	AccountID         int             `json:"account_id"`
+	PairID            string          `json:"pair_id"`
+	Equity            decimal.Decimal `json:"equity"`
+	MaintenanceMargin decimal.Decimal `json:"maintenance_margin"`
+	At                time.Time       `json:"at"`
+}
+
+type Book interface {
+	GetAccountOrders(accountId int) []order.Order
+}
+
+type Engine struct {
+	mu           sync.RWMutex
+	accounts     map[int]*Account
+	inMarginCall map[int]bool
+	events       map[int][]MarginCallEvent
+	listeners    []func(ev MarginCallEvent)
+	// limitsMu guards the limits apart from mu, they're read with it held
+	limitsMu      sync.RWMutex
+	limits        map[string]Limits
+	defaultLimits Limits
+	balances      account.BalanceRepo
+	positions     *position.Tracker
+	book          Book
+	repo          Repo
+	markUpdates   chan string
+}
+
+// NewEngine loads the margin accounts kept by repo
+func NewEngine(balances account.BalanceRepo, positions *position.Tracker, book Book, repo Repo, defaultLimits Limits, limits map[string]Limits) (*Engine, error) {
+	saved, err := repo.GetAccounts(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	accounts := make(map[int]*Account, len(saved))
+	for _, a := range saved {
+		accounts[a.AccountID] = &a
+	}
+	e := &Engine{
+		accounts:      accounts,
+		inMarginCall:  make(map[int]bool),
+		events:        make(map[int][]MarginCallEvent),
+		limits:        limits,
+		defaultLimits: defaultLimits,
+		balances:      balances,
+		positions:     positions,
+		book:          book,
+		repo:          repo,
+		markUpdates:   make(chan string, 1024),
+	}
+
+	// Evaluations read balances from the database, so they run off the matching goroutine
+	go func() {
+		for pairId := range e.markUpdates {
+			e.evaluatePair(pairId)
+		}
+	}()
+
+	return e, nil
+}
+
+func (e *Engine) LimitsFor(pairId string) Limits {
+	e.limitsMu.RLock()
+	defer e.limitsMu.RUnlock()
+	if l, ok := e.limits[pairId]; ok {
+		return l
+	}
+	return e.defaultLimits
+}
+
+// SetLimits replaces the default and per pair limits. Accounts keep their
+// leverage, the new limits apply to their next orders and evaluations.
+func (e *Engine) SetLimits(defaultLimits Limits, limits map[string]Limits) {
+	e.limitsMu.Lock()
+	defer e.limitsMu.Unlock()
+	e.defaultLimits = defaultLimits
+	e.limits = limits
+}
+
+// Enable switches an account to margin mode with the given leverage
+func (e *Engine) Enable(ctx context.Context, accountId int, leverage decimal.Decimal, collateralAsset string) (Account, error) {
+	e.limitsMu.RLock()
+	maxLeverage := e.defaultLimits.MaxLeverage
+	for _, l := range e.limits {
+		maxLeverage = math.Max(maxLeverage, l.MaxLeverage)
+	}
+	e.limitsMu.RUnlock()
+	if leverage.LessThan(decimal.NewFromInt(1)) || leverage.GreaterThan(decimal.NewFromFloat(maxLeverage)) {
+		return Account{}, ErrLeverageTooHigh
+	}
+
+	a := Account{
+		AccountID:       accountId,
+		Leverage:        leverage,
+		CollateralAsset: strings.ToLower(collateralAsset),
+	}
+	if err := e.repo.SetAccount(ctx, a); err != nil {
+		return Account{}, err
+	}
+	e.mu.Lock()
+	e.accounts[accountId] = &a
+	e.mu.Unlock()
+
+	marginLog.Info("margin trading enabled", map[string]any{
+		"account_id": accountId,
+		"leverage":   leverage.String(),
+		"collateral": a.CollateralAsset,
+	})
+	return a, nil
+}
+
+func (e *Engine) Disable(ctx context.Context, accountId int) error {
+	if err := e.repo.RemoveAccount(ctx, accountId); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.accounts, accountId)
+	delete(e.inMarginCall, accountId)
+	return nil
+}
+
+func (e *Engine) OnMarginCall(fn func(ev MarginCallEvent)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.listeners = append(e.listeners, fn)
+}
+
+// ValidateOrder is a book.OrderValidator checking the initial margin of margin
+// accounts against their exposure including open orders and the new order.
+// An order only reducing a position passes, so accounts in margin call and
+// their liquidations can always close it.
+func (e *Engine) ValidateOrder(o order.Order) error {
+	e.mu.RLock()
+	a, ok := e.accounts[o.AccountID]
+	e.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	if e.reduces(o) {
+		return nil
+	}
+
+	limits := e.LimitsFor(o.PairID)
+	if a.Leverage.GreaterThan(decimal.NewFromFloat(limits.MaxLeverage)) {
+		return ErrLeverageTooHigh
+	}
+
+	status, err := e.status(*a)
+	if err != nil {
+		return err
+	}
+	exposure := status.PositionNotional.Add(o.Price.Mul(o.Amount))
+	for _, open := range e.book.GetAccountOrders(o.AccountID) {
+		exposure = exposure.Add(open.Price.Mul(open.Amount))
+	}
+	if status.Equity.LessThan(exposure.Div(a.Leverage)) {
+		return ErrInsufficientMargin
+	}
+	return nil
+}
+
+// reduces tells whether an order trades against a position of its account
+// for at most its size
+func (e *Engine) reduces(o order.Order) bool {
+	for _, p := range e.positions.GetPositions(o.AccountID) {
+		if p.PairID != o.PairID {
+			continue
+		}
+		if o.Type == order.ASK {
+			return p.Quantity.IsPositive() && o.Amount.LessThanOrEqual(p.Quantity)
+		}
+		return p.Quantity.IsNegative() && o.Amount.LessThanOrEqual(p.Quantity.Neg())
+	}
+	return false
+}
+
+// OnTrade treats every trade as a mark price update for its pair
+func (e *Engine) OnTrade(t order.Trade) {
+	e.MarkUpdated(t.PairID)
+}
+
+// MarkUpdated evaluates the accounts with a position on a pair whose mark
+// price moved
+func (e *Engine) MarkUpdated(pairId string) {
+	select {
+	case e.markUpdates <- pairId:
+	default:
+		marginLog.Warn("margin evaluation queue is full, skipping mark update", map[string]any{
+			"pair_id": pairId,
+		})
+	}
+}
+
+func (e *Engine) GetStatus(accountId int) (Status, error) {
+	e.mu.RLock()
+	a, ok := e.accounts[accountId]
+	e.mu.RUnlock()
+	if !ok {
+		return Status{}, ErrMarginNotEnabled
+	}
+	return e.status(*a)
+}
+
+func (e *Engine) GetEvents(accountId int) []MarginCallEvent {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]MarginCallEvent(nil), e.events[accountId]...)
+}
+
+func (e *Engine) evaluatePair(pairId string) {
+	e.mu.RLock()
+	accounts := make([]Account, 0, len(e.accounts))
+	for _, a := range e.accounts {
+		accounts = append(accounts, *a)
+	}
+	e.mu.RUnlock()
+
+	for _, a := range accounts {
+		exposed := false
+		for _, p := range e.positions.GetPositions(a.AccountID) {
+			if p.PairID == pairId && !p.Quantity.IsZero() {
+				exposed = true
+				break
+			}
+		}
+		if !exposed {
+			continue
+		}
+
+		status, err := e.status(a)
+		if err != nil {
+			marginLog.Error("failed to evaluate margin", map[string]any{
+				"account_id": a.AccountID,
+				"error":      err,
+			})
+			continue
+		}
+		e.transition(status, pairId)
+	}
+}
+
+// transition emits a margin call event when an account first drops below
+// maintenance, and clears the flag once it recovers.
+func (e *Engine) transition(status Status, pairId string) {
+	e.mu.Lock()
+	wasInCall := e.inMarginCall[status.AccountID]
+	if !status.MarginCall {
+		delete(e.inMarginCall, status.AccountID)
+		e.mu.Unlock()
+		return
+	}
+	if wasInCall {
+		e.mu.Unlock()
+		return
+	}
+
+	ev := MarginCallEvent{
+		AccountID:         status.AccountID,
+		PairID:            pairId,
+		Equity:            status.Equity,
+		MaintenanceMargin: status.MaintenanceMargin,
+		At:                time.Now(),
+	}
+	e.inMarginCall[status.AccountID] = true
+	events := append(e.events[status.AccountID], ev)
+	if len(events) > maxEventsPerAccount {
+		events = events[len(events)-maxEventsPerAccount:]
+	}
+	e.events[status.AccountID] = events
+	listeners := e.listeners
+	e.mu.Unlock()
+
+	marginLog.Warn("margin call", map[string]any{
+		"account_id":         ev.AccountID,
+		"pair_id":            ev.PairID,
+		"equity":             ev.Equity.String(),
+		"maintenance_margin": ev.MaintenanceMargin.String(),
+	})
+	for _, fn := range listeners {
+		fn(ev)
+	}
+}
+
+// status computes equity as the collateral balance plus the unrealized PnL of
+// positions quoted in the collateral asset.
+func (e *Engine) status(a Account) (Status, error) {
+	s := Status{Account: a}
+
+	balances, err := e.balances.GetBalances(a.AccountID)
+	if err != nil {
+		return s, err
+	}
+	for _, b := range balances {
+		if strings.ToLower(b.Asset) == a.CollateralAsset {
+			s.Equity = s.Equity.Add(b.Available).Add(b.Held)
+		}
+	}
+
+	for _, p := range e.positions.GetPositions(a.AccountID) {
+		if _, quote, ok := order.SplitPairID(p.PairID); !ok || quote != a.CollateralAsset {
+			continue
+		}
+		notional := p.Quantity.Abs().Mul(p.MarkPrice)
+		s.Equity = s.Equity.Add(p.UnrealizedPnL)
+		s.PositionNotional = s.PositionNotional.Add(notional)
+		s.InitialMargin = s.InitialMargin.Add(notional.Div(a.Leverage))
+		s.MaintenanceMargin = s.MaintenanceMargin.Add(notional.Mul(decimal.NewFromFloat(e.LimitsFor(p.PairID).MaintenanceMarginRate)))
+	}
+
+	if s.MaintenanceMargin.IsPositive() {
+		s.MarginLevel = s.Equity.Div(s.MaintenanceMargin)
+		s.MarginCall = s.Equity.LessThan(s.MaintenanceMargin)
+	}
+	return s, nil
+}
@@ -0,0 +1,69 @@
+package margin
+
+import (
+	"context"
+	repository "order-book/margin/repository/gen"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Repo keeps the accounts trading on margin
+type Repo interface {
+	GetAccounts(ctx context.Context) ([]Account, error)
+	SetAccount(ctx context.Context, a Account) error
+	RemoveAccount(ctx context.Context, accountId int) error
+}
+
+type repo struct {
+	queries      *repository.Queries
+	queryTimeout time.Duration
+}
+
+func (repo *repo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *repo) GetAccounts(ctx context.Context) ([]Account, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	dbres, err := repo.queries.GetMarginAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]Account, 0, len(dbres))
+	for _, a := range dbres {
+		accounts = append(accounts, Account{
+			AccountID:       int(a.AccountID),
+			Leverage:        a.Leverage,
+			CollateralAsset: a.CollateralAsset,
+		})
+	}
+	return accounts, nil
+}
+
+func (repo *repo) SetAccount(ctx context.Context, a Account) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.UpsertMarginAccount(ctx, repository.UpsertMarginAccountParams{
+		AccountID:       int32(a.AccountID),
+		Leverage:        a.Leverage,
+		CollateralAsset: a.CollateralAsset,
+	})
+}
+
+func (repo *repo) RemoveAccount(ctx context.Context, accountId int) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.DeleteMarginAccount(ctx, int32(accountId))
+}
+
+func NewRepository(dbpool *pgxpool.Pool, queryTimeout time.Duration) Repo {
+	return &repo{
+		queries:      repository.New(dbpool),
+		queryTimeout: queryTimeout,
+	}
+}
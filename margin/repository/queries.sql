@@ -0,0 +1,9 @@
+-- name: GetMarginAccounts :many
+SELECT * FROM tbl_margin_accounts ORDER BY account_id;
+
+-- name: UpsertMarginAccount :exec
+INSERT INTO tbl_margin_accounts (account_id, leverage, collateral_asset) VALUES ($1, $2, $3)
+ON CONFLICT (account_id) DO UPDATE SET leverage = EXCLUDED.leverage, collateral_asset = EXCLUDED.collateral_asset, updated_at = NOW();
+
+-- name: DeleteMarginAccount :exec
+DELETE FROM tbl_margin_accounts WHERE account_id = $1;
@@ -0,0 +1,7 @@
+CREATE TABLE tbl_margin_accounts
+(
+    account_id INT PRIMARY KEY,
+    leverage NUMERIC NOT NULL,
+    collateral_asset VARCHAR(25) NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,65 @@
+package margin
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type sqliteRepo struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func (repo *sqliteRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *sqliteRepo) GetAccounts(ctx context.Context) ([]Account, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	rows, err := repo.db.QueryContext(ctx, "SELECT account_id, leverage, collateral_asset FROM tbl_margin_accounts ORDER BY account_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var accounts []Account
+	for rows.Next() {
+		var a Account
+		if err := rows.Scan(&a.AccountID, &a.Leverage, &a.CollateralAsset); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+func (repo *sqliteRepo) SetAccount(ctx context.Context, a Account) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	now := time.Now().UTC()
+	_, err := repo.db.ExecContext(ctx,
+		`INSERT INTO tbl_margin_accounts (account_id, leverage, collateral_asset, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (account_id) DO UPDATE SET leverage = excluded.leverage, collateral_asset = excluded.collateral_asset, updated_at = excluded.updated_at`,
+		a.AccountID, a.Leverage.String(), a.CollateralAsset, now,
+	)
+	return err
+}
+
+func (repo *sqliteRepo) RemoveAccount(ctx context.Context, accountId int) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err := repo.db.ExecContext(ctx, "DELETE FROM tbl_margin_accounts WHERE account_id = ?", accountId)
+	return err
+}
+
+// NewSQLiteRepository is NewRepository on a SQLite database
+func NewSQLiteRepository(db *sql.DB, queryTimeout time.Duration) Repo {
+	return &sqliteRepo{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
@@ -0,0 +1,183 @@
+package marketdata
+
+import (
+	"context"
+	"net/http"
+	"order-book/logger"
+	"order-book/metrics"
+	"order-book/panics"
+	"order-book/shutdown"
+	"order-book/tenant"
+	"order-book/wslimit"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// wsLog logs the market data stream as part of ws
+var wsLog = logger.Component("ws")
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindMarketDataRouter serves market data from the cache, so any API replica
+// can answer without reaching the node running the book
+func BindMarketDataRouter(r fiber.Router, cache Cache) {
+	r.Get("/market/:pair_id/depth", func(c *fiber.Ctx) error {
+		pairId := c.Params("pair_id")
+		depth, err := cache.GetDepth(c.UserContext(), tenant.FromCtx(c).ID, pairId)
+		if err != nil {
+			return respond(c, pairId, nil, err)
+		}
+		if levels := c.QueryInt("levels", 0); levels > 0 {
+			depth.Asks = depth.Asks[:min(levels, len(depth.Asks))]
+			depth.Bids = depth.Bids[:min(levels, len(depth.Bids))]
+		}
+		return respond(c, pairId, depth, nil)
+	})
+
+	r.Get("/market/:pair_id/ticker", func(c *fiber.Ctx) error {
+		pairId := c.Params("pair_id")
+		ticker, err := cache.GetTicker(c.UserContext(), tenant.FromCtx(c).ID, pairId)
+		return respond(c, pairId, ticker, err)
+	})
+
+	r.Get("/market/:pair_id/trades", func(c *fiber.Ctx) error {
+		pairId := c.Params("pair_id")
+		trades, err := cache.GetTrades(c.UserContext(), tenant.FromCtx(c).ID, pairId)
+		if err != nil {
+			return respond(c, pairId, nil, err)
+		}
+		if limit := c.QueryInt("limit", 0); limit > 0 {
+			trades = trades[:min(limit, len(trades))]
+		}
+		return respond(c, pairId, trades, nil)
+	})
+}
+
+// BindMarketStreamRouter streams the market data of a pair from the gateway.
+// The cached depth and ticker are sent first, the depth diffs then apply to
+// the depth whose seq matches their from_seq.
+func BindMarketStreamRouter(r fiber.Router, gateway *Gateway, cache Cache) {
+	r.Get("/ws/market/:pair_id", func(c *fiber.Ctx) error {
+		// The websocket connection doesn't carry the fiber context
+		c.Locals("tenant_id", tenant.FromCtx(c).ID)
+		return c.Next()
+	}, websocket.New(func(c *websocket.Conn) {
+		defer panics.Recover("ws", map[string]any{"stream": "market_data"})
+		defer shutdown.TrackWebsocket(c)()
+		defer wslimit.Release(c)
+		defer c.Close()
+		stream := metrics.Stream("market_data")
+		stream.Connected()
+		reason := metrics.DisconnectClientClosed
+		defer func() { stream.Disconnected(reason) }()
+		tenantId := c.Locals("tenant_id").(string)
+		pairId := c.Params("pair_id")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		// Subscribing first so nothing is missed between the snapshot and the stream
+		updates, err := gateway.Subscribe(ctx, tenantId, pairId)
+		if err != nil {
+			wsLog.Error("failed to subscribe to market data", map[string]any{
+				"pair_id": pairId,
+				"error":   err,
+			})
+			c.WriteJSON(&Response{
+				Message: "Market data is unavailable",
+			})
+			reason = metrics.DisconnectUnavailable
+			return
+		}
+		defer gateway.Unsubscribe(tenantId, pairId, updates)
+		stream.Subscribed()
+		defer stream.Unsubscribed()
+
+		sendSnapshot := func(ctx context.Context) {
+			if depth, err := cache.GetDepth(ctx, tenantId, pairId); err == nil {
+				if c.WriteJSON(&Update{Type: DEPTH, PairID: pairId, Data: depth}) == nil {
+					stream.Sent()
+				}
+			}
+			if ticker, err := cache.GetTicker(ctx, tenantId, pairId); err == nil {
+				if c.WriteJSON(&Update{Type: TICKER, PairID: pairId, Data: ticker}) == nil {
+					stream.Sent()
+				}
+			}
+		}
+		sendSnapshot(ctx)
+
+		// The stream is read-only, reading only detects the client going away
+		closed := make(chan struct{})
+		go func() {
+			defer panics.Recover("ws", map[string]any{"stream": "market_data"})
+			defer close(closed)
+			for {
+				if _, _, err := c.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		ticker := time.NewTicker(time.Second * 30)
+		defer ticker.Stop()
+		for {
+			select {
+			case payload, ok := <-updates.C:
+				if !ok {
+					c.WriteJSON(&Response{
+						Message: "Client fell behind, reconnect to get a new snapshot",
+					})
+					reason = metrics.DisconnectSlowConsumer
+					return
+				}
+				if err := c.WriteMessage(websocket.TextMessage, payload); err != nil {
+					reason = metrics.DisconnectWriteError
+					return
+				}
+				stream.Sent()
+			case <-updates.Resync:
+				// The updates the client missed are conflated into the
+				// latest snapshot
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				sendSnapshot(ctx)
+				cancel()
+			case <-ticker.C:
+				err := c.WriteControl(websocket.PingMessage, []byte("Ping message"), time.Now().Add(5*time.Second))
+				if err != nil {
+					reason = metrics.DisconnectPingFailed
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	}))
+}
+
+func respond(c *fiber.Ctx, pairId string, data any, err error) error {
+	if err == ErrNotCached {
+		c.Status(http.StatusNotFound)
+		return c.JSON(&Response{
+			Message: "No market data for the pair",
+			Data:    nil,
+		})
+	}
+	if err != nil {
+		logger.Error("failed to read cached market data", map[string]any{
+			"pair_id": pairId,
+			"error":   err,
+		})
+		return err
+	}
+	c.Status(http.StatusOK)
+	return c.JSON(&Response{
+		Message: "",
+		Data:    data,
+	})
+}
@@ -0,0 +1,210 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/json"
+	"order-book/logger"
+	"order-book/metrics"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Types of the streamed updates
+const (
+	DEPTH      = "DEPTH"
+	DEPTH_DIFF = "DEPTH_DIFF"
+	TRADE      = "TRADE"
+	TICKER     = "TICKER"
+)
+
+// Update is a message of the market data stream of a pair, Data holds a
+// Depth, a DepthDiff, a PublicTrade or a Ticker depending on Type
+type Update struct {
+	Type   string `json:"type"`
+	PairID string `json:"pair_id"`
+	Data   any    `json:"data"`
+}
+
+// Broadcaster sends the updates of a pair to the gateways streaming it
+type Broadcaster interface {
+	Broadcast(ctx context.Context, tenantId string, updates []Update) error
+}
+
+func channel(tenantId string, pairId string) string {
+	return "md:" + tenantId + ":stream:" + pairId
+}
+
+type redisBroadcaster struct {
+	client *redis.Client
+}
+
+// NewRedisBroadcaster publishes updates as JSON on md:<tenant>:stream:<pair>
+// channels
+func NewRedisBroadcaster(client *redis.Client) Broadcaster {
+	return &redisBroadcaster{client: client}
+}
+
+func (b *redisBroadcaster) Broadcast(ctx context.Context, tenantId string, updates []Update) error {
+	_, err := b.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, u := range updates {
+			data, err := json.Marshal(u)
+			if err != nil {
+				return err
+			}
+			pipe.Publish(ctx, channel(tenantId, u.PairID), data)
+		}
+		return nil
+	})
+	return err
+}
+
+// Policies for a subscriber whose send queue is full when an update comes
+const (
+	// POLICY_DISCONNECT closes the subscription, the client reconnects for a
+	// new snapshot
+	POLICY_DISCONNECT = "disconnect"
+	// POLICY_DROP_OLDEST drops the oldest queued update to make room, the
+	// client sees a gap in the from_seq of the depth diffs
+	POLICY_DROP_OLDEST = "drop_oldest"
+	// POLICY_CONFLATE drops the queued updates and sends the client the
+	// cached depth and ticker instead, the latest state replaces what it missed
+	POLICY_CONFLATE = "conflate"
+)
+
+// Subscription is the stream of the updates of a pair to one client. C is
+// closed if the subscriber falls behind under POLICY_DISCONNECT, Resync
+// receives when it should be sent a snapshot under POLICY_CONFLATE.
+type Subscription struct {
+	C      chan []byte
+	Resync chan struct{}
+}
+
+// Gateway serves the updates broadcast by every engine node to the
+// websocket clients of this one. A Redis channel is only subscribed to while
+// a client streams its pair, and payloads are forwarded as they were sent.
+type Gateway struct {
+	pubsub      *redis.PubSub
+	queueSize   int
+	policy      string
+	mu          sync.Mutex
+	subscribers map[string]map[*Subscription]struct{}
+}
+
+// NewGateway queues up to queueSize updates for each subscriber, policy is
+// what happens once a subscriber's queue is full
+func NewGateway(client *redis.Client, queueSize int, policy string) *Gateway {
+	return &Gateway{
+		pubsub:      client.Subscribe(context.Background()),
+		queueSize:   queueSize,
+		policy:      policy,
+		subscribers: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Run forwards the received updates until ctx is done. The client
+// reconnects and subscribes again by itself after a failure.
+func (g *Gateway) Run(ctx context.Context) {
+	defer g.pubsub.Close()
+	messages := g.pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			g.publish(msg.Channel, []byte(msg.Payload))
+		}
+	}
+}
+
+// publish applies the policy to subscribers that can't keep up instead of
+// holding the other ones back
+func (g *Gateway) publish(ch string, payload []byte) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for sub := range g.subscribers[ch] {
+		select {
+		case sub.C <- payload:
+			continue
+		default:
+		}
+		metrics.Stream("market_data").Overflowed()
+		switch g.policy {
+		case POLICY_DROP_OLDEST:
+			// The subscriber may have made room meanwhile, the update is
+			// dropped rather than block
+			select {
+			case <-sub.C:
+			default:
+			}
+			select {
+			case sub.C <- payload:
+			default:
+			}
+		case POLICY_CONFLATE:
+			for len(sub.C) > 0 {
+				select {
+				case <-sub.C:
+				default:
+				}
+			}
+			select {
+			case sub.Resync <- struct{}{}:
+			default:
+			}
+		default:
+			logger.Warn("market data subscriber is too slow, disconnecting", map[string]any{
+				"channel": ch,
+			})
+			g.remove(ch, sub)
+		}
+	}
+}
+
+// Subscribe returns the subscription to the updates of a pair
+func (g *Gateway) Subscribe(ctx context.Context, tenantId string, pairId string) (*Subscription, error) {
+	ch := channel(tenantId, pairId)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.subscribers[ch] == nil {
+		if err := g.pubsub.Subscribe(ctx, ch); err != nil {
+			return nil, err
+		}
+		g.subscribers[ch] = make(map[*Subscription]struct{})
+	}
+	sub := &Subscription{
+		C:      make(chan []byte, g.queueSize),
+		Resync: make(chan struct{}, 1),
+	}
+	g.subscribers[ch][sub] = struct{}{}
+	return sub, nil
+}
+
+func (g *Gateway) Unsubscribe(tenantId string, pairId string, sub *Subscription) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.remove(channel(tenantId, pairId), sub)
+}
+
+// remove closes a subscription and leaves the Redis channel after its last
+// subscriber, g.mu must be held
+func (g *Gateway) remove(ch string, sub *Subscription) {
+	if _, ok := g.subscribers[ch][sub]; !ok {
+		return
+	}
+	delete(g.subscribers[ch], sub)
+	close(sub.C)
+	if len(g.subscribers[ch]) > 0 {
+		return
+	}
+	delete(g.subscribers, ch)
+	if err := g.pubsub.Unsubscribe(context.Background(), ch); err != nil {
+		logger.Warn("failed to unsubscribe from market data", map[string]any{
+			"channel": ch,
+			"error":   err,
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"order-book/order"
+	"slices"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrNotCached = errors.New("Market data not cached")
+
+// Level is the total amount resting at a price
+type Level struct {
+	Price  decimal.Decimal `json:"price"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// Depth is the aggregated book of a pair, best prices first: asks ascending
+// and bids descending. Seq is the engine event the book reflects.
+type Depth struct {
+	PairID    string    `json:"pair_id"`
+	Seq       int64     `json:"seq"`
+	Asks      []Level   `json:"asks"`
+	Bids      []Level   `json:"bids"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Ticker sums up the last 24 hours of a pair, a pair without trades in that
+// window only has its best prices set
+type Ticker struct {
+	PairID         string          `json:"pair_id"`
+	LastPrice      decimal.Decimal `json:"last_price"`
+	BestBid        decimal.Decimal `json:"best_bid"`
+	BestAsk        decimal.Decimal `json:"best_ask"`
+	Open24h        decimal.Decimal `json:"open_24h"`
+	High24h        decimal.Decimal `json:"high_24h"`
+	Low24h         decimal.Decimal `json:"low_24h"`
+	Volume24h      decimal.Decimal `json:"volume_24h"`
+	QuoteVolume24h decimal.Decimal `json:"quote_volume_24h"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// PublicTrade is a fill as anyone watching the pair sees it, without the
+// orders and accounts involved
+type PublicTrade struct {
+	ID         int64           `json:"id"`
+	PairID     string          `json:"pair_id"`
+	Price      decimal.Decimal `json:"price"`
+	Amount     decimal.Decimal `json:"amount"`
+	TakerSide  order.OrderType `json:"taker_side"`
+	ExecutedAt time.Time       `json:"executed_at"`
+}
+
+// Cache holds the rendered market data of every tenant for the API replicas
+// that don't run the books. Entries expire shortly after the engine stops
+// refreshing them, Get methods return ErrNotCached for missing entries.
+type Cache interface {
+	PutDepth(ctx context.Context, tenantId string, d Depth) error
+	GetDepth(ctx context.Context, tenantId string, pairId string) (Depth, error)
+	PutTicker(ctx context.Context, tenantId string, t Ticker) error
+	GetTicker(ctx context.Context, tenantId string, pairId string) (Ticker, error)
+	// PutTrades replaces the recent trades of a pair, the most recent first
+	PutTrades(ctx context.Context, tenantId string, pairId string, trades []PublicTrade) error
+	GetTrades(ctx context.Context, tenantId string, pairId string) ([]PublicTrade, error)
+}
+
+// aggregate sums the orders of each level, levels are expected best first
+func aggregate(levels []order.PriceLevel, limit int) []Level {
+	res := make([]Level, 0, min(limit, len(levels)))
+	for _, level := range levels {
+		if len(res) == limit {
+			break
+		}
+		amount := decimal.Zero
+		for _, o := range level.Orders {
+			amount = amount.Add(o.Amount)
+		}
+		res = append(res, Level{Price: level.Price, Amount: amount})
+	}
+	return res
+}
+
+// DepthDiff holds the levels that changed between two depths of a pair, a
+// zero amount removes the level. Applying it to the depth at FromSeq gives
+// the depth at Seq.
+type DepthDiff struct {
+	PairID  string    `json:"pair_id"`
+	FromSeq int64     `json:"from_seq"`
+	Seq     int64     `json:"seq"`
+	Asks    []Level   `json:"asks"`
+	Bids    []Level   `json:"bids"`
+	At      time.Time `json:"at"`
+}
+
+// Empty reports whether no level changed
+func (d DepthDiff) Empty() bool {
+	return len(d.Asks) == 0 && len(d.Bids) == 0
+}
+
+// Diff returns the changes from prev to next
+func Diff(prev Depth, next Depth) DepthDiff {
+	return DepthDiff{
+		PairID:  next.PairID,
+		FromSeq: prev.Seq,
+		Seq:     next.Seq,
+		Asks:    diffLevels(prev.Asks, next.Asks),
+		Bids:    diffLevels(prev.Bids, next.Bids),
+		At:      next.UpdatedAt,
+	}
+}
+
+func diffLevels(prev []Level, next []Level) []Level {
+	amounts := make(map[string]decimal.Decimal, len(prev))
+	for _, level := range prev {
+		amounts[level.Price.String()] = level.Amount
+	}
+	var changed []Level
+	for _, level := range next {
+		key := level.Price.String()
+		if amount, ok := amounts[key]; !ok || !amount.Equal(level.Amount) {
+			changed = append(changed, level)
+		}
+		delete(amounts, key)
+	}
+	for _, level := range prev {
+		if _, removed := amounts[level.Price.String()]; removed {
+			changed = append(changed, Level{Price: level.Price, Amount: decimal.Zero})
+		}
+	}
+	return changed
+}
+
+// Apply returns depth with the changes of the diff, depth is expected to
+// be at FromSeq
+func (d DepthDiff) Apply(depth Depth) Depth {
+	return Depth{
+		PairID:    depth.PairID,
+		Seq:       d.Seq,
+		Asks:      applyLevels(depth.Asks, d.Asks, func(a, b decimal.Decimal) bool { return a.LessThan(b) }),
+		Bids:      applyLevels(depth.Bids, d.Bids, func(a, b decimal.Decimal) bool { return a.GreaterThan(b) }),
+		UpdatedAt: d.At,
+	}
+}
+
+// applyLevels keeps the levels in the order better gives
+func applyLevels(levels []Level, changed []Level, better func(a, b decimal.Decimal) bool) []Level {
+	amounts := make(map[string]Level, len(levels)+len(changed))
+	for _, level := range levels {
+		amounts[level.Price.String()] = level
+	}
+	for _, level := range changed {
+		if level.Amount.IsZero() {
+			delete(amounts, level.Price.String())
+			continue
+		}
+		amounts[level.Price.String()] = level
+	}
+	res := slices.Collect(maps.Values(amounts))
+	slices.SortFunc(res, func(a, b Level) int {
+		if better(a.Price, b.Price) {
+			return -1
+		}
+		if better(b.Price, a.Price) {
+			return 1
+		}
+		return 0
+	})
+	return res
+}
+
+// RenderDepth keeps the best levels of a snapshot, whose levels are in
+// ascending price order. A limit of 0 keeps every level.
+func RenderDepth(snap order.BookSnapshot, limit int) Depth {
+	if limit <= 0 {
+		limit = max(len(snap.Asks), len(snap.Bids))
+	}
+	bids := make([]order.PriceLevel, len(snap.Bids))
+	for idx, level := range snap.Bids {
+		bids[len(bids)-1-idx] = level
+	}
+	return Depth{
+		PairID:    snap.PairID,
+		Seq:       snap.Seq,
+		Asks:      aggregate(snap.Asks, limit),
+		Bids:      aggregate(bids, limit),
+		UpdatedAt: snap.CreatedAt,
+	}
+}
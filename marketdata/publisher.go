@@ -0,0 +1,271 @@
+package marketdata
+
+import (
+	"context"
+	"order-book/book"
+	"order-book/logger"
+	"order-book/order"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Publisher renders the market data of the books run by this node into the
+// cache. Pairs that changed are written every interval, and every pair is
+// rewritten before its entries expire so idle pairs stay served. With a
+// broadcaster, the new trades, the depth diff and the ticker of each written
+// pair are streamed to the gateways as well.
+type Publisher struct {
+	cache        Cache
+	broadcaster  Broadcaster
+	interval     time.Duration
+	ttl          time.Duration
+	levels       int
+	recentTrades int
+}
+
+// NewPublisher creates a publisher, broadcaster can be nil to only fill the cache
+func NewPublisher(cache Cache, broadcaster Broadcaster, interval time.Duration, ttl time.Duration, levels int, recentTrades int) *Publisher {
+	return &Publisher{
+		cache:        cache,
+		broadcaster:  broadcaster,
+		interval:     interval,
+		ttl:          ttl,
+		levels:       levels,
+		recentTrades: recentTrades,
+	}
+}
+
+// bucket holds the trades of one minute
+type bucket struct {
+	minute      int64
+	open        decimal.Decimal
+	high        decimal.Decimal
+	low         decimal.Decimal
+	volume      decimal.Decimal
+	quoteVolume decimal.Decimal
+}
+
+type pairState struct {
+	dirty bool
+	// trades are the most recent first
+	trades []PublicTrade
+	// buckets cover the last 24 hours in ascending minutes
+	buckets []bucket
+	// pending are the trades not broadcast yet, the oldest first
+	pending []PublicTrade
+}
+
+type feed struct {
+	publisher *Publisher
+	tenantId  string
+	book      book.Book
+	mu        sync.Mutex
+	pairs     map[string]*pairState
+	// sent is the last broadcast depth of each pair, only used by run
+	sent map[string]Depth
+}
+
+// Attach starts publishing the book of a tenant, it is meant to be run from
+// a Registry.OnBook hook
+func (p *Publisher) Attach(tenantId string, b book.Book) {
+	f := &feed{
+		publisher: p,
+		tenantId:  tenantId,
+		book:      b,
+		pairs:     make(map[string]*pairState),
+		sent:      make(map[string]Depth),
+	}
+	b.OnTrade(f.onTrade)
+	b.OnOrderEvent(func(ev order.OrderEvent) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.pair(ev.Order.PairID).dirty = true
+	})
+	go f.run()
+}
+
+// pair returns the state of a pair, f.mu must be held
+func (f *feed) pair(pairId string) *pairState {
+	s, ok := f.pairs[pairId]
+	if !ok {
+		s = &pairState{}
+		f.pairs[pairId] = s
+	}
+	return s
+}
+
+func (f *feed) onTrade(t order.Trade) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.pair(t.PairID)
+	s.dirty = true
+
+	trade := PublicTrade{
+		ID:         t.ID,
+		PairID:     t.PairID,
+		Price:      t.Price,
+		Amount:     t.Amount,
+		TakerSide:  t.TakerSide,
+		ExecutedAt: t.ExecutedAt,
+	}
+	s.trades = slices.Insert(s.trades, 0, trade)
+	if len(s.trades) > f.publisher.recentTrades {
+		s.trades = s.trades[:f.publisher.recentTrades]
+	}
+	if f.publisher.broadcaster != nil {
+		s.pending = append(s.pending, trade)
+		if over := len(s.pending) - f.publisher.recentTrades; over > 0 {
+			s.pending = slices.Delete(s.pending, 0, over)
+		}
+	}
+
+	minute := t.ExecutedAt.Unix() / 60
+	quote := t.Price.Mul(t.Amount)
+	if n := len(s.buckets); n > 0 && s.buckets[n-1].minute == minute {
+		b := &s.buckets[n-1]
+		b.high = decimal.Max(b.high, t.Price)
+		b.low = decimal.Min(b.low, t.Price)
+		b.volume = b.volume.Add(t.Amount)
+		b.quoteVolume = b.quoteVolume.Add(quote)
+		return
+	}
+	s.buckets = append(s.buckets, bucket{
+		minute:      minute,
+		open:        t.Price,
+		high:        t.Price,
+		low:         t.Price,
+		volume:      t.Amount,
+		quoteVolume: quote,
+	})
+}
+
+func (f *feed) run() {
+	ticker := time.NewTicker(f.publisher.interval)
+	defer ticker.Stop()
+	refreshEvery := f.publisher.ttl / 2
+	var lastRefresh time.Time
+	for now := range ticker.C {
+		refresh := now.Sub(lastRefresh) >= refreshEvery
+		if refresh {
+			lastRefresh = now
+		}
+		for _, pairId := range f.due(refresh) {
+			f.publish(pairId, now)
+		}
+	}
+}
+
+// due lists the pairs to publish and clears their dirty flags
+func (f *feed) due(all bool) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if all {
+		for _, pairId := range f.book.Pairs() {
+			f.pair(pairId)
+		}
+	}
+	var pairs []string
+	for pairId, s := range f.pairs {
+		if all || s.dirty {
+			pairs = append(pairs, pairId)
+			s.dirty = false
+		}
+	}
+	return pairs
+}
+
+func (f *feed) publish(pairId string, now time.Time) {
+	depth := RenderDepth(f.book.Snapshot(pairId), f.publisher.levels)
+	ticker, trades := f.render(pairId, depth, now)
+
+	ctx, cancel := context.WithTimeout(context.Background(), f.publisher.ttl)
+	defer cancel()
+	for _, put := range []func() error{
+		func() error { return f.publisher.cache.PutDepth(ctx, f.tenantId, depth) },
+		func() error { return f.publisher.cache.PutTicker(ctx, f.tenantId, ticker) },
+		func() error { return f.publisher.cache.PutTrades(ctx, f.tenantId, pairId, trades) },
+	} {
+		if err := put(); err != nil {
+			logger.Error("failed to cache market data", map[string]any{
+				"tenant_id": f.tenantId,
+				"pair_id":   pairId,
+				"error":     err,
+			})
+			// Retried on the next tick
+			f.mu.Lock()
+			f.pair(pairId).dirty = true
+			f.mu.Unlock()
+			return
+		}
+	}
+	if f.publisher.broadcaster != nil {
+		f.broadcast(ctx, pairId, depth, ticker)
+	}
+}
+
+// broadcast streams the trades since the last broadcast, the changes of the
+// depth and the ticker of a pair. They're sent again with the next ones
+// after a failure.
+func (f *feed) broadcast(ctx context.Context, pairId string, depth Depth, ticker Ticker) {
+	f.mu.Lock()
+	s := f.pair(pairId)
+	trades := s.pending
+	s.pending = nil
+	f.mu.Unlock()
+
+	updates := make([]Update, 0, len(trades)+2)
+	for _, t := range trades {
+		updates = append(updates, Update{Type: TRADE, PairID: pairId, Data: t})
+	}
+	if diff := Diff(f.sent[pairId], depth); !diff.Empty() {
+		updates = append(updates, Update{Type: DEPTH_DIFF, PairID: pairId, Data: diff})
+	}
+	updates = append(updates, Update{Type: TICKER, PairID: pairId, Data: ticker})
+
+	if err := f.publisher.broadcaster.Broadcast(ctx, f.tenantId, updates); err != nil {
+		logger.Error("failed to broadcast market data", map[string]any{
+			"tenant_id": f.tenantId,
+			"pair_id":   pairId,
+			"error":     err,
+		})
+		f.mu.Lock()
+		s.pending = append(trades, s.pending...)
+		f.mu.Unlock()
+		return
+	}
+	f.sent[pairId] = depth
+}
+
+// render builds the ticker of a pair and copies its recent trades, dropping
+// the buckets older than 24 hours
+func (f *feed) render(pairId string, depth Depth, now time.Time) (Ticker, []PublicTrade) {
+	t := Ticker{PairID: pairId, UpdatedAt: now}
+	if len(depth.Asks) > 0 {
+		t.BestAsk = depth.Asks[0].Price
+	}
+	if len(depth.Bids) > 0 {
+		t.BestBid = depth.Bids[0].Price
+	}
+	t.LastPrice, _ = f.book.LastPrice(pairId)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.pair(pairId)
+	since := now.Add(-24*time.Hour).Unix() / 60
+	s.buckets = slices.DeleteFunc(s.buckets, func(b bucket) bool {
+		return b.minute <= since
+	})
+	for idx, b := range s.buckets {
+		if idx == 0 {
+			t.Open24h, t.High24h, t.Low24h = b.open, b.high, b.low
+		}
+		t.High24h = decimal.Max(t.High24h, b.high)
+		t.Low24h = decimal.Min(t.Low24h, b.low)
+		t.Volume24h = t.Volume24h.Add(b.volume)
+		t.QuoteVolume24h = t.QuoteVolume24h.Add(b.quoteVolume)
+	}
+	return t, slices.Clone(s.trades)
+}
@@ -0,0 +1,77 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type redisCache struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewRedisCache stores market data as JSON under md:<tenant>:<kind>:<pair>
+// keys expiring after ttl
+func NewRedisCache(client *redis.Client, ttl time.Duration) Cache {
+	return &redisCache{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+func key(tenantId string, kind string, pairId string) string {
+	return "md:" + tenantId + ":" + kind + ":" + pairId
+}
+
+func (c *redisCache) put(ctx context.Context, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, key, data, c.ttl).Err()
+}
+
+func (c *redisCache) get(ctx context.Context, key string, dst any) error {
+	data, err := c.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return ErrNotCached
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+func (c *redisCache) PutDepth(ctx context.Context, tenantId string, d Depth) error {
+	return c.put(ctx, key(tenantId, "depth", d.PairID), d)
+}
+
+func (c *redisCache) GetDepth(ctx context.Context, tenantId string, pairId string) (Depth, error) {
+	var d Depth
+	err := c.get(ctx, key(tenantId, "depth", pairId), &d)
+	return d, err
+}
+
+func (c *redisCache) PutTicker(ctx context.Context, tenantId string, t Ticker) error {
+	return c.put(ctx, key(tenantId, "ticker", t.PairID), t)
+}
+
+func (c *redisCache) GetTicker(ctx context.Context, tenantId string, pairId string) (Ticker, error) {
+	var t Ticker
+	err := c.get(ctx, key(tenantId, "ticker", pairId), &t)
+	return t, err
+}
+
+func (c *redisCache) PutTrades(ctx context.Context, tenantId string, pairId string, trades []PublicTrade) error {
+	return c.put(ctx, key(tenantId, "trades", pairId), trades)
+}
+
+func (c *redisCache) GetTrades(ctx context.Context, tenantId string, pairId string) ([]PublicTrade, error) {
+	var trades []PublicTrade
+	err := c.get(ctx, key(tenantId, "trades", pairId), &trades)
+	return trades, err
+}
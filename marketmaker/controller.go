@@ -0,0 +1,25 @@
+package marketmaker
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindMarketMakerRouter serves the inventory and the quotes of the market
+// maker on GET /admin/market-maker
+func BindMarketMakerRouter(r fiber.Router, mm *MarketMaker) {
+	r.Get("/admin/market-maker", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    mm.Stats(),
+		})
+	})
+}
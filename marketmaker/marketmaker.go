@@ -0,0 +1,272 @@
+// Package marketmaker quotes two-sided liquidity on configured pairs through
+// the book, so development and sandbox environments have books to trade
+// against. It's no trading strategy, the quotes only follow the last price
+// and lean against the inventory they build.
+package marketmaker
+
+import (
+	"context"
+	"maps"
+	"order-book/book"
+	"order-book/logger"
+	"order-book/order"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var mmLog = logger.Component("marketmaker")
+
+// Quote is how a pair is quoted. Price is the reference price until the pair
+// trades, the last price after. Spread is the relative distance between the
+// best bid and ask, each further level is another half spread away. Skew
+// shifts the prices against the inventory, by Skew half spreads at
+// MaxInventory, and no level is quoted that could take the inventory past
+// MaxInventory, 0 for no limit.
+type Quote struct {
+	Price        decimal.Decimal
+	Spread       float64
+	Size         decimal.Decimal
+	Levels       int
+	MaxInventory decimal.Decimal
+	Skew         float64
+}
+
+// PairStats are the inventory and the quotes of a pair
+type PairStats struct {
+	Inventory decimal.Decimal `json:"inventory"`
+	Mid       decimal.Decimal `json:"mid"`
+	Bids      []order.Order   `json:"bids"`
+	Asks      []order.Order   `json:"asks"`
+	Error     string          `json:"error,omitempty"`
+}
+
+type Stats struct {
+	AccountID   int                  `json:"account_id"`
+	Interval    string               `json:"interval"`
+	RefreshedAt time.Time            `json:"refreshed_at,omitzero"`
+	Pairs       map[string]PairStats `json:"pairs"`
+}
+
+// MarketMaker quotes the pairs from a single account, cancelling its quotes
+// and placing them again every interval
+type MarketMaker struct {
+	book      book.Book
+	accountId int
+	quotes    map[string]Quote
+	interval  time.Duration
+
+	mu          sync.Mutex
+	inventory   map[string]decimal.Decimal
+	open        map[string][]order.Order
+	errs        map[string]string
+	mids        map[string]decimal.Decimal
+	refreshedAt time.Time
+}
+
+// New quotes quotes into b from accountId. The fills of the account are
+// followed from the trades of b for the inventory, which starts flat.
+func New(b book.Book, accountId int, quotes map[string]Quote, interval time.Duration) *MarketMaker {
+	mm := &MarketMaker{
+		book:      b,
+		accountId: accountId,
+		quotes:    quotes,
+		interval:  interval,
+		inventory: make(map[string]decimal.Decimal),
+		open:      make(map[string][]order.Order),
+		errs:      make(map[string]string),
+		mids:      make(map[string]decimal.Decimal),
+	}
+	b.OnTrade(mm.onTrade)
+	return mm
+}
+
+// onTrade is called from the engine goroutine, it only counts the fill
+func (mm *MarketMaker) onTrade(t order.Trade) {
+	if _, ok := mm.quotes[t.PairID]; !ok {
+		return
+	}
+	var bought decimal.Decimal
+	if t.TakerAccountID == mm.accountId {
+		bought = bought.Add(signed(t.TakerSide, t.Amount))
+	}
+	if t.MakerAccountID == mm.accountId {
+		bought = bought.Sub(signed(t.TakerSide, t.Amount))
+	}
+	if bought.IsZero() {
+		return
+	}
+	mm.mu.Lock()
+	mm.inventory[t.PairID] = mm.inventory[t.PairID].Add(bought)
+	mm.mu.Unlock()
+}
+
+// signed is the amount a side buys, negative for a sell
+func signed(side order.OrderType, amount decimal.Decimal) decimal.Decimal {
+	if side == order.BID {
+		return amount
+	}
+	return amount.Neg()
+}
+
+// Run refreshes the quotes every interval until ctx is done, then cancels
+// them
+func (mm *MarketMaker) Run(ctx context.Context) {
+	mmLog.Info("market maker started", map[string]any{
+		"account_id": mm.accountId,
+		"pairs":      slices.Sorted(maps.Keys(mm.quotes)),
+		"interval":   mm.interval.String(),
+	})
+	ticker := time.NewTicker(mm.interval)
+	defer ticker.Stop()
+	for {
+		mm.refresh(ctx)
+		select {
+		case <-ctx.Done():
+			mm.cancelAll(context.Background())
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (mm *MarketMaker) refresh(ctx context.Context) {
+	mm.cancelAll(ctx)
+	for _, pairId := range slices.Sorted(maps.Keys(mm.quotes)) {
+		mm.quotePair(ctx, pairId)
+	}
+	mm.mu.Lock()
+	mm.refreshedAt = time.Now()
+	mm.mu.Unlock()
+}
+
+// cancelAll pulls the quotes off the book, those filled meanwhile are
+// already gone
+func (mm *MarketMaker) cancelAll(ctx context.Context) {
+	mm.mu.Lock()
+	open := mm.open
+	mm.open = make(map[string][]order.Order)
+	mm.mu.Unlock()
+	for _, orders := range open {
+		for _, o := range orders {
+			err := mm.book.CancellOrder(ctx, o.ID)
+			if err != nil && err != book.ErrOrderNotFound {
+				mmLog.Error("failed to cancel a quote", map[string]any{
+					"order_id": o.ID,
+					"pair_id":  o.PairID,
+					"error":    err,
+				})
+			}
+		}
+	}
+}
+
+func (mm *MarketMaker) quotePair(ctx context.Context, pairId string) {
+	q := mm.quotes[pairId]
+	mm.mu.Lock()
+	inventory := mm.inventory[pairId]
+	mm.mu.Unlock()
+
+	mid := q.Price
+	if last, ok := mm.book.LastPrice(pairId); ok {
+		mid = last
+	}
+	orders := ladder(pairId, q, mid, inventory)
+	var placed []order.Order
+	var lastErr string
+	for _, o := range orders {
+		o.AccountID = mm.accountId
+		o.CreatedAt = time.Now()
+		timings, err := mm.book.AddOrderWithTimings(ctx, o)
+		if err != nil {
+			lastErr = err.Error()
+			mmLog.Debug("quote rejected", map[string]any{
+				"pair_id": pairId,
+				"price":   o.Price,
+				"reason":  err.Error(),
+			})
+			continue
+		}
+		o.ID = timings.OrderID
+		placed = append(placed, o)
+	}
+	mm.mu.Lock()
+	mm.open[pairId] = placed
+	mm.mids[pairId] = mid
+	mm.errs[pairId] = lastErr
+	mm.mu.Unlock()
+}
+
+// ladder is the quotes of a pair around mid for the inventory held. The
+// bids are rounded down to the tick of the pair and the asks up, so they
+// never get closer to each other than the spread.
+func ladder(pairId string, q Quote, mid decimal.Decimal, inventory decimal.Decimal) []order.Order {
+	tick := decimal.New(1, -order.DefaultScale)
+	amountScale := int32(order.DefaultScale)
+	if p, ok := order.GetPair(pairId); ok {
+		tick = decimal.New(1, -p.PriceScale)
+		if p.TickSize.IsPositive() {
+			tick = p.TickSize
+		}
+		amountScale = p.AmountScale
+	}
+	size := q.Size.Truncate(amountScale)
+	if !size.IsPositive() {
+		return nil
+	}
+
+	one := decimal.NewFromInt(1)
+	halfSpread := decimal.NewFromFloat(q.Spread / 2)
+	if q.MaxInventory.IsPositive() {
+		// Long, the prices go down to sell more than buy, and up when short
+		ratio := inventory.Div(q.MaxInventory)
+		ratio = decimal.Max(one.Neg(), decimal.Min(one, ratio))
+		mid = mid.Mul(one.Sub(ratio.Mul(decimal.NewFromFloat(q.Skew)).Mul(halfSpread)))
+	}
+
+	var orders []order.Order
+	for level := range max(q.Levels, 1) {
+		distance := halfSpread.Mul(decimal.NewFromInt(int64(level + 1)))
+		filled := size.Mul(decimal.NewFromInt(int64(level + 1)))
+		bid := mid.Mul(one.Sub(distance)).Div(tick).Floor().Mul(tick)
+		if bid.IsPositive() && (!q.MaxInventory.IsPositive() || inventory.Add(filled).LessThanOrEqual(q.MaxInventory)) {
+			orders = append(orders, order.Order{PairID: pairId, Type: order.BID, Price: bid, Amount: size})
+		}
+		ask := mid.Mul(one.Add(distance)).Div(tick).Ceil().Mul(tick)
+		if !q.MaxInventory.IsPositive() || inventory.Sub(filled).GreaterThanOrEqual(q.MaxInventory.Neg()) {
+			orders = append(orders, order.Order{PairID: pairId, Type: order.ASK, Price: ask, Amount: size})
+		}
+	}
+	return orders
+}
+
+func (mm *MarketMaker) Stats() Stats {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	stats := Stats{
+		AccountID:   mm.accountId,
+		Interval:    mm.interval.String(),
+		RefreshedAt: mm.refreshedAt,
+		Pairs:       make(map[string]PairStats, len(mm.quotes)),
+	}
+	for pairId := range mm.quotes {
+		ps := PairStats{
+			Inventory: mm.inventory[pairId],
+			Mid:       mm.mids[pairId],
+			Error:     mm.errs[pairId],
+			Bids:      []order.Order{},
+			Asks:      []order.Order{},
+		}
+		for _, o := range mm.open[pairId] {
+			if o.Type == order.BID {
+				ps.Bids = append(ps.Bids, o)
+			} else {
+				ps.Asks = append(ps.Asks, o)
+			}
+		}
+		stats.Pairs[pairId] = ps
+	}
+	return stats
+}
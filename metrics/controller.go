@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// BindMetricsRouter serves the Prometheus exposition of Registry on path
+func BindMetricsRouter(r fiber.Router, path string) {
+	r.Get(path, adaptor.HTTPHandler(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})))
+}
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindWSStatsRouter serves GET /admin/ws/stats, the connections, the
+// subscriptions and the traffic of each websocket stream
+func BindWSStatsRouter(r fiber.Router) {
+	r.Get("/admin/ws/stats", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    WSStats(),
+		})
+	})
+}
+
+// Middleware records the requests fiber handles. Requests are labelled with
+// the route pattern rather than the path so ids don't make new series.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		HTTPInFlight.Inc()
+		defer HTTPInFlight.Dec()
+
+		err := c.Next()
+		status := c.Response().StatusCode()
+		route := c.Route().Path
+		if err != nil {
+			// The error handler sets the status once the middlewares returned
+			status = fiber.StatusInternalServerError
+			if e, ok := err.(*fiber.Error); ok {
+				status = e.Code
+			}
+			// Fiber's own 404 leaves the route of the last middleware
+			if status == fiber.StatusNotFound {
+				route = "unmatched"
+			}
+		}
+		// The method is a view of the fasthttp buffer that gets reused
+		method := strings.Clone(c.Method())
+		HTTPRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+		HTTPLatency.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
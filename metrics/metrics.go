@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"order-book/logger"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Registry holds every collector of the engine, along with the Go runtime
+// and process ones. Packages record on the vars below, components that are
+// read at scrape time register a collector of their own with MustRegister.
+var Registry = prometheus.NewRegistry()
+
+var (
+	OrdersReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orderbook_orders_received_total",
+		Help: "Orders submitted to a book, before validation.",
+	}, []string{"pair"})
+	OrdersRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orderbook_orders_rejected_total",
+		Help: "Orders refused by a validator before reaching the engine.",
+	}, []string{"pair"})
+	OrdersMatched = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orderbook_orders_matched_total",
+		Help: "Incoming orders that filled against the book, fully or partially.",
+	}, []string{"pair"})
+	OrdersCancelled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orderbook_orders_cancelled_total",
+		Help: "Resting orders removed from a book.",
+	}, []string{"pair"})
+	Trades = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orderbook_trades_total",
+		Help: "Fills produced by the engine.",
+	}, []string{"pair"})
+	MatchLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "orderbook_match_duration_seconds",
+		Help:    "Time the engine holds the book to match an order and rest what is left.",
+		Buckets: prometheus.ExponentialBuckets(0.000005, 4, 10),
+	}, []string{"pair"})
+	StageLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "orderbook_engine_stage_duration_seconds",
+		Help:    "Time an order spends in each stage of the engine: queue, persist, match, publish and total.",
+		Buckets: prometheus.ExponentialBuckets(0.000005, 4, 12),
+	}, []string{"stage"})
+
+	DBQueryLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "orderbook_db_query_duration_seconds",
+		Help:    "Database queries by sqlc query name, other for hand written ones.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"query", "status"})
+
+	WSConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "orderbook_ws_connections",
+		Help: "Open websocket connections by stream.",
+	}, []string{"stream"})
+
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orderbook_http_requests_total",
+		Help: "HTTP requests by route and status code.",
+	}, []string{"method", "route", "status"})
+	HTTPLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "orderbook_http_request_duration_seconds",
+		Help:    "HTTP request handling time by route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	HTTPInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "orderbook_http_requests_in_flight",
+		Help: "HTTP requests being handled.",
+	})
+
+	Panics = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orderbook_panics_total",
+		Help: "Panics recovered by component, the goroutine went on or was restarted.",
+	}, []string{"component"})
+
+	FaultsInjected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orderbook_chaos_faults_injected_total",
+		Help: "Faults injected by the chaos mode, by kind.",
+	}, []string{"fault"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		OrdersReceived,
+		OrdersRejected,
+		OrdersMatched,
+		OrdersCancelled,
+		Trades,
+		MatchLatency,
+		StageLatency,
+		DBQueryLatency,
+		WSConnections,
+		HTTPRequests,
+		HTTPLatency,
+		HTTPInFlight,
+		Panics,
+		FaultsInjected,
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "orderbook_log_entries_dropped_total",
+			Help: "Log entries dropped because the buffer of the async logger was full.",
+		}, func() float64 {
+			return float64(logger.Stats().Dropped)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "orderbook_log_hook_entries_dropped_total",
+			Help: "Log entries not handed to a hook because it was lagging behind.",
+		}, func() float64 {
+			return float64(logger.Stats().HookDropped)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "orderbook_log_buffer_entries",
+			Help: "Log entries waiting to be written by the async logger.",
+		}, func() float64 {
+			return float64(logger.Stats().Buffered)
+		}),
+	)
+}
+
+// MustRegister adds collectors to Registry, it panics on a name collision
+func MustRegister(cs ...prometheus.Collector) {
+	Registry.MustRegister(cs...)
+}
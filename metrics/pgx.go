@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	name string
+	at   time.Time
+}
+
+// QueryTracer times the queries of a pgx connection into DBQueryLatency
+type QueryTracer struct{}
+
+func (QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{name: queryName(data.SQL), at: time.Now()})
+}
+
+func (QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	status := "ok"
+	if data.Err != nil {
+		status = "error"
+	}
+	DBQueryLatency.WithLabelValues(start.name, status).Observe(time.Since(start.at).Seconds())
+}
+
+// queryName reads the name sqlc puts in the leading comment of its queries
+func queryName(sql string) string {
+	rest, ok := strings.CutPrefix(sql, "-- name: ")
+	if !ok {
+		return "other"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reasons a websocket connection ended for
+const (
+	DisconnectClientClosed   = "client_closed"
+	DisconnectWriteError     = "write_error"
+	DisconnectPingFailed     = "ping_failed"
+	DisconnectSlowConsumer   = "slow_consumer"
+	DisconnectInvalidRequest = "invalid_request"
+	DisconnectUnavailable    = "unavailable"
+)
+
+var (
+	WSSubscriptions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "orderbook_ws_subscriptions",
+		Help: "Active websocket subscriptions by stream.",
+	}, []string{"stream"})
+	WSMessagesSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orderbook_ws_messages_sent_total",
+		Help: "Messages written to websocket clients by stream.",
+	}, []string{"stream"})
+	WSSendOverflows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orderbook_ws_send_buffer_overflows_total",
+		Help: "Subscribers dropped because their send buffer was full, by stream.",
+	}, []string{"stream"})
+	WSDisconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orderbook_ws_disconnects_total",
+		Help: "Closed websocket connections by stream and reason.",
+	}, []string{"stream", "reason"})
+)
+
+func init() {
+	Registry.MustRegister(WSSubscriptions, WSMessagesSent, WSSendOverflows, WSDisconnects)
+}
+
+// WSStream records the activity of a websocket stream, both as metrics and
+// for the stats endpoint
+type WSStream struct {
+	name          string
+	connections   atomic.Int64
+	subscriptions atomic.Int64
+	sent          atomic.Uint64
+	overflows     atomic.Uint64
+	mu            sync.Mutex
+	disconnects   map[string]uint64
+}
+
+// WSStreamStats is the activity of a stream since the process started
+type WSStreamStats struct {
+	Stream        string            `json:"stream"`
+	Connections   int64             `json:"connections"`
+	Subscriptions int64             `json:"subscriptions"`
+	MessagesSent  uint64            `json:"messages_sent"`
+	Overflows     uint64            `json:"send_buffer_overflows"`
+	Disconnects   map[string]uint64 `json:"disconnects"`
+}
+
+var wsStreams sync.Map
+
+// Stream returns the recorder of a stream like order_book or market_data
+func Stream(name string) *WSStream {
+	if s, ok := wsStreams.Load(name); ok {
+		return s.(*WSStream)
+	}
+	s, _ := wsStreams.LoadOrStore(name, &WSStream{name: name, disconnects: make(map[string]uint64)})
+	return s.(*WSStream)
+}
+
+func (s *WSStream) Connected() {
+	s.connections.Add(1)
+	WSConnections.WithLabelValues(s.name).Inc()
+}
+
+// Disconnected records a connection closing for reason, one of the Disconnect constants
+func (s *WSStream) Disconnected(reason string) {
+	s.connections.Add(-1)
+	WSConnections.WithLabelValues(s.name).Dec()
+	WSDisconnects.WithLabelValues(s.name, reason).Inc()
+	s.mu.Lock()
+	s.disconnects[reason]++
+	s.mu.Unlock()
+}
+
+func (s *WSStream) Subscribed() {
+	s.subscriptions.Add(1)
+	WSSubscriptions.WithLabelValues(s.name).Inc()
+}
+
+func (s *WSStream) Unsubscribed() {
+	s.subscriptions.Add(-1)
+	WSSubscriptions.WithLabelValues(s.name).Dec()
+}
+
+func (s *WSStream) Sent() {
+	s.sent.Add(1)
+	WSMessagesSent.WithLabelValues(s.name).Inc()
+}
+
+// Overflowed records a subscriber dropped by a publisher it couldn't keep up with
+func (s *WSStream) Overflowed() {
+	s.overflows.Add(1)
+	WSSendOverflows.WithLabelValues(s.name).Inc()
+}
+
+func (s *WSStream) stats() WSStreamStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	disconnects := make(map[string]uint64, len(s.disconnects))
+	for reason, n := range s.disconnects {
+		disconnects[reason] = n
+	}
+	return WSStreamStats{
+		Stream:        s.name,
+		Connections:   s.connections.Load(),
+		Subscriptions: s.subscriptions.Load(),
+		MessagesSent:  s.sent.Load(),
+		Overflows:     s.overflows.Load(),
+		Disconnects:   disconnects,
+	}
+}
+
+// WSStats returns the activity of every stream, by name
+func WSStats() []WSStreamStats {
+	stats := []WSStreamStats{}
+	wsStreams.Range(func(_, s any) bool {
+		stats = append(stats, s.(*WSStream).stats())
+		return true
+	})
+	slices.SortFunc(stats, func(a, b WSStreamStats) int {
+		return strings.Compare(a.Stream, b.Stream)
+	})
+	return stats
+}
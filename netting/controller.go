@@ -0,0 +1,102 @@
+package netting
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindNettingRouter serves the netting mode of an account and what it owes
+// in the running window on /accounts/:id/netting, and its netting reports on
+// GET /accounts/:id/netting-reports
+func BindNettingRouter(r fiber.Router, e *Engine) {
+	r.Get("/accounts/:id/netting", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return invalidAccount(c)
+		}
+		pending, closesAt, err := e.Pending(c.UserContext(), accountId)
+		if err != nil {
+			nettingLog.Error("failed to get the pending obligations", map[string]any{
+				"account_id": accountId,
+				"error":      err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data: map[string]any{
+				"enabled":   e.Enabled(accountId),
+				"window":    e.Window().String(),
+				"closes_at": closesAt,
+				"pending":   pending,
+			},
+		})
+	})
+
+	r.Put("/accounts/:id/netting", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return invalidAccount(c)
+		}
+		if err := e.OptIn(c.UserContext(), accountId); err != nil {
+			nettingLog.Error("failed to opt an account in netting", map[string]any{
+				"account_id": accountId,
+				"error":      err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "The account settles once per netting window",
+			Data:    nil,
+		})
+	})
+
+	r.Delete("/accounts/:id/netting", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return invalidAccount(c)
+		}
+		if err := e.OptOut(c.UserContext(), accountId); err != nil {
+			nettingLog.Error("failed to opt an account out of netting", map[string]any{
+				"account_id": accountId,
+				"error":      err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "The account settles its fills one by one",
+			Data:    nil,
+		})
+	})
+
+	r.Get("/accounts/:id/netting-reports", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return invalidAccount(c)
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    e.Reports(accountId),
+		})
+	})
+}
+
+func invalidAccount(c *fiber.Ctx) error {
+	c.Status(http.StatusBadRequest)
+	return c.JSON(&Response{
+		Message: "Invalid account ID",
+		Data:    nil,
+	})
+}
@@ -0,0 +1,367 @@
+// Package netting settles the fees and rebates of the accounts in netting
+// mode once per settlement window. Their obligations are added up through
+// the window instead of being posted fill by fill, and when it closes each
+// account gets one net ledger movement per asset against the fees account,
+// described by a netting report.
+package netting
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"order-book/account"
+	"order-book/logger"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// maxHistory bounds the reports kept per account
+const maxHistory = 100
+
+var nettingLog = logger.Component("netting")
+
+// retryInterval is how often the nets that failed to post are retried
+const retryInterval = time.Minute
+
+// Obligation is what a fill owes an account, positive, or the account owes
+// for it, negative, like the rebate of a maker or a fee paid in a fee asset.
+// WindowStart and WindowEnd are set once its window closed.
+type Obligation struct {
+	AccountID   int               `json:"-"`
+	TradeID     int64             `json:"trade_id"`
+	PairID      string            `json:"pair_id"`
+	Type        account.EntryType `json:"type"`
+	Asset       string            `json:"asset"`
+	Amount      decimal.Decimal   `json:"amount"`
+	At          time.Time         `json:"at"`
+	WindowStart time.Time         `json:"-"`
+	WindowEnd   time.Time         `json:"-"`
+}
+
+// Net is the settlement of an asset at the close of a window. Credits and
+// Debits add up the obligations each way, Net is what was posted. A net
+// with an Error wasn't posted, it's retried until it is.
+type Net struct {
+	Asset       string          `json:"asset"`
+	Credits     decimal.Decimal `json:"credits"`
+	Debits      decimal.Decimal `json:"debits"`
+	Net         decimal.Decimal `json:"net"`
+	Obligations int             `json:"obligations"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// Report is the netting of an account over the window [From, To), with the
+// obligations it settled
+type Report struct {
+	AccountID   int          `json:"account_id"`
+	From        time.Time    `json:"from"`
+	To          time.Time    `json:"to"`
+	Nets        []Net        `json:"nets"`
+	Obligations []Obligation `json:"obligations"`
+}
+
+// Engine keeps the accounts in netting mode and their obligations in repo,
+// so a restart neither loses what they owe nor puts them back to settling
+// fill by fill
+type Engine struct {
+	window   time.Duration
+	repo     Repo
+	balances account.BalanceRepo
+
+	mu       sync.Mutex
+	accounts map[int]bool
+	history  map[int][]Report
+	// from is the start of the running window
+	from time.Time
+	now  func() time.Time
+	// settling serializes the closes of the windows and the retries
+	settling sync.Mutex
+}
+
+// NewEngine nets the accounts opted in through the API and those of the
+// configuration, which are opted in again on every start
+func NewEngine(window time.Duration, accounts []int, repo Repo, balances account.BalanceRepo) (*Engine, error) {
+	e := &Engine{
+		window:   window,
+		repo:     repo,
+		balances: balances,
+		accounts: make(map[int]bool, len(accounts)),
+		history:  make(map[int][]Report),
+		now:      time.Now,
+	}
+	ctx := context.Background()
+	for _, accountId := range accounts {
+		if err := repo.AddAccount(ctx, accountId); err != nil {
+			return nil, err
+		}
+	}
+	opted, err := repo.GetAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, accountId := range opted {
+		e.accounts[accountId] = true
+	}
+	e.from = e.now().Truncate(window)
+	return e, nil
+}
+
+// Window is the length of the settlement windows
+func (e *Engine) Window() time.Duration {
+	return e.window
+}
+
+// OptIn puts an account in netting mode from the running window on
+func (e *Engine) OptIn(ctx context.Context, accountId int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.repo.AddAccount(ctx, accountId); err != nil {
+		return err
+	}
+	e.accounts[accountId] = true
+	return nil
+}
+
+// OptOut settles the fills of an account one by one again. What it owes in
+// the running window is still netted when the window closes.
+func (e *Engine) OptOut(ctx context.Context, accountId int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.repo.RemoveAccount(ctx, accountId); err != nil {
+		return err
+	}
+	delete(e.accounts, accountId)
+	return nil
+}
+
+func (e *Engine) Enabled(accountId int) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.accounts[accountId]
+}
+
+// Defer adds an obligation of an account in netting mode to the running
+// window and reports true. The caller posts it itself otherwise, also when
+// the obligation couldn't be kept.
+func (e *Engine) Defer(accountId int, o Obligation) bool {
+	if !e.Enabled(accountId) {
+		return false
+	}
+	o.AccountID = accountId
+	if err := e.repo.AddObligation(context.Background(), o); err != nil {
+		nettingLog.Error("failed to keep an obligation, it's posted on its own", map[string]any{
+			"account_id": accountId,
+			"trade_id":   o.TradeID,
+			"asset":      o.Asset,
+			"error":      err,
+		})
+		return false
+	}
+	return true
+}
+
+// Pending are the obligations of an account in the running window and when
+// it closes
+func (e *Engine) Pending(ctx context.Context, accountId int) (obligations []Obligation, closesAt time.Time, err error) {
+	obligations, err = e.repo.GetOpenObligations(ctx, accountId)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return obligations, e.from.Add(e.window), nil
+}
+
+// Run closes the windows on schedule and retries the nets that failed to
+// post until ctx is done. The windows closed before a restart are settled
+// first.
+func (e *Engine) Run(ctx context.Context) {
+	nettingLog.Info("netting started", map[string]any{
+		"window": e.window.String(),
+	})
+	e.settle()
+	retry := time.NewTicker(retryInterval)
+	defer retry.Stop()
+	for {
+		e.mu.Lock()
+		due := e.from.Add(e.window)
+		e.mu.Unlock()
+		timer := time.NewTimer(due.Sub(e.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-retry.C:
+			timer.Stop()
+			e.settle()
+		case <-timer.C:
+			e.close(due)
+		}
+	}
+}
+
+// Close settles the running window early, up to now. It's called on
+// shutdown once no more obligations are deferred, what fails to post is
+// settled on the next start.
+func (e *Engine) Close() {
+	e.close(e.now())
+}
+
+// close ends the running window at to and settles it
+func (e *Engine) close(to time.Time) {
+	e.settling.Lock()
+	defer e.settling.Unlock()
+	e.mu.Lock()
+	from := e.from
+	e.mu.Unlock()
+	closed, err := e.repo.CloseWindow(context.Background(), from, to)
+	if err != nil {
+		// The window is closed on the next attempt, with what was deferred
+		// in the meantime
+		nettingLog.Error("failed to close a netting window", map[string]any{
+			"from":  from,
+			"to":    to,
+			"error": err,
+		})
+		return
+	}
+	e.mu.Lock()
+	e.from = to
+	e.mu.Unlock()
+	nettingLog.Info("window closed", map[string]any{
+		"to":          to,
+		"obligations": closed,
+	})
+	e.settleClosed()
+}
+
+func (e *Engine) settle() {
+	e.settling.Lock()
+	defer e.settling.Unlock()
+	e.settleClosed()
+}
+
+// settleClosed posts the nets of the closed windows. Each net is its own
+// ledger transaction against the fees account, keyed by the account, the
+// asset and the window so a retry never posts twice, and its obligations
+// are dropped once it's posted.
+func (e *Engine) settleClosed() {
+	ctx := context.Background()
+	closed, err := e.repo.GetClosedObligations(ctx)
+	if err != nil {
+		nettingLog.Error("failed to get the obligations of the closed windows", map[string]any{
+			"error": err,
+		})
+		return
+	}
+	var reports []*Report
+	for _, o := range closed {
+		if n := len(reports); n == 0 || reports[n-1].AccountID != o.AccountID || !reports[n-1].To.Equal(o.WindowEnd) {
+			reports = append(reports, &Report{AccountID: o.AccountID, From: o.WindowStart, To: o.WindowEnd, Nets: []Net{}})
+		}
+		r := reports[len(reports)-1]
+		r.Obligations = append(r.Obligations, o)
+	}
+	for _, r := range reports {
+		byAsset := make(map[string]*Net)
+		for _, o := range r.Obligations {
+			n, ok := byAsset[o.Asset]
+			if !ok {
+				n = &Net{Asset: o.Asset}
+				byAsset[o.Asset] = n
+			}
+			if o.Amount.IsPositive() {
+				n.Credits = n.Credits.Add(o.Amount)
+			} else {
+				n.Debits = n.Debits.Sub(o.Amount)
+			}
+			n.Net = n.Net.Add(o.Amount)
+			n.Obligations++
+		}
+		for _, asset := range slices.Sorted(maps.Keys(byAsset)) {
+			n := byAsset[asset]
+			if err := e.post(r.AccountID, r.To, n); err != nil {
+				n.Error = err.Error()
+				nettingLog.Error("failed to post a net, it's retried", map[string]any{
+					"account_id": r.AccountID,
+					"asset":      asset,
+					"net":        n.Net,
+					"to":         r.To,
+					"error":      err,
+				})
+			}
+			r.Nets = append(r.Nets, *n)
+		}
+		e.record(*r)
+	}
+}
+
+// post settles a net and drops its obligations
+func (e *Engine) post(accountId int, to time.Time, n *Net) error {
+	if !n.Net.IsZero() {
+		_, _, err := e.balances.Post(account.Transaction{
+			Type:           account.NETTING,
+			IdempotencyKey: fmt.Sprintf("netting:%d:%s:%d", accountId, n.Asset, to.Unix()),
+			Source:         account.ENGINE,
+			Reference:      fmt.Sprintf("net of %d obligations in %s until %s", n.Obligations, n.Asset, to.UTC().Format(time.RFC3339)),
+			Postings: []account.Posting{
+				{AccountID: accountId, Asset: n.Asset, Amount: n.Net},
+				{SystemAccount: account.FEES, Asset: n.Asset, Amount: n.Net.Neg()},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return e.repo.DeleteObligations(context.Background(), accountId, n.Asset, to)
+}
+
+// record keeps the report of a window. A retry replaces the report of the
+// earlier attempt, keeping the nets it posted then.
+func (e *Engine) record(r Report) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	history := e.history[r.AccountID]
+	if idx := slices.IndexFunc(history, func(h Report) bool { return h.To.Equal(r.To) }); idx >= 0 {
+		retried := func(asset string) bool {
+			return slices.ContainsFunc(r.Nets, func(n Net) bool { return n.Asset == asset })
+		}
+		var nets []Net
+		var obligations []Obligation
+		for _, n := range history[idx].Nets {
+			if !retried(n.Asset) {
+				nets = append(nets, n)
+			}
+		}
+		for _, o := range history[idx].Obligations {
+			if !retried(o.Asset) {
+				obligations = append(obligations, o)
+			}
+		}
+		r.Nets = append(nets, r.Nets...)
+		slices.SortFunc(r.Nets, func(a, b Net) int { return cmp.Compare(a.Asset, b.Asset) })
+		r.Obligations = append(obligations, r.Obligations...)
+		history = slices.Delete(history, idx, idx+1)
+	}
+	history = append(history, r)
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	e.history[r.AccountID] = history
+}
+
+// Reports are the last netting reports of an account, the latest first
+func (e *Engine) Reports(accountId int) []Report {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	reports := slices.Clone(e.history[accountId])
+	slices.Reverse(reports)
+	if reports == nil {
+		reports = []Report{}
+	}
+	return reports
+}
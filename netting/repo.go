@@ -0,0 +1,148 @@
+package netting
+
+import (
+	"context"
+	"order-book/account"
+	repository "order-book/netting/repository/gen"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Repo keeps the accounts in netting mode and their obligations until their
+// net is posted, so neither is lost on a restart
+type Repo interface {
+	GetAccounts(ctx context.Context) ([]int, error)
+	AddAccount(ctx context.Context, accountId int) error
+	RemoveAccount(ctx context.Context, accountId int) error
+	AddObligation(ctx context.Context, o Obligation) error
+	// GetOpenObligations are the obligations of an account in the running
+	// window
+	GetOpenObligations(ctx context.Context, accountId int) ([]Obligation, error)
+	// CloseWindow puts the obligations of the running window from before to
+	// in the window [from, to)
+	CloseWindow(ctx context.Context, from time.Time, to time.Time) (int64, error)
+	// GetClosedObligations are the obligations of the closed windows whose
+	// net wasn't posted yet, by window then account
+	GetClosedObligations(ctx context.Context) ([]Obligation, error)
+	// DeleteObligations drops the obligations of an account in an asset once
+	// the net of their window is posted
+	DeleteObligations(ctx context.Context, accountId int, asset string, windowEnd time.Time) error
+}
+
+type repo struct {
+	queries      *repository.Queries
+	queryTimeout time.Duration
+}
+
+func (repo *repo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *repo) GetAccounts(ctx context.Context) ([]int, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	dbres, err := repo.queries.GetNettingAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]int, len(dbres))
+	for idx, accountId := range dbres {
+		accounts[idx] = int(accountId)
+	}
+	return accounts, nil
+}
+
+func (repo *repo) AddAccount(ctx context.Context, accountId int) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.InsertNettingAccount(ctx, int32(accountId))
+}
+
+func (repo *repo) RemoveAccount(ctx context.Context, accountId int) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.DeleteNettingAccount(ctx, int32(accountId))
+}
+
+func (repo *repo) AddObligation(ctx context.Context, o Obligation) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.InsertObligation(ctx, repository.InsertObligationParams{
+		AccountID: int32(o.AccountID),
+		TradeID:   o.TradeID,
+		PairID:    o.PairID,
+		EntryType: string(o.Type),
+		Asset:     o.Asset,
+		Amount:    o.Amount,
+		At:        pgtype.Timestamp{Time: o.At.UTC(), Valid: true},
+	})
+}
+
+func (repo *repo) GetOpenObligations(ctx context.Context, accountId int) ([]Obligation, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	dbres, err := repo.queries.GetOpenObligations(ctx, int32(accountId))
+	if err != nil {
+		return nil, err
+	}
+	return convertObligations(dbres), nil
+}
+
+func (repo *repo) CloseWindow(ctx context.Context, from time.Time, to time.Time) (int64, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.CloseWindow(ctx, repository.CloseWindowParams{
+		WindowStart: pgtype.Timestamp{Time: from.UTC(), Valid: true},
+		WindowEnd:   pgtype.Timestamp{Time: to.UTC(), Valid: true},
+	})
+}
+
+func (repo *repo) GetClosedObligations(ctx context.Context) ([]Obligation, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	dbres, err := repo.queries.GetClosedObligations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return convertObligations(dbres), nil
+}
+
+func (repo *repo) DeleteObligations(ctx context.Context, accountId int, asset string, windowEnd time.Time) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.DeleteObligations(ctx, repository.DeleteObligationsParams{
+		AccountID: int32(accountId),
+		Asset:     asset,
+		WindowEnd: pgtype.Timestamp{Time: windowEnd.UTC(), Valid: true},
+	})
+}
+
+func NewRepository(dbpool *pgxpool.Pool, queryTimeout time.Duration) Repo {
+	return &repo{
+		queries:      repository.New(dbpool),
+		queryTimeout: queryTimeout,
+	}
+}
+
+func convertObligations(dbres []repository.TblNettingObligation) []Obligation {
+	obligations := make([]Obligation, len(dbres))
+	for idx, o := range dbres {
+		obligations[idx] = Obligation{
+			AccountID:   int(o.AccountID),
+			TradeID:     o.TradeID,
+			PairID:      o.PairID,
+			Type:        account.EntryType(o.EntryType),
+			Asset:       o.Asset,
+			Amount:      o.Amount,
+			At:          o.At.Time,
+			WindowStart: o.WindowStart.Time,
+			WindowEnd:   o.WindowEnd.Time,
+		}
+	}
+	return obligations
+}
@@ -0,0 +1,31 @@
+-- name: GetNettingAccounts :many
+SELECT account_id FROM tbl_netting_accounts ORDER BY account_id;
+
+-- name: InsertNettingAccount :exec
+INSERT INTO tbl_netting_accounts (account_id) VALUES ($1)
+ON CONFLICT (account_id) DO NOTHING;
+
+-- name: DeleteNettingAccount :exec
+DELETE FROM tbl_netting_accounts WHERE account_id = $1;
+
+-- name: InsertObligation :exec
+INSERT INTO tbl_netting_obligations (account_id, trade_id, pair_id, entry_type, asset, amount, at)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: GetOpenObligations :many
+SELECT * FROM tbl_netting_obligations
+WHERE account_id = $1 AND window_end IS NULL
+ORDER BY id;
+
+-- name: CloseWindow :execrows
+UPDATE tbl_netting_obligations SET window_start = @window_start, window_end = @window_end
+WHERE window_end IS NULL AND at < @window_end;
+
+-- name: GetClosedObligations :many
+SELECT * FROM tbl_netting_obligations
+WHERE window_end IS NOT NULL
+ORDER BY window_end, account_id, id;
+
+-- name: DeleteObligations :exec
+DELETE FROM tbl_netting_obligations
+WHERE account_id = $1 AND asset = $2 AND window_end = $3;
@@ -0,0 +1,19 @@
+CREATE TABLE tbl_netting_accounts
+(
+    account_id INT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE tbl_netting_obligations
+(
+    id BIGSERIAL PRIMARY KEY,
+    account_id INT NOT NULL,
+    trade_id BIGINT NOT NULL,
+    pair_id VARCHAR(25) NOT NULL,
+    entry_type VARCHAR(25) NOT NULL,
+    asset VARCHAR(25) NOT NULL,
+    amount DECIMAL(20, 10) NOT NULL,
+    at TIMESTAMP NOT NULL,
+    window_start TIMESTAMP NULL,
+    window_end TIMESTAMP NULL
+);
@@ -0,0 +1,138 @@
+package netting
+
+import (
+	"context"
+	"database/sql"
+	"order-book/account"
+	"time"
+)
+
+type sqliteRepo struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func (repo *sqliteRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+const sqliteObligationColumns = "account_id, trade_id, pair_id, entry_type, asset, amount, at, window_start, window_end"
+
+func (repo *sqliteRepo) GetAccounts(ctx context.Context) ([]int, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	rows, err := repo.db.QueryContext(ctx, "SELECT account_id FROM tbl_netting_accounts ORDER BY account_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	accounts := []int{}
+	for rows.Next() {
+		var accountId int
+		if err := rows.Scan(&accountId); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, accountId)
+	}
+	return accounts, rows.Err()
+}
+
+func (repo *sqliteRepo) AddAccount(ctx context.Context, accountId int) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err := repo.db.ExecContext(ctx,
+		"INSERT INTO tbl_netting_accounts (account_id, created_at) VALUES (?, ?) ON CONFLICT (account_id) DO NOTHING",
+		accountId, time.Now().UTC(),
+	)
+	return err
+}
+
+func (repo *sqliteRepo) RemoveAccount(ctx context.Context, accountId int) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err := repo.db.ExecContext(ctx, "DELETE FROM tbl_netting_accounts WHERE account_id = ?", accountId)
+	return err
+}
+
+func (repo *sqliteRepo) AddObligation(ctx context.Context, o Obligation) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err := repo.db.ExecContext(ctx,
+		"INSERT INTO tbl_netting_obligations (account_id, trade_id, pair_id, entry_type, asset, amount, at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		o.AccountID, o.TradeID, o.PairID, string(o.Type), o.Asset, o.Amount.String(), o.At.UTC(),
+	)
+	return err
+}
+
+func (repo *sqliteRepo) getObligations(ctx context.Context, query string, args ...any) ([]Obligation, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	obligations := []Obligation{}
+	for rows.Next() {
+		var (
+			o                      Obligation
+			entryType              string
+			windowStart, windowEnd sql.NullTime
+		)
+		if err := rows.Scan(&o.AccountID, &o.TradeID, &o.PairID, &entryType, &o.Asset, &o.Amount, &o.At, &windowStart, &windowEnd); err != nil {
+			return nil, err
+		}
+		o.Type = account.EntryType(entryType)
+		o.WindowStart = windowStart.Time
+		o.WindowEnd = windowEnd.Time
+		obligations = append(obligations, o)
+	}
+	return obligations, rows.Err()
+}
+
+func (repo *sqliteRepo) GetOpenObligations(ctx context.Context, accountId int) ([]Obligation, error) {
+	return repo.getObligations(ctx,
+		"SELECT "+sqliteObligationColumns+" FROM tbl_netting_obligations WHERE account_id = ? AND window_end IS NULL ORDER BY id",
+		accountId,
+	)
+}
+
+func (repo *sqliteRepo) CloseWindow(ctx context.Context, from time.Time, to time.Time) (int64, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	res, err := repo.db.ExecContext(ctx,
+		"UPDATE tbl_netting_obligations SET window_start = ?, window_end = ? WHERE window_end IS NULL AND at < ?",
+		from.UTC(), to.UTC(), to.UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (repo *sqliteRepo) GetClosedObligations(ctx context.Context) ([]Obligation, error) {
+	return repo.getObligations(ctx,
+		"SELECT "+sqliteObligationColumns+" FROM tbl_netting_obligations WHERE window_end IS NOT NULL ORDER BY window_end, account_id, id",
+	)
+}
+
+func (repo *sqliteRepo) DeleteObligations(ctx context.Context, accountId int, asset string, windowEnd time.Time) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err := repo.db.ExecContext(ctx,
+		"DELETE FROM tbl_netting_obligations WHERE account_id = ? AND asset = ? AND window_end = ?",
+		accountId, asset, windowEnd.UTC(),
+	)
+	return err
+}
+
+// NewSQLiteRepository is NewRepository on a SQLite database
+func NewSQLiteRepository(db *sql.DB, queryTimeout time.Duration) Repo {
+	return &sqliteRepo{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
@@ -0,0 +1,207 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	repository "order-book/order/repository/gen"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// historyTable is the partitioned table order history events are written to
+const historyTable = "tbl_order_history_events"
+
+// partitionsAhead is the number of monthly partitions kept ready past the
+// current month, so events never land in the default partition
+const partitionsAhead = 2
+
+// ArchivedPartition is a monthly partition detached from the hot tables,
+// holding the rows created in [RangeStart, RangeEnd)
+type ArchivedPartition struct {
+	Name       string    `json:"name"`
+	Parent     string    `json:"parent"`
+	RangeStart time.Time `json:"range_start"`
+	RangeEnd   time.Time `json:"range_end"`
+	ArchivedAt time.Time `json:"archived_at"`
+	// ObjectKey is set once the rows are exported to object storage
+	ObjectKey  string    `json:"object_key,omitempty"`
+	ExportedAt time.Time `json:"exported_at,omitzero"`
+}
+
+type HistoryPartitionRepo interface {
+	// CreatePartition creates the partition of the month of t if it's missing
+	CreatePartition(ctx context.Context, t time.Time) (name string, err error)
+	// ArchivePartitions moves the partitions ending at or before the given
+	// time to the archive schema and returns their names
+	ArchivePartitions(ctx context.Context, before time.Time) ([]string, error)
+	GetArchivedPartitions(ctx context.Context) ([]ArchivedPartition, error)
+	// GetUnexportedPartitions lists the archived partitions still in the database
+	GetUnexportedPartitions(ctx context.Context) ([]ArchivedPartition, error)
+	ReadArchivedPartition(ctx context.Context, name string) ([]OrderHistoryEvent, error)
+	// DropArchivedPartition drops a partition once its rows are stored under objectKey
+	DropArchivedPartition(ctx context.Context, name string, objectKey string) error
+}
+
+type historyPartitionRepo struct {
+	queries      *repository.Queries
+	queryTimeout time.Duration
+}
+
+func (repo *historyPartitionRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *historyPartitionRepo) CreatePartition(ctx context.Context, t time.Time) (string, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.CreateOrderHistoryPartition(ctx, pgtype.Timestamp{Time: t.UTC(), Valid: true})
+}
+
+func (repo *historyPartitionRepo) ArchivePartitions(ctx context.Context, before time.Time) ([]string, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.ArchiveOrderHistoryPartitions(ctx, pgtype.Timestamp{Time: before.UTC(), Valid: true})
+}
+
+func (repo *historyPartitionRepo) GetArchivedPartitions(ctx context.Context) ([]ArchivedPartition, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	dbres, err := repo.queries.GetArchivedPartitions(ctx, historyTable)
+	if err != nil {
+		return nil, err
+	}
+	return convertPartitions(dbres), nil
+}
+
+func (repo *historyPartitionRepo) GetUnexportedPartitions(ctx context.Context) ([]ArchivedPartition, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	dbres, err := repo.queries.GetUnexportedArchivedPartitions(ctx, historyTable)
+	if err != nil {
+		return nil, err
+	}
+	return convertPartitions(dbres), nil
+}
+
+func (repo *historyPartitionRepo) ReadArchivedPartition(ctx context.Context, name string) ([]OrderHistoryEvent, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	dbres, err := repo.queries.ReadArchivedHistoryPartition(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]OrderHistoryEvent, len(dbres))
+	for idx, ev := range dbres {
+		events[idx] = OrderHistoryEvent{
+			ID:        ev.ID,
+			Name:      ev.Event,
+			OrderId:   int(ev.OrderID.Int64),
+			CreatedAt: ev.CreatedAt.Time,
+		}
+		if len(ev.Metadata) > 0 {
+			if err := json.Unmarshal(ev.Metadata, &events[idx].Metadata); err != nil {
+				return nil, err
+			}
+		}
+		if events[idx], err = Upcast(events[idx]); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+func (repo *historyPartitionRepo) DropArchivedPartition(ctx context.Context, name string, objectKey string) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.DropArchivedPartition(ctx, repository.DropArchivedPartitionParams{
+		Name:      name,
+		ObjectKey: objectKey,
+	})
+}
+
+func convertPartitions(dbres []repository.TblArchivedPartition) []ArchivedPartition {
+	partitions := make([]ArchivedPartition, len(dbres))
+	for idx, p := range dbres {
+		partitions[idx] = ArchivedPartition{
+			Name:       p.Name,
+			Parent:     p.Parent,
+			RangeStart: p.RangeStart.Time,
+			RangeEnd:   p.RangeEnd.Time,
+			ArchivedAt: p.ArchivedAt.Time,
+			ObjectKey:  p.ObjectKey.String,
+			ExportedAt: p.ExportedAt.Time,
+		}
+	}
+	return partitions
+}
+
+func NewHistoryPartitionRepository(dbpool *pgxpool.Pool, queryTimeout time.Duration) HistoryPartitionRepo {
+	return &historyPartitionRepo{
+		queries:      repository.New(dbpool),
+		queryTimeout: queryTimeout,
+	}
+}
+
+// HistoryArchiver keeps the monthly partitions of the order history created
+// ahead of time and archives the months that fully fall out of the retention
+// window. A zero retention keeps every month in the hot tables.
+type HistoryArchiver struct {
+	repo      HistoryPartitionRepo
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewHistoryArchiver(repo HistoryPartitionRepo, retention time.Duration, interval time.Duration) *HistoryArchiver {
+	return &HistoryArchiver{
+		repo:      repo,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run maintains the partitions right away and then every interval until ctx is done
+func (a *HistoryArchiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		a.maintain(ctx, time.Now().UTC())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *HistoryArchiver) maintain(ctx context.Context, now time.Time) {
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i <= partitionsAhead; i++ {
+		if _, err := a.repo.CreatePartition(ctx, month.AddDate(0, i, 0)); err != nil {
+			repoLog.Error("failed to create order history partition", map[string]any{
+				"month": month.AddDate(0, i, 0).Format("2006-01"),
+				"error": err,
+			})
+		}
+	}
+
+	if a.retention <= 0 {
+		return
+	}
+	archived, err := a.repo.ArchivePartitions(ctx, now.Add(-a.retention))
+	if err != nil {
+		repoLog.Error("failed to archive order history partitions", map[string]any{
+			"error": err,
+		})
+		return
+	}
+	for _, name := range archived {
+		repoLog.Info("order history partition archived", map[string]any{
+			"partition": name,
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	repository "order-book/order/repository/gen"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrUnknownAsset        = errors.New("Asset is not listed")
+	ErrAssetPrecision      = errors.New("Amount has more decimal places than the asset allows")
+	ErrTradingDisabled     = errors.New("Trading of the asset is disabled")
+	ErrWithdrawalsDisabled = errors.New("Withdrawals of the asset are disabled")
+	ErrAssetExists         = errors.New("Asset is already listed")
+	ErrAssetInUse          = errors.New("Asset is traded on a listed pair, delist the pair first")
+)
+
+// Asset is a listed asset, amounts of it carry at most Precision decimal
+// places. Pairs trade listed assets and balances hold them.
+type Asset struct {
+	Symbol             string `json:"symbol"`
+	Name               string `json:"name"`
+	Precision          int32  `json:"precision"`
+	TradingEnabled     bool   `json:"trading_enabled"`
+	WithdrawalsEnabled bool   `json:"withdrawals_enabled"`
+}
+
+// Validate checks an asset before it's listed
+func (a Asset) Validate() error {
+	switch {
+	case a.Symbol == "":
+		return errors.New("symbol is required")
+	case a.Symbol != strings.ToLower(a.Symbol):
+		return errors.New("symbol should be lower case")
+	case a.Precision < 0 || a.Precision > MaxScale:
+		return fmt.Errorf("precision should be between 0 and %d", MaxScale)
+	}
+	return nil
+}
+
+// Round rounds an amount of the asset to its precision
+func (a Asset) Round(amount decimal.Decimal) decimal.Decimal {
+	return amount.Round(a.Precision)
+}
+
+// Format prints an amount of the asset with all its decimal places
+func (a Asset) Format(amount decimal.Decimal) string {
+	return amount.StringFixed(a.Precision)
+}
+
+var (
+	assetsMu sync.RWMutex
+	assets   = map[string]Asset{}
+)
+
+// RegisterAsset lists an asset, replacing any previous definition with the
+// same symbol
+func RegisterAsset(a Asset) {
+	assetsMu.Lock()
+	defer assetsMu.Unlock()
+	assets[a.Symbol] = a
+}
+
+// DelistAsset delists an asset, false if it wasn't listed
+func DelistAsset(symbol string) bool {
+	assetsMu.Lock()
+	defer assetsMu.Unlock()
+	_, ok := assets[symbol]
+	delete(assets, symbol)
+	return ok
+}
+
+// GetAssets returns the listed assets by symbol
+func GetAssets() []Asset {
+	assetsMu.RLock()
+	defer assetsMu.RUnlock()
+	list := slices.Collect(maps.Values(assets))
+	slices.SortFunc(list, func(a, b Asset) int { return strings.Compare(a.Symbol, b.Symbol) })
+	return list
+}
+
+func GetAsset(symbol string) (Asset, bool) {
+	assetsMu.RLock()
+	defer assetsMu.RUnlock()
+	a, ok := assets[symbol]
+	return a, ok
+}
+
+// ValidateAmount checks an amount of an asset against its precision. Like
+// the pairs, amounts are not restricted until at least one asset is listed.
+func ValidateAmount(symbol string, amount decimal.Decimal) error {
+	assetsMu.RLock()
+	defer assetsMu.RUnlock()
+	if len(assets) == 0 {
+		return nil
+	}
+	a, ok := assets[symbol]
+	if !ok {
+		return ErrUnknownAsset
+	}
+	if !amount.Equal(a.Round(amount)) {
+		return ErrAssetPrecision
+	}
+	return nil
+}
+
+// ValidateWithdrawal checks the amount of a withdrawal and that withdrawals
+// of the asset are enabled
+func ValidateWithdrawal(symbol string, amount decimal.Decimal) error {
+	if err := ValidateAmount(symbol, amount); err != nil {
+		return err
+	}
+	assetsMu.RLock()
+	defer assetsMu.RUnlock()
+	if a, ok := assets[symbol]; ok && !a.WithdrawalsEnabled {
+		return ErrWithdrawalsDisabled
+	}
+	return nil
+}
+
+// tradable checks that both assets of a pair are listed and enabled for
+// trading, when any asset is listed
+func tradable(p Pair) error {
+	assetsMu.RLock()
+	defer assetsMu.RUnlock()
+	if len(assets) == 0 {
+		return nil
+	}
+	for _, symbol := range []string{p.Base, p.Quote} {
+		a, ok := assets[symbol]
+		if !ok {
+			return ErrUnknownAsset
+		}
+		if !a.TradingEnabled {
+			return ErrTradingDisabled
+		}
+	}
+	return nil
+}
+
+// checkAssets checks the assets a pair definition references, the amounts
+// of the pair can't be more precise than its base asset
+func checkAssets(p Pair) error {
+	assetsMu.RLock()
+	defer assetsMu.RUnlock()
+	if len(assets) == 0 {
+		return nil
+	}
+	base, ok := assets[p.Base]
+	if !ok {
+		return fmt.Errorf("base asset %q is not listed", p.Base)
+	}
+	if _, ok := assets[p.Quote]; !ok {
+		return fmt.Errorf("quote asset %q is not listed", p.Quote)
+	}
+	if p.AmountScale > base.Precision {
+		return fmt.Errorf("amount_scale is above the precision of %s, %d", base.Symbol, base.Precision)
+	}
+	return nil
+}
+
+// PairsOfAsset returns the listed pairs trading an asset
+func PairsOfAsset(symbol string) []string {
+	var ids []string
+	for _, p := range GetPairs() {
+		if p.Base == symbol || p.Quote == symbol {
+			ids = append(ids, p.ID)
+		}
+	}
+	return ids
+}
+
+// AssetRepo stores the assets listed through the admin API, they're listed
+// again at startup over those of the config file
+type AssetRepo interface {
+	GetAssets(ctx context.Context) ([]Asset, error)
+	// SaveAsset lists an asset or replaces its definition
+	SaveAsset(ctx context.Context, a Asset) error
+	DeleteAsset(ctx context.Context, symbol string) error
+}
+
+type assetRepo struct {
+	queries      *repository.Queries
+	queryTimeout time.Duration
+}
+
+func (repo *assetRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *assetRepo) GetAssets(ctx context.Context) ([]Asset, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	dbres, err := repo.queries.GetAssets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Asset, len(dbres))
+	for idx, a := range dbres {
+		list[idx] = Asset{
+			Symbol:             a.Symbol,
+			Name:               a.Name,
+			Precision:          a.Precision,
+			TradingEnabled:     a.TradingEnabled,
+			WithdrawalsEnabled: a.WithdrawalsEnabled,
+		}
+	}
+	return list, nil
+}
+
+func (repo *assetRepo) SaveAsset(ctx context.Context, a Asset) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err := repo.queries.UpsertAsset(ctx, repository.UpsertAssetParams{
+		Symbol:             a.Symbol,
+		Name:               a.Name,
+		Precision:          a.Precision,
+		TradingEnabled:     a.TradingEnabled,
+		WithdrawalsEnabled: a.WithdrawalsEnabled,
+	})
+	return err
+}
+
+func (repo *assetRepo) DeleteAsset(ctx context.Context, symbol string) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.DeleteAsset(ctx, symbol)
+}
+
+func NewAssetRepository(dbpool *pgxpool.Pool, queryTimeout time.Duration) AssetRepo {
+	return &assetRepo{
+		queries:      repository.New(dbpool),
+		queryTimeout: queryTimeout,
+	}
+}
@@ -0,0 +1,165 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Actor types, who caused a change of an order, or of a setting for the
+// config file
+const (
+	ACTOR_ACCOUNT     = "account"
+	ACTOR_API_KEY     = "api_key"
+	ACTOR_OPERATOR    = "operator"
+	ACTOR_CONFIG_FILE = "config_file"
+)
+
+// Order statuses, as recorded in the before and after states of history events
+const (
+	STATUS_OPEN             = "open"
+	STATUS_PARTIALLY_FILLED = "partially_filled"
+	STATUS_FILLED           = "filled"
+	STATUS_CANCELLED        = "cancelled"
+	STATUS_REJECTED         = "rejected"
+)
+
+// Metadata keys of the audit fields of history events
+const (
+	versionKey = "version"
+	actorKey   = "actor"
+	beforeKey  = "before"
+	afterKey   = "after"
+)
+
+// Actor is who caused a change of an order, an API key, the operator or the
+// account owning the order when the request didn't say otherwise
+type Actor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// OrderState is what an event changed of an order
+type OrderState struct {
+	Remaining decimal.Decimal `json:"remaining"`
+	Status    string          `json:"status"`
+}
+
+type actorCtxKey struct{}
+
+// WithActor returns a context recording a as the actor of the changes made with it
+func WithActor(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, actorCtxKey{}, a)
+}
+
+// ActorOf returns the actor recorded in ctx, the account owning the order otherwise
+func ActorOf(ctx context.Context, accountId int) Actor {
+	if a, ok := ctx.Value(actorCtxKey{}).(Actor); ok {
+		return a
+	}
+	return Actor{Type: ACTOR_ACCOUNT, ID: strconv.Itoa(accountId)}
+}
+
+// StateAfterFill is the state of an order a fill left remaining of
+func StateAfterFill(remaining decimal.Decimal) OrderState {
+	if remaining.IsPositive() {
+		return OrderState{Remaining: remaining, Status: STATUS_PARTIALLY_FILLED}
+	}
+	return OrderState{Remaining: decimal.Zero, Status: STATUS_FILLED}
+}
+
+// AuditMetadata adds the audit fields of a change to the metadata of its
+// event. before is nil for the creation of an order.
+func AuditMetadata(metadata map[string]any, version int, actor Actor, before *OrderState, after OrderState) map[string]any {
+	if metadata == nil {
+		metadata = make(map[string]any, 4)
+	}
+	metadata[versionKey] = version
+	metadata[actorKey] = actor
+	if before != nil {
+		metadata[beforeKey] = *before
+	}
+	metadata[afterKey] = after
+	return metadata
+}
+
+// createdEvent is the ORDER_CREATED event written along with an order, the
+// first version of it
+func createdEvent(ctx context.Context, o Order) OrderHistoryEvent {
+	ev := OrderHistoryEvent{
+		Name:    ORDER_CREATED,
+		OrderId: o.ID,
+		Metadata: AuditMetadata(nil, 1, ActorOf(ctx, o.AccountID), nil, OrderState{
+			Remaining: o.Amount,
+			Status:    STATUS_OPEN,
+		}),
+	}
+	ev.stamp()
+	return ev
+}
+
+// TimelineEntry is a history event with its audit fields decoded, Details
+// holds the rest of its metadata. Events recorded before the audit fields
+// existed have a zero version and no actor or states.
+type TimelineEntry struct {
+	EventID int64          `json:"event_id"`
+	Version int            `json:"version"`
+	Event   string         `json:"event"`
+	At      time.Time      `json:"at"`
+	Actor   *Actor         `json:"actor"`
+	Before  *OrderState    `json:"before"`
+	After   *OrderState    `json:"after"`
+	Details map[string]any `json:"details"`
+}
+
+// Timeline orders the history of an order by version, the events of the
+// same version by the order they were written in
+func Timeline(events []OrderHistoryEvent) ([]TimelineEntry, error) {
+	timeline := make([]TimelineEntry, len(events))
+	for idx, ev := range events {
+		var audit struct {
+			Version int         `json:"version"`
+			Actor   *Actor      `json:"actor"`
+			Before  *OrderState `json:"before"`
+			After   *OrderState `json:"after"`
+		}
+		// The metadata was decoded from JSON, it's encoded back to read the
+		// audit fields with their types
+		raw, err := json.Marshal(ev.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, &audit); err != nil {
+			return nil, err
+		}
+		details := make(map[string]any, len(ev.Metadata))
+		for k, v := range ev.Metadata {
+			switch k {
+			case versionKey, actorKey, beforeKey, afterKey, SchemaVersionKey:
+				continue
+			}
+			details[k] = v
+		}
+		timeline[idx] = TimelineEntry{
+			EventID: ev.ID,
+			Version: audit.Version,
+			Event:   ev.Name,
+			At:      ev.CreatedAt,
+			Actor:   audit.Actor,
+			Before:  audit.Before,
+			After:   audit.After,
+			Details: details,
+		}
+	}
+	slices.SortFunc(timeline, func(a, b TimelineEntry) int {
+		if a.Version != b.Version {
+			return a.Version - b.Version
+		}
+		return int(a.EventID - b.EventID)
+	})
+	return timeline, nil
+}
@@ -0,0 +1,136 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"order-book/db/replica"
+	repository "order-book/order/repository/gen"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/shopspring/decimal"
+)
+
+var ErrInvalidInterval = errors.New("Interval should be 1m, 1h or 1d")
+
+type CandleInterval string
+
+const (
+	CANDLE_1M CandleInterval = "1m"
+	CANDLE_1H CandleInterval = "1h"
+	CANDLE_1D CandleInterval = "1d"
+)
+
+var CandleIntervals = []CandleInterval{CANDLE_1M, CANDLE_1H, CANDLE_1D}
+
+func (i CandleInterval) Duration() time.Duration {
+	switch i {
+	case CANDLE_1M:
+		return time.Minute
+	case CANDLE_1H:
+		return time.Hour
+	case CANDLE_1D:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// Candle sums up the trades of a pair executed in [OpenTime, OpenTime+Interval)
+type Candle struct {
+	PairID      string          `json:"pair_id"`
+	Interval    CandleInterval  `json:"interval"`
+	OpenTime    time.Time       `json:"open_time"`
+	Open        decimal.Decimal `json:"open"`
+	High        decimal.Decimal `json:"high"`
+	Low         decimal.Decimal `json:"low"`
+	Close       decimal.Decimal `json:"close"`
+	Volume      decimal.Decimal `json:"volume"`
+	QuoteVolume decimal.Decimal `json:"quote_volume"`
+	Trades      int64           `json:"trades"`
+}
+
+type CandleRepo interface {
+	// GetCandles returns up to limit candles opening in [from, to), the oldest first
+	GetCandles(ctx context.Context, tenantId string, pairId string, interval CandleInterval, from time.Time, to time.Time, limit int) ([]Candle, error)
+}
+
+type candleRepo struct {
+	reads        *replica.Router
+	queryTimeout time.Duration
+}
+
+func (repo *candleRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *candleRepo) GetCandles(ctx context.Context, tenantId string, pairId string, interval CandleInterval, from time.Time, to time.Time, limit int) ([]Candle, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	pool, _ := repo.reads.Reader()
+	queries := repository.New(pool)
+	params := repository.GetCandles1mParams{
+		TenantID: tenantId,
+		PairID:   pairId,
+		FromTime: pgtype.Timestamp{Time: from.UTC(), Valid: true},
+		ToTime:   pgtype.Timestamp{Time: to.UTC(), Valid: true},
+		MaxCount: int32(limit),
+	}
+
+	// Every interval has its own continuous aggregate with the same columns
+	var dbres []repository.GetCandles1mRow
+	switch interval {
+	case CANDLE_1M:
+		rows, err := queries.GetCandles1m(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		dbres = rows
+	case CANDLE_1H:
+		rows, err := queries.GetCandles1h(ctx, repository.GetCandles1hParams(params))
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			dbres = append(dbres, repository.GetCandles1mRow(row))
+		}
+	case CANDLE_1D:
+		rows, err := queries.GetCandles1d(ctx, repository.GetCandles1dParams(params))
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			dbres = append(dbres, repository.GetCandles1mRow(row))
+		}
+	default:
+		return nil, ErrInvalidInterval
+	}
+
+	candles := make([]Candle, len(dbres))
+	for idx, row := range dbres {
+		candles[idx] = Candle{
+			PairID:      pairId,
+			Interval:    interval,
+			OpenTime:    row.Bucket.Time,
+			Open:        row.Open,
+			High:        row.High,
+			Low:         row.Low,
+			Close:       row.Close,
+			Volume:      row.Volume,
+			QuoteVolume: row.QuoteVolume,
+			Trades:      row.Trades,
+		}
+	}
+	return candles, nil
+}
+
+// NewCandleRepository reads candles from the Timescale continuous aggregates
+// fed by tbl_trade_ticks, on the pool picked by reads
+func NewCandleRepository(reads *replica.Router, queryTimeout time.Duration) CandleRepo {
+	return &candleRepo{
+		reads:        reads,
+		queryTimeout: queryTimeout,
+	}
+}
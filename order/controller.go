@@ -0,0 +1,668 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"order-book/logger"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+)
+
+var ErrInvalidData = errors.New("ErrInvalidData")
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+func BindTradeRouter(r fiber.Router, trades TradeRepo) {
+	r.Get("/accounts/:id/trades", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		filter := TradeFilter{AccountID: accountId, PairID: c.Query("pair_id")}
+		for param, dst := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+			if v := c.Query(param); v != "" {
+				t, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					c.Status(http.StatusBadRequest)
+					return c.JSON(&Response{
+						Error:   ErrInvalidData,
+						Message: param + " should be an RFC 3339 time",
+					})
+				}
+				*dst = t
+			}
+		}
+		page := c.QueryInt("page", 1)
+		size := c.QueryInt("size", 50)
+
+		res, err := trades.GetTrades(c.UserContext(), filter, page, size)
+		if err != nil {
+			logger.Error("failed to get trades", map[string]any{
+				"account_id": accountId,
+				"error":      err,
+			})
+			return err
+		}
+		for idx, t := range res {
+			res[idx] = t.ForAccount(accountId)
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    res,
+		})
+	})
+
+	r.Get("/accounts/:id/trades/:trade_id", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		tradeId, err := strconv.ParseInt(c.Params("trade_id"), 10, 64)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid ID",
+				Data:    nil,
+			})
+		}
+
+		t, err := trades.GetTrade(c.UserContext(), tradeId)
+		// Trades of other accounts are not disclosed
+		if err == ErrTradeNotFound || (err == nil && t.MakerAccountID != accountId && t.TakerAccountID != accountId) {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The trade not found",
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    t.ForAccount(accountId),
+		})
+	})
+}
+
+// BindOrderRouter serves the orders of an account. Orders are read from the
+// replica when one is configured, an order it doesn't have yet is read from
+// the primary so it can be fetched right after its submission.
+func BindOrderRouter(r fiber.Router, orders OrderRepo) {
+	r.Get("/accounts/:id/orders", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		page := c.QueryInt("page", 1)
+		size := c.QueryInt("size", 50)
+
+		res, err := orders.GetOrders(c.UserContext(), page, size, accountId)
+		if err != nil {
+			logger.Error("failed to get orders", map[string]any{
+				"account_id": accountId,
+				"error":      err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    res.Orders,
+		})
+	})
+
+	r.Get("/accounts/:id/orders/:order_id", func(c *fiber.Ctx) error {
+		accountId, orderId, err := orderParams(c)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		o, err := orders.GetOrderByID(c.UserContext(), orderId)
+		// Orders of other accounts are not disclosed
+		if err == ErrOrderNotFound || (err == nil && o.AccountID != accountId) {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The order not found",
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    o,
+		})
+	})
+
+	r.Get("/accounts/:id/orders/:order_id/history", func(c *fiber.Ctx) error {
+		accountId, orderId, err := orderParams(c)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		o, err := orders.GetOrderByID(c.UserContext(), orderId)
+		if err == ErrOrderNotFound || (err == nil && o.AccountID != accountId) {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The order not found",
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		events, err := orders.GetOrderHistoryByID(c.UserContext(), orderId)
+		if err != nil {
+			logger.Error("failed to get order history", map[string]any{
+				"order_id": orderId,
+				"error":    err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    events,
+		})
+	})
+
+	// The timeline is the history in the order the changes were made, with
+	// who made them and what they changed
+	r.Get("/accounts/:id/orders/:order_id/timeline", func(c *fiber.Ctx) error {
+		accountId, orderId, err := orderParams(c)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		o, err := orders.GetOrderByID(c.UserContext(), orderId)
+		if err == ErrOrderNotFound || (err == nil && o.AccountID != accountId) {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The order not found",
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		events, err := orders.GetOrderHistoryByID(c.UserContext(), orderId)
+		if err != nil {
+			logger.Error("failed to get order history", map[string]any{
+				"order_id": orderId,
+				"error":    err,
+			})
+			return err
+		}
+		timeline, err := Timeline(events)
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    timeline,
+		})
+	})
+}
+
+// PairStatus is a listed pair and whether its trading is halted
+type PairStatus struct {
+	Pair
+	Halted bool `json:"halted"`
+}
+
+// pairRequest is the body of POST /admin/pairs and PUT /admin/pairs/:id,
+// scales that are not set default to DefaultScale
+type pairRequest struct {
+	ID          string          `json:"id"`
+	Base        string          `json:"base"`
+	Quote       string          `json:"quote"`
+	TickSize    decimal.Decimal `json:"tick_size"`
+	LotSize     decimal.Decimal `json:"lot_size"`
+	MinAmount   decimal.Decimal `json:"min_amount"`
+	MinNotional decimal.Decimal `json:"min_notional"`
+	PriceScale  *int32          `json:"price_scale"`
+	AmountScale *int32          `json:"amount_scale"`
+	Status      string          `json:"status"`
+	MakerFee    *float64        `json:"maker_fee"`
+	TakerFee    *float64        `json:"taker_fee"`
+}
+
+func (req pairRequest) pair() Pair {
+	p := Pair{
+		ID:          req.ID,
+		Base:        req.Base,
+		Quote:       req.Quote,
+		TickSize:    req.TickSize,
+		LotSize:     req.LotSize,
+		MinAmount:   req.MinAmount,
+		MinNotional: req.MinNotional,
+		PriceScale:  DefaultScale,
+		AmountScale: DefaultScale,
+		Status:      req.Status,
+		MakerFee:    req.MakerFee,
+		TakerFee:    req.TakerFee,
+	}
+	if req.PriceScale != nil {
+		p.PriceScale = *req.PriceScale
+	}
+	if req.AmountScale != nil {
+		p.AmountScale = *req.AmountScale
+	}
+	if p.Status == "" {
+		p.Status = PAIR_TRADING
+	}
+	return p
+}
+
+// BindPairRouter lists the pairs on GET /admin/pairs, lists one on POST
+// /admin/pairs, changes it on PUT /admin/pairs/:id and delists it on DELETE
+// /admin/pairs/:id. Listed pairs are stored and get a book on every tenant,
+// orders for other pairs are refused. A pair is delisted once no order rests
+// on it. A pair is halted with POST /admin/pairs/:id/halt and resumed with
+// DELETE, every pair at once with /admin/halt, halts don't outlive a
+// restart. Each change is recorded as a CONFIG_CHANGED event.
+func BindPairRouter(r fiber.Router, events *EventWriter, repo PairRepo, books PairBooks) {
+	r.Get("/admin/pairs", func(c *fiber.Ctx) error {
+		list := GetPairs()
+		res := make([]PairStatus, len(list))
+		for idx, p := range list {
+			res[idx] = PairStatus{Pair: p, Halted: IsHalted(p.ID)}
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data: map[string]any{
+				"pairs":  res,
+				"halted": Halts(),
+			},
+		})
+	})
+
+	r.Get("/admin/pairs/:id", func(c *fiber.Ctx) error {
+		p, ok := GetPair(c.Params("id"))
+		if !ok {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: ErrUnknownPair.Error(),
+				Data:    nil,
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    PairStatus{Pair: p, Halted: IsHalted(p.ID)},
+		})
+	})
+
+	// save stores and lists the pair of the request, then opens its book
+	save := func(c *fiber.Ctx, req pairRequest, create bool) error {
+		p := req.pair()
+		if err := p.Validate(); err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrInvalidData,
+				Message: err.Error(),
+			})
+		}
+		before, existed := GetPair(p.ID)
+		if create && existed {
+			c.Status(http.StatusConflict)
+			return c.JSON(&Response{
+				Error:   ErrPairExists,
+				Message: ErrPairExists.Error(),
+			})
+		}
+		if err := repo.SavePair(c.UserContext(), p); err != nil {
+			logger.Error("failed to save a pair", map[string]any{
+				"pair_id": p.ID,
+				"error":   err,
+			})
+			return err
+		}
+		RegisterPair(p)
+		books.OpenPair(p)
+		if existed {
+			auditSetting(c, events, "pairs."+p.ID, before, p)
+		} else {
+			auditSetting(c, events, "pairs."+p.ID, nil, p)
+		}
+		status, message := http.StatusOK, "Pair updated"
+		if !existed {
+			status, message = http.StatusCreated, "Pair listed"
+		}
+		c.Status(status)
+		return c.JSON(&Response{
+			Message: message,
+			Data:    p,
+		})
+	}
+
+	r.Post("/admin/pairs", func(c *fiber.Ctx) error {
+		var req pairRequest
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		return save(c, req, true)
+	})
+
+	r.Put("/admin/pairs/:id", func(c *fiber.Ctx) error {
+		var req pairRequest
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		req.ID = strings.Clone(c.Params("id"))
+		return save(c, req, false)
+	})
+
+	r.Delete("/admin/pairs/:id", func(c *fiber.Ctx) error {
+		pairId := strings.Clone(c.Params("id"))
+		before, existed := GetPair(pairId)
+		if !existed || !DelistPair(pairId) {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: ErrUnknownPair.Error(),
+				Data:    nil,
+			})
+		}
+		// Delisted first so no order rests on the pair while its books close
+		if err := books.ClosePair(pairId); err != nil {
+			RegisterPair(before)
+			c.Status(http.StatusConflict)
+			return c.JSON(&Response{
+				Error:   err,
+				Message: err.Error(),
+			})
+		}
+		if err := repo.DeletePair(c.UserContext(), pairId); err != nil {
+			logger.Error("failed to delete a pair", map[string]any{
+				"pair_id": pairId,
+				"error":   err,
+			})
+			return err
+		}
+		auditSetting(c, events, "pairs."+pairId, before, nil)
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Pair delisted",
+			Data:    nil,
+		})
+	})
+
+	// The pair of the path, or all
+	pairOf := func(c *fiber.Ctx, all bool) string {
+		if all {
+			return ALL_PAIRS
+		}
+		return strings.Clone(c.Params("id"))
+	}
+	halt := func(all bool) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			pairId := pairOf(c, all)
+			if !HaltPair(pairId) {
+				auditSetting(c, events, "halts."+pairId, false, true)
+			}
+			c.Status(http.StatusOK)
+			return c.JSON(&Response{
+				Message: "Trading halted",
+				Data:    Halts(),
+			})
+		}
+	}
+	resume := func(all bool) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			pairId := pairOf(c, all)
+			if ResumePair(pairId) {
+				auditSetting(c, events, "halts."+pairId, true, false)
+			}
+			c.Status(http.StatusOK)
+			return c.JSON(&Response{
+				Message: "Trading resumed",
+				Data:    Halts(),
+			})
+		}
+	}
+	r.Post("/admin/pairs/:id/halt", halt(false))
+	r.Delete("/admin/pairs/:id/halt", resume(false))
+	r.Post("/admin/halt", halt(true))
+	r.Delete("/admin/halt", resume(true))
+}
+
+// assetRequest is the body of POST /admin/assets and PUT
+// /admin/assets/:symbol, trading and withdrawals are enabled unless set
+type assetRequest struct {
+	Symbol             string `json:"symbol"`
+	Name               string `json:"name"`
+	Precision          *int32 `json:"precision"`
+	TradingEnabled     *bool  `json:"trading_enabled"`
+	WithdrawalsEnabled *bool  `json:"withdrawals_enabled"`
+}
+
+func (req assetRequest) asset() Asset {
+	a := Asset{
+		Symbol:             req.Symbol,
+		Name:               req.Name,
+		TradingEnabled:     true,
+		WithdrawalsEnabled: true,
+	}
+	if req.Precision != nil {
+		a.Precision = *req.Precision
+	}
+	if req.TradingEnabled != nil {
+		a.TradingEnabled = *req.TradingEnabled
+	}
+	if req.WithdrawalsEnabled != nil {
+		a.WithdrawalsEnabled = *req.WithdrawalsEnabled
+	}
+	return a
+}
+
+// BindAssetRouter lists the assets on GET /assets, for clients to format and
+// check their amounts. The operator lists one on POST /admin/assets, changes
+// it on PUT /admin/assets/:symbol and delists it on DELETE once no listed
+// pair trades it. Listed assets are stored, each change is recorded as a
+// CONFIG_CHANGED event.
+func BindAssetRouter(r fiber.Router, events *EventWriter, repo AssetRepo) {
+	r.Get("/assets", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    GetAssets(),
+		})
+	})
+
+	r.Get("/assets/:symbol", func(c *fiber.Ctx) error {
+		a, ok := GetAsset(c.Params("symbol"))
+		if !ok {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: ErrUnknownAsset.Error(),
+				Data:    nil,
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    a,
+		})
+	})
+
+	save := func(c *fiber.Ctx, req assetRequest, create bool) error {
+		if req.Precision == nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrInvalidData,
+				Message: "precision is required",
+			})
+		}
+		a := req.asset()
+		if err := a.Validate(); err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrInvalidData,
+				Message: err.Error(),
+			})
+		}
+		before, existed := GetAsset(a.Symbol)
+		if create && existed {
+			c.Status(http.StatusConflict)
+			return c.JSON(&Response{
+				Error:   ErrAssetExists,
+				Message: ErrAssetExists.Error(),
+			})
+		}
+		// The pairs trading the asset can't be more precise than it
+		for _, pairId := range PairsOfAsset(a.Symbol) {
+			if p, ok := GetPair(pairId); ok && p.Base == a.Symbol && p.AmountScale > a.Precision {
+				c.Status(http.StatusConflict)
+				return c.JSON(&Response{
+					Error:   ErrInvalidData,
+					Message: fmt.Sprintf("%s trades with amount_scale %d, above the precision", pairId, p.AmountScale),
+				})
+			}
+		}
+		if err := repo.SaveAsset(c.UserContext(), a); err != nil {
+			logger.Error("failed to save an asset", map[string]any{
+				"asset": a.Symbol,
+				"error": err,
+			})
+			return err
+		}
+		RegisterAsset(a)
+		if existed {
+			auditSetting(c, events, "assets."+a.Symbol, before, a)
+		} else {
+			auditSetting(c, events, "assets."+a.Symbol, nil, a)
+		}
+		status, message := http.StatusOK, "Asset updated"
+		if !existed {
+			status, message = http.StatusCreated, "Asset listed"
+		}
+		c.Status(status)
+		return c.JSON(&Response{
+			Message: message,
+			Data:    a,
+		})
+	}
+
+	r.Post("/admin/assets", func(c *fiber.Ctx) error {
+		var req assetRequest
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		return save(c, req, true)
+	})
+
+	r.Put("/admin/assets/:symbol", func(c *fiber.Ctx) error {
+		var req assetRequest
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		req.Symbol = strings.Clone(c.Params("symbol"))
+		return save(c, req, false)
+	})
+
+	r.Delete("/admin/assets/:symbol", func(c *fiber.Ctx) error {
+		symbol := strings.Clone(c.Params("symbol"))
+		before, existed := GetAsset(symbol)
+		if !existed {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: ErrUnknownAsset.Error(),
+				Data:    nil,
+			})
+		}
+		if pairs := PairsOfAsset(symbol); len(pairs) > 0 {
+			c.Status(http.StatusConflict)
+			return c.JSON(&Response{
+				Error:   ErrAssetInUse,
+				Message: ErrAssetInUse.Error() + ": " + strings.Join(pairs, ", "),
+			})
+		}
+		if err := repo.DeleteAsset(c.UserContext(), symbol); err != nil {
+			logger.Error("failed to delete an asset", map[string]any{
+				"asset": symbol,
+				"error": err,
+			})
+			return err
+		}
+		DelistAsset(symbol)
+		auditSetting(c, events, "assets."+symbol, before, nil)
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Asset delisted",
+			Data:    nil,
+		})
+	})
+}
+
+// auditSetting logs and records a setting changed through the admin API
+func auditSetting(c *fiber.Ctx, events *EventWriter, setting string, before any, after any) {
+	metadata := map[string]any{
+		"setting": setting,
+		"before":  before,
+		"after":   after,
+		"source":  "admin",
+		"actor":   ActorOf(c.UserContext(), 0),
+	}
+	logger.Info("setting changed", metadata)
+	err := events.AddEvent(context.WithoutCancel(c.UserContext()), OrderHistoryEvent{
+		Name:     CONFIG_CHANGED,
+		Metadata: metadata,
+	})
+	if err != nil {
+		logger.Error("failed to add the audit event of a setting change", map[string]any{
+			"setting": setting,
+			"error":   err,
+		})
+	}
+}
+
+// orderParams parses the account and order IDs of the path
+func orderParams(c *fiber.Ctx) (accountId int, orderId int, err error) {
+	accountId, err = strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return 0, 0, errors.New("Invalid account ID")
+	}
+	orderId, err = strconv.Atoi(c.Params("order_id"))
+	if err != nil {
+		return 0, 0, errors.New("Invalid ID")
+	}
+	return accountId, orderId, nil
+}
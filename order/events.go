@@ -0,0 +1,199 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var ErrEventWriterClosed = errors.New("Event writer is closed")
+
+const (
+	minFlushBackoff = 100 * time.Millisecond
+	maxFlushBackoff = 30 * time.Second
+	// maxFlushAttempts bounds the attempts at a batch before its events are
+	// written one by one
+	maxFlushAttempts = 10
+)
+
+// EventWriter is an OrderRepo whose AddEvent queues the event and returns,
+// the queued events are written in batches by a single goroutine. A batch is
+// written once it is full or FlushInterval after its first event. AddEvent
+// blocks while BufferSize events are waiting, which bounds the memory used
+// when the database falls behind. A batch that fails is retried with a
+// backoff, holding back the next ones, so a short outage fills the buffer
+// rather than losing history. One the database refuses, or still fails
+// after maxFlushAttempts, is written an event at a time and the events that
+// fail on their own are logged in full and dropped, so a bad event never
+// halts the engine.
+type EventWriter struct {
+	OrderRepo
+	mu            sync.RWMutex
+	closed        bool
+	events        chan OrderHistoryEvent
+	done          chan struct{}
+	abort         chan struct{}
+	abortOnce     sync.Once
+	batchSize     int
+	flushInterval time.Duration
+}
+
+func NewEventWriter(repo OrderRepo, batchSize int, flushInterval time.Duration, bufferSize int) *EventWriter {
+	w := &EventWriter{
+		OrderRepo:     repo,
+		events:        make(chan OrderHistoryEvent, bufferSize),
+		done:          make(chan struct{}),
+		abort:         make(chan struct{}),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+	}
+	go w.run()
+	return w
+}
+
+func (w *EventWriter) AddEvent(ctx context.Context, ev OrderHistoryEvent) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return ErrEventWriterClosed
+	}
+	select {
+	case w.events <- ev:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting events and waits for the queued ones to be written.
+// Once ctx is done it gives up on the batch being retried, the events it
+// couldn't write are logged as lost.
+func (w *EventWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.events)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		w.abortOnce.Do(func() { close(w.abort) })
+		return ctx.Err()
+	}
+}
+
+func (w *EventWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]OrderHistoryEvent, 0, w.batchSize)
+	for {
+		select {
+		case ev, ok := <-w.events:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			if len(batch) == 0 {
+				ticker.Reset(w.flushInterval)
+			}
+			batch = append(batch, ev)
+			if len(batch) >= w.batchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (w *EventWriter) flush(batch []OrderHistoryEvent) {
+	if len(batch) == 0 {
+		return
+	}
+	for attempt := 0; ; attempt++ {
+		err := w.OrderRepo.AddEvents(context.Background(), batch)
+		if err == nil {
+			return
+		}
+		if refused(err) || attempt+1 >= maxFlushAttempts {
+			w.writeEach(batch, err)
+			return
+		}
+		backoff := min(minFlushBackoff<<min(attempt, 20), maxFlushBackoff)
+		repoLog.Error("failed to write order history events, they're retried", map[string]any{
+			"count":    len(batch),
+			"attempts": attempt + 1,
+			"retry_in": backoff.String(),
+			"error":    err,
+		})
+		select {
+		case <-time.After(backoff):
+		case <-w.abort:
+			repoLog.Error("lost order history events the writer was closed with", map[string]any{
+				"count": len(batch),
+				"error": err,
+			})
+			return
+		}
+	}
+}
+
+// writeEach writes the events of a batch that failed one by one, logging
+// those that fail whole so they can be written again by hand
+func (w *EventWriter) writeEach(batch []OrderHistoryEvent, batchErr error) {
+	repoLog.Error("failed to write a batch of order history events, they're written one by one", map[string]any{
+		"count": len(batch),
+		"error": batchErr,
+	})
+	for _, ev := range batch {
+		if err := w.OrderRepo.AddEvent(context.Background(), ev); err != nil {
+			repoLog.Error("dropped an order history event that failed to be written", map[string]any{
+				"order_id":   ev.OrderId,
+				"name":       ev.Name,
+				"metadata":   ev.Metadata,
+				"created_at": ev.CreatedAt,
+				"error":      err,
+			})
+		}
+	}
+}
+
+// refused reports whether the database rejected the data itself, a data
+// exception or an integrity violation, which no retry gets past
+func refused(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23")
+}
+
+// discardingOrderRepo is an OrderRepo that drops the history events
+type discardingOrderRepo struct {
+	OrderRepo
+}
+
+func (discardingOrderRepo) AddEvent(ctx context.Context, ev OrderHistoryEvent) error {
+	return nil
+}
+
+func (discardingOrderRepo) AddEvents(ctx context.Context, evs []OrderHistoryEvent) error {
+	return nil
+}
+
+// NewDiscardingOrderRepository creates orders with repo but drops the events
+// the engine records while matching, their history only holds ORDER_CREATED
+func NewDiscardingOrderRepository(repo OrderRepo) OrderRepo {
+	return discardingOrderRepo{OrderRepo: repo}
+}
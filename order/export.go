@@ -0,0 +1,119 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"order-book/db/replica"
+	repository "order-book/order/repository/gen"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ExportFilter selects the rows of an export executed or created in
+// [From, To), zero AccountID and PairID match every account and pair
+type ExportFilter struct {
+	AccountID int
+	PairID    string
+	From      time.Time
+	To        time.Time
+}
+
+// ExportedEvent is a history event with the account and the pair of its
+// order, both zero for an event without one
+type ExportedEvent struct {
+	OrderHistoryEvent
+	AccountID int
+	PairID    string
+}
+
+// ExportRepo pages through the trades and the history events of a range by
+// ID, so an export reads a page at a time however long the range
+type ExportRepo interface {
+	// GetTradesPage returns up to limit trades with an ID above afterId
+	GetTradesPage(ctx context.Context, filter ExportFilter, afterId int64, limit int) ([]Trade, error)
+	// GetEventsPage returns up to limit history events with an ID above afterId
+	GetEventsPage(ctx context.Context, filter ExportFilter, afterId int64, limit int) ([]ExportedEvent, error)
+}
+
+type exportRepo struct {
+	reads        *replica.Router
+	queryTimeout time.Duration
+}
+
+func (repo *exportRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *exportRepo) GetTradesPage(ctx context.Context, filter ExportFilter, afterId int64, limit int) ([]Trade, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	pool, _ := repo.reads.Reader()
+	dbres, err := repository.New(pool).GetTradesPage(ctx, repository.GetTradesPageParams{
+		AccountID: int32(filter.AccountID),
+		PairID:    filter.PairID,
+		FromTime:  pgtype.Timestamp{Time: filter.From.UTC(), Valid: true},
+		ToTime:    pgtype.Timestamp{Time: filter.To.UTC(), Valid: true},
+		AfterID:   afterId,
+		MaxCount:  int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	trades := make([]Trade, len(dbres))
+	for idx, t := range dbres {
+		trades[idx] = convertTrade(t)
+	}
+	return trades, nil
+}
+
+func (repo *exportRepo) GetEventsPage(ctx context.Context, filter ExportFilter, afterId int64, limit int) ([]ExportedEvent, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	pool, _ := repo.reads.Reader()
+	dbres, err := repository.New(pool).GetOrderEventsPage(ctx, repository.GetOrderEventsPageParams{
+		AccountID: int32(filter.AccountID),
+		PairID:    filter.PairID,
+		FromTime:  pgtype.Timestamp{Time: filter.From.UTC(), Valid: true},
+		ToTime:    pgtype.Timestamp{Time: filter.To.UTC(), Valid: true},
+		AfterID:   afterId,
+		MaxCount:  int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	events := make([]ExportedEvent, len(dbres))
+	for idx, ev := range dbres {
+		events[idx] = ExportedEvent{
+			OrderHistoryEvent: OrderHistoryEvent{
+				ID:        ev.ID,
+				Name:      ev.Event,
+				OrderId:   int(ev.OrderID.Int64),
+				CreatedAt: ev.CreatedAt.Time,
+			},
+			AccountID: int(ev.AccountID.Int32),
+			PairID:    ev.PairID.String,
+		}
+		if len(ev.Metadata) > 0 {
+			if err := json.Unmarshal(ev.Metadata, &events[idx].Metadata); err != nil {
+				return nil, err
+			}
+		}
+		if events[idx].OrderHistoryEvent, err = Upcast(events[idx].OrderHistoryEvent); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// NewExportRepository reads the exports on the pool picked by reads, the
+// replica when it's caught up
+func NewExportRepository(reads *replica.Router, queryTimeout time.Duration) ExportRepo {
+	return &exportRepo{
+		reads:        reads,
+		queryTimeout: queryTimeout,
+	}
+}
@@ -0,0 +1,77 @@
+package order
+
+import (
+	"context"
+	repository "order-book/order/repository/gen"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Cancellation is a recorded cancel of an order
+type Cancellation struct {
+	OrderID int
+	At      time.Time
+}
+
+// FlowRepo reads back the order flow recorded in the history, to replay it
+type FlowRepo interface {
+	// GetOrdersCreatedBetween returns the orders created in [from, to) in
+	// the order they were created, with their original amounts
+	GetOrdersCreatedBetween(ctx context.Context, from time.Time, to time.Time) ([]Order, error)
+	GetCancellationsBetween(ctx context.Context, from time.Time, to time.Time) ([]Cancellation, error)
+}
+
+type flowRepo struct {
+	queries      *repository.Queries
+	queryTimeout time.Duration
+}
+
+func NewFlowRepository(dbpool *pgxpool.Pool, queryTimeout time.Duration) FlowRepo {
+	return &flowRepo{
+		queries:      repository.New(dbpool),
+		queryTimeout: queryTimeout,
+	}
+}
+
+func (repo *flowRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *flowRepo) GetOrdersCreatedBetween(ctx context.Context, from time.Time, to time.Time) ([]Order, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	dbres, err := repo.queries.GetOrdersCreatedBetween(ctx, repository.GetOrdersCreatedBetweenParams{
+		FromTime: pgtype.Timestamp{Time: from.UTC(), Valid: true},
+		ToTime:   pgtype.Timestamp{Time: to.UTC(), Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	orders := make([]Order, len(dbres))
+	for idx, o := range dbres {
+		orders[idx] = convertOrder(o)
+	}
+	return orders, nil
+}
+
+func (repo *flowRepo) GetCancellationsBetween(ctx context.Context, from time.Time, to time.Time) ([]Cancellation, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	dbres, err := repo.queries.GetCancellationsBetween(ctx, repository.GetCancellationsBetweenParams{
+		FromTime: pgtype.Timestamp{Time: from.UTC(), Valid: true},
+		ToTime:   pgtype.Timestamp{Time: to.UTC(), Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	cancellations := make([]Cancellation, len(dbres))
+	for idx, c := range dbres {
+		cancellations[idx] = Cancellation{OrderID: int(c.OrderID.Int64), At: c.CreatedAt.Time}
+	}
+	return cancellations, nil
+}
@@ -2,19 +2,29 @@ package order
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
+	"errors"
+	"order-book/db/replica"
 	"order-book/logger"
 	repository "order-book/order/repository/gen"
-	"strconv"
+	"strings"
 	"time"
 
-	"github.com/jmoiron/sqlx"
-	"github.com/sqlc-dev/pqtype"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
 )
 
+var ErrOrderNotFound = errors.New("Order not found")
+
+// repoLog is the logger of the repositories, its level is set as repo
+var repoLog = logger.Component("repo")
+
+// OrderList is a price level, Amount is the total resting at it
 type OrderList struct {
-	List []Order
+	List   []Order
+	Amount decimal.Decimal
 }
 
 type OrderType int
@@ -28,20 +38,100 @@ func (ot OrderType) String() string {
 	return []string{"ASK", "BID"}[ot]
 }
 
+// quoteAssets are the assets pair IDs can be quoted in, ordered so that
+// "btcusdt" resolves to usdt rather than a shorter suffix.
+var quoteAssets = []string{"usdt", "usdc", "busd", "eur", "usd", "btc", "eth", "bnb"}
+
+// SplitPairID splits a pair ID like "btcusdt" into its base and quote assets.
+// Registered pairs are used as defined, other IDs are split by known quote assets.
+func SplitPairID(pairId string) (base string, quote string, ok bool) {
+	if base, quote, ok := registeredSplit(pairId); ok {
+		return base, quote, true
+	}
+	pairId = strings.ToLower(pairId)
+	for _, q := range quoteAssets {
+		if len(pairId) > len(q) && strings.HasSuffix(pairId, q) {
+			return strings.TrimSuffix(pairId, q), q, true
+		}
+	}
+	return "", "", false
+}
+
+// Order history event names. TARGET_HIT is a fill of a resting order,
+// ORDER_FILLED one of the incoming order. ORDER_REJECTED has no order ID, the
+// order never reached the book, nor has CONFIG_CHANGED, a setting changed
+// while the engine ran.
+const (
+	ORDER_CREATED   = "ORDER_CREATED"
+	ORDER_CANCELLED = "ORDER_CANCELLED"
+	TARGET_HIT      = "TARGET_HIT"
+	ORDER_FILLED    = "ORDER_FILLED"
+	ORDER_REJECTED  = "ORDER_REJECTED"
+	CONFIG_CHANGED  = "CONFIG_CHANGED"
+)
+
+// OrderHistoryEvent is an entry of the order history. ID and CreatedAt are
+// set by the database and ignored when adding events.
 type OrderHistoryEvent struct {
-	Name     string
-	OrderId  int
-	Metadata map[string]any
+	ID        int64
+	Name      string
+	OrderId   int
+	Metadata  map[string]any
+	CreatedAt time.Time
 }
 
+// Order prices and amounts are exact decimals, they are encoded as JSON
+// strings and accepted as either strings or numbers.
 type Order struct {
-	Price     float64   `json:"price"`
-	Amount    float64   `json:"amount"`
-	PairID    string    `json:"pair_id"`
-	ID        int       `json:"id"`
-	AccountID int       `json:"account_id"`
-	CreatedAt time.Time `json:"created_at"`
-	Type      OrderType `json:"type"`
+	Price     decimal.Decimal `json:"price"`
+	Amount    decimal.Decimal `json:"amount"`
+	PairID    string          `json:"pair_id"`
+	ID        int             `json:"id"`
+	AccountID int             `json:"account_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Type      OrderType       `json:"type"`
+	// Version is that of the last history event of the order, every change
+	// increments it
+	Version int `json:"version,omitempty"`
+	// PostOnly orders only rest on the book, one that would match on
+	// arrival is rejected. It's checked on arrival and isn't persisted.
+	PostOnly bool `json:"post_only,omitempty"`
+	// Tag and StrategyID are free-form labels of the client, echoed in the
+	// fills and updates of the order
+	Tag        string `json:"tag,omitempty"`
+	StrategyID string `json:"strategy_id,omitempty"`
+}
+
+// OrderEvent is published by the engine when an order enters or leaves the book
+type OrderEvent struct {
+	Name  string    `json:"name"`
+	Order Order     `json:"order"`
+	At    time.Time `json:"at"`
+}
+
+// Trade is a single fill between a resting (maker) order and an incoming (taker) order.
+// Fees are charged in the quote asset.
+type Trade struct {
+	ID             int64           `json:"id"`
+	PairID         string          `json:"pair_id"`
+	Price          decimal.Decimal `json:"price"`
+	Amount         decimal.Decimal `json:"amount"`
+	MakerOrderID   int             `json:"maker_order_id"`
+	TakerOrderID   int             `json:"taker_order_id"`
+	MakerAccountID int             `json:"maker_account_id"`
+	TakerAccountID int             `json:"taker_account_id"`
+	TakerSide      OrderType       `json:"taker_side"`
+	MakerFee       decimal.Decimal `json:"maker_fee"`
+	TakerFee       decimal.Decimal `json:"taker_fee"`
+	// MakerRebate is paid to the maker when its fee rate is negative, its
+	// MakerFee is zero then
+	MakerRebate decimal.Decimal `json:"maker_rebate"`
+	ExecutedAt  time.Time       `json:"executed_at"`
+	// The tags and strategy IDs of the orders of each side
+	MakerTag        string `json:"maker_tag,omitempty"`
+	MakerStrategyID string `json:"maker_strategy_id,omitempty"`
+	TakerTag        string `json:"taker_tag,omitempty"`
+	TakerStrategyID string `json:"taker_strategy_id,omitempty"`
 }
 
 type paginatedOrders struct {
@@ -49,38 +139,89 @@ type paginatedOrders struct {
 	Total  int
 }
 
+// OrderRepo methods stop waiting on the database once ctx is done or the
+// query timeout of the repository has passed, whichever comes first.
 type OrderRepo interface {
-	AddEvent(ev OrderHistoryEvent) error
-	GetOrders(page int, size int, accountId int) (*paginatedOrders, error)
-	GetOrderByID(id int) (Order, error)
-	GetOrderHistoryByID(id int) ([]OrderHistoryEvent, error)
-	CreateOrder(pairID string, price float64, amount float64, accountID int, orderType OrderType) (Order, error)
+	AddEvent(ctx context.Context, ev OrderHistoryEvent) error
+	// AddEvents writes a batch of events with a single COPY
+	AddEvents(ctx context.Context, evs []OrderHistoryEvent) error
+	GetOrders(ctx context.Context, page int, size int, accountId int) (*paginatedOrders, error)
+	GetOrderByID(ctx context.Context, id int) (Order, error)
+	GetOrderHistoryByID(ctx context.Context, id int) ([]OrderHistoryEvent, error)
+	CreateOrder(ctx context.Context, pairID string, price decimal.Decimal, amount decimal.Decimal, accountID int, orderType OrderType, tag string, strategyId string) (Order, error)
 }
 
 type orderRepo struct {
-	queries *repository.Queries
-	dbpool  *sqlx.DB
+	queries      *repository.Queries
+	dbpool       *pgxpool.Pool
+	reads        *replica.Router
+	queryTimeout time.Duration
 }
 
-func (repo *orderRepo) AddEvent(ev OrderHistoryEvent) error {
+// withTimeout bounds a query by the repository timeout, zero leaves ctx as is
+func (repo *orderRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+// readQueries runs reads on the replica when it's caught up, fromReplica
+// tells whether a missing row may only not be replicated yet
+func (repo *orderRepo) readQueries() (queries *repository.Queries, fromReplica bool) {
+	pool, fromReplica := repo.reads.Reader()
+	if !fromReplica {
+		return repo.queries, false
+	}
+	return repository.New(pool), true
+}
+
+func (repo *orderRepo) AddEvent(ctx context.Context, ev OrderHistoryEvent) error {
+	ev.stamp()
 	jsonRawMsg, err := json.Marshal(ev.Metadata)
 	if err != nil {
 		return err
 	}
-	err = repo.queries.InsertOneOrderHistoryEvent(context.Background(), repository.InsertOneOrderHistoryEventParams{
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	err = repo.queries.InsertOneOrderHistoryEvent(ctx, repository.InsertOneOrderHistoryEventParams{
 		Event:    ev.Name,
-		Metadata: pqtype.NullRawMessage{RawMessage: jsonRawMsg},
+		OrderID:  pgtype.Int8{Int64: int64(ev.OrderId), Valid: ev.OrderId != 0},
+		Metadata: jsonRawMsg,
 	})
 
 	return err
 }
 
-func (repo *orderRepo) GetOrders(page int, size int, accountId int) (*paginatedOrders, error) {
+func (repo *orderRepo) AddEvents(ctx context.Context, evs []OrderHistoryEvent) error {
+	rows := make([]repository.InsertOrderHistoryEventsParams, len(evs))
+	for idx, ev := range evs {
+		ev.stamp()
+		jsonRawMsg, err := json.Marshal(ev.Metadata)
+		if err != nil {
+			return err
+		}
+		rows[idx] = repository.InsertOrderHistoryEventsParams{
+			Event:    ev.Name,
+			OrderID:  pgtype.Int8{Int64: int64(ev.OrderId), Valid: ev.OrderId != 0},
+			Metadata: jsonRawMsg,
+		}
+	}
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err := repo.queries.InsertOrderHistoryEvents(ctx, rows)
+	return err
+}
+
+func (repo *orderRepo) GetOrders(ctx context.Context, page int, size int, accountId int) (*paginatedOrders, error) {
 	var offset int32
 	offset = int32(max(0, page-1) * size)
 
-	dbres, err := repo.queries.GetOrders(context.Background(), repository.GetOrdersParams{
-		AccountID: sql.NullInt32{Int32: int32(accountId)},
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	queries, _ := repo.readQueries()
+	dbres, err := queries.GetOrders(ctx, repository.GetOrdersParams{
+		AccountID: pgtype.Int4{Int32: int32(accountId), Valid: true},
 		Offset:    offset,
 		Limit:     int32(size),
 	})
@@ -89,11 +230,7 @@ func (repo *orderRepo) GetOrders(page int, size int, accountId int) (*paginatedO
 	}
 	var orders = make([]Order, len(dbres))
 	for idx, ord := range dbres {
-		o, err := convertOrder(ord)
-		if err != nil {
-			return nil, err
-		}
-		orders[idx] = o
+		orders[idx] = convertOrder(ord)
 	}
 	return &paginatedOrders{
 		Orders: orders,
@@ -101,87 +238,149 @@ func (repo *orderRepo) GetOrders(page int, size int, accountId int) (*paginatedO
 	}, nil
 }
 
-func (repo *orderRepo) GetOrderByID(id int) (Order, error) {
-	res, err := repo.queries.GetOneById(context.Background(), int64(id))
-	order, err := convertOrder(res)
-	return order, err
+func (repo *orderRepo) GetOrderByID(ctx context.Context, id int) (Order, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	queries, fromReplica := repo.readQueries()
+	res, err := queries.GetOneById(ctx, int64(id))
+	// An order read right after its submission may not be replicated yet
+	if errors.Is(err, pgx.ErrNoRows) && fromReplica {
+		res, err = repo.queries.GetOneById(ctx, int64(id))
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Order{}, ErrOrderNotFound
+	}
+	if err != nil {
+		return Order{}, err
+	}
+	return convertOrder(res), nil
 }
 
-func (repo *orderRepo) CreateOrder(pairID string, price float64, amount float64, accountID int, orderType OrderType) (Order, error) {
-	tx, err := repo.dbpool.Begin()
+func (repo *orderRepo) CreateOrder(ctx context.Context, pairID string, price decimal.Decimal, amount decimal.Decimal, accountID int, orderType OrderType, tag string, strategyId string) (Order, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	tx, err := repo.dbpool.Begin(ctx)
 	if err != nil {
 		return Order{}, err
 	}
-	defer tx.Rollback()
+	// Rolling back after a timeout still has to reach the database
+	defer tx.Rollback(context.WithoutCancel(ctx))
 
 	qtx := repo.queries.WithTx(tx)
-	createdOrder, err := qtx.CreateOrder(context.Background(), repository.CreateOrderParams{
-		PairID:    pairID,
-		Price:     strconv.FormatFloat(price, 'f', -1, 64),
-		Amount:    strconv.FormatFloat(amount, 'f', -1, 64),
-		AccountID: sql.NullInt32{Int32: int32(accountID)},
-		OrderType: int32(orderType),
+	createdOrder, err := qtx.CreateOrder(ctx, repository.CreateOrderParams{
+		PairID:     pairID,
+		Price:      price,
+		Amount:     amount,
+		AccountID:  pgtype.Int4{Int32: int32(accountID), Valid: true},
+		OrderType:  int32(orderType),
+		Tag:        tag,
+		StrategyID: strategyId,
 	})
-	err = qtx.InsertOneOrderHistoryEvent(context.Background(), repository.InsertOneOrderHistoryEventParams{
-		Event:   "ORDER_CREATED",
-		OrderID: sql.NullInt64{Int64: int64(createdOrder.ID)},
-	})
-
 	if err != nil {
-		logger.Error("failed to insert order history event, rolling back", map[string]any{
-			"order_id": createdOrder.ID,
-			"error":    err,
-		})
 		return Order{}, err
 	}
+	created := createdEvent(ctx, convertOrder(createdOrder))
+	jsonRawMsg, err := json.Marshal(created.Metadata)
+	if err != nil {
+		return Order{}, err
+	}
+	err = qtx.InsertOneOrderHistoryEvent(ctx, repository.InsertOneOrderHistoryEventParams{
+		Event:    ORDER_CREATED,
+		OrderID:  pgtype.Int8{Int64: int64(createdOrder.ID), Valid: true},
+		Metadata: jsonRawMsg,
+	})
 
-	if err := tx.Commit(); err != nil {
-		logger.Error("failed to commit transaction, rolling back", map[string]any{
+	if err != nil {
+		repoLog.Error("failed to insert order history event, rolling back", map[string]any{
 			"order_id": createdOrder.ID,
 			"error":    err,
 		})
 		return Order{}, err
 	}
 
-	o, err := convertOrder(createdOrder)
-	if err != nil {
-		logger.Error("failed to convert order", map[string]any{
+	if err := tx.Commit(ctx); err != nil {
+		repoLog.Error("failed to commit transaction, rolling back", map[string]any{
 			"order_id": createdOrder.ID,
 			"error":    err,
 		})
 		return Order{}, err
 	}
-	return o, err
-}
 
-func (repo *orderRepo) GetOrderHistoryByID(id int) ([]OrderHistoryEvent, error) {
-	res, err := repo.GetOrderHistoryByID(id)
-	return res, err
+	res := convertOrder(createdOrder)
+	res.Version = 1
+	return res, nil
 }
 
-func NewOrderRepository(dbpool *sqlx.DB) OrderRepo {
-	return &orderRepo{
-		queries: repository.New(dbpool),
-		dbpool:  dbpool,
+func (repo *orderRepo) GetOrderHistoryByID(ctx context.Context, id int) ([]OrderHistoryEvent, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	// Events are never older than their order, bounding the query by its
+	// creation time skips the partitions of earlier months. An order the
+	// replica doesn't have yet is read with its history from the primary.
+	queries, fromReplica := repo.readQueries()
+	ord, err := queries.GetOneById(ctx, int64(id))
+	if errors.Is(err, pgx.ErrNoRows) && fromReplica {
+		queries = repo.queries
+		ord, err = queries.GetOneById(ctx, int64(id))
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrOrderNotFound
 	}
-}
-
-func convertOrder(ord repository.TblOrder) (res Order, err error) {
-	amount, err := strconv.ParseFloat(ord.Amount, 64)
 	if err != nil {
-		return
+		return nil, err
+	}
+	since := ord.CreatedAt
+	if !since.Valid {
+		since = pgtype.Timestamp{Valid: true}
 	}
-	price, err := strconv.ParseFloat(ord.Price, 64)
+	dbres, err := queries.GetHistoryById(ctx, repository.GetHistoryByIdParams{
+		OrderID: pgtype.Int8{Int64: int64(id), Valid: true},
+		Since:   since,
+	})
 	if err != nil {
-		return
+		return nil, err
+	}
+	events := make([]OrderHistoryEvent, len(dbres))
+	for idx, ev := range dbres {
+		events[idx] = OrderHistoryEvent{
+			ID:        ev.ID,
+			Name:      ev.Event,
+			OrderId:   int(ev.OrderID.Int64),
+			CreatedAt: ev.CreatedAt.Time,
+		}
+		if len(ev.Metadata) > 0 {
+			if err := json.Unmarshal(ev.Metadata, &events[idx].Metadata); err != nil {
+				return nil, err
+			}
+		}
+		if events[idx], err = Upcast(events[idx]); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// NewOrderRepository returns a repository bounding every query by queryTimeout,
+// zero only relies on the context of the caller. Writes go to dbpool and
+// reads to the pool picked by reads.
+func NewOrderRepository(dbpool *pgxpool.Pool, reads *replica.Router, queryTimeout time.Duration) OrderRepo {
+	return &orderRepo{
+		queries:      repository.New(dbpool),
+		dbpool:       dbpool,
+		reads:        reads,
+		queryTimeout: queryTimeout,
 	}
+}
 
+func convertOrder(ord repository.TblOrder) (res Order) {
 	res.AccountID = int(ord.AccountID.Int32)
-	res.Amount = amount
-	res.Price = price
+	res.Amount = ord.Amount
+	res.Price = ord.Price
 	res.ID = int(ord.ID)
 	res.Type = OrderType(ord.OrderType)
 	res.PairID = ord.PairID
 	res.CreatedAt = ord.CreatedAt.Time
+	res.Tag = ord.Tag
+	res.StrategyID = ord.StrategyID
 	return
 }
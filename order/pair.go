@@ -0,0 +1,317 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	repository "order-book/order/repository/gen"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrUnknownPair     = errors.New("Pair is not listed")
+	ErrInvalidTickSize = errors.New("Price is not a multiple of the pair tick size")
+	ErrAmountTooSmall  = errors.New("Amount is below the pair minimum")
+	ErrPriceScale      = errors.New("Price has more decimal places than the pair allows")
+	ErrAmountScale     = errors.New("Amount has more decimal places than the pair allows")
+	ErrPairHalted      = errors.New("Trading is halted on the pair")
+	ErrInvalidLotSize  = errors.New("Amount is not a multiple of the pair lot size")
+	ErrNotionalTooLow  = errors.New("Price times amount is below the pair minimum notional")
+	ErrPairExists      = errors.New("Pair is already listed")
+)
+
+// DefaultScale is the number of decimal places of pairs that don't set one
+const DefaultScale = 8
+
+// MaxScale bounds the decimal places of a pair, well past what any asset uses
+const MaxScale = 18
+
+// Statuses of a listed pair
+const (
+	PAIR_TRADING = "trading"
+	PAIR_HALTED  = "halted"
+)
+
+// Pair is a listed trading pair. A zero TickSize, LotSize, MinAmount or
+// MinNotional is not enforced. Prices and amounts are stored exactly, with
+// at most PriceScale and AmountScale decimal places. MakerFee and TakerFee
+// override the fee schedule for the pair when set.
+type Pair struct {
+	ID          string          `json:"id"`
+	Base        string          `json:"base"`
+	Quote       string          `json:"quote"`
+	TickSize    decimal.Decimal `json:"tick_size"`
+	LotSize     decimal.Decimal `json:"lot_size"`
+	MinAmount   decimal.Decimal `json:"min_amount"`
+	MinNotional decimal.Decimal `json:"min_notional"`
+	PriceScale  int32           `json:"price_scale"`
+	AmountScale int32           `json:"amount_scale"`
+	Status      string          `json:"status"`
+	MakerFee    *float64        `json:"maker_fee,omitempty"`
+	TakerFee    *float64        `json:"taker_fee,omitempty"`
+}
+
+// PairBooks opens the books of the pairs being listed and closes those of
+// the pairs being delisted, book.Registry in the engine
+type PairBooks interface {
+	OpenPair(p Pair)
+	ClosePair(pairId string) error
+}
+
+// ALL_PAIRS halts every pair, listed or not
+const ALL_PAIRS = "*"
+
+var (
+	pairsMu sync.RWMutex
+	pairs   = map[string]Pair{}
+	// halted pairs refuse new orders, their resting orders can still be
+	// cancelled
+	halted = map[string]bool{}
+)
+
+// RegisterPair lists a pair, replacing any previous definition with the same
+// ID. A halted status halts the pair, a trading one lifts the halt of a
+// pair that was halted by its status.
+func RegisterPair(p Pair) {
+	pairsMu.Lock()
+	defer pairsMu.Unlock()
+	if p.Status == PAIR_HALTED {
+		halted[p.ID] = true
+	} else if prev, ok := pairs[p.ID]; ok && prev.Status == PAIR_HALTED {
+		delete(halted, p.ID)
+	}
+	pairs[p.ID] = p
+}
+
+// DelistPair delists a pair, false if it wasn't listed. Resting orders of a
+// delisted pair stay on the book, new ones are refused.
+func DelistPair(pairId string) bool {
+	pairsMu.Lock()
+	defer pairsMu.Unlock()
+	_, ok := pairs[pairId]
+	delete(pairs, pairId)
+	return ok
+}
+
+// GetPairs returns the listed pairs by ID
+func GetPairs() []Pair {
+	pairsMu.RLock()
+	defer pairsMu.RUnlock()
+	list := slices.Collect(maps.Values(pairs))
+	slices.SortFunc(list, func(a, b Pair) int { return strings.Compare(a.ID, b.ID) })
+	return list
+}
+
+// HaltPair stops the trading of a pair, or of every pair with ALL_PAIRS.
+// It returns whether the pair was already halted.
+func HaltPair(pairId string) bool {
+	pairsMu.Lock()
+	defer pairsMu.Unlock()
+	was := halted[pairId]
+	halted[pairId] = true
+	return was
+}
+
+// ResumePair lifts the halt of a pair, or the one of ALL_PAIRS. A pair
+// halted on its own stays halted when ALL_PAIRS is lifted. It returns
+// whether the pair was halted.
+func ResumePair(pairId string) bool {
+	pairsMu.Lock()
+	defer pairsMu.Unlock()
+	was := halted[pairId]
+	delete(halted, pairId)
+	return was
+}
+
+// Halts returns the halted pairs, ALL_PAIRS among them when every pair is
+func Halts() []string {
+	pairsMu.RLock()
+	defer pairsMu.RUnlock()
+	return slices.Sorted(maps.Keys(halted))
+}
+
+// IsHalted reports whether orders of the pair are refused
+func IsHalted(pairId string) bool {
+	pairsMu.RLock()
+	defer pairsMu.RUnlock()
+	return halted[ALL_PAIRS] || halted[pairId]
+}
+
+func GetPair(pairId string) (Pair, bool) {
+	pairsMu.RLock()
+	defer pairsMu.RUnlock()
+	p, ok := pairs[pairId]
+	return p, ok
+}
+
+// ValidatePair checks an order against its pair definition. Orders are not
+// restricted until at least one pair is registered, but for halts.
+func ValidatePair(o Order) error {
+	pairsMu.RLock()
+	defer pairsMu.RUnlock()
+	if halted[ALL_PAIRS] || halted[o.PairID] {
+		return ErrPairHalted
+	}
+	if len(pairs) == 0 {
+		return nil
+	}
+	p, ok := pairs[o.PairID]
+	if !ok {
+		return ErrUnknownPair
+	}
+	if err := tradable(p); err != nil {
+		return err
+	}
+	if !o.Price.Equal(o.Price.Truncate(p.PriceScale)) {
+		return ErrPriceScale
+	}
+	if !o.Amount.Equal(o.Amount.Truncate(p.AmountScale)) {
+		return ErrAmountScale
+	}
+	if p.TickSize.IsPositive() && !o.Price.Mod(p.TickSize).IsZero() {
+		return ErrInvalidTickSize
+	}
+	if p.LotSize.IsPositive() && !o.Amount.Mod(p.LotSize).IsZero() {
+		return ErrInvalidLotSize
+	}
+	if o.Amount.LessThan(p.MinAmount) {
+		return ErrAmountTooSmall
+	}
+	if o.Price.Mul(o.Amount).LessThan(p.MinNotional) {
+		return ErrNotionalTooLow
+	}
+	return nil
+}
+
+func registeredSplit(pairId string) (base string, quote string, ok bool) {
+	pairsMu.RLock()
+	defer pairsMu.RUnlock()
+	p, ok := pairs[strings.ToLower(pairId)]
+	return p.Base, p.Quote, ok
+}
+
+// Validate checks a pair before it's listed
+func (p Pair) Validate() error {
+	switch {
+	case p.ID == "" || p.Base == "" || p.Quote == "":
+		return errors.New("id, base and quote are required")
+	case p.Base+p.Quote != p.ID:
+		return errors.New("id should be base followed by quote")
+	case p.TickSize.IsNegative() || p.LotSize.IsNegative() || p.MinAmount.IsNegative() || p.MinNotional.IsNegative():
+		return errors.New("tick_size, lot_size, min_amount and min_notional can't be negative")
+	case p.PriceScale < 0 || p.PriceScale > MaxScale || p.AmountScale < 0 || p.AmountScale > MaxScale:
+		return fmt.Errorf("price_scale and amount_scale should be between 0 and %d", MaxScale)
+	case !p.TickSize.Equal(p.TickSize.Truncate(p.PriceScale)):
+		return errors.New("tick_size has more decimal places than price_scale")
+	case !p.LotSize.Equal(p.LotSize.Truncate(p.AmountScale)):
+		return errors.New("lot_size has more decimal places than amount_scale")
+	case p.Status != PAIR_TRADING && p.Status != PAIR_HALTED:
+		return errors.New("status should be trading or halted")
+	}
+	// A negative maker fee is a rebate
+	if p.MakerFee != nil && (*p.MakerFee <= -1 || *p.MakerFee >= 1) {
+		return errors.New("maker_fee should be between -1 and 1")
+	}
+	if p.TakerFee != nil && (*p.TakerFee < 0 || *p.TakerFee >= 1) {
+		return errors.New("taker_fee should be between 0 and 1")
+	}
+	return checkAssets(p)
+}
+
+// PairRepo stores the pairs listed through the admin API, they're listed
+// again at startup over those of the config file
+type PairRepo interface {
+	GetPairs(ctx context.Context) ([]Pair, error)
+	// SavePair lists a pair or replaces its definition
+	SavePair(ctx context.Context, p Pair) error
+	DeletePair(ctx context.Context, pairId string) error
+}
+
+type pairRepo struct {
+	queries      *repository.Queries
+	queryTimeout time.Duration
+}
+
+func (repo *pairRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *pairRepo) GetPairs(ctx context.Context) ([]Pair, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	dbres, err := repo.queries.GetPairs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Pair, len(dbres))
+	for idx, p := range dbres {
+		list[idx] = Pair{
+			ID:          p.ID,
+			Base:        p.Base,
+			Quote:       p.Quote,
+			TickSize:    p.TickSize,
+			LotSize:     p.LotSize,
+			MinAmount:   p.MinAmount,
+			MinNotional: p.MinNotional,
+			PriceScale:  p.PriceScale,
+			AmountScale: p.AmountScale,
+			Status:      p.Status,
+		}
+		if p.MakerFee.Valid {
+			list[idx].MakerFee = &p.MakerFee.Float64
+		}
+		if p.TakerFee.Valid {
+			list[idx].TakerFee = &p.TakerFee.Float64
+		}
+	}
+	return list, nil
+}
+
+func (repo *pairRepo) SavePair(ctx context.Context, p Pair) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	params := repository.UpsertPairParams{
+		ID:          p.ID,
+		Base:        p.Base,
+		Quote:       p.Quote,
+		TickSize:    p.TickSize,
+		LotSize:     p.LotSize,
+		MinAmount:   p.MinAmount,
+		MinNotional: p.MinNotional,
+		PriceScale:  p.PriceScale,
+		AmountScale: p.AmountScale,
+		Status:      p.Status,
+	}
+	if p.MakerFee != nil {
+		params.MakerFee = pgtype.Float8{Float64: *p.MakerFee, Valid: true}
+	}
+	if p.TakerFee != nil {
+		params.TakerFee = pgtype.Float8{Float64: *p.TakerFee, Valid: true}
+	}
+	_, err := repo.queries.UpsertPair(ctx, params)
+	return err
+}
+
+func (repo *pairRepo) DeletePair(ctx context.Context, pairId string) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.DeletePair(ctx, pairId)
+}
+
+func NewPairRepository(dbpool *pgxpool.Pool, queryTimeout time.Duration) PairRepo {
+	return &pairRepo{
+		queries:      repository.New(dbpool),
+		queryTimeout: queryTimeout,
+	}
+}
@@ -0,0 +1,65 @@
+package order
+
+import (
+	"errors"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrQuoteCrossed = errors.New("The bid of a quote should be below its ask")
+	ErrQuoteInvalid = errors.New("Quote prices and amounts should be positive, a zero amount leaves the side out")
+)
+
+// Quote is the two-sided quote of a market maker on a pair. It replaces the
+// previous quote of the account on the pair, a side with a zero amount is
+// left out and a quote without any side only pulls the previous one.
+type Quote struct {
+	AccountID  int             `json:"account_id"`
+	PairID     string          `json:"pair_id"`
+	BidPrice   decimal.Decimal `json:"bid_price"`
+	BidAmount  decimal.Decimal `json:"bid_amount"`
+	AskPrice   decimal.Decimal `json:"ask_price"`
+	AskAmount  decimal.Decimal `json:"ask_amount"`
+	PostOnly   bool            `json:"post_only,omitempty"`
+	Tag        string          `json:"tag,omitempty"`
+	StrategyID string          `json:"strategy_id,omitempty"`
+}
+
+func (q Quote) Validate() error {
+	for _, side := range [][2]decimal.Decimal{{q.BidPrice, q.BidAmount}, {q.AskPrice, q.AskAmount}} {
+		price, amount := side[0], side[1]
+		if amount.IsNegative() || (amount.IsPositive() && !price.IsPositive()) {
+			return ErrQuoteInvalid
+		}
+	}
+	if q.BidAmount.IsPositive() && q.AskAmount.IsPositive() && !q.BidPrice.LessThan(q.AskPrice) {
+		return ErrQuoteCrossed
+	}
+	return nil
+}
+
+// Orders are the orders of the sides of the quote, the bid first
+func (q Quote) Orders() []Order {
+	var orders []Order
+	for _, side := range []struct {
+		typ    OrderType
+		price  decimal.Decimal
+		amount decimal.Decimal
+	}{{BID, q.BidPrice, q.BidAmount}, {ASK, q.AskPrice, q.AskAmount}} {
+		if !side.amount.IsPositive() {
+			continue
+		}
+		orders = append(orders, Order{
+			Price:      side.price,
+			Amount:     side.amount,
+			PairID:     q.PairID,
+			AccountID:  q.AccountID,
+			Type:       side.typ,
+			PostOnly:   q.PostOnly,
+			Tag:        q.Tag,
+			StrategyID: q.StrategyID,
+		})
+	}
+	return orders
+}
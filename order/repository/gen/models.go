@@ -5,26 +5,102 @@
 package repository
 
 import (
-	"database/sql"
-
-	"github.com/sqlc-dev/pqtype"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/shopspring/decimal"
 )
 
-type TblOrder struct {
+type TblArchivedPartition struct {
+	Name       string
+	Parent     string
+	RangeStart pgtype.Timestamp
+	RangeEnd   pgtype.Timestamp
+	ArchivedAt pgtype.Timestamp
+	ObjectKey  pgtype.Text
+	ExportedAt pgtype.Timestamp
+}
+
+type TblAsset struct {
+	Symbol             string
+	Name               string
+	Precision          int32
+	TradingEnabled     bool
+	WithdrawalsEnabled bool
+	CreatedAt          pgtype.Timestamp
+	UpdatedAt          pgtype.Timestamp
+}
+
+type TblBookSnapshot struct {
 	ID        int64
+	TenantID  string
 	PairID    string
-	Price     string
-	Amount    string
-	CreatedAt sql.NullTime
-	OrderType int32
-	AccountID sql.NullInt32
+	Seq       int64
+	Asks      []byte
+	Bids      []byte
+	CreatedAt pgtype.Timestamp
+}
+
+type TblOrder struct {
+	ID         int64
+	PairID     string
+	Price      decimal.Decimal
+	Amount     decimal.Decimal
+	CreatedAt  pgtype.Timestamp
+	OrderType  int32
+	AccountID  pgtype.Int4
+	Tag        string
+	StrategyID string
 }
 
 type TblOrderHistoryEvent struct {
-	ID        int32
+	ID        int64
 	Event     string
-	Status    string
-	CreatedAt sql.NullTime
-	Metadata  pqtype.NullRawMessage
-	OrderID   sql.NullInt64
+	CreatedAt pgtype.Timestamp
+	Metadata  []byte
+	OrderID   pgtype.Int8
+}
+
+type TblPair struct {
+	ID          string
+	Base        string
+	Quote       string
+	TickSize    decimal.Decimal
+	LotSize     decimal.Decimal
+	MinAmount   decimal.Decimal
+	MinNotional decimal.Decimal
+	PriceScale  int32
+	AmountScale int32
+	Status      string
+	MakerFee    pgtype.Float8
+	TakerFee    pgtype.Float8
+	CreatedAt   pgtype.Timestamp
+	UpdatedAt   pgtype.Timestamp
+}
+
+type TblTrade struct {
+	ID              int64
+	PairID          string
+	Price           decimal.Decimal
+	Amount          decimal.Decimal
+	MakerOrderID    int64
+	TakerOrderID    int64
+	MakerAccountID  int32
+	TakerAccountID  int32
+	TakerSide       int32
+	MakerFee        decimal.Decimal
+	TakerFee        decimal.Decimal
+	ExecutedAt      pgtype.Timestamp
+	MakerTag        string
+	MakerStrategyID string
+	TakerTag        string
+	TakerStrategyID string
+	MakerRebate     decimal.Decimal
+}
+
+type TblTradeTick struct {
+	TradeID    int64
+	TenantID   string
+	PairID     string
+	Price      decimal.Decimal
+	Amount     decimal.Decimal
+	ExecutedAt pgtype.Timestamp
 }
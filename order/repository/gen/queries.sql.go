@@ -7,31 +7,59 @@ package repository
 
 import (
 	"context"
-	"database/sql"
 
-	"github.com/sqlc-dev/pqtype"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/shopspring/decimal"
 )
 
+const archiveOrderHistoryPartitions = `-- name: ArchiveOrderHistoryPartitions :many
+SELECT fn_archive_order_history_partitions($1::TIMESTAMP)::VARCHAR AS name
+`
+
+func (q *Queries) ArchiveOrderHistoryPartitions(ctx context.Context, before pgtype.Timestamp) ([]string, error) {
+	rows, err := q.db.Query(ctx, archiveOrderHistoryPartitions, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		items = append(items, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createOrder = `-- name: CreateOrder :one
-INSERT INTO tbl_orders (pair_id, price, amount, account_id, order_type)
-VALUES ($1, $2, $3, $4, $5) RETURNING id, pair_id, price, amount, created_at, order_type, account_id
+INSERT INTO tbl_orders (pair_id, price, amount, account_id, order_type, tag, strategy_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, pair_id, price, amount, created_at, order_type, account_id, tag, strategy_id
 `
 
 type CreateOrderParams struct {
-	PairID    string
-	Price     string
-	Amount    string
-	AccountID sql.NullInt32
-	OrderType int32
+	PairID     string
+	Price      decimal.Decimal
+	Amount     decimal.Decimal
+	AccountID  pgtype.Int4
+	OrderType  int32
+	Tag        string
+	StrategyID string
 }
 
 func (q *Queries) CreateOrder(ctx context.Context, arg CreateOrderParams) (TblOrder, error) {
-	row := q.db.QueryRowContext(ctx, createOrder,
+	row := q.db.QueryRow(ctx, createOrder,
 		arg.PairID,
 		arg.Price,
 		arg.Amount,
 		arg.AccountID,
 		arg.OrderType,
+		arg.Tag,
+		arg.StrategyID,
 	)
 	var i TblOrder
 	err := row.Scan(
@@ -42,16 +70,380 @@ func (q *Queries) CreateOrder(ctx context.Context, arg CreateOrderParams) (TblOr
 		&i.CreatedAt,
 		&i.OrderType,
 		&i.AccountID,
+		&i.Tag,
+		&i.StrategyID,
 	)
 	return i, err
 }
 
+const createOrderHistoryPartition = `-- name: CreateOrderHistoryPartition :one
+SELECT fn_create_order_history_partition($1::TIMESTAMP)::VARCHAR AS name
+`
+
+func (q *Queries) CreateOrderHistoryPartition(ctx context.Context, month pgtype.Timestamp) (string, error) {
+	row := q.db.QueryRow(ctx, createOrderHistoryPartition, month)
+	var name string
+	err := row.Scan(&name)
+	return name, err
+}
+
+const deleteAsset = `-- name: DeleteAsset :exec
+DELETE FROM tbl_assets WHERE symbol = $1
+`
+
+func (q *Queries) DeleteAsset(ctx context.Context, symbol string) error {
+	_, err := q.db.Exec(ctx, deleteAsset, symbol)
+	return err
+}
+
+const deleteBookSnapshotsBefore = `-- name: DeleteBookSnapshotsBefore :exec
+DELETE FROM tbl_book_snapshots WHERE tenant_id = $1 AND pair_id = $2 AND id < $3
+`
+
+type DeleteBookSnapshotsBeforeParams struct {
+	TenantID string
+	PairID   string
+	ID       int64
+}
+
+func (q *Queries) DeleteBookSnapshotsBefore(ctx context.Context, arg DeleteBookSnapshotsBeforeParams) error {
+	_, err := q.db.Exec(ctx, deleteBookSnapshotsBefore, arg.TenantID, arg.PairID, arg.ID)
+	return err
+}
+
+const deletePair = `-- name: DeletePair :exec
+DELETE FROM tbl_pairs WHERE id = $1
+`
+
+func (q *Queries) DeletePair(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, deletePair, id)
+	return err
+}
+
+const deleteTradesBetween = `-- name: DeleteTradesBetween :exec
+DELETE FROM tbl_trades WHERE executed_at >= $1 AND executed_at < $2
+`
+
+type DeleteTradesBetweenParams struct {
+	FromTime pgtype.Timestamp
+	ToTime   pgtype.Timestamp
+}
+
+func (q *Queries) DeleteTradesBetween(ctx context.Context, arg DeleteTradesBetweenParams) error {
+	_, err := q.db.Exec(ctx, deleteTradesBetween, arg.FromTime, arg.ToTime)
+	return err
+}
+
+const dropArchivedPartition = `-- name: DropArchivedPartition :exec
+SELECT fn_drop_archived_partition($1::VARCHAR, $2::VARCHAR)
+`
+
+type DropArchivedPartitionParams struct {
+	Name      string
+	ObjectKey string
+}
+
+func (q *Queries) DropArchivedPartition(ctx context.Context, arg DropArchivedPartitionParams) error {
+	_, err := q.db.Exec(ctx, dropArchivedPartition, arg.Name, arg.ObjectKey)
+	return err
+}
+
+const getArchivedPartitions = `-- name: GetArchivedPartitions :many
+SELECT name, parent, range_start, range_end, archived_at, object_key, exported_at FROM tbl_archived_partitions WHERE parent = $1 ORDER BY range_start
+`
+
+func (q *Queries) GetArchivedPartitions(ctx context.Context, parent string) ([]TblArchivedPartition, error) {
+	rows, err := q.db.Query(ctx, getArchivedPartitions, parent)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TblArchivedPartition
+	for rows.Next() {
+		var i TblArchivedPartition
+		if err := rows.Scan(
+			&i.Name,
+			&i.Parent,
+			&i.RangeStart,
+			&i.RangeEnd,
+			&i.ArchivedAt,
+			&i.ObjectKey,
+			&i.ExportedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAssets = `-- name: GetAssets :many
+SELECT symbol, name, precision, trading_enabled, withdrawals_enabled, created_at, updated_at FROM tbl_assets ORDER BY symbol
+`
+
+func (q *Queries) GetAssets(ctx context.Context) ([]TblAsset, error) {
+	rows, err := q.db.Query(ctx, getAssets)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TblAsset
+	for rows.Next() {
+		var i TblAsset
+		if err := rows.Scan(
+			&i.Symbol,
+			&i.Name,
+			&i.Precision,
+			&i.TradingEnabled,
+			&i.WithdrawalsEnabled,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCancellationsBetween = `-- name: GetCancellationsBetween :many
+SELECT order_id, created_at FROM tbl_order_history_events
+WHERE event = 'ORDER_CANCELLED' AND created_at >= $1 AND created_at < $2
+ORDER BY id
+`
+
+type GetCancellationsBetweenParams struct {
+	FromTime pgtype.Timestamp
+	ToTime   pgtype.Timestamp
+}
+
+type GetCancellationsBetweenRow struct {
+	OrderID   pgtype.Int8
+	CreatedAt pgtype.Timestamp
+}
+
+func (q *Queries) GetCancellationsBetween(ctx context.Context, arg GetCancellationsBetweenParams) ([]GetCancellationsBetweenRow, error) {
+	rows, err := q.db.Query(ctx, getCancellationsBetween, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCancellationsBetweenRow
+	for rows.Next() {
+		var i GetCancellationsBetweenRow
+		if err := rows.Scan(
+			&i.OrderID,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCandles1d = `-- name: GetCandles1d :many
+SELECT bucket, open, high, low, close, volume, quote_volume, trades FROM cagg_candles_1d
+WHERE tenant_id = $1 AND pair_id = $2 AND bucket >= $3 AND bucket < $4
+ORDER BY bucket LIMIT $5
+`
+
+type GetCandles1dParams struct {
+	TenantID string
+	PairID   string
+	FromTime pgtype.Timestamp
+	ToTime   pgtype.Timestamp
+	MaxCount int32
+}
+
+type GetCandles1dRow struct {
+	Bucket      pgtype.Timestamp
+	Open        decimal.Decimal
+	High        decimal.Decimal
+	Low         decimal.Decimal
+	Close       decimal.Decimal
+	Volume      decimal.Decimal
+	QuoteVolume decimal.Decimal
+	Trades      int64
+}
+
+func (q *Queries) GetCandles1d(ctx context.Context, arg GetCandles1dParams) ([]GetCandles1dRow, error) {
+	rows, err := q.db.Query(ctx, getCandles1d,
+		arg.TenantID,
+		arg.PairID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.MaxCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCandles1dRow
+	for rows.Next() {
+		var i GetCandles1dRow
+		if err := rows.Scan(
+			&i.Bucket,
+			&i.Open,
+			&i.High,
+			&i.Low,
+			&i.Close,
+			&i.Volume,
+			&i.QuoteVolume,
+			&i.Trades,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCandles1h = `-- name: GetCandles1h :many
+SELECT bucket, open, high, low, close, volume, quote_volume, trades FROM cagg_candles_1h
+WHERE tenant_id = $1 AND pair_id = $2 AND bucket >= $3 AND bucket < $4
+ORDER BY bucket LIMIT $5
+`
+
+type GetCandles1hParams struct {
+	TenantID string
+	PairID   string
+	FromTime pgtype.Timestamp
+	ToTime   pgtype.Timestamp
+	MaxCount int32
+}
+
+type GetCandles1hRow struct {
+	Bucket      pgtype.Timestamp
+	Open        decimal.Decimal
+	High        decimal.Decimal
+	Low         decimal.Decimal
+	Close       decimal.Decimal
+	Volume      decimal.Decimal
+	QuoteVolume decimal.Decimal
+	Trades      int64
+}
+
+func (q *Queries) GetCandles1h(ctx context.Context, arg GetCandles1hParams) ([]GetCandles1hRow, error) {
+	rows, err := q.db.Query(ctx, getCandles1h,
+		arg.TenantID,
+		arg.PairID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.MaxCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCandles1hRow
+	for rows.Next() {
+		var i GetCandles1hRow
+		if err := rows.Scan(
+			&i.Bucket,
+			&i.Open,
+			&i.High,
+			&i.Low,
+			&i.Close,
+			&i.Volume,
+			&i.QuoteVolume,
+			&i.Trades,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCandles1m = `-- name: GetCandles1m :many
+SELECT bucket, open, high, low, close, volume, quote_volume, trades FROM cagg_candles_1m
+WHERE tenant_id = $1 AND pair_id = $2 AND bucket >= $3 AND bucket < $4
+ORDER BY bucket LIMIT $5
+`
+
+type GetCandles1mParams struct {
+	TenantID string
+	PairID   string
+	FromTime pgtype.Timestamp
+	ToTime   pgtype.Timestamp
+	MaxCount int32
+}
+
+type GetCandles1mRow struct {
+	Bucket      pgtype.Timestamp
+	Open        decimal.Decimal
+	High        decimal.Decimal
+	Low         decimal.Decimal
+	Close       decimal.Decimal
+	Volume      decimal.Decimal
+	QuoteVolume decimal.Decimal
+	Trades      int64
+}
+
+func (q *Queries) GetCandles1m(ctx context.Context, arg GetCandles1mParams) ([]GetCandles1mRow, error) {
+	rows, err := q.db.Query(ctx, getCandles1m,
+		arg.TenantID,
+		arg.PairID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.MaxCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCandles1mRow
+	for rows.Next() {
+		var i GetCandles1mRow
+		if err := rows.Scan(
+			&i.Bucket,
+			&i.Open,
+			&i.High,
+			&i.Low,
+			&i.Close,
+			&i.Volume,
+			&i.QuoteVolume,
+			&i.Trades,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getHistoryById = `-- name: GetHistoryById :many
-SELECT id, event, status, created_at, metadata, order_id FROM tbl_order_history_events WHERE order_id = $1 ORDER BY created_at DESC
+SELECT id, event, created_at, metadata, order_id FROM tbl_order_history_events
+WHERE order_id = $1 AND created_at >= $2
+ORDER BY created_at DESC
 `
 
-func (q *Queries) GetHistoryById(ctx context.Context, orderID sql.NullInt64) ([]TblOrderHistoryEvent, error) {
-	rows, err := q.db.QueryContext(ctx, getHistoryById, orderID)
+type GetHistoryByIdParams struct {
+	OrderID pgtype.Int8
+	Since   pgtype.Timestamp
+}
+
+func (q *Queries) GetHistoryById(ctx context.Context, arg GetHistoryByIdParams) ([]TblOrderHistoryEvent, error) {
+	rows, err := q.db.Query(ctx, getHistoryById, arg.OrderID, arg.Since)
 	if err != nil {
 		return nil, err
 	}
@@ -62,7 +454,6 @@ func (q *Queries) GetHistoryById(ctx context.Context, orderID sql.NullInt64) ([]
 		if err := rows.Scan(
 			&i.ID,
 			&i.Event,
-			&i.Status,
 			&i.CreatedAt,
 			&i.Metadata,
 			&i.OrderID,
@@ -71,21 +462,53 @@ func (q *Queries) GetHistoryById(ctx context.Context, orderID sql.NullInt64) ([]
 		}
 		items = append(items, i)
 	}
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 	return items, nil
 }
 
+const getLatestBookSnapshot = `-- name: GetLatestBookSnapshot :one
+SELECT id, tenant_id, pair_id, seq, asks, bids, created_at FROM tbl_book_snapshots WHERE tenant_id = $1 AND pair_id = $2 ORDER BY id DESC LIMIT 1
+`
+
+type GetLatestBookSnapshotParams struct {
+	TenantID string
+	PairID   string
+}
+
+func (q *Queries) GetLatestBookSnapshot(ctx context.Context, arg GetLatestBookSnapshotParams) (TblBookSnapshot, error) {
+	row := q.db.QueryRow(ctx, getLatestBookSnapshot, arg.TenantID, arg.PairID)
+	var i TblBookSnapshot
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.PairID,
+		&i.Seq,
+		&i.Asks,
+		&i.Bids,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getOldestTradeTime = `-- name: GetOldestTradeTime :one
+SELECT MIN(executed_at)::TIMESTAMP AS executed_at FROM tbl_trades
+`
+
+func (q *Queries) GetOldestTradeTime(ctx context.Context) (pgtype.Timestamp, error) {
+	row := q.db.QueryRow(ctx, getOldestTradeTime)
+	var executedAt pgtype.Timestamp
+	err := row.Scan(&executedAt)
+	return executedAt, err
+}
+
 const getOneById = `-- name: GetOneById :one
-SELECT id, pair_id, price, amount, created_at, order_type, account_id FROM tbl_orders WHERE id = $1
+SELECT id, pair_id, price, amount, created_at, order_type, account_id, tag, strategy_id FROM tbl_orders WHERE id = $1
 `
 
 func (q *Queries) GetOneById(ctx context.Context, id int64) (TblOrder, error) {
-	row := q.db.QueryRowContext(ctx, getOneById, id)
+	row := q.db.QueryRow(ctx, getOneById, id)
 	var i TblOrder
 	err := row.Scan(
 		&i.ID,
@@ -95,22 +518,129 @@ func (q *Queries) GetOneById(ctx context.Context, id int64) (TblOrder, error) {
 		&i.CreatedAt,
 		&i.OrderType,
 		&i.AccountID,
+		&i.Tag,
+		&i.StrategyID,
 	)
 	return i, err
 }
 
+const getOrderEventsPage = `-- name: GetOrderEventsPage :many
+SELECT e.id, e.event, e.order_id, o.account_id, o.pair_id, e.metadata, e.created_at
+FROM tbl_order_history_events e
+LEFT JOIN tbl_orders o ON o.id = e.order_id
+WHERE ($1::INTEGER = 0 OR o.account_id = $1)
+  AND ($2::VARCHAR = '' OR o.pair_id = $2)
+  AND e.created_at >= $3 AND e.created_at < $4
+  AND e.id > $5
+ORDER BY e.id
+LIMIT $6
+`
+
+type GetOrderEventsPageParams struct {
+	AccountID int32
+	PairID    string
+	FromTime  pgtype.Timestamp
+	ToTime    pgtype.Timestamp
+	AfterID   int64
+	MaxCount  int32
+}
+
+type GetOrderEventsPageRow struct {
+	ID        int64
+	Event     string
+	OrderID   pgtype.Int8
+	AccountID pgtype.Int4
+	PairID    pgtype.Text
+	Metadata  []byte
+	CreatedAt pgtype.Timestamp
+}
+
+func (q *Queries) GetOrderEventsPage(ctx context.Context, arg GetOrderEventsPageParams) ([]GetOrderEventsPageRow, error) {
+	rows, err := q.db.Query(ctx, getOrderEventsPage,
+		arg.AccountID,
+		arg.PairID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.AfterID,
+		arg.MaxCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetOrderEventsPageRow
+	for rows.Next() {
+		var i GetOrderEventsPageRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Event,
+			&i.OrderID,
+			&i.AccountID,
+			&i.PairID,
+			&i.Metadata,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getOrders = `-- name: GetOrders :many
-SELECT id, pair_id, price, amount, created_at, order_type, account_id FROM tbl_orders WHERE account_id = $3 LIMIT $1 OFFSET $2
+SELECT id, pair_id, price, amount, created_at, order_type, account_id, tag, strategy_id FROM tbl_orders WHERE account_id = $3 LIMIT $1 OFFSET $2
 `
 
 type GetOrdersParams struct {
 	Limit     int32
 	Offset    int32
-	AccountID sql.NullInt32
+	AccountID pgtype.Int4
 }
 
 func (q *Queries) GetOrders(ctx context.Context, arg GetOrdersParams) ([]TblOrder, error) {
-	rows, err := q.db.QueryContext(ctx, getOrders, arg.Limit, arg.Offset, arg.AccountID)
+	rows, err := q.db.Query(ctx, getOrders, arg.Limit, arg.Offset, arg.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TblOrder
+	for rows.Next() {
+		var i TblOrder
+		if err := rows.Scan(
+			&i.ID,
+			&i.PairID,
+			&i.Price,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.OrderType,
+			&i.AccountID,
+			&i.Tag,
+			&i.StrategyID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getOrdersCreatedBetween = `-- name: GetOrdersCreatedBetween :many
+SELECT id, pair_id, price, amount, created_at, order_type, account_id, tag, strategy_id FROM tbl_orders WHERE created_at >= $1 AND created_at < $2 ORDER BY id
+`
+
+type GetOrdersCreatedBetweenParams struct {
+	FromTime pgtype.Timestamp
+	ToTime   pgtype.Timestamp
+}
+
+func (q *Queries) GetOrdersCreatedBetween(ctx context.Context, arg GetOrdersCreatedBetweenParams) ([]TblOrder, error) {
+	rows, err := q.db.Query(ctx, getOrdersCreatedBetween, arg.FromTime, arg.ToTime)
 	if err != nil {
 		return nil, err
 	}
@@ -126,20 +656,327 @@ func (q *Queries) GetOrders(ctx context.Context, arg GetOrdersParams) ([]TblOrde
 			&i.CreatedAt,
 			&i.OrderType,
 			&i.AccountID,
+			&i.Tag,
+			&i.StrategyID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPairs = `-- name: GetPairs :many
+SELECT id, base, quote, tick_size, lot_size, min_amount, min_notional, price_scale, amount_scale, status, maker_fee, taker_fee, created_at, updated_at FROM tbl_pairs ORDER BY id
+`
+
+func (q *Queries) GetPairs(ctx context.Context) ([]TblPair, error) {
+	rows, err := q.db.Query(ctx, getPairs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TblPair
+	for rows.Next() {
+		var i TblPair
+		if err := rows.Scan(
+			&i.ID,
+			&i.Base,
+			&i.Quote,
+			&i.TickSize,
+			&i.LotSize,
+			&i.MinAmount,
+			&i.MinNotional,
+			&i.PriceScale,
+			&i.AmountScale,
+			&i.Status,
+			&i.MakerFee,
+			&i.TakerFee,
+			&i.CreatedAt,
+			&i.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
 	}
-	if err := rows.Close(); err != nil {
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTradeByID = `-- name: GetTradeByID :one
+SELECT id, pair_id, price, amount, maker_order_id, taker_order_id, maker_account_id, taker_account_id, taker_side, maker_fee, taker_fee, executed_at, maker_tag, maker_strategy_id, taker_tag, taker_strategy_id, maker_rebate FROM tbl_trades WHERE id = $1
+`
+
+func (q *Queries) GetTradeByID(ctx context.Context, id int64) (TblTrade, error) {
+	row := q.db.QueryRow(ctx, getTradeByID, id)
+	var i TblTrade
+	err := row.Scan(
+		&i.ID,
+		&i.PairID,
+		&i.Price,
+		&i.Amount,
+		&i.MakerOrderID,
+		&i.TakerOrderID,
+		&i.MakerAccountID,
+		&i.TakerAccountID,
+		&i.TakerSide,
+		&i.MakerFee,
+		&i.TakerFee,
+		&i.ExecutedAt,
+		&i.MakerTag,
+		&i.MakerStrategyID,
+		&i.TakerTag,
+		&i.TakerStrategyID,
+		&i.MakerRebate,
+	)
+	return i, err
+}
+
+const getTrades = `-- name: GetTrades :many
+SELECT id, pair_id, price, amount, maker_order_id, taker_order_id, maker_account_id, taker_account_id, taker_side, maker_fee, taker_fee, executed_at, maker_tag, maker_strategy_id, taker_tag, taker_strategy_id, maker_rebate FROM tbl_trades
+WHERE ($1::INTEGER = 0 OR maker_account_id = $1 OR taker_account_id = $1)
+  AND ($2::VARCHAR = '' OR pair_id = $2)
+  AND executed_at >= $3 AND executed_at < $4
+ORDER BY executed_at DESC, id DESC
+LIMIT $5 OFFSET $6
+`
+
+type GetTradesParams struct {
+	AccountID int32
+	PairID    string
+	FromTime  pgtype.Timestamp
+	ToTime    pgtype.Timestamp
+	Limit     int32
+	Offset    int32
+}
+
+func (q *Queries) GetTrades(ctx context.Context, arg GetTradesParams) ([]TblTrade, error) {
+	rows, err := q.db.Query(ctx, getTrades,
+		arg.AccountID,
+		arg.PairID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+	var items []TblTrade
+	for rows.Next() {
+		var i TblTrade
+		if err := rows.Scan(
+			&i.ID,
+			&i.PairID,
+			&i.Price,
+			&i.Amount,
+			&i.MakerOrderID,
+			&i.TakerOrderID,
+			&i.MakerAccountID,
+			&i.TakerAccountID,
+			&i.TakerSide,
+			&i.MakerFee,
+			&i.TakerFee,
+			&i.ExecutedAt,
+			&i.MakerTag,
+			&i.MakerStrategyID,
+			&i.TakerTag,
+			&i.TakerStrategyID,
+			&i.MakerRebate,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 	return items, nil
 }
 
+const getTradesBetween = `-- name: GetTradesBetween :many
+SELECT id, pair_id, price, amount, maker_order_id, taker_order_id, maker_account_id, taker_account_id, taker_side, maker_fee, taker_fee, executed_at, maker_tag, maker_strategy_id, taker_tag, taker_strategy_id, maker_rebate FROM tbl_trades WHERE executed_at >= $1 AND executed_at < $2 ORDER BY id
+`
+
+type GetTradesBetweenParams struct {
+	FromTime pgtype.Timestamp
+	ToTime   pgtype.Timestamp
+}
+
+func (q *Queries) GetTradesBetween(ctx context.Context, arg GetTradesBetweenParams) ([]TblTrade, error) {
+	rows, err := q.db.Query(ctx, getTradesBetween, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TblTrade
+	for rows.Next() {
+		var i TblTrade
+		if err := rows.Scan(
+			&i.ID,
+			&i.PairID,
+			&i.Price,
+			&i.Amount,
+			&i.MakerOrderID,
+			&i.TakerOrderID,
+			&i.MakerAccountID,
+			&i.TakerAccountID,
+			&i.TakerSide,
+			&i.MakerFee,
+			&i.TakerFee,
+			&i.ExecutedAt,
+			&i.MakerTag,
+			&i.MakerStrategyID,
+			&i.TakerTag,
+			&i.TakerStrategyID,
+			&i.MakerRebate,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTradesPage = `-- name: GetTradesPage :many
+SELECT id, pair_id, price, amount, maker_order_id, taker_order_id, maker_account_id, taker_account_id, taker_side, maker_fee, taker_fee, executed_at, maker_tag, maker_strategy_id, taker_tag, taker_strategy_id, maker_rebate FROM tbl_trades
+WHERE ($1::INTEGER = 0 OR maker_account_id = $1 OR taker_account_id = $1)
+  AND ($2::VARCHAR = '' OR pair_id = $2)
+  AND executed_at >= $3 AND executed_at < $4
+  AND id > $5
+ORDER BY id
+LIMIT $6
+`
+
+type GetTradesPageParams struct {
+	AccountID int32
+	PairID    string
+	FromTime  pgtype.Timestamp
+	ToTime    pgtype.Timestamp
+	AfterID   int64
+	MaxCount  int32
+}
+
+func (q *Queries) GetTradesPage(ctx context.Context, arg GetTradesPageParams) ([]TblTrade, error) {
+	rows, err := q.db.Query(ctx, getTradesPage,
+		arg.AccountID,
+		arg.PairID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.AfterID,
+		arg.MaxCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TblTrade
+	for rows.Next() {
+		var i TblTrade
+		if err := rows.Scan(
+			&i.ID,
+			&i.PairID,
+			&i.Price,
+			&i.Amount,
+			&i.MakerOrderID,
+			&i.TakerOrderID,
+			&i.MakerAccountID,
+			&i.TakerAccountID,
+			&i.TakerSide,
+			&i.MakerFee,
+			&i.TakerFee,
+			&i.ExecutedAt,
+			&i.MakerTag,
+			&i.MakerStrategyID,
+			&i.TakerTag,
+			&i.TakerStrategyID,
+			&i.MakerRebate,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnexportedArchivedPartitions = `-- name: GetUnexportedArchivedPartitions :many
+SELECT name, parent, range_start, range_end, archived_at, object_key, exported_at FROM tbl_archived_partitions WHERE parent = $1 AND exported_at IS NULL ORDER BY range_start
+`
+
+func (q *Queries) GetUnexportedArchivedPartitions(ctx context.Context, parent string) ([]TblArchivedPartition, error) {
+	rows, err := q.db.Query(ctx, getUnexportedArchivedPartitions, parent)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TblArchivedPartition
+	for rows.Next() {
+		var i TblArchivedPartition
+		if err := rows.Scan(
+			&i.Name,
+			&i.Parent,
+			&i.RangeStart,
+			&i.RangeEnd,
+			&i.ArchivedAt,
+			&i.ObjectKey,
+			&i.ExportedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertBookSnapshot = `-- name: InsertBookSnapshot :one
+INSERT INTO tbl_book_snapshots (tenant_id, pair_id, seq, asks, bids)
+VALUES ($1, $2, $3, $4, $5) RETURNING id, tenant_id, pair_id, seq, asks, bids, created_at
+`
+
+type InsertBookSnapshotParams struct {
+	TenantID string
+	PairID   string
+	Seq      int64
+	Asks     []byte
+	Bids     []byte
+}
+
+func (q *Queries) InsertBookSnapshot(ctx context.Context, arg InsertBookSnapshotParams) (TblBookSnapshot, error) {
+	row := q.db.QueryRow(ctx, insertBookSnapshot,
+		arg.TenantID,
+		arg.PairID,
+		arg.Seq,
+		arg.Asks,
+		arg.Bids,
+	)
+	var i TblBookSnapshot
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.PairID,
+		&i.Seq,
+		&i.Asks,
+		&i.Bids,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
 const insertOneOrderHistoryEvent = `-- name: InsertOneOrderHistoryEvent :exec
 INSERT INTO tbl_order_history_events (event, order_id, metadata)
 VALUES ($1, $2, $3)
@@ -147,11 +984,174 @@ VALUES ($1, $2, $3)
 
 type InsertOneOrderHistoryEventParams struct {
 	Event    string
-	OrderID  sql.NullInt64
-	Metadata pqtype.NullRawMessage
+	OrderID  pgtype.Int8
+	Metadata []byte
 }
 
 func (q *Queries) InsertOneOrderHistoryEvent(ctx context.Context, arg InsertOneOrderHistoryEventParams) error {
-	_, err := q.db.ExecContext(ctx, insertOneOrderHistoryEvent, arg.Event, arg.OrderID, arg.Metadata)
+	_, err := q.db.Exec(ctx, insertOneOrderHistoryEvent, arg.Event, arg.OrderID, arg.Metadata)
 	return err
 }
+
+type InsertOrderHistoryEventsParams struct {
+	Event    string
+	OrderID  pgtype.Int8
+	Metadata []byte
+}
+
+type InsertTradesParams struct {
+	ID              int64
+	PairID          string
+	Price           decimal.Decimal
+	Amount          decimal.Decimal
+	MakerOrderID    int64
+	TakerOrderID    int64
+	MakerAccountID  int32
+	TakerAccountID  int32
+	TakerSide       int32
+	MakerFee        decimal.Decimal
+	TakerFee        decimal.Decimal
+	ExecutedAt      pgtype.Timestamp
+	MakerTag        string
+	MakerStrategyID string
+	TakerTag        string
+	TakerStrategyID string
+	MakerRebate     decimal.Decimal
+}
+
+const readArchivedHistoryPartition = `-- name: ReadArchivedHistoryPartition :many
+SELECT id, event, created_at, metadata, order_id FROM fn_read_archived_history_partition($1::VARCHAR)
+`
+
+type ReadArchivedHistoryPartitionRow struct {
+	ID        int64
+	Event     string
+	CreatedAt pgtype.Timestamp
+	Metadata  []byte
+	OrderID   pgtype.Int8
+}
+
+func (q *Queries) ReadArchivedHistoryPartition(ctx context.Context, name string) ([]ReadArchivedHistoryPartitionRow, error) {
+	rows, err := q.db.Query(ctx, readArchivedHistoryPartition, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReadArchivedHistoryPartitionRow
+	for rows.Next() {
+		var i ReadArchivedHistoryPartitionRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Event,
+			&i.CreatedAt,
+			&i.Metadata,
+			&i.OrderID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertAsset = `-- name: UpsertAsset :one
+INSERT INTO tbl_assets (symbol, name, precision, trading_enabled, withdrawals_enabled)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (symbol) DO UPDATE SET
+    name = EXCLUDED.name, precision = EXCLUDED.precision, trading_enabled = EXCLUDED.trading_enabled,
+    withdrawals_enabled = EXCLUDED.withdrawals_enabled, updated_at = NOW()
+RETURNING symbol, name, precision, trading_enabled, withdrawals_enabled, created_at, updated_at
+`
+
+type UpsertAssetParams struct {
+	Symbol             string
+	Name               string
+	Precision          int32
+	TradingEnabled     bool
+	WithdrawalsEnabled bool
+}
+
+func (q *Queries) UpsertAsset(ctx context.Context, arg UpsertAssetParams) (TblAsset, error) {
+	row := q.db.QueryRow(ctx, upsertAsset,
+		arg.Symbol,
+		arg.Name,
+		arg.Precision,
+		arg.TradingEnabled,
+		arg.WithdrawalsEnabled,
+	)
+	var i TblAsset
+	err := row.Scan(
+		&i.Symbol,
+		&i.Name,
+		&i.Precision,
+		&i.TradingEnabled,
+		&i.WithdrawalsEnabled,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const upsertPair = `-- name: UpsertPair :one
+INSERT INTO tbl_pairs (id, base, quote, tick_size, lot_size, min_amount, min_notional, price_scale, amount_scale, status, maker_fee, taker_fee)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+ON CONFLICT (id) DO UPDATE SET
+    base = EXCLUDED.base, quote = EXCLUDED.quote, tick_size = EXCLUDED.tick_size, lot_size = EXCLUDED.lot_size,
+    min_amount = EXCLUDED.min_amount, min_notional = EXCLUDED.min_notional, price_scale = EXCLUDED.price_scale,
+    amount_scale = EXCLUDED.amount_scale, status = EXCLUDED.status, maker_fee = EXCLUDED.maker_fee,
+    taker_fee = EXCLUDED.taker_fee, updated_at = NOW()
+RETURNING id, base, quote, tick_size, lot_size, min_amount, min_notional, price_scale, amount_scale, status, maker_fee, taker_fee, created_at, updated_at
+`
+
+type UpsertPairParams struct {
+	ID          string
+	Base        string
+	Quote       string
+	TickSize    decimal.Decimal
+	LotSize     decimal.Decimal
+	MinAmount   decimal.Decimal
+	MinNotional decimal.Decimal
+	PriceScale  int32
+	AmountScale int32
+	Status      string
+	MakerFee    pgtype.Float8
+	TakerFee    pgtype.Float8
+}
+
+func (q *Queries) UpsertPair(ctx context.Context, arg UpsertPairParams) (TblPair, error) {
+	row := q.db.QueryRow(ctx, upsertPair,
+		arg.ID,
+		arg.Base,
+		arg.Quote,
+		arg.TickSize,
+		arg.LotSize,
+		arg.MinAmount,
+		arg.MinNotional,
+		arg.PriceScale,
+		arg.AmountScale,
+		arg.Status,
+		arg.MakerFee,
+		arg.TakerFee,
+	)
+	var i TblPair
+	err := row.Scan(
+		&i.ID,
+		&i.Base,
+		&i.Quote,
+		&i.TickSize,
+		&i.LotSize,
+		&i.MinAmount,
+		&i.MinNotional,
+		&i.PriceScale,
+		&i.AmountScale,
+		&i.Status,
+		&i.MakerFee,
+		&i.TakerFee,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
@@ -8,9 +8,143 @@ SELECT * FROM tbl_orders WHERE account_id = $3 LIMIT $1 OFFSET $2;
 INSERT INTO tbl_order_history_events (event, order_id, metadata)
 VALUES ($1, $2, $3);
 
+-- name: InsertOrderHistoryEvents :copyfrom
+INSERT INTO tbl_order_history_events (event, order_id, metadata)
+VALUES ($1, $2, $3);
+
 -- name: CreateOrder :one
-INSERT INTO tbl_orders (pair_id, price, amount, account_id, order_type)
-VALUES ($1, $2, $3, $4, $5) RETURNING *;
+INSERT INTO tbl_orders (pair_id, price, amount, account_id, order_type, tag, strategy_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *;
 
 -- name: GetHistoryById :many
-SELECT * FROM tbl_order_history_events WHERE order_id = $1 ORDER BY created_at DESC;
+SELECT * FROM tbl_order_history_events
+WHERE order_id = @order_id AND created_at >= @since
+ORDER BY created_at DESC;
+
+-- name: InsertTrades :copyfrom
+INSERT INTO tbl_trades (
+    id, pair_id, price, amount, maker_order_id, taker_order_id,
+    maker_account_id, taker_account_id, taker_side, maker_fee, taker_fee, executed_at,
+    maker_tag, maker_strategy_id, taker_tag, taker_strategy_id, maker_rebate
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17);
+
+-- name: GetTradeByID :one
+SELECT * FROM tbl_trades WHERE id = $1;
+
+-- name: GetTrades :many
+SELECT * FROM tbl_trades
+WHERE (@account_id::INTEGER = 0 OR maker_account_id = @account_id OR taker_account_id = @account_id)
+  AND (@pair_id::VARCHAR = '' OR pair_id = @pair_id)
+  AND executed_at >= @from_time AND executed_at < @to_time
+ORDER BY executed_at DESC, id DESC
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: InsertBookSnapshot :one
+INSERT INTO tbl_book_snapshots (tenant_id, pair_id, seq, asks, bids)
+VALUES ($1, $2, $3, $4, $5) RETURNING *;
+
+-- name: DeleteBookSnapshotsBefore :exec
+DELETE FROM tbl_book_snapshots WHERE tenant_id = $1 AND pair_id = $2 AND id < $3;
+
+-- name: GetLatestBookSnapshot :one
+SELECT * FROM tbl_book_snapshots WHERE tenant_id = $1 AND pair_id = $2 ORDER BY id DESC LIMIT 1;
+
+-- name: CreateOrderHistoryPartition :one
+SELECT fn_create_order_history_partition(@month::TIMESTAMP)::VARCHAR AS name;
+
+-- name: ArchiveOrderHistoryPartitions :many
+SELECT fn_archive_order_history_partitions(@before::TIMESTAMP)::VARCHAR AS name;
+
+-- name: GetArchivedPartitions :many
+SELECT * FROM tbl_archived_partitions WHERE parent = $1 ORDER BY range_start;
+
+-- name: GetUnexportedArchivedPartitions :many
+SELECT * FROM tbl_archived_partitions WHERE parent = $1 AND exported_at IS NULL ORDER BY range_start;
+
+-- name: ReadArchivedHistoryPartition :many
+SELECT id, event, created_at, metadata, order_id FROM fn_read_archived_history_partition(@name::VARCHAR);
+
+-- name: DropArchivedPartition :exec
+SELECT fn_drop_archived_partition(@name::VARCHAR, @object_key::VARCHAR);
+
+-- name: GetTradesBetween :many
+SELECT * FROM tbl_trades WHERE executed_at >= @from_time AND executed_at < @to_time ORDER BY id;
+
+-- name: DeleteTradesBetween :exec
+DELETE FROM tbl_trades WHERE executed_at >= @from_time AND executed_at < @to_time;
+
+-- name: GetOldestTradeTime :one
+SELECT MIN(executed_at)::TIMESTAMP AS executed_at FROM tbl_trades;
+
+-- name: GetCandles1m :many
+SELECT bucket, open, high, low, close, volume, quote_volume, trades FROM cagg_candles_1m
+WHERE tenant_id = @tenant_id AND pair_id = @pair_id AND bucket >= @from_time AND bucket < @to_time
+ORDER BY bucket LIMIT @max_count;
+
+-- name: GetCandles1h :many
+SELECT bucket, open, high, low, close, volume, quote_volume, trades FROM cagg_candles_1h
+WHERE tenant_id = @tenant_id AND pair_id = @pair_id AND bucket >= @from_time AND bucket < @to_time
+ORDER BY bucket LIMIT @max_count;
+
+-- name: GetCandles1d :many
+SELECT bucket, open, high, low, close, volume, quote_volume, trades FROM cagg_candles_1d
+WHERE tenant_id = @tenant_id AND pair_id = @pair_id AND bucket >= @from_time AND bucket < @to_time
+ORDER BY bucket LIMIT @max_count;
+
+-- name: GetOrdersCreatedBetween :many
+SELECT * FROM tbl_orders WHERE created_at >= @from_time AND created_at < @to_time ORDER BY id;
+
+-- name: GetCancellationsBetween :many
+SELECT order_id, created_at FROM tbl_order_history_events
+WHERE event = 'ORDER_CANCELLED' AND created_at >= @from_time AND created_at < @to_time
+ORDER BY id;
+
+-- name: GetTradesPage :many
+SELECT * FROM tbl_trades
+WHERE (@account_id::INTEGER = 0 OR maker_account_id = @account_id OR taker_account_id = @account_id)
+  AND (@pair_id::VARCHAR = '' OR pair_id = @pair_id)
+  AND executed_at >= @from_time AND executed_at < @to_time
+  AND id > @after_id
+ORDER BY id
+LIMIT @max_count;
+
+-- name: GetOrderEventsPage :many
+SELECT e.id, e.event, e.order_id, o.account_id, o.pair_id, e.metadata, e.created_at
+FROM tbl_order_history_events e
+LEFT JOIN tbl_orders o ON o.id = e.order_id
+WHERE (@account_id::INTEGER = 0 OR o.account_id = @account_id)
+  AND (@pair_id::VARCHAR = '' OR o.pair_id = @pair_id)
+  AND e.created_at >= @from_time AND e.created_at < @to_time
+  AND e.id > @after_id
+ORDER BY e.id
+LIMIT @max_count;
+
+-- name: GetPairs :many
+SELECT * FROM tbl_pairs ORDER BY id;
+
+-- name: UpsertPair :one
+INSERT INTO tbl_pairs (id, base, quote, tick_size, lot_size, min_amount, min_notional, price_scale, amount_scale, status, maker_fee, taker_fee)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+ON CONFLICT (id) DO UPDATE SET
+    base = EXCLUDED.base, quote = EXCLUDED.quote, tick_size = EXCLUDED.tick_size, lot_size = EXCLUDED.lot_size,
+    min_amount = EXCLUDED.min_amount, min_notional = EXCLUDED.min_notional, price_scale = EXCLUDED.price_scale,
+    amount_scale = EXCLUDED.amount_scale, status = EXCLUDED.status, maker_fee = EXCLUDED.maker_fee,
+    taker_fee = EXCLUDED.taker_fee, updated_at = NOW()
+RETURNING *;
+
+-- name: DeletePair :exec
+DELETE FROM tbl_pairs WHERE id = $1;
+
+-- name: GetAssets :many
+SELECT * FROM tbl_assets ORDER BY symbol;
+
+-- name: UpsertAsset :one
+INSERT INTO tbl_assets (symbol, name, precision, trading_enabled, withdrawals_enabled)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (symbol) DO UPDATE SET
+    name = EXCLUDED.name, precision = EXCLUDED.precision, trading_enabled = EXCLUDED.trading_enabled,
+    withdrawals_enabled = EXCLUDED.withdrawals_enabled, updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteAsset :exec
+DELETE FROM tbl_assets WHERE symbol = $1;
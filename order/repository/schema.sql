@@ -2,22 +2,107 @@ CREATE TABLE tbl_orders
 (
     id BIGSERIAL PRIMARY KEY,
     pair_id VARCHAR(25) NOT NULL,
-    price DECIMAL(20, 10) NOT NULL,
-    amount DECIMAL(20, 10) NOT NULL,
+    price NUMERIC NOT NULL,
+    amount NUMERIC NOT NULL,
     created_at TIMESTAMP DEFAULT NOW(),
     order_type int NOT NULL,
 
-    account_id INTEGER REFERENCES tbl_accounts(id)
+    account_id INTEGER REFERENCES tbl_accounts(id),
+    tag VARCHAR(64) NOT NULL DEFAULT '',
+    strategy_id VARCHAR(64) NOT NULL DEFAULT ''
 );
 
 
 
 CREATE TABLE tbl_order_history_events (
-    id SERIAL PRIMARY KEY,
+    id BIGSERIAL,
     event VARCHAR(255) NOT NULL,
-    status VARCHAR(255) NOT NULL,
-    created_at TIMESTAMP DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
     metadata JSONB DEFAULT '{}',
 
-    order_id BIGINT REFERENCES tbl_orders(id)
+    order_id BIGINT REFERENCES tbl_orders(id),
+
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+CREATE TABLE tbl_archived_partitions (
+    name VARCHAR(63) PRIMARY KEY,
+    parent VARCHAR(63) NOT NULL,
+    range_start TIMESTAMP NOT NULL,
+    range_end TIMESTAMP NOT NULL,
+    archived_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    object_key VARCHAR(255),
+    exported_at TIMESTAMP
+);
+
+CREATE TABLE tbl_trades
+(
+    id BIGINT PRIMARY KEY,
+    pair_id VARCHAR(25) NOT NULL,
+    price NUMERIC NOT NULL,
+    amount NUMERIC NOT NULL,
+    maker_order_id BIGINT NOT NULL REFERENCES tbl_orders(id),
+    taker_order_id BIGINT NOT NULL REFERENCES tbl_orders(id),
+    maker_account_id INTEGER NOT NULL REFERENCES tbl_accounts(id),
+    taker_account_id INTEGER NOT NULL REFERENCES tbl_accounts(id),
+    taker_side INTEGER NOT NULL,
+    maker_fee NUMERIC NOT NULL,
+    taker_fee NUMERIC NOT NULL,
+    executed_at TIMESTAMP NOT NULL,
+    maker_tag VARCHAR(64) NOT NULL DEFAULT '',
+    maker_strategy_id VARCHAR(64) NOT NULL DEFAULT '',
+    taker_tag VARCHAR(64) NOT NULL DEFAULT '',
+    taker_strategy_id VARCHAR(64) NOT NULL DEFAULT '',
+    maker_rebate NUMERIC NOT NULL DEFAULT 0
+);
+
+CREATE TABLE tbl_trade_ticks
+(
+    trade_id BIGINT NOT NULL,
+    tenant_id VARCHAR(64) NOT NULL,
+    pair_id VARCHAR(25) NOT NULL,
+    price NUMERIC NOT NULL,
+    amount NUMERIC NOT NULL,
+    executed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (trade_id, executed_at)
+);
+
+CREATE TABLE tbl_book_snapshots
+(
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL,
+    pair_id VARCHAR(25) NOT NULL,
+    seq BIGINT NOT NULL,
+    asks JSONB NOT NULL,
+    bids JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE tbl_pairs
+(
+    id VARCHAR(25) PRIMARY KEY,
+    base VARCHAR(16) NOT NULL,
+    quote VARCHAR(16) NOT NULL,
+    tick_size NUMERIC NOT NULL DEFAULT 0,
+    lot_size NUMERIC NOT NULL DEFAULT 0,
+    min_amount NUMERIC NOT NULL DEFAULT 0,
+    min_notional NUMERIC NOT NULL DEFAULT 0,
+    price_scale INTEGER NOT NULL,
+    amount_scale INTEGER NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'trading',
+    maker_fee DOUBLE PRECISION,
+    taker_fee DOUBLE PRECISION,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE tbl_assets
+(
+    symbol VARCHAR(16) PRIMARY KEY,
+    name VARCHAR(64) NOT NULL DEFAULT '',
+    precision INTEGER NOT NULL,
+    trading_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    withdrawals_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
 );
@@ -0,0 +1,138 @@
+package order
+
+import (
+	"context"
+	"order-book/db/resilience"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// guard runs a repository call through the breaker, writes wait for the
+// database instead of failing while queue is set
+type guard struct {
+	breaker *resilience.Breaker
+	queue   bool
+}
+
+func (g guard) read(ctx context.Context, fn func(ctx context.Context) error) error {
+	return g.breaker.Do(ctx, fn)
+}
+
+func (g guard) write(ctx context.Context, fn func(ctx context.Context) error) error {
+	if g.queue {
+		return g.breaker.DoWait(ctx, fn)
+	}
+	return g.breaker.Do(ctx, fn)
+}
+
+type resilientOrderRepo struct {
+	repo OrderRepo
+	guard
+}
+
+// NewResilientOrderRepository guards the calls of repo with the breaker.
+// With queue set, the writes of the engine wait for the database to come
+// back so its orders are held in the book queue rather than dropped.
+func NewResilientOrderRepository(repo OrderRepo, breaker *resilience.Breaker, queue bool) OrderRepo {
+	return &resilientOrderRepo{repo: repo, guard: guard{breaker: breaker, queue: queue}}
+}
+
+func (r *resilientOrderRepo) AddEvent(ctx context.Context, ev OrderHistoryEvent) error {
+	return r.write(ctx, func(ctx context.Context) error {
+		return r.repo.AddEvent(ctx, ev)
+	})
+}
+
+func (r *resilientOrderRepo) AddEvents(ctx context.Context, evs []OrderHistoryEvent) error {
+	return r.write(ctx, func(ctx context.Context) error {
+		return r.repo.AddEvents(ctx, evs)
+	})
+}
+
+func (r *resilientOrderRepo) GetOrders(ctx context.Context, page int, size int, accountId int) (res *paginatedOrders, err error) {
+	err = r.read(ctx, func(ctx context.Context) error {
+		res, err = r.repo.GetOrders(ctx, page, size, accountId)
+		return err
+	})
+	return res, err
+}
+
+func (r *resilientOrderRepo) GetOrderByID(ctx context.Context, id int) (res Order, err error) {
+	err = r.read(ctx, func(ctx context.Context) error {
+		res, err = r.repo.GetOrderByID(ctx, id)
+		return err
+	})
+	return res, err
+}
+
+func (r *resilientOrderRepo) GetOrderHistoryByID(ctx context.Context, id int) (res []OrderHistoryEvent, err error) {
+	err = r.read(ctx, func(ctx context.Context) error {
+		res, err = r.repo.GetOrderHistoryByID(ctx, id)
+		return err
+	})
+	return res, err
+}
+
+func (r *resilientOrderRepo) CreateOrder(ctx context.Context, pairID string, price decimal.Decimal, amount decimal.Decimal, accountID int, orderType OrderType, tag string, strategyId string) (res Order, err error) {
+	err = r.write(ctx, func(ctx context.Context) error {
+		res, err = r.repo.CreateOrder(ctx, pairID, price, amount, accountID, orderType, tag, strategyId)
+		return err
+	})
+	return res, err
+}
+
+type resilientTradeRepo struct {
+	repo TradeRepo
+	guard
+}
+
+// NewResilientTradeRepository guards the calls of repo with the breaker like
+// NewResilientOrderRepository
+func NewResilientTradeRepository(repo TradeRepo, breaker *resilience.Breaker, queue bool) TradeRepo {
+	return &resilientTradeRepo{repo: repo, guard: guard{breaker: breaker, queue: queue}}
+}
+
+func (r *resilientTradeRepo) AddTrades(ctx context.Context, trades []Trade) error {
+	return r.write(ctx, func(ctx context.Context) error {
+		return r.repo.AddTrades(ctx, trades)
+	})
+}
+
+func (r *resilientTradeRepo) GetTrade(ctx context.Context, id int64) (res Trade, err error) {
+	err = r.read(ctx, func(ctx context.Context) error {
+		res, err = r.repo.GetTrade(ctx, id)
+		return err
+	})
+	return res, err
+}
+
+func (r *resilientTradeRepo) GetTrades(ctx context.Context, filter TradeFilter, page int, size int) (res []Trade, err error) {
+	err = r.read(ctx, func(ctx context.Context) error {
+		res, err = r.repo.GetTrades(ctx, filter, page, size)
+		return err
+	})
+	return res, err
+}
+
+func (r *resilientTradeRepo) GetTradesBetween(ctx context.Context, from time.Time, to time.Time) (res []Trade, err error) {
+	err = r.read(ctx, func(ctx context.Context) error {
+		res, err = r.repo.GetTradesBetween(ctx, from, to)
+		return err
+	})
+	return res, err
+}
+
+func (r *resilientTradeRepo) DeleteTradesBetween(ctx context.Context, from time.Time, to time.Time) error {
+	return r.read(ctx, func(ctx context.Context) error {
+		return r.repo.DeleteTradesBetween(ctx, from, to)
+	})
+}
+
+func (r *resilientTradeRepo) OldestTradeTime(ctx context.Context) (executedAt time.Time, ok bool, err error) {
+	err = r.read(ctx, func(ctx context.Context) error {
+		executedAt, ok, err = r.repo.OldestTradeTime(ctx)
+		return err
+	})
+	return executedAt, ok, err
+}
@@ -0,0 +1,128 @@
+package order
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var ErrUnsupportedSchemaVersion = errors.New("Event schema version is newer than supported")
+
+// SchemaVersionKey is the metadata key holding the schema version of a history event
+const SchemaVersionKey = "v"
+
+// HistorySchemas are the current metadata schema versions of the history events.
+// Events written before versioning have no version and are read as version 1.
+//
+//	ORDER_CREATED   1: {}
+//	ORDER_CREATED   2: {version, actor, after}
+//	ORDER_CANCELLED 1: {}
+//	ORDER_CANCELLED 2: {version, actor, before, after}
+//	TARGET_HIT      1: {matching_order_id}
+//	TARGET_HIT      2: {taker_order_id, price, amount}
+//	TARGET_HIT      3: {taker_order_id, price, amount, version, actor, before, after}
+//	ORDER_FILLED    1: {maker_order_id, price, amount, version, actor, before, after}
+//	ORDER_REJECTED  1: {account_id, pair_id, type, price, amount, reason, actor}
+//	CONFIG_CHANGED  1: {setting, before, after, source, actor}
+var HistorySchemas = map[string]int{
+	ORDER_CREATED:   2,
+	ORDER_CANCELLED: 2,
+	TARGET_HIT:      3,
+	ORDER_FILLED:    1,
+	ORDER_REJECTED:  1,
+	CONFIG_CHANGED:  1,
+}
+
+// Upcaster turns the metadata of an event at one version into the next version
+type Upcaster func(metadata map[string]any) map[string]any
+
+// upcasters of each event, indexed by the version they upcast from
+var upcasters = map[string]map[int]Upcaster{
+	ORDER_CREATED: {
+		1: unaudited,
+	},
+	ORDER_CANCELLED: {
+		1: unaudited,
+	},
+	TARGET_HIT: {
+		// The price and amount of the fill weren't recorded
+		1: func(metadata map[string]any) map[string]any {
+			metadata["taker_order_id"] = metadata["matching_order_id"]
+			delete(metadata, "matching_order_id")
+			return metadata
+		},
+		2: unaudited,
+	},
+}
+
+// unaudited upcasts the events recorded before the version, the actor and
+// the states of the order were. They can't be recovered, the events keep a
+// zero version and come first in the timeline of their order.
+func unaudited(metadata map[string]any) map[string]any {
+	return metadata
+}
+
+// stamp sets the current schema version of the event in its metadata
+func (ev *OrderHistoryEvent) stamp() {
+	version, ok := HistorySchemas[ev.Name]
+	if !ok {
+		return
+	}
+	if ev.Metadata == nil {
+		ev.Metadata = make(map[string]any, 1)
+	}
+	ev.Metadata[SchemaVersionKey] = version
+}
+
+// Upcast brings the metadata of an event read from the history, the archive
+// or the outbox to the current schema version. An event written by a newer
+// version of the engine is rejected rather than read with a schema it
+// doesn't follow.
+func Upcast(ev OrderHistoryEvent) (OrderHistoryEvent, error) {
+	current, ok := HistorySchemas[ev.Name]
+	if !ok {
+		return ev, nil
+	}
+	version, err := schemaVersion(ev.Metadata)
+	if err != nil {
+		return ev, err
+	}
+	if version > current {
+		repoLog.Error("history event has an unsupported schema version", map[string]any{
+			"event_id":  ev.ID,
+			"event":     ev.Name,
+			"version":   version,
+			"supported": current,
+		})
+		return ev, fmt.Errorf("%w: %s v%d, supported v%d", ErrUnsupportedSchemaVersion, ev.Name, version, current)
+	}
+	if ev.Metadata == nil {
+		ev.Metadata = make(map[string]any, 1)
+	}
+	for ; version < current; version++ {
+		upcast, ok := upcasters[ev.Name][version]
+		if !ok {
+			return ev, fmt.Errorf("no upcaster for %s v%d", ev.Name, version)
+		}
+		ev.Metadata = upcast(ev.Metadata)
+	}
+	ev.Metadata[SchemaVersionKey] = current
+	return ev, nil
+}
+
+// schemaVersion reads the version of decoded metadata, numbers are float64
+// once decoded from JSON
+func schemaVersion(metadata map[string]any) (int, error) {
+	switch v := metadata[SchemaVersionKey].(type) {
+	case nil:
+		return 1, nil
+	case int:
+		return v, nil
+	case float64:
+		return int(v), nil
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err
+	}
+	return 0, fmt.Errorf("invalid schema version %v", metadata[SchemaVersionKey])
+}
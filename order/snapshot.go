@@ -0,0 +1,162 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	repository "order-book/order/repository/gen"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+)
+
+var ErrSnapshotNotFound = errors.New("Book snapshot not found")
+
+// SnapshotOrder is a resting order in a book snapshot, its pair, side and
+// price are those of the level it rests on
+type SnapshotOrder struct {
+	ID        int             `json:"id"`
+	AccountID int             `json:"account_id"`
+	Amount    decimal.Decimal `json:"amount"`
+	CreatedAt time.Time       `json:"created_at"`
+	Version   int             `json:"version,omitempty"`
+}
+
+// PriceLevel holds the orders resting at a price in time priority
+type PriceLevel struct {
+	Price  decimal.Decimal `json:"price"`
+	Orders []SnapshotOrder `json:"orders"`
+}
+
+// BookSnapshot is the resting book of a pair once the engine applied the
+// event numbered Seq. Levels of both sides are in ascending price order.
+type BookSnapshot struct {
+	TenantID  string       `json:"tenant_id"`
+	PairID    string       `json:"pair_id"`
+	Seq       int64        `json:"seq"`
+	Asks      []PriceLevel `json:"asks"`
+	Bids      []PriceLevel `json:"bids"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// Orders expands the snapshot back into the resting orders it was taken from
+func (s BookSnapshot) Orders() []Order {
+	var orders []Order
+	for _, side := range []struct {
+		levels    []PriceLevel
+		orderType OrderType
+	}{{s.Asks, ASK}, {s.Bids, BID}} {
+		for _, level := range side.levels {
+			for _, o := range level.Orders {
+				orders = append(orders, Order{
+					ID:        o.ID,
+					AccountID: o.AccountID,
+					PairID:    s.PairID,
+					Price:     level.Price,
+					Amount:    o.Amount,
+					Type:      side.orderType,
+					CreatedAt: o.CreatedAt,
+					Version:   o.Version,
+				})
+			}
+		}
+	}
+	return orders
+}
+
+type SnapshotRepo interface {
+	// SaveSnapshot writes a snapshot and drops the older ones of the same book
+	SaveSnapshot(ctx context.Context, s BookSnapshot) error
+	GetLatestSnapshot(ctx context.Context, tenantId string, pairId string) (BookSnapshot, error)
+}
+
+type snapshotRepo struct {
+	queries      *repository.Queries
+	dbpool       *pgxpool.Pool
+	queryTimeout time.Duration
+}
+
+func (repo *snapshotRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *snapshotRepo) SaveSnapshot(ctx context.Context, s BookSnapshot) error {
+	asks, err := json.Marshal(s.Asks)
+	if err != nil {
+		return err
+	}
+	bids, err := json.Marshal(s.Bids)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	tx, err := repo.dbpool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	qtx := repo.queries.WithTx(tx)
+	created, err := qtx.InsertBookSnapshot(ctx, repository.InsertBookSnapshotParams{
+		TenantID: s.TenantID,
+		PairID:   s.PairID,
+		Seq:      s.Seq,
+		Asks:     asks,
+		Bids:     bids,
+	})
+	if err != nil {
+		return err
+	}
+	err = qtx.DeleteBookSnapshotsBefore(ctx, repository.DeleteBookSnapshotsBeforeParams{
+		TenantID: s.TenantID,
+		PairID:   s.PairID,
+		ID:       created.ID,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (repo *snapshotRepo) GetLatestSnapshot(ctx context.Context, tenantId string, pairId string) (BookSnapshot, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	res, err := repo.queries.GetLatestBookSnapshot(ctx, repository.GetLatestBookSnapshotParams{
+		TenantID: tenantId,
+		PairID:   pairId,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return BookSnapshot{}, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return BookSnapshot{}, err
+	}
+	return convertSnapshot(res)
+}
+
+func NewSnapshotRepository(dbpool *pgxpool.Pool, queryTimeout time.Duration) SnapshotRepo {
+	return &snapshotRepo{
+		queries:      repository.New(dbpool),
+		dbpool:       dbpool,
+		queryTimeout: queryTimeout,
+	}
+}
+
+func convertSnapshot(s repository.TblBookSnapshot) (res BookSnapshot, err error) {
+	res.TenantID = s.TenantID
+	res.PairID = s.PairID
+	res.Seq = s.Seq
+	res.CreatedAt = s.CreatedAt.Time
+	if err = json.Unmarshal(s.Asks, &res.Asks); err != nil {
+		return
+	}
+	err = json.Unmarshal(s.Bids, &res.Bids)
+	return
+}
@@ -0,0 +1,640 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"order-book/db/sqlite"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// The SQLite repositories run the engine on a single local file for
+// development. They follow the Postgres ones, minus the replica reads.
+
+type sqliteOrderRepo struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func (repo *sqliteOrderRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *sqliteOrderRepo) AddEvent(ctx context.Context, ev OrderHistoryEvent) error {
+	return repo.AddEvents(ctx, []OrderHistoryEvent{ev})
+}
+
+func (repo *sqliteOrderRepo) AddEvents(ctx context.Context, evs []OrderHistoryEvent) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := sqlite.Now()
+	for _, ev := range evs {
+		ev.stamp()
+		jsonRawMsg, err := json.Marshal(ev.Metadata)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO tbl_order_history_events (event, order_id, metadata, created_at) VALUES (?, ?, ?, ?)",
+			ev.Name, sql.NullInt64{Int64: int64(ev.OrderId), Valid: ev.OrderId != 0}, string(jsonRawMsg), now,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const sqliteOrderColumns = "id, pair_id, price, amount, created_at, order_type, account_id, tag, strategy_id"
+
+func scanSQLiteOrder(row interface{ Scan(...any) error }) (Order, error) {
+	var (
+		res       Order
+		createdAt sql.NullTime
+		accountId sql.NullInt64
+	)
+	err := row.Scan(&res.ID, &res.PairID, &res.Price, &res.Amount, &createdAt, &res.Type, &accountId, &res.Tag, &res.StrategyID)
+	res.CreatedAt = createdAt.Time
+	res.AccountID = int(accountId.Int64)
+	return res, err
+}
+
+func (repo *sqliteOrderRepo) GetOrders(ctx context.Context, page int, size int, accountId int) (*paginatedOrders, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	rows, err := repo.db.QueryContext(ctx,
+		"SELECT "+sqliteOrderColumns+" FROM tbl_orders WHERE account_id = ? LIMIT ? OFFSET ?",
+		accountId, size, max(0, page-1)*size,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	orders := []Order{}
+	for rows.Next() {
+		ord, err := scanSQLiteOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, ord)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &paginatedOrders{
+		Orders: orders,
+		Total:  len(orders),
+	}, nil
+}
+
+func (repo *sqliteOrderRepo) GetOrderByID(ctx context.Context, id int) (Order, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	res, err := scanSQLiteOrder(repo.db.QueryRowContext(ctx, "SELECT "+sqliteOrderColumns+" FROM tbl_orders WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Order{}, ErrOrderNotFound
+	}
+	return res, err
+}
+
+func (repo *sqliteOrderRepo) CreateOrder(ctx context.Context, pairID string, price decimal.Decimal, amount decimal.Decimal, accountID int, orderType OrderType, tag string, strategyId string) (Order, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Order{}, err
+	}
+	defer tx.Rollback()
+
+	now := sqlite.Now()
+	createdOrder, err := scanSQLiteOrder(tx.QueryRowContext(ctx,
+		"INSERT INTO tbl_orders (pair_id, price, amount, account_id, order_type, tag, strategy_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING "+sqliteOrderColumns,
+		pairID, price.String(), amount.String(), accountID, int(orderType), tag, strategyId, now,
+	))
+	if err != nil {
+		return Order{}, err
+	}
+	created := createdEvent(ctx, createdOrder)
+	jsonRawMsg, err := json.Marshal(created.Metadata)
+	if err != nil {
+		return Order{}, err
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO tbl_order_history_events (event, order_id, metadata, created_at) VALUES (?, ?, ?, ?)",
+		ORDER_CREATED, createdOrder.ID, string(jsonRawMsg), now,
+	)
+	if err != nil {
+		repoLog.Error("failed to insert order history event, rolling back", map[string]any{
+			"order_id": createdOrder.ID,
+			"error":    err,
+		})
+		return Order{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		repoLog.Error("failed to commit transaction, rolling back", map[string]any{
+			"order_id": createdOrder.ID,
+			"error":    err,
+		})
+		return Order{}, err
+	}
+	createdOrder.Version = 1
+	return createdOrder, nil
+}
+
+func (repo *sqliteOrderRepo) GetOrderHistoryByID(ctx context.Context, id int) ([]OrderHistoryEvent, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	var exists bool
+	err := repo.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM tbl_orders WHERE id = ?)", id).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrOrderNotFound
+	}
+	rows, err := repo.db.QueryContext(ctx,
+		"SELECT id, event, order_id, metadata, created_at FROM tbl_order_history_events WHERE order_id = ? ORDER BY created_at DESC, id DESC",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := []OrderHistoryEvent{}
+	for rows.Next() {
+		var (
+			ev       OrderHistoryEvent
+			metadata sql.NullString
+		)
+		if err := rows.Scan(&ev.ID, &ev.Name, &ev.OrderId, &metadata, &ev.CreatedAt); err != nil {
+			return nil, err
+		}
+		if metadata.String != "" {
+			if err := json.Unmarshal([]byte(metadata.String), &ev.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		if ev, err = Upcast(ev); err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+// NewSQLiteOrderRepository is NewOrderRepository on a SQLite database
+func NewSQLiteOrderRepository(db *sql.DB, queryTimeout time.Duration) OrderRepo {
+	return &sqliteOrderRepo{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
+
+type sqliteTradeRepo struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func (repo *sqliteTradeRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+const sqliteTradeColumns = `id, pair_id, price, amount, maker_order_id, taker_order_id,
+	maker_account_id, taker_account_id, taker_side, maker_fee, taker_fee, executed_at,
+	maker_tag, maker_strategy_id, taker_tag, taker_strategy_id, maker_rebate`
+
+func scanSQLiteTrade(row interface{ Scan(...any) error }) (t Trade, err error) {
+	err = row.Scan(
+		&t.ID, &t.PairID, &t.Price, &t.Amount, &t.MakerOrderID, &t.TakerOrderID,
+		&t.MakerAccountID, &t.TakerAccountID, &t.TakerSide, &t.MakerFee, &t.TakerFee, &t.ExecutedAt,
+		&t.MakerTag, &t.MakerStrategyID, &t.TakerTag, &t.TakerStrategyID, &t.MakerRebate,
+	)
+	return
+}
+
+func (repo *sqliteTradeRepo) queryTrades(ctx context.Context, query string, args ...any) ([]Trade, error) {
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	trades := []Trade{}
+	for rows.Next() {
+		t, err := scanSQLiteTrade(rows)
+		if err != nil {
+			return nil, err
+		}
+		trades = append(trades, t)
+	}
+	return trades, rows.Err()
+}
+
+func (repo *sqliteTradeRepo) AddTrades(ctx context.Context, trades []Trade) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, t := range trades {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO tbl_trades ("+sqliteTradeColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			t.ID, t.PairID, t.Price.String(), t.Amount.String(), t.MakerOrderID, t.TakerOrderID,
+			t.MakerAccountID, t.TakerAccountID, int(t.TakerSide), t.MakerFee.String(), t.TakerFee.String(), t.ExecutedAt.UTC(),
+			t.MakerTag, t.MakerStrategyID, t.TakerTag, t.TakerStrategyID, t.MakerRebate.String(),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (repo *sqliteTradeRepo) GetTrade(ctx context.Context, id int64) (Trade, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	res, err := scanSQLiteTrade(repo.db.QueryRowContext(ctx, "SELECT "+sqliteTradeColumns+" FROM tbl_trades WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Trade{}, ErrTradeNotFound
+	}
+	return res, err
+}
+
+func (repo *sqliteTradeRepo) GetTrades(ctx context.Context, filter TradeFilter, page int, size int) ([]Trade, error) {
+	to := filter.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queryTrades(ctx, `SELECT `+sqliteTradeColumns+` FROM tbl_trades
+		WHERE (?1 = 0 OR maker_account_id = ?1 OR taker_account_id = ?1)
+		  AND (?2 = '' OR pair_id = ?2)
+		  AND executed_at >= ?3 AND executed_at < ?4
+		ORDER BY executed_at DESC, id DESC
+		LIMIT ?5 OFFSET ?6`,
+		filter.AccountID, filter.PairID, filter.From.UTC(), to.UTC(), size, max(0, page-1)*size,
+	)
+}
+
+func (repo *sqliteTradeRepo) GetTradesBetween(ctx context.Context, from time.Time, to time.Time) ([]Trade, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queryTrades(ctx,
+		"SELECT "+sqliteTradeColumns+" FROM tbl_trades WHERE executed_at >= ? AND executed_at < ? ORDER BY id",
+		from.UTC(), to.UTC(),
+	)
+}
+
+func (repo *sqliteTradeRepo) DeleteTradesBetween(ctx context.Context, from time.Time, to time.Time) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err := repo.db.ExecContext(ctx,
+		"DELETE FROM tbl_trades WHERE executed_at >= ? AND executed_at < ?",
+		from.UTC(), to.UTC(),
+	)
+	return err
+}
+
+func (repo *sqliteTradeRepo) OldestTradeTime(ctx context.Context) (time.Time, bool, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	// MIN loses the declared type of the column, the time is read back as text
+	t, err := scanSQLiteTrade(repo.db.QueryRowContext(ctx, "SELECT "+sqliteTradeColumns+" FROM tbl_trades ORDER BY executed_at LIMIT 1"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t.ExecutedAt, true, nil
+}
+
+// NewSQLiteTradeRepository is NewTradeRepository on a SQLite database
+func NewSQLiteTradeRepository(db *sql.DB, queryTimeout time.Duration) TradeRepo {
+	return &sqliteTradeRepo{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
+
+type sqliteSnapshotRepo struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func (repo *sqliteSnapshotRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *sqliteSnapshotRepo) SaveSnapshot(ctx context.Context, s BookSnapshot) error {
+	asks, err := json.Marshal(s.Asks)
+	if err != nil {
+		return err
+	}
+	bids, err := json.Marshal(s.Bids)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO tbl_book_snapshots (tenant_id, pair_id, seq, asks, bids, created_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING id",
+		s.TenantID, s.PairID, s.Seq, string(asks), string(bids), sqlite.Now(),
+	).Scan(&id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM tbl_book_snapshots WHERE tenant_id = ? AND pair_id = ? AND id < ?",
+		s.TenantID, s.PairID, id,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (repo *sqliteSnapshotRepo) GetLatestSnapshot(ctx context.Context, tenantId string, pairId string) (BookSnapshot, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	var (
+		res        BookSnapshot
+		asks, bids string
+	)
+	err := repo.db.QueryRowContext(ctx,
+		"SELECT tenant_id, pair_id, seq, asks, bids, created_at FROM tbl_book_snapshots WHERE tenant_id = ? AND pair_id = ? ORDER BY id DESC LIMIT 1",
+		tenantId, pairId,
+	).Scan(&res.TenantID, &res.PairID, &res.Seq, &asks, &bids, &res.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return BookSnapshot{}, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return BookSnapshot{}, err
+	}
+	if err := json.Unmarshal([]byte(asks), &res.Asks); err != nil {
+		return BookSnapshot{}, err
+	}
+	err = json.Unmarshal([]byte(bids), &res.Bids)
+	return res, err
+}
+
+// NewSQLiteSnapshotRepository is NewSnapshotRepository on a SQLite database
+func NewSQLiteSnapshotRepository(db *sql.DB, queryTimeout time.Duration) SnapshotRepo {
+	return &sqliteSnapshotRepo{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
+
+type sqliteCandleRepo struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func (repo *sqliteCandleRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+// GetCandles buckets the trades themselves, there are no continuous
+// aggregates to read them from
+func (repo *sqliteCandleRepo) GetCandles(ctx context.Context, tenantId string, pairId string, interval CandleInterval, from time.Time, to time.Time, limit int) ([]Candle, error) {
+	step := interval.Duration()
+	if step == 0 {
+		return nil, ErrInvalidInterval
+	}
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	// Trades of the bucket open at from are included like the aggregates do
+	rows, err := repo.db.QueryContext(ctx, `SELECT t.price, t.amount, t.executed_at FROM tbl_trades t
+		JOIN tbl_accounts a ON a.id = t.taker_account_id
+		WHERE a.tenant_id = ? AND t.pair_id = ? AND t.executed_at >= ? AND t.executed_at < ?
+		ORDER BY t.executed_at, t.id`,
+		tenantId, pairId, from.Truncate(step).UTC(), to.Truncate(step).Add(step).UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candles := []Candle{}
+	for rows.Next() {
+		var (
+			price, amount decimal.Decimal
+			executedAt    time.Time
+		)
+		if err := rows.Scan(&price, &amount, &executedAt); err != nil {
+			return nil, err
+		}
+		openTime := executedAt.Truncate(step)
+		if openTime.Before(from) || !openTime.Before(to) {
+			continue
+		}
+		last := len(candles) - 1
+		if last < 0 || !candles[last].OpenTime.Equal(openTime) {
+			if len(candles) == limit {
+				break
+			}
+			candles = append(candles, Candle{
+				PairID:   pairId,
+				Interval: interval,
+				OpenTime: openTime,
+				Open:     price,
+				High:     price,
+				Low:      price,
+			})
+			last++
+		}
+		c := &candles[last]
+		c.High = decimal.Max(c.High, price)
+		c.Low = decimal.Min(c.Low, price)
+		c.Close = price
+		c.Volume = c.Volume.Add(amount)
+		c.QuoteVolume = c.QuoteVolume.Add(price.Mul(amount))
+		c.Trades++
+	}
+	return candles, rows.Err()
+}
+
+// NewSQLiteCandleRepository is NewCandleRepository on a SQLite database
+func NewSQLiteCandleRepository(db *sql.DB, queryTimeout time.Duration) CandleRepo {
+	return &sqliteCandleRepo{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
+
+type sqlitePairRepo struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func (repo *sqlitePairRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *sqlitePairRepo) GetPairs(ctx context.Context) ([]Pair, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	rows, err := repo.db.QueryContext(ctx,
+		"SELECT id, base, quote, tick_size, lot_size, min_amount, min_notional, price_scale, amount_scale, status, maker_fee, taker_fee FROM tbl_pairs ORDER BY id",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Pair{}
+	for rows.Next() {
+		var (
+			p                  Pair
+			makerFee, takerFee sql.NullFloat64
+		)
+		err := rows.Scan(&p.ID, &p.Base, &p.Quote, &p.TickSize, &p.LotSize, &p.MinAmount, &p.MinNotional, &p.PriceScale, &p.AmountScale, &p.Status, &makerFee, &takerFee)
+		if err != nil {
+			return nil, err
+		}
+		if makerFee.Valid {
+			p.MakerFee = &makerFee.Float64
+		}
+		if takerFee.Valid {
+			p.TakerFee = &takerFee.Float64
+		}
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+func (repo *sqlitePairRepo) SavePair(ctx context.Context, p Pair) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	var makerFee, takerFee sql.NullFloat64
+	if p.MakerFee != nil {
+		makerFee = sql.NullFloat64{Float64: *p.MakerFee, Valid: true}
+	}
+	if p.TakerFee != nil {
+		takerFee = sql.NullFloat64{Float64: *p.TakerFee, Valid: true}
+	}
+	now := sqlite.Now()
+	_, err := repo.db.ExecContext(ctx,
+		`INSERT INTO tbl_pairs (id, base, quote, tick_size, lot_size, min_amount, min_notional, price_scale, amount_scale, status, maker_fee, taker_fee, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET base = excluded.base, quote = excluded.quote, tick_size = excluded.tick_size,
+			lot_size = excluded.lot_size, min_amount = excluded.min_amount, min_notional = excluded.min_notional,
+			price_scale = excluded.price_scale, amount_scale = excluded.amount_scale, status = excluded.status,
+			maker_fee = excluded.maker_fee, taker_fee = excluded.taker_fee, updated_at = excluded.updated_at`,
+		p.ID, p.Base, p.Quote, p.TickSize.String(), p.LotSize.String(), p.MinAmount.String(), p.MinNotional.String(),
+		p.PriceScale, p.AmountScale, p.Status, makerFee, takerFee, now, now,
+	)
+	return err
+}
+
+func (repo *sqlitePairRepo) DeletePair(ctx context.Context, pairId string) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err := repo.db.ExecContext(ctx, "DELETE FROM tbl_pairs WHERE id = ?", pairId)
+	return err
+}
+
+// NewSQLitePairRepository is NewPairRepository on a SQLite database
+func NewSQLitePairRepository(db *sql.DB, queryTimeout time.Duration) PairRepo {
+	return &sqlitePairRepo{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
+
+type sqliteAssetRepo struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func (repo *sqliteAssetRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *sqliteAssetRepo) GetAssets(ctx context.Context) ([]Asset, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	rows, err := repo.db.QueryContext(ctx,
+		"SELECT symbol, name, precision, trading_enabled, withdrawals_enabled FROM tbl_assets ORDER BY symbol",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Asset{}
+	for rows.Next() {
+		var a Asset
+		if err := rows.Scan(&a.Symbol, &a.Name, &a.Precision, &a.TradingEnabled, &a.WithdrawalsEnabled); err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+func (repo *sqliteAssetRepo) SaveAsset(ctx context.Context, a Asset) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	now := sqlite.Now()
+	_, err := repo.db.ExecContext(ctx,
+		`INSERT INTO tbl_assets (symbol, name, precision, trading_enabled, withdrawals_enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (symbol) DO UPDATE SET name = excluded.name, precision = excluded.precision,
+			trading_enabled = excluded.trading_enabled, withdrawals_enabled = excluded.withdrawals_enabled,
+			updated_at = excluded.updated_at`,
+		a.Symbol, a.Name, a.Precision, a.TradingEnabled, a.WithdrawalsEnabled, now, now,
+	)
+	return err
+}
+
+func (repo *sqliteAssetRepo) DeleteAsset(ctx context.Context, symbol string) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err := repo.db.ExecContext(ctx, "DELETE FROM tbl_assets WHERE symbol = ?", symbol)
+	return err
+}
+
+// NewSQLiteAssetRepository is NewAssetRepository on a SQLite database
+func NewSQLiteAssetRepository(db *sql.DB, queryTimeout time.Duration) AssetRepo {
+	return &sqliteAssetRepo{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
@@ -0,0 +1,34 @@
+package order
+
+import "errors"
+
+// MAX_TAG_LENGTH bounds the tag and the strategy ID of an order, in bytes
+const MAX_TAG_LENGTH = 64
+
+var (
+	ErrTagTooLong        = errors.New("Tag should be at most 64 characters")
+	ErrStrategyIDTooLong = errors.New("Strategy ID should be at most 64 characters")
+)
+
+// ValidateTags checks the client labels of an order
+func ValidateTags(o Order) error {
+	if len(o.Tag) > MAX_TAG_LENGTH {
+		return ErrTagTooLong
+	}
+	if len(o.StrategyID) > MAX_TAG_LENGTH {
+		return ErrStrategyIDTooLong
+	}
+	return nil
+}
+
+// ForAccount hides the tags of the other side of a trade from an account,
+// they're labels of its owner only
+func (t Trade) ForAccount(accountId int) Trade {
+	if t.MakerAccountID != accountId {
+		t.MakerTag, t.MakerStrategyID = "", ""
+	}
+	if t.TakerAccountID != accountId {
+		t.TakerTag, t.TakerStrategyID = "", ""
+	}
+	return t
+}
@@ -0,0 +1,198 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"order-book/db/replica"
+	repository "order-book/order/repository/gen"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrTradeNotFound = errors.New("Trade not found")
+
+// TradeFilter narrows a trade query. A zero AccountID or an empty PairID
+// matches every account or pair, a zero To means up to now.
+type TradeFilter struct {
+	AccountID int
+	PairID    string
+	From      time.Time
+	To        time.Time
+}
+
+type TradeRepo interface {
+	// AddTrades records the fills of a match with a single COPY
+	AddTrades(ctx context.Context, trades []Trade) error
+	GetTrade(ctx context.Context, id int64) (Trade, error)
+	// GetTrades returns the matching trades, the most recent first
+	GetTrades(ctx context.Context, filter TradeFilter, page int, size int) ([]Trade, error)
+	// GetTradesBetween and DeleteTradesBetween cover the trades executed in
+	// [from, to), they are used to move closed months to cold storage
+	GetTradesBetween(ctx context.Context, from time.Time, to time.Time) ([]Trade, error)
+	DeleteTradesBetween(ctx context.Context, from time.Time, to time.Time) error
+	// OldestTradeTime reports ok=false when there are no trades
+	OldestTradeTime(ctx context.Context) (executedAt time.Time, ok bool, err error)
+}
+
+type tradeRepo struct {
+	queries      *repository.Queries
+	reads        *replica.Router
+	queryTimeout time.Duration
+}
+
+func (repo *tradeRepo) readQueries() (queries *repository.Queries, fromReplica bool) {
+	pool, fromReplica := repo.reads.Reader()
+	if !fromReplica {
+		return repo.queries, false
+	}
+	return repository.New(pool), true
+}
+
+func (repo *tradeRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *tradeRepo) AddTrades(ctx context.Context, trades []Trade) error {
+	rows := make([]repository.InsertTradesParams, len(trades))
+	for idx, t := range trades {
+		rows[idx] = repository.InsertTradesParams{
+			ID:              t.ID,
+			PairID:          t.PairID,
+			Price:           t.Price,
+			Amount:          t.Amount,
+			MakerOrderID:    int64(t.MakerOrderID),
+			TakerOrderID:    int64(t.TakerOrderID),
+			MakerAccountID:  int32(t.MakerAccountID),
+			TakerAccountID:  int32(t.TakerAccountID),
+			TakerSide:       int32(t.TakerSide),
+			MakerFee:        t.MakerFee,
+			TakerFee:        t.TakerFee,
+			ExecutedAt:      pgtype.Timestamp{Time: t.ExecutedAt.UTC(), Valid: true},
+			MakerTag:        t.MakerTag,
+			MakerStrategyID: t.MakerStrategyID,
+			TakerTag:        t.TakerTag,
+			TakerStrategyID: t.TakerStrategyID,
+			MakerRebate:     t.MakerRebate,
+		}
+	}
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	_, err := repo.queries.InsertTrades(ctx, rows)
+	return err
+}
+
+func (repo *tradeRepo) GetTrade(ctx context.Context, id int64) (Trade, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	queries, fromReplica := repo.readQueries()
+	res, err := queries.GetTradeByID(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) && fromReplica {
+		res, err = repo.queries.GetTradeByID(ctx, id)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Trade{}, ErrTradeNotFound
+	}
+	if err != nil {
+		return Trade{}, err
+	}
+	return convertTrade(res), nil
+}
+
+func (repo *tradeRepo) GetTrades(ctx context.Context, filter TradeFilter, page int, size int) ([]Trade, error) {
+	to := filter.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	queries, _ := repo.readQueries()
+	dbres, err := queries.GetTrades(ctx, repository.GetTradesParams{
+		AccountID: int32(filter.AccountID),
+		PairID:    filter.PairID,
+		FromTime:  pgtype.Timestamp{Time: filter.From.UTC(), Valid: true},
+		ToTime:    pgtype.Timestamp{Time: to.UTC(), Valid: true},
+		Limit:     int32(size),
+		Offset:    int32(max(0, page-1) * size),
+	})
+	if err != nil {
+		return nil, err
+	}
+	trades := make([]Trade, len(dbres))
+	for idx, t := range dbres {
+		trades[idx] = convertTrade(t)
+	}
+	return trades, nil
+}
+
+func (repo *tradeRepo) GetTradesBetween(ctx context.Context, from time.Time, to time.Time) ([]Trade, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	dbres, err := repo.queries.GetTradesBetween(ctx, repository.GetTradesBetweenParams{
+		FromTime: pgtype.Timestamp{Time: from.UTC(), Valid: true},
+		ToTime:   pgtype.Timestamp{Time: to.UTC(), Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	trades := make([]Trade, len(dbres))
+	for idx, t := range dbres {
+		trades[idx] = convertTrade(t)
+	}
+	return trades, nil
+}
+
+func (repo *tradeRepo) DeleteTradesBetween(ctx context.Context, from time.Time, to time.Time) error {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	return repo.queries.DeleteTradesBetween(ctx, repository.DeleteTradesBetweenParams{
+		FromTime: pgtype.Timestamp{Time: from.UTC(), Valid: true},
+		ToTime:   pgtype.Timestamp{Time: to.UTC(), Valid: true},
+	})
+}
+
+func (repo *tradeRepo) OldestTradeTime(ctx context.Context) (time.Time, bool, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	executedAt, err := repo.queries.GetOldestTradeTime(ctx)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return executedAt.Time, executedAt.Valid, nil
+}
+
+// NewTradeRepository writes trades to dbpool and reads them from the pool picked by reads
+func NewTradeRepository(dbpool *pgxpool.Pool, reads *replica.Router, queryTimeout time.Duration) TradeRepo {
+	return &tradeRepo{
+		queries:      repository.New(dbpool),
+		reads:        reads,
+		queryTimeout: queryTimeout,
+	}
+}
+
+func convertTrade(t repository.TblTrade) Trade {
+	return Trade{
+		ID:              t.ID,
+		PairID:          t.PairID,
+		Price:           t.Price,
+		Amount:          t.Amount,
+		MakerOrderID:    int(t.MakerOrderID),
+		TakerOrderID:    int(t.TakerOrderID),
+		MakerAccountID:  int(t.MakerAccountID),
+		TakerAccountID:  int(t.TakerAccountID),
+		TakerSide:       OrderType(t.TakerSide),
+		MakerFee:        t.MakerFee,
+		TakerFee:        t.TakerFee,
+		ExecutedAt:      t.ExecutedAt.Time,
+		MakerTag:        t.MakerTag,
+		MakerStrategyID: t.MakerStrategyID,
+		TakerTag:        t.TakerTag,
+		TakerStrategyID: t.TakerStrategyID,
+		MakerRebate:     t.MakerRebate,
+	}
+}
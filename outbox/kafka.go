@@ -0,0 +1,45 @@
+package outbox
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Publisher delivers a batch of events, in order, before returning nil
+type Publisher interface {
+	Publish(ctx context.Context, events []Event) error
+}
+
+type kafkaPublisher struct {
+	client      *kgo.Client
+	topicPrefix string
+}
+
+// NewKafkaPublisher produces every event to topicPrefix followed by its
+// aggregate type, keyed by its aggregate ID so the events of an order,
+// a pair's trades or an account's balances stay in order. The id and type
+// headers match what the Debezium outbox event router sets.
+func NewKafkaPublisher(client *kgo.Client, topicPrefix string) Publisher {
+	return &kafkaPublisher{
+		client:      client,
+		topicPrefix: topicPrefix,
+	}
+}
+
+func (p *kafkaPublisher) Publish(ctx context.Context, events []Event) error {
+	records := make([]*kgo.Record, len(events))
+	for idx, ev := range events {
+		records[idx] = &kgo.Record{
+			Topic: p.topicPrefix + ev.AggregateType,
+			Key:   []byte(ev.AggregateID),
+			Value: ev.Payload,
+			Headers: []kgo.RecordHeader{
+				{Key: "id", Value: []byte(strconv.FormatInt(ev.ID, 10))},
+				{Key: "type", Value: []byte(ev.Type)},
+			},
+		}
+	}
+	return p.client.ProduceSync(ctx, records...).FirstErr()
+}
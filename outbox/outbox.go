@@ -0,0 +1,112 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	repository "order-book/outbox/repository/gen"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Aggregate types of the events the database triggers write
+const (
+	ORDER   = "order"
+	TRADE   = "trade"
+	BALANCE = "balance"
+)
+
+// Event is a row of tbl_outbox. ID increases with every event written.
+type Event struct {
+	ID            int64           `json:"id"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id"`
+	Type          string          `json:"type"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+type OutboxRepo interface {
+	// Relay hands up to limit of the oldest unpublished events to publish and
+	// marks them published once it returns nil. The events stay locked until
+	// then so concurrent relays skip them. It returns the number published.
+	Relay(ctx context.Context, limit int, publish func(ctx context.Context, events []Event) error) (int, error)
+	// Purge deletes the events written before the given time, only the
+	// published ones unless unpublished is set
+	Purge(ctx context.Context, before time.Time, unpublished bool) (int64, error)
+}
+
+type outboxRepo struct {
+	queries      *repository.Queries
+	dbpool       *pgxpool.Pool
+	queryTimeout time.Duration
+}
+
+func (repo *outboxRepo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if repo.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, repo.queryTimeout)
+}
+
+func (repo *outboxRepo) Relay(ctx context.Context, limit int, publish func(ctx context.Context, events []Event) error) (int, error) {
+	tx, err := repo.dbpool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	qtx := repo.queries.WithTx(tx)
+	queryCtx, cancel := repo.withTimeout(ctx)
+	dbres, err := qtx.GetUnpublishedOutboxEvents(queryCtx, int32(limit))
+	cancel()
+	if err != nil || len(dbres) == 0 {
+		return 0, err
+	}
+	events := make([]Event, len(dbres))
+	for idx, ev := range dbres {
+		events[idx] = Event{
+			ID:            ev.ID,
+			AggregateType: ev.Aggregatetype,
+			AggregateID:   ev.Aggregateid,
+			Type:          ev.Type,
+			Payload:       ev.Payload,
+			CreatedAt:     ev.CreatedAt.Time,
+		}
+	}
+
+	if err := publish(ctx, events); err != nil {
+		return 0, err
+	}
+
+	queryCtx, cancel = repo.withTimeout(ctx)
+	defer cancel()
+	for _, ev := range events {
+		if err := qtx.MarkOutboxEventPublished(queryCtx, ev.ID); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(queryCtx); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
+
+func (repo *outboxRepo) Purge(ctx context.Context, before time.Time, unpublished bool) (int64, error) {
+	ctx, cancel := repo.withTimeout(ctx)
+	defer cancel()
+	ts := pgtype.Timestamp{Time: before.UTC(), Valid: true}
+	if unpublished {
+		return repo.queries.PurgeOutboxEvents(ctx, ts)
+	}
+	return repo.queries.PurgePublishedOutboxEvents(ctx, ts)
+}
+
+func NewOutboxRepository(dbpool *pgxpool.Pool, queryTimeout time.Duration) OutboxRepo {
+	return &outboxRepo{
+		queries:      repository.New(dbpool),
+		dbpool:       dbpool,
+		queryTimeout: queryTimeout,
+	}
+}
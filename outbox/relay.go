@@ -0,0 +1,92 @@
+package outbox
+
+import (
+	"context"
+	"order-book/logger"
+	"time"
+)
+
+// purgeInterval is how often events past the retention are deleted
+const purgeInterval = 10 * time.Minute
+
+// Relay polls the outbox and publishes the events in the order they were
+// written. Events are marked published in the transaction that locked them,
+// and the Kafka producer is idempotent, so an event reaches its topic once
+// unless the relay dies between the produce and the commit. Consumers drop
+// that rare duplicate by the id header.
+//
+// Without a publisher the relay only purges, the events being streamed by
+// Debezium reading the WAL instead.
+type Relay struct {
+	repo      OutboxRepo
+	publisher Publisher
+	batchSize int
+	interval  time.Duration
+	retention time.Duration
+}
+
+func NewRelay(repo OutboxRepo, publisher Publisher, batchSize int, interval time.Duration, retention time.Duration) *Relay {
+	return &Relay{
+		repo:      repo,
+		publisher: publisher,
+		batchSize: batchSize,
+		interval:  interval,
+		retention: retention,
+	}
+}
+
+// Run relays the events until ctx is done
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	var lastPurge time.Time
+	for {
+		if time.Since(lastPurge) >= purgeInterval {
+			r.purge(ctx)
+			lastPurge = time.Now()
+		}
+		// A full batch means more events are waiting
+		for r.publisher != nil && r.relay(ctx) == r.batchSize {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Relay) relay(ctx context.Context) int {
+	n, err := r.repo.Relay(ctx, r.batchSize, r.publisher.Publish)
+	if err != nil {
+		logger.Error("failed to relay outbox events", map[string]any{
+			"error": err,
+		})
+		return 0
+	}
+	if n > 0 {
+		logger.Debug("outbox events relayed", map[string]any{
+			"count": n,
+		})
+	}
+	return n
+}
+
+func (r *Relay) purge(ctx context.Context) {
+	if r.retention <= 0 {
+		return
+	}
+	// Debezium already read every event committed before the retention
+	deleted, err := r.repo.Purge(ctx, time.Now().Add(-r.retention), r.publisher == nil)
+	if err != nil {
+		logger.Error("failed to purge outbox events", map[string]any{
+			"error": err,
+		})
+		return
+	}
+	if deleted > 0 {
+		logger.Info("outbox events purged", map[string]any{
+			"count": deleted,
+		})
+	}
+}
@@ -0,0 +1,17 @@
+-- name: GetUnpublishedOutboxEvents :many
+SELECT * FROM tbl_outbox WHERE published_at IS NULL ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED;
+
+-- name: MarkOutboxEventPublished :exec
+UPDATE tbl_outbox SET published_at = NOW() WHERE id = $1;
+
+-- name: PurgePublishedOutboxEvents :one
+WITH deleted AS (
+    DELETE FROM tbl_outbox WHERE published_at IS NOT NULL AND created_at < @before RETURNING 1
+)
+SELECT COUNT(*) AS deleted FROM deleted;
+
+-- name: PurgeOutboxEvents :one
+WITH deleted AS (
+    DELETE FROM tbl_outbox WHERE created_at < @before RETURNING 1
+)
+SELECT COUNT(*) AS deleted FROM deleted;
@@ -0,0 +1,10 @@
+CREATE TABLE tbl_outbox
+(
+    id BIGSERIAL PRIMARY KEY,
+    aggregatetype VARCHAR(64) NOT NULL,
+    aggregateid VARCHAR(64) NOT NULL,
+    type VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    published_at TIMESTAMP
+);
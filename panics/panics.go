@@ -0,0 +1,82 @@
+package panics
+
+import (
+	"fmt"
+	"order-book/logger"
+	"order-book/metrics"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Report is a panic recovered in a component of the engine, like http, ws or
+// engine/<pair>, along with the stack it was raised from
+type Report struct {
+	Component string         `json:"component"`
+	Panic     any            `json:"panic"`
+	Stack     string         `json:"stack"`
+	Fields    map[string]any `json:"fields"`
+	At        time.Time      `json:"at"`
+}
+
+var (
+	mu        sync.RWMutex
+	reporters []func(r Report)
+)
+
+// OnPanic registers fn to be told about every panic captured, as error
+// reporting services are. fn runs on the goroutine that panicked, before it
+// goes on.
+func OnPanic(fn func(r Report)) {
+	mu.Lock()
+	defer mu.Unlock()
+	reporters = append(reporters, fn)
+}
+
+// Capture logs and reports recovered, the value of a recovered panic. It's
+// meant to be called from the deferred function that recovered it.
+func Capture(component string, recovered any, fields map[string]any) {
+	metrics.Panics.WithLabelValues(component).Inc()
+	logFields := map[string]any{"component": component}
+	for k, v := range fields {
+		logFields[k] = v
+	}
+	logger.LogPanic("recovered a panic", recovered, logFields)
+
+	mu.RLock()
+	fns := reporters
+	mu.RUnlock()
+	r := Report{
+		Component: component,
+		Panic:     recovered,
+		Stack:     string(debug.Stack()),
+		Fields:    fields,
+		At:        time.Now(),
+	}
+	for _, fn := range fns {
+		report(fn, r)
+	}
+}
+
+// report runs a reporter, a reporter panicking itself is only logged
+func report(fn func(r Report), r Report) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logger.Error("panic reporter panicked", map[string]any{
+				"component": r.Component,
+				"panic":     fmt.Sprint(recovered),
+			})
+		}
+	}()
+	fn(r)
+}
+
+// Recover captures the panic of the goroutine it's deferred in, which then
+// returns normally:
+//
+//	defer panics.Recover("ws", nil)
+func Recover(component string, fields map[string]any) {
+	if recovered := recover(); recovered != nil {
+		Capture(component, recovered, fields)
+	}
+}
@@ -0,0 +1,37 @@
+package panics
+
+import (
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// SentryReporter sets up the Sentry client and returns a reporter sending the
+// panics to the project of dsn. The component is sent as a tag, the fields as
+// extra data.
+func SentryReporter(dsn string, environment string, release string) (func(r Report), error) {
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:         dsn,
+		Environment: environment,
+		Release:     release,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func(r Report) {
+		hub := sentry.CurrentHub().Clone()
+		hub.ConfigureScope(func(scope *sentry.Scope) {
+			scope.SetLevel(sentry.LevelFatal)
+			scope.SetTag("component", r.Component)
+			scope.SetExtras(r.Fields)
+		})
+		// The stack is taken from the goroutine that panicked, the reporter
+		// runs on it
+		hub.Recover(r.Panic)
+	}, nil
+}
+
+// FlushSentry waits up to timeout for the reports to be sent, before exiting
+func FlushSentry(timeout time.Duration) {
+	sentry.Flush(timeout)
+}
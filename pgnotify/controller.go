@@ -0,0 +1,95 @@
+package pgnotify
+
+import (
+	"order-book/logger"
+	"order-book/metrics"
+	"order-book/panics"
+	"order-book/shutdown"
+	"order-book/wslimit"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// wsLog follows the level of the ws component
+var wsLog = logger.Component("ws")
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindOrderUpdatesRouter streams the order updates of an account. Updates
+// come through the database, so any replica serves the orders of every node.
+func BindOrderUpdatesRouter(r fiber.Router, listener *Listener) {
+	r.Get("/ws/accounts/:id/orders", websocket.New(func(c *websocket.Conn) {
+		defer panics.Recover("ws", map[string]any{"stream": "order_updates"})
+		defer shutdown.TrackWebsocket(c)()
+		defer wslimit.Release(c)
+		defer c.Close()
+		stream := metrics.Stream("order_updates")
+		stream.Connected()
+		reason := metrics.DisconnectClientClosed
+		defer func() { stream.Disconnected(reason) }()
+
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.WriteJSON(&Response{
+				Message: "Invalid account ID",
+			})
+			reason = metrics.DisconnectInvalidRequest
+			return
+		}
+		updates := listener.Subscribe(accountId)
+		defer listener.Unsubscribe(accountId, updates)
+		stream.Subscribed()
+		defer stream.Unsubscribed()
+
+		// The stream is read-only, reading only detects the client going away
+		closed := make(chan struct{})
+		go func() {
+			defer panics.Recover("ws", map[string]any{"stream": "order_updates"})
+			defer close(closed)
+			for {
+				if _, _, err := c.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		ticker := time.NewTicker(time.Second * 30)
+		defer ticker.Stop()
+		for {
+			select {
+			case u, ok := <-updates:
+				if !ok {
+					c.WriteJSON(&Response{
+						Message: "Client fell behind, reconnect and reload the orders",
+					})
+					reason = metrics.DisconnectSlowConsumer
+					return
+				}
+				if err := c.WriteJSON(u); err != nil {
+					wsLog.Error("Error while sending order update", map[string]any{
+						"err":        err,
+						"account_id": accountId,
+					})
+					reason = metrics.DisconnectWriteError
+					return
+				}
+				stream.Sent()
+			case <-ticker.C:
+				err := c.WriteControl(websocket.PingMessage, []byte("Ping message"), time.Now().Add(5*time.Second))
+				if err != nil {
+					reason = metrics.DisconnectPingFailed
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	}))
+}
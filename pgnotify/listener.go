@@ -0,0 +1,140 @@
+package pgnotify
+
+import (
+	"context"
+	"encoding/json"
+	"order-book/logger"
+	"order-book/metrics"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Listener receives the order updates published by every engine node on a
+// dedicated connection and fans them out to the subscribed accounts. The
+// connection is reopened and the channel listened to again after a failure.
+type Listener struct {
+	connConfig  *pgx.ConnConfig
+	channel     string
+	mu          sync.Mutex
+	subscribers map[int]map[chan OrderUpdate]struct{}
+}
+
+func NewListener(connConfig *pgx.ConnConfig, channel string) *Listener {
+	return &Listener{
+		connConfig:  connConfig,
+		channel:     channel,
+		subscribers: make(map[int]map[chan OrderUpdate]struct{}),
+	}
+}
+
+// Run listens until ctx is done
+func (l *Listener) Run(ctx context.Context) {
+	delay := minReconnectDelay
+	for {
+		err := l.listen(ctx, func() { delay = minReconnectDelay })
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Warn("order update listener disconnected, reconnecting", map[string]any{
+			"channel": l.channel,
+			"delay":   delay.String(),
+			"error":   err,
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxReconnectDelay)
+	}
+}
+
+// listen holds one connection until it fails, subscribed is called once the
+// channel is listened to
+func (l *Listener) listen(ctx context.Context, subscribed func()) error {
+	conn, err := pgx.ConnectConfig(ctx, l.connConfig)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{l.channel}.Sanitize()); err != nil {
+		return err
+	}
+	subscribed()
+	logger.Info("listening for order updates", map[string]any{
+		"channel": l.channel,
+	})
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var u OrderUpdate
+		if err := json.Unmarshal([]byte(n.Payload), &u); err != nil {
+			logger.Error("failed to decode order update", map[string]any{
+				"payload": n.Payload,
+				"error":   err,
+			})
+			continue
+		}
+		l.publish(u)
+	}
+}
+
+// publish disconnects subscribers that can't keep up instead of holding the
+// connection back
+func (l *Listener) publish(u OrderUpdate) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ch := range l.subscribers[u.AccountID] {
+		select {
+		case ch <- u:
+		default:
+			logger.Warn("order update subscriber is too slow, disconnecting", map[string]any{
+				"account_id": u.AccountID,
+			})
+			metrics.Stream("order_updates").Overflowed()
+			l.remove(u.AccountID, ch)
+		}
+	}
+}
+
+// Subscribe returns a channel of the updates of an account's orders, it is
+// closed if the subscriber falls behind
+func (l *Listener) Subscribe(accountId int) chan OrderUpdate {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ch := make(chan OrderUpdate, 256)
+	if l.subscribers[accountId] == nil {
+		l.subscribers[accountId] = make(map[chan OrderUpdate]struct{})
+	}
+	l.subscribers[accountId][ch] = struct{}{}
+	return ch
+}
+
+func (l *Listener) Unsubscribe(accountId int, ch chan OrderUpdate) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.remove(accountId, ch)
+}
+
+// remove closes a subscription, l.mu must be held
+func (l *Listener) remove(accountId int, ch chan OrderUpdate) {
+	if _, ok := l.subscribers[accountId][ch]; !ok {
+		return
+	}
+	delete(l.subscribers[accountId], ch)
+	if len(l.subscribers[accountId]) == 0 {
+		delete(l.subscribers, accountId)
+	}
+	close(ch)
+}
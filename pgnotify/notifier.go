@@ -0,0 +1,169 @@
+package pgnotify
+
+import (
+	"context"
+	"encoding/json"
+	"order-book/book"
+	"order-book/liquidation"
+	"order-book/logger"
+	"order-book/order"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+)
+
+type Status string
+
+const (
+	NEW      Status = "NEW"
+	CANCELED Status = "CANCELED"
+	FILL     Status = "FILL"
+	// LIQUIDATION is a liquidation order of the account, Price its limit and
+	// FillPrice and FillAmount what it filled
+	LIQUIDATION Status = "LIQUIDATION"
+)
+
+// OrderUpdate is the payload of a notification. A trade notifies each side
+// once with the fill in TradeID, FillPrice and FillAmount, and the tag and
+// strategy ID of its order.
+type OrderUpdate struct {
+	TenantID   string          `json:"tenant_id"`
+	Status     Status          `json:"status"`
+	OrderID    int             `json:"order_id"`
+	AccountID  int             `json:"account_id"`
+	PairID     string          `json:"pair_id"`
+	Side       string          `json:"side"`
+	Price      decimal.Decimal `json:"price,omitzero"`
+	Amount     decimal.Decimal `json:"amount,omitzero"`
+	TradeID    int64           `json:"trade_id,omitempty"`
+	FillPrice  decimal.Decimal `json:"fill_price,omitzero"`
+	FillAmount decimal.Decimal `json:"fill_amount,omitzero"`
+	Tag        string          `json:"tag,omitempty"`
+	StrategyID string          `json:"strategy_id,omitempty"`
+	At         time.Time       `json:"at"`
+}
+
+// Notifier publishes the order updates of the engine with NOTIFY. Updates
+// are queued so the engine never waits on the database, they are dropped
+// once bufferSize updates are waiting.
+type Notifier struct {
+	dbpool  *pgxpool.Pool
+	channel string
+	queue   chan OrderUpdate
+}
+
+func NewNotifier(dbpool *pgxpool.Pool, channel string, bufferSize int) *Notifier {
+	return &Notifier{
+		dbpool:  dbpool,
+		channel: channel,
+		queue:   make(chan OrderUpdate, bufferSize),
+	}
+}
+
+// Attach publishes the updates of the book of a tenant, it is meant to be
+// run from a Registry.OnBook hook
+func (n *Notifier) Attach(tenantId string, b book.Book) {
+	b.OnOrderEvent(func(ev order.OrderEvent) {
+		var status Status
+		switch ev.Name {
+		case order.ORDER_CREATED:
+			status = NEW
+		case order.ORDER_CANCELLED:
+			status = CANCELED
+		default:
+			return
+		}
+		o := ev.Order
+		n.enqueue(OrderUpdate{
+			TenantID:   tenantId,
+			Status:     status,
+			OrderID:    o.ID,
+			AccountID:  o.AccountID,
+			PairID:     o.PairID,
+			Side:       o.Type.String(),
+			Price:      o.Price,
+			Amount:     o.Amount,
+			Tag:        o.Tag,
+			StrategyID: o.StrategyID,
+			At:         ev.At,
+		})
+	})
+	b.OnTrade(func(t order.Trade) {
+		makerSide := order.ASK
+		if t.TakerSide == order.ASK {
+			makerSide = order.BID
+		}
+		fill := OrderUpdate{
+			TenantID:   tenantId,
+			Status:     FILL,
+			PairID:     t.PairID,
+			TradeID:    t.ID,
+			FillPrice:  t.Price,
+			FillAmount: t.Amount,
+			At:         t.ExecutedAt,
+		}
+		maker, taker := fill, fill
+		maker.OrderID, maker.AccountID, maker.Side = t.MakerOrderID, t.MakerAccountID, makerSide.String()
+		maker.Tag, maker.StrategyID = t.MakerTag, t.MakerStrategyID
+		taker.OrderID, taker.AccountID, taker.Side = t.TakerOrderID, t.TakerAccountID, t.TakerSide.String()
+		taker.Tag, taker.StrategyID = t.TakerTag, t.TakerStrategyID
+		n.enqueue(maker)
+		n.enqueue(taker)
+	})
+}
+
+// Liquidation publishes a liquidation step on the stream of its account, it
+// is meant to be registered with liquidation.Engine.OnLiquidation
+func (n *Notifier) Liquidation(s liquidation.Step) {
+	n.enqueue(OrderUpdate{
+		Status:     LIQUIDATION,
+		OrderID:    s.OrderID,
+		AccountID:  s.AccountID,
+		PairID:     s.PairID,
+		Side:       s.Type.String(),
+		Price:      s.Price,
+		Amount:     s.Amount,
+		FillPrice:  s.AvgPrice,
+		FillAmount: s.Filled,
+		StrategyID: s.StrategyID,
+		At:         s.At,
+	})
+}
+
+func (n *Notifier) enqueue(u OrderUpdate) {
+	select {
+	case n.queue <- u:
+	default:
+		logger.Warn("order update queue is full, dropping the notification", map[string]any{
+			"order_id": u.OrderID,
+			"status":   u.Status,
+		})
+	}
+}
+
+// Run sends the queued updates until ctx is done
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case u := <-n.queue:
+			payload, err := json.Marshal(u)
+			if err != nil {
+				logger.Error("failed to encode order update", map[string]any{
+					"order_id": u.OrderID,
+					"error":    err,
+				})
+				continue
+			}
+			if _, err := n.dbpool.Exec(ctx, "SELECT pg_notify($1, $2)", n.channel, string(payload)); err != nil {
+				logger.Error("failed to notify order update", map[string]any{
+					"order_id": u.OrderID,
+					"status":   u.Status,
+					"error":    err,
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package portfolio
+
+import (
+	"net/http"
+	"order-book/logger"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+func BindPortfolioRouter(r fiber.Router, service *Service) {
+	r.Get("/accounts/:id/portfolio", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		quote := c.Query("quote", "usdt")
+
+		p, err := service.GetPortfolio(accountId, quote)
+		if err != nil {
+			logger.Error("failed to build portfolio", map[string]any{
+				"account_id": accountId,
+				"quote":      quote,
+				"error":      err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    p,
+		})
+	})
+}
@@ -0,0 +1,129 @@
+package portfolio
+
+import (
+	"order-book/account"
+	"order-book/order"
+	"order-book/position"
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Ticker prices are looked up per account since each tenant trades on its own books
+type Ticker interface {
+	AccountLastPrice(accountId int, pairId string) (price decimal.Decimal, ok bool)
+	GetAccountOrders(accountId int) []order.Order
+}
+
+type AssetValue struct {
+	Asset     string          `json:"asset"`
+	Available decimal.Decimal `json:"available"`
+	Held      decimal.Decimal `json:"held"`
+	Value     decimal.Decimal `json:"value"`
+}
+
+type Portfolio struct {
+	AccountID          int                 `json:"account_id"`
+	Quote              string              `json:"quote"`
+	Balances           []AssetValue        `json:"balances"`
+	Positions          []position.Position `json:"positions"`
+	OpenOrdersCount    int                 `json:"open_orders_count"`
+	OpenOrdersNotional decimal.Decimal     `json:"open_orders_notional"`
+	HoldsValue         decimal.Decimal     `json:"holds_value"`
+	UnrealizedPnL      decimal.Decimal     `json:"unrealized_pnl"`
+	TotalValue         decimal.Decimal     `json:"total_value"`
+	// UnpricedAssets lists assets that had no ticker path to the quote and are left out of the totals
+	UnpricedAssets []string `json:"unpriced_assets,omitempty"`
+}
+
+type Service struct {
+	balances  account.BalanceRepo
+	ticker    Ticker
+	positions *position.Tracker
+}
+
+func NewService(balances account.BalanceRepo, ticker Ticker, positions *position.Tracker) *Service {
+	return &Service{
+		balances:  balances,
+		ticker:    ticker,
+		positions: positions,
+	}
+}
+
+// GetPortfolio values an account in the given quote asset. The total value is
+// the balances (available and held) plus the unrealized PnL of open positions.
+func (s *Service) GetPortfolio(accountId int, quote string) (Portfolio, error) {
+	quote = strings.ToLower(quote)
+	p := Portfolio{AccountID: accountId, Quote: quote}
+	unpriced := make(map[string]bool)
+
+	balances, err := s.balances.GetBalances(accountId)
+	if err != nil {
+		return p, err
+	}
+	for _, b := range balances {
+		asset := strings.ToLower(b.Asset)
+		entry := AssetValue{Asset: asset, Available: b.Available, Held: b.Held}
+		rate, ok := s.rate(accountId, asset, quote)
+		if !ok {
+			unpriced[asset] = true
+		} else {
+			entry.Value = b.Available.Add(b.Held).Mul(rate)
+			p.HoldsValue = p.HoldsValue.Add(b.Held.Mul(rate))
+			p.TotalValue = p.TotalValue.Add(entry.Value)
+		}
+		p.Balances = append(p.Balances, entry)
+	}
+
+	for _, o := range s.ticker.GetAccountOrders(accountId) {
+		p.OpenOrdersCount++
+		_, pairQuote, ok := order.SplitPairID(o.PairID)
+		if !ok {
+			unpriced[o.PairID] = true
+			continue
+		}
+		rate, ok := s.rate(accountId, pairQuote, quote)
+		if !ok {
+			unpriced[pairQuote] = true
+			continue
+		}
+		p.OpenOrdersNotional = p.OpenOrdersNotional.Add(o.Price.Mul(o.Amount).Mul(rate))
+	}
+
+	p.Positions = s.positions.GetPositions(accountId)
+	for _, pos := range p.Positions {
+		_, pairQuote, ok := order.SplitPairID(pos.PairID)
+		if !ok {
+			unpriced[pos.PairID] = true
+			continue
+		}
+		rate, ok := s.rate(accountId, pairQuote, quote)
+		if !ok {
+			unpriced[pairQuote] = true
+			continue
+		}
+		p.UnrealizedPnL = p.UnrealizedPnL.Add(pos.UnrealizedPnL.Mul(rate))
+	}
+	p.TotalValue = p.TotalValue.Add(p.UnrealizedPnL)
+
+	for asset := range unpriced {
+		p.UnpricedAssets = append(p.UnpricedAssets, asset)
+	}
+	sort.Strings(p.UnpricedAssets)
+	return p, nil
+}
+
+// rate converts one unit of asset into quote using the direct or inverse pair last price
+func (s *Service) rate(accountId int, asset string, quote string) (decimal.Decimal, bool) {
+	if asset == quote {
+		return decimal.NewFromInt(1), true
+	}
+	if price, ok := s.ticker.AccountLastPrice(accountId, asset+quote); ok && price.IsPositive() {
+		return price, true
+	}
+	if price, ok := s.ticker.AccountLastPrice(accountId, quote+asset); ok && price.IsPositive() {
+		return decimal.NewFromInt(1).Div(price), true
+	}
+	return decimal.Zero, false
+}
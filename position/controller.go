@@ -0,0 +1,36 @@
+package position
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+func BindPositionRouter(r fiber.Router, tracker *Tracker) {
+	r.Get("/accounts/:id/positions", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data: map[string]any{
+				"positions": tracker.GetPositions(accountId),
+				"fills":     tracker.GetFills(accountId),
+			},
+		})
+	})
+}
@@ -0,0 +1,220 @@
+package position
+
+import (
+	"context"
+	"order-book/order"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// maxFillsPerAccount bounds the per-account fill history kept in memory
+const maxFillsPerAccount = 100
+
+// MarkPriceSource provides the price open positions are valued against, looked
+// up on the book the account trades on
+type MarkPriceSource interface {
+	AccountLastPrice(accountId int, pairId string) (price decimal.Decimal, ok bool)
+}
+
+// Trades are the trades kept in the database, as the positions are rebuilt
+// from them
+type Trades interface {
+	GetTradesBetween(ctx context.Context, from time.Time, to time.Time) ([]order.Trade, error)
+	OldestTradeTime(ctx context.Context) (executedAt time.Time, ok bool, err error)
+}
+
+// Position is the net exposure of an account on a pair. Quantity is signed,
+// positive for long (net bought) and negative for short (net sold).
+type Position struct {
+	AccountID         int             `json:"account_id"`
+	PairID            string          `json:"pair_id"`
+	Quantity          decimal.Decimal `json:"quantity"`
+	AverageEntryPrice decimal.Decimal `json:"average_entry_price"`
+	RealizedPnL       decimal.Decimal `json:"realized_pnl"`
+	MarkPrice         decimal.Decimal `json:"mark_price"`
+	UnrealizedPnL     decimal.Decimal `json:"unrealized_pnl"`
+}
+
+// Fill is one side of a trade from the account's point of view
+type Fill struct {
+	PairID      string          `json:"pair_id"`
+	OrderID     int             `json:"order_id"`
+	Side        order.OrderType `json:"side"`
+	Price       decimal.Decimal `json:"price"`
+	Amount      decimal.Decimal `json:"amount"`
+	RealizedPnL decimal.Decimal `json:"realized_pnl"`
+	ExecutedAt  time.Time       `json:"executed_at"`
+}
+
+type Tracker struct {
+	mu        sync.RWMutex
+	positions map[int]map[string]*Position
+	fills     map[int][]Fill
+	marks     MarkPriceSource
+}
+
+func NewTracker(marks MarkPriceSource) *Tracker {
+	return &Tracker{
+		positions: make(map[int]map[string]*Position),
+		fills:     make(map[int][]Fill),
+		marks:     marks,
+	}
+}
+
+// Rebuild replaces the positions and fills with those of the trades kept in
+// the database, read a month at a time. The trades the archiver moved to
+// cold storage aren't replayed. It returns the number of trades applied.
+func (t *Tracker) Rebuild(ctx context.Context, trades Trades) (int, error) {
+	rebuilt := NewTracker(t.marks)
+	oldest, ok, err := trades.OldestTradeTime(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	if ok {
+		now := time.Now().UTC()
+		oldest = oldest.UTC()
+		for from := time.Date(oldest.Year(), oldest.Month(), 1, 0, 0, 0, 0, time.UTC); !from.After(now); from = from.AddDate(0, 1, 0) {
+			month, err := trades.GetTradesBetween(ctx, from, from.AddDate(0, 1, 0))
+			if err != nil {
+				return 0, err
+			}
+			for _, tr := range month {
+				rebuilt.OnTrade(tr)
+			}
+			count += len(month)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.positions = rebuilt.positions
+	t.fills = rebuilt.fills
+	return count, nil
+}
+
+// OnTrade applies both sides of a trade. A BID is a buy and an ASK is a sell.
+func (t *Tracker) OnTrade(tr order.Trade) {
+	makerSide := order.BID
+	if tr.TakerSide == order.BID {
+		makerSide = order.ASK
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.apply(tr.TakerAccountID, tr.TakerOrderID, tr.TakerSide, tr)
+	t.apply(tr.MakerAccountID, tr.MakerOrderID, makerSide, tr)
+}
+
+func (t *Tracker) apply(accountId int, orderId int, side order.OrderType, tr order.Trade) {
+	accountPositions := t.positions[accountId]
+	if accountPositions == nil {
+		accountPositions = make(map[string]*Position)
+		t.positions[accountId] = accountPositions
+	}
+	p := accountPositions[tr.PairID]
+	if p == nil {
+		p = &Position{AccountID: accountId, PairID: tr.PairID}
+		accountPositions[tr.PairID] = p
+	}
+
+	// The quantity follows the fills exactly, so a position closed by the
+	// opposite fills is zero
+	price := tr.Price
+	delta := tr.Amount
+	if side == order.ASK {
+		delta = delta.Neg()
+	}
+
+	var realized decimal.Decimal
+	if p.Quantity.IsZero() || p.Quantity.Sign() == delta.Sign() {
+		// Opening or increasing, the entry price becomes the volume weighted average
+		size := p.Quantity.Abs().Add(delta.Abs())
+		p.AverageEntryPrice = p.Quantity.Abs().Mul(p.AverageEntryPrice).Add(delta.Abs().Mul(price)).Div(size)
+		p.Quantity = p.Quantity.Add(delta)
+	} else {
+		// Reducing, closing or flipping the position
+		closed := decimal.Min(delta.Abs(), p.Quantity.Abs())
+		realized = closed.Mul(price.Sub(p.AverageEntryPrice))
+		if p.Quantity.IsNegative() {
+			realized = realized.Neg()
+		}
+		p.RealizedPnL = p.RealizedPnL.Add(realized)
+		flipped := delta.Abs().GreaterThan(p.Quantity.Abs())
+		p.Quantity = p.Quantity.Add(delta)
+		if flipped {
+			p.AverageEntryPrice = price
+		}
+		if p.Quantity.IsZero() {
+			p.AverageEntryPrice = decimal.Zero
+		}
+	}
+
+	fills := append(t.fills[accountId], Fill{
+		PairID:      tr.PairID,
+		OrderID:     orderId,
+		Side:        side,
+		Price:       tr.Price,
+		Amount:      tr.Amount,
+		RealizedPnL: realized,
+		ExecutedAt:  tr.ExecutedAt,
+	})
+	if len(fills) > maxFillsPerAccount {
+		fills = fills[len(fills)-maxFillsPerAccount:]
+	}
+	t.fills[accountId] = fills
+}
+
+// GetPositions returns the account positions valued at the current mark prices
+func (t *Tracker) GetPositions(accountId int) []Position {
+	t.mu.RLock()
+	positions := make([]Position, 0, len(t.positions[accountId]))
+	for _, p := range t.positions[accountId] {
+		positions = append(positions, *p)
+	}
+	t.mu.RUnlock()
+
+	for idx := range positions {
+		p := &positions[idx]
+		p.MarkPrice = p.AverageEntryPrice
+		if markPrice, ok := t.marks.AccountLastPrice(accountId, p.PairID); ok {
+			p.MarkPrice = markPrice
+		}
+		p.UnrealizedPnL = p.Quantity.Mul(p.MarkPrice.Sub(p.AverageEntryPrice))
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].PairID < positions[j].PairID
+	})
+	return positions
+}
+
+// PairPositions returns the open positions on a pair by account, without
+// their marks
+func (t *Tracker) PairPositions(pairId string) []Position {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var positions []Position
+	for _, accountPositions := range t.positions {
+		if p, ok := accountPositions[pairId]; ok && !p.Quantity.IsZero() {
+			positions = append(positions, *p)
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].AccountID < positions[j].AccountID
+	})
+	return positions
+}
+
+// GetFills returns the most recent fills of an account, newest first
+func (t *Tracker) GetFills(accountId int) []Fill {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	fills := make([]Fill, len(t.fills[accountId]))
+	for idx, f := range t.fills[accountId] {
+		fills[len(fills)-1-idx] = f
+	}
+	return fills
+}
@@ -0,0 +1,34 @@
+package rebate
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindRebateRouter serves the maker rebates an account was paid on GET
+// /accounts/:id/rebates
+func BindRebateRouter(r fiber.Router, e *Engine) {
+	r.Get("/accounts/:id/rebates", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    e.Rebates(accountId),
+		})
+	})
+}
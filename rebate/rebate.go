@@ -0,0 +1,216 @@
+// Package rebate pays the makers on a negative maker fee rate. The rebate of
+// a fill is recorded on the trade apart from its fees and credited to the
+// maker in the quote asset of the pair, out of the fees account, when the
+// fill settles.
+package rebate
+
+import (
+	"context"
+	"fmt"
+	"order-book/account"
+	"order-book/ledgerqueue"
+	"order-book/logger"
+	"order-book/netting"
+	"order-book/order"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// maxHistory bounds the rebates kept per account
+const maxHistory = 100
+
+var rebateLog = logger.Component("rebate")
+
+// Rebate is what the maker of a fill was paid, in the quote asset of the
+// pair. A rebate with an Error failed to be credited and is retried, a
+// Netted one is credited with the others of its settlement window.
+type Rebate struct {
+	TradeID   int64           `json:"trade_id"`
+	PairID    string          `json:"pair_id"`
+	AccountID int             `json:"account_id"`
+	Asset     string          `json:"asset"`
+	Amount    decimal.Decimal `json:"amount"`
+	At        time.Time       `json:"at"`
+	Netted    bool            `json:"netted,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// Engine credits the rebates through the ledger queue, which keeps them
+// until they're posted. Those waiting for the queue are held in memory
+// however many there are.
+type Engine struct {
+	ledger  *ledgerqueue.Queue
+	netting *netting.Engine
+	wake    chan struct{}
+
+	mu      sync.Mutex
+	queued  []Rebate
+	history map[int][]Rebate
+	// flushing serializes the flushes of Run and Drain
+	flushing sync.Mutex
+}
+
+func NewEngine(ledger *ledgerqueue.Queue, nets *netting.Engine) *Engine {
+	e := &Engine{
+		ledger:  ledger,
+		netting: nets,
+		wake:    make(chan struct{}, 1),
+		history: make(map[int][]Rebate),
+	}
+	ledger.OnResult(e.onResult)
+	return e
+}
+
+// OnTrade queues the rebate of a fill. It's called from the engine
+// goroutine, the rebates are credited by Run.
+func (e *Engine) OnTrade(t order.Trade) {
+	// The ledger keeps LedgerScale decimals, the venue pays no more than the
+	// rebate
+	amount := t.MakerRebate.Truncate(account.LedgerScale)
+	if !amount.IsPositive() {
+		return
+	}
+	_, quote, _ := order.SplitPairID(t.PairID)
+	e.mu.Lock()
+	e.queued = append(e.queued, Rebate{
+		TradeID:   t.ID,
+		PairID:    t.PairID,
+		AccountID: t.MakerAccountID,
+		Asset:     quote,
+		Amount:    amount,
+		At:        t.ExecutedAt,
+	})
+	e.mu.Unlock()
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run hands the rebates to the ledger queue until ctx is done. Each rebate
+// is a ledger transaction from the fees account, keyed by the trade so a
+// retry never pays twice. Those of the accounts in netting mode are left to
+// the netting of their window.
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.wake:
+		case <-ticker.C:
+		}
+		e.flush()
+	}
+}
+
+// Drain hands the rebates still held to the ledger queue. It's called on
+// shutdown once the books are drained.
+func (e *Engine) Drain() {
+	if left := e.flush(); left > 0 {
+		rebateLog.Error("rebates were left uncredited at shutdown", map[string]any{
+			"rebates": left,
+		})
+	}
+}
+
+// flush hands the held rebates over in order until the queue refuses one,
+// which is tried again on the next flush. It returns how many are held.
+func (e *Engine) flush() int {
+	e.flushing.Lock()
+	defer e.flushing.Unlock()
+	for {
+		e.mu.Lock()
+		if len(e.queued) == 0 {
+			e.mu.Unlock()
+			return 0
+		}
+		r := e.queued[0]
+		e.mu.Unlock()
+
+		r.Netted = e.netting.Defer(r.AccountID, netting.Obligation{
+			TradeID: r.TradeID,
+			PairID:  r.PairID,
+			Type:    account.REBATE,
+			Asset:   r.Asset,
+			Amount:  r.Amount,
+			At:      r.At,
+		})
+		if !r.Netted {
+			err := e.ledger.Enqueue(context.Background(), account.Transaction{
+				Type:           account.REBATE,
+				IdempotencyKey: key(r.TradeID),
+				Source:         account.ENGINE,
+				Reference:      fmt.Sprintf("maker rebate of trade %d on %s", r.TradeID, r.PairID),
+				Postings: []account.Posting{
+					{AccountID: r.AccountID, Asset: r.Asset, Amount: r.Amount},
+					{SystemAccount: account.FEES, Asset: r.Asset, Amount: r.Amount.Neg()},
+				},
+			}, nil)
+			if err != nil {
+				rebateLog.Error("failed to queue a rebate, it's retried", map[string]any{
+					"trade_id":   r.TradeID,
+					"account_id": r.AccountID,
+					"asset":      r.Asset,
+					"amount":     r.Amount,
+					"error":      err,
+				})
+				e.mu.Lock()
+				defer e.mu.Unlock()
+				return len(e.queued)
+			}
+		}
+		e.mu.Lock()
+		e.queued = e.queued[1:]
+		e.mu.Unlock()
+		e.record(r)
+	}
+}
+
+func key(tradeId int64) string {
+	return fmt.Sprintf("rebate:%d", tradeId)
+}
+
+// onResult keeps the outcome of an attempt to credit a rebate on it
+func (e *Engine) onResult(res ledgerqueue.Result) {
+	if res.Transaction.Type != account.REBATE || len(res.Transaction.Postings) == 0 {
+		return
+	}
+	accountId := res.Transaction.Postings[0].AccountID
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for idx, r := range e.history[accountId] {
+		if key(r.TradeID) == res.Transaction.IdempotencyKey {
+			e.history[accountId][idx].Error = ""
+			if res.Err != nil {
+				e.history[accountId][idx].Error = res.Err.Error()
+			}
+		}
+	}
+}
+
+func (e *Engine) record(r Rebate) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	history := append(e.history[r.AccountID], r)
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	e.history[r.AccountID] = history
+}
+
+// Rebates are the last rebates an account was paid, the latest first
+func (e *Engine) Rebates(accountId int) []Rebate {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rebates := slices.Clone(e.history[accountId])
+	slices.Reverse(rebates)
+	if rebates == nil {
+		rebates = []Rebate{}
+	}
+	return rebates
+}
@@ -0,0 +1,316 @@
+package reload
+
+import (
+	"order-book/book"
+	"order-book/config"
+	"order-book/dmm"
+	"order-book/fee"
+	"order-book/feeasset"
+	"order-book/flags"
+	"order-book/logger"
+	"order-book/margin"
+	"order-book/order"
+	"order-book/session"
+	"order-book/stp"
+	"order-book/wslimit"
+	"slices"
+)
+
+// LogLevels applies log.level and log.components. A component left out of
+// the file follows the global level again.
+func LogLevels() Applier {
+	return func(prev config.Config, next config.Config) []Change {
+		var changes []Change
+		if next.Log.Level != prev.Log.Level {
+			lvl, _ := logger.ParseLevel(next.Log.Level)
+			logger.SetLevel(lvl)
+			changes = append(changes, Change{Setting: "log.level", Before: prev.Log.Level, After: next.Log.Level})
+		}
+		for _, ch := range diffMap("log.components.", prev.Log.Components, next.Log.Components, equal) {
+			name := ch.Setting[len("log.components."):]
+			if ch.After == nil {
+				logger.ResetComponentLevel(name)
+			} else {
+				lvl, _ := logger.ParseLevel(ch.After.(string))
+				logger.SetComponentLevel(name, lvl)
+			}
+			changes = append(changes, ch)
+		}
+		return changes
+	}
+}
+
+// WSLimits applies the websocket connection limits of http.ws
+func WSLimits(limiter *wslimit.Limiter) Applier {
+	return func(prev config.Config, next config.Config) []Change {
+		before, after := wsLimits(prev), wsLimits(next)
+		if before == after {
+			return nil
+		}
+		limiter.SetLimits(after)
+		return []Change{{Setting: "http.ws.limits", Before: before, After: after}}
+	}
+}
+
+func wsLimits(cfg config.Config) wslimit.Limits {
+	return wslimit.Limits{
+		Total:  cfg.HTTP.WS.MaxConnections,
+		PerIP:  cfg.HTTP.WS.MaxConnectionsPerIP,
+		PerKey: cfg.HTTP.WS.MaxConnectionsPerKey,
+	}
+}
+
+// Fees applies the default fee rates, those of the pairs and the fee tiers to
+// every book
+func Fees(books *book.Registry) Applier {
+	return func(prev config.Config, next config.Config) []Change {
+		before, after := prev.FeeSchedule(), next.FeeSchedule()
+		var changes []Change
+		if before.Default != after.Default {
+			changes = append(changes, Change{Setting: "fees", Before: before.Default, After: after.Default})
+		}
+		changes = append(changes, diffMap("fees.pairs.", before.Pairs, after.Pairs, equal)...)
+		changes = append(changes, diffMap("fees.tiers.", before.Tiers, after.Tiers, fee.Tier.Equal)...)
+		if len(changes) > 0 {
+			books.SetFees(after)
+		}
+		return changes
+	}
+}
+
+// FeeAssets applies the assets fees can be paid in and their discounts
+func FeeAssets(engine *feeasset.Engine) Applier {
+	return func(prev config.Config, next config.Config) []Change {
+		changes := diffMap("fees.assets.", prev.Fees.Assets, next.Fees.Assets, equal)
+		if len(changes) > 0 {
+			engine.SetAssets(next.Fees.Assets)
+		}
+		return changes
+	}
+}
+
+// Margin applies the default margin limits and those of the pairs
+func Margin(engine *margin.Engine) Applier {
+	return func(prev config.Config, next config.Config) []Change {
+		beforeDefault, beforePairs := prev.MarginLimits()
+		afterDefault, afterPairs := next.MarginLimits()
+		var changes []Change
+		if beforeDefault != afterDefault {
+			changes = append(changes, Change{Setting: "margin", Before: beforeDefault, After: afterDefault})
+		}
+		changes = append(changes, diffMap("margin.pairs.", beforePairs, afterPairs, equal)...)
+		if len(changes) > 0 {
+			engine.SetLimits(afterDefault, afterPairs)
+		}
+		return changes
+	}
+}
+
+// Assets applies the assets that changed in the file, before the pairs that
+// trade them. An asset removed from the file is delisted, those changed since
+// through the admin API are left as they are.
+func Assets() Applier {
+	return func(prev config.Config, next config.Config) []Change {
+		changes := diffMap("assets.", assetsBySymbol(prev.OrderAssets()), assetsBySymbol(next.OrderAssets()), equal[order.Asset])
+		for _, ch := range changes {
+			if ch.After == nil {
+				order.DelistAsset(ch.Setting[len("assets."):])
+			} else {
+				order.RegisterAsset(ch.After.(order.Asset))
+			}
+		}
+		return changes
+	}
+}
+
+// Pairs applies the pairs that changed in the file and opens their books. A
+// pair removed from the file is delisted and its book closed, unless orders
+// still rest on it. Those changed since through the admin API are left as
+// they are.
+func Pairs(books order.PairBooks) Applier {
+	return func(prev config.Config, next config.Config) []Change {
+		changes := diffMap("pairs.", pairsByID(prev.OrderPairs()), pairsByID(next.OrderPairs()), samePair)
+		for _, ch := range changes {
+			if ch.After == nil {
+				pairId := ch.Setting[len("pairs."):]
+				order.DelistPair(pairId)
+				if err := books.ClosePair(pairId); err != nil {
+					logger.Warn("delisted pair keeps its book", map[string]any{
+						"pair_id": pairId,
+						"error":   err.Error(),
+					})
+				}
+			} else {
+				p := ch.After.(order.Pair)
+				order.RegisterPair(p)
+				books.OpenPair(p)
+			}
+		}
+		return changes
+	}
+}
+
+// Sessions applies the trading hours of the pairs that changed in the file,
+// the pairs move to the state of their new schedule at once
+func Sessions(sessions *session.Sessions) Applier {
+	return func(prev config.Config, next config.Config) []Change {
+		changes := diffMap("sessions.", sessionsByPair(prev), sessionsByPair(next), config.SessionConfig.Equal)
+		if len(changes) > 0 {
+			sessions.SetSchedules(next.Sessions())
+		}
+		return changes
+	}
+}
+
+// Calendar applies the closures that changed in the file, the pairs move to
+// their new state at once
+func Calendar(sessions *session.Sessions) Applier {
+	return func(prev config.Config, next config.Config) []Change {
+		changes := diffMap("calendar.", closuresByName(prev), closuresByName(next), config.ClosureConfig.Equal)
+		if len(changes) > 0 {
+			sessions.SetCalendar(next.Closures())
+		}
+		return changes
+	}
+}
+
+// DMM applies the obligations of the designated market makers that changed
+// in the file, what was measured of the others is kept
+func DMM(tracker *dmm.Tracker) Applier {
+	return func(prev config.Config, next config.Config) []Change {
+		changes := diffMap("dmm.obligations.", obligationsByKey(prev), obligationsByKey(next), config.DMMObligationConfig.Equal)
+		if len(changes) > 0 {
+			tracker.SetObligations(next.DMMObligations())
+		}
+		return changes
+	}
+}
+
+// Flags applies the feature flags that changed in the file, those changed
+// since through the admin API are left as they are
+func Flags(set *flags.Set) Applier {
+	return func(prev config.Config, next config.Config) []Change {
+		changes := diffMap("flags.", prev.Flags, next.Flags, flags.Flag.Equal)
+		for _, ch := range changes {
+			name := ch.Setting[len("flags."):]
+			if ch.After == nil {
+				set.Remove(name)
+			} else {
+				set.Set(name, ch.After.(flags.Flag))
+			}
+		}
+		return changes
+	}
+}
+
+// STPGroups applies the STP groups that changed in the file, those changed
+// since through the admin API are left as they are
+func STPGroups(groups *stp.Groups) Applier {
+	return func(prev config.Config, next config.Config) []Change {
+		changes := diffMap("stp.groups.", prev.STP.Groups, next.STP.Groups, slices.Equal)
+		// The changed groups are all dissolved first, for the accounts moving
+		// between them
+		for _, ch := range changes {
+			groups.Remove(ch.Setting[len("stp.groups."):])
+		}
+		for _, ch := range changes {
+			if ch.After == nil {
+				continue
+			}
+			name := ch.Setting[len("stp.groups."):]
+			if _, _, err := groups.Set(name, ch.After.([]int)); err != nil {
+				logger.Warn("STP group not applied", map[string]any{
+					"group": name,
+					"error": err.Error(),
+				})
+			}
+		}
+		return changes
+	}
+}
+
+func sessionsByPair(cfg config.Config) map[string]config.SessionConfig {
+	byPair := make(map[string]config.SessionConfig)
+	for _, p := range cfg.Pairs {
+		if p.Session != nil {
+			byPair[p.ID] = *p.Session
+		}
+	}
+	return byPair
+}
+
+func closuresByName(cfg config.Config) map[string]config.ClosureConfig {
+	byName := make(map[string]config.ClosureConfig, len(cfg.Calendar))
+	for _, c := range cfg.Calendar {
+		byName[c.Name] = c
+	}
+	return byName
+}
+
+func obligationsByKey(cfg config.Config) map[string]config.DMMObligationConfig {
+	byKey := make(map[string]config.DMMObligationConfig, len(cfg.DMM.Obligations))
+	for _, o := range cfg.DMM.Obligations {
+		byKey[o.Key()] = o
+	}
+	return byKey
+}
+
+func assetsBySymbol(assets []order.Asset) map[string]order.Asset {
+	bySymbol := make(map[string]order.Asset, len(assets))
+	for _, a := range assets {
+		bySymbol[a.Symbol] = a
+	}
+	return bySymbol
+}
+
+func pairsByID(pairs []order.Pair) map[string]order.Pair {
+	byID := make(map[string]order.Pair, len(pairs))
+	for _, p := range pairs {
+		byID[p.ID] = p
+	}
+	return byID
+}
+
+func samePair(a order.Pair, b order.Pair) bool {
+	return a.ID == b.ID && a.Base == b.Base && a.Quote == b.Quote &&
+		a.TickSize.Equal(b.TickSize) && a.LotSize.Equal(b.LotSize) &&
+		a.MinAmount.Equal(b.MinAmount) && a.MinNotional.Equal(b.MinNotional) &&
+		a.PriceScale == b.PriceScale && a.AmountScale == b.AmountScale && a.Status == b.Status
+}
+
+func equal[V comparable](a V, b V) bool {
+	return a == b
+}
+
+// diffMap returns a change per key of prev or next whose value differs, in
+// the order of the keys
+func diffMap[V any](prefix string, prev map[string]V, next map[string]V, same func(a V, b V) bool) []Change {
+	var keys []string
+	for k := range prev {
+		keys = append(keys, k)
+	}
+	for k := range next {
+		if _, ok := prev[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	var changes []Change
+	for _, k := range keys {
+		before, hadBefore := prev[k]
+		after, hasAfter := next[k]
+		if hadBefore && hasAfter && same(before, after) {
+			continue
+		}
+		ch := Change{Setting: prefix + k}
+		if hadBefore {
+			ch.Before = before
+		}
+		if hasAfter {
+			ch.After = after
+		}
+		changes = append(changes, ch)
+	}
+	return changes
+}
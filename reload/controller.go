@@ -0,0 +1,44 @@
+package reload
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindReloadRouter serves POST /admin/config/reload, loading the config file
+// again and returning the settings it changed
+func BindReloadRouter(r fiber.Router, reloader *Reloader) {
+	r.Post("/admin/config/reload", func(c *fiber.Ctx) error {
+		changes, err := reloader.Reload(c.UserContext(), SOURCE_ADMIN)
+		if errors.Is(err, ErrNoConfigFile) {
+			c.Status(http.StatusConflict)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			c.Status(http.StatusUnprocessableEntity)
+			return c.JSON(&Response{
+				Message: "The configuration is invalid, the settings in effect are kept: " + err.Error(),
+				Data:    nil,
+			})
+		}
+		if changes == nil {
+			changes = []Change{}
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Configuration reloaded",
+			Data:    changes,
+		})
+	})
+}
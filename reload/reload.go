@@ -0,0 +1,166 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"order-book/config"
+	"order-book/logger"
+	"order-book/order"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrNoConfigFile = errors.New("There is no config file to reload, CONFIG_FILE isn't set")
+
+// Sources of a reload
+const (
+	SOURCE_FILE  = "file"
+	SOURCE_ADMIN = "admin"
+)
+
+// Change is a setting a reload changed, Before is nil for one that wasn't
+// set and After for one that was removed
+type Change struct {
+	Setting string `json:"setting"`
+	Before  any    `json:"before"`
+	After   any    `json:"after"`
+}
+
+// Applier applies the settings it handles from next when they differ from
+// those of prev, and returns what it changed
+type Applier func(prev config.Config, next config.Config) []Change
+
+type EventWriter interface {
+	AddEvent(ctx context.Context, ev order.OrderHistoryEvent) error
+}
+
+// Reloader loads the configuration again when its file changes or on
+// demand. A configuration that doesn't validate is refused as a whole, the
+// settings in effect are kept.
+type Reloader struct {
+	path     string
+	load     func() (config.Config, error)
+	events   EventWriter
+	appliers []Applier
+
+	mu      sync.Mutex
+	current config.Config
+	modTime time.Time
+}
+
+// NewReloader reloads the file at path with load, current being the
+// configuration the engine started with. The changes are recorded to events.
+func NewReloader(path string, load func() (config.Config, error), current config.Config, events EventWriter) *Reloader {
+	r := &Reloader{
+		path:    path,
+		load:    load,
+		events:  events,
+		current: current,
+	}
+	if info, err := os.Stat(path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	return r
+}
+
+// Register adds the applier of a part of the configuration
+func (r *Reloader) Register(fn Applier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.appliers = append(r.appliers, fn)
+}
+
+// Run reloads the configuration once its file was modified, checking every
+// interval until ctx is done
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(r.path)
+			if err != nil {
+				logger.Error("failed to check the config file", map[string]any{
+					"path":  r.path,
+					"error": err,
+				})
+				continue
+			}
+			r.mu.Lock()
+			unchanged := info.ModTime().Equal(r.modTime)
+			r.mu.Unlock()
+			if unchanged {
+				continue
+			}
+			// A refused configuration is logged by Reload, and only tried
+			// again once the file changes
+			r.Reload(ctx, SOURCE_FILE)
+		}
+	}
+}
+
+// Reload loads the configuration and applies what changed of it
+func (r *Reloader) Reload(ctx context.Context, source string) ([]Change, error) {
+	if r.path == "" {
+		return nil, ErrNoConfigFile
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return nil, err
+	}
+	r.modTime = info.ModTime()
+	next, err := r.load()
+	if err != nil {
+		logger.Error("refused the reloaded configuration", map[string]any{
+			"path":   r.path,
+			"source": source,
+			"error":  err,
+		})
+		return nil, err
+	}
+
+	var changes []Change
+	for _, fn := range r.appliers {
+		changes = append(changes, fn(r.current, next)...)
+	}
+	r.current = next
+
+	actor := order.Actor{Type: order.ACTOR_CONFIG_FILE, ID: r.path}
+	if source == SOURCE_ADMIN {
+		actor = order.ActorOf(ctx, 0)
+	}
+	for _, ch := range changes {
+		logger.Info("setting changed", map[string]any{
+			"setting": ch.Setting,
+			"before":  ch.Before,
+			"after":   ch.After,
+			"source":  source,
+		})
+		err := r.events.AddEvent(context.WithoutCancel(ctx), order.OrderHistoryEvent{
+			Name: order.CONFIG_CHANGED,
+			Metadata: map[string]any{
+				"setting": ch.Setting,
+				"before":  ch.Before,
+				"after":   ch.After,
+				"source":  source,
+				"actor":   actor,
+			},
+		})
+		if err != nil {
+			logger.Error("failed to add the audit event of a setting change", map[string]any{
+				"setting": ch.Setting,
+				"error":   err,
+			})
+		}
+	}
+	logger.Info("configuration reloaded", map[string]any{
+		"source":  source,
+		"changes": len(changes),
+	})
+	return changes, nil
+}
@@ -0,0 +1,41 @@
+package replay
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindReplayRouter serves the progress of the replay on GET /admin/replay,
+// and starts it on POST /admin/replay/start. The replay runs until ctx is
+// done, not for as long as the request.
+func BindReplayRouter(ctx context.Context, r fiber.Router, replayer *Replayer) {
+	r.Get("/admin/replay", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    replayer.Stats(),
+		})
+	})
+	r.Post("/admin/replay/start", func(c *fiber.Ctx) error {
+		if err := replayer.Start(ctx); err != nil {
+			c.Status(http.StatusConflict)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    replayer.Stats(),
+			})
+		}
+		c.Status(http.StatusAccepted)
+		return c.JSON(&Response{
+			Message: "Replay started",
+			Data:    replayer.Stats(),
+		})
+	})
+}
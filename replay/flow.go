@@ -0,0 +1,137 @@
+package replay
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"order-book/order"
+	"os"
+	"slices"
+	"time"
+)
+
+var ErrInvalidEvent = errors.New("The event should be an add with an order or a cancel with an order_id")
+
+// Actions of a recorded order flow
+const (
+	ACTION_ADD    = "add"
+	ACTION_CANCEL = "cancel"
+)
+
+// Event is a step of a recorded order flow. An add carries the order with
+// the ID it was recorded with, a cancel the ID of the order it cancels.
+type Event struct {
+	At      time.Time    `json:"at"`
+	Action  string       `json:"action"`
+	Order   *order.Order `json:"order,omitempty"`
+	OrderID int          `json:"order_id,omitempty"`
+}
+
+func (ev Event) validate() error {
+	switch {
+	case ev.Action == ACTION_ADD && ev.Order != nil:
+	case ev.Action == ACTION_CANCEL && ev.OrderID != 0:
+	default:
+		return ErrInvalidEvent
+	}
+	return nil
+}
+
+// Source yields the events of a flow in the order they happened, io.EOF
+// once there are no more
+type Source interface {
+	Next() (Event, error)
+	Close() error
+}
+
+type fileSource struct {
+	f    *os.File
+	scan *bufio.Scanner
+	line int
+}
+
+// OpenFile reads a flow recorded as JSON lines, an event per line like
+//
+//	{"at":"2026-01-02T10:00:00Z","action":"add","order":{"id":7,"pair_id":"btcusdt","price":"100","amount":"1","type":0,"account_id":1}}
+//	{"at":"2026-01-02T10:00:01Z","action":"cancel","order_id":7}
+func OpenFile(path string) (Source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	scan := bufio.NewScanner(f)
+	scan.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &fileSource{f: f, scan: scan}, nil
+}
+
+func (s *fileSource) Next() (Event, error) {
+	for s.scan.Scan() {
+		s.line++
+		if len(s.scan.Bytes()) == 0 {
+			continue
+		}
+		var ev Event
+		if err := json.Unmarshal(s.scan.Bytes(), &ev); err != nil {
+			return Event{}, fmt.Errorf("line %d: %w", s.line, err)
+		}
+		if err := ev.validate(); err != nil {
+			return Event{}, fmt.Errorf("line %d: %w", s.line, err)
+		}
+		return ev, nil
+	}
+	if err := s.scan.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+func (s *fileSource) Close() error {
+	return s.f.Close()
+}
+
+type sliceSource struct {
+	events []Event
+}
+
+// LoadHistory reads the flow of [from, to) from the orders and their
+// cancellations in the history. It's loaded at once, a range is best kept
+// to what fits in memory.
+func LoadHistory(ctx context.Context, repo order.FlowRepo, from time.Time, to time.Time) (Source, error) {
+	orders, err := repo.GetOrdersCreatedBetween(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	cancellations, err := repo.GetCancellationsBetween(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, 0, len(orders)+len(cancellations))
+	for _, o := range orders {
+		events = append(events, Event{At: o.CreatedAt, Action: ACTION_ADD, Order: &o})
+	}
+	for _, c := range cancellations {
+		events = append(events, Event{At: c.At, Action: ACTION_CANCEL, OrderID: c.OrderID})
+	}
+	// An order comes before its cancel recorded at the same time
+	slices.SortStableFunc(events, func(a, b Event) int {
+		return cmp.Or(a.At.Compare(b.At), cmp.Compare(a.Action, b.Action))
+	})
+	return &sliceSource{events: events}, nil
+}
+
+func (s *sliceSource) Next() (Event, error) {
+	if len(s.events) == 0 {
+		return Event{}, io.EOF
+	}
+	ev := s.events[0]
+	s.events = s.events[1:]
+	return ev, nil
+}
+
+func (s *sliceSource) Close() error {
+	return nil
+}
@@ -0,0 +1,190 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"order-book/book"
+	"order-book/logger"
+	"sync"
+	"time"
+)
+
+var ErrRunning = errors.New("A replay is already running")
+
+var replayLog = logger.Component("replay")
+
+// Stats report the progress of a replay. Position is the recorded time the
+// replay reached.
+type Stats struct {
+	Running    bool      `json:"running"`
+	Speed      float64   `json:"speed"`
+	StartedAt  time.Time `json:"started_at,omitzero"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	Position   time.Time `json:"position,omitzero"`
+	Added      int       `json:"added"`
+	Cancelled  int       `json:"cancelled"`
+	Rejected   int       `json:"rejected"`
+	Skipped    int       `json:"skipped"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Replayer feeds a recorded order flow to a book, which publishes the market
+// data of the books it builds like it does for live orders
+type Replayer struct {
+	book       book.Book
+	speed      float64
+	open       func(ctx context.Context) (Source, error)
+	newAccount func() (int, error)
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// NewReplayer replays the flow open returns into b. speed 1 keeps the pace
+// it was recorded at, 10 is ten times faster and 0 goes as fast as the
+// engine does. The recorded accounts trade as accounts newAccount opens.
+func NewReplayer(b book.Book, speed float64, open func(ctx context.Context) (Source, error), newAccount func() (int, error)) *Replayer {
+	return &Replayer{
+		book:       b,
+		speed:      speed,
+		open:       open,
+		newAccount: newAccount,
+	}
+}
+
+func (r *Replayer) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// Start replays the flow in the background until it's done or ctx is. A
+// replay started again goes on with the books the previous one left.
+func (r *Replayer) Start(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stats.Running {
+		return ErrRunning
+	}
+	r.stats = Stats{Running: true, Speed: r.speed, StartedAt: time.Now()}
+	go r.run(ctx)
+	return nil
+}
+
+func (r *Replayer) run(ctx context.Context) {
+	err := r.replay(ctx)
+	r.mu.Lock()
+	r.stats.Running = false
+	r.stats.FinishedAt = time.Now()
+	if err != nil {
+		r.stats.Error = err.Error()
+	}
+	stats := r.stats
+	r.mu.Unlock()
+
+	if err != nil {
+		replayLog.Error("replay failed", map[string]any{
+			"position": stats.Position,
+			"error":    err,
+		})
+		return
+	}
+	replayLog.Info("replay done", map[string]any{
+		"added":     stats.Added,
+		"cancelled": stats.Cancelled,
+		"rejected":  stats.Rejected,
+		"skipped":   stats.Skipped,
+		"took":      stats.FinishedAt.Sub(stats.StartedAt).String(),
+	})
+}
+
+func (r *Replayer) replay(ctx context.Context) error {
+	src, err := r.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// The recorded IDs of the orders are mapped to those the engine gives
+	// them, for their cancels, and the recorded accounts to those opened for
+	// the replay
+	ids := make(map[int]int)
+	accounts := make(map[int]int)
+	var recordedStart time.Time
+	start := time.Now()
+	for {
+		ev, err := src.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if recordedStart.IsZero() {
+			recordedStart = ev.At
+		}
+		if r.speed > 0 {
+			due := start.Add(time.Duration(float64(ev.At.Sub(recordedStart)) / r.speed))
+			select {
+			case <-time.After(time.Until(due)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		switch ev.Action {
+		case ACTION_ADD:
+			o := *ev.Order
+			recordedId := o.ID
+			accountId, ok := accounts[o.AccountID]
+			if !ok {
+				if accountId, err = r.newAccount(); err != nil {
+					return err
+				}
+				accounts[o.AccountID] = accountId
+			}
+			o.ID, o.Version, o.AccountID = 0, 0, accountId
+			o.CreatedAt = time.Now()
+			timings, err := r.book.AddOrderWithTimings(ctx, o)
+			r.record(ev, func(s *Stats) {
+				if err != nil {
+					s.Rejected++
+					return
+				}
+				ids[recordedId] = timings.OrderID
+				s.Added++
+			})
+			if err != nil {
+				replayLog.Debug("replayed order rejected", map[string]any{
+					"order_id": recordedId,
+					"reason":   err.Error(),
+				})
+			}
+		case ACTION_CANCEL:
+			// An order that was filled by the replay, or that was created
+			// before the flow started, has nothing left to cancel
+			id, ok := ids[ev.OrderID]
+			if ok {
+				err = r.book.CancellOrder(ctx, id)
+			}
+			r.record(ev, func(s *Stats) {
+				if !ok || err != nil {
+					s.Skipped++
+					return
+				}
+				s.Cancelled++
+			})
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *Replayer) record(ev Event, fn func(s *Stats)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.Position = ev.At
+	fn(&r.stats)
+}
@@ -0,0 +1,130 @@
+package sandbox
+
+import (
+	"net/http"
+	"order-book/account"
+	"order-book/logger"
+	"order-book/tenant"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+type fundRequest struct {
+	Asset  string  `json:"asset"`
+	Amount float64 `json:"amount"`
+}
+
+// BindSandboxRouter lets the API keys of the sandbox tenant open accounts on
+// POST /sandbox/accounts and fund them on POST /sandbox/accounts/:id/fund
+func BindSandboxRouter(r fiber.Router, s *Sandbox) {
+	sandbox := r.Group("/sandbox", func(c *fiber.Ctx) error {
+		if tenant.FromCtx(c).ID != s.tenantId {
+			c.Status(http.StatusForbidden)
+			return c.JSON(&Response{
+				Message: ErrNotSandbox.Error(),
+				Data:    nil,
+			})
+		}
+		return c.Next()
+	})
+
+	sandbox.Post("/accounts", func(c *fiber.Ctx) error {
+		accountId, balances, err := s.CreateAccount()
+		if err == tenant.ErrAccountsQuota {
+			c.Status(http.StatusUnprocessableEntity)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusCreated)
+		return c.JSON(&Response{
+			Message: "Sandbox account created",
+			Data: map[string]any{
+				"account_id": accountId,
+				"balances":   balances,
+			},
+		})
+	})
+
+	sandbox.Post("/accounts/:id/fund", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		idempotencyKey := c.Get("Idempotency-Key")
+		if idempotencyKey == "" {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   account.ErrFieldRequired,
+				Message: "Idempotency-Key header is required",
+			})
+		}
+		var req fundRequest
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		if req.Asset == "" || req.Amount <= 0 {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   account.ErrInvalidData,
+				Message: "Please provide an asset and a positive amount",
+			})
+		}
+
+		entry, replayed, err := s.Fund(accountId, req.Asset, req.Amount, idempotencyKey)
+		if err == ErrAccountNotInSandbox {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		if err == ErrFundLimit {
+			c.Status(http.StatusUnprocessableEntity)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		if err == account.ErrIdempotencyKeyReused {
+			c.Status(http.StatusConflict)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			logger.Error("failed to fund a sandbox account", map[string]any{
+				"account_id":      accountId,
+				"asset":           req.Asset,
+				"idempotency_key": idempotencyKey,
+				"error":           err,
+			})
+			return err
+		}
+		if replayed {
+			c.Status(http.StatusOK)
+		} else {
+			c.Status(http.StatusCreated)
+		}
+		return c.JSON(&Response{
+			Message: "Sandbox account funded",
+			Data:    entry,
+		})
+	})
+}
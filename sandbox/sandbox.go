@@ -0,0 +1,114 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"order-book/account"
+	"order-book/logger"
+	"order-book/tenant"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrNotSandbox          = errors.New("The sandbox is only open to the API keys of the sandbox tenant")
+	ErrAccountNotInSandbox = errors.New("The account isn't a sandbox account")
+	ErrFundLimit           = errors.New("The amount is above the funding limit of the sandbox")
+)
+
+// Sandbox is a paper trading tenant. Its accounts and books are those of a
+// tenant like any other, kept apart from the real ones by the engine, and
+// its balances are fictional deposits with the SANDBOX source.
+type Sandbox struct {
+	tenantId string
+	dir      *tenant.Directory
+	balances account.BalanceRepo
+	autoFund map[string]float64
+	maxFund  float64
+}
+
+// New runs the sandbox as tenantId. Its new accounts are credited autoFund,
+// and an account can be funded up to maxFund at a time, 0 for no limit.
+func New(tenantId string, dir *tenant.Directory, balances account.BalanceRepo, autoFund map[string]float64, maxFund float64) *Sandbox {
+	return &Sandbox{
+		tenantId: tenantId,
+		dir:      dir,
+		balances: balances,
+		autoFund: autoFund,
+		maxFund:  maxFund,
+	}
+}
+
+func (s *Sandbox) TenantID() string {
+	return s.tenantId
+}
+
+// Ensure creates the tenant of the sandbox unless it exists
+func (s *Sandbox) Ensure() error {
+	_, err := s.dir.CreateTenant(tenant.Tenant{ID: s.tenantId, Name: "Sandbox"})
+	if err == tenant.ErrTenantExists {
+		return nil
+	}
+	return err
+}
+
+// CreateAccount opens a sandbox account funded with the auto funding
+func (s *Sandbox) CreateAccount() (int, []account.Balance, error) {
+	accountId, err := s.dir.CreateAccount(s.tenantId)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, asset := range slices.Sorted(maps.Keys(s.autoFund)) {
+		_, _, err := s.balances.Deposit(account.Movement{
+			AccountID:      accountId,
+			Asset:          asset,
+			Amount:         decimal.NewFromFloat(s.autoFund[asset]),
+			IdempotencyKey: fmt.Sprintf("sandbox-auto-fund-%d-%s", accountId, asset),
+			Source:         account.SANDBOX,
+			Reference:      "auto funding",
+		})
+		if err != nil {
+			logger.Error("failed to auto fund a sandbox account", map[string]any{
+				"account_id": accountId,
+				"asset":      asset,
+				"error":      err,
+			})
+			return accountId, nil, err
+		}
+	}
+	balances, err := s.balances.GetBalances(accountId)
+	return accountId, balances, err
+}
+
+// Fund credits a sandbox account with fictional funds
+func (s *Sandbox) Fund(accountId int, asset string, amount float64, idempotencyKey string) (account.LedgerEntry, bool, error) {
+	if s.dir.TenantOf(accountId) != s.tenantId {
+		return account.LedgerEntry{}, false, ErrAccountNotInSandbox
+	}
+	if s.maxFund > 0 && amount > s.maxFund {
+		return account.LedgerEntry{}, false, ErrFundLimit
+	}
+	return s.balances.Deposit(account.Movement{
+		AccountID:      accountId,
+		Asset:          asset,
+		Amount:         decimal.NewFromFloat(amount),
+		IdempotencyKey: idempotencyKey,
+		Source:         account.SANDBOX,
+		Reference:      "sandbox funding",
+	})
+}
+
+// Middleware marks the responses to the sandbox tenant with X-Sandbox, so
+// integrators can tell they aren't trading for real. It runs after
+// tenant.Middleware.
+func (s *Sandbox) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if tenant.FromCtx(c).ID == s.tenantId {
+			c.Set("X-Sandbox", "true")
+		}
+		return c.Next()
+	}
+}
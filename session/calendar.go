@@ -0,0 +1,74 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Kinds of closure
+const (
+	HOLIDAY     = "holiday"
+	MAINTENANCE = "maintenance"
+)
+
+var ErrCalendarClosed = errors.New("The pair is closed for a holiday or maintenance, see GET /calendar")
+
+// Closure closes pairs from Start until End whatever their session, all of
+// them when it names none
+type Closure struct {
+	Name  string    `json:"name"`
+	Kind  string    `json:"kind"`
+	Pairs []string  `json:"pairs,omitempty"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// FullDay is a closure of date, a 2006-01-02 day of timezone, from midnight
+// to midnight
+func FullDay(name string, kind string, date string, timezone string, pairs []string) (Closure, error) {
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return Closure{}, fmt.Errorf("unknown timezone %q", timezone)
+	}
+	day, err := time.ParseInLocation(time.DateOnly, date, location)
+	if err != nil {
+		return Closure{}, fmt.Errorf("date %q should be a day like 2006-01-02", date)
+	}
+	if kind == "" {
+		kind = HOLIDAY
+	}
+	return Closure{
+		Name:  name,
+		Kind:  kind,
+		Pairs: pairs,
+		Start: day,
+		End:   day.AddDate(0, 0, 1),
+	}, nil
+}
+
+func (c Closure) Validate() error {
+	switch {
+	case c.Name == "":
+		return errors.New("name is required")
+	case c.Kind != HOLIDAY && c.Kind != MAINTENANCE:
+		return fmt.Errorf("kind should be %s or %s", HOLIDAY, MAINTENANCE)
+	case c.Start.IsZero() || !c.End.After(c.Start):
+		return errors.New("end should be after start")
+	}
+	return nil
+}
+
+// Closes reports whether the closure applies to a pair
+func (c Closure) Closes(pairId string) bool {
+	return len(c.Pairs) == 0 || slices.Contains(c.Pairs, pairId)
+}
+
+// Active reports whether the closure is in force at t
+func (c Closure) Active(t time.Time) bool {
+	return !t.Before(c.Start) && t.Before(c.End)
+}
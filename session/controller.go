@@ -0,0 +1,45 @@
+package session
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindSessionRouter serves the trading sessions of the scheduled and closed
+// pairs on GET /sessions and the closures of the calendar on GET /calendar,
+// a pair without a schedule is OPEN out of those closures
+func BindSessionRouter(r fiber.Router, sessions *Sessions) {
+	r.Get("/sessions", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    sessions.States(),
+		})
+	})
+
+	r.Get("/sessions/:pair_id", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    sessions.PairStateOf(c.Params("pair_id")),
+		})
+	})
+
+	// The closures to come and those in force, of a pair with ?pair_id=
+	// including those of every pair
+	r.Get("/calendar", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    sessions.Calendar(c.Query("pair_id"), time.Now()),
+		})
+	})
+}
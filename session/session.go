@@ -0,0 +1,432 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"order-book/logger"
+	"order-book/order"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// States of the trading session of a pair
+const (
+	CLOSED   = "CLOSED"
+	PRE_OPEN = "PRE_OPEN"
+	OPEN     = "OPEN"
+)
+
+// Modes of PRE_OPEN. CANCEL_ONLY refuses new orders, AUCTION takes them
+// without matching and uncrosses the book at the open.
+const (
+	CANCEL_ONLY = "cancel_only"
+	AUCTION     = "auction"
+)
+
+var (
+	ErrSessionClosed = errors.New("The trading session of the pair is closed")
+	ErrCancelOnly    = errors.New("The pair is in pre-open, only cancellations are accepted")
+)
+
+var log = logger.Component("session")
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Schedule is the trading hours of a pair in Location. A session opens at
+// Open on each of Days, every day if there are none, and closes at Close of
+// the same day or of the next one when Close isn't after Open. PreOpen
+// before the open the pair is in PRE_OPEN.
+type Schedule struct {
+	Location    *time.Location
+	Days        []time.Weekday
+	Open        time.Duration
+	Close       time.Duration
+	PreOpen     time.Duration
+	PreOpenMode string
+}
+
+// Parse reads a schedule, open and close are 15:04 clock times of timezone
+// and days the three letter names of the week days
+func Parse(timezone string, open string, close string, days []string, preOpen time.Duration, preOpenMode string) (Schedule, error) {
+	var (
+		s   = Schedule{PreOpen: preOpen, PreOpenMode: preOpenMode}
+		err error
+	)
+	if s.Location, err = time.LoadLocation(timezone); err != nil {
+		return Schedule{}, fmt.Errorf("unknown timezone %q", timezone)
+	}
+	if s.Open, err = clock(open); err != nil {
+		return Schedule{}, fmt.Errorf("open: %w", err)
+	}
+	if s.Close, err = clock(close); err != nil {
+		return Schedule{}, fmt.Errorf("close: %w", err)
+	}
+	for _, day := range days {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return Schedule{}, fmt.Errorf("unknown day %q, days are sun to sat", day)
+		}
+		s.Days = append(s.Days, weekday)
+	}
+	if s.PreOpenMode == "" {
+		s.PreOpenMode = CANCEL_ONLY
+	}
+	if s.PreOpenMode != CANCEL_ONLY && s.PreOpenMode != AUCTION {
+		return Schedule{}, fmt.Errorf("pre_open_mode should be %s or %s", CANCEL_ONLY, AUCTION)
+	}
+	if preOpen < 0 || (preOpen > 0 && preOpen >= 24*time.Hour-s.length()) {
+		return Schedule{}, errors.New("pre_open should be shorter than the time between two sessions")
+	}
+	return s, nil
+}
+
+// clock parses a 15:04 time of the day
+func clock(v string) (time.Duration, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, fmt.Errorf("%q should be a time like 09:30", v)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// length is how long a session lasts
+func (s Schedule) length() time.Duration {
+	if s.Close > s.Open {
+		return s.Close - s.Open
+	}
+	return 24*time.Hour - s.Open + s.Close
+}
+
+// sessionsAround returns the pre-open, open and close times of the sessions
+// opening from the day before t to days after it
+func (s Schedule) sessionsAround(t time.Time, days int) [][3]time.Time {
+	local := t.In(s.Location)
+	var sessions [][3]time.Time
+	for offset := -1; offset <= days; offset++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+offset, 0, 0, 0, 0, s.Location)
+		if len(s.Days) > 0 && !slices.Contains(s.Days, day.Weekday()) {
+			continue
+		}
+		open := atClock(day, s.Open)
+		sessions = append(sessions, [3]time.Time{open.Add(-s.PreOpen), open, open.Add(s.length())})
+	}
+	return sessions
+}
+
+// atClock is the time of day d at clock c, on the wall clock so it holds
+// across daylight saving changes
+func atClock(d time.Time, c time.Duration) time.Time {
+	return time.Date(d.Year(), d.Month(), d.Day(), int(c/time.Hour), int(c%time.Hour/time.Minute), 0, 0, d.Location())
+}
+
+// StateAt is the state of the session at t
+func (s Schedule) StateAt(t time.Time) string {
+	for _, times := range s.sessionsAround(t, 1) {
+		switch {
+		case !t.Before(times[1]) && t.Before(times[2]):
+			return OPEN
+		case !t.Before(times[0]) && t.Before(times[1]):
+			return PRE_OPEN
+		}
+	}
+	return CLOSED
+}
+
+// Next is the next state of the session after t and when it starts, zero
+// without any session in the coming week
+func (s Schedule) Next(t time.Time) (state string, at time.Time) {
+	for _, times := range s.sessionsAround(t, 8) {
+		for _, boundary := range times {
+			if boundary.After(t) && (at.IsZero() || boundary.Before(at)) {
+				at = boundary
+			}
+		}
+	}
+	if at.IsZero() {
+		return "", at
+	}
+	return s.StateAt(at), at
+}
+
+// PairState is the session of a pair and what comes next, Closure the
+// calendar closure the pair is closed for
+type PairState struct {
+	PairID      string    `json:"pair_id"`
+	State       string    `json:"state"`
+	PreOpenMode string    `json:"pre_open_mode"`
+	Timezone    string    `json:"timezone"`
+	Next        string    `json:"next,omitempty"`
+	NextAt      time.Time `json:"next_at,omitzero"`
+	Closure     *Closure  `json:"closure,omitempty"`
+}
+
+// Sessions moves the scheduled pairs between their states, and closes the
+// pairs for the closures of the calendar. Pairs without a schedule are OPEN
+// out of those.
+type Sessions struct {
+	mu        sync.RWMutex
+	schedules map[string]Schedule
+	calendar  []Closure
+	states    map[string]string
+	listeners []func(pairId string, from string, to string)
+	now       func() time.Time
+}
+
+func New(schedules map[string]Schedule) *Sessions {
+	return &Sessions{
+		schedules: schedules,
+		states:    make(map[string]string),
+		now:       time.Now,
+	}
+}
+
+// OnTransition registers a listener for the state changes of the pairs. The
+// first state of a pair is a change from an empty state.
+func (s *Sessions) OnTransition(fn func(pairId string, from string, to string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// SetSchedules replaces the schedules and moves the pairs to their new
+// state, a pair left without a schedule goes back to OPEN
+func (s *Sessions) SetSchedules(schedules map[string]Schedule) {
+	s.mu.Lock()
+	s.schedules = schedules
+	s.mu.Unlock()
+	s.Update()
+}
+
+// SetCalendar replaces the closures of the calendar and moves the pairs to
+// their new state
+func (s *Sessions) SetCalendar(calendar []Closure) {
+	s.mu.Lock()
+	s.calendar = slices.Clone(calendar)
+	slices.SortStableFunc(s.calendar, func(a, b Closure) int { return a.Start.Compare(b.Start) })
+	s.mu.Unlock()
+	s.Update()
+}
+
+// closureAt is the closure a pair is closed for at t, callers hold s.mu
+func (s *Sessions) closureAt(pairId string, t time.Time) (Closure, bool) {
+	for _, c := range s.calendar {
+		if c.Closes(pairId) && c.Active(t) {
+			return c, true
+		}
+	}
+	return Closure{}, false
+}
+
+// stateAt is the state of a pair at t, callers hold s.mu
+func (s *Sessions) stateAt(pairId string, t time.Time) string {
+	if _, ok := s.closureAt(pairId, t); ok {
+		return CLOSED
+	}
+	if schedule, ok := s.schedules[pairId]; ok {
+		return schedule.StateAt(t)
+	}
+	return OPEN
+}
+
+// pairs are the pairs whose state is tracked, the scheduled ones, the
+// listed ones and those the calendar names. Callers hold s.mu.
+func (s *Sessions) pairs() []string {
+	ids := slices.Collect(maps.Keys(s.schedules))
+	for _, p := range order.GetPairs() {
+		ids = append(ids, p.ID)
+	}
+	for _, c := range s.calendar {
+		ids = append(ids, c.Pairs...)
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// Update moves the pairs to their state now, that of their schedule unless
+// the calendar closes them
+func (s *Sessions) Update() {
+	type transition struct{ pairId, from, to string }
+	var transitions []transition
+	s.mu.Lock()
+	now := s.now()
+	states := make(map[string]string)
+	for _, pairId := range s.pairs() {
+		_, scheduled := s.schedules[pairId]
+		if to := s.stateAt(pairId, now); scheduled || to != OPEN {
+			states[pairId] = to
+		}
+	}
+	for pairId, to := range states {
+		if from, ok := s.states[pairId]; !ok || from != to {
+			transitions = append(transitions, transition{pairId, from, to})
+		}
+	}
+	for pairId, from := range s.states {
+		if _, ok := states[pairId]; !ok && from != OPEN {
+			transitions = append(transitions, transition{pairId, from, OPEN})
+		}
+	}
+	s.states = states
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	for _, t := range transitions {
+		log.Info("trading session changed", map[string]any{
+			"pair_id": t.pairId,
+			"from":    t.from,
+			"to":      t.to,
+		})
+		for _, fn := range listeners {
+			fn(t.pairId, t.from, t.to)
+		}
+	}
+}
+
+// Run updates the states every interval until ctx is done
+func (s *Sessions) Run(ctx context.Context, interval time.Duration) {
+	s.Update()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Update()
+		}
+	}
+}
+
+// State is the session state of a pair
+func (s *Sessions) State(pairId string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if state, ok := s.states[pairId]; ok {
+		return state
+	}
+	// The pairs aren't listed, a closure of every pair still applies
+	return s.stateAt(pairId, s.now())
+}
+
+// Auction reports whether the pre-open of a pair takes orders for an auction
+func (s *Sessions) Auction(pairId string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.schedules[pairId].PreOpenMode == AUCTION
+}
+
+// States returns the state of each pair that is scheduled or closed
+func (s *Sessions) States() []PairState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := slices.Collect(maps.Keys(s.schedules))
+	for pairId := range s.states {
+		if _, ok := s.schedules[pairId]; !ok {
+			ids = append(ids, pairId)
+		}
+	}
+	slices.Sort(ids)
+	list := make([]PairState, 0, len(ids))
+	for _, pairId := range ids {
+		list = append(list, s.pairState(pairId))
+	}
+	return list
+}
+
+// PairStateOf returns the state of a pair
+func (s *Sessions) PairStateOf(pairId string) PairState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pairState(pairId)
+}
+
+// pairState is the state of a pair now, callers hold s.mu
+func (s *Sessions) pairState(pairId string) PairState {
+	now := s.now()
+	ps := PairState{PairID: pairId, State: s.stateAt(pairId, now)}
+	if state, ok := s.states[pairId]; ok {
+		ps.State = state
+	}
+	if schedule, ok := s.schedules[pairId]; ok {
+		ps.PreOpenMode = schedule.PreOpenMode
+		ps.Timezone = schedule.Location.String()
+	}
+	if c, ok := s.closureAt(pairId, now); ok {
+		ps.Closure = &c
+	}
+	ps.Next, ps.NextAt = s.next(pairId, now)
+	return ps
+}
+
+// next is the next state of a pair after t and when it starts, callers
+// hold s.mu. The boundaries are those of the schedule and the calendar.
+func (s *Sessions) next(pairId string, t time.Time) (string, time.Time) {
+	var boundaries []time.Time
+	if schedule, ok := s.schedules[pairId]; ok {
+		for _, times := range schedule.sessionsAround(t, 8) {
+			boundaries = append(boundaries, times[:]...)
+		}
+	}
+	for _, c := range s.calendar {
+		if c.Closes(pairId) {
+			boundaries = append(boundaries, c.Start, c.End)
+		}
+	}
+	slices.SortFunc(boundaries, time.Time.Compare)
+	current := s.stateAt(pairId, t)
+	for _, at := range boundaries {
+		if !at.After(t) {
+			continue
+		}
+		if state := s.stateAt(pairId, at); state != current {
+			return state, at
+		}
+	}
+	return "", time.Time{}
+}
+
+// Calendar returns the closures that have not ended by t, of a pair or of
+// every pair when pairId is empty
+func (s *Sessions) Calendar(pairId string, t time.Time) []Closure {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := make([]Closure, 0, len(s.calendar))
+	for _, c := range s.calendar {
+		if c.End.After(t) && (pairId == "" || c.Closes(pairId)) {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// ValidateOrder refuses the orders of a pair closed by the calendar or out
+// of its session, and in its pre-open unless it's an auction
+func (s *Sessions) ValidateOrder(o order.Order) error {
+	s.mu.RLock()
+	_, closed := s.closureAt(o.PairID, s.now())
+	s.mu.RUnlock()
+	if closed {
+		return ErrCalendarClosed
+	}
+	switch s.State(o.PairID) {
+	case CLOSED:
+		return ErrSessionClosed
+	case PRE_OPEN:
+		if !s.Auction(o.PairID) {
+			return ErrCancelOnly
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package shard
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindShardRouter serves GET /shards, the nodes and which of them owns
+// ?pair_id=, for clients that would rather connect to the owner directly
+func BindShardRouter(r fiber.Router, router *Router) {
+	r.Get("/shards", func(c *fiber.Ctx) error {
+		data := map[string]any{
+			"self":  router.Self(),
+			"nodes": router.Nodes(),
+		}
+		if pairId := c.Query("pair_id"); pairId != "" {
+			data["owner"] = router.Owner(pairId)
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    data,
+		})
+	})
+}
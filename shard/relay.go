@@ -0,0 +1,119 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"order-book/metrics"
+	"order-book/panics"
+	"order-book/shutdown"
+	"order-book/wslimit"
+	"strings"
+	"time"
+
+	dialer "github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+const (
+	targetKey   = "shard_target"
+	headerKey   = "shard_header"
+	dialTimeout = 5 * time.Second
+)
+
+// handshakeHeaders are set by the dialer itself, the others of the client
+// like its API key and origin go on to the owner
+var handshakeHeaders = map[string]bool{
+	"Host":                     true,
+	"Connection":               true,
+	"Upgrade":                  true,
+	"Sec-Websocket-Key":        true,
+	"Sec-Websocket-Version":    true,
+	"Sec-Websocket-Extensions": true,
+}
+
+// streamURL is the websocket URL of the public listener at nodeURL
+func streamURL(nodeURL string) string {
+	if rest, ok := strings.CutPrefix(nodeURL, "https://"); ok {
+		return "wss://" + rest
+	}
+	return "ws://" + strings.TrimPrefix(nodeURL, "http://")
+}
+
+// relayHeader copies the headers of the client's handshake for the owner,
+// they don't outlive the fiber context
+func relayHeader(c *fiber.Ctx, self string) http.Header {
+	header := make(http.Header)
+	c.Request().Header.VisitAll(func(k, v []byte) {
+		if name := http.CanonicalHeaderKey(string(k)); !handshakeHeaders[name] {
+			header.Add(name, string(v))
+		}
+	})
+	header.Set(forwardedHeader, self)
+	return header
+}
+
+// relayStream copies the messages between a client and the same stream on
+// the owner of its pair until either side closes, the close code of the
+// owner is passed on to the client
+func (r *Router) relayStream(c *websocket.Conn) {
+	defer panics.Recover("ws", map[string]any{"stream": "shard"})
+	defer shutdown.TrackWebsocket(c)()
+	defer wslimit.Release(c)
+	defer c.Close()
+	stream := metrics.Stream("shard")
+	stream.Connected()
+	reason := metrics.DisconnectClientClosed
+	defer func() { stream.Disconnected(reason) }()
+	target := c.Locals(targetKey).(string)
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	upstream, _, err := dialer.DefaultDialer.DialContext(ctx, target, c.Locals(headerKey).(http.Header))
+	if err != nil {
+		shardLog.Warn("failed to relay a stream to the owner of the pair", map[string]any{
+			"target": target,
+			"error":  err,
+		})
+		reason = metrics.DisconnectUnavailable
+		c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, ErrOwnerUnreachable.Error()))
+		return
+	}
+	defer upstream.Close()
+	stream.Subscribed()
+	defer stream.Unsubscribed()
+
+	// The client only sends subscriptions and pongs, closing the owner's side
+	// once it's gone ends the loop below
+	go func() {
+		defer panics.Recover("ws", map[string]any{"stream": "shard"})
+		defer upstream.Close()
+		for {
+			kind, msg, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := upstream.WriteMessage(kind, msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		kind, msg, err := upstream.ReadMessage()
+		if err != nil {
+			var closeErr *dialer.CloseError
+			if errors.As(err, &closeErr) {
+				reason = metrics.DisconnectUnavailable
+				c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(closeErr.Code, closeErr.Text))
+			}
+			return
+		}
+		if err := c.WriteMessage(kind, msg); err != nil {
+			reason = metrics.DisconnectWriteError
+			return
+		}
+		stream.Sent()
+	}
+}
@@ -0,0 +1,72 @@
+package shard
+
+import (
+	"hash/fnv"
+	"slices"
+	"strconv"
+)
+
+// replicas is the number of points of a node on the ring, enough for the
+// pairs to spread evenly between a handful of nodes
+const replicas = 128
+
+// Node is an engine node and the URL of its public listener
+type Node struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+type point struct {
+	hash uint32
+	node int
+}
+
+// ring places the pairs on the nodes by consistent hashing, adding or
+// removing a node only moves the pairs of its own points
+type ring struct {
+	points []point
+}
+
+func newRing(nodes []Node) ring {
+	r := ring{points: make([]point, 0, len(nodes)*replicas)}
+	for idx, node := range nodes {
+		for replica := range replicas {
+			r.points = append(r.points, point{hash: hash(node.ID + "#" + strconv.Itoa(replica)), node: idx})
+		}
+	}
+	slices.SortFunc(r.points, func(a, b point) int {
+		switch {
+		case a.hash < b.hash:
+			return -1
+		case a.hash > b.hash:
+			return 1
+		}
+		return a.node - b.node
+	})
+	return r
+}
+
+// owner is the index of the node owning pairId, that of the first point at
+// or after the hash of the pair
+func (r ring) owner(pairId string) int {
+	h := hash(pairId)
+	idx, _ := slices.BinarySearchFunc(r.points, h, func(p point, h uint32) int {
+		switch {
+		case p.hash < h:
+			return -1
+		case p.hash > h:
+			return 1
+		}
+		return 0
+	})
+	if idx == len(r.points) {
+		idx = 0
+	}
+	return r.points[idx].node
+}
+
+func hash(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
@@ -0,0 +1,178 @@
+package shard
+
+import (
+	"errors"
+	"order-book/logger"
+	"order-book/order"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/proxy"
+	"github.com/gofiber/websocket/v2"
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	ErrNotOwner         = errors.New("This node doesn't own the pair, the nodes disagree on the sharding")
+	ErrOwnerUnreachable = errors.New("The node owning the pair is unreachable")
+)
+
+const (
+	// forwardedHeader names the node a request was forwarded by, a node gets
+	// no further than the owner so a misconfigured node can't loop requests
+	forwardedHeader = "X-Shard-Forwarded-By"
+	// nodeHeader names the node that served a forwarded request
+	nodeHeader = "X-Shard-Node"
+)
+
+var shardLog = logger.Component("shard")
+
+// Router sends the requests for a pair to the node owning it. The pairs of
+// the node go on to its own handlers, the others are forwarded.
+type Router struct {
+	self        int
+	nodes       []Node
+	assignments map[string]int
+	ring        ring
+	timeout     time.Duration
+	orders      order.OrderRepo
+	client      *fasthttp.Client
+	relay       fiber.Handler
+}
+
+// NewRouter routes for the node self of nodes. A pair goes to the node
+// assignments gives it, or else to its owner on the hash ring. Cancels
+// without a pair_id are routed by the pair of the order in orders.
+func NewRouter(self string, nodes []Node, assignments map[string]string, timeout time.Duration, orders order.OrderRepo) *Router {
+	r := &Router{
+		nodes:       slices.Clone(nodes),
+		assignments: make(map[string]int, len(assignments)),
+		ring:        newRing(nodes),
+		timeout:     timeout,
+		orders:      orders,
+		client: &fasthttp.Client{
+			NoDefaultUserAgentHeader: true,
+			DisablePathNormalizing:   true,
+		},
+	}
+	r.relay = websocket.New(r.relayStream)
+	for idx, node := range nodes {
+		r.nodes[idx].URL = strings.TrimSuffix(node.URL, "/")
+		if node.ID == self {
+			r.self = idx
+		}
+	}
+	for pairId, nodeId := range assignments {
+		for idx, node := range nodes {
+			if node.ID == nodeId {
+				r.assignments[pairId] = idx
+			}
+		}
+	}
+	return r
+}
+
+// Owner is the node owning the book of pairId
+func (r *Router) Owner(pairId string) Node {
+	return r.nodes[r.owner(pairId)]
+}
+
+// Self is the node the router runs on
+func (r *Router) Self() Node {
+	return r.nodes[r.self]
+}
+
+func (r *Router) Nodes() []Node {
+	return r.nodes
+}
+
+func (r *Router) owner(pairId string) int {
+	if idx, ok := r.assignments[pairId]; ok {
+		return idx
+	}
+	return r.ring.owner(pairId)
+}
+
+// Bind registers the routing ahead of the handlers of the books, with the
+// same paths
+func (r *Router) Bind(app fiber.Router) {
+	app.Post("/add-order", r.routeOrder)
+	app.Post("/quotes", r.routeOrder)
+	app.Delete("/order-book/:id", r.routeCancel)
+	app.Get("/market/:pair_id/*", func(c *fiber.Ctx) error {
+		return r.forward(c, c.Params("pair_id"))
+	})
+	app.Get("/ws/order-book/:pair_id", r.routeStream)
+	app.Get("/ws/market/:pair_id", r.routeStream)
+}
+
+func (r *Router) routeOrder(c *fiber.Ctx) error {
+	var body struct {
+		PairID string `json:"pair_id" form:"pair_id"`
+	}
+	// A body that doesn't parse is left to the handler to report
+	if err := c.BodyParser(&body); err != nil {
+		return c.Next()
+	}
+	return r.forward(c, body.PairID)
+}
+
+func (r *Router) routeCancel(c *fiber.Ctx) error {
+	pairId := c.Query("pair_id")
+	if pairId == "" {
+		orderId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Next()
+		}
+		o, err := r.orders.GetOrderByID(c.UserContext(), orderId)
+		if err != nil {
+			return c.Next()
+		}
+		pairId = o.PairID
+	}
+	return r.forward(c, pairId)
+}
+
+// forward proxies the request to the owner of pairId, or hands it to the
+// handlers of the node when it owns the pair or there is none to route by
+func (r *Router) forward(c *fiber.Ctx, pairId string) error {
+	owner := r.owner(pairId)
+	if pairId == "" || owner == r.self {
+		return c.Next()
+	}
+	if c.Get(forwardedHeader) != "" {
+		return ErrNotOwner
+	}
+	node := r.nodes[owner]
+	c.Request().Header.Set(forwardedHeader, r.nodes[r.self].ID)
+	if err := proxy.DoTimeout(c, node.URL+c.OriginalURL(), r.timeout, r.client); err != nil {
+		shardLog.Error("failed to forward a request to the owner of the pair", map[string]any{
+			"pair_id": pairId,
+			"node":    node.ID,
+			"path":    c.Path(),
+			"error":   err,
+		})
+		return ErrOwnerUnreachable
+	}
+	c.Set(nodeHeader, node.ID)
+	return nil
+}
+
+// routeStream relays the websocket subscriptions to a pair of another node
+// to the same stream on its owner
+func (r *Router) routeStream(c *fiber.Ctx) error {
+	owner := r.owner(c.Params("pair_id"))
+	if owner == r.self {
+		return c.Next()
+	}
+	if c.Get(forwardedHeader) != "" {
+		return ErrNotOwner
+	}
+	c.Locals(targetKey, streamURL(r.nodes[owner].URL)+c.OriginalURL())
+	c.Locals(headerKey, relayHeader(c, r.nodes[r.self].ID))
+	c.Set(nodeHeader, r.nodes[owner].ID)
+	return r.relay(c)
+}
@@ -0,0 +1,90 @@
+package shutdown
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+// closeWriteTimeout bounds writing the close frame to a client that stopped reading
+const closeWriteTimeout = time.Second
+
+// websockets are the open websocket connections. Once closing is set the
+// connections opened during the shutdown are closed right away.
+var websockets = struct {
+	mu      sync.Mutex
+	conns   map[*websocket.Conn]struct{}
+	closing bool
+	code    int
+	reason  string
+}{conns: make(map[*websocket.Conn]struct{})}
+
+// TrackWebsocket records c as open until the returned func is called, so a
+// shutdown can close it. Handlers defer it as they start:
+//
+//	defer shutdown.TrackWebsocket(c)()
+func TrackWebsocket(c *websocket.Conn) (untrack func()) {
+	websockets.mu.Lock()
+	defer websockets.mu.Unlock()
+	if websockets.closing {
+		closeWebsocket(c, websockets.code, websockets.reason)
+		return func() {}
+	}
+	websockets.conns[c] = struct{}{}
+	return func() {
+		websockets.mu.Lock()
+		defer websockets.mu.Unlock()
+		delete(websockets.conns, c)
+	}
+}
+
+// CloseWebsockets sends a close frame with code and reason to every open
+// websocket and closes it, their handlers return as their reads and writes
+// fail. It returns the number of connections closed.
+func CloseWebsockets(code int, reason string) int {
+	websockets.mu.Lock()
+	websockets.closing = true
+	websockets.code, websockets.reason = code, reason
+	conns := make([]*websocket.Conn, 0, len(websockets.conns))
+	for c := range websockets.conns {
+		conns = append(conns, c)
+	}
+	websockets.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, c := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			closeWebsocket(c, code, reason)
+		}()
+	}
+	wg.Wait()
+	return len(conns)
+}
+
+// DropWebsockets closes each open websocket with a probability of share,
+// for the chaos mode. New connections are still accepted. It returns the
+// number of connections closed.
+func DropWebsockets(share float64, code int, reason string) int {
+	websockets.mu.Lock()
+	var conns []*websocket.Conn
+	for c := range websockets.conns {
+		if rand.Float64() < share {
+			conns = append(conns, c)
+		}
+	}
+	websockets.mu.Unlock()
+	for _, c := range conns {
+		go closeWebsocket(c, code, reason)
+	}
+	return len(conns)
+}
+
+func closeWebsocket(c *websocket.Conn, code int, reason string) {
+	frame := websocket.FormatCloseMessage(code, reason)
+	c.WriteControl(websocket.CloseMessage, frame, time.Now().Add(closeWriteTimeout))
+	c.Close()
+}
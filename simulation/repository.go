@@ -0,0 +1,65 @@
+package simulation
+
+import (
+	"context"
+	"order-book/order"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+// orderRepo keeps the orders of a run in memory and numbers them in the
+// order they're created, the history is dropped
+type orderRepo struct {
+	order.OrderRepo
+	mu     sync.Mutex
+	orders map[int]order.Order
+}
+
+func newOrderRepo() *orderRepo {
+	return &orderRepo{orders: make(map[int]order.Order)}
+}
+
+func (repo *orderRepo) CreateOrder(ctx context.Context, pairID string, price decimal.Decimal, amount decimal.Decimal, accountID int, orderType order.OrderType, tag string, strategyId string) (order.Order, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	o := order.Order{
+		ID:         len(repo.orders) + 1,
+		PairID:     pairID,
+		Price:      price,
+		Amount:     amount,
+		AccountID:  accountID,
+		Type:       orderType,
+		Tag:        tag,
+		StrategyID: strategyId,
+	}
+	repo.orders[o.ID] = o
+	return o, nil
+}
+
+func (repo *orderRepo) GetOrderByID(ctx context.Context, id int) (order.Order, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	o, ok := repo.orders[id]
+	if !ok {
+		return o, order.ErrOrderNotFound
+	}
+	return o, nil
+}
+
+func (repo *orderRepo) AddEvent(ctx context.Context, ev order.OrderHistoryEvent) error {
+	return nil
+}
+
+func (repo *orderRepo) AddEvents(ctx context.Context, evs []order.OrderHistoryEvent) error {
+	return nil
+}
+
+// tradeRepo drops the trades, the run hashes them from the listeners
+type tradeRepo struct {
+	order.TradeRepo
+}
+
+func (repo *tradeRepo) AddTrades(ctx context.Context, trades []order.Trade) error {
+	return nil
+}
@@ -0,0 +1,213 @@
+// Package simulation drives a book with a seeded pseudo-random workload
+// under a virtual clock, and hashes the state the book reaches after every
+// step. Two runs with the same seed must hash the same at every step: a
+// difference is nondeterminism in the engine, a map iterated or the wall
+// clock read, which would make the books of replicas drift apart.
+package simulation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"order-book/book"
+	"order-book/fee"
+	"order-book/order"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Options shape the workload. The prices are few ticks apart around a
+// middle, as orders only match at their own price.
+type Options struct {
+	Seed     uint64
+	Steps    int
+	Pairs    []string
+	Accounts int
+	// Levels is the number of prices on each side of the middle
+	Levels int
+	// CancelRatio is the share of the steps cancelling an open order
+	CancelRatio float64
+}
+
+func DefaultOptions(seed uint64) Options {
+	return Options{
+		Seed:        seed,
+		Steps:       5000,
+		Pairs:       []string{"btcusdt", "ethusdt"},
+		Accounts:    8,
+		Levels:      5,
+		CancelRatio: 0.3,
+	}
+}
+
+// Trace is the state hash of a run after each step
+type Trace struct {
+	Seed   uint64
+	Hashes [][sha256.Size]byte
+}
+
+// Diverge returns the first step two traces differ at, -1 if they don't
+func Diverge(a Trace, b Trace) int {
+	for idx := range min(len(a.Hashes), len(b.Hashes)) {
+		if a.Hashes[idx] != b.Hashes[idx] {
+			return idx
+		}
+	}
+	if len(a.Hashes) != len(b.Hashes) {
+		return min(len(a.Hashes), len(b.Hashes))
+	}
+	return -1
+}
+
+// Clock is the virtual clock of a run, it only moves when advanced
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// step is what a step did and left, the hash covers it whole
+type step struct {
+	Op        string               `json:"op"`
+	Order     order.Order          `json:"order"`
+	Error     string               `json:"error,omitempty"`
+	Trades    []order.Trade        `json:"trades"`
+	Events    []order.OrderEvent   `json:"events"`
+	Changes   []book.Change        `json:"changes"`
+	Snapshots []order.BookSnapshot `json:"snapshots"`
+	Seq       int64                `json:"seq"`
+}
+
+// Run plays the workload of opts on a new book and returns its trace
+func Run(opts Options) (Trace, error) {
+	rng := rand.New(rand.NewPCG(opts.Seed, opts.Seed))
+	clock := NewClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	orders := newOrderRepo()
+	b := book.NewBook(orders, &tradeRepo{}, 1, fee.Schedule{Default: fee.Rates{Maker: 0.001, Taker: 0.002}})
+	defer b.Drain(context.Background())
+	impl, ok := b.(*book.BookImpl)
+	if !ok {
+		return Trace{}, fmt.Errorf("the book isn't a *book.BookImpl")
+	}
+	impl.SetClock(clock.Now)
+
+	// The listeners are called by the engine before a step returns
+	var mu sync.Mutex
+	var current step
+	b.OnTrade(func(t order.Trade) {
+		mu.Lock()
+		defer mu.Unlock()
+		current.Trades = append(current.Trades, t)
+	})
+	b.OnOrderEvent(func(ev order.OrderEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		current.Events = append(current.Events, ev)
+	})
+	b.OnChange(func(ch book.Change) {
+		mu.Lock()
+		defer mu.Unlock()
+		current.Changes = append(current.Changes, ch)
+	})
+
+	ctx := context.Background()
+	trace := Trace{Seed: opts.Seed, Hashes: make([][sha256.Size]byte, 0, opts.Steps)}
+	var open []int
+	// Trade IDs are shared by every book of the process, they're hashed
+	// relative to the first of the run
+	var firstTradeId int64
+	var prev [sha256.Size]byte
+	for range opts.Steps {
+		clock.Advance(time.Duration(1+rng.IntN(1000)) * time.Millisecond)
+		mu.Lock()
+		current = step{}
+		mu.Unlock()
+
+		var op string
+		var o order.Order
+		var err error
+		if len(open) > 0 && rng.Float64() < opts.CancelRatio {
+			op = "cancel"
+			idx := rng.IntN(len(open))
+			o.ID = open[idx]
+			open = append(open[:idx], open[idx+1:]...)
+			// An order filled meanwhile is gone already
+			if err = b.CancellOrder(ctx, o.ID); err == book.ErrOrderNotFound {
+				err = nil
+			}
+		} else {
+			op = "add"
+			o = randomOrder(rng, opts, clock.Now())
+			var timings book.StageTimings
+			timings, err = b.AddOrderWithTimings(ctx, o)
+			if err == nil {
+				o.ID = timings.OrderID
+				open = append(open, o.ID)
+			}
+		}
+
+		mu.Lock()
+		s := current
+		mu.Unlock()
+		s.Op, s.Order = op, o
+		if err != nil {
+			s.Error = err.Error()
+		}
+		for idx := range s.Trades {
+			if firstTradeId == 0 {
+				firstTradeId = s.Trades[idx].ID
+			}
+			s.Trades[idx].ID -= firstTradeId
+		}
+		for _, pairId := range opts.Pairs {
+			s.Snapshots = append(s.Snapshots, b.Snapshot(pairId))
+		}
+		s.Seq = b.Seq()
+
+		data, err := json.Marshal(s)
+		if err != nil {
+			return trace, err
+		}
+		prev = sha256.Sum256(append(prev[:], data...))
+		trace.Hashes = append(trace.Hashes, prev)
+	}
+	return trace, nil
+}
+
+func randomOrder(rng *rand.Rand, opts Options, now time.Time) order.Order {
+	o := order.Order{
+		PairID:    opts.Pairs[rng.IntN(len(opts.Pairs))],
+		AccountID: 1 + rng.IntN(opts.Accounts),
+		Type:      order.OrderType(rng.IntN(2)),
+		Amount:    decimal.New(int64(1+rng.IntN(20)), -2),
+		CreatedAt: now,
+	}
+	// Bids below the middle and asks above it mostly, the overlap trades
+	offset := rng.IntN(2*opts.Levels+1) - opts.Levels
+	if o.Type == order.BID {
+		offset -= opts.Levels / 2
+	} else {
+		offset += opts.Levels / 2
+	}
+	o.Price = decimal.NewFromInt(int64(1000 + offset))
+	return o
+}
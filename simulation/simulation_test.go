@@ -0,0 +1,34 @@
+package simulation
+
+import (
+	"flag"
+	"order-book/logger"
+	"testing"
+)
+
+var (
+	seed  = flag.Uint64("seed", 1, "seed of the first run, the following ones use the next seeds")
+	seeds = flag.Int("seeds", 3, "number of seeds to run")
+	steps = flag.Int("steps", 2000, "steps of each run")
+)
+
+// TestDeterminism runs every seed twice, the runs must reach the same state
+// at every step
+func TestDeterminism(t *testing.T) {
+	logger.SetLevel(logger.ErrorLevel)
+	for s := *seed; s < *seed+uint64(*seeds); s++ {
+		opts := DefaultOptions(s)
+		opts.Steps = *steps
+		first, err := Run(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := Run(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if step := Diverge(first, second); step >= 0 {
+			t.Fatalf("seed %d: the runs diverge at step %d, rerun with -seed %d -seeds 1", s, step, s)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package standby
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"order-book/book"
+	"order-book/logger"
+	"order-book/metrics"
+	"order-book/panics"
+	"order-book/shutdown"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// heartbeatInterval keeps the stream of a quiet primary alive, well within
+// liveAfter
+const heartbeatInterval = time.Second
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindStreamRouter serves GET /admin/standby/stream to the standbys holding
+// token, the route is disabled when no token is configured
+func BindStreamRouter(r fiber.Router, books *book.Registry, journal *Journal, token string) {
+	r.Get(streamPath, func(c *fiber.Ctx) error {
+		provided := c.Get(tokenHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.Status(http.StatusUnauthorized)
+			return c.JSON(&Response{
+				Message: "Invalid standby token",
+				Data:    nil,
+			})
+		}
+		return c.Next()
+	}, websocket.New(func(c *websocket.Conn) {
+		defer panics.Recover("ws", map[string]any{"stream": "standby"})
+		defer shutdown.TrackWebsocket(c)()
+		defer c.Close()
+		stream := metrics.Stream("standby")
+		stream.Connected()
+		reason := metrics.DisconnectClientClosed
+		defer func() { stream.Disconnected(reason) }()
+		log := logger.Component("ws").With(map[string]any{
+			"stream": "standby",
+			"remote": c.RemoteAddr().String(),
+		})
+
+		// Subscribing first so no change is missed between the snapshots and
+		// the stream, the changes the snapshots already hold are skipped
+		changes := journal.Subscribe()
+		defer journal.Unsubscribe(changes)
+		stream.Subscribed()
+		defer stream.Unsubscribed()
+
+		snapshotSeqs := make(map[string]map[string]int64)
+		for tenantId, snaps := range snapshots(books) {
+			snapshotSeqs[tenantId] = make(map[string]int64, len(snaps))
+			for _, snap := range snaps {
+				snapshotSeqs[tenantId][snap.PairID] = snap.Seq
+				if err := c.WriteJSON(&Message{Type: SNAPSHOT, Tenant: tenantId, Snapshot: &snap, At: snap.CreatedAt}); err != nil {
+					reason = metrics.DisconnectWriteError
+					return
+				}
+				stream.Sent()
+			}
+		}
+		if err := c.WriteJSON(&Message{Type: SYNCED, Seq: journal.Seq(), At: time.Now()}); err != nil {
+			reason = metrics.DisconnectWriteError
+			return
+		}
+		log.Info("standby synced", map[string]any{
+			"tenants": len(snapshotSeqs),
+		})
+
+		// The stream is read-only, reading only detects the standby going away
+		closed := make(chan struct{})
+		go func() {
+			defer panics.Recover("ws", map[string]any{"stream": "standby"})
+			defer close(closed)
+			for {
+				if _, _, err := c.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case msg, ok := <-changes:
+				if !ok {
+					reason = metrics.DisconnectSlowConsumer
+					return
+				}
+				if msg.Change.Seq <= snapshotSeqs[msg.Tenant][msg.Change.PairID] {
+					continue
+				}
+				if err := c.WriteJSON(&msg); err != nil {
+					reason = metrics.DisconnectWriteError
+					return
+				}
+				stream.Sent()
+			case t := <-ticker.C:
+				if err := c.WriteJSON(&Message{Type: HEARTBEAT, At: t}); err != nil {
+					reason = metrics.DisconnectWriteError
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	}))
+}
+
+// BindFollowerRouter serves GET /admin/standby, the replication state of the
+// standby, and POST /admin/standby/promote?force=true to make it the primary
+func BindFollowerRouter(r fiber.Router, follower *Follower) {
+	r.Get("/admin/standby", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    follower.Status(),
+		})
+	})
+
+	r.Post("/admin/standby/promote", func(c *fiber.Ctx) error {
+		if err := follower.Promote(c.QueryBool("force")); err != nil {
+			c.Status(http.StatusConflict)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    follower.Status(),
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Promoted to primary",
+			Data:    follower.Status(),
+		})
+	})
+}
@@ -0,0 +1,250 @@
+package standby
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"order-book/book"
+	"order-book/logger"
+	"sync"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	ErrStandby          = errors.New("This node is a standby, writes go to the primary")
+	ErrAlreadyPrimary   = errors.New("This node is already the primary")
+	ErrPrimaryReachable = errors.New("The primary is still streaming, stop it first or force the promotion")
+)
+
+// Roles of a node
+const (
+	ROLE_PRIMARY = "primary"
+	ROLE_STANDBY = "standby"
+)
+
+const (
+	// liveAfter is how long after the last message the primary still counts
+	// as streaming, a promotion is refused meanwhile unless forced
+	liveAfter     = 3 * time.Second
+	maxRetryDelay = 10 * time.Second
+	dialTimeout   = 5 * time.Second
+	tokenHeader   = "X-Standby-Token"
+	streamPath    = "/admin/standby/stream"
+)
+
+// Status is the replication state of a standby for operators. Lag is how far
+// behind the primary the last applied message was when it arrived, and
+// LastMessageAt tells how long the primary has been silent.
+type Status struct {
+	Role          string    `json:"role"`
+	Primary       string    `json:"primary"`
+	Connected     bool      `json:"connected"`
+	Synced        bool      `json:"synced"`
+	Seq           int64     `json:"seq"`
+	Lag           string    `json:"lag"`
+	LastMessageAt time.Time `json:"last_message_at"`
+	Reconnects    int       `json:"reconnects"`
+	PromotedAt    time.Time `json:"promoted_at,omitzero"`
+}
+
+// Follower keeps the books of a warm standby in sync with those of the
+// primary, from the snapshots and changes the primary streams. The standby
+// serves reads from them and refuses writes until it's promoted, it then
+// stops following and takes orders on the books as they were.
+//
+// Recovery point: the changes the primary made but didn't stream before it
+// failed, usually those of the last few milliseconds, plus the orders still
+// queued for its engine. Recovery time: the promotion is immediate, clients
+// resume once they're routed to the standby.
+type Follower struct {
+	books      *book.Registry
+	primaryURL string
+	token      string
+	cancel     context.CancelFunc
+
+	mu        sync.Mutex
+	status    Status
+	onPromote []func()
+	lag       time.Duration
+}
+
+// NewFollower follows the primary whose admin listener is at primaryURL,
+// like ws://primary:6060
+func NewFollower(books *book.Registry, primaryURL string, token string) *Follower {
+	return &Follower{
+		books:      books,
+		primaryURL: primaryURL,
+		token:      token,
+		status:     Status{Role: ROLE_STANDBY, Primary: primaryURL},
+	}
+}
+
+// OnPromote registers a hook run once the standby is promoted, like starting
+// consumers that only the primary runs
+func (f *Follower) OnPromote(fn func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.onPromote = append(f.onPromote, fn)
+}
+
+// Run follows the primary until ctx is done or the standby is promoted,
+// reconnecting with a growing delay after a failure
+func (f *Follower) Run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	f.mu.Lock()
+	if f.status.Role == ROLE_PRIMARY {
+		f.mu.Unlock()
+		cancel()
+		return
+	}
+	f.cancel = cancel
+	f.mu.Unlock()
+	defer cancel()
+
+	delay := time.Second
+	for {
+		synced, err := f.follow(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Warn("lost the stream of the primary", map[string]any{
+			"primary": f.primaryURL,
+			"error":   err,
+		})
+		f.mu.Lock()
+		f.status.Connected, f.status.Synced = false, false
+		f.status.Reconnects++
+		f.mu.Unlock()
+		if synced {
+			delay = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxRetryDelay)
+	}
+}
+
+// follow streams from the primary until the connection fails, synced
+// reports whether it got as far as the changes
+func (f *Follower) follow(ctx context.Context) (synced bool, err error) {
+	dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+	conn, _, err := websocket.DefaultDialer.DialContext(dialCtx, f.primaryURL+streamPath, http.Header{
+		tokenHeader: []string{f.token},
+	})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	// The connection is closed once ctx is done to end the read
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	f.mu.Lock()
+	f.status.Connected = true
+	f.mu.Unlock()
+	logger.Info("following the primary", map[string]any{
+		"primary": f.primaryURL,
+	})
+
+	for {
+		var msg Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			return synced, err
+		}
+		// A promotion may race with a message already read
+		if f.Promoted() {
+			return synced, ErrAlreadyPrimary
+		}
+		switch msg.Type {
+		case SNAPSHOT:
+			f.books.Get(msg.Tenant).Restore(*msg.Snapshot)
+		case SYNCED:
+			synced = true
+			logger.Info("synced with the primary", map[string]any{
+				"primary": f.primaryURL,
+				"seq":     msg.Seq,
+			})
+		case CHANGE:
+			f.books.Get(msg.Tenant).ApplyChange(*msg.Change)
+		}
+		f.mu.Lock()
+		f.status.Synced = synced
+		if msg.Seq > 0 {
+			f.status.Seq = msg.Seq
+		}
+		f.status.LastMessageAt = time.Now()
+		f.lag = max(time.Since(msg.At), 0)
+		f.mu.Unlock()
+	}
+}
+
+// Promoted reports whether the node takes writes
+func (f *Follower) Promoted() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status.Role == ROLE_PRIMARY
+}
+
+func (f *Follower) Status() Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status := f.status
+	status.Lag = f.lag.String()
+	return status
+}
+
+// Promote stops following and makes the node the primary. While the primary
+// still streams it's refused unless force is set, two primaries would take
+// orders on diverging books.
+func (f *Follower) Promote(force bool) error {
+	f.mu.Lock()
+	if f.status.Role == ROLE_PRIMARY {
+		f.mu.Unlock()
+		return ErrAlreadyPrimary
+	}
+	if !force && f.status.Connected && time.Since(f.status.LastMessageAt) < liveAfter {
+		f.mu.Unlock()
+		return ErrPrimaryReachable
+	}
+	f.status.Role = ROLE_PRIMARY
+	f.status.Connected, f.status.Synced = false, false
+	f.status.PromotedAt = time.Now()
+	if f.cancel != nil {
+		f.cancel()
+	}
+	hooks := f.onPromote
+	status := f.status
+	f.mu.Unlock()
+
+	logger.Warn("promoted to primary", map[string]any{
+		"primary": f.primaryURL,
+		"seq":     status.Seq,
+		"forced":  force,
+	})
+	for _, fn := range hooks {
+		fn()
+	}
+	return nil
+}
+
+// Middleware refuses the writes of the public API until the standby is
+// promoted, reads are served from the followed books
+func (f *Follower) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			return c.Next()
+		}
+		if f.Promoted() {
+			return c.Next()
+		}
+		return ErrStandby
+	}
+}
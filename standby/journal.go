@@ -0,0 +1,116 @@
+package standby
+
+import (
+	"order-book/book"
+	"order-book/logger"
+	"order-book/metrics"
+	"order-book/order"
+	"sync"
+	"time"
+)
+
+// Types of the messages of the standby stream
+const (
+	SNAPSHOT  = "SNAPSHOT"
+	SYNCED    = "SYNCED"
+	CHANGE    = "CHANGE"
+	HEARTBEAT = "HEARTBEAT"
+)
+
+// subscriberBuffer is the number of changes a standby can fall behind before
+// it's disconnected, it then starts over from new snapshots
+const subscriberBuffer = 4096
+
+// Message is sent by the primary to its standbys. A stream starts with a
+// SNAPSHOT of every pair, then SYNCED, then the CHANGE of every engine event
+// and a HEARTBEAT every so often. Seq numbers the changes of the journal
+// without gaps.
+type Message struct {
+	Type     string              `json:"type"`
+	Seq      int64               `json:"seq,omitempty"`
+	Tenant   string              `json:"tenant,omitempty"`
+	Snapshot *order.BookSnapshot `json:"snapshot,omitempty"`
+	Change   *book.Change        `json:"change,omitempty"`
+	At       time.Time           `json:"at"`
+}
+
+// Journal numbers the changes the engines of the primary make to their books
+// and streams them to the standbys
+type Journal struct {
+	mu          sync.Mutex
+	seq         int64
+	subscribers map[chan Message]struct{}
+}
+
+func NewJournal() *Journal {
+	return &Journal{subscribers: make(map[chan Message]struct{})}
+}
+
+// Attach journals the changes of the book of a tenant, it is meant to be run
+// from a Registry.OnBook hook
+func (j *Journal) Attach(tenantId string, b book.Book) {
+	b.OnChange(func(ch book.Change) {
+		j.publish(tenantId, ch)
+	})
+}
+
+// Seq is the number of changes journaled so far
+func (j *Journal) Seq() int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.seq
+}
+
+// publish runs with the book locked, a standby that can't keep up is
+// disconnected instead of slowing the engine down
+func (j *Journal) publish(tenantId string, ch book.Change) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.seq++
+	msg := Message{Type: CHANGE, Seq: j.seq, Tenant: tenantId, Change: &ch, At: ch.At}
+	for sub := range j.subscribers {
+		select {
+		case sub <- msg:
+		default:
+			logger.Warn("standby is too slow, disconnecting", map[string]any{
+				"seq": j.seq,
+			})
+			metrics.Stream("standby").Overflowed()
+			delete(j.subscribers, sub)
+			close(sub)
+		}
+	}
+}
+
+// Subscribe returns a channel of the changes journaled from now on, it is
+// closed if the standby falls behind
+func (j *Journal) Subscribe() chan Message {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	sub := make(chan Message, subscriberBuffer)
+	j.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (j *Journal) Unsubscribe(sub chan Message) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.subscribers[sub]; ok {
+		delete(j.subscribers, sub)
+		close(sub)
+	}
+}
+
+// snapshots returns the snapshot of every pair of every book, the changes
+// with a Seq up to that of the snapshot of their pair are part of it
+func snapshots(books *book.Registry) map[string][]order.BookSnapshot {
+	snaps := make(map[string][]order.BookSnapshot)
+	for tenantId, b := range books.Books() {
+		for _, pairId := range b.Pairs() {
+			snap := b.Snapshot(pairId)
+			snap.TenantID = tenantId
+			snaps[tenantId] = append(snaps[tenantId], snap)
+		}
+	}
+	return snaps
+}
@@ -0,0 +1,119 @@
+package stp
+
+import (
+	"context"
+	"net/http"
+	"order-book/logger"
+	"order-book/order"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+type EventWriter interface {
+	AddEvent(ctx context.Context, ev order.OrderHistoryEvent) error
+}
+
+type setRequest struct {
+	AccountIDs []int `json:"account_ids"`
+}
+
+// BindSTPRouter serves the STP groups on GET /admin/stp-groups, sets the
+// accounts of one on PUT /admin/stp-groups/:name and dissolves it on DELETE
+// /admin/stp-groups/:name. A change holds until the config file changes the
+// same group, each is recorded as a CONFIG_CHANGED event.
+func BindSTPRouter(r fiber.Router, groups *Groups, events EventWriter) {
+	r.Get("/admin/stp-groups", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    groups.All(),
+		})
+	})
+
+	r.Put("/admin/stp-groups/:name", func(c *fiber.Ctx) error {
+		name := strings.Clone(c.Params("name"))
+		var req setRequest
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		before, existed, err := groups.Set(name, req.AccountIDs)
+		switch err {
+		case nil:
+		case ErrEmptyGroup:
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		case ErrAccountInGroup:
+			c.Status(http.StatusConflict)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		default:
+			return err
+		}
+		record(c, events, name, before, existed, req.AccountIDs)
+
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "STP group updated",
+			Data:    groups.All()[name],
+		})
+	})
+
+	r.Delete("/admin/stp-groups/:name", func(c *fiber.Ctx) error {
+		name := strings.Clone(c.Params("name"))
+		before, existed := groups.Remove(name)
+		if !existed {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "There is no STP group with this name",
+				Data:    nil,
+			})
+		}
+		record(c, events, name, before, existed, nil)
+
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "STP group removed",
+			Data:    nil,
+		})
+	})
+}
+
+// record logs a change of a group and adds its audit event
+func record(c *fiber.Ctx, events EventWriter, name string, before []int, existed bool, after []int) {
+	metadata := map[string]any{
+		"setting": "stp.groups." + name,
+		"before":  nil,
+		"after":   nil,
+		"source":  "admin",
+		"actor":   order.ActorOf(c.UserContext(), 0),
+	}
+	if existed {
+		metadata["before"] = before
+	}
+	if after != nil {
+		metadata["after"] = after
+	}
+	logger.Info("setting changed", metadata)
+	err := events.AddEvent(context.WithoutCancel(c.UserContext()), order.OrderHistoryEvent{
+		Name:     order.CONFIG_CHANGED,
+		Metadata: metadata,
+	})
+	if err != nil {
+		logger.Error("failed to add the audit event of a setting change", map[string]any{
+			"setting": "stp.groups." + name,
+			"error":   err,
+		})
+	}
+}
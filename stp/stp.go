@@ -0,0 +1,85 @@
+// Package stp holds the self-trade prevention groups. The orders of the
+// accounts of a group, like the sub-accounts of one institution, never match
+// each other on the books, as if they were those of a single account.
+package stp
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+)
+
+var (
+	ErrEmptyGroup     = errors.New("A group needs at least one account")
+	ErrAccountInGroup = errors.New("An account is already in another group")
+)
+
+// Groups maps the STP groups to their accounts, an account is in one group
+// at most. They're changed while the engine runs by the config file or the
+// admin API.
+type Groups struct {
+	mu       sync.RWMutex
+	groups   map[string][]int
+	accounts map[int]string
+}
+
+func NewGroups(groups map[string][]int) *Groups {
+	g := &Groups{
+		groups:   make(map[string][]int, len(groups)),
+		accounts: make(map[int]string),
+	}
+	for name, accounts := range groups {
+		g.Set(name, accounts)
+	}
+	return g
+}
+
+// GroupOf is the group of an account, empty if it's in none
+func (g *Groups) GroupOf(accountId int) string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.accounts[accountId]
+}
+
+// Set replaces the accounts of a group and returns those it had
+func (g *Groups) Set(name string, accounts []int) (before []int, existed bool, err error) {
+	if len(accounts) == 0 {
+		return nil, false, ErrEmptyGroup
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, accountId := range accounts {
+		if group, ok := g.accounts[accountId]; ok && group != name {
+			return nil, false, ErrAccountInGroup
+		}
+	}
+	before, existed = g.groups[name]
+	for _, accountId := range before {
+		delete(g.accounts, accountId)
+	}
+	accounts = slices.Compact(slices.Sorted(slices.Values(accounts)))
+	for _, accountId := range accounts {
+		g.accounts[accountId] = name
+	}
+	g.groups[name] = accounts
+	return before, existed, nil
+}
+
+// Remove dissolves a group and returns its accounts
+func (g *Groups) Remove(name string) (before []int, existed bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	before, existed = g.groups[name]
+	for _, accountId := range before {
+		delete(g.accounts, accountId)
+	}
+	delete(g.groups, name)
+	return before, existed
+}
+
+func (g *Groups) All() map[string][]int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return maps.Clone(g.groups)
+}
@@ -0,0 +1,273 @@
+package subaccount
+
+import (
+	"errors"
+	"net/http"
+	"order-book/account"
+	"order-book/logger"
+	"order-book/order"
+	"order-book/tenant"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrFieldRequired = errors.New("ErrFieldRequired")
+	ErrInvalidData   = errors.New("ErrInvalidData")
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+type transferRequest struct {
+	ToAccountID int             `json:"to_account_id"`
+	Asset       string          `json:"asset"`
+	Amount      decimal.Decimal `json:"amount"`
+	Reference   string          `json:"reference"`
+}
+
+// BindSubAccountRouter serves the sub-accounts of a master account, their
+// limits and API keys, the transfers between them and their consolidated
+// report. An API key bound to a master reaches its sub-accounts, one bound to
+// a sub-account only reaches that one, so only the master sets the limits.
+func BindSubAccountRouter(r fiber.Router, dir *tenant.Directory, service *Service) {
+	r.Post("/accounts/:id/sub-accounts", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return invalidAccount(c)
+		}
+		sub, err := dir.CreateSubAccount(accountId)
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusCreated)
+		return c.JSON(&Response{
+			Message: "Sub-account created",
+			Data:    sub,
+		})
+	})
+
+	r.Get("/accounts/:id/sub-accounts", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return invalidAccount(c)
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    dir.SubAccounts(accountId),
+		})
+	})
+
+	r.Put("/accounts/:id/sub-accounts/:sub_id/limits", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return invalidAccount(c)
+		}
+		subId, err := strconv.Atoi(c.Params("sub_id"))
+		if err != nil {
+			return invalidAccount(c)
+		}
+		if sub, ok := dir.GetAccount(subId); !ok || sub.MasterID != accountId {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The sub-account not found",
+				Data:    nil,
+			})
+		}
+		var req tenant.Limits
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		if req.MaxOpenOrders < 0 || req.MaxOrdersPerSecond < 0 {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrInvalidData,
+				Message: "Limits can't be negative",
+			})
+		}
+		sub, err := dir.SetLimits(subId, req)
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Limits updated",
+			Data:    sub,
+		})
+	})
+
+	r.Get("/accounts/:id/api-keys", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return invalidAccount(c)
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    dir.AccountAPIKeys(accountId),
+		})
+	})
+
+	r.Post("/accounts/:id/api-keys", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return invalidAccount(c)
+		}
+		apiKey, key, err := dir.CreateAPIKey(dir.TenantOf(accountId), accountId)
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusCreated)
+		return c.JSON(&Response{
+			Message: "Store the key now, it can't be retrieved again",
+			Data: map[string]any{
+				"api_key": apiKey,
+				"key":     key,
+			},
+		})
+	})
+
+	r.Delete("/accounts/:id/api-keys/:key_id", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return invalidAccount(c)
+		}
+		keyId, err := strconv.ParseInt(c.Params("key_id"), 10, 64)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid ID",
+				Data:    nil,
+			})
+		}
+		apiKey, err := dir.RevokeAccountAPIKey(accountId, keyId)
+		if err == tenant.ErrAPIKeyNotFound {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The API key not found",
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "API key revoked",
+			Data:    apiKey,
+		})
+	})
+
+	r.Post("/accounts/:id/transfers", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return invalidAccount(c)
+		}
+		idempotencyKey := c.Get("Idempotency-Key")
+		if idempotencyKey == "" {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrFieldRequired,
+				Message: "Idempotency-Key header is required",
+			})
+		}
+		var req transferRequest
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		if req.Asset == "" || req.ToAccountID == 0 {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrFieldRequired,
+				Message: "Please provide a to_account_id and an asset",
+			})
+		}
+		if !req.Amount.IsPositive() {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrInvalidData,
+				Message: "Amount should be a positive number",
+			})
+		}
+		if err := order.ValidateAmount(req.Asset, req.Amount); err != nil {
+			c.Status(http.StatusUnprocessableEntity)
+			return c.JSON(&Response{
+				Error:   err,
+				Message: err.Error(),
+			})
+		}
+
+		created, replayed, err := service.Transfer(TransferRequest{
+			FromAccountID:  accountId,
+			ToAccountID:    req.ToAccountID,
+			Asset:          req.Asset,
+			Amount:         req.Amount,
+			IdempotencyKey: idempotencyKey,
+			Reference:      req.Reference,
+		})
+		if err == ErrNotRelated {
+			c.Status(http.StatusUnprocessableEntity)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		if err == account.ErrInsufficientBalance || err == account.ErrIdempotencyKeyReused {
+			return err
+		}
+		if err != nil {
+			logger.Error("failed to transfer between sub-accounts", map[string]any{
+				"from_account_id": accountId,
+				"to_account_id":   req.ToAccountID,
+				"asset":           req.Asset,
+				"idempotency_key": idempotencyKey,
+				"error":           err,
+			})
+			return err
+		}
+
+		if replayed {
+			c.Status(http.StatusOK)
+		} else {
+			c.Status(http.StatusCreated)
+		}
+		return c.JSON(&Response{
+			Message: "Funds transferred",
+			Data:    created,
+		})
+	})
+
+	r.Get("/accounts/:id/consolidated", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return invalidAccount(c)
+		}
+		report, err := service.Consolidate(accountId)
+		if err != nil {
+			logger.Error("failed to consolidate sub-accounts", map[string]any{
+				"account_id": accountId,
+				"error":      err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    report,
+		})
+	})
+}
+
+func invalidAccount(c *fiber.Ctx) error {
+	c.Status(http.StatusBadRequest)
+	return c.JSON(&Response{
+		Message: "Invalid account ID",
+		Data:    nil,
+	})
+}
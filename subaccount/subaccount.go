@@ -0,0 +1,168 @@
+// Package subaccount moves funds between a master account and its
+// sub-accounts and reports them together. Transfers are instant ledger
+// transactions, the books aren't involved.
+package subaccount
+
+import (
+	"errors"
+	"maps"
+	"order-book/account"
+	"order-book/logger"
+	"order-book/order"
+	"order-book/position"
+	"order-book/tenant"
+	"slices"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrNotRelated = errors.New("Funds only move between a master account and its sub-accounts")
+
+var subaccountLog = logger.Component("subaccount")
+
+// Orders are the resting orders of the accounts on the books of their tenant
+type Orders interface {
+	GetAccountOrders(accountId int) []order.Order
+}
+
+// TransferRequest moves Amount of Asset from an account to another of its
+// family, the IdempotencyKey makes a retry return the first transfer
+type TransferRequest struct {
+	FromAccountID  int
+	ToAccountID    int
+	Asset          string
+	Amount         decimal.Decimal
+	IdempotencyKey string
+	Reference      string
+}
+
+// AssetBalance is the balance of an asset summed over the accounts holding it
+type AssetBalance struct {
+	Asset     string            `json:"asset"`
+	Available decimal.Decimal   `json:"available"`
+	Held      decimal.Decimal   `json:"held"`
+	Accounts  []account.Balance `json:"accounts"`
+}
+
+// NetPosition is the position on a pair summed over the accounts holding one
+type NetPosition struct {
+	PairID        string              `json:"pair_id"`
+	Quantity      decimal.Decimal     `json:"quantity"`
+	RealizedPnL   decimal.Decimal     `json:"realized_pnl"`
+	UnrealizedPnL decimal.Decimal     `json:"unrealized_pnl"`
+	Accounts      []position.Position `json:"accounts"`
+}
+
+// Report is an account together with its sub-accounts
+type Report struct {
+	AccountID   int            `json:"account_id"`
+	SubAccounts []int          `json:"sub_accounts"`
+	Balances    []AssetBalance `json:"balances"`
+	Positions   []NetPosition  `json:"positions"`
+	OpenOrders  []order.Order  `json:"open_orders"`
+}
+
+type Service struct {
+	dir       *tenant.Directory
+	balances  account.BalanceRepo
+	positions *position.Tracker
+	orders    Orders
+}
+
+func NewService(dir *tenant.Directory, balances account.BalanceRepo, positions *position.Tracker, orders Orders) *Service {
+	return &Service{
+		dir:       dir,
+		balances:  balances,
+		positions: positions,
+		orders:    orders,
+	}
+}
+
+// Transfer posts a TRANSFER between two accounts of the same family
+func (s *Service) Transfer(req TransferRequest) (account.Transaction, bool, error) {
+	if !s.dir.Related(req.FromAccountID, req.ToAccountID) {
+		return account.Transaction{}, false, ErrNotRelated
+	}
+	created, replayed, err := s.balances.Transfer(account.Transfer{
+		FromAccountID:  req.FromAccountID,
+		ToAccountID:    req.ToAccountID,
+		Asset:          req.Asset,
+		Amount:         req.Amount,
+		IdempotencyKey: req.IdempotencyKey,
+		Source:         account.ACCOUNT,
+		Reference:      req.Reference,
+	})
+	if err != nil {
+		return account.Transaction{}, false, err
+	}
+	if !replayed {
+		subaccountLog.Info("funds transferred", map[string]any{
+			"from_account_id": req.FromAccountID,
+			"to_account_id":   req.ToAccountID,
+			"asset":           req.Asset,
+			"amount":          req.Amount,
+		})
+	}
+	return created, replayed, nil
+}
+
+// Consolidate reports the balances, positions and open orders of an account
+// and its sub-accounts
+func (s *Service) Consolidate(accountId int) (Report, error) {
+	family := s.dir.Family(accountId)
+	r := Report{
+		AccountID:   accountId,
+		SubAccounts: family[1:],
+		Balances:    []AssetBalance{},
+		Positions:   []NetPosition{},
+		OpenOrders:  []order.Order{},
+	}
+
+	balances := make(map[string]*AssetBalance)
+	positions := make(map[string]*NetPosition)
+	for _, id := range family {
+		accountBalances, err := s.balances.GetBalances(id)
+		if err != nil {
+			return Report{}, err
+		}
+		for _, b := range accountBalances {
+			asset := strings.ToLower(b.Asset)
+			total, ok := balances[asset]
+			if !ok {
+				total = &AssetBalance{Asset: asset}
+				balances[asset] = total
+			}
+			total.Available = total.Available.Add(b.Available)
+			total.Held = total.Held.Add(b.Held)
+			total.Accounts = append(total.Accounts, b)
+		}
+
+		for _, p := range s.positions.GetPositions(id) {
+			net, ok := positions[p.PairID]
+			if !ok {
+				net = &NetPosition{PairID: p.PairID}
+				positions[p.PairID] = net
+			}
+			net.Quantity = net.Quantity.Add(p.Quantity)
+			net.RealizedPnL = net.RealizedPnL.Add(p.RealizedPnL)
+			net.UnrealizedPnL = net.UnrealizedPnL.Add(p.UnrealizedPnL)
+			net.Accounts = append(net.Accounts, p)
+		}
+
+		r.OpenOrders = append(r.OpenOrders, s.orders.GetAccountOrders(id)...)
+	}
+
+	for _, asset := range slices.Sorted(maps.Keys(balances)) {
+		b := *balances[asset]
+		if a, ok := order.GetAsset(asset); ok {
+			b.Available = a.Round(b.Available)
+			b.Held = a.Round(b.Held)
+		}
+		r.Balances = append(r.Balances, b)
+	}
+	for _, pairId := range slices.Sorted(maps.Keys(positions)) {
+		r.Positions = append(r.Positions, *positions[pairId])
+	}
+	return r, nil
+}
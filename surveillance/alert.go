@@ -0,0 +1,182 @@
+package surveillance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	repository "order-book/surveillance/repository/gen"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrAlertNotFound        = errors.New("Alert not found")
+	ErrAlertAlreadyReviewed = errors.New("Alert was already reviewed")
+)
+
+type AlertType string
+
+const (
+	WASH_TRADE AlertType = "WASH_TRADE"
+	SPOOFING   AlertType = "SPOOFING"
+)
+
+type AlertStatus string
+
+const (
+	OPEN      AlertStatus = "OPEN"
+	DISMISSED AlertStatus = "DISMISSED"
+	ESCALATED AlertStatus = "ESCALATED"
+)
+
+type Alert struct {
+	ID         int64           `json:"id"`
+	Type       AlertType       `json:"type"`
+	PairID     string          `json:"pair_id"`
+	Status     AlertStatus     `json:"status"`
+	Score      float64         `json:"score"`
+	Details    json.RawMessage `json:"details"`
+	ReviewedBy string          `json:"reviewed_by,omitempty"`
+	ReviewNote string          `json:"review_note,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ReviewedAt *time.Time      `json:"reviewed_at,omitempty"`
+}
+
+type AlertRepo interface {
+	// CreateAlert persists an alert, score goes from 0 to 1 and orders the review queue
+	CreateAlert(alertType AlertType, pairId string, score float64, details any) (Alert, error)
+	// UpdateDetails replaces the details of an alert that is still open, ok is
+	// false if the alert was reviewed in the meantime.
+	UpdateDetails(id int64, score float64, details any) (ok bool, err error)
+	GetAlert(id int64) (Alert, error)
+	GetAlerts(status AlertStatus, page int, size int) ([]Alert, error)
+	Review(id int64, status AlertStatus, reviewer string, note string) (Alert, error)
+	GetBeneficialOwners() (map[int]string, error)
+	SetBeneficialOwner(accountId int, ownerId string) error
+}
+
+type alertRepo struct {
+	queries *repository.Queries
+}
+
+func (repo *alertRepo) CreateAlert(alertType AlertType, pairId string, score float64, details any) (Alert, error) {
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return Alert{}, err
+	}
+	created, err := repo.queries.InsertAlert(context.Background(), repository.InsertAlertParams{
+		AlertType: string(alertType),
+		PairID:    pairId,
+		Details:   raw,
+		Score:     score,
+	})
+	if err != nil {
+		return Alert{}, err
+	}
+	return convertAlert(created), nil
+}
+
+func (repo *alertRepo) UpdateDetails(id int64, score float64, details any) (bool, error) {
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return false, err
+	}
+	affected, err := repo.queries.UpdateAlertDetails(context.Background(), repository.UpdateAlertDetailsParams{
+		ID:      id,
+		Details: raw,
+		Score:   score,
+	})
+	return affected > 0, err
+}
+
+func (repo *alertRepo) GetAlert(id int64) (Alert, error) {
+	res, err := repo.queries.GetAlertByID(context.Background(), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Alert{}, ErrAlertNotFound
+	}
+	if err != nil {
+		return Alert{}, err
+	}
+	return convertAlert(res), nil
+}
+
+func (repo *alertRepo) GetAlerts(status AlertStatus, page int, size int) ([]Alert, error) {
+	dbres, err := repo.queries.GetAlertsByStatus(context.Background(), repository.GetAlertsByStatusParams{
+		Status: string(status),
+		Limit:  int32(size),
+		Offset: int32(max(0, page-1) * size),
+	})
+	if err != nil {
+		return nil, err
+	}
+	alerts := make([]Alert, len(dbres))
+	for idx, a := range dbres {
+		alerts[idx] = convertAlert(a)
+	}
+	return alerts, nil
+}
+
+func (repo *alertRepo) Review(id int64, status AlertStatus, reviewer string, note string) (Alert, error) {
+	res, err := repo.queries.ReviewAlert(context.Background(), repository.ReviewAlertParams{
+		ID:         id,
+		Status:     string(status),
+		ReviewedBy: pgtype.Text{String: reviewer, Valid: reviewer != ""},
+		ReviewNote: pgtype.Text{String: note, Valid: note != ""},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := repo.GetAlert(id); err != nil {
+			return Alert{}, err
+		}
+		return Alert{}, ErrAlertAlreadyReviewed
+	}
+	if err != nil {
+		return Alert{}, err
+	}
+	return convertAlert(res), nil
+}
+
+func (repo *alertRepo) GetBeneficialOwners() (map[int]string, error) {
+	dbres, err := repo.queries.GetBeneficialOwners(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[int]string, len(dbres))
+	for _, o := range dbres {
+		owners[int(o.AccountID)] = o.OwnerID
+	}
+	return owners, nil
+}
+
+func (repo *alertRepo) SetBeneficialOwner(accountId int, ownerId string) error {
+	return repo.queries.UpsertBeneficialOwner(context.Background(), repository.UpsertBeneficialOwnerParams{
+		AccountID: int32(accountId),
+		OwnerID:   ownerId,
+	})
+}
+
+func NewAlertRepository(dbpool *pgxpool.Pool) AlertRepo {
+	return &alertRepo{
+		queries: repository.New(dbpool),
+	}
+}
+
+func convertAlert(a repository.TblSurveillanceAlert) Alert {
+	res := Alert{
+		ID:         a.ID,
+		Type:       AlertType(a.AlertType),
+		PairID:     a.PairID,
+		Status:     AlertStatus(a.Status),
+		Score:      a.Score,
+		Details:    a.Details,
+		ReviewedBy: a.ReviewedBy.String,
+		ReviewNote: a.ReviewNote.String,
+		CreatedAt:  a.CreatedAt.Time,
+	}
+	if a.ReviewedAt.Valid {
+		res.ReviewedAt = &a.ReviewedAt.Time
+	}
+	return res
+}
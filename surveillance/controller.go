@@ -0,0 +1,178 @@
+package surveillance
+
+import (
+	"errors"
+	"net/http"
+	"order-book/logger"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	ErrFieldRequired = errors.New("ErrFieldRequired")
+	ErrInvalidData   = errors.New("ErrInvalidData")
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+type reviewRequest struct {
+	Status   AlertStatus `json:"status"`
+	Reviewer string      `json:"reviewer"`
+	Note     string      `json:"note"`
+}
+
+type ownerRequest struct {
+	OwnerID string `json:"owner_id"`
+}
+
+func BindSurveillanceRouter(r fiber.Router, alertRepo AlertRepo, washDetector *WashTradeDetector) {
+	r.Get("/admin/surveillance/alerts", func(c *fiber.Ctx) error {
+		status := AlertStatus(c.Query("status", string(OPEN)))
+		page := c.QueryInt("page", 1)
+		size := c.QueryInt("size", 50)
+
+		alerts, err := alertRepo.GetAlerts(status, page, size)
+		if err != nil {
+			logger.Error("failed to get surveillance alerts", map[string]any{
+				"status": status,
+				"error":  err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    alerts,
+		})
+	})
+
+	r.Get("/admin/surveillance/alerts/:id", func(c *fiber.Ctx) error {
+		id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid ID",
+				Data:    nil,
+			})
+		}
+
+		alert, err := alertRepo.GetAlert(id)
+		if err == ErrAlertNotFound {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The alert not found",
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    alert,
+		})
+	})
+
+	r.Post("/admin/surveillance/alerts/:id/review", func(c *fiber.Ctx) error {
+		id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid ID",
+				Data:    nil,
+			})
+		}
+
+		var req reviewRequest
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		if req.Status != DISMISSED && req.Status != ESCALATED {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrInvalidData,
+				Message: "Status should be either DISMISSED or ESCALATED",
+			})
+		}
+		if req.Reviewer == "" {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrFieldRequired,
+				Message: "Please provide a reviewer",
+			})
+		}
+
+		alert, err := alertRepo.Review(id, req.Status, req.Reviewer, req.Note)
+		if err == ErrAlertNotFound {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The alert not found",
+				Data:    nil,
+			})
+		}
+		if err == ErrAlertAlreadyReviewed {
+			c.Status(http.StatusConflict)
+			return c.JSON(&Response{
+				Message: "The alert was already reviewed",
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			return err
+		}
+
+		logger.Info("surveillance alert reviewed", map[string]any{
+			"alert_id": alert.ID,
+			"status":   alert.Status,
+			"reviewer": alert.ReviewedBy,
+		})
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Alert reviewed successfully",
+			Data:    alert,
+		})
+	})
+
+	r.Put("/admin/surveillance/accounts/:id/owner", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+
+		var req ownerRequest
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		if req.OwnerID == "" {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrFieldRequired,
+				Message: "Please provide an owner_id",
+			})
+		}
+
+		if err := washDetector.SetOwner(accountId, req.OwnerID); err != nil {
+			logger.Error("failed to link account to beneficial owner", map[string]any{
+				"account_id": accountId,
+				"owner_id":   req.OwnerID,
+				"error":      err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Beneficial owner updated",
+			Data:    nil,
+		})
+	})
+}
@@ -0,0 +1,25 @@
+-- name: GetBeneficialOwners :many
+SELECT * FROM tbl_beneficial_owners;
+
+-- name: UpsertBeneficialOwner :exec
+INSERT INTO tbl_beneficial_owners (account_id, owner_id)
+VALUES ($1, $2)
+ON CONFLICT (account_id) DO UPDATE SET owner_id = EXCLUDED.owner_id, updated_at = NOW();
+
+-- name: InsertAlert :one
+INSERT INTO tbl_surveillance_alerts (alert_type, pair_id, details, score)
+VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: UpdateAlertDetails :execrows
+UPDATE tbl_surveillance_alerts SET details = $2, score = $3 WHERE id = $1 AND status = 'OPEN';
+
+-- name: GetAlertByID :one
+SELECT * FROM tbl_surveillance_alerts WHERE id = $1;
+
+-- name: GetAlertsByStatus :many
+SELECT * FROM tbl_surveillance_alerts WHERE status = $1 ORDER BY score DESC, id LIMIT $2 OFFSET $3;
+
+-- name: ReviewAlert :one
+UPDATE tbl_surveillance_alerts SET status = $2, reviewed_by = $3, review_note = $4, reviewed_at = NOW()
+WHERE id = $1 AND status = 'OPEN'
+RETURNING *;
@@ -0,0 +1,18 @@
+CREATE TABLE tbl_beneficial_owners (
+    account_id INTEGER PRIMARY KEY REFERENCES tbl_accounts(id),
+    owner_id VARCHAR(64) NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE tbl_surveillance_alerts (
+    id BIGSERIAL PRIMARY KEY,
+    alert_type VARCHAR(25) NOT NULL,
+    pair_id VARCHAR(25) NOT NULL,
+    status VARCHAR(25) NOT NULL DEFAULT 'OPEN',
+    details JSONB NOT NULL DEFAULT '{}',
+    reviewed_by VARCHAR(255),
+    review_note TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    reviewed_at TIMESTAMP,
+    score DOUBLE PRECISION NOT NULL DEFAULT 0
+);
@@ -0,0 +1,303 @@
+package surveillance
+
+import (
+	"order-book/logger"
+	"order-book/order"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SpoofingThresholds are the detection parameters of a pair. An order is a
+// spoofing candidate when its notional is at least LargeOrderNotional and it
+// is cancelled within MaxLifetime. Candidates are flagged when the same
+// account traded at least MinOppositeNotional on the other side within Window.
+type SpoofingThresholds struct {
+	LargeOrderNotional  float64       `json:"large_order_notional"`
+	MaxLifetime         time.Duration `json:"max_lifetime"`
+	Window              time.Duration `json:"window"`
+	MinOppositeNotional float64       `json:"min_opposite_notional"`
+	// Layers is the number of cancelled orders at which the layering component
+	// of the score saturates
+	Layers   int     `json:"layers"`
+	MinScore float64 `json:"min_score"`
+}
+
+// SpoofingDetails is the payload of a SPOOFING alert
+type SpoofingDetails struct {
+	AccountID         int     `json:"account_id"`
+	Side              string  `json:"side"`
+	CancelledOrderIDs []int   `json:"cancelled_order_ids"`
+	CancelledNotional float64 `json:"cancelled_notional"`
+	OppositeTradeIDs  []int64 `json:"opposite_trade_ids"`
+	OppositeNotional  float64 `json:"opposite_notional"`
+	AvgLifetimeMs     int64   `json:"avg_lifetime_ms"`
+	Score             float64 `json:"score"`
+}
+
+type placedOrder struct {
+	order    order.Order
+	placedAt time.Time
+}
+
+type fastCancel struct {
+	orderId     int
+	side        order.OrderType
+	notional    float64
+	lifetime    time.Duration
+	cancelledAt time.Time
+}
+
+type accountFill struct {
+	tradeId    int64
+	side       order.OrderType
+	notional   float64
+	executedAt time.Time
+}
+
+type spoofingActivity struct {
+	cancels []fastCancel
+	fills   []accountFill
+}
+
+type spoofingEvent struct {
+	orderEvent *order.OrderEvent
+	trade      *order.Trade
+}
+
+// SpoofingDetector watches the order event stream for large orders that are
+// placed and cancelled quickly on one side of a pair while the same account
+// trades on the other side. All state is owned by the worker goroutine.
+type SpoofingDetector struct {
+	repo              AlertRepo
+	thresholds        map[string]SpoofingThresholds
+	defaultThresholds SpoofingThresholds
+	placed            map[int]placedOrder
+	activity          map[string]*spoofingActivity
+	events            chan spoofingEvent
+}
+
+func NewSpoofingDetector(repo AlertRepo, defaultThresholds SpoofingThresholds, thresholds map[string]SpoofingThresholds) *SpoofingDetector {
+	d := &SpoofingDetector{
+		repo:              repo,
+		thresholds:        thresholds,
+		defaultThresholds: defaultThresholds,
+		placed:            make(map[int]placedOrder),
+		activity:          make(map[string]*spoofingActivity),
+		events:            make(chan spoofingEvent, 4096),
+	}
+	go d.run()
+	return d
+}
+
+func (d *SpoofingDetector) ThresholdsFor(pairId string) SpoofingThresholds {
+	if t, ok := d.thresholds[pairId]; ok {
+		return t
+	}
+	return d.defaultThresholds
+}
+
+// OnOrderEvent queues an order event for inspection without blocking the engine
+func (d *SpoofingDetector) OnOrderEvent(ev order.OrderEvent) {
+	d.enqueue(spoofingEvent{orderEvent: &ev})
+}
+
+// OnTrade queues a trade for inspection without blocking the engine
+func (d *SpoofingDetector) OnTrade(t order.Trade) {
+	d.enqueue(spoofingEvent{trade: &t})
+}
+
+func (d *SpoofingDetector) enqueue(ev spoofingEvent) {
+	select {
+	case d.events <- ev:
+	default:
+		logger.Warn("spoofing detector queue is full, event not inspected", nil)
+	}
+}
+
+func (d *SpoofingDetector) run() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev := <-d.events:
+			if ev.orderEvent != nil {
+				d.onOrderEvent(*ev.orderEvent)
+			} else {
+				d.onTrade(*ev.trade)
+			}
+		case now := <-ticker.C:
+			d.prune(now)
+		}
+	}
+}
+
+func (d *SpoofingDetector) onOrderEvent(ev order.OrderEvent) {
+	o := ev.Order
+	switch ev.Name {
+	case order.ORDER_CREATED:
+		if o.Price.Mul(o.Amount).InexactFloat64() >= d.ThresholdsFor(o.PairID).LargeOrderNotional {
+			d.placed[o.ID] = placedOrder{order: o, placedAt: ev.At}
+		}
+	case order.ORDER_CANCELLED:
+		placed, ok := d.placed[o.ID]
+		if !ok {
+			return
+		}
+		delete(d.placed, o.ID)
+
+		limits := d.ThresholdsFor(o.PairID)
+		lifetime := ev.At.Sub(placed.placedAt)
+		// The cancelled order carries what was left on the book
+		notional := o.Price.Mul(o.Amount).InexactFloat64()
+		if lifetime > limits.MaxLifetime || notional < limits.LargeOrderNotional {
+			return
+		}
+		a := d.activityOf(o.AccountID, o.PairID)
+		a.cancels = append(a.cancels, fastCancel{
+			orderId:     o.ID,
+			side:        o.Type,
+			notional:    notional,
+			lifetime:    lifetime,
+			cancelledAt: ev.At,
+		})
+		d.evaluate(o.AccountID, o.PairID, ev.At)
+	}
+}
+
+func (d *SpoofingDetector) onTrade(t order.Trade) {
+	makerSide := order.ASK
+	if t.TakerSide == order.ASK {
+		makerSide = order.BID
+	}
+	notional := t.Price.Mul(t.Amount).InexactFloat64()
+	for _, fill := range []struct {
+		accountId int
+		side      order.OrderType
+	}{{t.MakerAccountID, makerSide}, {t.TakerAccountID, t.TakerSide}} {
+		a := d.activityOf(fill.accountId, t.PairID)
+		a.fills = append(a.fills, accountFill{
+			tradeId:    t.ID,
+			side:       fill.side,
+			notional:   notional,
+			executedAt: t.ExecutedAt,
+		})
+		d.evaluate(fill.accountId, t.PairID, t.ExecutedAt)
+	}
+}
+
+func (d *SpoofingDetector) activityOf(accountId int, pairId string) *spoofingActivity {
+	key := strconv.Itoa(accountId) + "|" + pairId
+	a := d.activity[key]
+	if a == nil {
+		a = &spoofingActivity{}
+		d.activity[key] = a
+	}
+	return a
+}
+
+func (d *SpoofingDetector) evaluate(accountId int, pairId string, now time.Time) {
+	limits := d.ThresholdsFor(pairId)
+	a := d.activityOf(accountId, pairId)
+	a.trim(now.Add(-limits.Window))
+
+	for _, side := range []order.OrderType{order.ASK, order.BID} {
+		details := SpoofingDetails{AccountID: accountId, Side: side.String()}
+		var totalLifetime time.Duration
+		for _, c := range a.cancels {
+			if c.side != side {
+				continue
+			}
+			details.CancelledOrderIDs = append(details.CancelledOrderIDs, c.orderId)
+			details.CancelledNotional += c.notional
+			totalLifetime += c.lifetime
+		}
+		for _, f := range a.fills {
+			if f.side == side {
+				continue
+			}
+			details.OppositeTradeIDs = append(details.OppositeTradeIDs, f.tradeId)
+			details.OppositeNotional += f.notional
+		}
+		if len(details.CancelledOrderIDs) == 0 || details.OppositeNotional < limits.MinOppositeNotional {
+			continue
+		}
+
+		avgLifetime := totalLifetime / time.Duration(len(details.CancelledOrderIDs))
+		details.AvgLifetimeMs = avgLifetime.Milliseconds()
+		details.Score = spoofingScore(limits, details, avgLifetime)
+		if details.Score < limits.MinScore {
+			continue
+		}
+
+		alert, err := d.repo.CreateAlert(SPOOFING, pairId, details.Score, details)
+		if err != nil {
+			logger.Error("failed to create spoofing alert", map[string]any{
+				"account_id": accountId,
+				"pair_id":    pairId,
+				"error":      err,
+			})
+			continue
+		}
+		logger.Warn("spoofing detected", map[string]any{
+			"alert_id":   alert.ID,
+			"account_id": accountId,
+			"pair_id":    pairId,
+			"side":       details.Side,
+			"score":      details.Score,
+		})
+		// Flagged activity is not reported twice
+		a.cancels = nil
+		a.fills = nil
+		return
+	}
+}
+
+// spoofingScore averages three components between 0 and 1: how large the
+// cancelled orders are compared to the real trading, how fast they were
+// cancelled and how many of them were layered
+func spoofingScore(limits SpoofingThresholds, details SpoofingDetails, avgLifetime time.Duration) float64 {
+	size := details.CancelledNotional / (details.CancelledNotional + details.OppositeNotional)
+	speed := 1.0
+	if limits.MaxLifetime > 0 {
+		speed = 1 - float64(avgLifetime)/float64(limits.MaxLifetime)
+	}
+	layering := 1.0
+	if limits.Layers > 1 {
+		layering = min(1, float64(len(details.CancelledOrderIDs))/float64(limits.Layers))
+	}
+	return (size + speed + layering) / 3
+}
+
+func (a *spoofingActivity) trim(since time.Time) {
+	cancels := a.cancels[:0]
+	for _, c := range a.cancels {
+		if !c.cancelledAt.Before(since) {
+			cancels = append(cancels, c)
+		}
+	}
+	a.cancels = cancels
+	fills := a.fills[:0]
+	for _, f := range a.fills {
+		if !f.executedAt.Before(since) {
+			fills = append(fills, f)
+		}
+	}
+	a.fills = fills
+}
+
+// prune drops orders that can't be fast cancels anymore and idle activity
+func (d *SpoofingDetector) prune(now time.Time) {
+	for id, p := range d.placed {
+		if now.Sub(p.placedAt) > d.ThresholdsFor(p.order.PairID).MaxLifetime {
+			delete(d.placed, id)
+		}
+	}
+	for key, a := range d.activity {
+		_, pairId, _ := strings.Cut(key, "|")
+		a.trim(now.Add(-d.ThresholdsFor(pairId).Window))
+		if len(a.cancels) == 0 && len(a.fills) == 0 {
+			delete(d.activity, key)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package surveillance
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"order-book/db/sqlite"
+)
+
+type sqliteAlertRepo struct {
+	db *sql.DB
+}
+
+const sqliteAlertColumns = "id, alert_type, pair_id, status, score, details, reviewed_by, review_note, created_at, reviewed_at"
+
+func scanSQLiteAlert(row interface{ Scan(...any) error }) (Alert, error) {
+	var (
+		a          Alert
+		details    string
+		reviewedBy sql.NullString
+		reviewNote sql.NullString
+		reviewedAt sql.NullTime
+	)
+	err := row.Scan(&a.ID, &a.Type, &a.PairID, &a.Status, &a.Score, &details, &reviewedBy, &reviewNote, &a.CreatedAt, &reviewedAt)
+	a.Details = json.RawMessage(details)
+	a.ReviewedBy = reviewedBy.String
+	a.ReviewNote = reviewNote.String
+	if reviewedAt.Valid {
+		a.ReviewedAt = &reviewedAt.Time
+	}
+	return a, err
+}
+
+func (repo *sqliteAlertRepo) CreateAlert(alertType AlertType, pairId string, score float64, details any) (Alert, error) {
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return Alert{}, err
+	}
+	return scanSQLiteAlert(repo.db.QueryRowContext(context.Background(),
+		"INSERT INTO tbl_surveillance_alerts (alert_type, pair_id, details, score, created_at) VALUES (?, ?, ?, ?, ?) RETURNING "+sqliteAlertColumns,
+		string(alertType), pairId, string(raw), score, sqlite.Now(),
+	))
+}
+
+func (repo *sqliteAlertRepo) UpdateDetails(id int64, score float64, details any) (bool, error) {
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return false, err
+	}
+	res, err := repo.db.ExecContext(context.Background(),
+		"UPDATE tbl_surveillance_alerts SET details = ?, score = ? WHERE id = ? AND status = 'OPEN'",
+		string(raw), score, id,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+func (repo *sqliteAlertRepo) GetAlert(id int64) (Alert, error) {
+	res, err := scanSQLiteAlert(repo.db.QueryRowContext(context.Background(),
+		"SELECT "+sqliteAlertColumns+" FROM tbl_surveillance_alerts WHERE id = ?", id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Alert{}, ErrAlertNotFound
+	}
+	return res, err
+}
+
+func (repo *sqliteAlertRepo) GetAlerts(status AlertStatus, page int, size int) ([]Alert, error) {
+	rows, err := repo.db.QueryContext(context.Background(),
+		"SELECT "+sqliteAlertColumns+" FROM tbl_surveillance_alerts WHERE status = ? ORDER BY score DESC, id LIMIT ? OFFSET ?",
+		string(status), size, max(0, page-1)*size,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	alerts := []Alert{}
+	for rows.Next() {
+		a, err := scanSQLiteAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+func (repo *sqliteAlertRepo) Review(id int64, status AlertStatus, reviewer string, note string) (Alert, error) {
+	res, err := scanSQLiteAlert(repo.db.QueryRowContext(context.Background(),
+		"UPDATE tbl_surveillance_alerts SET status = ?, reviewed_by = ?, review_note = ?, reviewed_at = ? WHERE id = ? AND status = 'OPEN' RETURNING "+sqliteAlertColumns,
+		string(status),
+		sql.NullString{String: reviewer, Valid: reviewer != ""},
+		sql.NullString{String: note, Valid: note != ""},
+		sqlite.Now(),
+		id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := repo.GetAlert(id); err != nil {
+			return Alert{}, err
+		}
+		return Alert{}, ErrAlertAlreadyReviewed
+	}
+	return res, err
+}
+
+func (repo *sqliteAlertRepo) GetBeneficialOwners() (map[int]string, error) {
+	rows, err := repo.db.QueryContext(context.Background(), "SELECT account_id, owner_id FROM tbl_beneficial_owners")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	owners := make(map[int]string)
+	for rows.Next() {
+		var (
+			accountId int
+			ownerId   string
+		)
+		if err := rows.Scan(&accountId, &ownerId); err != nil {
+			return nil, err
+		}
+		owners[accountId] = ownerId
+	}
+	return owners, rows.Err()
+}
+
+func (repo *sqliteAlertRepo) SetBeneficialOwner(accountId int, ownerId string) error {
+	_, err := repo.db.ExecContext(context.Background(), `INSERT INTO tbl_beneficial_owners (account_id, owner_id, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (account_id) DO UPDATE SET owner_id = excluded.owner_id, updated_at = excluded.updated_at`,
+		accountId, ownerId, sqlite.Now(),
+	)
+	return err
+}
+
+// NewSQLiteAlertRepository is NewAlertRepository on a SQLite database
+func NewSQLiteAlertRepository(db *sql.DB) AlertRepo {
+	return &sqliteAlertRepo{db: db}
+}
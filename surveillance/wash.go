@@ -0,0 +1,152 @@
+package surveillance
+
+import (
+	"order-book/logger"
+	"order-book/order"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+// WashTradeDetails is the payload of a WASH_TRADE alert. Trades of the same
+// owner on the same pair are grouped into one alert until it is reviewed.
+type WashTradeDetails struct {
+	OwnerID    string          `json:"owner_id"`
+	AccountIDs []int           `json:"account_ids"`
+	TradeIDs   []int64         `json:"trade_ids"`
+	Volume     decimal.Decimal `json:"volume"`
+}
+
+// washTradeScore is the score of every wash trade alert, both sides of the
+// trade having the same owner is not a heuristic
+const washTradeScore = 1
+
+type openWashAlert struct {
+	id      int64
+	details WashTradeDetails
+}
+
+// WashTradeDetector flags trades where the maker and the taker belong to the
+// same beneficial owner. Accounts without a registered owner are their own owner.
+type WashTradeDetector struct {
+	mu         sync.Mutex
+	repo       AlertRepo
+	owners     map[int]string
+	openAlerts map[string]*openWashAlert
+	trades     chan order.Trade
+}
+
+func NewWashTradeDetector(repo AlertRepo) (*WashTradeDetector, error) {
+	owners, err := repo.GetBeneficialOwners()
+	if err != nil {
+		return nil, err
+	}
+	d := &WashTradeDetector{
+		repo:       repo,
+		owners:     owners,
+		openAlerts: make(map[string]*openWashAlert),
+		trades:     make(chan order.Trade, 4096),
+	}
+	go func() {
+		for t := range d.trades {
+			d.inspect(t)
+		}
+	}()
+	return d, nil
+}
+
+// OnTrade queues a trade for inspection without blocking the engine
+func (d *WashTradeDetector) OnTrade(t order.Trade) {
+	select {
+	case d.trades <- t:
+	default:
+		logger.Warn("wash trade detector queue is full, trade not inspected", map[string]any{
+			"trade_id": t.ID,
+			"pair_id":  t.PairID,
+		})
+	}
+}
+
+func (d *WashTradeDetector) SetOwner(accountId int, ownerId string) error {
+	if err := d.repo.SetBeneficialOwner(accountId, ownerId); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.owners[accountId] = ownerId
+	return nil
+}
+
+func (d *WashTradeDetector) ownerOf(accountId int) string {
+	if ownerId, ok := d.owners[accountId]; ok {
+		return ownerId
+	}
+	return "account:" + strconv.Itoa(accountId)
+}
+
+func (d *WashTradeDetector) inspect(t order.Trade) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ownerId := d.ownerOf(t.MakerAccountID)
+	if ownerId != d.ownerOf(t.TakerAccountID) {
+		return
+	}
+
+	key := ownerId + "|" + t.PairID
+	if open := d.openAlerts[key]; open != nil {
+		details := open.details
+		details.TradeIDs = append(slices.Clone(details.TradeIDs), t.ID)
+		details.AccountIDs = appendAccounts(slices.Clone(details.AccountIDs), t.MakerAccountID, t.TakerAccountID)
+		details.Volume = details.Volume.Add(t.Price.Mul(t.Amount))
+		ok, err := d.repo.UpdateDetails(open.id, washTradeScore, details)
+		if err != nil {
+			logger.Error("failed to update wash trade alert", map[string]any{
+				"alert_id": open.id,
+				"trade_id": t.ID,
+				"error":    err,
+			})
+			return
+		}
+		if ok {
+			open.details = details
+			return
+		}
+		// The alert was reviewed, new activity starts a new one
+		delete(d.openAlerts, key)
+	}
+
+	details := WashTradeDetails{
+		OwnerID:    ownerId,
+		AccountIDs: appendAccounts(nil, t.MakerAccountID, t.TakerAccountID),
+		TradeIDs:   []int64{t.ID},
+		Volume:     t.Price.Mul(t.Amount),
+	}
+	alert, err := d.repo.CreateAlert(WASH_TRADE, t.PairID, washTradeScore, details)
+	if err != nil {
+		logger.Error("failed to create wash trade alert", map[string]any{
+			"trade_id": t.ID,
+			"owner_id": ownerId,
+			"error":    err,
+		})
+		return
+	}
+	d.openAlerts[key] = &openWashAlert{id: alert.ID, details: details}
+	logger.Warn("wash trade detected", map[string]any{
+		"alert_id": alert.ID,
+		"trade_id": t.ID,
+		"pair_id":  t.PairID,
+		"owner_id": ownerId,
+	})
+}
+
+func appendAccounts(accounts []int, ids ...int) []int {
+	for _, id := range ids {
+		if !slices.Contains(accounts, id) {
+			accounts = append(accounts, id)
+		}
+	}
+	return accounts
+}
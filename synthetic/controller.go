@@ -0,0 +1,61 @@
+package synthetic
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+// BindSyntheticRouter serves the route of a synthetic pair on
+// GET /synthetic-routes?base=&quote= and routes the orders of an account on
+// POST /accounts/:id/synthetic-orders
+func BindSyntheticRouter(r fiber.Router, router *Router) {
+	r.Get("/synthetic-routes", func(c *fiber.Ctx) error {
+		route, err := FindRoute(c.Query("base"), c.Query("quote"))
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    route,
+		})
+	})
+
+	r.Post("/accounts/:id/synthetic-orders", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		var req Request
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		req.AccountID = accountId
+
+		fill, err := router.Execute(c.UserContext(), req)
+		if err != nil {
+			return err
+		}
+		message := "Synthetic order filled"
+		if fill.Status != FILLED {
+			message = "Synthetic order fell short and was rolled back"
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: message,
+			Data:    fill,
+		})
+	})
+}
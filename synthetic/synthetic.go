@@ -0,0 +1,488 @@
+// Package synthetic trades pairs without a book of their own through two
+// books sharing an asset, ETH/BTC through ethusdt and btcusdt. The leg
+// spending what the account holds goes first, then the other one with what
+// it got, each immediate or cancel. Both legs are sized on the books before
+// the first is sent, and when a leg falls short the filled legs are reversed
+// so the order is all or nothing. An order only matches those resting at its
+// own price, so a leg is sent as one order per price level it reaches.
+package synthetic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"order-book/book"
+	"order-book/logger"
+	"order-book/order"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// FUNDING_BUFFER is how much more of the shared asset a buy raises than the
+// books quote for the second leg, for fees and moves in between. What's left
+// of it stays in the account.
+const FUNDING_BUFFER = 0.005
+
+// Statuses of a synthetic order
+const (
+	FILLED      = "FILLED"
+	ROLLED_BACK = "ROLLED_BACK"
+	FAILED      = "FAILED"
+)
+
+var (
+	ErrNoRoute               = errors.New("No pair of books trades both assets through a common one")
+	ErrDirectPair            = errors.New("The pair has a book, trade it directly")
+	ErrInsufficientLiquidity = errors.New("The books are too thin to fill the synthetic order")
+	ErrSyntheticLimit        = errors.New("The synthetic price would be past the limit price")
+	ErrInvalidSynthetic      = errors.New("A synthetic order needs a base, a quote, a positive amount and a limit price that isn't negative")
+)
+
+var synLog = logger.Component("synthetic")
+
+// Request buys (BID) or sells (ASK) Amount of Base for Quote. LimitPrice,
+// in Quote per Base, bounds the price the books are quoting before any leg
+// is sent, 0 for none.
+type Request struct {
+	AccountID  int             `json:"account_id"`
+	Base       string          `json:"base"`
+	Quote      string          `json:"quote"`
+	Type       order.OrderType `json:"type"`
+	Amount     decimal.Decimal `json:"amount"`
+	LimitPrice decimal.Decimal `json:"limit_price"`
+}
+
+// Route is the books a synthetic pair trades through
+type Route struct {
+	Base      string `json:"base"`
+	Quote     string `json:"quote"`
+	Via       string `json:"via"`
+	BasePair  string `json:"base_pair"`
+	QuotePair string `json:"quote_pair"`
+}
+
+// Leg is what a synthetic order sent to a pair, one order per price level
+// up to Price, and what they filled
+type Leg struct {
+	PairID   string          `json:"pair_id"`
+	Type     order.OrderType `json:"type"`
+	Amount   decimal.Decimal `json:"amount"`
+	Price    decimal.Decimal `json:"price"`
+	OrderIDs []int           `json:"order_ids"`
+	Filled   decimal.Decimal `json:"filled"`
+	Notional decimal.Decimal `json:"notional"`
+	AvgPrice decimal.Decimal `json:"avg_price"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// Fill is the outcome of a synthetic order. Price is in Quote per Base,
+// Residual the shared asset the legs left in the account. Rollback are the
+// orders that reversed the legs of an order that fell short.
+type Fill struct {
+	Route
+	StrategyID string          `json:"strategy_id"`
+	Type       order.OrderType `json:"type"`
+	Amount     decimal.Decimal `json:"amount"`
+	Status     string          `json:"status"`
+	Filled     decimal.Decimal `json:"filled"`
+	Price      decimal.Decimal `json:"price"`
+	Residual   decimal.Decimal `json:"residual"`
+	Legs       []Leg           `json:"legs"`
+	Rollback   []Leg           `json:"rollback,omitempty"`
+}
+
+// Books resolves the book an account trades on
+type Books interface {
+	ForAccount(accountId int) book.Book
+}
+
+type Router struct {
+	books Books
+	// stpGroupOf is the STP group of an account, the orders of its group
+	// don't match its legs
+	stpGroupOf func(accountId int) string
+
+	mu    sync.Mutex
+	seq   int
+	fills map[string][]order.Trade
+}
+
+func NewRouter(books Books, stpGroupOf func(accountId int) string) *Router {
+	return &Router{
+		books:      books,
+		stpGroupOf: stpGroupOf,
+		fills:      make(map[string][]order.Trade),
+	}
+}
+
+// Attach follows the fills of the legs sent to a book
+func (r *Router) Attach(tenantId string, b book.Book) {
+	b.OnTrade(r.onTrade)
+}
+
+func (r *Router) onTrade(t order.Trade) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, strategyId := range []string{t.TakerStrategyID, t.MakerStrategyID} {
+		if _, ok := r.fills[strategyId]; ok {
+			r.fills[strategyId] = append(r.fills[strategyId], t)
+		}
+	}
+}
+
+// FindRoute is the books base and quote both trade against, the first
+// shared asset in the order of the pairs
+func FindRoute(base string, quote string) (Route, error) {
+	base, quote = strings.ToLower(base), strings.ToLower(quote)
+	byQuote := make(map[string]map[string]order.Pair)
+	for _, p := range order.GetPairs() {
+		if (p.Base == base && p.Quote == quote) || (p.Base == quote && p.Quote == base) {
+			return Route{}, ErrDirectPair
+		}
+		if p.Status == order.PAIR_HALTED {
+			continue
+		}
+		if byQuote[p.Quote] == nil {
+			byQuote[p.Quote] = make(map[string]order.Pair)
+		}
+		byQuote[p.Quote][p.Base] = p
+	}
+	for _, via := range slices.Sorted(maps.Keys(byQuote)) {
+		basePair, okBase := byQuote[via][base]
+		quotePair, okQuote := byQuote[via][quote]
+		if okBase && okQuote {
+			return Route{Base: base, Quote: quote, Via: via, BasePair: basePair.ID, QuotePair: quotePair.ID}, nil
+		}
+	}
+	return Route{}, ErrNoRoute
+}
+
+// take is what a leg takes at a price level
+type take struct {
+	price  decimal.Decimal
+	amount decimal.Decimal
+}
+
+// step is a leg to send, sized on the books, with what it takes at each
+// level up to price
+type step struct {
+	pairId string
+	side   order.OrderType
+	amount decimal.Decimal
+	price  decimal.Decimal
+	takes  []take
+}
+
+// newStep spreads amount over levels, the best first, what's past them on
+// the last one
+func newStep(pairId string, side order.OrderType, levels []order.PriceLevel, amount decimal.Decimal) step {
+	s := step{pairId: pairId, side: side, amount: amount}
+	left := amount
+	for _, level := range levels {
+		if !left.IsPositive() {
+			break
+		}
+		t := take{price: level.Price, amount: decimal.Min(left, levelAmount(level))}
+		s.takes = append(s.takes, t)
+		s.price = t.price
+		left = left.Sub(t.amount)
+	}
+	if n := len(s.takes); n > 0 && left.IsPositive() {
+		s.takes[n-1].amount = s.takes[n-1].amount.Add(left)
+	}
+	return s
+}
+
+// plan sizes the legs of a request on the books, the leg to send first
+// first. A buy sells quote for the shared asset then buys base with it, a
+// sell does the reverse.
+func (r *Router) plan(b book.Book, route Route, req Request) (first step, second step, estimate decimal.Decimal, err error) {
+	buffer := decimal.NewFromFloat(FUNDING_BUFFER)
+	one := decimal.NewFromInt(1)
+	if req.Type == order.BID {
+		baseAsks := r.levels(b, route.BasePair, order.ASK, req.AccountID)
+		cost, ok := walkAmount(baseAsks, req.Amount)
+		if !ok {
+			return step{}, step{}, decimal.Zero, ErrInsufficientLiquidity
+		}
+		quoteBids := r.levels(b, route.QuotePair, order.BID, req.AccountID)
+		sell, ok := walkNotional(quoteBids, cost.Mul(one.Add(buffer)))
+		if !ok {
+			return step{}, step{}, decimal.Zero, ErrInsufficientLiquidity
+		}
+		sell = roundAmount(route.QuotePair, sell, true)
+		first = newStep(route.QuotePair, order.ASK, quoteBids, sell)
+		second = newStep(route.BasePair, order.BID, baseAsks, req.Amount)
+		return first, second, sell.Div(req.Amount), nil
+	}
+
+	baseBids := r.levels(b, route.BasePair, order.BID, req.AccountID)
+	proceeds, ok := walkAmount(baseBids, req.Amount)
+	if !ok {
+		return step{}, step{}, decimal.Zero, ErrInsufficientLiquidity
+	}
+	quoteAsks := r.levels(b, route.QuotePair, order.ASK, req.AccountID)
+	buy, ok := walkNotional(quoteAsks, proceeds.Mul(one.Sub(buffer)))
+	if !ok {
+		return step{}, step{}, decimal.Zero, ErrInsufficientLiquidity
+	}
+	buy = roundAmount(route.QuotePair, buy, false)
+	first = newStep(route.BasePair, order.ASK, baseBids, req.Amount)
+	second = newStep(route.QuotePair, order.BID, quoteAsks, buy)
+	return first, second, buy.Div(req.Amount), nil
+}
+
+// levels are the price levels of a side of a book an order of accountId can
+// match, the best first. Self-trade prevention skips the orders of the
+// account and of its STP group, they're left out.
+func (r *Router) levels(b book.Book, pairId string, side order.OrderType, accountId int) []order.PriceLevel {
+	var levels []order.PriceLevel
+	for _, level := range bestFirst(b.Snapshot(pairId), side) {
+		matchable := order.PriceLevel{Price: level.Price}
+		for _, o := range level.Orders {
+			if !r.sameOwner(o.AccountID, accountId) {
+				matchable.Orders = append(matchable.Orders, o)
+			}
+		}
+		if len(matchable.Orders) > 0 {
+			levels = append(levels, matchable)
+		}
+	}
+	return levels
+}
+
+func (r *Router) sameOwner(a int, other int) bool {
+	if a == other {
+		return true
+	}
+	if r.stpGroupOf == nil {
+		return false
+	}
+	group := r.stpGroupOf(a)
+	return group != "" && group == r.stpGroupOf(other)
+}
+
+// bestFirst are the price levels of a side of a snapshot, the best first
+func bestFirst(snap order.BookSnapshot, side order.OrderType) []order.PriceLevel {
+	if side == order.ASK {
+		return snap.Asks
+	}
+	levels := slices.Clone(snap.Bids)
+	slices.Reverse(levels)
+	return levels
+}
+
+func levelAmount(level order.PriceLevel) decimal.Decimal {
+	amount := decimal.Zero
+	for _, o := range level.Orders {
+		amount = amount.Add(o.Amount)
+	}
+	return amount
+}
+
+// walkAmount is what amount costs through levels, ok is false when they
+// don't hold that much
+func walkAmount(levels []order.PriceLevel, amount decimal.Decimal) (notional decimal.Decimal, ok bool) {
+	left := amount
+	for _, level := range levels {
+		take := decimal.Min(left, levelAmount(level))
+		notional = notional.Add(take.Mul(level.Price))
+		left = left.Sub(take)
+		if !left.IsPositive() {
+			return notional, true
+		}
+	}
+	return notional, false
+}
+
+// walkNotional is the amount worth notional through levels
+func walkNotional(levels []order.PriceLevel, notional decimal.Decimal) (amount decimal.Decimal, ok bool) {
+	left := notional
+	for _, level := range levels {
+		available := levelAmount(level)
+		if value := available.Mul(level.Price); value.LessThan(left) {
+			amount = amount.Add(available)
+			left = left.Sub(value)
+			continue
+		}
+		return amount.Add(left.Div(level.Price)), true
+	}
+	return amount, false
+}
+
+// roundAmount rounds an amount to the scale and the lot of its pair
+func roundAmount(pairId string, amount decimal.Decimal, up bool) decimal.Decimal {
+	p, ok := order.GetPair(pairId)
+	if !ok {
+		return amount
+	}
+	step := decimal.New(1, -p.AmountScale)
+	if p.LotSize.IsPositive() {
+		step = p.LotSize
+	}
+	if up {
+		return amount.Div(step).Ceil().Mul(step)
+	}
+	return amount.Div(step).Floor().Mul(step)
+}
+
+// Execute routes a synthetic order. It's refused before any leg is sent
+// when the books can't fill it or quote past the limit price.
+func (r *Router) Execute(ctx context.Context, req Request) (Fill, error) {
+	if req.Base == "" || req.Quote == "" || !req.Amount.IsPositive() || req.LimitPrice.IsNegative() ||
+		(req.Type != order.ASK && req.Type != order.BID) {
+		return Fill{}, ErrInvalidSynthetic
+	}
+	route, err := FindRoute(req.Base, req.Quote)
+	if err != nil {
+		return Fill{}, err
+	}
+	req.Amount = roundAmount(route.BasePair, req.Amount, false)
+	b := r.books.ForAccount(req.AccountID)
+	first, second, estimate, err := r.plan(b, route, req)
+	if err != nil {
+		return Fill{}, err
+	}
+	if req.LimitPrice.IsPositive() &&
+		((req.Type == order.BID && estimate.GreaterThan(req.LimitPrice)) || (req.Type == order.ASK && estimate.LessThan(req.LimitPrice))) {
+		return Fill{}, ErrSyntheticLimit
+	}
+
+	r.mu.Lock()
+	r.seq++
+	strategyId := fmt.Sprintf("synthetic-%d-%d", time.Now().Unix(), r.seq)
+	r.mu.Unlock()
+	fill := Fill{
+		Route:      route,
+		StrategyID: strategyId,
+		Type:       req.Type,
+		Amount:     req.Amount,
+		Status:     FILLED,
+		Legs:       []Leg{},
+	}
+	ctx = context.WithoutCancel(ctx)
+	for idx, s := range []step{first, second} {
+		leg, err := r.send(ctx, b, req.AccountID, strategyId, s)
+		if idx == 0 && err != nil && !leg.Filled.IsPositive() {
+			// Nothing traded yet, the order is refused as the leg was
+			return Fill{}, err
+		}
+		fill.Legs = append(fill.Legs, leg)
+		if leg.Filled.LessThan(s.amount) {
+			r.rollback(ctx, b, req.AccountID, strategyId, &fill)
+			return fill, nil
+		}
+	}
+
+	// The base leg is the one on the base pair, the other one traded quote
+	baseLeg, quoteLeg := fill.Legs[1], fill.Legs[0]
+	if req.Type == order.ASK {
+		baseLeg, quoteLeg = fill.Legs[0], fill.Legs[1]
+	}
+	fill.Filled = baseLeg.Filled
+	fill.Price = quoteLeg.Filled.Div(baseLeg.Filled)
+	fill.Residual = fill.Legs[0].Notional.Sub(fill.Legs[1].Notional)
+	synLog.Info("synthetic order filled", map[string]any{
+		"account_id":  req.AccountID,
+		"strategy_id": strategyId,
+		"base":        route.Base,
+		"quote":       route.Quote,
+		"via":         route.Via,
+		"filled":      fill.Filled,
+		"price":       fill.Price,
+	})
+	return fill, nil
+}
+
+// send places the orders of a leg, one per level, each pulling what it
+// didn't match off the book before the next. It stops at the first order
+// refused, with what the leg filled so far.
+func (r *Router) send(ctx context.Context, b book.Book, accountId int, strategyId string, s step) (Leg, error) {
+	leg := Leg{PairID: s.pairId, Type: s.side, Amount: s.amount, Price: s.price, OrderIDs: []int{}}
+	r.mu.Lock()
+	r.fills[strategyId] = []order.Trade{}
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.fills, strategyId)
+		r.mu.Unlock()
+	}()
+
+	var sendErr error
+	for _, t := range s.takes {
+		timings, err := b.AddOrderWithTimings(ctx, order.Order{
+			Price:      t.price,
+			Amount:     t.amount,
+			PairID:     s.pairId,
+			AccountID:  accountId,
+			Type:       s.side,
+			CreatedAt:  time.Now(),
+			StrategyID: strategyId,
+		})
+		if err != nil {
+			leg.Error = err.Error()
+			sendErr = err
+			break
+		}
+		leg.OrderIDs = append(leg.OrderIDs, timings.OrderID)
+		if err := b.CancellOrder(ctx, timings.OrderID); err != nil && !errors.Is(err, book.ErrOrderNotFound) {
+			synLog.Error("failed to cancel a synthetic leg", map[string]any{
+				"order_id": timings.OrderID,
+				"error":    err,
+			})
+			leg.Error = err.Error()
+		}
+	}
+
+	r.mu.Lock()
+	trades := r.fills[strategyId]
+	r.mu.Unlock()
+	for _, t := range trades {
+		if !slices.Contains(leg.OrderIDs, t.TakerOrderID) && !slices.Contains(leg.OrderIDs, t.MakerOrderID) {
+			continue
+		}
+		leg.Filled = leg.Filled.Add(t.Amount)
+		leg.Notional = leg.Notional.Add(t.Amount.Mul(t.Price))
+	}
+	if leg.Filled.IsPositive() {
+		leg.AvgPrice = leg.Notional.Div(leg.Filled)
+	}
+	return leg, sendErr
+}
+
+// rollback reverses the filled legs, the last first, at whatever the books
+// offer for them
+func (r *Router) rollback(ctx context.Context, b book.Book, accountId int, strategyId string, fill *Fill) {
+	fill.Status = ROLLED_BACK
+	for _, leg := range slices.Backward(fill.Legs) {
+		if !leg.Filled.IsPositive() {
+			continue
+		}
+		side := order.BID
+		if leg.Type == order.BID {
+			side = order.ASK
+		}
+		// The reverse order takes the side the leg traded against
+		levels := r.levels(b, leg.PairID, leg.Type, accountId)
+		if len(levels) == 0 {
+			fill.Rollback = append(fill.Rollback, Leg{PairID: leg.PairID, Type: side, Amount: leg.Filled, OrderIDs: []int{}, Error: ErrInsufficientLiquidity.Error()})
+			fill.Status = FAILED
+			continue
+		}
+		reverse, _ := r.send(ctx, b, accountId, strategyId, newStep(leg.PairID, side, levels, leg.Filled))
+		fill.Rollback = append(fill.Rollback, reverse)
+		if reverse.Filled.LessThan(leg.Filled) {
+			fill.Status = FAILED
+		}
+	}
+	synLog.Warn("synthetic order rolled back", map[string]any{
+		"account_id":  accountId,
+		"strategy_id": strategyId,
+		"status":      fill.Status,
+	})
+}
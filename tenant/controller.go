@@ -0,0 +1,212 @@
+package tenant
+
+import (
+	"errors"
+	"net/http"
+	"order-book/logger"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	ErrFieldRequired = errors.New("ErrFieldRequired")
+	ErrInvalidData   = errors.New("ErrInvalidData")
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+type pairRequest struct {
+	PairID string `json:"pair_id"`
+}
+
+func BindTenantRouter(r fiber.Router, dir *Directory) {
+	r.Get("/admin/tenants", func(c *fiber.Ctx) error {
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    dir.GetTenants(),
+		})
+	})
+
+	r.Post("/admin/tenants", func(c *fiber.Ctx) error {
+		var req Tenant
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		if req.ID == "" || req.Name == "" {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrFieldRequired,
+				Message: "Please provide an id and a name",
+			})
+		}
+
+		t, err := dir.CreateTenant(req)
+		if err == ErrTenantExists {
+			c.Status(http.StatusConflict)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			logger.Error("failed to create tenant", map[string]any{
+				"tenant_id": req.ID,
+				"error":     err,
+			})
+			return err
+		}
+		c.Status(http.StatusCreated)
+		return c.JSON(&Response{
+			Message: "Tenant created",
+			Data:    t,
+		})
+	})
+
+	r.Put("/admin/tenants/:id/quotas", func(c *fiber.Ctx) error {
+		var req Quotas
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		if req.MaxOpenOrders < 0 || req.MaxOrdersPerSecond < 0 || req.MaxAccounts < 0 {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrInvalidData,
+				Message: "Quotas can't be negative",
+			})
+		}
+
+		t, err := dir.UpdateQuotas(c.Params("id"), req)
+		if err == ErrTenantNotFound {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The tenant not found",
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Quotas updated",
+			Data:    t,
+		})
+	})
+
+	r.Post("/admin/tenants/:id/pairs", func(c *fiber.Ctx) error {
+		var req pairRequest
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		if req.PairID == "" {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrFieldRequired,
+				Message: "Please provide a pair_id",
+			})
+		}
+
+		t, err := dir.AddPair(c.Params("id"), req.PairID)
+		if err == ErrTenantNotFound {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The tenant not found",
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Pair added",
+			Data:    t,
+		})
+	})
+
+	r.Post("/admin/tenants/:id/api-keys", func(c *fiber.Ctx) error {
+		apiKey, key, err := dir.CreateAPIKey(c.Params("id"), 0)
+		if err == ErrTenantNotFound {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The tenant not found",
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusCreated)
+		return c.JSON(&Response{
+			Message: "Store the key now, it can't be retrieved again",
+			Data: map[string]any{
+				"api_key": apiKey,
+				"key":     key,
+			},
+		})
+	})
+
+	r.Delete("/admin/tenants/:id/api-keys/:key_id", func(c *fiber.Ctx) error {
+		keyId, err := strconv.ParseInt(c.Params("key_id"), 10, 64)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid ID",
+				Data:    nil,
+			})
+		}
+
+		apiKey, err := dir.RevokeAPIKey(c.Params("id"), keyId)
+		if err == ErrAPIKeyNotFound {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The API key not found",
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "API key revoked",
+			Data:    apiKey,
+		})
+	})
+
+	r.Post("/admin/tenants/:id/accounts", func(c *fiber.Ctx) error {
+		// The directory keeps the tenant ID, fiber reuses the buffer of the params
+		accountId, err := dir.CreateAccount(strings.Clone(c.Params("id")))
+		if err == ErrTenantNotFound {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The tenant not found",
+				Data:    nil,
+			})
+		}
+		if err == ErrAccountsQuota {
+			c.Status(http.StatusUnprocessableEntity)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		c.Status(http.StatusCreated)
+		return c.JSON(&Response{
+			Message: "Account created",
+			Data: map[string]any{
+				"account_id": accountId,
+			},
+		})
+	})
+}
@@ -0,0 +1,392 @@
+package tenant
+
+import (
+	"order-book/order"
+	"slices"
+	"sync"
+	"time"
+)
+
+// OrderCounter is the book of a tenant as seen by the open orders quota and
+// limits
+type OrderCounter interface {
+	OpenOrders() int
+	GetAccountOrders(accountId int) []order.Order
+}
+
+type rateWindow struct {
+	second int64
+	count  int
+}
+
+// Directory keeps tenants, API keys and accounts in memory so requests and
+// order validation don't hit the database.
+type Directory struct {
+	mu           sync.RWMutex
+	repo         TenantRepo
+	tenants      map[string]Tenant
+	keys         map[string]APIKey
+	accounts     map[int]Account
+	rates        map[string]*rateWindow
+	accountRates map[int]*rateWindow
+}
+
+func NewDirectory(repo TenantRepo) (*Directory, error) {
+	tenants, err := repo.GetTenants()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := repo.GetActiveAPIKeys()
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := repo.GetAccounts()
+	if err != nil {
+		return nil, err
+	}
+	d := &Directory{
+		repo:         repo,
+		tenants:      make(map[string]Tenant, len(tenants)),
+		keys:         keys,
+		accounts:     make(map[int]Account, len(accounts)),
+		rates:        make(map[string]*rateWindow),
+		accountRates: make(map[int]*rateWindow),
+	}
+	for _, t := range tenants {
+		d.tenants[t.ID] = t
+	}
+	for _, a := range accounts {
+		d.accounts[a.ID] = a
+	}
+	return d, nil
+}
+
+// Authenticate resolves the tenant of an API key, an empty key is the default tenant
+func (d *Directory) Authenticate(key string) (Tenant, error) {
+	t, _, err := d.Identify(key)
+	return t, err
+}
+
+// Identify is Authenticate along with the API key used, a zero APIKey when
+// the request had none
+func (d *Directory) Identify(key string) (Tenant, APIKey, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	tenantId := DEFAULT
+	var apiKey APIKey
+	if key != "" {
+		var ok bool
+		apiKey, ok = d.keys[HashKey(key)]
+		if !ok {
+			return Tenant{}, APIKey{}, ErrInvalidAPIKey
+		}
+		tenantId = apiKey.TenantID
+	}
+	t, ok := d.tenants[tenantId]
+	if !ok {
+		return Tenant{}, APIKey{}, ErrTenantNotFound
+	}
+	return t, apiKey, nil
+}
+
+// TenantOf returns the tenant owning an account, unknown accounts belong to the default tenant
+func (d *Directory) TenantOf(accountId int) string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if a, ok := d.accounts[accountId]; ok {
+		return a.TenantID
+	}
+	return DEFAULT
+}
+
+func (d *Directory) GetAccount(accountId int) (Account, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	a, ok := d.accounts[accountId]
+	return a, ok
+}
+
+// SubAccounts returns the sub-accounts of a master account by ID
+func (d *Directory) SubAccounts(masterId int) []Account {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	subs := []Account{}
+	for _, a := range d.accounts {
+		if a.MasterID == masterId && masterId != 0 {
+			subs = append(subs, a)
+		}
+	}
+	slices.SortFunc(subs, func(a, b Account) int { return a.ID - b.ID })
+	return subs
+}
+
+// Family is an account followed by its sub-accounts, a sub-account has none
+func (d *Directory) Family(accountId int) []int {
+	family := []int{accountId}
+	for _, sub := range d.SubAccounts(accountId) {
+		family = append(family, sub.ID)
+	}
+	return family
+}
+
+// Related tells if funds can move between two accounts: a master and one of
+// its sub-accounts, or two sub-accounts of the same master
+func (d *Directory) Related(a int, b int) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	master := func(accountId int) int {
+		if m := d.accounts[accountId].MasterID; m != 0 {
+			return m
+		}
+		return accountId
+	}
+	_, okA := d.accounts[a]
+	_, okB := d.accounts[b]
+	return okA && okB && a != b && master(a) == master(b)
+}
+
+func (d *Directory) GetTenants() []Tenant {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	tenants := make([]Tenant, 0, len(d.tenants))
+	for _, t := range d.tenants {
+		tenants = append(tenants, t)
+	}
+	slices.SortFunc(tenants, func(a, b Tenant) int {
+		if a.ID < b.ID {
+			return -1
+		}
+		if a.ID > b.ID {
+			return 1
+		}
+		return 0
+	})
+	return tenants
+}
+
+func (d *Directory) CreateTenant(t Tenant) (Tenant, error) {
+	created, err := d.repo.CreateTenant(t)
+	if err != nil {
+		return Tenant{}, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tenants[created.ID] = created
+	return created, nil
+}
+
+func (d *Directory) UpdateQuotas(tenantId string, quotas Quotas) (Tenant, error) {
+	if _, err := d.repo.UpdateQuotas(tenantId, quotas); err != nil {
+		return Tenant{}, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t := d.tenants[tenantId]
+	t.Quotas = quotas
+	d.tenants[tenantId] = t
+	return t, nil
+}
+
+func (d *Directory) AddPair(tenantId string, pairId string) (Tenant, error) {
+	if err := d.repo.AddPair(tenantId, pairId); err != nil {
+		return Tenant{}, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t := d.tenants[tenantId]
+	if !slices.Contains(t.Pairs, pairId) {
+		t.Pairs = append(slices.Clone(t.Pairs), pairId)
+	}
+	d.tenants[tenantId] = t
+	return t, nil
+}
+
+// CreateAPIKey creates a key of a tenant, bound to one of its accounts unless
+// accountId is 0
+func (d *Directory) CreateAPIKey(tenantId string, accountId int) (APIKey, string, error) {
+	apiKey, key, err := d.repo.CreateAPIKey(tenantId, accountId)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.keys[HashKey(key)] = apiKey
+	return apiKey, key, nil
+}
+
+func (d *Directory) RevokeAPIKey(tenantId string, id int64) (APIKey, error) {
+	return d.revokeAPIKey(id, func(k APIKey) bool { return k.TenantID == tenantId })
+}
+
+// RevokeAccountAPIKey revokes a key bound to an account
+func (d *Directory) RevokeAccountAPIKey(accountId int, id int64) (APIKey, error) {
+	return d.revokeAPIKey(id, func(k APIKey) bool { return k.AccountID == accountId })
+}
+
+// AccountAPIKeys lists the active keys bound to an account
+func (d *Directory) AccountAPIKeys(accountId int) []APIKey {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	keys := []APIKey{}
+	for _, k := range d.keys {
+		if k.AccountID == accountId {
+			keys = append(keys, k)
+		}
+	}
+	slices.SortFunc(keys, func(a, b APIKey) int { return int(a.ID - b.ID) })
+	return keys
+}
+
+func (d *Directory) revokeAPIKey(id int64, owns func(k APIKey) bool) (APIKey, error) {
+	d.mu.RLock()
+	var hash string
+	for h, k := range d.keys {
+		if k.ID == id && owns(k) {
+			hash = h
+		}
+	}
+	d.mu.RUnlock()
+	if hash == "" {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+
+	revoked, err := d.repo.RevokeAPIKey(id)
+	if err != nil {
+		return APIKey{}, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.keys, hash)
+	return revoked, nil
+}
+
+// CreateAccount opens an account in a tenant within its accounts quota
+func (d *Directory) CreateAccount(tenantId string) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.checkAccountsQuota(tenantId); err != nil {
+		return 0, err
+	}
+
+	accountId, err := d.repo.CreateAccount(tenantId)
+	if err != nil {
+		return 0, err
+	}
+	d.accounts[accountId] = Account{ID: accountId, TenantID: tenantId}
+	return accountId, nil
+}
+
+// CreateSubAccount opens a sub-account under a master account, in the tenant
+// of the master and within its accounts quota
+func (d *Directory) CreateSubAccount(masterId int) (Account, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	master, ok := d.accounts[masterId]
+	if !ok {
+		return Account{}, ErrAccountNotFound
+	}
+	if master.MasterID != 0 {
+		return Account{}, ErrNestedSubAccount
+	}
+	if err := d.checkAccountsQuota(master.TenantID); err != nil {
+		return Account{}, err
+	}
+
+	accountId, err := d.repo.CreateSubAccount(master.TenantID, masterId)
+	if err != nil {
+		return Account{}, err
+	}
+	a := Account{ID: accountId, TenantID: master.TenantID, MasterID: masterId}
+	d.accounts[accountId] = a
+	return a, nil
+}
+
+// checkAccountsQuota refuses a new account past the quota of its tenant,
+// callers hold d.mu
+func (d *Directory) checkAccountsQuota(tenantId string) error {
+	t, ok := d.tenants[tenantId]
+	if !ok {
+		return ErrTenantNotFound
+	}
+	if t.Quotas.MaxAccounts > 0 {
+		count := 0
+		for _, a := range d.accounts {
+			if a.TenantID == tenantId {
+				count++
+			}
+		}
+		if count >= t.Quotas.MaxAccounts {
+			return ErrAccountsQuota
+		}
+	}
+	return nil
+}
+
+// SetLimits replaces the trading limits of an account
+func (d *Directory) SetLimits(accountId int, limits Limits) (Account, error) {
+	if _, ok := d.GetAccount(accountId); !ok {
+		return Account{}, ErrAccountNotFound
+	}
+	if err := d.repo.UpdateAccountLimits(accountId, limits); err != nil {
+		return Account{}, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	a := d.accounts[accountId]
+	a.Limits = limits
+	d.accounts[accountId] = a
+	return a, nil
+}
+
+// OrderValidator returns the book.OrderValidator enforcing the isolation and
+// the quotas of a tenant on its book, then the limits of the account
+func (d *Directory) OrderValidator(tenantId string, book OrderCounter) func(o order.Order) error {
+	return func(o order.Order) error {
+		if d.TenantOf(o.AccountID) != tenantId {
+			return ErrAccountNotInTenant
+		}
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		t := d.tenants[tenantId]
+		if len(t.Pairs) > 0 && !slices.Contains(t.Pairs, o.PairID) {
+			return ErrPairNotAllowed
+		}
+		if t.Quotas.MaxOpenOrders > 0 && book.OpenOrders() >= t.Quotas.MaxOpenOrders {
+			return ErrOpenOrdersQuota
+		}
+		if t.Quotas.MaxOrdersPerSecond > 0 {
+			now := time.Now().Unix()
+			w := d.rates[tenantId]
+			if w == nil || w.second != now {
+				w = &rateWindow{second: now}
+				d.rates[tenantId] = w
+			}
+			if w.count >= t.Quotas.MaxOrdersPerSecond {
+				return ErrOrderRateQuota
+			}
+			w.count++
+		}
+
+		limits := d.accounts[o.AccountID].Limits
+		if limits.MaxOpenOrders > 0 && len(book.GetAccountOrders(o.AccountID)) >= limits.MaxOpenOrders {
+			return ErrOpenOrdersLimit
+		}
+		if limits.MaxOrdersPerSecond > 0 {
+			now := time.Now().Unix()
+			w := d.accountRates[o.AccountID]
+			if w == nil || w.second != now {
+				w = &rateWindow{second: now}
+				d.accountRates[o.AccountID] = w
+			}
+			if w.count >= limits.MaxOrdersPerSecond {
+				return ErrOrderRateLimit
+			}
+			w.count++
+		}
+		return nil
+	}
+}
@@ -0,0 +1,106 @@
+package tenant
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"order-book/order"
+	"slices"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	localsKey      = "tenant"
+	scopeLocalsKey = "tenant_scope"
+)
+
+// Middleware resolves the tenant of the request from the X-API-Key header.
+// Websocket clients that can't set headers pass the key as api_key. The key
+// is recorded as the actor of the order changes the request makes. A key bound
+// to an account scopes the request to that account and its sub-accounts.
+func Middleware(dir *Directory) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		t, apiKey, err := dir.Identify(c.Get("X-API-Key", c.Query("api_key")))
+		if err != nil {
+			c.Status(http.StatusUnauthorized)
+			return c.JSON(&Response{
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		c.Locals(localsKey, t)
+		if apiKey.AccountID != 0 {
+			c.Locals(scopeLocalsKey, dir.Family(apiKey.AccountID))
+		}
+		if apiKey.ID != 0 {
+			c.SetUserContext(order.WithActor(c.UserContext(), order.Actor{
+				Type: order.ACTOR_API_KEY,
+				ID:   strconv.FormatInt(apiKey.ID, 10),
+			}))
+		}
+		return c.Next()
+	}
+}
+
+// FromCtx returns the tenant resolved by Middleware
+func FromCtx(c *fiber.Ctx) Tenant {
+	if t, ok := c.Locals(localsKey).(Tenant); ok {
+		return t
+	}
+	return Tenant{ID: DEFAULT}
+}
+
+// Allows tells if the API key of the request reaches an account of its
+// tenant, the keys that aren't bound to an account reach them all
+func Allows(c *fiber.Ctx, accountId int) bool {
+	scope, ok := c.Locals(scopeLocalsKey).([]int)
+	return !ok || slices.Contains(scope, accountId)
+}
+
+// RequireAccount hides accounts of other tenants, and those out of the scope
+// of the API key, on routes with an :id account param
+func RequireAccount(dir *Directory) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Next()
+		}
+		if dir.TenantOf(accountId) != FromCtx(c).ID || !Allows(c, accountId) {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The account not found",
+				Data:    nil,
+			})
+		}
+		return c.Next()
+	}
+}
+
+// RequireOperator restricts a route to the operator of the deployment, the
+// requests carrying its token as X-Operator-Token, or operator_token for the
+// pages opened in a browser. No token, an empty one configured included,
+// isn't the operator: the API key alone, or its absence, never is. The keys
+// of the other tenants and those bound to an account are refused even along
+// with the token.
+func RequireOperator(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		provided := c.Get("X-Operator-Token", c.Query("operator_token"))
+		if provided == "" || token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.Status(http.StatusUnauthorized)
+			return c.JSON(&Response{
+				Message: "An operator token is required for this route",
+				Data:    nil,
+			})
+		}
+		if _, scoped := c.Locals(scopeLocalsKey).([]int); FromCtx(c).ID != DEFAULT || scoped {
+			c.Status(http.StatusForbidden)
+			return c.JSON(&Response{
+				Message: "Only the operator can access this route",
+				Data:    nil,
+			})
+		}
+		c.SetUserContext(order.WithActor(c.UserContext(), order.Actor{Type: order.ACTOR_OPERATOR}))
+		return c.Next()
+	}
+}
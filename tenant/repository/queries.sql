@@ -0,0 +1,40 @@
+-- name: GetTenants :many
+SELECT * FROM tbl_tenants ORDER BY id;
+
+-- name: InsertTenant :one
+INSERT INTO tbl_tenants (id, name, max_open_orders, max_orders_per_second, max_accounts)
+VALUES ($1, $2, $3, $4, $5) RETURNING *;
+
+-- name: UpdateTenantQuotas :one
+UPDATE tbl_tenants SET max_open_orders = $2, max_orders_per_second = $3, max_accounts = $4
+WHERE id = $1
+RETURNING *;
+
+-- name: GetTenantPairs :many
+SELECT * FROM tbl_tenant_pairs ORDER BY tenant_id, pair_id;
+
+-- name: InsertTenantPair :exec
+INSERT INTO tbl_tenant_pairs (tenant_id, pair_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;
+
+-- name: InsertAPIKey :one
+INSERT INTO tbl_api_keys (tenant_id, key_hash, key_prefix, account_id) VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: GetActiveAPIKeys :many
+SELECT * FROM tbl_api_keys WHERE revoked_at IS NULL;
+
+-- name: RevokeAPIKey :one
+UPDATE tbl_api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL RETURNING *;
+
+-- name: GetAccounts :many
+SELECT * FROM tbl_accounts WHERE deleted_at IS NULL;
+
+-- name: InsertAccount :one
+INSERT INTO tbl_accounts (tenant_id) VALUES ($1) RETURNING *;
+
+-- name: InsertSubAccount :one
+INSERT INTO tbl_accounts (tenant_id, master_id) VALUES ($1, $2) RETURNING *;
+
+-- name: UpdateAccountLimits :one
+UPDATE tbl_accounts SET max_open_orders = $2, max_orders_per_second = $3, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
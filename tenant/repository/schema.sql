@@ -0,0 +1,35 @@
+CREATE TABLE tbl_tenants (
+    id VARCHAR(64) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    max_open_orders INTEGER NOT NULL DEFAULT 0,
+    max_orders_per_second INTEGER NOT NULL DEFAULT 0,
+    max_accounts INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE tbl_tenant_pairs (
+    tenant_id VARCHAR(64) NOT NULL REFERENCES tbl_tenants(id),
+    pair_id VARCHAR(25) NOT NULL,
+    PRIMARY KEY (tenant_id, pair_id)
+);
+
+CREATE TABLE tbl_api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL REFERENCES tbl_tenants(id),
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    key_prefix VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMP,
+    account_id INTEGER REFERENCES tbl_accounts(id)
+);
+
+CREATE TABLE tbl_accounts (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP DEFAULT NULL,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default' REFERENCES tbl_tenants(id),
+    master_id INTEGER REFERENCES tbl_accounts(id),
+    max_open_orders INTEGER NOT NULL DEFAULT 0,
+    max_orders_per_second INTEGER NOT NULL DEFAULT 0
+);
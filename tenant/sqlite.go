@@ -0,0 +1,258 @@
+package tenant
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"order-book/db/sqlite"
+)
+
+type sqliteTenantRepo struct {
+	db *sql.DB
+}
+
+const sqliteTenantColumns = "id, name, max_open_orders, max_orders_per_second, max_accounts, created_at"
+
+func scanSQLiteTenant(row interface{ Scan(...any) error }) (t Tenant, err error) {
+	err = row.Scan(&t.ID, &t.Name, &t.Quotas.MaxOpenOrders, &t.Quotas.MaxOrdersPerSecond, &t.Quotas.MaxAccounts, &t.CreatedAt)
+	t.Pairs = []string{}
+	return
+}
+
+const sqliteAPIKeyColumns = "id, tenant_id, key_hash, key_prefix, created_at, revoked_at, account_id"
+
+func scanSQLiteAPIKey(row interface{ Scan(...any) error }) (k APIKey, hash string, err error) {
+	var (
+		revokedAt sql.NullTime
+		accountId sql.NullInt64
+	)
+	err = row.Scan(&k.ID, &k.TenantID, &hash, &k.Prefix, &k.CreatedAt, &revokedAt, &accountId)
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.Time
+	}
+	k.AccountID = int(accountId.Int64)
+	return
+}
+
+func (repo *sqliteTenantRepo) GetTenants() ([]Tenant, error) {
+	ctx := context.Background()
+	pairsByTenant := make(map[string][]string)
+	rows, err := repo.db.QueryContext(ctx, "SELECT tenant_id, pair_id FROM tbl_tenant_pairs ORDER BY tenant_id, pair_id")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var tenantId, pairId string
+		if err := rows.Scan(&tenantId, &pairId); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		pairsByTenant[tenantId] = append(pairsByTenant[tenantId], pairId)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = repo.db.QueryContext(ctx, "SELECT "+sqliteTenantColumns+" FROM tbl_tenants ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tenants := []Tenant{}
+	for rows.Next() {
+		t, err := scanSQLiteTenant(rows)
+		if err != nil {
+			return nil, err
+		}
+		if pairs, ok := pairsByTenant[t.ID]; ok {
+			t.Pairs = pairs
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+func (repo *sqliteTenantRepo) CreateTenant(t Tenant) (Tenant, error) {
+	ctx := context.Background()
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Tenant{}, err
+	}
+	defer tx.Rollback()
+
+	created, err := scanSQLiteTenant(tx.QueryRowContext(ctx,
+		"INSERT INTO tbl_tenants (id, name, max_open_orders, max_orders_per_second, max_accounts, created_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING "+sqliteTenantColumns,
+		t.ID, t.Name, t.Quotas.MaxOpenOrders, t.Quotas.MaxOrdersPerSecond, t.Quotas.MaxAccounts, sqlite.Now(),
+	))
+	if sqlite.IsUniqueViolation(err) {
+		return Tenant{}, ErrTenantExists
+	}
+	if err != nil {
+		return Tenant{}, err
+	}
+	for _, pairId := range t.Pairs {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO tbl_tenant_pairs (tenant_id, pair_id) VALUES (?, ?) ON CONFLICT DO NOTHING",
+			created.ID, pairId,
+		)
+		if err != nil {
+			return Tenant{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return Tenant{}, err
+	}
+	if t.Pairs != nil {
+		created.Pairs = t.Pairs
+	}
+	return created, nil
+}
+
+func (repo *sqliteTenantRepo) UpdateQuotas(tenantId string, quotas Quotas) (Tenant, error) {
+	updated, err := scanSQLiteTenant(repo.db.QueryRowContext(context.Background(),
+		"UPDATE tbl_tenants SET max_open_orders = ?, max_orders_per_second = ?, max_accounts = ? WHERE id = ? RETURNING "+sqliteTenantColumns,
+		quotas.MaxOpenOrders, quotas.MaxOrdersPerSecond, quotas.MaxAccounts, tenantId,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Tenant{}, ErrTenantNotFound
+	}
+	if err != nil {
+		return Tenant{}, err
+	}
+	return updated, nil
+}
+
+func (repo *sqliteTenantRepo) AddPair(tenantId string, pairId string) error {
+	_, err := repo.db.ExecContext(context.Background(),
+		"INSERT INTO tbl_tenant_pairs (tenant_id, pair_id) VALUES (?, ?) ON CONFLICT DO NOTHING",
+		tenantId, pairId,
+	)
+	if sqlite.IsForeignKeyViolation(err) {
+		return ErrTenantNotFound
+	}
+	return err
+}
+
+func (repo *sqliteTenantRepo) CreateAPIKey(tenantId string, accountId int) (APIKey, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return APIKey{}, "", err
+	}
+	key := hex.EncodeToString(secret)
+	created, _, err := scanSQLiteAPIKey(repo.db.QueryRowContext(context.Background(),
+		"INSERT INTO tbl_api_keys (tenant_id, key_hash, key_prefix, created_at, account_id) VALUES (?, ?, ?, ?, ?) RETURNING "+sqliteAPIKeyColumns,
+		tenantId, HashKey(key), key[:8], sqlite.Now(), sql.NullInt64{Int64: int64(accountId), Valid: accountId != 0},
+	))
+	if sqlite.IsForeignKeyViolation(err) {
+		return APIKey{}, "", ErrTenantNotFound
+	}
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	return created, key, nil
+}
+
+func (repo *sqliteTenantRepo) GetActiveAPIKeys() (map[string]APIKey, error) {
+	rows, err := repo.db.QueryContext(context.Background(),
+		"SELECT "+sqliteAPIKeyColumns+" FROM tbl_api_keys WHERE revoked_at IS NULL",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := make(map[string]APIKey)
+	for rows.Next() {
+		k, hash, err := scanSQLiteAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys[hash] = k
+	}
+	return keys, rows.Err()
+}
+
+func (repo *sqliteTenantRepo) RevokeAPIKey(id int64) (APIKey, error) {
+	revoked, _, err := scanSQLiteAPIKey(repo.db.QueryRowContext(context.Background(),
+		"UPDATE tbl_api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL RETURNING "+sqliteAPIKeyColumns,
+		sqlite.Now(), id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return APIKey{}, err
+	}
+	return revoked, nil
+}
+
+func (repo *sqliteTenantRepo) GetAccounts() ([]Account, error) {
+	rows, err := repo.db.QueryContext(context.Background(),
+		"SELECT id, tenant_id, master_id, max_open_orders, max_orders_per_second FROM tbl_accounts WHERE deleted_at IS NULL",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	accounts := []Account{}
+	for rows.Next() {
+		var (
+			a        Account
+			masterId sql.NullInt64
+		)
+		if err := rows.Scan(&a.ID, &a.TenantID, &masterId, &a.Limits.MaxOpenOrders, &a.Limits.MaxOrdersPerSecond); err != nil {
+			return nil, err
+		}
+		a.MasterID = int(masterId.Int64)
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+func (repo *sqliteTenantRepo) CreateAccount(tenantId string) (int, error) {
+	now := sqlite.Now()
+	var id int
+	err := repo.db.QueryRowContext(context.Background(),
+		"INSERT INTO tbl_accounts (tenant_id, created_at, updated_at) VALUES (?, ?, ?) RETURNING id",
+		tenantId, now, now,
+	).Scan(&id)
+	if sqlite.IsForeignKeyViolation(err) {
+		return 0, ErrTenantNotFound
+	}
+	return id, err
+}
+
+func (repo *sqliteTenantRepo) CreateSubAccount(tenantId string, masterId int) (int, error) {
+	now := sqlite.Now()
+	var id int
+	err := repo.db.QueryRowContext(context.Background(),
+		"INSERT INTO tbl_accounts (tenant_id, master_id, created_at, updated_at) VALUES (?, ?, ?, ?) RETURNING id",
+		tenantId, masterId, now, now,
+	).Scan(&id)
+	if sqlite.IsForeignKeyViolation(err) {
+		return 0, ErrAccountNotFound
+	}
+	return id, err
+}
+
+func (repo *sqliteTenantRepo) UpdateAccountLimits(accountId int, limits Limits) error {
+	res, err := repo.db.ExecContext(context.Background(),
+		"UPDATE tbl_accounts SET max_open_orders = ?, max_orders_per_second = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL",
+		limits.MaxOpenOrders, limits.MaxOrdersPerSecond, sqlite.Now(), accountId,
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err == nil && affected == 0 {
+		return ErrAccountNotFound
+	}
+	return err
+}
+
+// NewSQLiteTenantRepository is NewTenantRepository on a SQLite database
+func NewSQLiteTenantRepository(db *sql.DB) TenantRepo {
+	return &sqliteTenantRepo{db: db}
+}
@@ -0,0 +1,348 @@
+package tenant
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	repository "order-book/tenant/repository/gen"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrTenantNotFound     = errors.New("Tenant not found")
+	ErrTenantExists       = errors.New("Tenant already exists")
+	ErrAPIKeyNotFound     = errors.New("API key not found")
+	ErrInvalidAPIKey      = errors.New("Invalid API key")
+	ErrAccountNotInTenant = errors.New("Account does not belong to this tenant")
+	ErrPairNotAllowed     = errors.New("Pair is not available for this tenant")
+	ErrOpenOrdersQuota    = errors.New("Open orders quota of the tenant exceeded")
+	ErrOrderRateQuota     = errors.New("Order rate quota of the tenant exceeded")
+	ErrAccountsQuota      = errors.New("Accounts quota of the tenant exceeded")
+	ErrAccountNotFound    = errors.New("Account not found")
+	ErrNestedSubAccount   = errors.New("A sub-account can't have sub-accounts")
+	ErrOpenOrdersLimit    = errors.New("Open orders limit of the account exceeded")
+	ErrOrderRateLimit     = errors.New("Order rate limit of the account exceeded")
+	ErrAccountNotInScope  = errors.New("The API key doesn't reach this account")
+)
+
+// DEFAULT is the tenant of requests without an API key and of accounts that
+// existed before multi-tenancy
+const DEFAULT = "default"
+
+// Quotas bound the resources of a tenant, zero means unlimited
+type Quotas struct {
+	MaxOpenOrders      int `json:"max_open_orders"`
+	MaxOrdersPerSecond int `json:"max_orders_per_second"`
+	MaxAccounts        int `json:"max_accounts"`
+}
+
+type Tenant struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Pairs is the pair universe of the tenant, empty allows every pair
+	Pairs     []string  `json:"pairs"`
+	Quotas    Quotas    `json:"quotas"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Limits bound the trading of an account on top of the quotas of its tenant,
+// zero means unlimited
+type Limits struct {
+	MaxOpenOrders      int `json:"max_open_orders"`
+	MaxOrdersPerSecond int `json:"max_orders_per_second"`
+}
+
+// Account is an account of a tenant. A sub-account has the ID of its master
+// account as MasterID, masters and the accounts without sub-accounts have 0.
+type Account struct {
+	ID       int    `json:"id"`
+	TenantID string `json:"tenant_id"`
+	MasterID int    `json:"master_id,omitempty"`
+	Limits   Limits `json:"limits"`
+}
+
+// APIKey identifies the tenant of a request. Only a hash of the key is stored,
+// the key itself is returned once when it is created. A key with an AccountID
+// only reaches that account and, for a master, its sub-accounts.
+type APIKey struct {
+	ID        int64      `json:"id"`
+	TenantID  string     `json:"tenant_id"`
+	AccountID int        `json:"account_id,omitempty"`
+	Prefix    string     `json:"prefix"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type TenantRepo interface {
+	GetTenants() ([]Tenant, error)
+	CreateTenant(t Tenant) (Tenant, error)
+	UpdateQuotas(tenantId string, quotas Quotas) (Tenant, error)
+	AddPair(tenantId string, pairId string) error
+	// CreateAPIKey returns the stored key along with the plain key, the key
+	// reaches the whole tenant when accountId is 0
+	CreateAPIKey(tenantId string, accountId int) (APIKey, string, error)
+	// GetActiveAPIKeys returns the keys that are not revoked by their hash
+	GetActiveAPIKeys() (map[string]APIKey, error)
+	RevokeAPIKey(id int64) (APIKey, error)
+	GetAccounts() ([]Account, error)
+	CreateAccount(tenantId string) (int, error)
+	CreateSubAccount(tenantId string, masterId int) (int, error)
+	UpdateAccountLimits(accountId int, limits Limits) error
+}
+
+type tenantRepo struct {
+	queries *repository.Queries
+	dbpool  *pgxpool.Pool
+}
+
+func (repo *tenantRepo) GetTenants() ([]Tenant, error) {
+	ctx := context.Background()
+	dbres, err := repo.queries.GetTenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pairs, err := repo.queries.GetTenantPairs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pairsByTenant := make(map[string][]string)
+	for _, p := range pairs {
+		pairsByTenant[p.TenantID] = append(pairsByTenant[p.TenantID], p.PairID)
+	}
+
+	tenants := make([]Tenant, len(dbres))
+	for idx, t := range dbres {
+		tenants[idx] = convertTenant(t, pairsByTenant[t.ID])
+	}
+	return tenants, nil
+}
+
+func (repo *tenantRepo) CreateTenant(t Tenant) (Tenant, error) {
+	ctx := context.Background()
+	tx, err := repo.dbpool.Begin(ctx)
+	if err != nil {
+		return Tenant{}, err
+	}
+	defer tx.Rollback(ctx)
+	qtx := repo.queries.WithTx(tx)
+
+	created, err := qtx.InsertTenant(ctx, repository.InsertTenantParams{
+		ID:                 t.ID,
+		Name:               t.Name,
+		MaxOpenOrders:      int32(t.Quotas.MaxOpenOrders),
+		MaxOrdersPerSecond: int32(t.Quotas.MaxOrdersPerSecond),
+		MaxAccounts:        int32(t.Quotas.MaxAccounts),
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return Tenant{}, ErrTenantExists
+	}
+	if err != nil {
+		return Tenant{}, err
+	}
+	for _, pairId := range t.Pairs {
+		err := qtx.InsertTenantPair(ctx, repository.InsertTenantPairParams{
+			TenantID: created.ID,
+			PairID:   pairId,
+		})
+		if err != nil {
+			return Tenant{}, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Tenant{}, err
+	}
+	return convertTenant(created, t.Pairs), nil
+}
+
+func (repo *tenantRepo) UpdateQuotas(tenantId string, quotas Quotas) (Tenant, error) {
+	updated, err := repo.queries.UpdateTenantQuotas(context.Background(), repository.UpdateTenantQuotasParams{
+		ID:                 tenantId,
+		MaxOpenOrders:      int32(quotas.MaxOpenOrders),
+		MaxOrdersPerSecond: int32(quotas.MaxOrdersPerSecond),
+		MaxAccounts:        int32(quotas.MaxAccounts),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Tenant{}, ErrTenantNotFound
+	}
+	if err != nil {
+		return Tenant{}, err
+	}
+	return convertTenant(updated, nil), nil
+}
+
+func (repo *tenantRepo) AddPair(tenantId string, pairId string) error {
+	err := repo.queries.InsertTenantPair(context.Background(), repository.InsertTenantPairParams{
+		TenantID: tenantId,
+		PairID:   pairId,
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return ErrTenantNotFound
+	}
+	return err
+}
+
+func (repo *tenantRepo) CreateAPIKey(tenantId string, accountId int) (APIKey, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return APIKey{}, "", err
+	}
+	key := hex.EncodeToString(secret)
+	created, err := repo.queries.InsertAPIKey(context.Background(), repository.InsertAPIKeyParams{
+		TenantID:  tenantId,
+		KeyHash:   HashKey(key),
+		KeyPrefix: key[:8],
+		AccountID: pgtype.Int4{Int32: int32(accountId), Valid: accountId != 0},
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return APIKey{}, "", ErrTenantNotFound
+	}
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	return convertAPIKey(created), key, nil
+}
+
+func (repo *tenantRepo) GetActiveAPIKeys() (map[string]APIKey, error) {
+	dbres, err := repo.queries.GetActiveAPIKeys(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]APIKey, len(dbres))
+	for _, k := range dbres {
+		keys[k.KeyHash] = convertAPIKey(k)
+	}
+	return keys, nil
+}
+
+func (repo *tenantRepo) RevokeAPIKey(id int64) (APIKey, error) {
+	revoked, err := repo.queries.RevokeAPIKey(context.Background(), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return APIKey{}, err
+	}
+	return convertAPIKey(revoked), nil
+}
+
+func (repo *tenantRepo) GetAccounts() ([]Account, error) {
+	dbres, err := repo.queries.GetAccounts(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]Account, len(dbres))
+	for idx, a := range dbres {
+		accounts[idx] = convertAccount(a)
+	}
+	return accounts, nil
+}
+
+func (repo *tenantRepo) CreateAccount(tenantId string) (int, error) {
+	created, err := repo.queries.InsertAccount(context.Background(), tenantId)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return 0, ErrTenantNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int(created.ID), nil
+}
+
+func (repo *tenantRepo) CreateSubAccount(tenantId string, masterId int) (int, error) {
+	created, err := repo.queries.InsertSubAccount(context.Background(), repository.InsertSubAccountParams{
+		TenantID: tenantId,
+		MasterID: pgtype.Int4{Int32: int32(masterId), Valid: true},
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return 0, ErrAccountNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int(created.ID), nil
+}
+
+func (repo *tenantRepo) UpdateAccountLimits(accountId int, limits Limits) error {
+	_, err := repo.queries.UpdateAccountLimits(context.Background(), repository.UpdateAccountLimitsParams{
+		ID:                 int32(accountId),
+		MaxOpenOrders:      int32(limits.MaxOpenOrders),
+		MaxOrdersPerSecond: int32(limits.MaxOrdersPerSecond),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrAccountNotFound
+	}
+	return err
+}
+
+func NewTenantRepository(dbpool *pgxpool.Pool) TenantRepo {
+	return &tenantRepo{
+		queries: repository.New(dbpool),
+		dbpool:  dbpool,
+	}
+}
+
+// HashKey is the form an API key is stored and looked up in
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func convertTenant(t repository.TblTenant, pairs []string) Tenant {
+	if pairs == nil {
+		pairs = []string{}
+	}
+	return Tenant{
+		ID:    t.ID,
+		Name:  t.Name,
+		Pairs: pairs,
+		Quotas: Quotas{
+			MaxOpenOrders:      int(t.MaxOpenOrders),
+			MaxOrdersPerSecond: int(t.MaxOrdersPerSecond),
+			MaxAccounts:        int(t.MaxAccounts),
+		},
+		CreatedAt: t.CreatedAt.Time,
+	}
+}
+
+func convertAPIKey(k repository.TblApiKey) APIKey {
+	res := APIKey{
+		ID:        k.ID,
+		TenantID:  k.TenantID,
+		Prefix:    k.KeyPrefix,
+		CreatedAt: k.CreatedAt.Time,
+	}
+	if k.AccountID.Valid {
+		res.AccountID = int(k.AccountID.Int32)
+	}
+	if k.RevokedAt.Valid {
+		res.RevokedAt = &k.RevokedAt.Time
+	}
+	return res
+}
+
+func convertAccount(a repository.TblAccount) Account {
+	res := Account{
+		ID:       int(a.ID),
+		TenantID: a.TenantID,
+		Limits: Limits{
+			MaxOpenOrders:      int(a.MaxOpenOrders),
+			MaxOrdersPerSecond: int(a.MaxOrdersPerSecond),
+		},
+	}
+	if a.MasterID.Valid {
+		res.MasterID = int(a.MasterID.Int32)
+	}
+	return res
+}
@@ -0,0 +1,149 @@
+package tlscert
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"order-book/logger"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+var ErrNoCertificate = errors.New("no certificate is loaded")
+
+// Reloader serves the certificate of a cert and key file pair, and loads them
+// again once either changed on disk, like when a renewal replaced them
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewReloader loads the certificate of certFile and keyFile, both PEM encoded
+func NewReloader(certFile string, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate is the tls.Config hook serving the loaded certificate
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.cert == nil {
+		return nil, ErrNoCertificate
+	}
+	return r.cert, nil
+}
+
+// Reload loads the files again if either was modified since they were last
+// loaded, and reports whether it did. A pair that fails to load keeps the
+// previous certificate in use.
+func (r *Reloader) Reload() (bool, error) {
+	modTime, err := r.lastModified()
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	unchanged := r.cert != nil && modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return false, err
+		}
+	}
+	r.mu.Lock()
+	r.cert, r.modTime = &cert, modTime
+	r.mu.Unlock()
+	return true, nil
+}
+
+// Run checks the files for changes every interval until ctx is done
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.Reload()
+			if err != nil {
+				logger.Error("failed to reload the TLS certificate", map[string]any{
+					"cert_file": r.certFile,
+					"error":     err,
+				})
+				continue
+			}
+			if reloaded {
+				r.mu.RLock()
+				leaf := r.cert.Leaf
+				r.mu.RUnlock()
+				logger.Info("reloaded the TLS certificate", map[string]any{
+					"cert_file": r.certFile,
+					"subject":   leaf.Subject.String(),
+					"not_after": leaf.NotAfter,
+				})
+			}
+		}
+	}
+}
+
+// lastModified is the latest modification time of the two files, the key and
+// the certificate of a renewal may be written one after the other
+func (r *Reloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// Config is the server TLS config serving the certificates of getCertificate
+func Config(getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"http/1.1"},
+		GetCertificate: getCertificate,
+	}
+}
+
+// ACME is the server TLS config obtaining and renewing the certificates of
+// domains from Let's Encrypt, cached in cacheDir. The tls-alpn-01 challenge
+// is answered on the TLS listener itself, it has to be reachable on port 443
+// of the domains.
+func ACME(domains []string, email string, cacheDir string) *tls.Config {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      email,
+	}
+	cfg := Config(m.GetCertificate)
+	cfg.NextProtos = append(cfg.NextProtos, acme.ALPNProto)
+	return cfg
+}
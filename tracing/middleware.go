@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// headerCarrier reads and writes the trace context on fiber headers
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+func (h headerCarrier) Get(key string) string {
+	return h.c.Get(key)
+}
+
+func (h headerCarrier) Set(key string, value string) {
+	h.c.Set(key, value)
+}
+
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, 0)
+	h.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
+// Middleware starts a server span for every request, continuing the trace of
+// the caller when it sent a traceparent header. Handlers get the span through
+// c.UserContext() and the trace id is returned as X-Trace-Id.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{c: c})
+		// The method is a view of the fasthttp buffer that gets reused
+		method := strings.Clone(c.Method())
+		ctx, span := Tracer.Start(ctx, method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", method),
+				attribute.String("url.path", strings.Clone(c.Path())),
+			),
+		)
+		defer span.End()
+		if span.SpanContext().IsValid() {
+			c.Set("X-Trace-Id", span.SpanContext().TraceID().String())
+		}
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		status := c.Response().StatusCode()
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+		} else if err != nil {
+			status = fiber.StatusInternalServerError
+		}
+		// Fiber's own 404 leaves the route of the last middleware
+		if status != fiber.StatusNotFound || err == nil {
+			route := c.Route().Path
+			span.SetName(method + " " + route)
+			span.SetAttributes(attribute.String("http.route", route))
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if err != nil {
+			span.RecordError(err)
+		}
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, "")
+		}
+		return err
+	}
+}
@@ -0,0 +1,154 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"order-book/logger"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	ErrFieldRequired = errors.New("ErrFieldRequired")
+	ErrInvalidData   = errors.New("ErrInvalidData")
+)
+
+type Response struct {
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	Error   error
+}
+
+type registerRequest struct {
+	URL string `json:"url"`
+}
+
+func BindWebhookRouter(r fiber.Router, dispatcher *Dispatcher) {
+	r.Post("/accounts/:id/webhooks", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+
+		var req registerRequest
+		if err := c.BodyParser(&req); err != nil {
+			return err
+		}
+		if req.URL == "" {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrFieldRequired,
+				Message: "Please provide a url",
+			})
+		}
+		if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Error:   ErrInvalidData,
+				Message: "The url should be an absolute http or https URL",
+			})
+		}
+
+		w, err := dispatcher.Register(accountId, req.URL)
+		if err != nil {
+			logger.Error("failed to register webhook", map[string]any{
+				"account_id": accountId,
+				"error":      err,
+			})
+			return err
+		}
+		c.Status(http.StatusCreated)
+		return c.JSON(&Response{
+			Message: "Store the secret now, it is used to sign the payloads and can't be retrieved again",
+			Data: map[string]any{
+				"webhook": w,
+				"secret":  w.Secret,
+			},
+		})
+	})
+
+	r.Get("/accounts/:id/webhooks", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    dispatcher.GetWebhooks(accountId),
+		})
+	})
+
+	r.Delete("/accounts/:id/webhooks/:webhook_id", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		webhookId, err := strconv.ParseInt(c.Params("webhook_id"), 10, 64)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid ID",
+				Data:    nil,
+			})
+		}
+
+		ok, err := dispatcher.Remove(accountId, webhookId)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			c.Status(http.StatusNotFound)
+			return c.JSON(&Response{
+				Message: "The webhook not found",
+				Data:    nil,
+			})
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "Webhook removed",
+			Data:    nil,
+		})
+	})
+
+	r.Get("/accounts/:id/webhooks/deliveries", func(c *fiber.Ctx) error {
+		accountId, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return c.JSON(&Response{
+				Message: "Invalid account ID",
+				Data:    nil,
+			})
+		}
+		page := c.QueryInt("page", 1)
+		size := c.QueryInt("size", 50)
+
+		deliveries, err := dispatcher.GetDeliveries(accountId, page, size)
+		if err != nil {
+			logger.Error("failed to get webhook deliveries", map[string]any{
+				"account_id": accountId,
+				"error":      err,
+			})
+			return err
+		}
+		c.Status(http.StatusOK)
+		return c.JSON(&Response{
+			Message: "",
+			Data:    deliveries,
+		})
+	})
+}
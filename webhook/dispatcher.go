@@ -0,0 +1,290 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	mrand "math/rand/v2"
+	"net/http"
+	"order-book/logger"
+	"order-book/order"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	maxAttempts    = 8
+	baseBackoff    = time.Second
+	maxBackoff     = 5 * time.Minute
+	requestTimeout = 10 * time.Second
+	senders        = 4
+)
+
+// Payload is the JSON body posted to a webhook
+type Payload struct {
+	Event     Event        `json:"event"`
+	AccountID int          `json:"account_id"`
+	Order     *order.Order `json:"order,omitempty"`
+	Trade     *order.Trade `json:"trade,omitempty"`
+	At        time.Time    `json:"at"`
+}
+
+// Dispatcher posts order events to the webhooks of the accounts involved.
+// Every delivery is logged before the first attempt so it survives restarts,
+// failed attempts are retried with exponential backoff.
+type Dispatcher struct {
+	mu         sync.RWMutex
+	repo       WebhookRepo
+	client     *http.Client
+	webhooks   map[int64]Webhook
+	byAccount  map[int][]int64
+	payloads   chan Payload
+	deliveries chan Delivery
+}
+
+func NewDispatcher(repo WebhookRepo) (*Dispatcher, error) {
+	webhooks, err := repo.GetWebhooks()
+	if err != nil {
+		return nil, err
+	}
+	pending, err := repo.GetPendingDeliveries()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Dispatcher{
+		repo:       repo,
+		client:     &http.Client{Timeout: requestTimeout},
+		webhooks:   make(map[int64]Webhook),
+		byAccount:  make(map[int][]int64),
+		payloads:   make(chan Payload, 4096),
+		deliveries: make(chan Delivery, 4096),
+	}
+	for _, w := range webhooks {
+		d.add(w)
+	}
+
+	go func() {
+		for p := range d.payloads {
+			d.fanOut(p)
+		}
+	}()
+	for range senders {
+		go func() {
+			for delivery := range d.deliveries {
+				d.attempt(delivery)
+			}
+		}()
+	}
+	for _, delivery := range pending {
+		d.schedule(delivery)
+	}
+	return d, nil
+}
+
+func (d *Dispatcher) OnOrderEvent(ev order.OrderEvent) {
+	var event Event
+	switch ev.Name {
+	case order.ORDER_CREATED:
+		event = ORDER_ACCEPTED
+	case order.ORDER_CANCELLED:
+		event = ORDER_CANCELLED
+	default:
+		return
+	}
+	o := ev.Order
+	d.enqueue(Payload{Event: event, AccountID: o.AccountID, Order: &o, At: ev.At})
+}
+
+func (d *Dispatcher) OnTrade(t order.Trade) {
+	d.enqueue(Payload{Event: ORDER_FILLED, AccountID: t.MakerAccountID, Trade: &t, At: t.ExecutedAt})
+	if t.TakerAccountID != t.MakerAccountID {
+		d.enqueue(Payload{Event: ORDER_FILLED, AccountID: t.TakerAccountID, Trade: &t, At: t.ExecutedAt})
+	}
+}
+
+func (d *Dispatcher) enqueue(p Payload) {
+	d.mu.RLock()
+	registered := len(d.byAccount[p.AccountID]) > 0
+	d.mu.RUnlock()
+	if !registered {
+		return
+	}
+	select {
+	case d.payloads <- p:
+	default:
+		logger.Warn("webhook queue is full, event dropped", map[string]any{
+			"event":      p.Event,
+			"account_id": p.AccountID,
+		})
+	}
+}
+
+// Register adds a webhook to an account. The returned secret signs the payloads.
+func (d *Dispatcher) Register(accountId int, url string) (Webhook, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return Webhook{}, err
+	}
+	w, err := d.repo.CreateWebhook(accountId, url, hex.EncodeToString(secret))
+	if err != nil {
+		return Webhook{}, err
+	}
+	d.add(w)
+	return w, nil
+}
+
+func (d *Dispatcher) Remove(accountId int, id int64) (bool, error) {
+	ok, err := d.repo.DeleteWebhook(id, accountId)
+	if err != nil || !ok {
+		return ok, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.webhooks, id)
+	ids := d.byAccount[accountId]
+	for idx, webhookId := range ids {
+		if webhookId == id {
+			d.byAccount[accountId] = append(ids[:idx:idx], ids[idx+1:]...)
+			break
+		}
+	}
+	return true, nil
+}
+
+func (d *Dispatcher) GetWebhooks(accountId int) []Webhook {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	webhooks := make([]Webhook, 0, len(d.byAccount[accountId]))
+	for _, id := range d.byAccount[accountId] {
+		webhooks = append(webhooks, d.webhooks[id])
+	}
+	return webhooks
+}
+
+func (d *Dispatcher) GetDeliveries(accountId int, page int, size int) ([]Delivery, error) {
+	return d.repo.GetDeliveries(accountId, page, size)
+}
+
+func (d *Dispatcher) add(w Webhook) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.webhooks[w.ID] = w
+	d.byAccount[w.AccountID] = append(d.byAccount[w.AccountID], w.ID)
+}
+
+func (d *Dispatcher) fanOut(p Payload) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		logger.Error("failed to marshal webhook payload", map[string]any{
+			"event": p.Event,
+			"error": err,
+		})
+		return
+	}
+	for _, w := range d.GetWebhooks(p.AccountID) {
+		delivery, err := d.repo.CreateDelivery(w.ID, p.AccountID, p.Event, body)
+		if err != nil {
+			logger.Error("failed to log webhook delivery", map[string]any{
+				"webhook_id": w.ID,
+				"event":      p.Event,
+				"error":      err,
+			})
+			continue
+		}
+		d.deliveries <- delivery
+	}
+}
+
+// schedule queues a delivery for its next attempt
+func (d *Dispatcher) schedule(delivery Delivery) {
+	time.AfterFunc(time.Until(delivery.NextAttemptAt), func() {
+		d.deliveries <- delivery
+	})
+}
+
+func (d *Dispatcher) attempt(delivery Delivery) {
+	d.mu.RLock()
+	w, ok := d.webhooks[delivery.WebhookID]
+	d.mu.RUnlock()
+
+	delivery.Attempts++
+	delivery.LastStatusCode = 0
+	delivery.LastError = ""
+	if !ok {
+		delivery.Status = FAILED
+		delivery.LastError = "webhook was removed"
+	} else if statusCode, err := d.post(w, delivery); err != nil {
+		delivery.LastStatusCode = statusCode
+		delivery.LastError = err.Error()
+		delivery.Status = PENDING
+		if delivery.Attempts >= maxAttempts {
+			delivery.Status = FAILED
+		}
+	} else {
+		delivery.LastStatusCode = statusCode
+		delivery.Status = DELIVERED
+	}
+	if delivery.Status == PENDING {
+		delivery.NextAttemptAt = time.Now().Add(backoff(delivery.Attempts))
+	}
+
+	if err := d.repo.UpdateDelivery(delivery); err != nil {
+		logger.Error("failed to update webhook delivery", map[string]any{
+			"delivery_id": delivery.ID,
+			"error":       err,
+		})
+	}
+	switch delivery.Status {
+	case PENDING:
+		d.schedule(delivery)
+	case FAILED:
+		logger.Warn("webhook delivery failed", map[string]any{
+			"delivery_id": delivery.ID,
+			"webhook_id":  delivery.WebhookID,
+			"attempts":    delivery.Attempts,
+			"error":       delivery.LastError,
+		})
+	}
+}
+
+// post sends a delivery signed with the webhook secret. The signature is the
+// hex HMAC-SHA256 of "<timestamp>.<body>" so receivers can reject replays.
+func (d *Dispatcher) post(w Webhook, delivery Delivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(w.Secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(delivery.Payload)
+
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Webhook-Event", string(delivery.Event))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff doubles the delay after each attempt, with jitter so retries of
+// many deliveries to one endpoint don't arrive together
+func backoff(attempts int) time.Duration {
+	delay := min(baseBackoff<<(attempts-1), maxBackoff)
+	return delay/2 + mrand.N(delay/2+1)
+}
@@ -0,0 +1,24 @@
+-- name: InsertWebhook :one
+INSERT INTO tbl_webhooks (account_id, url, secret) VALUES ($1, $2, $3) RETURNING *;
+
+-- name: GetActiveWebhooks :many
+SELECT * FROM tbl_webhooks WHERE deleted_at IS NULL ORDER BY id;
+
+-- name: DeleteWebhook :execrows
+UPDATE tbl_webhooks SET deleted_at = NOW() WHERE id = $1 AND account_id = $2 AND deleted_at IS NULL;
+
+-- name: InsertDelivery :one
+INSERT INTO tbl_webhook_deliveries (webhook_id, account_id, event, payload)
+VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: UpdateDeliveryAttempt :exec
+UPDATE tbl_webhook_deliveries
+SET status = $2, attempts = $3, last_status_code = $4, last_error = $5, next_attempt_at = $6,
+    delivered_at = CASE WHEN $2 = 'DELIVERED' THEN NOW() ELSE delivered_at END
+WHERE id = $1;
+
+-- name: GetPendingDeliveries :many
+SELECT * FROM tbl_webhook_deliveries WHERE status = 'PENDING' ORDER BY id;
+
+-- name: GetDeliveriesByAccountID :many
+SELECT * FROM tbl_webhook_deliveries WHERE account_id = $1 ORDER BY id DESC LIMIT $2 OFFSET $3;
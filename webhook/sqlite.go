@@ -0,0 +1,140 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"order-book/db/sqlite"
+)
+
+type sqliteWebhookRepo struct {
+	db *sql.DB
+}
+
+const sqliteWebhookColumns = "id, account_id, url, secret, created_at"
+
+func scanSQLiteWebhook(row interface{ Scan(...any) error }) (w Webhook, err error) {
+	err = row.Scan(&w.ID, &w.AccountID, &w.URL, &w.Secret, &w.CreatedAt)
+	return
+}
+
+const sqliteDeliveryColumns = `id, webhook_id, account_id, event, payload, status, attempts,
+	last_status_code, last_error, next_attempt_at, created_at, delivered_at`
+
+func scanSQLiteDelivery(row interface{ Scan(...any) error }) (Delivery, error) {
+	var (
+		d              Delivery
+		payload        string
+		lastStatusCode sql.NullInt64
+		lastError      sql.NullString
+		deliveredAt    sql.NullTime
+	)
+	err := row.Scan(
+		&d.ID, &d.WebhookID, &d.AccountID, &d.Event, &payload, &d.Status, &d.Attempts,
+		&lastStatusCode, &lastError, &d.NextAttemptAt, &d.CreatedAt, &deliveredAt,
+	)
+	d.Payload = json.RawMessage(payload)
+	d.LastStatusCode = int(lastStatusCode.Int64)
+	d.LastError = lastError.String
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	return d, err
+}
+
+func (repo *sqliteWebhookRepo) queryDeliveries(query string, args ...any) ([]Delivery, error) {
+	rows, err := repo.db.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := []Delivery{}
+	for rows.Next() {
+		d, err := scanSQLiteDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (repo *sqliteWebhookRepo) CreateWebhook(accountId int, url string, secret string) (Webhook, error) {
+	return scanSQLiteWebhook(repo.db.QueryRowContext(context.Background(),
+		"INSERT INTO tbl_webhooks (account_id, url, secret, created_at) VALUES (?, ?, ?, ?) RETURNING "+sqliteWebhookColumns,
+		accountId, url, secret, sqlite.Now(),
+	))
+}
+
+func (repo *sqliteWebhookRepo) GetWebhooks() ([]Webhook, error) {
+	rows, err := repo.db.QueryContext(context.Background(),
+		"SELECT "+sqliteWebhookColumns+" FROM tbl_webhooks WHERE deleted_at IS NULL ORDER BY id",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	webhooks := []Webhook{}
+	for rows.Next() {
+		w, err := scanSQLiteWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+func (repo *sqliteWebhookRepo) DeleteWebhook(id int64, accountId int) (bool, error) {
+	res, err := repo.db.ExecContext(context.Background(),
+		"UPDATE tbl_webhooks SET deleted_at = ? WHERE id = ? AND account_id = ? AND deleted_at IS NULL",
+		sqlite.Now(), id, accountId,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+func (repo *sqliteWebhookRepo) CreateDelivery(webhookId int64, accountId int, event Event, payload json.RawMessage) (Delivery, error) {
+	now := sqlite.Now()
+	return scanSQLiteDelivery(repo.db.QueryRowContext(context.Background(),
+		"INSERT INTO tbl_webhook_deliveries (webhook_id, account_id, event, payload, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING "+sqliteDeliveryColumns,
+		webhookId, accountId, string(event), string(payload), now, now,
+	))
+}
+
+func (repo *sqliteWebhookRepo) UpdateDelivery(d Delivery) error {
+	_, err := repo.db.ExecContext(context.Background(), `UPDATE tbl_webhook_deliveries
+		SET status = ?2, attempts = ?3, last_status_code = ?4, last_error = ?5, next_attempt_at = ?6,
+		    delivered_at = CASE WHEN ?2 = 'DELIVERED' THEN ?7 ELSE delivered_at END
+		WHERE id = ?1`,
+		d.ID,
+		string(d.Status),
+		d.Attempts,
+		sql.NullInt64{Int64: int64(d.LastStatusCode), Valid: d.LastStatusCode != 0},
+		sql.NullString{String: d.LastError, Valid: d.LastError != ""},
+		d.NextAttemptAt.UTC(),
+		sqlite.Now(),
+	)
+	return err
+}
+
+func (repo *sqliteWebhookRepo) GetPendingDeliveries() ([]Delivery, error) {
+	return repo.queryDeliveries(
+		"SELECT " + sqliteDeliveryColumns + " FROM tbl_webhook_deliveries WHERE status = 'PENDING' ORDER BY id",
+	)
+}
+
+func (repo *sqliteWebhookRepo) GetDeliveries(accountId int, page int, size int) ([]Delivery, error) {
+	return repo.queryDeliveries(
+		"SELECT "+sqliteDeliveryColumns+" FROM tbl_webhook_deliveries WHERE account_id = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+		accountId, size, max(0, page-1)*size,
+	)
+}
+
+// NewSQLiteWebhookRepository is NewWebhookRepository on a SQLite database
+func NewSQLiteWebhookRepository(db *sql.DB) WebhookRepo {
+	return &sqliteWebhookRepo{db: db}
+}
@@ -0,0 +1,186 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	repository "order-book/webhook/repository/gen"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Event string
+
+const (
+	ORDER_ACCEPTED  Event = "order.accepted"
+	ORDER_FILLED    Event = "order.filled"
+	ORDER_CANCELLED Event = "order.cancelled"
+)
+
+type DeliveryStatus string
+
+const (
+	PENDING   DeliveryStatus = "PENDING"
+	DELIVERED DeliveryStatus = "DELIVERED"
+	FAILED    DeliveryStatus = "FAILED"
+)
+
+type Webhook struct {
+	ID        int64     `json:"id"`
+	AccountID int       `json:"account_id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery is one event sent to one webhook, along with the outcome of its last attempt
+type Delivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	AccountID      int             `json:"account_id"`
+	Event          Event           `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+type WebhookRepo interface {
+	CreateWebhook(accountId int, url string, secret string) (Webhook, error)
+	GetWebhooks() ([]Webhook, error)
+	// DeleteWebhook returns false if the account has no such webhook
+	DeleteWebhook(id int64, accountId int) (bool, error)
+	CreateDelivery(webhookId int64, accountId int, event Event, payload json.RawMessage) (Delivery, error)
+	UpdateDelivery(d Delivery) error
+	GetPendingDeliveries() ([]Delivery, error)
+	GetDeliveries(accountId int, page int, size int) ([]Delivery, error)
+}
+
+type webhookRepo struct {
+	queries *repository.Queries
+}
+
+func (repo *webhookRepo) CreateWebhook(accountId int, url string, secret string) (Webhook, error) {
+	created, err := repo.queries.InsertWebhook(context.Background(), repository.InsertWebhookParams{
+		AccountID: int32(accountId),
+		Url:       url,
+		Secret:    secret,
+	})
+	if err != nil {
+		return Webhook{}, err
+	}
+	return convertWebhook(created), nil
+}
+
+func (repo *webhookRepo) GetWebhooks() ([]Webhook, error) {
+	dbres, err := repo.queries.GetActiveWebhooks(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	webhooks := make([]Webhook, len(dbres))
+	for idx, w := range dbres {
+		webhooks[idx] = convertWebhook(w)
+	}
+	return webhooks, nil
+}
+
+func (repo *webhookRepo) DeleteWebhook(id int64, accountId int) (bool, error) {
+	affected, err := repo.queries.DeleteWebhook(context.Background(), repository.DeleteWebhookParams{
+		ID:        id,
+		AccountID: int32(accountId),
+	})
+	return affected > 0, err
+}
+
+func (repo *webhookRepo) CreateDelivery(webhookId int64, accountId int, event Event, payload json.RawMessage) (Delivery, error) {
+	created, err := repo.queries.InsertDelivery(context.Background(), repository.InsertDeliveryParams{
+		WebhookID: webhookId,
+		AccountID: int32(accountId),
+		Event:     string(event),
+		Payload:   payload,
+	})
+	if err != nil {
+		return Delivery{}, err
+	}
+	return convertDelivery(created), nil
+}
+
+func (repo *webhookRepo) UpdateDelivery(d Delivery) error {
+	return repo.queries.UpdateDeliveryAttempt(context.Background(), repository.UpdateDeliveryAttemptParams{
+		ID:             d.ID,
+		Status:         string(d.Status),
+		Attempts:       int32(d.Attempts),
+		LastStatusCode: pgtype.Int4{Int32: int32(d.LastStatusCode), Valid: d.LastStatusCode != 0},
+		LastError:      pgtype.Text{String: d.LastError, Valid: d.LastError != ""},
+		NextAttemptAt:  pgtype.Timestamp{Time: d.NextAttemptAt, Valid: true},
+	})
+}
+
+func (repo *webhookRepo) GetPendingDeliveries() ([]Delivery, error) {
+	dbres, err := repo.queries.GetPendingDeliveries(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]Delivery, len(dbres))
+	for idx, d := range dbres {
+		deliveries[idx] = convertDelivery(d)
+	}
+	return deliveries, nil
+}
+
+func (repo *webhookRepo) GetDeliveries(accountId int, page int, size int) ([]Delivery, error) {
+	dbres, err := repo.queries.GetDeliveriesByAccountID(context.Background(), repository.GetDeliveriesByAccountIDParams{
+		AccountID: int32(accountId),
+		Limit:     int32(size),
+		Offset:    int32(max(0, page-1) * size),
+	})
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]Delivery, len(dbres))
+	for idx, d := range dbres {
+		deliveries[idx] = convertDelivery(d)
+	}
+	return deliveries, nil
+}
+
+func NewWebhookRepository(dbpool *pgxpool.Pool) WebhookRepo {
+	return &webhookRepo{
+		queries: repository.New(dbpool),
+	}
+}
+
+func convertWebhook(w repository.TblWebhook) Webhook {
+	return Webhook{
+		ID:        w.ID,
+		AccountID: int(w.AccountID),
+		URL:       w.Url,
+		Secret:    w.Secret,
+		CreatedAt: w.CreatedAt.Time,
+	}
+}
+
+func convertDelivery(d repository.TblWebhookDelivery) Delivery {
+	res := Delivery{
+		ID:             d.ID,
+		WebhookID:      d.WebhookID,
+		AccountID:      int(d.AccountID),
+		Event:          Event(d.Event),
+		Payload:        d.Payload,
+		Status:         DeliveryStatus(d.Status),
+		Attempts:       int(d.Attempts),
+		LastStatusCode: int(d.LastStatusCode.Int32),
+		LastError:      d.LastError.String,
+		NextAttemptAt:  d.NextAttemptAt.Time,
+		CreatedAt:      d.CreatedAt.Time,
+	}
+	if d.DeliveredAt.Valid {
+		res.DeliveredAt = &d.DeliveredAt.Time
+	}
+	return res
+}
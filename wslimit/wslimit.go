@@ -0,0 +1,137 @@
+package wslimit
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+const localsKey = "wslimit_release"
+
+// Limits are the maximum numbers of open websocket connections, in total,
+// from one client IP and with one API key. 0 disables a limit.
+type Limits struct {
+	Total  int
+	PerIP  int
+	PerKey int
+}
+
+// Limiter counts the open websocket connections against Limits
+type Limiter struct {
+	limits Limits
+	mu     sync.Mutex
+	total  int
+	perIP  map[string]int
+	perKey map[string]int
+}
+
+func NewLimiter(limits Limits) *Limiter {
+	return &Limiter{
+		limits: limits,
+		perIP:  make(map[string]int),
+		perKey: make(map[string]int),
+	}
+}
+
+// Middleware refuses websocket upgrades over a limit with 429. An accepted
+// connection holds its slot until its handler calls Release, a failed
+// upgrade gives it back right away.
+func (l *Limiter) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return c.Next()
+		}
+		ip := strings.Clone(c.IP())
+		key := strings.Clone(c.Get("X-API-Key", c.Query("api_key")))
+		release, reason := l.acquire(ip, key)
+		if release == nil {
+			return fiber.NewError(http.StatusTooManyRequests, "Too many websocket connections "+reason)
+		}
+		c.Locals(localsKey, release)
+		err := c.Next()
+		if err != nil || c.Response().StatusCode() != http.StatusSwitchingProtocols {
+			release()
+		}
+		return err
+	}
+}
+
+// Release gives back the slot of a connection accepted by the Middleware,
+// websocket handlers defer it
+func Release(c *websocket.Conn) {
+	if release, ok := c.Locals(localsKey).(func()); ok {
+		release()
+	}
+}
+
+// SetLimits replaces the limits, the connections already open over them are
+// kept until they close
+func (l *Limiter) SetLimits(limits Limits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits = limits
+}
+
+// Stats are the open connections counted by the limiter
+type Stats struct {
+	Limits      Limits `json:"limits"`
+	Connections int    `json:"connections"`
+	IPs         int    `json:"ips"`
+	Keys        int    `json:"keys"`
+}
+
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Stats{
+		Limits:      l.limits,
+		Connections: l.total,
+		IPs:         len(l.perIP),
+		Keys:        len(l.perKey),
+	}
+}
+
+// acquire takes a slot for a connection from ip with key, an empty key only
+// counts against the total and the IP. It returns a nil release and the
+// limit reached when there is no room.
+func (l *Limiter) acquire(ip string, key string) (release func(), reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case l.limits.Total > 0 && l.total >= l.limits.Total:
+		return nil, "on this node"
+	case l.limits.PerIP > 0 && l.perIP[ip] >= l.limits.PerIP:
+		return nil, "from this IP"
+	case l.limits.PerKey > 0 && key != "" && l.perKey[key] >= l.limits.PerKey:
+		return nil, "with this API key"
+	}
+	l.total++
+	l.perIP[ip]++
+	if key != "" {
+		l.perKey[key]++
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.total--
+			decrement(l.perIP, ip)
+			if key != "" {
+				decrement(l.perKey, key)
+			}
+		})
+	}, ""
+}
+
+func decrement(counts map[string]int, k string) {
+	if counts[k] <= 1 {
+		delete(counts, k)
+		return
+	}
+	counts[k]--
+}